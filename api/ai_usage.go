@@ -0,0 +1,157 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"nofx/mcp"
+	"nofx/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAIUsageDays = 30
+	maxAIUsageDays     = 366
+)
+
+// aiUsageSince parses the "days" query parameter (0 = all time) into a start timestamp
+func aiUsageSince(c *gin.Context) (days int, since int64, ok bool) {
+	days = defaultAIUsageDays
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxAIUsageDays {
+			SafeBadRequest(c, "days must be between 0 and 366")
+			return 0, 0, false
+		}
+		days = n
+	}
+	if days > 0 {
+		since = time.Now().UTC().AddDate(0, 0, -days).UnixMilli()
+	}
+	return days, since, true
+}
+
+// ownedTrader loads a trader and checks it belongs to the user (writes a 404 otherwise)
+func (s *Server) ownedTrader(c *gin.Context, userID, traderID string) (*store.Trader, bool) {
+	trader, err := s.store.Trader().GetByID(traderID)
+	if err != nil || trader.UserID != userID {
+		SafeNotFound(c, "Trader")
+		return nil, false
+	}
+	return trader, true
+}
+
+// handleAIUsageDaily LLM token usage and cost per source (trader by default) per day
+func (s *Server) handleAIUsageDaily(c *gin.Context) {
+	userID := c.GetString("user_id")
+	days, since, ok := aiUsageSince(c)
+	if !ok {
+		return
+	}
+
+	filter := store.AIUsageFilter{
+		Source: c.DefaultQuery("source", mcp.UsageSourceTrader),
+		Since:  since,
+	}
+	if traderID := c.Query("trader_id"); traderID != "" {
+		if _, ok := s.ownedTrader(c, userID, traderID); !ok {
+			return
+		}
+		filter.Source = mcp.UsageSourceTrader
+		filter.SourceID = traderID
+	} else {
+		filter.UserID = userID
+	}
+
+	rows, err := s.store.AIUsage().Daily(filter)
+	if err != nil {
+		SafeInternalError(c, "Get AI usage", err)
+		return
+	}
+
+	totalCost := 0.0
+	for _, row := range rows {
+		totalCost += row.CostUSD
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"days":           days,
+		"source":         filter.Source,
+		"rows":           rows,
+		"total_cost_usd": totalCost,
+	})
+}
+
+// handleAICostPerTrade LLM cost of a trader against its closed trades, to judge whether a model pays for itself
+func (s *Server) handleAICostPerTrade(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+	if _, ok := s.ownedTrader(c, userID, traderID); !ok {
+		return
+	}
+	days, since, ok := aiUsageSince(c)
+	if !ok {
+		return
+	}
+
+	cost, err := s.store.AIUsage().TotalCost(store.AIUsageFilter{
+		Source:   mcp.UsageSourceTrader,
+		SourceID: traderID,
+		Since:    since,
+	})
+	if err != nil {
+		SafeInternalError(c, "Get AI cost", err)
+		return
+	}
+	trades, err := s.store.Position().GetClosedTradeCounts(traderID, since)
+	if err != nil {
+		SafeInternalError(c, "Get closed trades", err)
+		return
+	}
+
+	result := gin.H{
+		"trader_id":                     traderID,
+		"days":                          days,
+		"ai_cost_usd":                   cost,
+		"total_trades":                  trades.Total,
+		"profitable_trades":             trades.Wins,
+		"realized_pnl":                  trades.TotalPnL,
+		"net_pnl_after_ai":              trades.TotalPnL - cost,
+		"cost_per_trade_usd":            nil,
+		"cost_per_profitable_trade_usd": nil,
+	}
+	if trades.Total > 0 {
+		result["cost_per_trade_usd"] = cost / float64(trades.Total)
+	}
+	if trades.Wins > 0 {
+		result["cost_per_profitable_trade_usd"] = cost / float64(trades.Wins)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// handleGetAIModelPrices lists the model price table (USD per 1M tokens)
+func (s *Server) handleGetAIModelPrices(c *gin.Context) {
+	prices, err := s.store.AIUsage().ListPrices()
+	if err != nil {
+		SafeInternalError(c, "Get model prices", err)
+		return
+	}
+	c.JSON(http.StatusOK, prices)
+}
+
+// handleUpdateAIModelPrices creates or updates model prices
+func (s *Server) handleUpdateAIModelPrices(c *gin.Context) {
+	var prices []store.AIModelPrice
+	if err := c.ShouldBindJSON(&prices); err != nil {
+		SafeBadRequest(c, "Invalid request parameters")
+		return
+	}
+	for i := range prices {
+		if err := s.store.AIUsage().SetPrice(&prices[i]); err != nil {
+			SafeError(c, http.StatusBadRequest, "Failed to update model price", err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, prices)
+}
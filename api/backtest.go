@@ -36,7 +36,16 @@ func (s *Server) registerBacktestRoutes(router *gin.RouterGroup) {
 	router.GET("/trace", s.handleBacktestTrace)
 	router.GET("/decisions", s.handleBacktestDecisions)
 	router.GET("/export", s.handleBacktestExport)
+	router.POST("/import", s.handleBacktestImport)
 	router.GET("/klines", s.handleBacktestKlines)
+	router.POST("/sweep/start", s.handleBacktestSweepStart)
+	router.POST("/sweep/stop", s.handleBacktestSweepStop)
+	router.GET("/sweep/status", s.handleBacktestSweepStatus)
+	router.GET("/sweeps", s.handleBacktestSweeps)
+	router.GET("/leaderboard", s.handleBacktestLeaderboard)
+	router.POST("/klines/import", s.handleKlineImport)
+	router.GET("/klines/gaps", s.handleKlineGaps)
+	router.POST("/klines/backfill", s.handleKlineBackfill)
 }
 
 type backtestStartRequest struct {
@@ -68,8 +77,30 @@ func (s *Server) handleBacktestStart(c *gin.Context) {
 	if cfg.RunID == "" {
 		cfg.RunID = "bt_" + time.Now().UTC().Format("20060102_150405")
 	}
-	cfg.CustomPrompt = strings.TrimSpace(cfg.CustomPrompt)
 	cfg.UserID = normalizeUserID(c.GetString("user_id"))
+	if !s.prepareBacktestConfig(c, &cfg) {
+		return
+	}
+
+	logger.Infof("📊 Starting backtest with final config: runID=%s, symbols=%v (count=%d), strategyID=%s",
+		cfg.RunID, cfg.Symbols, len(cfg.Symbols), cfg.StrategyID)
+
+	runner, err := s.backtestManager.Start(context.Background(), cfg)
+	if err != nil {
+		SafeError(c, http.StatusBadRequest, "Failed to start backtest", err)
+		return
+	}
+	s.recordBacktestPromptAssignment(cfg)
+
+	meta := runner.CurrentMetadata()
+	c.JSON(http.StatusOK, meta)
+}
+
+// prepareBacktestConfig loads the saved strategy and AI model into cfg.
+// It writes the error response and returns false on failure.
+func (s *Server) prepareBacktestConfig(c *gin.Context, cfg *backtest.BacktestConfig) bool {
+	cfg.CustomPrompt = strings.TrimSpace(cfg.CustomPrompt)
+	cfg.ReplayKlinesPath = "" // only set by bundle imports
 
 	logger.Infof("📊 Backtest request - symbols from request: %v (count=%d), strategyID: %s",
 		cfg.Symbols, len(cfg.Symbols), cfg.StrategyID)
@@ -79,16 +110,16 @@ func (s *Server) handleBacktestStart(c *gin.Context) {
 		strategy, err := s.store.Strategy().Get(cfg.UserID, cfg.StrategyID)
 		if err != nil {
 			SafeBadRequest(c, "Failed to load strategy")
-			return
+			return false
 		}
 		if strategy == nil {
 			SafeBadRequest(c, "Strategy not found")
-			return
+			return false
 		}
 		var strategyConfig store.StrategyConfig
 		if err := json.Unmarshal([]byte(strategy.Config), &strategyConfig); err != nil {
 			SafeBadRequest(c, "Failed to parse strategy config")
-			return
+			return false
 		}
 		cfg.SetLoadedStrategy(&strategyConfig)
 		logger.Infof("📊 Backtest using saved strategy: %s (%s)", strategy.Name, strategy.ID)
@@ -98,34 +129,30 @@ func (s *Server) handleBacktestStart(c *gin.Context) {
 			strategyConfig.CoinSource.UseOITop,
 			strategyConfig.CoinSource.StaticCoins)
 
-		// If no symbols provided, fetch from strategy's coin source
-		if len(cfg.Symbols) == 0 {
+		// If no symbols provided, fetch from strategy's coin source (grid strategies trade their own symbol)
+		if len(cfg.Symbols) == 0 && !cfg.IsGrid() {
 			symbols, err := s.resolveStrategyCoins(&strategyConfig)
 			if err != nil {
 				SafeBadRequest(c, "Failed to resolve coins from strategy")
-				return
+				return false
 			}
 			cfg.Symbols = symbols
 			logger.Infof("📊 Resolved %d coins from strategy: %v", len(symbols), symbols)
 		}
 	}
 
-	if err := s.hydrateBacktestAIConfig(&cfg); err != nil {
-		SafeBadRequest(c, "Failed to configure AI model")
-		return
+	if !s.applyBacktestPromptTemplate(c, cfg) {
+		return false
 	}
 
-	logger.Infof("📊 Starting backtest with final config: runID=%s, symbols=%v (count=%d), strategyID=%s",
-		cfg.RunID, cfg.Symbols, len(cfg.Symbols), cfg.StrategyID)
-
-	runner, err := s.backtestManager.Start(context.Background(), cfg)
-	if err != nil {
-		SafeError(c, http.StatusBadRequest, "Failed to start backtest", err)
-		return
+	// Grid runs simulate resting orders and rule runs evaluate their rules; neither calls the AI
+	if !cfg.IsGrid() && !cfg.IsRules() {
+		if err := s.hydrateBacktestAIConfig(cfg); err != nil {
+			SafeBadRequest(c, "Failed to configure AI model")
+			return false
+		}
 	}
-
-	meta := runner.CurrentMetadata()
-	c.JSON(http.StatusOK, meta)
+	return true
 }
 
 func (s *Server) handleBacktestPause(c *gin.Context) {
@@ -491,6 +518,33 @@ func (s *Server) handleBacktestExport(c *gin.Context) {
 	c.FileAttachment(path, filename)
 }
 
+// handleBacktestImport re-runs an exported run (multipart field "file") offline from its replay bundle
+func (s *Server) handleBacktestImport(c *gin.Context) {
+	if s.backtestManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backtest manager unavailable"})
+		return
+	}
+	userID := normalizeUserID(c.GetString("user_id"))
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		SafeBadRequest(c, "file is required")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		SafeInternalError(c, "Open uploaded file", err)
+		return
+	}
+	defer file.Close()
+
+	runner, err := s.backtestManager.ImportBundle(file, fileHeader.Size, c.PostForm("run_id"), userID)
+	if err != nil {
+		SafeError(c, http.StatusBadRequest, "Failed to import backtest bundle", err)
+		return
+	}
+	c.JSON(http.StatusOK, runner.CurrentMetadata())
+}
+
 func (s *Server) handleBacktestKlines(c *gin.Context) {
 	if s.backtestManager == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backtest manager unavailable"})
@@ -573,6 +627,138 @@ func (s *Server) handleBacktestKlines(c *gin.Context) {
 	})
 }
 
+type sweepIDRequest struct {
+	SweepID string `json:"sweep_id"`
+}
+
+func (s *Server) handleBacktestSweepStart(c *gin.Context) {
+	if s.backtestManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backtest manager unavailable"})
+		return
+	}
+
+	var spec backtest.SweepSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		SafeBadRequest(c, "Invalid request parameters")
+		return
+	}
+	if strings.TrimSpace(spec.SweepID) == "" {
+		spec.SweepID = "sweep_" + time.Now().UTC().Format("20060102_150405")
+	}
+	spec.Base.UserID = normalizeUserID(c.GetString("user_id"))
+	if spec.Base.PromptExperimentID != "" {
+		SafeBadRequest(c, "Sweeps cannot join a prompt experiment, pick a prompt template instead")
+		return
+	}
+	if !s.prepareBacktestConfig(c, &spec.Base) {
+		return
+	}
+
+	status, err := s.backtestManager.StartSweep(spec)
+	if err != nil {
+		SafeError(c, http.StatusBadRequest, "Failed to start backtest sweep", err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handleBacktestSweepStop(c *gin.Context) {
+	if s.backtestManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backtest manager unavailable"})
+		return
+	}
+	var req sweepIDRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SafeBadRequest(c, "Invalid request parameters")
+		return
+	}
+	userID := normalizeUserID(c.GetString("user_id"))
+	if _, err := s.ensureBacktestSweepOwnership(req.SweepID, userID); writeBacktestAccessError(c, err) {
+		return
+	}
+	if err := s.backtestManager.StopSweep(req.SweepID); err != nil {
+		SafeError(c, http.StatusBadRequest, "Failed to stop backtest sweep", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "stopping"})
+}
+
+func (s *Server) handleBacktestSweepStatus(c *gin.Context) {
+	if s.backtestManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backtest manager unavailable"})
+		return
+	}
+	userID := normalizeUserID(c.GetString("user_id"))
+	status, err := s.ensureBacktestSweepOwnership(c.Query("sweep_id"), userID)
+	if writeBacktestAccessError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) handleBacktestSweeps(c *gin.Context) {
+	if s.backtestManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backtest manager unavailable"})
+		return
+	}
+	rawUserID := strings.TrimSpace(c.GetString("user_id"))
+	userID := normalizeUserID(rawUserID)
+	filterByUser := rawUserID != "" && rawUserID != "admin"
+
+	sweeps, err := s.backtestManager.ListSweeps()
+	if err != nil {
+		SafeInternalError(c, "List backtest sweeps", err)
+		return
+	}
+	items := make([]*backtest.SweepStatus, 0, len(sweeps))
+	for _, sweep := range sweeps {
+		if filterByUser && sweep.UserID != "" && sweep.UserID != userID {
+			continue
+		}
+		items = append(items, sweep)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(items),
+		"items": items,
+	})
+}
+
+func (s *Server) handleBacktestLeaderboard(c *gin.Context) {
+	if s.backtestManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "backtest manager unavailable"})
+		return
+	}
+	userID := normalizeUserID(c.GetString("user_id"))
+	sweepID := c.Query("sweep_id")
+	if _, err := s.ensureBacktestSweepOwnership(sweepID, userID); writeBacktestAccessError(c, err) {
+		return
+	}
+	board, err := s.backtestManager.SweepLeaderboard(sweepID)
+	if err != nil {
+		SafeInternalError(c, "Build backtest leaderboard", err)
+		return
+	}
+	c.JSON(http.StatusOK, board)
+}
+
+func (s *Server) ensureBacktestSweepOwnership(sweepID, userID string) (*backtest.SweepStatus, error) {
+	sweepID = strings.TrimSpace(sweepID)
+	if sweepID == "" || strings.ContainsAny(sweepID, `/\`) {
+		return nil, os.ErrNotExist
+	}
+	status, err := s.backtestManager.GetSweep(sweepID)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" || userID == "admin" || status.UserID == "" {
+		return status, nil
+	}
+	if status.UserID != userID {
+		return nil, errBacktestForbidden
+	}
+	return status, nil
+}
+
 func queryInt(c *gin.Context, name string, fallback int) int {
 	if value := c.Query(name); value != "" {
 		if v, err := strconv.Atoi(value); err == nil {
@@ -816,7 +1002,7 @@ func (s *Server) hydrateBacktestAIConfig(cfg *backtest.BacktestConfig) error {
 	}
 
 	apiKey := strings.TrimSpace(string(model.APIKey))
-	if apiKey == "" {
+	if apiKey == "" && model.Provider != "local" { // Local model servers run without a key
 		return fmt.Errorf("AI model %s is missing API Key, please configure it in the system first", model.Name)
 	}
 
@@ -0,0 +1,477 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/kernel"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultReplayCycles         = 50
+	maxReplayCycles             = 500
+	defaultReplayHorizonMinutes = 240
+	defaultReplayTimeframe      = "5m"
+	defaultReplayFeeBps         = 4.0 // Taker fee per side
+	maxFinishedReplays          = 20  // Finished replays kept in memory
+)
+
+// Decision replay status
+const (
+	replayRunning   = "running"
+	replayCompleted = "completed"
+	replayCancelled = "cancelled"
+)
+
+// decisionReplayRequest replays a trader's recorded decision cycles against another model and/or prompt template
+type decisionReplayRequest struct {
+	TraderID              string  `json:"trader_id"`
+	StartTime             int64   `json:"start_time"`              // Unix milliseconds
+	EndTime               int64   `json:"end_time"`                // Unix milliseconds (default: now)
+	AIModelID             string  `json:"ai_model_id"`             // Default: the trader's model
+	PromptTemplateID      string  `json:"prompt_template_id"`      // Default: the recorded system prompt
+	PromptTemplateVersion int     `json:"prompt_template_version"` // 0 = current version
+	MaxCycles             int     `json:"max_cycles"`
+	HorizonMinutes        int     `json:"horizon_minutes"` // PnL evaluation window after each decision
+	KlineTimeframe        string  `json:"kline_timeframe"`
+	FeeBps                float64 `json:"fee_bps"`
+}
+
+// decisionReplayCycle one recorded cycle with its replayed decisions
+type decisionReplayCycle struct {
+	RecordID          int64                 `json:"record_id"`
+	CycleNumber       int                   `json:"cycle_number"`
+	Timestamp         int64                 `json:"timestamp"`
+	Skipped           string                `json:"skipped,omitempty"` // Why the cycle was not replayed
+	Error             string                `json:"error,omitempty"`
+	OriginalDecisions []kernel.Decision     `json:"original_decisions"`
+	ReplayedDecisions []kernel.Decision     `json:"replayed_decisions"`
+	ReplayedCoT       string                `json:"replayed_cot,omitempty"`
+	Diffs             []kernel.DecisionDiff `json:"diffs"`
+	OriginalPnL       float64               `json:"original_pnl"` // Estimated over the horizon
+	ReplayedPnL       float64               `json:"replayed_pnl"`
+}
+
+// decisionReplaySummary totals over all replayed cycles
+type decisionReplaySummary struct {
+	Replayed      int     `json:"replayed"`
+	Skipped       int     `json:"skipped"`
+	Failed        int     `json:"failed"`
+	ChangedCycles int     `json:"changed_cycles"` // Cycles where the replay traded differently
+	AgreementPct  float64 `json:"agreement_pct"`
+	OriginalPnL   float64 `json:"original_pnl"`
+	ReplayedPnL   float64 `json:"replayed_pnl"`
+	PnLDelta      float64 `json:"pnl_delta"`
+}
+
+// decisionReplay a replay job; fields are guarded by mu while it runs
+type decisionReplay struct {
+	mu sync.Mutex
+
+	ID         string                 `json:"id"`
+	UserID     string                 `json:"-"`
+	Request    decisionReplayRequest  `json:"request"`
+	Status     string                 `json:"status"`
+	Total      int                    `json:"total"`
+	Done       int                    `json:"done"`
+	Cycles     []*decisionReplayCycle `json:"cycles,omitempty"`
+	Summary    decisionReplaySummary  `json:"summary"`
+	CreatedAt  int64                  `json:"created_at"`
+	FinishedAt int64                  `json:"finished_at,omitempty"`
+
+	cancel chan struct{}
+}
+
+// decisionReplayRegistry in-memory replay jobs (lost on restart; replays are cheap to rerun)
+type decisionReplayRegistry struct {
+	mu      sync.Mutex
+	replays map[string]*decisionReplay
+}
+
+func newDecisionReplayRegistry() *decisionReplayRegistry {
+	return &decisionReplayRegistry{replays: make(map[string]*decisionReplay)}
+}
+
+// add registers a replay and drops the oldest finished ones beyond maxFinishedReplays
+func (r *decisionReplayRegistry) add(replay *decisionReplay) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replays[replay.ID] = replay
+
+	var finished []*decisionReplay
+	for _, rp := range r.replays {
+		rp.mu.Lock()
+		if rp.Status != replayRunning {
+			finished = append(finished, rp)
+		}
+		rp.mu.Unlock()
+	}
+	if len(finished) <= maxFinishedReplays {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].CreatedAt < finished[j].CreatedAt })
+	for _, rp := range finished[:len(finished)-maxFinishedReplays] {
+		delete(r.replays, rp.ID)
+	}
+}
+
+func (r *decisionReplayRegistry) get(userID, id string) *decisionReplay {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if replay := r.replays[id]; replay != nil && replay.UserID == userID {
+		return replay
+	}
+	return nil
+}
+
+func (r *decisionReplayRegistry) list(userID string) []*decisionReplay {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var replays []*decisionReplay
+	for _, replay := range r.replays {
+		if replay.UserID == userID {
+			replays = append(replays, replay)
+		}
+	}
+	sort.Slice(replays, func(i, j int) bool { return replays[i].CreatedAt > replays[j].CreatedAt })
+	return replays
+}
+
+// snapshot copies the replay for JSON output; cycles are left out unless withCycles
+func (rp *decisionReplay) snapshot(withCycles bool) *decisionReplay {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	out := &decisionReplay{
+		ID:         rp.ID,
+		Request:    rp.Request,
+		Status:     rp.Status,
+		Total:      rp.Total,
+		Done:       rp.Done,
+		Summary:    rp.Summary,
+		CreatedAt:  rp.CreatedAt,
+		FinishedAt: rp.FinishedAt,
+	}
+	if withCycles {
+		out.Cycles = append([]*decisionReplayCycle(nil), rp.Cycles...)
+	}
+	return out
+}
+
+// handleStartDecisionReplay starts replaying a trader's recorded decision cycles in the background
+func (s *Server) handleStartDecisionReplay(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req decisionReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TraderID == "" || req.StartTime <= 0 {
+		SafeBadRequest(c, "trader_id and start_time are required")
+		return
+	}
+	traderCfg, ok := s.ownedTrader(c, userID, req.TraderID)
+	if !ok {
+		return
+	}
+
+	if req.EndTime <= 0 {
+		req.EndTime = time.Now().UnixMilli()
+	}
+	if req.EndTime <= req.StartTime {
+		SafeBadRequest(c, "end_time must be after start_time")
+		return
+	}
+	if req.MaxCycles <= 0 {
+		req.MaxCycles = defaultReplayCycles
+	}
+	if req.MaxCycles > maxReplayCycles {
+		SafeBadRequest(c, fmt.Sprintf("max_cycles must be at most %d", maxReplayCycles))
+		return
+	}
+	if req.HorizonMinutes <= 0 {
+		req.HorizonMinutes = defaultReplayHorizonMinutes
+	}
+	if req.KlineTimeframe == "" {
+		req.KlineTimeframe = defaultReplayTimeframe
+	}
+	if _, err := market.NormalizeTimeframe(req.KlineTimeframe); err != nil {
+		SafeBadRequest(c, "Invalid kline_timeframe")
+		return
+	}
+	if req.FeeBps <= 0 {
+		req.FeeBps = defaultReplayFeeBps
+	}
+	if req.AIModelID == "" {
+		req.AIModelID = traderCfg.AIModelID
+	}
+
+	// Risk limits (and the alternative prompt, if any) come from the trader's strategy
+	strategy, err := s.store.Strategy().Get(userID, traderCfg.StrategyID)
+	if err != nil || strategy == nil {
+		SafeBadRequest(c, "Trader has no strategy configured")
+		return
+	}
+	strategyConfig, err := strategy.ParseConfig()
+	if err != nil {
+		SafeInternalError(c, "Parse strategy config", err)
+		return
+	}
+	var promptContent *store.PromptTemplateContent
+	if req.PromptTemplateID != "" {
+		template, ok := s.ownedPromptTemplate(c, req.PromptTemplateID)
+		if !ok {
+			return
+		}
+		version, content, ok := s.promptTemplateVersion(c, template.ID, req.PromptTemplateVersion)
+		if !ok {
+			return
+		}
+		req.PromptTemplateVersion = version.Version
+		content.ApplyTo(strategyConfig)
+		promptContent = content
+	}
+
+	records, err := s.store.Decision().GetRecordsBetween(traderCfg.ID, time.UnixMilli(req.StartTime), time.UnixMilli(req.EndTime), req.MaxCycles)
+	if err != nil {
+		SafeInternalError(c, "Get decision records", err)
+		return
+	}
+	if len(records) == 0 {
+		SafeBadRequest(c, "No decision records in this time range")
+		return
+	}
+
+	replay := &decisionReplay{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Request:   req,
+		Status:    replayRunning,
+		Total:     len(records),
+		CreatedAt: time.Now().UnixMilli(),
+		cancel:    make(chan struct{}),
+	}
+	aiClient, err := s.newUserAIClient(userID, req.AIModelID)
+	if err != nil {
+		SafeError(c, http.StatusBadRequest, "Failed to create AI client", err)
+		return
+	}
+	mcp.AttributeUsage(aiClient, mcp.UsageAttribution{UserID: userID, Source: mcp.UsageSourceReplay, SourceID: replay.ID})
+
+	s.decisionReplays.add(replay)
+	runner := &decisionReplayRunner{
+		replay:         replay,
+		client:         aiClient,
+		engine:         kernel.NewStrategyEngine(strategyConfig),
+		promptContent:  promptContent,
+		initialBalance: traderCfg.InitialBalance,
+		klines:         make(map[string][]market.Kline),
+	}
+	go runner.run(records)
+
+	logger.Infof("🔁 Decision replay %s started: trader=%s, cycles=%d, model=%s, template=%s",
+		replay.ID, traderCfg.Name, len(records), req.AIModelID, req.PromptTemplateID)
+	c.JSON(http.StatusOK, replay.snapshot(false))
+}
+
+// handleListDecisionReplays lists the user's replays without their cycles
+func (s *Server) handleListDecisionReplays(c *gin.Context) {
+	replays := s.decisionReplays.list(c.GetString("user_id"))
+	out := make([]*decisionReplay, len(replays))
+	for i, replay := range replays {
+		out[i] = replay.snapshot(false)
+	}
+	c.JSON(http.StatusOK, gin.H{"replays": out})
+}
+
+// handleGetDecisionReplay returns a replay with the cycles replayed so far
+func (s *Server) handleGetDecisionReplay(c *gin.Context) {
+	replay := s.decisionReplays.get(c.GetString("user_id"), c.Param("id"))
+	if replay == nil {
+		SafeNotFound(c, "Decision replay")
+		return
+	}
+	c.JSON(http.StatusOK, replay.snapshot(true))
+}
+
+// handleCancelDecisionReplay stops a running replay after the cycle in progress
+func (s *Server) handleCancelDecisionReplay(c *gin.Context) {
+	replay := s.decisionReplays.get(c.GetString("user_id"), c.Param("id"))
+	if replay == nil {
+		SafeNotFound(c, "Decision replay")
+		return
+	}
+	replay.mu.Lock()
+	if replay.Status == replayRunning {
+		replay.Status = replayCancelled
+		close(replay.cancel)
+	}
+	replay.mu.Unlock()
+	c.JSON(http.StatusOK, replay.snapshot(false))
+}
+
+// decisionReplayRunner replays recorded cycles one by one (sequential, to stay within provider rate limits)
+type decisionReplayRunner struct {
+	replay         *decisionReplay
+	client         mcp.AIClient
+	engine         *kernel.StrategyEngine
+	promptContent  *store.PromptTemplateContent // nil: resend the recorded system prompt
+	initialBalance float64
+	klines         map[string][]market.Kline // Per symbol over the whole replay range
+}
+
+func (r *decisionReplayRunner) run(records []*store.DecisionRecord) {
+	req := r.replay.Request
+	for _, record := range records {
+		select {
+		case <-r.replay.cancel:
+			r.finish()
+			return
+		default:
+		}
+
+		cycle := r.replayCycle(record, req)
+
+		r.replay.mu.Lock()
+		r.replay.Cycles = append(r.replay.Cycles, cycle)
+		r.replay.Done++
+		addReplayCycle(&r.replay.Summary, cycle)
+		r.replay.mu.Unlock()
+	}
+	r.finish()
+}
+
+// finish marks the replay done (a cancelled replay stays cancelled)
+func (r *decisionReplayRunner) finish() {
+	r.replay.mu.Lock()
+	defer r.replay.mu.Unlock()
+	if r.replay.Status == replayRunning {
+		r.replay.Status = replayCompleted
+	}
+	r.replay.FinishedAt = time.Now().UnixMilli()
+	logger.Infof("🔁 Decision replay %s %s: %d/%d cycles, PnL %.2f → %.2f",
+		r.replay.ID, r.replay.Status, r.replay.Done, r.replay.Total, r.replay.Summary.OriginalPnL, r.replay.Summary.ReplayedPnL)
+}
+
+func (r *decisionReplayRunner) replayCycle(record *store.DecisionRecord, req decisionReplayRequest) *decisionReplayCycle {
+	cycle := &decisionReplayCycle{
+		RecordID:    record.ID,
+		CycleNumber: record.CycleNumber,
+		Timestamp:   record.Timestamp.UnixMilli(),
+	}
+	switch {
+	case record.InputPrompt == "" || record.SystemPrompt == "":
+		cycle.Skipped = "no AI call recorded in this cycle"
+		return cycle
+	case kernel.IsToolCallingPrompt(record.SystemPrompt):
+		cycle.Skipped = "market data was fetched through tools at decision time and is not in the recorded prompt"
+		return cycle
+	}
+
+	original, err := kernel.ParseRecordedDecisions(record.DecisionJSON)
+	if err != nil {
+		cycle.Error = err.Error()
+		return cycle
+	}
+	cycle.OriginalDecisions = original
+
+	equity := record.AccountState.TotalBalance
+	if equity <= 0 {
+		equity = r.initialBalance // Records from before account snapshots were stored
+	}
+	systemPrompt := record.SystemPrompt
+	if r.promptContent != nil {
+		variant := r.promptContent.PromptVariant
+		if variant == "" {
+			variant = "balanced"
+		}
+		systemPrompt = r.engine.BuildSystemPrompt(equity, variant)
+	}
+
+	replayed, err := kernel.ReplayDecision(r.client, r.engine, systemPrompt, record.InputPrompt, equity)
+	if replayed != nil {
+		cycle.ReplayedDecisions = replayed.Decisions
+		cycle.ReplayedCoT = replayed.CoTTrace
+	}
+	if err != nil {
+		cycle.Error = err.Error()
+		return cycle
+	}
+	cycle.Diffs = kernel.DiffDecisions(original, cycle.ReplayedDecisions)
+
+	// Evaluate both decision lists over the same horizon on historical klines
+	from := cycle.Timestamp
+	until := from + int64(req.HorizonMinutes)*time.Minute.Milliseconds()
+	if now := time.Now().UnixMilli(); until > now {
+		until = now
+	}
+	feeRate := req.FeeBps / 10000
+	klines := r.klinesFor(record, original, cycle.ReplayedDecisions)
+	cycle.OriginalPnL = kernel.EstimateHorizonPnL(original, record.Positions, klines, from, until, feeRate)
+	cycle.ReplayedPnL = kernel.EstimateHorizonPnL(cycle.ReplayedDecisions, record.Positions, klines, from, until, feeRate)
+	return cycle
+}
+
+// klinesFor returns the historical klines of every symbol a cycle holds or trades, fetching each
+// symbol once for the whole replay range
+func (r *decisionReplayRunner) klinesFor(record *store.DecisionRecord, decisionLists ...[]kernel.Decision) map[string][]market.Kline {
+	symbols := make(map[string]bool)
+	for _, pos := range record.Positions {
+		symbols[pos.Symbol] = true
+	}
+	for _, decisions := range decisionLists {
+		for _, d := range decisions {
+			if strings.HasPrefix(d.Action, "open_") || strings.HasPrefix(d.Action, "close_") {
+				symbols[d.Symbol] = true
+			}
+		}
+	}
+
+	req := r.replay.Request
+	start := time.UnixMilli(req.StartTime)
+	end := time.UnixMilli(req.EndTime).Add(time.Duration(req.HorizonMinutes) * time.Minute)
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	for symbol := range symbols {
+		if _, ok := r.klines[symbol]; ok {
+			continue
+		}
+		klines, err := market.GetKlinesRange(symbol, req.KlineTimeframe, start, end)
+		if err != nil {
+			logger.Warnf("⚠️ Decision replay %s: no klines for %s: %v", r.replay.ID, symbol, err)
+		}
+		r.klines[symbol] = klines // Cache failures too, the PnL of that symbol stays 0
+	}
+	return r.klines
+}
+
+// addReplayCycle adds a cycle to the replay totals
+func addReplayCycle(summary *decisionReplaySummary, cycle *decisionReplayCycle) {
+	switch {
+	case cycle.Skipped != "":
+		summary.Skipped++
+		return
+	case cycle.Error != "":
+		summary.Failed++
+		return
+	}
+
+	summary.Replayed++
+	for _, diff := range cycle.Diffs {
+		if diff.Change != kernel.DecisionSame {
+			summary.ChangedCycles++
+			break
+		}
+	}
+	summary.AgreementPct = float64(summary.Replayed-summary.ChangedCycles) / float64(summary.Replayed) * 100
+	summary.OriginalPnL += cycle.OriginalPnL
+	summary.ReplayedPnL += cycle.ReplayedPnL
+	summary.PnLDelta = summary.ReplayedPnL - summary.OriginalPnL
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+
+	"nofx/kernel"
+)
+
+func TestAddReplayCycle(t *testing.T) {
+	var summary decisionReplaySummary
+	cycles := []*decisionReplayCycle{
+		{Diffs: []kernel.DecisionDiff{{Symbol: "BTCUSDT", Change: kernel.DecisionSame}}, OriginalPnL: 10, ReplayedPnL: 12},
+		{Diffs: []kernel.DecisionDiff{{Symbol: "ETHUSDT", Change: kernel.DecisionAdded}}, OriginalPnL: 0, ReplayedPnL: -5},
+		{Skipped: "tool calling"},
+		{Error: "timeout"},
+	}
+	for _, cycle := range cycles {
+		addReplayCycle(&summary, cycle)
+	}
+
+	if summary.Replayed != 2 || summary.Skipped != 1 || summary.Failed != 1 {
+		t.Errorf("unexpected counts: %+v", summary)
+	}
+	if summary.ChangedCycles != 1 || summary.AgreementPct != 50 {
+		t.Errorf("unexpected agreement: %+v", summary)
+	}
+	if summary.OriginalPnL != 10 || summary.ReplayedPnL != 7 || summary.PnLDelta != -3 {
+		t.Errorf("unexpected PnL: %+v", summary)
+	}
+}
+
+func TestDecisionReplayRegistryDropsOldestFinished(t *testing.T) {
+	registry := newDecisionReplayRegistry()
+	running := &decisionReplay{ID: "running", UserID: "u1", Status: replayRunning, CreatedAt: 0}
+	registry.add(running)
+	for i := 1; i <= maxFinishedReplays+2; i++ {
+		registry.add(&decisionReplay{ID: fmt.Sprint(i), UserID: "u1", Status: replayCompleted, CreatedAt: int64(i)})
+	}
+
+	if registry.get("u1", "running") == nil {
+		t.Error("running replay must never be dropped")
+	}
+	if registry.get("u1", "1") != nil || registry.get("u1", "2") != nil {
+		t.Error("oldest finished replays should be dropped")
+	}
+	if registry.get("u1", fmt.Sprint(maxFinishedReplays+2)) == nil {
+		t.Error("newest replay missing")
+	}
+	if registry.get("u2", "running") != nil {
+		t.Error("replays of other users must not be visible")
+	}
+	if n := len(registry.list("u1")); n != maxFinishedReplays+1 {
+		t.Errorf("got %d replays, want %d", n, maxFinishedReplays+1)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// decisionStreamKeepAlive comment sent between cycles so proxies keep the connection open
+const decisionStreamKeepAlive = 15 * time.Second
+
+// handleTraderDecisionStream streams a trader's AI decision cycles over SSE: cycle_start, the model
+// output as chunk events while it is generated, and cycle_end with the parsed decisions
+func (s *Server) handleTraderDecisionStream(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+	if _, ok := s.ownedTrader(c, userID, traderID); !ok {
+		return
+	}
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		SafeNotFound(c, "Trader")
+		return
+	}
+
+	// Set SSE headers
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Transfer-Encoding", "chunked")
+
+	events, snapshot, cancel := at.SubscribeDecisionStream()
+	defer cancel()
+
+	// Send initial state (output of the cycle in progress, if any)
+	running, _ := at.GetStatus()["is_running"].(bool)
+	initialData, _ := json.Marshal(map[string]interface{}{
+		"trader_id":  traderID,
+		"is_running": running,
+		"cycle":      snapshot,
+	})
+	c.Writer.Write([]byte(fmt.Sprintf("event: initial\ndata: %s\n\n", initialData)))
+	c.Writer.Flush()
+
+	// Stream updates
+	keepAlive := time.NewTicker(decisionStreamKeepAlive)
+	defer keepAlive.Stop()
+	clientGone := c.Request.Context().Done()
+	for {
+		select {
+		case <-clientGone:
+			return
+		case <-keepAlive.C:
+			c.Writer.Write([]byte(": keep-alive\n\n"))
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(event)
+			c.Writer.Write([]byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data)))
+			c.Writer.Flush()
+		}
+	}
+}
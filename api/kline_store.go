@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"nofx/market"
+
+	"github.com/gin-gonic/gin"
+)
+
+type klineBackfillRequest struct {
+	Symbol    string `json:"symbol"`
+	Timeframe string `json:"timeframe"`
+	StartTS   int64  `json:"start_ts"`
+	EndTS     int64  `json:"end_ts"`
+}
+
+// handleKlineImport imports a CSV kline dump (multipart field "file") into the local kline store
+func (s *Server) handleKlineImport(c *gin.Context) {
+	klineStore := market.GetKlineStore()
+	if klineStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "kline store disabled"})
+		return
+	}
+
+	symbol := c.PostForm("symbol")
+	timeframe := c.PostForm("timeframe")
+	if symbol == "" || timeframe == "" {
+		SafeBadRequest(c, "symbol and timeframe are required")
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		SafeBadRequest(c, "file is required")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		SafeInternalError(c, "Open uploaded file", err)
+		return
+	}
+	defer file.Close()
+
+	added, err := klineStore.ImportCSV(c.DefaultPostForm("exchange", "binance"), symbol, timeframe, file)
+	if err != nil {
+		SafeError(c, http.StatusBadRequest, "Failed to import klines", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"imported": added})
+}
+
+// handleKlineGaps lists missing candle ranges in the local kline store
+func (s *Server) handleKlineGaps(c *gin.Context) {
+	klineStore := market.GetKlineStore()
+	if klineStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "kline store disabled"})
+		return
+	}
+
+	symbol := c.Query("symbol")
+	timeframe := c.Query("timeframe")
+	startTS, errStart := strconv.ParseInt(c.Query("start_ts"), 10, 64)
+	endTS, errEnd := strconv.ParseInt(c.Query("end_ts"), 10, 64)
+	if symbol == "" || timeframe == "" || errStart != nil || errEnd != nil || endTS <= startTS {
+		SafeBadRequest(c, "symbol, timeframe, start_ts and end_ts are required")
+		return
+	}
+
+	gaps, err := klineStore.Gaps(c.DefaultQuery("exchange", "binance"), symbol, timeframe, time.Unix(startTS, 0), time.Unix(endTS, 0))
+	if err != nil {
+		SafeError(c, http.StatusBadRequest, "Failed to check kline gaps", err)
+		return
+	}
+	if gaps == nil {
+		gaps = []market.KlineGap{}
+	}
+	c.JSON(http.StatusOK, gin.H{"gaps": gaps, "offline": klineStore.Offline()})
+}
+
+// handleKlineBackfill fetches missing candles for a range into the local kline store
+func (s *Server) handleKlineBackfill(c *gin.Context) {
+	klineStore := market.GetKlineStore()
+	if klineStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "kline store disabled"})
+		return
+	}
+
+	var req klineBackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SafeBadRequest(c, "Invalid request parameters")
+		return
+	}
+	if req.Symbol == "" || req.Timeframe == "" || req.EndTS <= req.StartTS {
+		SafeBadRequest(c, "symbol, timeframe, start_ts and end_ts are required")
+		return
+	}
+
+	added, err := klineStore.Backfill(req.Symbol, req.Timeframe, time.Unix(req.StartTS, 0), time.Unix(req.EndTS, 0))
+	if err != nil {
+		SafeError(c, http.StatusBadGateway, "Failed to backfill klines", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"added": added})
+}
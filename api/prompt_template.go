@@ -0,0 +1,637 @@
+package api
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nofx/backtest"
+	"nofx/kernel"
+	"nofx/logger"
+	"nofx/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============================================================================
+// Prompt Templates
+// ============================================================================
+
+type promptTemplateRequest struct {
+	Name        string                       `json:"name"`
+	Description string                       `json:"description"`
+	Content     *store.PromptTemplateContent `json:"content"`
+	Note        string                       `json:"note"` // Commit note of the new version
+}
+
+// handleListPromptTemplates lists the user's prompt templates
+func (s *Server) handleListPromptTemplates(c *gin.Context) {
+	templates, err := s.store.PromptTemplate().ListTemplates(c.GetString("user_id"))
+	if err != nil {
+		SafeInternalError(c, "Get prompt templates", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// handleCreatePromptTemplate creates a template with its first version
+func (s *Server) handleCreatePromptTemplate(c *gin.Context) {
+	var req promptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" || req.Content == nil {
+		SafeBadRequest(c, "name and content are required")
+		return
+	}
+
+	template := &store.PromptTemplate{
+		ID:          uuid.New().String(),
+		UserID:      c.GetString("user_id"),
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+	}
+	version, err := s.store.PromptTemplate().CreateTemplate(template, *req.Content, req.Note)
+	if err != nil {
+		SafeInternalError(c, "Create prompt template", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": template, "version": version})
+}
+
+// ownedPromptTemplate loads a template of the user (writes a 404 otherwise)
+func (s *Server) ownedPromptTemplate(c *gin.Context, id string) (*store.PromptTemplate, bool) {
+	template, err := s.store.PromptTemplate().GetTemplate(c.GetString("user_id"), id)
+	if err != nil {
+		SafeNotFound(c, "Prompt template")
+		return nil, false
+	}
+	return template, true
+}
+
+// promptTemplateVersion loads a version of a template with its parsed content (writes a 404 otherwise)
+func (s *Server) promptTemplateVersion(c *gin.Context, templateID string, version int) (*store.PromptTemplateVersion, *store.PromptTemplateContent, bool) {
+	v, err := s.store.PromptTemplate().GetVersion(templateID, version)
+	if err != nil {
+		SafeNotFound(c, "Prompt template version")
+		return nil, nil, false
+	}
+	content, err := v.ParseContent()
+	if err != nil {
+		SafeInternalError(c, "Parse prompt template", err)
+		return nil, nil, false
+	}
+	return v, content, true
+}
+
+// handleGetPromptTemplate returns a template with the content of its current version
+func (s *Server) handleGetPromptTemplate(c *gin.Context) {
+	template, ok := s.ownedPromptTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	version, content, ok := s.promptTemplateVersion(c, template.ID, 0)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": template, "version": version, "content": content})
+}
+
+// handleUpdatePromptTemplate renames a template and/or commits new content as its next version
+func (s *Server) handleUpdatePromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	template, ok := s.ownedPromptTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req promptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		SafeBadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" && (name != template.Name || req.Description != template.Description) {
+		if err := s.store.PromptTemplate().UpdateTemplateInfo(userID, template.ID, name, req.Description); err != nil {
+			SafeInternalError(c, "Update prompt template", err)
+			return
+		}
+	}
+
+	var version *store.PromptTemplateVersion
+	if req.Content != nil {
+		var err error
+		version, err = s.store.PromptTemplate().CommitVersion(userID, template.ID, *req.Content, req.Note)
+		if err != nil {
+			SafeInternalError(c, "Commit prompt template version", err)
+			return
+		}
+	}
+
+	template, _ = s.store.PromptTemplate().GetTemplate(userID, template.ID)
+	c.JSON(http.StatusOK, gin.H{"template": template, "version": version})
+}
+
+// handleDeletePromptTemplate deletes a template unless an active experiment still runs it
+func (s *Server) handleDeletePromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	template, ok := s.ownedPromptTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	experiments, err := s.store.PromptTemplate().ListExperiments(userID)
+	if err != nil {
+		SafeInternalError(c, "Get prompt experiments", err)
+		return
+	}
+	for _, e := range experiments {
+		if e.Status != store.PromptExperimentActive {
+			continue
+		}
+		for _, arm := range e.ArmList {
+			if arm.TemplateID == template.ID {
+				SafeBadRequest(c, "Template is used by active experiment "+e.Name+", stop it first")
+				return
+			}
+		}
+	}
+
+	if err := s.store.PromptTemplate().DeleteTemplate(userID, template.ID); err != nil {
+		SafeInternalError(c, "Delete prompt template", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Prompt template deleted"})
+}
+
+// handleListPromptTemplateVersions lists the version history of a template, newest first
+func (s *Server) handleListPromptTemplateVersions(c *gin.Context) {
+	template, ok := s.ownedPromptTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	versions, err := s.store.PromptTemplate().ListVersions(template.ID)
+	if err != nil {
+		SafeInternalError(c, "Get prompt template versions", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"template": template, "versions": versions})
+}
+
+// handleGetPromptTemplateVersion returns one version with its parsed content
+func (s *Server) handleGetPromptTemplateVersion(c *gin.Context) {
+	template, ok := s.ownedPromptTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number <= 0 {
+		SafeBadRequest(c, "Invalid version")
+		return
+	}
+	version, content, ok := s.promptTemplateVersion(c, template.ID, number)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"version": version, "content": content})
+}
+
+// handleDiffPromptTemplate line diff between two versions (?from=&to=, to defaults to the current version)
+func (s *Server) handleDiffPromptTemplate(c *gin.Context) {
+	template, ok := s.ownedPromptTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from <= 0 {
+		SafeBadRequest(c, "from must be a version number")
+		return
+	}
+	to := template.CurrentVersion
+	if v := c.Query("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil || to <= 0 {
+			SafeBadRequest(c, "to must be a version number")
+			return
+		}
+	}
+
+	_, fromContent, ok := s.promptTemplateVersion(c, template.ID, from)
+	if !ok {
+		return
+	}
+	_, toContent, ok := s.promptTemplateVersion(c, template.ID, to)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":  from,
+		"to":    to,
+		"diffs": kernel.DiffPromptContent(*fromContent, *toContent),
+	})
+}
+
+// handleRollbackPromptTemplate makes an earlier version current again (as a new version)
+func (s *Server) handleRollbackPromptTemplate(c *gin.Context) {
+	template, ok := s.ownedPromptTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req struct {
+		Version int `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Version <= 0 {
+		SafeBadRequest(c, "version is required")
+		return
+	}
+	if _, _, ok := s.promptTemplateVersion(c, template.ID, req.Version); !ok {
+		return
+	}
+
+	version, err := s.store.PromptTemplate().Rollback(c.GetString("user_id"), template.ID, req.Version)
+	if err != nil {
+		SafeInternalError(c, "Rollback prompt template", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"version": version})
+}
+
+// ============================================================================
+// Prompt Experiments
+// ============================================================================
+
+// handleListPromptExperiments lists the user's prompt experiments
+func (s *Server) handleListPromptExperiments(c *gin.Context) {
+	experiments, err := s.store.PromptTemplate().ListExperiments(c.GetString("user_id"))
+	if err != nil {
+		SafeInternalError(c, "Get prompt experiments", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"experiments": experiments})
+}
+
+// handleCreatePromptExperiment creates an experiment comparing two or more template versions
+func (s *Server) handleCreatePromptExperiment(c *gin.Context) {
+	var req struct {
+		Name        string                      `json:"name"`
+		Description string                      `json:"description"`
+		Arms        []store.PromptExperimentArm `json:"arms"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		SafeBadRequest(c, "name is required")
+		return
+	}
+	if len(req.Arms) < 2 {
+		SafeBadRequest(c, "An experiment needs at least two arms")
+		return
+	}
+
+	// Pin every arm to an existing version, so later edits of a template don't change the experiment
+	seen := make(map[string]bool, len(req.Arms))
+	for i := range req.Arms {
+		arm := &req.Arms[i]
+		template, ok := s.ownedPromptTemplate(c, arm.TemplateID)
+		if !ok {
+			return
+		}
+		if arm.Version <= 0 {
+			arm.Version = template.CurrentVersion
+		}
+		if _, _, ok := s.promptTemplateVersion(c, template.ID, arm.Version); !ok {
+			return
+		}
+		key := template.ID + "@" + strconv.Itoa(arm.Version)
+		if seen[key] {
+			SafeBadRequest(c, "Arms must use different template versions")
+			return
+		}
+		seen[key] = true
+		if strings.TrimSpace(arm.Label) == "" {
+			arm.Label = template.Name + " v" + strconv.Itoa(arm.Version)
+		}
+	}
+
+	experiment := &store.PromptExperiment{
+		ID:          uuid.New().String(),
+		UserID:      c.GetString("user_id"),
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		ArmList:     req.Arms,
+	}
+	if err := s.store.PromptTemplate().CreateExperiment(experiment); err != nil {
+		SafeInternalError(c, "Create prompt experiment", err)
+		return
+	}
+	c.JSON(http.StatusOK, experiment)
+}
+
+// ownedPromptExperiment loads an experiment of the user (writes a 404 otherwise)
+func (s *Server) ownedPromptExperiment(c *gin.Context) (*store.PromptExperiment, bool) {
+	experiment, err := s.store.PromptTemplate().GetExperiment(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		SafeNotFound(c, "Prompt experiment")
+		return nil, false
+	}
+	return experiment, true
+}
+
+// handleGetPromptExperiment returns an experiment with its assignments
+func (s *Server) handleGetPromptExperiment(c *gin.Context) {
+	experiment, ok := s.ownedPromptExperiment(c)
+	if !ok {
+		return
+	}
+	assignments, err := s.store.PromptTemplate().ListAssignments(experiment.ID)
+	if err != nil {
+		SafeInternalError(c, "Get prompt experiment assignments", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"experiment": experiment, "assignments": assignments})
+}
+
+// handleStopPromptExperiment ends an experiment; its traders use their strategy prompt again on next start
+func (s *Server) handleStopPromptExperiment(c *gin.Context) {
+	experiment, ok := s.ownedPromptExperiment(c)
+	if !ok {
+		return
+	}
+	if err := s.store.PromptTemplate().StopExperiment(experiment.UserID, experiment.ID); err != nil {
+		SafeInternalError(c, "Stop prompt experiment", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Prompt experiment stopped, restart its traders to return to their strategy prompt"})
+}
+
+// handleDeletePromptExperiment deletes an experiment with its assignments
+func (s *Server) handleDeletePromptExperiment(c *gin.Context) {
+	experiment, ok := s.ownedPromptExperiment(c)
+	if !ok {
+		return
+	}
+	if err := s.store.PromptTemplate().DeleteExperiment(experiment.UserID, experiment.ID); err != nil {
+		SafeInternalError(c, "Delete prompt experiment", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Prompt experiment deleted"})
+}
+
+// handleAssignPromptExperiment splits traders evenly and randomly across the experiment's arms
+func (s *Server) handleAssignPromptExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	experiment, ok := s.ownedPromptExperiment(c)
+	if !ok {
+		return
+	}
+	if experiment.Status != store.PromptExperimentActive {
+		SafeBadRequest(c, "Prompt experiment is stopped")
+		return
+	}
+	var req struct {
+		TraderIDs []string `json:"trader_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.TraderIDs) == 0 {
+		SafeBadRequest(c, "trader_ids is required")
+		return
+	}
+	for _, traderID := range req.TraderIDs {
+		if _, ok := s.ownedTrader(c, userID, traderID); !ok {
+			return
+		}
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	arms := assignPromptArms(req.TraderIDs, len(experiment.ArmList), rng)
+	assignments := make([]*store.PromptExperimentAssignment, 0, len(req.TraderIDs))
+	for _, traderID := range req.TraderIDs {
+		arm := experiment.ArmList[arms[traderID]]
+		assignment := &store.PromptExperimentAssignment{
+			ExperimentID: experiment.ID,
+			TemplateID:   arm.TemplateID,
+			Version:      arm.Version,
+			TargetType:   store.PromptTargetTrader,
+			TargetID:     traderID,
+		}
+		if err := s.store.PromptTemplate().Assign(assignment); err != nil {
+			SafeError(c, http.StatusBadRequest, "Failed to assign trader", err)
+			return
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	logger.Infof("🧪 Prompt experiment %s: assigned %d traders across %d arms", experiment.Name, len(assignments), len(experiment.ArmList))
+	c.JSON(http.StatusOK, gin.H{
+		"assignments": assignments,
+		"message":     "Assigned, running traders use their new prompt after a restart",
+	})
+}
+
+// assignPromptArms splits targets across arms in shuffled round robin order, so arm sizes differ by at most one
+func assignPromptArms(targetIDs []string, arms int, rng *rand.Rand) map[string]int {
+	shuffled := append([]string(nil), targetIDs...)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	// Start at a random arm too, otherwise the first arm is always the largest
+	offset := rng.Intn(arms)
+	result := make(map[string]int, len(shuffled))
+	for i, id := range shuffled {
+		result[id] = (i + offset) % arms
+	}
+	return result
+}
+
+// leastUsedPromptArm returns the arm with the fewest backtest runs so far (first arm on ties)
+func leastUsedPromptArm(arms []store.PromptExperimentArm, assignments []*store.PromptExperimentAssignment) int {
+	counts := make([]int, len(arms))
+	for _, a := range assignments {
+		if a.TargetType != store.PromptTargetBacktest {
+			continue
+		}
+		for i, arm := range arms {
+			if arm.TemplateID == a.TemplateID && arm.Version == a.Version {
+				counts[i]++
+				break
+			}
+		}
+	}
+	best := 0
+	for i := range counts {
+		if counts[i] < counts[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// promptArmResult performance of one experiment arm
+type promptArmResult struct {
+	store.PromptExperimentArm
+	Traders int     `json:"traders"`
+	Trades  int     `json:"trades"`
+	Wins    int     `json:"wins"`
+	WinRate float64 `json:"win_rate"` // Percent of closed trades
+	PnL     float64 `json:"realized_pnl"`
+
+	BacktestRuns      int     `json:"backtest_runs"`
+	AvgReturnPct      float64 `json:"avg_return_pct"`
+	AvgMaxDrawdownPct float64 `json:"avg_max_drawdown_pct"`
+	AvgWinRate        float64 `json:"avg_backtest_win_rate"`
+}
+
+// handlePromptExperimentResults compares the arms side by side: closed trades of each trader since it
+// was assigned, and the metrics of finished backtest runs
+func (s *Server) handlePromptExperimentResults(c *gin.Context) {
+	experiment, ok := s.ownedPromptExperiment(c)
+	if !ok {
+		return
+	}
+	assignments, err := s.store.PromptTemplate().ListAssignments(experiment.ID)
+	if err != nil {
+		SafeInternalError(c, "Get prompt experiment assignments", err)
+		return
+	}
+
+	arms := make([]*promptArmResult, len(experiment.ArmList))
+	armIndex := make(map[string]int, len(arms))
+	for i, arm := range experiment.ArmList {
+		arms[i] = &promptArmResult{PromptExperimentArm: arm}
+		armIndex[arm.TemplateID+"@"+strconv.Itoa(arm.Version)] = i
+	}
+
+	targets := make([]gin.H, 0, len(assignments))
+	for _, a := range assignments {
+		i, ok := armIndex[a.TemplateID+"@"+strconv.Itoa(a.Version)]
+		if !ok {
+			continue
+		}
+		arm := arms[i]
+		target := gin.H{
+			"target_type": a.TargetType,
+			"target_id":   a.TargetID,
+			"arm":         arm.Label,
+			"assigned_at": a.AssignedAt,
+		}
+
+		switch a.TargetType {
+		case store.PromptTargetTrader:
+			counts, err := s.store.Position().GetClosedTradeCountsBetween(a.TargetID, a.AssignedAt, experiment.StoppedAt)
+			if err != nil {
+				SafeInternalError(c, "Get closed trades", err)
+				return
+			}
+			arm.Traders++
+			arm.Trades += counts.Total
+			arm.Wins += counts.Wins
+			arm.PnL += counts.TotalPnL
+			target["trades"] = counts.Total
+			target["wins"] = counts.Wins
+			target["realized_pnl"] = counts.TotalPnL
+
+		case store.PromptTargetBacktest:
+			metrics := s.backtestMetrics(a.TargetID)
+			if metrics == nil {
+				target["status"] = "pending" // Still running or failed before producing metrics
+				break
+			}
+			arm.BacktestRuns++
+			arm.AvgReturnPct += metrics.TotalReturnPct
+			arm.AvgMaxDrawdownPct += metrics.MaxDrawdownPct
+			arm.AvgWinRate += metrics.WinRate
+			target["total_return_pct"] = metrics.TotalReturnPct
+			target["max_drawdown_pct"] = metrics.MaxDrawdownPct
+			target["win_rate"] = metrics.WinRate
+			target["trades"] = metrics.Trades
+		}
+		targets = append(targets, target)
+	}
+
+	for _, arm := range arms {
+		if arm.Trades > 0 {
+			arm.WinRate = float64(arm.Wins) / float64(arm.Trades) * 100
+		}
+		if arm.BacktestRuns > 0 {
+			n := float64(arm.BacktestRuns)
+			arm.AvgReturnPct /= n
+			arm.AvgMaxDrawdownPct /= n
+			arm.AvgWinRate /= n
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiment": experiment,
+		"arms":       arms,
+		"targets":    targets,
+	})
+}
+
+// backtestMetrics returns the metrics of a finished backtest run, nil if there are none (yet)
+func (s *Server) backtestMetrics(runID string) *backtest.Metrics {
+	if s.backtestManager == nil {
+		return nil
+	}
+	metrics, err := s.backtestManager.GetMetrics(runID)
+	if err != nil {
+		return nil
+	}
+	return metrics
+}
+
+// applyBacktestPromptTemplate resolves the prompt template (or experiment arm) of a backtest into
+// plain prompt settings, so the run and its resumes don't depend on the template afterwards.
+// It writes the error response and returns false on failure.
+func (s *Server) applyBacktestPromptTemplate(c *gin.Context, cfg *backtest.BacktestConfig) bool {
+	if cfg.PromptExperimentID != "" {
+		experiment, err := s.store.PromptTemplate().GetExperiment(cfg.UserID, cfg.PromptExperimentID)
+		if err != nil {
+			SafeNotFound(c, "Prompt experiment")
+			return false
+		}
+		if experiment.Status != store.PromptExperimentActive || len(experiment.ArmList) == 0 {
+			SafeBadRequest(c, "Prompt experiment is stopped")
+			return false
+		}
+		assignments, err := s.store.PromptTemplate().ListAssignments(experiment.ID)
+		if err != nil {
+			SafeInternalError(c, "Get prompt experiment assignments", err)
+			return false
+		}
+		arm := experiment.ArmList[leastUsedPromptArm(experiment.ArmList, assignments)]
+		cfg.PromptTemplateID = arm.TemplateID
+		cfg.PromptTemplateVersion = arm.Version
+	}
+	if cfg.PromptTemplateID == "" {
+		return true
+	}
+
+	template, ok := s.ownedPromptTemplate(c, cfg.PromptTemplateID)
+	if !ok {
+		return false
+	}
+	version, content, ok := s.promptTemplateVersion(c, template.ID, cfg.PromptTemplateVersion)
+	if !ok {
+		return false
+	}
+	cfg.PromptTemplateVersion = version.Version
+	if content.PromptVariant != "" {
+		cfg.PromptVariant = content.PromptVariant
+	}
+	cfg.CustomPrompt = content.CustomPrompt
+	cfg.OverrideBasePrompt = content.OverrideBasePrompt
+	cfg.PromptSections = &content.PromptSections
+	logger.Infof("📊 Backtest %s uses prompt template %s v%d", cfg.RunID, template.Name, version.Version)
+	return true
+}
+
+// recordBacktestPromptAssignment records a started backtest run as a sample of its experiment arm
+func (s *Server) recordBacktestPromptAssignment(cfg backtest.BacktestConfig) {
+	if cfg.PromptExperimentID == "" {
+		return
+	}
+	err := s.store.PromptTemplate().Assign(&store.PromptExperimentAssignment{
+		ExperimentID: cfg.PromptExperimentID,
+		TemplateID:   cfg.PromptTemplateID,
+		Version:      cfg.PromptTemplateVersion,
+		TargetType:   store.PromptTargetBacktest,
+		TargetID:     cfg.RunID,
+	})
+	if err != nil {
+		logger.Warnf("⚠️ Failed to record backtest %s in prompt experiment: %v", cfg.RunID, err)
+	}
+}
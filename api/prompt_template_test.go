@@ -0,0 +1,63 @@
+package api
+
+import (
+	"math/rand"
+	"testing"
+
+	"nofx/store"
+)
+
+// TestAssignPromptArms arms differ in size by at most one and every trader gets an arm
+func TestAssignPromptArms(t *testing.T) {
+	traders := []string{"t1", "t2", "t3", "t4", "t5", "t6", "t7"}
+	for seed := int64(0); seed < 20; seed++ {
+		result := assignPromptArms(traders, 3, rand.New(rand.NewSource(seed)))
+		if len(result) != len(traders) {
+			t.Fatalf("seed %d: assigned %d traders, want %d", seed, len(result), len(traders))
+		}
+		sizes := make([]int, 3)
+		for _, arm := range result {
+			if arm < 0 || arm >= 3 {
+				t.Fatalf("seed %d: arm %d out of range", seed, arm)
+			}
+			sizes[arm]++
+		}
+		min, max := sizes[0], sizes[0]
+		for _, n := range sizes {
+			if n < min {
+				min = n
+			}
+			if n > max {
+				max = n
+			}
+		}
+		if max-min > 1 {
+			t.Errorf("seed %d: unbalanced arms %v", seed, sizes)
+		}
+	}
+}
+
+// TestLeastUsedPromptArm backtests go to the arm with the fewest runs, trader assignments don't count
+func TestLeastUsedPromptArm(t *testing.T) {
+	arms := []store.PromptExperimentArm{
+		{TemplateID: "a", Version: 1},
+		{TemplateID: "a", Version: 2},
+	}
+	if got := leastUsedPromptArm(arms, nil); got != 0 {
+		t.Errorf("no runs yet: got arm %d, want 0", got)
+	}
+
+	assignments := []*store.PromptExperimentAssignment{
+		{TemplateID: "a", Version: 1, TargetType: store.PromptTargetBacktest, TargetID: "bt1"},
+		{TemplateID: "a", Version: 2, TargetType: store.PromptTargetTrader, TargetID: "t1"},
+		{TemplateID: "a", Version: 2, TargetType: store.PromptTargetTrader, TargetID: "t2"},
+	}
+	if got := leastUsedPromptArm(arms, assignments); got != 1 {
+		t.Errorf("got arm %d, want 1", got)
+	}
+
+	assignments = append(assignments, &store.PromptExperimentAssignment{TemplateID: "a", Version: 2, TargetType: store.PromptTargetBacktest, TargetID: "bt2"})
+	if got := leastUsedPromptArm(arms, assignments); got != 0 {
+		t.Errorf("tie: got arm %d, want 0", got)
+	}
+}
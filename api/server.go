@@ -29,6 +29,7 @@ import (
 	"nofx/trader/kucoin"
 	"nofx/trader/lighter"
 	"nofx/trader/okx"
+	"nofx/trader/paper"
 	"strconv"
 	"strings"
 	"time"
@@ -45,6 +46,7 @@ type Server struct {
 	cryptoHandler   *CryptoHandler
 	backtestManager *backtest.Manager
 	debateHandler   *DebateHandler
+	decisionReplays *decisionReplayRegistry
 	httpServer      *http.Server
 	port            int
 }
@@ -77,6 +79,7 @@ func NewServer(traderManager *manager.TraderManager, st *store.Store, cryptoServ
 		cryptoHandler:   cryptoHandler,
 		backtestManager: backtestManager,
 		debateHandler:   debateHandler,
+		decisionReplays: newDecisionReplayRegistry(),
 		port:            port,
 	}
 
@@ -167,6 +170,7 @@ func (s *Server) setupRoutes() {
 			protected.POST("/traders/:id/close-position", s.handleClosePosition)
 			protected.PUT("/traders/:id/competition", s.handleToggleCompetition)
 			protected.GET("/traders/:id/grid-risk", s.handleGetGridRiskInfo)
+			protected.GET("/traders/:id/decision-stream", s.handleTraderDecisionStream)
 
 			// AI model configuration
 			protected.GET("/models", s.handleGetModelConfigs)
@@ -191,6 +195,24 @@ func (s *Server) setupRoutes() {
 			protected.POST("/strategies/:id/activate", s.handleActivateStrategy)
 			protected.POST("/strategies/:id/duplicate", s.handleDuplicateStrategy)
 
+			// Versioned prompt templates and A/B prompt experiments
+			protected.GET("/prompt-templates", s.handleListPromptTemplates)
+			protected.POST("/prompt-templates", s.handleCreatePromptTemplate)
+			protected.GET("/prompt-templates/:id", s.handleGetPromptTemplate)
+			protected.PUT("/prompt-templates/:id", s.handleUpdatePromptTemplate)
+			protected.DELETE("/prompt-templates/:id", s.handleDeletePromptTemplate)
+			protected.GET("/prompt-templates/:id/versions", s.handleListPromptTemplateVersions)
+			protected.GET("/prompt-templates/:id/versions/:version", s.handleGetPromptTemplateVersion)
+			protected.GET("/prompt-templates/:id/diff", s.handleDiffPromptTemplate)
+			protected.POST("/prompt-templates/:id/rollback", s.handleRollbackPromptTemplate)
+			protected.GET("/prompt-experiments", s.handleListPromptExperiments)
+			protected.POST("/prompt-experiments", s.handleCreatePromptExperiment)
+			protected.GET("/prompt-experiments/:id", s.handleGetPromptExperiment)
+			protected.DELETE("/prompt-experiments/:id", s.handleDeletePromptExperiment)
+			protected.POST("/prompt-experiments/:id/stop", s.handleStopPromptExperiment)
+			protected.POST("/prompt-experiments/:id/assign", s.handleAssignPromptExperiment)
+			protected.GET("/prompt-experiments/:id/results", s.handlePromptExperimentResults)
+
 			// Debate Arena
 			protected.GET("/debates", s.debateHandler.HandleListDebates)
 			protected.GET("/debates/personalities", s.debateHandler.HandleGetPersonalities)
@@ -215,8 +237,18 @@ func (s *Server) setupRoutes() {
 			protected.GET("/open-orders", s.handleOpenOrders)      // Open orders from exchange (pending SL/TP)
 			protected.GET("/decisions", s.handleDecisions)
 			protected.GET("/decisions/latest", s.handleLatestDecisions)
+			protected.POST("/decision-replays", s.handleStartDecisionReplay)
+			protected.GET("/decision-replays", s.handleListDecisionReplays)
+			protected.GET("/decision-replays/:id", s.handleGetDecisionReplay)
+			protected.POST("/decision-replays/:id/cancel", s.handleCancelDecisionReplay)
 			protected.GET("/statistics", s.handleStatistics)
 
+			// AI token usage and cost accounting
+			protected.GET("/ai-usage/daily", s.handleAIUsageDaily)
+			protected.GET("/ai-usage/traders/:id/cost-per-trade", s.handleAICostPerTrade)
+			protected.GET("/ai-usage/prices", s.handleGetAIModelPrices)
+			protected.PUT("/ai-usage/prices", s.handleUpdateAIModelPrices)
+
 			// Backtest routes
 			backtest := protected.Group("/backtest")
 			s.registerBacktestRoutes(backtest)
@@ -402,6 +434,18 @@ func (s *Server) getTraderFromQuery(c *gin.Context) (*manager.TraderManager, str
 	return s.traderManager, traderID, nil
 }
 
+// paperTrader returns the paper trader of the loaded trader traderID, so API calls share its lock
+// and trigger loop instead of writing the same account from a second instance; a new one if the
+// trader isn't loaded
+func (s *Server) paperTrader(traderID string, initialBalance float64) (trader.Trader, error) {
+	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+		if pt, ok := at.GetUnderlyingTrader().(*paper.PaperTrader); ok {
+			return pt, nil
+		}
+	}
+	return paper.NewPaperTrader(s.store, traderID, initialBalance)
+}
+
 // AI trader management related structures
 type CreateTraderRequest struct {
 	Name                string  `json:"name" binding:"required"`
@@ -421,6 +465,8 @@ type CreateTraderRequest struct {
 	SystemPromptTemplate string `json:"system_prompt_template"` // System prompt template name
 	UseAI500             bool   `json:"use_ai500"`
 	UseOITop             bool   `json:"use_oi_top"`
+
+	traderAIModeRequest
 }
 
 type ModelConfig struct {
@@ -525,6 +571,12 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		}
 	}
 
+	aiMode, backupAIModelIDs, ensembleVote, err := s.resolveTraderAIMode(userID, req.AIModelID, req.traderAIModeRequest)
+	if err != nil {
+		SafeBadRequest(c, err.Error())
+		return
+	}
+
 	// Generate trader ID (use short UUID prefix for readability)
 	exchangeIDShort := req.ExchangeID
 	if len(exchangeIDShort) > 8 {
@@ -647,6 +699,9 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 			} else {
 				createErr = fmt.Errorf("Lighter requires wallet address and API Key private key")
 			}
+		case "paper":
+			// Paper account is seeded with the user's initial balance
+			tempTrader, createErr = s.paperTrader(traderID, req.InitialBalance)
 		default:
 			logger.Infof("⚠️ Unsupported exchange type: %s, using user input for initial balance", exchangeCfg.ExchangeType)
 		}
@@ -699,6 +754,9 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		ShowInCompetition:    showInCompetition,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            false,
+		AIMode:               aiMode,
+		BackupAIModelIDs:     backupAIModelIDs,
+		EnsembleVote:         ensembleVote,
 	}
 
 	// Save to database
@@ -747,6 +805,8 @@ type UpdateTraderRequest struct {
 	CustomPrompt         string `json:"custom_prompt"`
 	OverrideBasePrompt   bool   `json:"override_base_prompt"`
 	SystemPromptTemplate string `json:"system_prompt_template"`
+
+	traderAIModeRequest
 }
 
 // handleUpdateTrader Update trader configuration
@@ -823,6 +883,16 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		strategyID = existingTrader.StrategyID
 	}
 
+	// Handle AI mode (if not provided, keep original value)
+	aiMode, backupAIModelIDs, ensembleVote := existingTrader.AIMode, existingTrader.BackupAIModelIDs, existingTrader.EnsembleVote
+	if req.AIMode != "" {
+		aiMode, backupAIModelIDs, ensembleVote, err = s.resolveTraderAIMode(userID, req.AIModelID, req.traderAIModeRequest)
+		if err != nil {
+			SafeBadRequest(c, err.Error())
+			return
+		}
+	}
+
 	// Update trader configuration
 	traderRecord := &store.Trader{
 		ID:                   traderID,
@@ -842,6 +912,9 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		ShowInCompetition:    showInCompetition,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            existingTrader.IsRunning, // Keep original value
+		AIMode:               aiMode,
+		BackupAIModelIDs:     backupAIModelIDs,
+		EnsembleVote:         ensembleVote,
 	}
 
 	// Check if trader was running before update (we'll restart it after)
@@ -1216,6 +1289,8 @@ func (s *Server) handleSyncBalance(c *gin.Context) {
 		} else {
 			createErr = fmt.Errorf("Lighter requires wallet address and API Key private key")
 		}
+	case "paper":
+		tempTrader, createErr = s.paperTrader(traderID, traderConfig.InitialBalance)
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported exchange type"})
 		return
@@ -1379,6 +1454,8 @@ func (s *Server) handleClosePosition(c *gin.Context) {
 		} else {
 			createErr = fmt.Errorf("Lighter requires wallet address and API Key private key")
 		}
+	case "paper":
+		tempTrader, createErr = s.paperTrader(traderID, fullConfig.Trader.InitialBalance)
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported exchange type"})
 		return
@@ -1449,7 +1526,7 @@ func (s *Server) handleClosePosition(c *gin.Context) {
 func (s *Server) recordClosePositionOrder(traderID, exchangeID, exchangeType, symbol, side string, quantity, exitPrice float64, result map[string]interface{}) {
 	// Skip for exchanges with OrderSync - let the background sync handle it to avoid duplicates
 	switch exchangeType {
-	case "binance", "lighter", "hyperliquid", "bybit", "okx", "bitget", "aster", "gate":
+	case "binance", "lighter", "hyperliquid", "bybit", "okx", "bitget", "aster", "gate", "paper":
 		logger.Infof("  📝 Close order will be synced by OrderSync, skipping immediate record")
 		return
 	}
@@ -1681,6 +1758,7 @@ func (s *Server) handleGetModelConfigs(c *gin.Context) {
 			{ID: "gemini", Name: "Gemini AI", Provider: "gemini", Enabled: false},
 			{ID: "grok", Name: "Grok AI", Provider: "grok", Enabled: false},
 			{ID: "kimi", Name: "Kimi AI", Provider: "kimi", Enabled: false},
+			{ID: "local", Name: "Local Model", Provider: "local", Enabled: false},
 		}
 		c.JSON(http.StatusOK, defaultModels)
 		return
@@ -1932,7 +2010,7 @@ func (s *Server) handleUpdateExchangeConfigs(c *gin.Context) {
 
 // CreateExchangeRequest request structure for creating a new exchange account
 type CreateExchangeRequest struct {
-	ExchangeType            string `json:"exchange_type" binding:"required"` // "binance", "bybit", "okx", "hyperliquid", "aster", "lighter"
+	ExchangeType            string `json:"exchange_type" binding:"required"` // "binance", "bybit", "okx", "hyperliquid", "aster", "lighter", "paper"
 	AccountName             string `json:"account_name"`                     // User-defined account name
 	Enabled                 bool   `json:"enabled"`
 	APIKey                  string `json:"api_key"`
@@ -2004,6 +2082,7 @@ func (s *Server) handleCreateExchange(c *gin.Context) {
 	validTypes := map[string]bool{
 		"binance": true, "bybit": true, "okx": true, "bitget": true,
 		"hyperliquid": true, "aster": true, "lighter": true, "gate": true, "kucoin": true,
+		"paper": true,
 	}
 	if !validTypes[req.ExchangeType] {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid exchange type: %s", req.ExchangeType)})
@@ -2162,6 +2241,9 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"use_ai500":             traderConfig.UseAI500,
 		"use_oi_top":            traderConfig.UseOITop,
 		"is_running":            isRunning,
+		"ai_mode":               traderConfig.AIMode,
+		"backup_ai_model_ids":   traderConfig.BackupAIModelIDList(),
+		"ensemble_vote":         traderConfig.EnsembleVote,
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -2545,6 +2627,9 @@ func (s *Server) getKlinesFromCoinank(symbol, interval, exchange string, limit i
 	case "kucoin":
 		// KuCoin doesn't have direct CoinAnk support, use Binance data as fallback
 		coinankExchange = coinank_enum.Binance
+	case "paper":
+		// Paper trading prices come from Binance public data
+		coinankExchange = coinank_enum.Binance
 	default:
 		// For any unknown exchange, default to Binance
 		logger.Warnf("⚠️ Unknown exchange '%s', defaulting to Binance for CoinAnk", exchange)
@@ -3376,6 +3461,7 @@ func (s *Server) handleGetSupportedModels(c *gin.Context) {
 		{"id": "gemini", "name": "Google Gemini", "provider": "gemini", "defaultModel": "gemini-3-pro-preview"},
 		{"id": "grok", "name": "Grok (xAI)", "provider": "grok", "defaultModel": "grok-3-latest"},
 		{"id": "kimi", "name": "Kimi (Moonshot)", "provider": "kimi", "defaultModel": "moonshot-v1-auto"},
+		{"id": "local", "name": "Local (Ollama / llama.cpp)", "provider": "local", "defaultModel": ""}, // Empty: first model served
 	}
 
 	c.JSON(http.StatusOK, supportedModels)
@@ -3394,6 +3480,7 @@ func (s *Server) handleGetSupportedExchanges(c *gin.Context) {
 		{ExchangeType: "hyperliquid", Name: "Hyperliquid", Type: "dex"},
 		{ExchangeType: "aster", Name: "Aster DEX", Type: "dex"},
 		{ExchangeType: "lighter", Name: "LIGHTER DEX", Type: "dex"},
+		{ExchangeType: "paper", Name: "Paper Trading (Simulated)", Type: "paper"},
 		{ExchangeType: "alpaca", Name: "Alpaca (US Stocks)", Type: "stock"},
 		{ExchangeType: "forex", Name: "Forex (TwelveData)", Type: "forex"},
 		{ExchangeType: "metals", Name: "Metals (TwelveData)", Type: "metals"},
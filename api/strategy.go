@@ -9,6 +9,7 @@ import (
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/store"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -29,6 +30,18 @@ func validateStrategyConfig(config *store.StrategyConfig) []string {
 	return warnings
 }
 
+// validateStrategyRules rejects strategies whose rules or hybrid rules don't compile
+func validateStrategyRules(config *store.StrategyConfig) error {
+	if config.StrategyType == store.StrategyTypeRules {
+		_, err := kernel.CompileRules(config.RuleConfig)
+		return err
+	}
+	if _, err := kernel.CompileHybridRules(config.HybridRules); err != nil {
+		return fmt.Errorf("hybrid_rules: %w", err)
+	}
+	return nil
+}
+
 // handlePublicStrategies Get public strategies for strategy market (no auth required)
 func (s *Server) handlePublicStrategies(c *gin.Context) {
 	strategies, err := s.store.Strategy().ListPublic()
@@ -154,6 +167,10 @@ func (s *Server) handleCreateStrategy(c *gin.Context) {
 		SafeBadRequest(c, "Invalid request parameters")
 		return
 	}
+	if err := validateStrategyRules(&req.Config); err != nil {
+		SafeBadRequest(c, "Invalid rules: "+err.Error())
+		return
+	}
 
 	// Serialize configuration
 	configJSON, err := json.Marshal(req.Config)
@@ -224,6 +241,10 @@ func (s *Server) handleUpdateStrategy(c *gin.Context) {
 		SafeBadRequest(c, "Invalid request parameters")
 		return
 	}
+	if err := validateStrategyRules(&req.Config); err != nil {
+		SafeBadRequest(c, "Invalid rules: "+err.Error())
+		return
+	}
 
 	// Serialize configuration
 	configJSON, err := json.Marshal(req.Config)
@@ -377,9 +398,12 @@ func (s *Server) handlePreviewPrompt(c *gin.Context) {
 	}
 
 	var req struct {
-		Config          store.StrategyConfig `json:"config" binding:"required"`
-		AccountEquity   float64              `json:"account_equity"`
-		PromptVariant   string               `json:"prompt_variant"`
+		Config            store.StrategyConfig `json:"config" binding:"required"`
+		AccountEquity     float64              `json:"account_equity"`
+		PromptVariant     string               `json:"prompt_variant"`
+		IncludeUserPrompt bool                 `json:"include_user_prompt"` // Also build the user prompt from live market data
+		AIModelID         string               `json:"ai_model_id"`         // Budget the user prompt for this model's context window
+		ContextWindow     int                  `json:"context_window"`      // Or for an explicit context window (tokens)
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -404,7 +428,7 @@ func (s *Server) handlePreviewPrompt(c *gin.Context) {
 		req.PromptVariant,
 	)
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"system_prompt":  systemPrompt,
 		"prompt_variant": req.PromptVariant,
 		"config_summary": gin.H{
@@ -414,7 +438,32 @@ func (s *Server) handlePreviewPrompt(c *gin.Context) {
 			"altcoin_leverage": req.Config.RiskControl.AltcoinMaxLeverage,
 			"max_positions":    req.Config.RiskControl.MaxPositions,
 		},
-	})
+	}
+
+	// Build the user prompt within the context window budget and report what was left out
+	if req.IncludeUserPrompt {
+		var aiClient mcp.AIClient
+		if req.AIModelID != "" {
+			client, err := s.newStrategyTestClient(userID, req.AIModelID)
+			if err != nil {
+				SafeBadRequest(c, err.Error())
+				return
+			}
+			aiClient = client
+		}
+
+		testContext, err := buildStrategyTestContext(engine, &req.Config, req.PromptVariant, req.AccountEquity)
+		if err != nil {
+			SafeInternalError(c, "Failed to get candidate coins", err)
+			return
+		}
+		userPrompt, promptBudget := engine.BuildUserPromptWithBudget(testContext,
+			strategyPromptBudget(aiClient, req.ContextWindow, systemPrompt))
+		response["user_prompt"] = userPrompt
+		response["prompt_budget"] = promptBudget
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // handleStrategyTestRun AI test run (does not execute trades, only returns AI analysis results)
@@ -444,8 +493,8 @@ func (s *Server) handleStrategyTestRun(c *gin.Context) {
 	// Create strategy engine to build prompt
 	engine := kernel.NewStrategyEngine(&req.Config)
 
-	// Get candidate coins
-	candidates, err := engine.GetCandidateCoins()
+	// Build real context (for generating User Prompt)
+	testContext, err := buildStrategyTestContext(engine, &req.Config, req.PromptVariant, 1000.0)
 	if err != nil {
 		logger.Errorf("[API Error] Failed to get candidate coins: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -454,99 +503,47 @@ func (s *Server) handleStrategyTestRun(c *gin.Context) {
 		})
 		return
 	}
+	candidates := testContext.CandidateCoins
 
-	// Get timeframe configuration
-	timeframes := req.Config.Indicators.Klines.SelectedTimeframes
-	primaryTimeframe := req.Config.Indicators.Klines.PrimaryTimeframe
-	klineCount := req.Config.Indicators.Klines.PrimaryCount
-
-	// If no timeframes selected, use default values
-	if len(timeframes) == 0 {
-		// Backward compatibility: use primary and longer timeframes
-		if primaryTimeframe != "" {
-			timeframes = append(timeframes, primaryTimeframe)
-		} else {
-			timeframes = append(timeframes, "3m")
-		}
-		if req.Config.Indicators.Klines.LongerTimeframe != "" {
-			timeframes = append(timeframes, req.Config.Indicators.Klines.LongerTimeframe)
-		}
-	}
-	if primaryTimeframe == "" {
-		primaryTimeframe = timeframes[0]
-	}
-	if klineCount <= 0 {
-		klineCount = 30
-	}
-
-	fmt.Printf("📊 Using timeframes: %v, primary: %s, kline count: %d\n", timeframes, primaryTimeframe, klineCount)
-
-	// Get real market data (using multiple timeframes)
-	marketDataMap := make(map[string]*market.Data)
-	for _, coin := range candidates {
-		data, err := market.GetWithTimeframes(coin.Symbol, timeframes, primaryTimeframe, klineCount)
+	// Rule strategies don't use prompts: evaluate the rules on the current market data instead
+	if req.Config.IsRuleStrategy() {
+		decision, err := kernel.GetRuleDecision(testContext, engine, nil)
 		if err != nil {
-			// If getting data for a coin fails, log but continue
-			fmt.Printf("⚠️  Failed to get market data for %s: %v\n", coin.Symbol, err)
-			continue
+			SafeBadRequest(c, "Failed to evaluate rules: "+err.Error())
+			return
 		}
-		marketDataMap[coin.Symbol] = data
-	}
-
-	// Fetch quantitative data for each candidate coin
-	symbols := make([]string, 0, len(candidates))
-	for _, c := range candidates {
-		symbols = append(symbols, c.Symbol)
-	}
-	quantDataMap := engine.FetchQuantDataBatch(symbols)
-
-	// Fetch OI ranking data (market-wide position changes)
-	oiRankingData := engine.FetchOIRankingData()
-
-	// Fetch NetFlow ranking data (market-wide fund flow)
-	netFlowRankingData := engine.FetchNetFlowRankingData()
-
-	// Fetch Price ranking data (market-wide gainers/losers)
-	priceRankingData := engine.FetchPriceRankingData()
-
-	// Build real context (for generating User Prompt)
-	testContext := &kernel.Context{
-		CurrentTime:    time.Now().UTC().Format("2006-01-02 15:04:05 UTC"),
-		RuntimeMinutes: 0,
-		CallCount:      1,
-		Account: kernel.AccountInfo{
-			TotalEquity:      1000.0,
-			AvailableBalance: 1000.0,
-			UnrealizedPnL:    0,
-			TotalPnL:         0,
-			TotalPnLPct:      0,
-			MarginUsed:       0,
-			MarginUsedPct:    0,
-			PositionCount:    0,
-		},
-		Positions:          []kernel.PositionInfo{},
-		CandidateCoins:     candidates,
-		PromptVariant:      req.PromptVariant,
-		MarketDataMap:      marketDataMap,
-		QuantDataMap:       quantDataMap,
-		OIRankingData:      oiRankingData,
-		NetFlowRankingData: netFlowRankingData,
-		PriceRankingData:   priceRankingData,
+		c.JSON(http.StatusOK, gin.H{
+			"user_prompt":     decision.UserPrompt,
+			"candidate_count": len(candidates),
+			"candidates":      candidates,
+			"decisions":       decision.Decisions,
+			"rule_trace":      decision.CoTTrace,
+		})
+		return
 	}
 
 	// Build System Prompt
 	systemPrompt := engine.BuildSystemPrompt(1000.0, req.PromptVariant)
 
-	// Build User Prompt (using real market data)
-	userPrompt := engine.BuildUserPrompt(testContext)
+	// Build User Prompt (using real market data, compacted to the selected model's context window)
+	var aiClient mcp.AIClient
+	var aiErr error
+	if req.AIModelID != "" {
+		aiClient, aiErr = s.newStrategyTestClient(userID, req.AIModelID)
+	}
+	userPrompt, promptBudget := engine.BuildUserPromptWithBudget(testContext, strategyPromptBudget(aiClient, 0, systemPrompt))
 
 	// If requesting real AI call
 	if req.RunRealAI && req.AIModelID != "" {
-		aiResponse, aiErr := s.runRealAITest(userID, req.AIModelID, systemPrompt, userPrompt)
+		var aiResponse string
+		if aiErr == nil {
+			aiResponse, aiErr = runRealAITest(aiClient, systemPrompt, userPrompt)
+		}
 		if aiErr != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"system_prompt":   systemPrompt,
 				"user_prompt":     userPrompt,
+				"prompt_budget":   promptBudget,
 				"candidate_count": len(candidates),
 				"candidates":      candidates,
 				"prompt_variant":  req.PromptVariant,
@@ -560,6 +557,7 @@ func (s *Server) handleStrategyTestRun(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"system_prompt":   systemPrompt,
 			"user_prompt":     userPrompt,
+			"prompt_budget":   promptBudget,
 			"candidate_count": len(candidates),
 			"candidates":      candidates,
 			"prompt_variant":  req.PromptVariant,
@@ -573,6 +571,7 @@ func (s *Server) handleStrategyTestRun(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"system_prompt":   systemPrompt,
 		"user_prompt":     userPrompt,
+		"prompt_budget":   promptBudget,
 		"candidate_count": len(candidates),
 		"candidates":      candidates,
 		"prompt_variant":  req.PromptVariant,
@@ -581,20 +580,30 @@ func (s *Server) handleStrategyTestRun(c *gin.Context) {
 	})
 }
 
-// runRealAITest Execute real AI test call
-func (s *Server) runRealAITest(userID, modelID, systemPrompt, userPrompt string) (string, error) {
+// newStrategyTestClient creates the AI client of a user's model for strategy test runs
+func (s *Server) newStrategyTestClient(userID, modelID string) (mcp.AIClient, error) {
+	aiClient, err := s.newUserAIClient(userID, modelID)
+	if err != nil {
+		return nil, err
+	}
+	mcp.AttributeUsage(aiClient, mcp.UsageAttribution{UserID: userID, Source: mcp.UsageSourceStrategyTest, SourceID: modelID})
+	return aiClient, nil
+}
+
+// newUserAIClient creates the AI client of a user's enabled model (usage not yet attributed)
+func (s *Server) newUserAIClient(userID, modelID string) (mcp.AIClient, error) {
 	// Get AI model configuration
 	model, err := s.store.AIModel().Get(userID, modelID)
 	if err != nil {
-		return "", fmt.Errorf("failed to get AI model: %w", err)
+		return nil, fmt.Errorf("failed to get AI model: %w", err)
 	}
 
 	if !model.Enabled {
-		return "", fmt.Errorf("AI model %s is not enabled", model.Name)
+		return nil, fmt.Errorf("AI model %s is not enabled", model.Name)
 	}
 
-	if model.APIKey == "" {
-		return "", fmt.Errorf("AI model %s is missing API Key", model.Name)
+	if model.APIKey == "" && model.Provider != "local" {
+		return nil, fmt.Errorf("AI model %s is missing API Key", model.Name)
 	}
 
 	// Create AI client
@@ -625,12 +634,134 @@ func (s *Server) runRealAITest(userID, modelID, systemPrompt, userPrompt string)
 	case "openai":
 		aiClient = mcp.NewOpenAIClient()
 		aiClient.SetAPIKey(apiKey, model.CustomAPIURL, model.CustomModelName)
+	case "local":
+		aiClient = mcp.NewLocalClient()
+		aiClient.SetAPIKey(apiKey, model.CustomAPIURL, model.CustomModelName)
 	default:
 		// Use generic client
 		aiClient = mcp.NewClient()
 		aiClient.SetAPIKey(apiKey, model.CustomAPIURL, model.CustomModelName)
 	}
 
+	return aiClient, nil
+}
+
+// buildStrategyTestContext builds a decision context from live market data for the strategy's
+// candidate coins, with a simulated empty account
+func buildStrategyTestContext(engine *kernel.StrategyEngine, config *store.StrategyConfig, promptVariant string, accountEquity float64) (*kernel.Context, error) {
+	// Get candidate coins
+	candidates, err := engine.GetCandidateCoins()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get timeframe configuration
+	timeframes := config.Indicators.Klines.SelectedTimeframes
+	primaryTimeframe := config.Indicators.Klines.PrimaryTimeframe
+	klineCount := config.Indicators.Klines.PrimaryCount
+
+	// If no timeframes selected, use default values
+	if len(timeframes) == 0 {
+		// Backward compatibility: use primary and longer timeframes
+		if primaryTimeframe != "" {
+			timeframes = append(timeframes, primaryTimeframe)
+		} else {
+			timeframes = append(timeframes, "3m")
+		}
+		if config.Indicators.Klines.LongerTimeframe != "" {
+			timeframes = append(timeframes, config.Indicators.Klines.LongerTimeframe)
+		}
+	}
+	if primaryTimeframe == "" {
+		primaryTimeframe = timeframes[0]
+	}
+	if klineCount <= 0 {
+		klineCount = 30
+	}
+	// Rule strategies also need the timeframes their rules name and enough klines to warm up indicators
+	if config.IsRuleStrategy() {
+		if rules, err := kernel.CompileRules(config.RuleConfig); err == nil {
+			for _, tf := range rules.Timeframes() {
+				if !slices.Contains(timeframes, tf) {
+					timeframes = append(slices.Clip(timeframes), tf)
+				}
+			}
+			klineCount = max(klineCount, rules.Lookback())
+		}
+	}
+
+	fmt.Printf("📊 Using timeframes: %v, primary: %s, kline count: %d\n", timeframes, primaryTimeframe, klineCount)
+
+	// Get real market data (using multiple timeframes)
+	marketDataMap := make(map[string]*market.Data)
+	for _, coin := range candidates {
+		data, err := market.GetWithTimeframes(coin.Symbol, timeframes, primaryTimeframe, klineCount)
+		if err != nil {
+			// If getting data for a coin fails, log but continue
+			fmt.Printf("⚠️  Failed to get market data for %s: %v\n", coin.Symbol, err)
+			continue
+		}
+		marketDataMap[coin.Symbol] = data
+	}
+
+	// Fetch quantitative data for each candidate coin
+	symbols := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		symbols = append(symbols, c.Symbol)
+	}
+	quantDataMap := engine.FetchQuantDataBatch(symbols)
+
+	// Fetch OI ranking data (market-wide position changes)
+	oiRankingData := engine.FetchOIRankingData()
+
+	// Fetch NetFlow ranking data (market-wide fund flow)
+	netFlowRankingData := engine.FetchNetFlowRankingData()
+
+	// Fetch Price ranking data (market-wide gainers/losers)
+	priceRankingData := engine.FetchPriceRankingData()
+
+	// Build real context (for generating User Prompt)
+	testContext := &kernel.Context{
+		CurrentTime:    time.Now().UTC().Format("2006-01-02 15:04:05 UTC"),
+		RuntimeMinutes: 0,
+		CallCount:      1,
+		Account: kernel.AccountInfo{
+			TotalEquity:      accountEquity,
+			AvailableBalance: accountEquity,
+			UnrealizedPnL:    0,
+			TotalPnL:         0,
+			TotalPnLPct:      0,
+			MarginUsed:       0,
+			MarginUsedPct:    0,
+			PositionCount:    0,
+		},
+		Positions:          []kernel.PositionInfo{},
+		CandidateCoins:     candidates,
+		PromptVariant:      promptVariant,
+		MarketDataMap:      marketDataMap,
+		QuantDataMap:       quantDataMap,
+		OIRankingData:      oiRankingData,
+		NetFlowRankingData: netFlowRankingData,
+		PriceRankingData:   priceRankingData,
+	}
+
+	return testContext, nil
+}
+
+// strategyPromptBudget user prompt token budget for an explicit context window, else for the
+// model's own context window (0: no limit)
+func strategyPromptBudget(aiClient mcp.AIClient, contextWindow int, systemPrompt string) int {
+	if contextWindow > 0 {
+		return mcp.PromptBudget(contextWindow, mcp.DefaultConfig().MaxTokens, systemPrompt)
+	}
+	if budgeter, ok := aiClient.(mcp.PromptBudgeter); ok {
+		return budgeter.UserPromptBudget(systemPrompt)
+	}
+	return 0
+}
+
+// runRealAITest Execute real AI test call
+func runRealAITest(aiClient mcp.AIClient, systemPrompt, userPrompt string) (string, error) {
 	// Call AI API
 	response, err := aiClient.CallWithMessages(systemPrompt, userPrompt)
 	if err != nil {
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"nofx/kernel"
+	"nofx/store"
+)
+
+// traderAIModeRequest multi-model fields shared by create and update trader requests
+type traderAIModeRequest struct {
+	AIMode           string   `json:"ai_mode"`             // single/fallback/ensemble (empty keeps the current mode on update)
+	BackupAIModelIDs []string `json:"backup_ai_model_ids"` // Fallback order after the primary, or extra ensemble voters
+	EnsembleVote     string   `json:"ensemble_vote"`       // majority/confidence
+}
+
+// resolveTraderAIMode validates the multi-model fields and returns them in store form
+func (s *Server) resolveTraderAIMode(userID, primaryModelID string, req traderAIModeRequest) (mode, backupIDs, vote string, err error) {
+	mode = strings.TrimSpace(req.AIMode)
+	if mode == "" {
+		mode = store.TraderAIModeSingle
+	}
+	vote = strings.TrimSpace(req.EnsembleVote)
+	if vote == "" {
+		vote = kernel.EnsembleVoteConfidence
+	}
+
+	switch mode {
+	case store.TraderAIModeSingle:
+		return mode, "", vote, nil
+	case store.TraderAIModeFallback, store.TraderAIModeEnsemble:
+	default:
+		return "", "", "", fmt.Errorf("invalid ai_mode %q, must be single, fallback or ensemble", req.AIMode)
+	}
+	if vote != kernel.EnsembleVoteMajority && vote != kernel.EnsembleVoteConfidence {
+		return "", "", "", fmt.Errorf("invalid ensemble_vote %q, must be majority or confidence", req.EnsembleVote)
+	}
+
+	var ids []string
+	for _, id := range req.BackupAIModelIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || id == primaryModelID {
+			continue
+		}
+		if _, err := s.store.AIModel().Get(userID, id); err != nil {
+			return "", "", "", fmt.Errorf("backup AI model %s not found", id)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return "", "", "", fmt.Errorf("ai_mode %s requires at least one backup AI model", mode)
+	}
+	return mode, strings.Join(ids, ","), vote, nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"strings"
+
+	"nofx/trader/fillmodel"
 )
 
 const epsilon = 1e-8
@@ -18,28 +20,64 @@ type position struct {
 	Notional         float64
 	LiquidationPrice float64
 	OpenTime         int64
-	AccumulatedFee   float64 // Total fees paid (opening + any additions)
+	AccumulatedFee   float64           // Total fees paid (opening + any additions)
+	StopLoss         float64           // Protective stop price (0 = none)
+	TakeProfit       float64           // Take-profit price (0 = none)
+	TrailingStopPct  float64           // Stop-loss trails the best price by this % (0 = none)
+	BestPrice        float64           // Best price since the trailing stop was set
+	TakeProfitLevels []TakeProfitLevel // Partial take-profits, nearest to the entry first
+}
+
+// trail moves the trailing stop-loss after the high/low of a bar; it never loosens the stop
+func (pos *position) trail(high, low float64) {
+	if pos.TrailingStopPct <= 0 {
+		return
+	}
+	if pos.Side == "long" {
+		pos.BestPrice = math.Max(pos.BestPrice, high)
+		if stop := pos.BestPrice * (1 - pos.TrailingStopPct/100); stop > pos.StopLoss {
+			pos.StopLoss = stop
+		}
+		return
+	}
+	if pos.BestPrice <= 0 || low < pos.BestPrice {
+		pos.BestPrice = low
+	}
+	if stop := pos.BestPrice * (1 + pos.TrailingStopPct/100); pos.StopLoss <= 0 || stop < pos.StopLoss {
+		pos.StopLoss = stop
+	}
 }
 
 type BacktestAccount struct {
 	initialBalance float64
 	cash           float64
-	feeRate        float64
-	slippageRate   float64
+	fills          *fillmodel.Model
 	positions      map[string]*position
 	realizedPnL    float64
 }
 
 func NewBacktestAccount(initialBalance, feeBps, slippageBps float64) *BacktestAccount {
+	return NewBacktestAccountWithModel(initialBalance, fillmodel.New(fillmodel.Config{
+		Fees:         fillmodel.FlatFees(feeBps / 10000.0),
+		SlippageRate: slippageBps / 10000.0,
+	}))
+}
+
+// NewBacktestAccountWithModel creates an account whose fees and slippage come from the shared fill model.
+func NewBacktestAccountWithModel(initialBalance float64, model *fillmodel.Model) *BacktestAccount {
 	return &BacktestAccount{
 		initialBalance: initialBalance,
 		cash:           initialBalance,
-		feeRate:        feeBps / 10000.0,
-		slippageRate:   slippageBps / 10000.0,
+		fills:          model,
 		positions:      make(map[string]*position),
 	}
 }
 
+// FillModel returns the fill model used for executions.
+func (acc *BacktestAccount) FillModel() *fillmodel.Model {
+	return acc.fills
+}
+
 func positionKey(symbol, side string) string {
 	return strings.ToUpper(symbol) + ":" + side
 }
@@ -67,10 +105,27 @@ func (acc *BacktestAccount) Open(symbol, side string, quantity float64, leverage
 		return nil, 0, 0, fmt.Errorf("leverage must be positive")
 	}
 
-	execPrice := applySlippage(price, acc.slippageRate, side, true)
+	execPrice := acc.fills.Slip(price, orderSide(side, true))
+	fee := acc.fills.Fee(execPrice*quantity, false)
+	return acc.open(symbol, side, quantity, leverage, execPrice, fee, ts)
+}
+
+// OpenFill opens or adds to a position with a fill already priced by the fill model (e.g. a resting grid limit order).
+// Returns the same values as Open.
+func (acc *BacktestAccount) OpenFill(symbol, side string, leverage int, fill fillmodel.Fill) (*position, float64, float64, error) {
+	if fill.Quantity <= 0 {
+		return nil, 0, 0, fmt.Errorf("quantity must be positive")
+	}
+	if leverage <= 0 {
+		return nil, 0, 0, fmt.Errorf("leverage must be positive")
+	}
+	return acc.open(symbol, side, fill.Quantity, leverage, fill.Price, fill.Fee, fill.Time)
+}
+
+// open books an entry of quantity at execPrice, charging fee on top of the margin.
+func (acc *BacktestAccount) open(symbol, side string, quantity float64, leverage int, execPrice, fee float64, ts int64) (*position, float64, float64, error) {
 	notional := execPrice * quantity
 	margin := notional / float64(leverage)
-	fee := notional * acc.feeRate
 
 	if margin+fee > acc.cash+epsilon {
 		return nil, 0, 0, fmt.Errorf("insufficient cash: need %.2f", margin+fee)
@@ -107,24 +162,93 @@ func (acc *BacktestAccount) Open(symbol, side string, quantity float64, leverage
 }
 
 func (acc *BacktestAccount) Close(symbol, side string, quantity float64, price float64) (float64, float64, float64, error) {
+	pos, quantity, err := acc.closable(symbol, side, quantity)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	execPrice := acc.fills.Slip(price, orderSide(side, false))
+	closeNotional := execPrice * quantity // Notional at close price (for fee calculation)
+	closingFee := acc.fills.Fee(closeNotional, false)
+
+	realized, totalFee := acc.settleClose(pos, quantity, execPrice, closingFee)
+	return realized, totalFee, execPrice, nil
+}
+
+// CloseFill closes a position with a fill already priced by the fill model (e.g. a triggered stop-loss).
+// Returns the same values as Close.
+func (acc *BacktestAccount) CloseFill(symbol, side string, fill fillmodel.Fill) (float64, float64, float64, error) {
+	key := positionKey(symbol, side)
+	quantity := fill.Quantity
+	closingFee := fill.Fee
+	if pos, ok := acc.positions[key]; ok && quantity > pos.Quantity {
+		// Protective orders may be sized larger than what is left of the position
+		closingFee *= pos.Quantity / quantity
+		quantity = pos.Quantity
+	}
+
+	pos, quantity, err := acc.closable(symbol, side, quantity)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	realized, totalFee := acc.settleClose(pos, quantity, fill.Price, closingFee)
+	return realized, totalFee, fill.Price, nil
+}
+
+// SetProtection records stop-loss / take-profit levels for a position. Zero leaves a level unchanged.
+func (acc *BacktestAccount) SetProtection(symbol, side string, stopLoss, takeProfit float64) {
+	pos, ok := acc.positions[positionKey(symbol, side)]
+	if !ok {
+		return
+	}
+	if stopLoss > 0 {
+		pos.StopLoss = stopLoss
+	}
+	if takeProfit > 0 {
+		pos.TakeProfit = takeProfit
+	}
+}
+
+// SetTrailingStop trails the stop-loss of a position callbackPct behind its best price, starting at price.
+func (acc *BacktestAccount) SetTrailingStop(symbol, side string, callbackPct, price float64) {
+	pos, ok := acc.positions[positionKey(symbol, side)]
+	if !ok || callbackPct <= 0 {
+		return
+	}
+	pos.TrailingStopPct = callbackPct
+	pos.BestPrice = price
+	pos.trail(price, price)
+}
+
+// SetTakeProfitLadder replaces the partial take-profits of a position. An empty ladder leaves them unchanged.
+func (acc *BacktestAccount) SetTakeProfitLadder(symbol, side string, levels []TakeProfitLevel) {
+	pos, ok := acc.positions[positionKey(symbol, side)]
+	if !ok || len(levels) == 0 {
+		return
+	}
+	pos.TakeProfitLevels = levels
+}
+
+// closable returns the position and the quantity to close (0 means the whole position).
+func (acc *BacktestAccount) closable(symbol, side string, quantity float64) (*position, float64, error) {
 	key := positionKey(symbol, side)
 	pos, ok := acc.positions[key]
 	if !ok || pos.Quantity <= epsilon {
-		return 0, 0, 0, fmt.Errorf("no active %s position for %s", side, symbol)
+		return nil, 0, fmt.Errorf("no active %s position for %s", side, symbol)
 	}
 
 	if quantity <= 0 || quantity > pos.Quantity+epsilon {
 		if math.Abs(quantity) <= epsilon {
 			quantity = pos.Quantity
 		} else {
-			return 0, 0, 0, fmt.Errorf("invalid close quantity")
+			return nil, 0, fmt.Errorf("invalid close quantity")
 		}
 	}
+	return pos, quantity, nil
+}
 
-	execPrice := applySlippage(price, acc.slippageRate, side, false)
-	closeNotional := execPrice * quantity // Notional at close price (for fee calculation)
-	closingFee := closeNotional * acc.feeRate
-
+// settleClose books a close of quantity at execPrice and returns realized PnL and total fee (opening + closing).
+func (acc *BacktestAccount) settleClose(pos *position, quantity, execPrice, closingFee float64) (float64, float64) {
 	// Calculate proportional values based on the portion being closed
 	closePortion := quantity / pos.Quantity
 	openingFeePortion := pos.AccumulatedFee * closePortion
@@ -152,7 +276,7 @@ func (acc *BacktestAccount) Close(symbol, side string, quantity float64, price f
 	}
 
 	// Return total fee (opening + closing) so caller can calculate accurate P&L
-	return realized, totalFee, execPrice, nil
+	return realized, totalFee
 }
 
 func (acc *BacktestAccount) TotalEquity(priceMap map[string]float64) (float64, float64, map[string]float64) {
@@ -169,25 +293,12 @@ func (acc *BacktestAccount) TotalEquity(priceMap map[string]float64) (float64, f
 	return acc.cash + margin + unrealized, unrealized, perSymbol
 }
 
-func applySlippage(price float64, rate float64, side string, isOpen bool) float64 {
-	if rate <= 0 {
-		return price
-	}
-	adjust := 1.0
-	if side == "long" {
-		if isOpen {
-			adjust += rate
-		} else {
-			adjust -= rate
-		}
-	} else {
-		if isOpen {
-			adjust -= rate
-		} else {
-			adjust += rate
-		}
+// orderSide maps a position side and direction to the BUY/SELL side of the order.
+func orderSide(side string, isOpen bool) string {
+	if (side == "long") == isOpen {
+		return fillmodel.SideBuy
 	}
-	return price * adjust
+	return fillmodel.SideSell
 }
 
 func computeLiquidation(entry float64, leverage int, side string) float64 {
@@ -260,6 +371,11 @@ func (acc *BacktestAccount) RestoreFromSnapshots(cash float64, realized float64,
 			LiquidationPrice: snap.LiquidationPrice,
 			OpenTime:         snap.OpenTime,
 			AccumulatedFee:   snap.AccumulatedFee,
+			StopLoss:         snap.StopLoss,
+			TakeProfit:       snap.TakeProfit,
+			TrailingStopPct:  snap.TrailingStopPct,
+			BestPrice:        snap.BestPrice,
+			TakeProfitLevels: append([]TakeProfitLevel(nil), snap.TakeProfitLevels...),
 		}
 		key := positionKey(pos.Symbol, pos.Side)
 		acc.positions[key] = pos
@@ -71,6 +71,11 @@ func configureMCPClient(cfg BacktestConfig, base mcp.AIClient) (mcp.AIClient, er
 		oaiC := mcp.NewOpenAIClientWithOptions()
 		oaiC.(*mcp.OpenAIClient).SetAPIKey(cfg.AICfg.APIKey, cfg.AICfg.BaseURL, cfg.AICfg.Model)
 		return oaiC, nil
+	case "local":
+		// Local OpenAI-compatible server: key optional, model discovered if not set
+		lc := mcp.NewLocalClientWithOptions()
+		lc.SetAPIKey(cfg.AICfg.APIKey, cfg.AICfg.BaseURL, cfg.AICfg.Model)
+		return lc, nil
 	case "custom":
 		if cfg.AICfg.BaseURL == "" || cfg.AICfg.APIKey == "" || cfg.AICfg.Model == "" {
 			return nil, fmt.Errorf("custom provider requires base_url, api key and model")
@@ -83,49 +88,12 @@ func configureMCPClient(cfg BacktestConfig, base mcp.AIClient) (mcp.AIClient, er
 	}
 }
 
-// cloneBaseClient copies the base client to avoid shared mutable state.
-func cloneBaseClient(base mcp.AIClient) *mcp.Client {
-	// Prefer to reuse the passed-in base client (deep copy)
-	switch c := base.(type) {
-	case *mcp.Client:
-		cp := *c
-		return &cp
-	case *mcp.DeepSeekClient:
-		if c != nil && c.Client != nil {
-			cp := *c.Client
-			return &cp
-		}
-	case *mcp.QwenClient:
-		if c != nil && c.Client != nil {
-			cp := *c.Client
-			return &cp
-		}
-	case *mcp.ClaudeClient:
-		if c != nil && c.Client != nil {
-			cp := *c.Client
-			return &cp
-		}
-	case *mcp.KimiClient:
-		if c != nil && c.Client != nil {
-			cp := *c.Client
-			return &cp
-		}
-	case *mcp.GeminiClient:
-		if c != nil && c.Client != nil {
-			cp := *c.Client
-			return &cp
-		}
-	case *mcp.GrokClient:
-		if c != nil && c.Client != nil {
-			cp := *c.Client
-			return &cp
-		}
-	case *mcp.OpenAIClient:
-		if c != nil && c.Client != nil {
-			cp := *c.Client
-			return &cp
-		}
+// cloneBaseClient copies the base client to avoid shared mutable state. Provider hooks are
+// rebound to the copy, so it reports usage under its own attribution.
+func cloneBaseClient(base mcp.AIClient) mcp.AIClient {
+	if client := mcp.CloneClient(base); client != nil {
+		return client
 	}
 	// Fall back to a new default client
-	return mcp.NewClient().(*mcp.Client)
+	return mcp.NewClient()
 }
@@ -0,0 +1,64 @@
+package backtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"nofx/mcp"
+)
+
+func TestCloneBaseClientReportsOwnAttribution(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		keys     []string
+		reported []mcp.TokenUsage
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("x-api-key"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":100,"output_tokens":20}}`))
+	}))
+	defer server.Close()
+
+	previous := mcp.TokenUsageCallback
+	mcp.TokenUsageCallback = func(usage mcp.TokenUsage) {
+		mu.Lock()
+		reported = append(reported, usage)
+		mu.Unlock()
+	}
+	defer func() { mcp.TokenUsageCallback = previous }()
+
+	base := mcp.NewClaudeClientWithOptions(mcp.WithAPIKey("base-key"), mcp.WithBaseURL(server.URL))
+	mcp.AttributeUsage(base, mcp.UsageAttribution{Source: mcp.UsageSourceTrader, SourceID: "trader-1"})
+
+	// Two backtests sharing the trader's Claude client
+	for _, runID := range []string{"run-1", "run-2"} {
+		client := cloneBaseClient(base)
+		mcp.AttributeUsage(client, mcp.UsageAttribution{Source: mcp.UsageSourceBacktest, SourceID: runID})
+		if _, err := client.CallWithMessages("sys", "user"); err != nil {
+			t.Fatalf("%s: CallWithMessages: %v", runID, err)
+		}
+	}
+
+	if len(reported) != 2 {
+		t.Fatalf("expected 2 usage reports, got %d", len(reported))
+	}
+	for i, want := range []string{"run-1", "run-2"} {
+		if got := reported[i].Attribution; got.Source != mcp.UsageSourceBacktest || got.SourceID != want {
+			t.Errorf("usage %d attributed to %+v, want %s", i, got, want)
+		}
+		if reported[i].Provider != mcp.ProviderClaude || reported[i].TotalTokens != 120 {
+			t.Errorf("unexpected usage %d: %+v", i, reported[i])
+		}
+		if keys[i] != "base-key" {
+			t.Errorf("clone %d should keep Claude auth, got x-api-key %q", i, keys[i])
+		}
+	}
+	if got := base.(*mcp.ClaudeClient).UsageAttribution().SourceID; got != "trader-1" {
+		t.Errorf("cloning changed the base attribution to %q", got)
+	}
+}
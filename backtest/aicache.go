@@ -60,6 +60,54 @@ func LoadAICache(path string) (*AICache, error) {
 	return cache, nil
 }
 
+var (
+	sharedCachesMu sync.Mutex
+	sharedCaches   = make(map[string]*sharedAICache)
+)
+
+// sharedAICache an AICache with the number of runs using it
+type sharedAICache struct {
+	cache *AICache
+	refs  int
+}
+
+// OpenSharedAICache returns one AICache per file so concurrent runs that share
+// ai_cache_path (e.g. sweep variants) don't overwrite each other's entries.
+// Each call must be paired with ReleaseSharedAICache.
+func OpenSharedAICache(path string) (*AICache, error) {
+	if path == "" {
+		return nil, fmt.Errorf("ai cache path is empty")
+	}
+	key := filepath.Clean(path)
+	sharedCachesMu.Lock()
+	defer sharedCachesMu.Unlock()
+	if shared, ok := sharedCaches[key]; ok {
+		shared.refs++
+		return shared.cache, nil
+	}
+	cache, err := LoadAICache(path)
+	if err != nil {
+		return nil, err
+	}
+	sharedCaches[key] = &sharedAICache{cache: cache, refs: 1}
+	return cache, nil
+}
+
+// ReleaseSharedAICache releases a cache opened with OpenSharedAICache. The last release drops it
+// from memory; entries are already on disk since Put saves them.
+func ReleaseSharedAICache(path string) {
+	key := filepath.Clean(path)
+	sharedCachesMu.Lock()
+	defer sharedCachesMu.Unlock()
+	shared, ok := sharedCaches[key]
+	if !ok {
+		return
+	}
+	if shared.refs--; shared.refs <= 0 {
+		delete(sharedCaches, key)
+	}
+}
+
 func (c *AICache) Path() string {
 	if c == nil {
 		return ""
@@ -144,7 +192,7 @@ func computeCacheKey(ctx *kernel.Context, variant string, ts int64) (string, err
 		Timestamp:      ts,
 		CurrentTime:    ctx.CurrentTime,
 		Account:        ctx.Account,
-		Positions:      ctx.Positions,
+		Positions:      make([]kernel.PositionInfo, len(ctx.Positions)),
 		CandidateCoins: ctx.CandidateCoins,
 		MarginUsedPct:  ctx.Account.MarginUsedPct,
 		Runtime:        ctx.RuntimeMinutes,
@@ -152,6 +200,12 @@ func computeCacheKey(ctx *kernel.Context, variant string, ts int64) (string, err
 		MarketData:     make(map[string]market.Data, len(ctx.MarketDataMap)),
 	}
 
+	// UpdateTime is wall-clock time in backtests; keep it out of the key so replays hit the cache
+	copy(payload.Positions, ctx.Positions)
+	for i := range payload.Positions {
+		payload.Positions[i].UpdateTime = 0
+	}
+
 	for symbol, data := range ctx.MarketDataMap {
 		if data == nil {
 			continue
@@ -0,0 +1,318 @@
+package backtest
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"nofx/logger"
+	"nofx/market"
+	"nofx/store"
+)
+
+// Replay bundles make a run reproducible elsewhere: the export zip carries the config,
+// the resolved strategy, every kline the data feed consumed and the cached AI responses
+// under bundle/. Importing a bundle re-runs it in replay_only mode without network or LLM access.
+const (
+	replayBundleVersion = 1
+	replayBundleDir     = "bundle/"
+
+	bundleManifestFile = "manifest.json"
+	bundleConfigFile   = "config.json"
+	bundleStrategyFile = "strategy.json"
+	bundleAICacheFile  = "ai_cache.json"
+	bundleKlinesFile   = "klines.json.gz"
+)
+
+// ReplayBundleManifest describes the bundle inside a run export.
+type ReplayBundleManifest struct {
+	Version     int       `json:"version"`
+	RunID       string    `json:"run_id"`
+	ExportedAt  time.Time `json:"exported_at"`
+	Replayable  bool      `json:"replayable"`
+	Note        string    `json:"note,omitempty"`
+	AIResponses int       `json:"ai_responses"`
+	Metrics     *Metrics  `json:"metrics,omitempty"`
+}
+
+// replayKlines is the kline snapshot a run consumed, keyed by symbol and timeframe.
+type replayKlines struct {
+	Series    map[string]map[string][]market.Kline `json:"series"`
+	Benchmark []market.Kline                       `json:"benchmark,omitempty"`
+}
+
+// replayInputsDir holds the inputs needed to rebuild a bundle after the run finishes.
+func replayInputsDir(runID string) string {
+	return filepath.Join(runDir(runID), "replay")
+}
+
+func replayKlinesPath(runID string) string {
+	return filepath.Join(replayInputsDir(runID), bundleKlinesFile)
+}
+
+func replayStrategyPath(runID string) string {
+	return filepath.Join(replayInputsDir(runID), bundleStrategyFile)
+}
+
+// saveReplayInputs snapshots the resolved strategy and the klines loaded by the feed.
+func saveReplayInputs(cfg *BacktestConfig, feed *DataFeed, benchmark []market.Kline, strategy *store.StrategyConfig) error {
+	if strategy != nil {
+		if err := writeJSONAtomic(replayStrategyPath(cfg.RunID), strategy); err != nil {
+			return err
+		}
+	}
+	if feed == nil || cfg.ReplayKlinesPath != "" {
+		return nil // replayed runs already read their klines from a snapshot
+	}
+
+	snapshot := replayKlines{
+		Series:    make(map[string]map[string][]market.Kline, len(feed.symbolSeries)),
+		Benchmark: benchmark,
+	}
+	for symbol, ss := range feed.symbolSeries {
+		byTF := make(map[string][]market.Kline, len(ss.byTF))
+		for tf, series := range ss.byTF {
+			byTF[tf] = series.klines
+		}
+		snapshot.Series[symbol] = byTF
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(&snapshot); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return writeFileAtomic(replayKlinesPath(cfg.RunID), buf.Bytes(), 0o644)
+}
+
+func loadReplayKlines(path string) (*replayKlines, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	var snapshot replayKlines
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func loadReplayStrategy(runID string) (*store.StrategyConfig, error) {
+	data, err := os.ReadFile(replayStrategyPath(runID))
+	if err != nil {
+		return nil, err
+	}
+	var strategy store.StrategyConfig
+	if err := json.Unmarshal(data, &strategy); err != nil {
+		return nil, err
+	}
+	return &strategy, nil
+}
+
+// aiCachePath returns where the run stores its cached AI decisions.
+func aiCachePath(cfg *BacktestConfig) string {
+	if cfg.SharedAICachePath != "" {
+		return cfg.SharedAICachePath
+	}
+	return filepath.Join(runDir(cfg.RunID), "ai_cache.json")
+}
+
+// writeReplayBundle adds the bundle/ directory to a run export.
+func writeReplayBundle(z *zip.Writer, runID string) error {
+	cfg, err := LoadConfig(runID)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	bundleCfg := *cfg
+	bundleCfg.AICfg.APIKey = ""
+	bundleCfg.AICfg.SecretKey = ""
+
+	manifest := ReplayBundleManifest{
+		Version:    replayBundleVersion,
+		RunID:      runID,
+		ExportedAt: time.Now().UTC(),
+		Replayable: true,
+	}
+	if metrics, err := LoadMetrics(runID); err == nil {
+		manifest.Metrics = metrics
+	}
+
+	strategy, err := loadReplayStrategy(runID)
+	if err != nil {
+		strategy = cfg.ToStrategyConfig()
+	}
+
+	klines, err := os.ReadFile(replayKlinesPath(runID))
+	if err != nil {
+		manifest.Replayable = false
+		manifest.Note = "kline snapshot missing (run predates replay bundles)"
+	}
+
+	var cache *AICache
+	if !cfg.IsGrid() && !cfg.IsRules() {
+		if c, err := LoadAICache(aiCachePath(cfg)); err == nil {
+			cache = c
+			manifest.AIResponses = len(c.Entries)
+		}
+		if manifest.AIResponses == 0 && manifest.Replayable {
+			manifest.Replayable = false
+			manifest.Note = "run has no cached AI responses (enable cache_ai to make it replayable)"
+		}
+	}
+
+	if err := writeJSONToZip(z, replayBundleDir+bundleManifestFile, manifest); err != nil {
+		return err
+	}
+	if err := writeJSONToZip(z, replayBundleDir+bundleConfigFile, &bundleCfg); err != nil {
+		return err
+	}
+	if err := writeJSONToZip(z, replayBundleDir+bundleStrategyFile, strategy); err != nil {
+		return err
+	}
+	if cache != nil {
+		cache.mu.RLock()
+		err := writeJSONToZip(z, replayBundleDir+bundleAICacheFile, cache)
+		cache.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+	if len(klines) > 0 {
+		// Already gzip-compressed
+		w, err := z.CreateHeader(&zip.FileHeader{Name: replayBundleDir + bundleKlinesFile, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(klines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportBundle starts a replay_only run from an exported run zip. Klines and AI responses
+// come from the bundle, so the run needs neither market data nor an LLM and reproduces the original.
+func (m *Manager) ImportBundle(r io.ReaderAt, size int64, runID, userID string) (*Runner, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle archive: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		if name, ok := strings.CutPrefix(f.Name, replayBundleDir); ok {
+			files[name] = f
+		}
+	}
+
+	var manifest ReplayBundleManifest
+	if err := readZipJSON(files, bundleManifestFile, &manifest); err != nil {
+		return nil, fmt.Errorf("not a replay bundle: %w", err)
+	}
+	if manifest.Version > replayBundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+	if !manifest.Replayable {
+		return nil, fmt.Errorf("bundle is not replayable: %s", manifest.Note)
+	}
+
+	var cfg BacktestConfig
+	if err := readZipJSON(files, bundleConfigFile, &cfg); err != nil {
+		return nil, err
+	}
+	var strategy store.StrategyConfig
+	if err := readZipJSON(files, bundleStrategyFile, &strategy); err != nil {
+		return nil, err
+	}
+	klines, err := readZipFile(files, bundleKlinesFile)
+	if err != nil {
+		return nil, err
+	}
+	cache := &AICache{Entries: make(map[string]cachedDecision)}
+	if !cfg.IsGrid() && !cfg.IsRules() {
+		if err := readZipJSON(files, bundleAICacheFile, cache); err != nil {
+			return nil, err
+		}
+	}
+
+	runID = strings.TrimSpace(runID)
+	if runID == "" {
+		runID = "replay_" + time.Now().UTC().Format("20060102_150405")
+	}
+	if _, err := LoadRunMetadata(runID); err == nil {
+		return nil, fmt.Errorf("run %s already exists", runID)
+	}
+
+	cfg.RunID = runID
+	cfg.UserID = userID
+	cfg.StrategyID = ""
+	cfg.AIModelID = ""
+	cfg.AICfg = AIConfig{Provider: "inherit", Model: cfg.AICfg.Model, Temperature: cfg.AICfg.Temperature}
+	cfg.ReplayOnly = true
+	cfg.CacheAI = false
+	cfg.SharedAICachePath = ""
+	cfg.ReplayKlinesPath = replayKlinesPath(runID)
+	cfg.SetLoadedStrategy(&strategy)
+
+	if err := ensureRunDir(runID); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(cfg.ReplayKlinesPath, klines, 0o644); err != nil {
+		return nil, err
+	}
+	if !cfg.IsGrid() && !cfg.IsRules() {
+		cache.path = aiCachePath(&cfg)
+		if err := cache.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	runner, err := m.Start(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.UpdateLabel(runID, "Replay of "+manifest.RunID); err != nil {
+		logger.Infof("failed to label replay run %s: %v", runID, err)
+	}
+	return runner, nil
+}
+
+func readZipFile(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("bundle is missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func readZipJSON(files map[string]*zip.File, name string, v any) error {
+	data, err := readZipFile(files, name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", name, err)
+	}
+	return nil
+}
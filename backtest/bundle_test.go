@@ -0,0 +1,141 @@
+package backtest
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+// bundleTestKlines stores 15m BTCUSDT bars around [start, end] in an offline kline store,
+// so the original run loads its klines without network access
+func bundleTestKlines(t *testing.T, start, end time.Time) {
+	t.Helper()
+	previous := market.GetKlineStore()
+	t.Cleanup(func() { market.SetKlineStore(previous) })
+	ks := market.NewKlineStore(t.TempDir(), true)
+	market.SetKlineStore(ks)
+
+	step := 15 * time.Minute
+	var klines []market.Kline
+	for ts, i := start.Add(-250*step), 0; !ts.After(end.Add(2 * step)); ts, i = ts.Add(step), i+1 {
+		mid := 40000 + 800*math.Sin(float64(i)/7) + 300*math.Sin(float64(i)/3)
+		klines = append(klines, market.Kline{
+			OpenTime:  ts.UnixMilli(),
+			Open:      mid - 20,
+			High:      mid + 120,
+			Low:       mid - 120,
+			Close:     mid + 20,
+			Volume:    1000,
+			CloseTime: ts.Add(step).UnixMilli() - 1,
+		})
+	}
+	if _, err := ks.Save("binance", "BTCUSDT", "15m", klines); err != nil {
+		t.Fatalf("save klines: %v", err)
+	}
+}
+
+// waitForRun waits for a run and for its watcher to persist the final metadata
+func waitForRun(t *testing.T, m *Manager, runID string) {
+	t.Helper()
+	if err := m.Wait(runID); err != nil {
+		t.Fatalf("run %s: %v", runID, err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, active := m.GetRunner(runID); !active {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run %s still active after it finished", runID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplayBundleRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(3 * 24 * time.Hour)
+	bundleTestKlines(t, start, end)
+
+	m := NewManager(nil)
+	cfg := BacktestConfig{
+		RunID:                "bundle-original",
+		Symbols:              []string{"BTCUSDT"},
+		Timeframes:           []string{"15m"},
+		DecisionTimeframe:    "15m",
+		DecisionCadenceNBars: 1,
+		StartTS:              start.Unix(),
+		EndTS:                end.Unix(),
+		InitialBalance:       10000,
+		FeeBps:               4,
+		Leverage:             LeverageConfig{BTCETHLeverage: 3, AltcoinLeverage: 3},
+		RuleConfig: &store.RuleStrategyConfig{
+			EntryLong:     "close crosses above sma20",
+			ExitLong:      "close crosses below sma20",
+			EntryShort:    "close crosses below sma20",
+			ExitShort:     "close crosses above sma20",
+			StopLossPct:   1,
+			TakeProfitPct: 2,
+		},
+	}
+	if _, err := m.Start(t.Context(), cfg); err != nil {
+		t.Fatalf("start original run: %v", err)
+	}
+	waitForRun(t, m, cfg.RunID)
+
+	exportPath, err := m.ExportRun(cfg.RunID)
+	if err != nil {
+		t.Fatalf("ExportRun: %v", err)
+	}
+	defer os.Remove(exportPath)
+
+	// The replay may only read klines from the bundle
+	market.SetKlineStore(market.NewKlineStore(t.TempDir(), true))
+
+	f, err := os.Open(exportPath)
+	if err != nil {
+		t.Fatalf("open export: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("stat export: %v", err)
+	}
+	if _, err := m.ImportBundle(f, info.Size(), "bundle-replay", "default"); err != nil {
+		t.Fatalf("ImportBundle: %v", err)
+	}
+	waitForRun(t, m, "bundle-replay")
+
+	wantTrades, err := LoadTradeEvents(cfg.RunID)
+	if err != nil {
+		t.Fatalf("load original trades: %v", err)
+	}
+	gotTrades, err := LoadTradeEvents("bundle-replay")
+	if err != nil {
+		t.Fatalf("load replay trades: %v", err)
+	}
+	if len(wantTrades) == 0 {
+		t.Fatal("original run made no trades")
+	}
+	if !reflect.DeepEqual(gotTrades, wantTrades) {
+		t.Fatalf("replay trades differ:\n got %d %+v\nwant %d %+v", len(gotTrades), gotTrades, len(wantTrades), wantTrades)
+	}
+
+	wantMetrics, err := LoadMetrics(cfg.RunID)
+	if err != nil {
+		t.Fatalf("load original metrics: %v", err)
+	}
+	gotMetrics, err := LoadMetrics("bundle-replay")
+	if err != nil {
+		t.Fatalf("load replay metrics: %v", err)
+	}
+	if !reflect.DeepEqual(gotMetrics, wantMetrics) {
+		t.Errorf("replay metrics differ:\n got %+v\nwant %+v", gotMetrics, wantMetrics)
+	}
+}
@@ -2,11 +2,14 @@ package backtest
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
+	"nofx/kernel"
 	"nofx/market"
 	"nofx/store"
+	"nofx/trader/fillmodel"
 )
 
 // AIConfig defines the AI client configuration used in backtesting.
@@ -24,6 +27,33 @@ type LeverageConfig struct {
 	AltcoinLeverage int `json:"altcoin_leverage"`
 }
 
+// reID allowlist of new run and sweep IDs. They name directories, so they start with a letter or
+// digit ("." and ".." can't get through) and never contain path separators.
+var reID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// validateID checks a new run or sweep ID against reID
+func validateID(field, id string) error {
+	if err := checkStoredID(field, id); err != nil {
+		return err
+	}
+	if !reID.MatchString(id) {
+		return fmt.Errorf("%s %q may only contain letters, digits, '_', '-' and '.' and must start with a letter or digit", field, id)
+	}
+	return nil
+}
+
+// checkStoredID checks the ID of an existing run. Runs created before the reID allowlist may
+// contain spaces or colons, so only IDs that would leave the backtests directory are rejected.
+func checkStoredID(field, id string) error {
+	if id == "" {
+		return fmt.Errorf("%s cannot be empty", field)
+	}
+	if id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid %s %q", field, id)
+	}
+	return nil
+}
+
 // BacktestConfig describes the input configuration for a backtest run.
 type BacktestConfig struct {
 	RunID                string   `json:"run_id"`
@@ -38,8 +68,11 @@ type BacktestConfig struct {
 	EndTS                int64    `json:"end_ts"`
 	InitialBalance       float64  `json:"initial_balance"`
 	FeeBps               float64  `json:"fee_bps"`
+	FeeExchange          string   `json:"fee_exchange,omitempty"` // Optional: use this exchange's maker/taker schedule instead of FeeBps
 	SlippageBps          float64  `json:"slippage_bps"`
 	FillPolicy           string   `json:"fill_policy"`
+	MaxParticipation     float64  `json:"max_participation,omitempty"` // Max fraction of bar volume per fill; larger orders fill partially
+	IntrabarTieBreak     string   `json:"intrabar_tie_break,omitempty"` // stop_first (default) / target_first / nearest_open when SL and TP are both crossed in one bar
 	PromptVariant        string   `json:"prompt_variant"`
 	PromptTemplate       string   `json:"prompt_template"`
 	CustomPrompt         string   `json:"custom_prompt"`
@@ -54,6 +87,22 @@ type BacktestConfig struct {
 	CheckpointIntervalBars    int    `json:"checkpoint_interval_bars,omitempty"`
 	CheckpointIntervalSeconds int    `json:"checkpoint_interval_seconds,omitempty"`
 	ReplayDecisionDir         string `json:"replay_decision_dir,omitempty"`
+	ReplayKlinesPath          string `json:"replay_klines_path,omitempty"` // Kline snapshot of an imported replay bundle (no market data fetches)
+
+	// Optional: simulate a grid_trading strategy (limit orders at grid levels) instead of AI decisions
+	GridConfig *store.GridStrategyConfig `json:"grid_config,omitempty"`
+
+	// Optional: run a rule-based strategy (deterministic entry/exit rules) instead of AI decisions
+	RuleConfig *store.RuleStrategyConfig `json:"rule_config,omitempty"`
+
+	// Optional: replace the strategy's risk control (used by parameter sweeps); leverage still comes from Leverage
+	RiskControl *store.RiskControlConfig `json:"risk_control,omitempty"`
+
+	// Optional: run a prompt template version (resolved into PromptVariant/PromptSections/CustomPrompt at start)
+	PromptTemplateID      string                      `json:"prompt_template_id,omitempty"`
+	PromptTemplateVersion int                         `json:"prompt_template_version,omitempty"` // 0 = current version
+	PromptExperimentID    string                      `json:"prompt_experiment_id,omitempty"`    // Pick an arm of this experiment instead
+	PromptSections        *store.PromptSectionsConfig `json:"prompt_sections,omitempty"`
 
 	// Internal: loaded strategy config (set by Manager when StrategyID is provided)
 	loadedStrategy *store.StrategyConfig `json:"-"`
@@ -65,8 +114,8 @@ func (cfg *BacktestConfig) Validate() error {
 		return fmt.Errorf("config is nil")
 	}
 	cfg.RunID = strings.TrimSpace(cfg.RunID)
-	if cfg.RunID == "" {
-		return fmt.Errorf("run_id cannot be empty")
+	if err := checkStoredID("run_id", cfg.RunID); err != nil {
+		return err
 	}
 	cfg.UserID = strings.TrimSpace(cfg.UserID)
 	if cfg.UserID == "" {
@@ -74,6 +123,12 @@ func (cfg *BacktestConfig) Validate() error {
 	}
 	cfg.AIModelID = strings.TrimSpace(cfg.AIModelID)
 
+	if cfg.GridConfig != nil {
+		if err := cfg.validateGrid(); err != nil {
+			return err
+		}
+	}
+
 	if len(cfg.Symbols) == 0 {
 		return fmt.Errorf("at least one symbol is required")
 	}
@@ -93,6 +148,37 @@ func (cfg *BacktestConfig) Validate() error {
 		normTF = append(normTF, normalized)
 	}
 	cfg.Timeframes = normTF
+	if cfg.IsGrid() {
+		// Box breakout detection and ATR bounds use the same candles as live grid trading
+		if !contains(cfg.Timeframes, gridBoxTimeframe) {
+			cfg.Timeframes = append(cfg.Timeframes, gridBoxTimeframe)
+		}
+		if cfg.GridConfig.UseATRBounds && !contains(cfg.Timeframes, gridATRTimeframe) {
+			cfg.Timeframes = append(cfg.Timeframes, gridATRTimeframe)
+		}
+	}
+	if cfg.IsRules() {
+		rules, err := kernel.CompileRules(cfg.RuleConfig)
+		if err != nil {
+			return fmt.Errorf("invalid rule_config: %w", err)
+		}
+		// Timeframes named by the rules are loaded like the others
+		for _, tf := range rules.Timeframes() {
+			if !contains(cfg.Timeframes, tf) {
+				cfg.Timeframes = append(cfg.Timeframes, tf)
+			}
+		}
+	} else if cfg.loadedStrategy != nil && cfg.loadedStrategy.HybridRules != nil && !cfg.IsGrid() {
+		hybrid, err := kernel.CompileHybridRules(cfg.loadedStrategy.HybridRules)
+		if err != nil {
+			return fmt.Errorf("invalid hybrid_rules: %w", err)
+		}
+		for _, tf := range hybrid.Timeframes() {
+			if !contains(cfg.Timeframes, tf) {
+				cfg.Timeframes = append(cfg.Timeframes, tf)
+			}
+		}
+	}
 
 	if cfg.DecisionTimeframe == "" {
 		cfg.DecisionTimeframe = cfg.Timeframes[0]
@@ -122,6 +208,21 @@ func (cfg *BacktestConfig) Validate() error {
 		return err
 	}
 
+	cfg.FeeExchange = strings.ToLower(strings.TrimSpace(cfg.FeeExchange))
+	if cfg.FeeExchange != "" {
+		if _, ok := fillmodel.ExchangeFees(cfg.FeeExchange); !ok {
+			return fmt.Errorf("unsupported fee_exchange '%s'", cfg.FeeExchange)
+		}
+	}
+	if cfg.MaxParticipation < 0 || cfg.MaxParticipation > 1 {
+		return fmt.Errorf("max_participation must be between 0 and 1")
+	}
+	tieBreak, err := fillmodel.ParseTieBreak(cfg.IntrabarTieBreak)
+	if err != nil {
+		return fmt.Errorf("invalid intrabar_tie_break: %w", err)
+	}
+	cfg.IntrabarTieBreak = string(tieBreak)
+
 	if cfg.CheckpointIntervalBars <= 0 {
 		cfg.CheckpointIntervalBars = 20
 	}
@@ -182,9 +283,92 @@ func validateFillPolicy(policy string) error {
 	}
 }
 
+// FillModelConfig returns the fill simulation settings shared with paper trading.
+func (cfg *BacktestConfig) FillModelConfig() fillmodel.Config {
+	fees := fillmodel.FlatFees(cfg.FeeBps / 10000.0)
+	if cfg.FeeExchange != "" {
+		fees = fillmodel.FeesFor(cfg.FeeExchange)
+	}
+	return fillmodel.Config{
+		Fees:             fees,
+		SlippageRate:     cfg.SlippageBps / 10000.0,
+		TieBreak:         fillmodel.TieBreak(cfg.IntrabarTieBreak),
+		MaxParticipation: cfg.MaxParticipation,
+	}
+}
+
 // SetLoadedStrategy sets the loaded strategy config from database.
+// Grid strategies also fill GridConfig so the run simulates the grid instead of asking the AI,
+// rule strategies fill RuleConfig so the run evaluates the rules.
 func (cfg *BacktestConfig) SetLoadedStrategy(strategy *store.StrategyConfig) {
 	cfg.loadedStrategy = strategy
+	if strategy != nil && strategy.StrategyType == "grid_trading" && strategy.GridConfig != nil && cfg.GridConfig == nil {
+		grid := *strategy.GridConfig
+		cfg.GridConfig = &grid
+	}
+	if strategy.IsRuleStrategy() && cfg.RuleConfig == nil {
+		rules := *strategy.RuleConfig
+		cfg.RuleConfig = &rules
+	}
+}
+
+// IsGrid reports whether the run simulates a grid_trading strategy.
+func (cfg *BacktestConfig) IsGrid() bool {
+	return cfg != nil && cfg.GridConfig != nil
+}
+
+// IsRules reports whether the run's decisions come from strategy rules instead of the AI.
+func (cfg *BacktestConfig) IsRules() bool {
+	return cfg != nil && cfg.RuleConfig != nil && !cfg.IsGrid()
+}
+
+// applyRules makes a strategy config evaluate the run's rules on the decision timeframe.
+func (cfg *BacktestConfig) applyRules(strategy *store.StrategyConfig) {
+	if !cfg.IsRules() {
+		return
+	}
+	strategy.StrategyType = store.StrategyTypeRules
+	strategy.RuleConfig = cfg.RuleConfig
+	if cfg.DecisionTimeframe != "" {
+		strategy.Indicators.Klines.PrimaryTimeframe = cfg.DecisionTimeframe
+	}
+}
+
+// Kline intervals used by grid runs for box (Donchian) breakout detection and ATR bounds
+const (
+	gridBoxTimeframe = "1h"
+	gridATRTimeframe = "4h"
+)
+
+// validateGrid checks the grid settings and pins the run to the grid symbol.
+func (cfg *BacktestConfig) validateGrid() error {
+	grid := cfg.GridConfig
+	symbol := strings.TrimSpace(grid.Symbol)
+	if symbol == "" && len(cfg.Symbols) == 1 {
+		symbol = cfg.Symbols[0]
+	}
+	if symbol == "" {
+		return fmt.Errorf("grid_config.symbol is required")
+	}
+	grid.Symbol = market.Normalize(symbol)
+	cfg.Symbols = []string{grid.Symbol}
+
+	if grid.GridCount < 2 {
+		return fmt.Errorf("grid_config.grid_count must be at least 2")
+	}
+	if grid.TotalInvestment <= 0 {
+		return fmt.Errorf("grid_config.total_investment must be positive")
+	}
+	if grid.Leverage <= 0 {
+		grid.Leverage = 1
+	}
+	if !grid.UseATRBounds && grid.UpperPrice > 0 && grid.LowerPrice > 0 && grid.UpperPrice <= grid.LowerPrice {
+		return fmt.Errorf("grid_config.upper_price must be above lower_price")
+	}
+	if grid.MaxDrawdownPct < 0 || grid.StopLossPct < 0 || grid.DailyLossLimitPct < 0 {
+		return fmt.Errorf("grid_config risk limits cannot be negative")
+	}
+	return nil
 }
 
 // ToStrategyConfig converts BacktestConfig to StrategyConfig for unified prompt generation.
@@ -213,6 +397,10 @@ func (cfg *BacktestConfig) ToStrategyConfig() *store.StrategyConfig {
 			result.Indicators.Klines.EnableMultiTimeframe = len(cfg.Timeframes) > 1
 		}
 
+		if cfg.RiskControl != nil {
+			result.RiskControl = *cfg.RiskControl
+		}
+
 		// Override leverage with backtest config
 		if cfg.Leverage.BTCETHLeverage > 0 {
 			result.RiskControl.BTCETHMaxLeverage = cfg.Leverage.BTCETHLeverage
@@ -225,6 +413,10 @@ func (cfg *BacktestConfig) ToStrategyConfig() *store.StrategyConfig {
 		if cfg.CustomPrompt != "" {
 			result.CustomPrompt = cfg.CustomPrompt
 		}
+		if cfg.PromptSections != nil {
+			result.PromptSections = *cfg.PromptSections
+		}
+		cfg.applyRules(&result)
 
 		return &result
 	}
@@ -239,7 +431,7 @@ func (cfg *BacktestConfig) ToStrategyConfig() *store.StrategyConfig {
 		longerTF = cfg.Timeframes[len(cfg.Timeframes)-1]
 	}
 
-	return &store.StrategyConfig{
+	fallback := &store.StrategyConfig{
 		CoinSource: store.CoinSourceConfig{
 			SourceType: "static",
 			StaticCoins: cfg.Symbols,
@@ -282,4 +474,14 @@ func (cfg *BacktestConfig) ToStrategyConfig() *store.StrategyConfig {
 			MinConfidence:                75,
 		},
 	}
+	if cfg.RiskControl != nil {
+		fallback.RiskControl = *cfg.RiskControl
+		fallback.RiskControl.BTCETHMaxLeverage = cfg.Leverage.BTCETHLeverage
+		fallback.RiskControl.AltcoinMaxLeverage = cfg.Leverage.AltcoinLeverage
+	}
+	if cfg.PromptSections != nil {
+		fallback.PromptSections = *cfg.PromptSections
+	}
+	cfg.applyRules(fallback)
+	return fallback
 }
@@ -26,6 +26,9 @@ type DataFeed struct {
 	decisionTimes []int64
 	primaryTF     string
 	longerTF      string
+
+	// replay holds the kline snapshot of a replay bundle; nil means fetch klines
+	replay *replayKlines
 }
 
 func NewDataFeed(cfg BacktestConfig) (*DataFeed, error) {
@@ -38,6 +41,14 @@ func NewDataFeed(cfg BacktestConfig) (*DataFeed, error) {
 	}
 	copy(df.symbols, cfg.Symbols)
 
+	if cfg.ReplayKlinesPath != "" {
+		snapshot, err := loadReplayKlines(cfg.ReplayKlinesPath)
+		if err != nil {
+			return nil, fmt.Errorf("load replay klines: %w", err)
+		}
+		df.replay = snapshot
+	}
+
 	if err := df.loadAll(); err != nil {
 		return nil, err
 	}
@@ -73,7 +84,15 @@ func (df *DataFeed) loadAll() error {
 			}
 			fetchEnd := end.Add(dur)
 
-			klines, err := market.GetKlinesRange(symbol, tf, fetchStart, fetchEnd)
+			var (
+				klines []market.Kline
+				err    error
+			)
+			if df.replay != nil {
+				klines = df.replay.Series[symbol][tf]
+			} else {
+				klines, err = market.GetKlinesRange(symbol, tf, fetchStart, fetchEnd)
+			}
 			if err != nil {
 				return fmt.Errorf("fetch klines for %s %s: %w", symbol, tf, err)
 			}
@@ -150,6 +169,19 @@ func (df *DataFeed) sliceUpTo(symbol, tf string, ts int64) []market.Kline {
 	return series.klines[:idx]
 }
 
+// klines returns every loaded kline of symbol on tf, or nil when it is not part of the feed.
+func (df *DataFeed) klines(symbol, tf string) []market.Kline {
+	ss, ok := df.symbolSeries[symbol]
+	if !ok || ss == nil {
+		return nil
+	}
+	series, ok := ss.byTF[tf]
+	if !ok || series == nil {
+		return nil
+	}
+	return series.klines
+}
+
 func (df *DataFeed) BuildMarketData(ts int64) (map[string]*market.Data, map[string]map[string]*market.Data, error) {
 	result := make(map[string]*market.Data, len(df.symbols))
 	multi := make(map[string]map[string]*market.Data, len(df.symbols))
@@ -0,0 +1,641 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"nofx/kernel"
+	"nofx/market"
+	"nofx/store"
+	"nofx/trader/fillmodel"
+)
+
+// Grid pause reasons
+const (
+	gridPauseRangeBreakout = "range_breakout"
+	gridPauseBoxBreakout   = "box_breakout"
+	gridPauseDailyLoss     = "daily_loss"
+)
+
+// gridRangeBreakoutPct pauses the grid when price is this far (%) beyond the grid bounds, as in live trading
+const gridRangeBreakoutPct = 2.0
+
+// gridSim is the state of a simulated grid_trading strategy.
+//
+// The grid runs mechanically instead of asking the AI: every level rests a limit order
+// (buy below price, sell above), and each completely filled order places the opposite
+// order one level away. Positions are netted like a one-way exchange account, so a grid
+// sell first reduces a long before opening a short. Breakout handling and the
+// GridStrategyConfig risk limits follow trader/auto_trader_grid.go.
+type gridSim struct {
+	cfg         *store.GridStrategyConfig
+	book        *fillmodel.Book
+	levels      []kernel.GridLevelInfo
+	orderLevels map[string]int // order ID -> level index
+
+	upper   float64
+	lower   float64
+	spacing float64
+	armed   bool // resting orders are placed for the current layout
+
+	pauseReason string // non-empty while paused
+	halted      bool   // max drawdown exceeded: positions closed, no more trading
+
+	direction    market.GridDirection
+	breakout     kernel.BreakoutState
+	reductionPct float64
+
+	peakEquity float64
+	dailyPnL   float64
+	day        int64
+}
+
+// GridCheckpoint is the gridSim state saved with a checkpoint, so a resumed grid keeps
+// its levels, resting orders and risk limits instead of laying out a new grid.
+type GridCheckpoint struct {
+	Levels       []kernel.GridLevelInfo `json:"levels"`
+	Book         fillmodel.BookState    `json:"book"`
+	Upper        float64                `json:"upper"`
+	Lower        float64                `json:"lower"`
+	Spacing      float64                `json:"spacing"`
+	Armed        bool                   `json:"armed"`
+	PauseReason  string                 `json:"pause_reason,omitempty"`
+	Halted       bool                   `json:"halted"`
+	Direction    market.GridDirection   `json:"direction"`
+	Breakout     kernel.BreakoutState   `json:"breakout"`
+	ReductionPct float64                `json:"reduction_pct"`
+	PeakEquity   float64                `json:"peak_equity"`
+	DailyPnL     float64                `json:"daily_pnl"`
+	Day          int64                  `json:"day"`
+}
+
+func newGridSim(cfg *store.GridStrategyConfig, model *fillmodel.Model) *gridSim {
+	return &gridSim{
+		cfg:         cfg,
+		book:        fillmodel.NewBook(model),
+		orderLevels: make(map[string]int),
+		direction:   market.GridDirectionNeutral,
+		breakout:    kernel.BreakoutState{Level: market.BreakoutNone},
+	}
+}
+
+// stepGrid advances the grid by the bar closing at ts. prior holds trade events already
+// produced for this bar (protective stops), which count towards the daily loss.
+// Orders placed at the close of a bar can only fill from the next bar on.
+func (r *Runner) stepGrid(ts int64, priceMap map[string]float64, prior []TradeEvent, cycle int) ([]TradeEvent, []string, error) {
+	g := r.grid
+	symbol := g.cfg.Symbol
+	bar, _ := r.feed.decisionBarSnapshot(symbol, ts)
+	if bar == nil {
+		return nil, nil, nil
+	}
+	price := bar.Close
+
+	events := make([]TradeEvent, 0)
+	notes := make([]string, 0)
+
+	g.rollDay(ts)
+	for _, evt := range prior {
+		if evt.Symbol != symbol {
+			continue
+		}
+		g.dailyPnL += evt.RealizedPnL
+		if evt.CloseReason == "stop_loss" && !g.halted {
+			// Re-center the grid after a stop-out instead of re-buying the same levels
+			g.cancelAll()
+			g.levels = nil
+			notes = append(notes, fmt.Sprintf("grid stop loss hit at %.4f, rebuilding grid", evt.Price))
+		}
+	}
+
+	if g.armed && g.pauseReason == "" && !g.halted {
+		fills := g.book.Match(symbol, fillmodel.Bar{
+			Time:   ts,
+			Open:   bar.Open,
+			High:   bar.High,
+			Low:    bar.Low,
+			Close:  bar.Close,
+			Volume: bar.Volume,
+		})
+		for _, fill := range fills {
+			fillEvents, err := r.applyGridFill(fill, cycle)
+			events = append(events, fillEvents...)
+			for _, evt := range fillEvents {
+				g.dailyPnL += evt.RealizedPnL
+			}
+			if err != nil {
+				notes = append(notes, fmt.Sprintf("grid %s fill at %.4f skipped: %v", fill.Side, fill.Price, err))
+				g.releaseOrder(fill.OrderID)
+				continue
+			}
+			if !fill.Partial {
+				g.placeCounterOrder(fill, price)
+			}
+		}
+	}
+
+	if g.halted {
+		return events, notes, nil
+	}
+	if g.levels == nil {
+		r.layoutGrid(ts, price)
+		notes = append(notes, fmt.Sprintf("grid initialized: %d levels, %.4f - %.4f, spacing %.4f",
+			len(g.levels), g.lower, g.upper, g.spacing))
+	}
+
+	// Price left the grid range
+	if pct := g.rangeBreakoutPct(price); pct >= gridRangeBreakoutPct && g.pauseReason == "" {
+		g.pause(gridPauseRangeBreakout)
+		notes = append(notes, fmt.Sprintf("grid paused: price %.4f is %.2f%% outside the grid range", price, pct))
+	}
+
+	// Max drawdown: close everything and stop trading
+	if g.cfg.MaxDrawdownPct > 0 {
+		equity, _, _ := r.account.TotalEquity(priceMap)
+		if equity > g.peakEquity {
+			g.peakEquity = equity
+		}
+		if g.peakEquity > 0 {
+			drawdown := (g.peakEquity - equity) / g.peakEquity * 100
+			if drawdown >= g.cfg.MaxDrawdownPct {
+				g.cancelAll()
+				g.halted = true
+				closeEvents, err := r.closeGridPositions(ts, price, cycle, "max_drawdown")
+				if err != nil {
+					return events, notes, err
+				}
+				events = append(events, closeEvents...)
+				notes = append(notes, fmt.Sprintf("grid stopped: drawdown %.2f%% exceeded max %.2f%%", drawdown, g.cfg.MaxDrawdownPct))
+				return events, notes, nil
+			}
+		}
+	}
+
+	// Daily loss limit: pause until the next UTC day
+	if g.cfg.DailyLossLimitPct > 0 && g.dailyPnL < 0 && g.pauseReason != gridPauseDailyLoss {
+		lossPct := -g.dailyPnL / g.cfg.TotalInvestment * 100
+		if lossPct >= g.cfg.DailyLossLimitPct {
+			g.pause(gridPauseDailyLoss)
+			notes = append(notes, fmt.Sprintf("grid paused: daily loss %.2f%% exceeded limit %.2f%%", lossPct, g.cfg.DailyLossLimitPct))
+		}
+	}
+
+	boxEvents, boxNotes, err := r.checkGridBox(ts, price, cycle)
+	if err != nil {
+		return events, notes, err
+	}
+	events = append(events, boxEvents...)
+	notes = append(notes, boxNotes...)
+
+	if g.pauseReason == "" && !g.armed {
+		placed := g.arm(symbol, price)
+		notes = append(notes, fmt.Sprintf("grid armed: %d orders around %.4f (direction %s)", placed, price, g.direction))
+	}
+	return events, notes, nil
+}
+
+// layoutGrid computes bounds and levels around price, like AutoTrader.InitializeGrid.
+func (r *Runner) layoutGrid(ts int64, price float64) {
+	g := r.grid
+	cfg := g.cfg
+	switch {
+	case cfg.UseATRBounds:
+		atr := 0.0
+		// Live trading derives ATR bounds from 4h klines
+		if klines := r.feed.sliceUpTo(cfg.Symbol, gridATRTimeframe, ts); len(klines) > 0 {
+			if data, err := market.BuildDataFromKlines(cfg.Symbol, klines, klines); err == nil && data.LongerTermContext != nil {
+				atr = data.LongerTermContext.ATR14
+			}
+		}
+		g.upper, g.lower = kernel.ATRGridBounds(price, atr, cfg)
+	case cfg.UpperPrice > 0 && cfg.LowerPrice > 0:
+		g.upper, g.lower = cfg.UpperPrice, cfg.LowerPrice
+	default:
+		g.upper, g.lower = kernel.DefaultGridBounds(price, cfg.GridCount)
+	}
+
+	g.spacing = (g.upper - g.lower) / float64(cfg.GridCount-1)
+	g.levels = kernel.BuildGridLevels(g.lower, g.spacing, price, cfg)
+	if cfg.EnableDirectionAdjust {
+		kernel.ApplyGridDirection(g.levels, g.direction, cfg.DirectionBiasRatio, price)
+	}
+	g.armed = false
+}
+
+// checkGridBox runs box breakout detection and false-breakout recovery on completed 1h candles.
+func (r *Runner) checkGridBox(ts int64, price float64, cycle int) ([]TradeEvent, []string, error) {
+	g := r.grid
+	klines := r.feed.sliceUpTo(g.cfg.Symbol, gridBoxTimeframe, ts)
+	if len(klines) < 2 {
+		return nil, nil, nil
+	}
+	// Boxes come from the candles before the latest one so the current move can break out of them
+	box := market.ExportCalculateBoxData(klines[:len(klines)-1], price)
+
+	var (
+		events []TradeEvent
+		notes  []string
+	)
+
+	level, direction := kernel.DetectBoxBreakout(box)
+	if kernel.ConfirmBreakoutAt(&g.breakout, level, direction, time.UnixMilli(ts)) {
+		action := kernel.GetBreakoutActionWithDirection(level, g.cfg.EnableDirectionAdjust)
+		switch action {
+		case kernel.BreakoutActionAdjustDirection:
+			if next := kernel.DetermineGridDirection(box, g.direction, level, direction); next != g.direction {
+				notes = append(notes, fmt.Sprintf("grid direction %s → %s (%s box breakout %s)", g.direction, next, level, direction))
+				g.setDirection(next, price)
+			}
+		case kernel.BreakoutActionReducePosition:
+			if g.reductionPct != 50 {
+				g.reductionPct = 50
+				notes = append(notes, fmt.Sprintf("short box breakout %s, grid order size reduced to 50%%", direction))
+			}
+		case kernel.BreakoutActionPauseGrid:
+			if g.pauseReason == "" {
+				g.pause(gridPauseBoxBreakout)
+				notes = append(notes, fmt.Sprintf("mid box breakout %s, grid paused", direction))
+			}
+		case kernel.BreakoutActionCloseAll:
+			g.pause(gridPauseBoxBreakout)
+			closeEvents, err := r.closeGridPositions(ts, price, cycle, "breakout")
+			if err != nil {
+				return events, notes, err
+			}
+			if len(closeEvents) > 0 {
+				events = append(events, closeEvents...)
+				notes = append(notes, fmt.Sprintf("long box breakout %s, grid paused and positions closed", direction))
+			}
+		}
+	}
+
+	// False breakout recovery: once price is back inside the boxes the grid resumes at 50% size
+	recovering := g.breakout.Level != market.BreakoutNone || g.reductionPct != 0 ||
+		g.pauseReason == gridPauseBoxBreakout || g.pauseReason == gridPauseRangeBreakout
+	if recovering && level == market.BreakoutNone && price >= box.LongLower && price <= box.LongUpper {
+		if g.pauseReason == gridPauseBoxBreakout || g.pauseReason == gridPauseRangeBreakout {
+			if g.rangeBreakoutPct(price) < gridRangeBreakoutPct {
+				notes = append(notes, "price returned to box, grid resumed at 50% size")
+				g.pauseReason = ""
+			}
+		}
+		g.breakout = kernel.BreakoutState{Level: market.BreakoutNone}
+		g.reductionPct = 50
+	}
+	if g.cfg.EnableDirectionAdjust && kernel.ShouldRecoverDirection(box, g.direction) {
+		if next := kernel.DetermineRecoveryDirection(price, box, g.direction); next != g.direction {
+			notes = append(notes, fmt.Sprintf("grid direction recovery %s → %s", g.direction, next))
+			g.setDirection(next, price)
+		}
+	}
+	return events, notes, nil
+}
+
+// applyGridFill books a grid fill against the net position: the opposite side is reduced first,
+// any remainder opens or adds to this side.
+func (r *Runner) applyGridFill(fill fillmodel.Fill, cycle int) ([]TradeEvent, error) {
+	symbol := fill.Symbol
+	closeSide, openSide := "short", "long"
+	if fill.Side == fillmodel.SideSell {
+		closeSide, openSide = "long", "short"
+	}
+	note := fmt.Sprintf("grid %s limit %.4f", fill.Side, fill.Price)
+
+	events := make([]TradeEvent, 0, 2)
+	remaining := fill.Quantity
+	if held := r.remainingPosition(symbol, closeSide); held > epsilon {
+		part := splitFill(fill, math.Min(held, remaining))
+		leverage := r.account.positionLeverage(symbol, closeSide)
+		realized, fee, execPrice, err := r.account.CloseFill(symbol, closeSide, part)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, TradeEvent{
+			Timestamp:     fill.Time,
+			Symbol:        symbol,
+			Action:        "close_" + closeSide,
+			Side:          closeSide,
+			Quantity:      part.Quantity,
+			Price:         execPrice,
+			Fee:           fee,
+			OrderValue:    execPrice * part.Quantity,
+			RealizedPnL:   realized - fee,
+			Leverage:      leverage,
+			Cycle:         cycle,
+			PositionAfter: r.remainingPosition(symbol, closeSide),
+			CloseReason:   "grid",
+			Note:          note,
+		})
+		remaining -= part.Quantity
+	}
+
+	if remaining > epsilon {
+		part := splitFill(fill, remaining)
+		pos, fee, execPrice, err := r.account.OpenFill(symbol, openSide, r.grid.cfg.Leverage, part)
+		if err != nil {
+			return events, err
+		}
+		if pct := r.grid.cfg.StopLossPct; pct > 0 {
+			stop := pos.EntryPrice * (1 - pct/100)
+			if openSide == "short" {
+				stop = pos.EntryPrice * (1 + pct/100)
+			}
+			r.account.SetProtection(symbol, openSide, stop, 0)
+		}
+		events = append(events, TradeEvent{
+			Timestamp:     fill.Time,
+			Symbol:        symbol,
+			Action:        "open_" + openSide,
+			Side:          openSide,
+			Quantity:      part.Quantity,
+			Price:         execPrice,
+			Fee:           fee,
+			OrderValue:    execPrice * part.Quantity,
+			Leverage:      pos.Leverage,
+			Cycle:         cycle,
+			PositionAfter: pos.Quantity,
+			Note:          note,
+		})
+	}
+	return events, nil
+}
+
+// closeGridPositions market-closes both sides of the grid symbol at price.
+func (r *Runner) closeGridPositions(ts int64, price float64, cycle int, reason string) ([]TradeEvent, error) {
+	symbol := r.grid.cfg.Symbol
+	model := r.account.FillModel()
+	events := make([]TradeEvent, 0, 2)
+	for _, side := range []string{"long", "short"} {
+		qty := r.remainingPosition(symbol, side)
+		if qty <= epsilon {
+			continue
+		}
+		leverage := r.account.positionLeverage(symbol, side)
+		fill := model.FillMarket(symbol, orderSide(side, false), qty, price, 0, ts)
+		realized, fee, execPrice, err := r.account.CloseFill(symbol, side, fill)
+		if err != nil {
+			return events, err
+		}
+		slippage := price - execPrice
+		if side == "short" {
+			slippage = execPrice - price
+		}
+		events = append(events, TradeEvent{
+			Timestamp:     ts,
+			Symbol:        symbol,
+			Action:        "close_" + side,
+			Side:          side,
+			Quantity:      qty,
+			Price:         execPrice,
+			Fee:           fee,
+			Slippage:      slippage,
+			OrderValue:    execPrice * qty,
+			RealizedPnL:   realized - fee,
+			Leverage:      leverage,
+			Cycle:         cycle,
+			PositionAfter: r.remainingPosition(symbol, side),
+			CloseReason:   reason,
+		})
+		r.grid.dailyPnL += realized - fee
+	}
+	return events, nil
+}
+
+// splitFill returns the part of fill covering quantity, with the fee scaled accordingly.
+func splitFill(fill fillmodel.Fill, quantity float64) fillmodel.Fill {
+	if quantity >= fill.Quantity {
+		return fill
+	}
+	part := fill
+	part.Fee = fill.Fee * quantity / fill.Quantity
+	part.Quantity = quantity
+	return part
+}
+
+// arm places a resting limit order on every level that is on the right side of price.
+// Returns the number of orders placed.
+func (g *gridSim) arm(symbol string, price float64) int {
+	placed := 0
+	for i := range g.levels {
+		level := &g.levels[i]
+		side := ""
+		switch {
+		case level.Side == "buy" && level.Price < price:
+			side = fillmodel.SideBuy
+		case level.Side == "sell" && level.Price > price:
+			side = fillmodel.SideSell
+		}
+		if side == "" || level.OrderID != "" {
+			continue
+		}
+		if g.submit(i, symbol, side, g.levelQuantity(level), price) {
+			placed++
+		}
+	}
+	g.armed = true
+	return placed
+}
+
+// placeCounterOrder marks the filled level and rests the opposite order one level away
+// (a sell above a filled buy, a buy below a filled sell).
+func (g *gridSim) placeCounterOrder(fill fillmodel.Fill, lastPrice float64) {
+	idx, ok := g.orderLevels[fill.OrderID]
+	if !ok {
+		return
+	}
+	delete(g.orderLevels, fill.OrderID)
+	level := &g.levels[idx]
+	qty := level.OrderQuantity
+	level.State = "filled"
+	level.OrderID = ""
+	level.OrderQuantity = 0
+	level.PositionEntry = fill.Price
+	level.PositionSize = qty
+
+	next, side := idx+1, fillmodel.SideSell
+	if fill.Side == fillmodel.SideSell {
+		next, side = idx-1, fillmodel.SideBuy
+	}
+	if next < 0 || next >= len(g.levels) || g.levels[next].OrderID != "" {
+		return
+	}
+	g.submit(next, fill.Symbol, side, qty, lastPrice)
+}
+
+// submit rests a limit order at level idx and records it on the level.
+func (g *gridSim) submit(idx int, symbol, side string, qty, lastPrice float64) bool {
+	if qty <= 0 {
+		return false
+	}
+	level := &g.levels[idx]
+	order := &fillmodel.Order{
+		Symbol:   symbol,
+		Side:     side,
+		Type:     fillmodel.TypeLimit,
+		Price:    level.Price,
+		Quantity: qty,
+		PostOnly: g.cfg.UseMakerOnly,
+	}
+	if err := g.book.Submit(order, lastPrice); err != nil {
+		return false
+	}
+	g.orderLevels[order.ID] = idx
+	level.State = "pending"
+	level.OrderID = order.ID
+	level.OrderQuantity = qty
+	return true
+}
+
+// releaseOrder forgets an order that filled but could not be booked, freeing its level.
+func (g *gridSim) releaseOrder(orderID string) {
+	idx, ok := g.orderLevels[orderID]
+	if !ok {
+		return
+	}
+	g.book.Cancel(orderID)
+	delete(g.orderLevels, orderID)
+	g.levels[idx].State = "empty"
+	g.levels[idx].OrderID = ""
+	g.levels[idx].OrderQuantity = 0
+}
+
+// levelQuantity sizes a level order from its allocation, leverage and any breakout reduction.
+func (g *gridSim) levelQuantity(level *kernel.GridLevelInfo) float64 {
+	if level.Price <= 0 {
+		return 0
+	}
+	qty := level.AllocatedUSD * float64(g.cfg.Leverage) / level.Price
+	return qty * (1 - g.reductionPct/100)
+}
+
+// cancelAll cancels every resting order; the grid re-arms at the next bar close.
+func (g *gridSim) cancelAll() {
+	g.book.CancelWhere(func(*fillmodel.Order) bool { return true })
+	g.orderLevels = make(map[string]int)
+	for i := range g.levels {
+		if g.levels[i].State == "pending" {
+			g.levels[i].State = "empty"
+		}
+		g.levels[i].OrderID = ""
+		g.levels[i].OrderQuantity = 0
+	}
+	g.armed = false
+}
+
+func (g *gridSim) pause(reason string) {
+	g.cancelAll()
+	g.pauseReason = reason
+}
+
+// setDirection reassigns level sides and re-arms the grid.
+func (g *gridSim) setDirection(direction market.GridDirection, price float64) {
+	g.direction = direction
+	g.cancelAll()
+	kernel.ApplyGridDirection(g.levels, direction, g.cfg.DirectionBiasRatio, price)
+}
+
+// rangeBreakoutPct returns how far (%) price is outside the grid bounds, 0 when inside.
+func (g *gridSim) rangeBreakoutPct(price float64) float64 {
+	if g.upper <= 0 || g.lower <= 0 {
+		return 0
+	}
+	if price > g.upper {
+		return (price - g.upper) / g.upper * 100
+	}
+	if price < g.lower {
+		return (g.lower - price) / g.lower * 100
+	}
+	return 0
+}
+
+// rollDay resets the daily PnL (and a daily-loss pause) on a new UTC day.
+func (g *gridSim) rollDay(ts int64) {
+	day := ts / int64(24*time.Hour/time.Millisecond)
+	if day == g.day {
+		return
+	}
+	g.day = day
+	g.dailyPnL = 0
+	if g.pauseReason == gridPauseDailyLoss {
+		g.pauseReason = ""
+	}
+}
+
+// checkpoint copies the grid state for a checkpoint.
+func (g *gridSim) checkpoint() *GridCheckpoint {
+	return &GridCheckpoint{
+		Levels:       append([]kernel.GridLevelInfo(nil), g.levels...),
+		Book:         g.book.State(),
+		Upper:        g.upper,
+		Lower:        g.lower,
+		Spacing:      g.spacing,
+		Armed:        g.armed,
+		PauseReason:  g.pauseReason,
+		Halted:       g.halted,
+		Direction:    g.direction,
+		Breakout:     g.breakout,
+		ReductionPct: g.reductionPct,
+		PeakEquity:   g.peakEquity,
+		DailyPnL:     g.dailyPnL,
+		Day:          g.day,
+	}
+}
+
+// restore resumes the grid from a checkpoint; level order IDs rebuild the order index.
+func (g *gridSim) restore(ckpt *GridCheckpoint) {
+	g.levels = append([]kernel.GridLevelInfo(nil), ckpt.Levels...)
+	g.book.Restore(ckpt.Book)
+	g.orderLevels = make(map[string]int)
+	for i, level := range g.levels {
+		if level.OrderID != "" {
+			g.orderLevels[level.OrderID] = i
+		}
+	}
+	g.upper, g.lower, g.spacing = ckpt.Upper, ckpt.Lower, ckpt.Spacing
+	g.armed = ckpt.Armed
+	g.pauseReason = ckpt.PauseReason
+	g.halted = ckpt.Halted
+	g.direction = ckpt.Direction
+	g.breakout = ckpt.Breakout
+	g.reductionPct = ckpt.ReductionPct
+	g.peakEquity = ckpt.PeakEquity
+	g.dailyPnL = ckpt.DailyPnL
+	g.day = ckpt.Day
+}
+
+// gridDecisionRecord builds the decision-trail record for a bar with grid activity.
+func (r *Runner) gridDecisionRecord(ts int64, priceMap map[string]float64, cycle int) *store.DecisionRecord {
+	equity, unrealized, _ := r.account.TotalEquity(priceMap)
+	marginPct := 0.0
+	if equity > 0 {
+		marginPct = r.totalMarginUsed() / equity * 100
+	}
+	return &store.DecisionRecord{
+		CycleNumber: cycle,
+		Timestamp:   time.UnixMilli(ts).UTC(),
+		AccountState: store.AccountSnapshot{
+			TotalBalance:          equity,
+			AvailableBalance:      r.account.Cash(),
+			TotalUnrealizedProfit: unrealized,
+			PositionCount:         len(r.account.Positions()),
+			MarginUsedPct:         marginPct,
+		},
+		CandidateCoins: []string{r.grid.cfg.Symbol},
+		Positions:      r.snapshotPositions(priceMap),
+	}
+}
+
+// tradeEventAction converts a simulated execution to a decision action for the decision trail.
+func tradeEventAction(evt TradeEvent) store.DecisionAction {
+	return store.DecisionAction{
+		Action:    evt.Action,
+		Symbol:    evt.Symbol,
+		Quantity:  evt.Quantity,
+		Leverage:  evt.Leverage,
+		Price:     evt.Price,
+		Timestamp: time.UnixMilli(evt.Timestamp).UTC(),
+		Success:   true,
+		Reasoning: evt.Note,
+	}
+}
@@ -0,0 +1,128 @@
+package backtest
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/store"
+	"nofx/trader/fillmodel"
+)
+
+const gridTestSymbol = "SOLUSDT"
+
+// gridTestFeed 15m bars oscillating around 100, through the levels of the test grid
+func gridTestFeed(bars int) *DataFeed {
+	step := int64(15 * time.Minute / time.Millisecond)
+	series := &timeframeSeries{}
+	for i := 0; i < bars; i++ {
+		mid := 100 + 6*math.Sin(float64(i)/5)
+		openTime := int64(i) * step
+		series.klines = append(series.klines, market.Kline{
+			OpenTime:  openTime,
+			Open:      mid - 0.5,
+			High:      mid + 1.5,
+			Low:       mid - 1.5,
+			Close:     mid + 0.5,
+			Volume:    50,
+			CloseTime: openTime + step - 1,
+		})
+		series.closeTimes = append(series.closeTimes, openTime+step-1)
+	}
+	return &DataFeed{
+		symbols:       []string{gridTestSymbol},
+		primaryTF:     "15m",
+		decisionTimes: series.closeTimes,
+		symbolSeries: map[string]*symbolSeries{
+			gridTestSymbol: {byTF: map[string]*timeframeSeries{"15m": series}},
+		},
+	}
+}
+
+func newGridTestRunner(feed *DataFeed) *Runner {
+	// Participation cap below the level size so orders rest partially filled across bars
+	account := NewBacktestAccountWithModel(10000, fillmodel.New(fillmodel.Config{
+		Fees:             fillmodel.FlatFees(0.0004),
+		MaxParticipation: 0.02,
+	}))
+	cfg := &store.GridStrategyConfig{
+		Symbol:            gridTestSymbol,
+		GridCount:         11,
+		TotalInvestment:   1000,
+		Leverage:          2,
+		UpperPrice:        110,
+		LowerPrice:        90,
+		MaxDrawdownPct:    50,
+		DailyLossLimitPct: 50,
+	}
+	return &Runner{
+		feed:    feed,
+		account: account,
+		grid:    newGridSim(cfg, account.FillModel()),
+		state:   &BacktestState{Positions: make(map[string]PositionSnapshot)},
+	}
+}
+
+// runGridBars steps the grid over bars [from, to) like the run loop and returns the trade events
+func runGridBars(t *testing.T, r *Runner, from, to int) []TradeEvent {
+	t.Helper()
+	var events []TradeEvent
+	for i := from; i < to; i++ {
+		ts := r.feed.DecisionTimestamp(i)
+		bar, _ := r.feed.decisionBarSnapshot(gridTestSymbol, ts)
+		priceMap := map[string]float64{gridTestSymbol: bar.Close}
+		barEvents, _, err := r.stepGrid(ts, priceMap, nil, i)
+		if err != nil {
+			t.Fatalf("bar %d: %v", i, err)
+		}
+		events = append(events, barEvents...)
+		equity, unrealized, _ := r.account.TotalEquity(priceMap)
+		r.updateState(ts, equity, unrealized, r.totalMarginUsed(), priceMap, false)
+	}
+	return events
+}
+
+func TestGridResumeFromCheckpoint(t *testing.T) {
+	const bars, pauseAt = 120, 47
+	feed := gridTestFeed(bars)
+
+	straight := newGridTestRunner(feed)
+	runGridBars(t, straight, 0, pauseAt)
+	want := runGridBars(t, straight, pauseAt, bars)
+
+	first := newGridTestRunner(feed)
+	runGridBars(t, first, 0, pauseAt)
+	if len(first.grid.book.Orders("")) == 0 {
+		t.Fatal("test grid has no resting orders at the checkpoint")
+	}
+	data, err := json.Marshal(first.buildCheckpointFromState(first.snapshotState()))
+	if err != nil {
+		t.Fatalf("marshal checkpoint: %v", err)
+	}
+	var ckpt Checkpoint
+	if err := json.Unmarshal(data, &ckpt); err != nil {
+		t.Fatalf("unmarshal checkpoint: %v", err)
+	}
+
+	resumed := newGridTestRunner(feed)
+	if err := resumed.applyCheckpoint(&ckpt); err != nil {
+		t.Fatalf("applyCheckpoint: %v", err)
+	}
+	got := runGridBars(t, resumed, pauseAt, bars)
+
+	if len(want) == 0 {
+		t.Fatal("test grid traded nothing after the checkpoint")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("resumed run diverged:\n got %d events %+v\nwant %d events %+v", len(got), got, len(want), want)
+	}
+	if got, want := resumed.account.Cash(), straight.account.Cash(); math.Abs(got-want) > 1e-9 {
+		t.Errorf("cash = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(resumed.grid.levels, straight.grid.levels) {
+		t.Errorf("levels diverged:\n got %+v\nwant %+v", resumed.grid.levels, straight.grid.levels)
+	}
+}
@@ -21,6 +21,7 @@ type Manager struct {
 	cancels    map[string]context.CancelFunc
 	mcpClient  mcp.AIClient
 	aiResolver AIConfigResolver
+	sweeps     map[string]*sweepJob
 }
 
 type AIConfigResolver func(*BacktestConfig) error
@@ -30,6 +31,7 @@ func NewManager(defaultClient mcp.AIClient) *Manager {
 		runners:   make(map[string]*Runner),
 		metadata:  make(map[string]*RunMetadata),
 		cancels:   make(map[string]context.CancelFunc),
+		sweeps:    make(map[string]*sweepJob),
 		mcpClient: defaultClient,
 	}
 }
@@ -44,6 +46,9 @@ func (m *Manager) Start(ctx context.Context, cfg BacktestConfig) (*Runner, error
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	if err := validateID("run_id", cfg.RunID); err != nil {
+		return nil, err
+	}
 	if err := m.resolveAIConfig(&cfg); err != nil {
 		return nil, err
 	}
@@ -78,6 +83,7 @@ func (m *Manager) Start(ctx context.Context, cfg BacktestConfig) (*Runner, error
 	if _, exists := m.runners[cfg.RunID]; exists {
 		m.mu.Unlock()
 		cancel()
+		runner.releaseAICache()
 		return nil, fmt.Errorf("run %s is already active", cfg.RunID)
 	}
 	m.runners[cfg.RunID] = runner
@@ -94,6 +100,7 @@ func (m *Manager) Start(ctx context.Context, cfg BacktestConfig) (*Runner, error
 		delete(m.metadata, cfg.RunID)
 		m.mu.Unlock()
 		runner.releaseLock()
+		runner.releaseAICache()
 		return nil, err
 	}
 
@@ -205,6 +212,10 @@ func (m *Manager) Resume(runID string) error {
 	if err := cfgCopy.Validate(); err != nil {
 		return err
 	}
+	// Keep the strategy resolved at start (saved strategies are not persisted with the config)
+	if strategy, err := loadReplayStrategy(runID); err == nil {
+		cfgCopy.SetLoadedStrategy(strategy)
+	}
 	if err := m.resolveAIConfig(&cfgCopy); err != nil {
 		return err
 	}
@@ -214,6 +225,7 @@ func (m *Manager) Resume(runID string) error {
 		return err
 	}
 	if err := restored.RestoreFromCheckpoint(); err != nil {
+		restored.releaseAICache()
 		return err
 	}
 
@@ -223,6 +235,7 @@ func (m *Manager) Resume(runID string) error {
 	if _, exists := m.runners[runID]; exists {
 		m.mu.Unlock()
 		cancel()
+		restored.releaseAICache()
 		return fmt.Errorf("run %s is already active", runID)
 	}
 	m.runners[runID] = restored
@@ -238,6 +251,7 @@ func (m *Manager) Resume(runID string) error {
 		delete(m.metadata, runID)
 		m.mu.Unlock()
 		restored.releaseLock()
+		restored.releaseAICache()
 		return err
 	}
 
@@ -350,8 +364,38 @@ func (m *Manager) LoadTrades(runID string, limit int) ([]TradeEvent, error) {
 	return LimitTradeEvents(events, limit), nil
 }
 
+// GetMetrics returns the run's metrics. Finished runs persisted by an older version
+// are recomputed from their logs so new fields are filled in.
 func (m *Manager) GetMetrics(runID string) (*Metrics, error) {
-	return LoadMetrics(runID)
+	metrics, err := LoadMetrics(runID)
+	if err == nil && metrics.Version >= metricsVersion {
+		return metrics, nil
+	}
+	if _, active := m.GetRunner(runID); active {
+		return metrics, err
+	}
+	cfg, cfgErr := LoadConfig(runID)
+	if cfgErr != nil {
+		return metrics, err
+	}
+	refreshed, calcErr := CalculateMetrics(runID, cfg, nil, storedBenchmarkKlines(cfg))
+	if calcErr != nil {
+		if err == nil {
+			return metrics, nil
+		}
+		return nil, calcErr
+	}
+	if metrics != nil {
+		// Liquidation is only known from the final state for runs without a liquidation event
+		refreshed.Liquidated = refreshed.Liquidated || metrics.Liquidated
+		if refreshed.Benchmark == nil {
+			refreshed.Benchmark = metrics.Benchmark
+		}
+	}
+	if err := PersistMetrics(runID, refreshed); err != nil {
+		logger.Infof("failed to persist refreshed metrics for %s: %v", runID, err)
+	}
+	return refreshed, nil
 }
 
 func (m *Manager) Cleanup(runID string) {
@@ -427,6 +471,9 @@ func (m *Manager) resolveAIConfig(cfg *BacktestConfig) error {
 	if cfg == nil {
 		return fmt.Errorf("ai config missing")
 	}
+	if cfg.IsGrid() || cfg.IsRules() || cfg.ReplayOnly {
+		return nil // grid, rule and replay-only runs do not call the AI
+	}
 	provider := strings.TrimSpace(cfg.AICfg.Provider)
 	apiKey := strings.TrimSpace(cfg.AICfg.APIKey)
 	if provider != "" && !strings.EqualFold(provider, "inherit") && apiKey != "" {
@@ -450,6 +497,10 @@ func (m *Manager) GetTrace(runID string, cycle int) (*store.DecisionRecord, erro
 }
 
 func (m *Manager) ExportRun(runID string) (string, error) {
+	// Make sure the archived metrics.json carries the current metric set
+	if _, err := m.GetMetrics(runID); err != nil {
+		logger.Infof("export %s without refreshed metrics: %v", runID, err)
+	}
 	return CreateRunExport(runID)
 }
 
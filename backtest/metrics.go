@@ -3,11 +3,23 @@ package backtest
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strings"
+	"time"
+
+	"nofx/logger"
+	"nofx/market"
 )
 
+// metricsVersion is bumped when fields are added so GetMetrics can recompute older runs.
+const metricsVersion = 2
+
+// benchmarkSymbol is bought and held over the run window for comparison.
+const benchmarkSymbol = "BTCUSDT"
+
 // CalculateMetrics reads existing logs and calculates summary metrics. state is optional, used to supplement information not yet persisted.
-func CalculateMetrics(runID string, cfg *BacktestConfig, state *BacktestState) (*Metrics, error) {
+// benchmark holds decision-timeframe klines of benchmarkSymbol; the comparison is skipped when empty.
+func CalculateMetrics(runID string, cfg *BacktestConfig, state *BacktestState, benchmark []market.Kline) (*Metrics, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
@@ -23,7 +35,9 @@ func CalculateMetrics(runID string, cfg *BacktestConfig, state *BacktestState) (
 	}
 
 	metrics := &Metrics{
+		Version:     metricsVersion,
 		SymbolStats: make(map[string]SymbolMetrics),
+		SideStats:   make(map[string]SymbolMetrics),
 	}
 
 	metrics.Liquidated = determineLiquidation(events, state)
@@ -43,8 +57,24 @@ func CalculateMetrics(runID string, cfg *BacktestConfig, state *BacktestState) (
 
 	metrics.MaxDrawdownPct = maxDrawdown(points, state)
 	metrics.SharpeRatio = sharpeRatio(points)
+	metrics.SortinoRatio = sortinoRatio(points)
+	metrics.UlcerIndex = ulcerIndex(points)
+	metrics.LongestDrawdownHours = longestDrawdown(points).Hours()
+	metrics.AnnualizedReturnPct = annualizedReturn(points, initialBalance, lastEquity)
+	if metrics.MaxDrawdownPct > 0 {
+		metrics.CalmarRatio = metrics.AnnualizedReturnPct / metrics.MaxDrawdownPct
+	}
+	metrics.MonthlyReturns = monthlyReturns(points, initialBalance)
 
 	fillTradeMetrics(metrics, events)
+	fillExposureMetrics(metrics, points, events)
+	metrics.FeeDragPct = metrics.TotalFees / initialBalance * 100
+	metrics.SlippageDragPct = metrics.TotalSlippage / initialBalance * 100
+
+	if bench := benchmarkMetrics(points, benchmark); bench != nil {
+		bench.ExcessReturnPct = metrics.TotalReturnPct - bench.ReturnPct
+		metrics.Benchmark = bench
+	}
 
 	return metrics, nil
 }
@@ -162,6 +192,26 @@ func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
 	totalWinAmount := 0.0
 	totalLossAmount := 0.0
 
+	// Close events carry the closed share of the opening fee as well, so opening
+	// fees are tracked per position and only the remainder is counted at close.
+	openFees := make(map[string]float64)
+	for _, evt := range events {
+		key := evt.Symbol + ":" + evt.Side
+		fee := evt.Fee
+		if strings.HasPrefix(evt.Action, "open") {
+			openFees[key] += evt.Fee
+		} else if before := evt.PositionAfter + evt.Quantity; before > 0 {
+			share := openFees[key] * evt.Quantity / before
+			openFees[key] -= share
+			fee -= share
+		}
+		metrics.TotalFees += fee
+		metrics.TotalSlippage += evt.Slippage * evt.Quantity
+		stats := metrics.SymbolStats[evt.Symbol]
+		stats.Fees += fee
+		metrics.SymbolStats[evt.Symbol] = stats
+	}
+
 	for _, evt := range events {
 		include := evt.LiquidationFlag || strings.HasPrefix(evt.Action, "close")
 		if evt.RealizedPnL != 0 {
@@ -175,18 +225,34 @@ func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
 		stats := metrics.SymbolStats[evt.Symbol]
 		stats.TotalTrades++
 		stats.TotalPnL += evt.RealizedPnL
+		sideStats := metrics.SideStats[evt.Side]
+		sideStats.TotalTrades++
+		sideStats.TotalPnL += evt.RealizedPnL
+		switch evt.Side {
+		case "long":
+			stats.LongPnL += evt.RealizedPnL
+			sideStats.LongPnL += evt.RealizedPnL
+		case "short":
+			stats.ShortPnL += evt.RealizedPnL
+			sideStats.ShortPnL += evt.RealizedPnL
+		}
 
 		if evt.RealizedPnL > 0 {
 			winTrades++
 			totalWinAmount += evt.RealizedPnL
 			stats.WinningTrades++
+			sideStats.WinningTrades++
 		} else if evt.RealizedPnL < 0 {
 			lossTrades++
 			totalLossAmount += -evt.RealizedPnL
 			stats.LosingTrades++
+			sideStats.LosingTrades++
 		}
 
 		metrics.SymbolStats[evt.Symbol] = stats
+		if evt.Side != "" {
+			metrics.SideStats[evt.Side] = sideStats
+		}
 	}
 
 	metrics.Trades = totalTrades
@@ -228,6 +294,14 @@ func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
 		metrics.SymbolStats[symbol] = stats
 	}
 
+	for side, stats := range metrics.SideStats {
+		if stats.TotalTrades > 0 {
+			stats.AvgPnL = stats.TotalPnL / float64(stats.TotalTrades)
+			stats.WinRate = (float64(stats.WinningTrades) / float64(stats.TotalTrades)) * 100
+		}
+		metrics.SideStats[side] = stats
+	}
+
 	metrics.BestSymbol = bestSymbol
 	if math.IsInf(bestPnL, -1) {
 		metrics.BestSymbol = ""
@@ -237,3 +311,270 @@ func fillTradeMetrics(metrics *Metrics, events []TradeEvent) {
 		metrics.WorstSymbol = ""
 	}
 }
+
+// periodReturns returns simple returns between consecutive equity points.
+func periodReturns(points []EquityPoint) []float64 {
+	if len(points) < 2 {
+		return nil
+	}
+	returns := make([]float64, 0, len(points)-1)
+	prev := points[0].Equity
+	for i := 1; i < len(points); i++ {
+		curr := points[i].Equity
+		if prev <= 0 {
+			prev = curr
+			continue
+		}
+		returns = append(returns, (curr-prev)/prev)
+		prev = curr
+	}
+	return returns
+}
+
+// sortinoRatio is the Sharpe ratio with only downside deviation in the denominator,
+// annualized the same way as sharpeRatio.
+func sortinoRatio(points []EquityPoint) float64 {
+	const minDataPoints = 10
+	returns := periodReturns(points)
+	if len(returns) < minDataPoints-1 {
+		return 0
+	}
+
+	mean := 0.0
+	downside := 0.0
+	for _, r := range returns {
+		mean += r
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	mean /= float64(len(returns))
+	downsideDev := math.Sqrt(downside / float64(len(returns)))
+	if downsideDev < 1e-10 {
+		return 0
+	}
+	return (mean / downsideDev) * math.Sqrt(252)
+}
+
+// ulcerIndex is the root mean square of percentage drawdowns from the running peak.
+func ulcerIndex(points []EquityPoint) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	peak := 0.0
+	sumSq := 0.0
+	for _, pt := range points {
+		if pt.Equity > peak {
+			peak = pt.Equity
+		}
+		if peak <= 0 {
+			continue
+		}
+		dd := (peak - pt.Equity) / peak * 100
+		sumSq += dd * dd
+	}
+	return math.Sqrt(sumSq / float64(len(points)))
+}
+
+// longestDrawdown returns the longest time equity spent below a previous peak,
+// including a drawdown still open at the last point.
+func longestDrawdown(points []EquityPoint) time.Duration {
+	if len(points) == 0 {
+		return 0
+	}
+	peak := points[0].Equity
+	peakTS := points[0].Timestamp
+	longest := int64(0)
+	for _, pt := range points[1:] {
+		if pt.Equity >= peak {
+			peak = pt.Equity
+			peakTS = pt.Timestamp
+			continue
+		}
+		if d := pt.Timestamp - peakTS; d > longest {
+			longest = d
+		}
+	}
+	return time.Duration(longest) * time.Millisecond
+}
+
+// annualizedReturn compounds the total return over the span covered by points.
+func annualizedReturn(points []EquityPoint, initialBalance, lastEquity float64) float64 {
+	if len(points) < 2 || initialBalance <= 0 {
+		return 0
+	}
+	span := time.Duration(points[len(points)-1].Timestamp-points[0].Timestamp) * time.Millisecond
+	years := span.Hours() / (24 * 365)
+	if years <= 0 {
+		return 0
+	}
+	growth := lastEquity / initialBalance
+	if growth <= 0 {
+		return -100
+	}
+	return (math.Pow(growth, 1/years) - 1) * 100
+}
+
+// monthlyReturns builds the per-month return table; each month starts from the previous month's close.
+func monthlyReturns(points []EquityPoint, initialBalance float64) []MonthlyReturn {
+	var rows []MonthlyReturn
+	startEquity := initialBalance
+	for _, pt := range points {
+		month := time.UnixMilli(pt.Timestamp).UTC().Format("2006-01")
+		if len(rows) == 0 || rows[len(rows)-1].Month != month {
+			if len(rows) > 0 {
+				startEquity = rows[len(rows)-1].EndEquity
+			}
+			rows = append(rows, MonthlyReturn{Month: month, StartEquity: startEquity})
+		}
+		rows[len(rows)-1].EndEquity = pt.Equity
+	}
+	for i := range rows {
+		if rows[i].StartEquity > 0 {
+			rows[i].ReturnPct = (rows[i].EndEquity - rows[i].StartEquity) / rows[i].StartEquity * 100
+		}
+	}
+	return rows
+}
+
+type openExposure struct {
+	notional float64
+	leverage int
+}
+
+// fillExposureMetrics replays trade events against the equity curve to measure how long
+// and how heavily the account was in the market. Each interval between equity points is
+// weighted by its duration and uses the positions held at its start.
+func fillExposureMetrics(metrics *Metrics, points []EquityPoint, events []TradeEvent) {
+	if metrics == nil || len(points) < 2 {
+		return
+	}
+	sorted := append([]TradeEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp < sorted[j].Timestamp })
+
+	open := make(map[string]openExposure)
+	next := 0
+	var total, inMarket, exposureSum, leverageSum float64
+	for i := 0; i < len(points)-1; i++ {
+		for next < len(sorted) && sorted[next].Timestamp <= points[i].Timestamp {
+			evt := sorted[next]
+			key := evt.Symbol + ":" + evt.Side
+			if evt.PositionAfter > 0 {
+				open[key] = openExposure{notional: evt.PositionAfter * evt.Price, leverage: evt.Leverage}
+			} else {
+				delete(open, key)
+			}
+			next++
+		}
+
+		dt := float64(points[i+1].Timestamp - points[i].Timestamp)
+		if dt <= 0 {
+			continue
+		}
+		total += dt
+		if len(open) == 0 {
+			continue
+		}
+		gross, weightedLev := 0.0, 0.0
+		for _, pos := range open {
+			gross += pos.notional
+			weightedLev += pos.notional * float64(pos.leverage)
+		}
+		inMarket += dt
+		if points[i].Equity > 0 {
+			exposureSum += gross / points[i].Equity * 100 * dt
+		}
+		if gross > 0 {
+			leverageSum += weightedLev / gross * dt
+		}
+	}
+
+	if total > 0 {
+		metrics.TimeInMarketPct = inMarket / total * 100
+	}
+	if inMarket > 0 {
+		metrics.AvgExposurePct = exposureSum / inMarket
+		metrics.AvgLeverage = leverageSum / inMarket
+	}
+}
+
+// benchmarkMetrics measures buy-and-hold of benchmarkSymbol between the first and last equity points.
+func benchmarkMetrics(points []EquityPoint, klines []market.Kline) *BenchmarkMetrics {
+	if len(points) < 2 || len(klines) == 0 {
+		return nil
+	}
+	startTS := points[0].Timestamp
+	endTS := points[len(points)-1].Timestamp
+
+	var startPrice, endPrice, peak, maxDD float64
+	for _, k := range klines {
+		if k.CloseTime > endTS {
+			break
+		}
+		if k.CloseTime <= startTS || startPrice == 0 {
+			// Last close at or before the first equity point (or the first close available)
+			startPrice, peak = k.Close, k.Close
+		}
+		endPrice = k.Close
+		peak = math.Max(peak, k.Close)
+		if peak > 0 {
+			maxDD = math.Max(maxDD, (peak-k.Close)/peak*100)
+		}
+	}
+	if startPrice <= 0 || endPrice <= 0 {
+		return nil
+	}
+	return &BenchmarkMetrics{
+		Symbol:         benchmarkSymbol,
+		StartPrice:     startPrice,
+		EndPrice:       endPrice,
+		ReturnPct:      (endPrice - startPrice) / startPrice * 100,
+		MaxDrawdownPct: maxDD,
+	}
+}
+
+// loadBenchmarkKlines returns decision-timeframe klines of benchmarkSymbol for the run window,
+// reusing the feed when the run already trades it. Errors only disable the comparison.
+func loadBenchmarkKlines(cfg *BacktestConfig, feed *DataFeed) []market.Kline {
+	if feed != nil && feed.replay != nil {
+		return feed.replay.Benchmark
+	}
+	if feed != nil {
+		if klines := feed.klines(benchmarkSymbol, cfg.DecisionTimeframe); len(klines) > 0 {
+			return klines
+		}
+	}
+	dur, err := market.TFDuration(cfg.DecisionTimeframe)
+	if err != nil {
+		return nil
+	}
+	klines, err := market.GetKlinesRange(benchmarkSymbol, cfg.DecisionTimeframe,
+		time.Unix(cfg.StartTS, 0), time.Unix(cfg.EndTS, 0).Add(dur))
+	if err != nil {
+		logger.Infof("benchmark klines unavailable for %s: %v", cfg.RunID, err)
+		return nil
+	}
+	return klines
+}
+
+// storedBenchmarkKlines is loadBenchmarkKlines for finished runs: klines come from the replay
+// snapshot or the kline store only, never from the network.
+func storedBenchmarkKlines(cfg *BacktestConfig) []market.Kline {
+	if cfg.ReplayKlinesPath != "" {
+		snapshot, err := loadReplayKlines(cfg.ReplayKlinesPath)
+		if err != nil {
+			return nil
+		}
+		return snapshot.Benchmark
+	}
+	dur, err := market.TFDuration(cfg.DecisionTimeframe)
+	if err != nil {
+		return nil
+	}
+	klines, err := market.GetStoredKlinesRange(benchmarkSymbol, cfg.DecisionTimeframe,
+		time.Unix(cfg.StartTS, 0), time.Unix(cfg.EndTS, 0).Add(dur))
+	if err != nil {
+		return nil
+	}
+	return klines
+}
@@ -0,0 +1,119 @@
+package backtest
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"nofx/market"
+)
+
+// Daily equity from 2024-01-28 to 2024-02-07: a 1-day dip, a 3-day drawdown from 1050 and a final 8% gain
+var metricsTestEquity = []float64{1000, 1010, 990, 1020, 1050, 1000, 980, 1030, 1060, 1040, 1080}
+
+func metricsTestTime(day int) int64 {
+	return time.Date(2024, 1, 28+day, 0, 0, 0, 0, time.UTC).UnixMilli()
+}
+
+func TestCalculateMetricsGolden(t *testing.T) {
+	t.Chdir(t.TempDir())
+	const runID = "metrics-golden"
+
+	for i, equity := range metricsTestEquity {
+		if err := appendEquityPoint(runID, EquityPoint{Timestamp: metricsTestTime(i), Equity: equity}); err != nil {
+			t.Fatalf("appendEquityPoint: %v", err)
+		}
+	}
+	// 1 BTC at 1000 with 2x leverage, held for the first 5 of 10 days
+	events := []TradeEvent{
+		{Timestamp: metricsTestTime(0), Symbol: "BTCUSDT", Action: "open_long", Side: "long", Quantity: 1, Price: 1000, Leverage: 2, PositionAfter: 1},
+		{Timestamp: metricsTestTime(5), Symbol: "BTCUSDT", Action: "close_long", Side: "long", Quantity: 1, Price: 1000, Leverage: 2, PositionAfter: 0},
+	}
+	for _, evt := range events {
+		if err := appendTradeEvent(runID, evt); err != nil {
+			t.Fatalf("appendTradeEvent: %v", err)
+		}
+	}
+	var benchmark []market.Kline
+	for i, price := range []float64{100, 105, 95, 100, 110, 120, 100, 105, 115, 110, 125} {
+		benchmark = append(benchmark, market.Kline{OpenTime: metricsTestTime(i) - 1, Close: price, CloseTime: metricsTestTime(i)})
+	}
+
+	m, err := CalculateMetrics(runID, &BacktestConfig{RunID: runID, InitialBalance: 1000}, nil, benchmark)
+	if err != nil {
+		t.Fatalf("CalculateMetrics: %v", err)
+	}
+
+	if len(m.MonthlyReturns) != 2 || m.MonthlyReturns[0].Month != "2024-01" || m.MonthlyReturns[1].Month != "2024-02" {
+		t.Fatalf("monthly returns = %+v, want 2024-01 and 2024-02", m.MonthlyReturns)
+	}
+	if m.Benchmark == nil || m.Benchmark.Symbol != benchmarkSymbol {
+		t.Fatalf("benchmark = %+v, want %s", m.Benchmark, benchmarkSymbol)
+	}
+	jan, feb, bench := m.MonthlyReturns[0], m.MonthlyReturns[1], m.Benchmark
+
+	golden := []struct {
+		name      string
+		got, want float64
+	}{
+		{"total return", m.TotalReturnPct, 8},
+		{"max drawdown", m.MaxDrawdownPct, 70.0 / 1050 * 100},
+		{"sortino", m.SortinoRatio, 7.0461990238107735},
+		{"ulcer index", m.UlcerIndex, 2.666790193155478},
+		{"longest drawdown hours", m.LongestDrawdownHours, 72},
+		{"annualized return", m.AnnualizedReturnPct, 1559.4610956304869},
+		{"calmar", m.CalmarRatio, 233.919164344573},
+		{"time in market", m.TimeInMarketPct, 50},
+		{"avg exposure", m.AvgExposurePct, 98.65946258491395},
+		{"avg leverage", m.AvgLeverage, 2},
+		// Each month starts from the previous month's close
+		{"january start", jan.StartEquity, 1000},
+		{"january end", jan.EndEquity, 1020},
+		{"january return", jan.ReturnPct, 2},
+		{"february start", feb.StartEquity, 1020},
+		{"february end", feb.EndEquity, 1080},
+		{"february return", feb.ReturnPct, 60.0 / 1020 * 100},
+		{"benchmark start", bench.StartPrice, 100},
+		{"benchmark end", bench.EndPrice, 125},
+		{"benchmark return", bench.ReturnPct, 25},
+		{"benchmark drawdown", bench.MaxDrawdownPct, 20.0 / 120 * 100},
+		{"excess return", bench.ExcessReturnPct, -17},
+	}
+	for _, g := range golden {
+		if math.Abs(g.got-g.want) > 1e-9*math.Max(1, math.Abs(g.want)) {
+			t.Errorf("%s = %v, want %v", g.name, g.got, g.want)
+		}
+	}
+}
+
+func TestStoredBenchmarkKlinesNeverFetch(t *testing.T) {
+	previous := market.GetKlineStore()
+	defer market.SetKlineStore(previous)
+
+	cfg := &BacktestConfig{
+		DecisionTimeframe: "1d",
+		StartTS:           metricsTestTime(0) / 1000,
+		EndTS:             metricsTestTime(10) / 1000,
+	}
+
+	market.SetKlineStore(nil)
+	if klines := storedBenchmarkKlines(cfg); klines != nil {
+		t.Fatalf("got %d klines without a kline store", len(klines))
+	}
+
+	// An online store with missing candles returns what it has instead of fetching the rest
+	store := market.NewKlineStore(t.TempDir(), false)
+	market.SetKlineStore(store)
+	day := int64(24 * time.Hour / time.Millisecond)
+	cached := []market.Kline{
+		{OpenTime: metricsTestTime(0), Close: 100, CloseTime: metricsTestTime(0) + day - 1},
+		{OpenTime: metricsTestTime(1), Close: 105, CloseTime: metricsTestTime(1) + day - 1},
+	}
+	if _, err := store.Save("binance", benchmarkSymbol, "1d", cached); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if klines := storedBenchmarkKlines(cfg); !reflect.DeepEqual(klines, cached) {
+		t.Errorf("stored benchmark klines = %+v, want %+v", klines, cached)
+	}
+}
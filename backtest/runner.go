@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"nofx/logger"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +19,7 @@ import (
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/store"
+	"nofx/trader/fillmodel"
 )
 
 var (
@@ -35,6 +38,8 @@ type Runner struct {
 	feed           *DataFeed
 	account        *BacktestAccount
 	strategyEngine *kernel.StrategyEngine
+	grid           *gridSim       // set for grid_trading runs, which replace AI decisions
+	benchmark      []market.Kline // buy-and-hold comparison for metrics; nil when unavailable
 
 	decisionLogDir string
 	mcpClient      mcp.AIClient
@@ -57,8 +62,9 @@ type Runner struct {
 	createdAt        time.Time
 	lastMetricsWrite time.Time
 
-	aiCache   *AICache
-	cachePath string
+	aiCache        *AICache
+	cachePath      string
+	aiCacheRelease sync.Once // Releases a shared AI cache exactly once
 
 	lockInfo     *RunLockInfo
 	lockStop     chan struct{}
@@ -75,6 +81,7 @@ func NewRunner(cfg BacktestConfig, mcpClient mcp.AIClient) (*Runner, error) {
 	if err != nil {
 		return nil, err
 	}
+	mcp.AttributeUsage(client, mcp.UsageAttribution{UserID: cfg.UserID, Source: mcp.UsageSourceBacktest, SourceID: cfg.RunID})
 
 	feed, err := NewDataFeed(cfg)
 	if err != nil {
@@ -86,7 +93,7 @@ func NewRunner(cfg BacktestConfig, mcpClient mcp.AIClient) (*Runner, error) {
 	}
 
 	dLogDir := decisionLogDir(cfg.RunID)
-	account := NewBacktestAccount(cfg.InitialBalance, cfg.FeeBps, cfg.SlippageBps)
+	account := NewBacktestAccountWithModel(cfg.InitialBalance, fillmodel.New(cfg.FillModelConfig()))
 
 	createdAt := time.Now().UTC()
 	state := &BacktestState{
@@ -106,11 +113,17 @@ func NewRunner(cfg BacktestConfig, mcpClient mcp.AIClient) (*Runner, error) {
 		cachePath string
 	)
 	if cfg.CacheAI || cfg.ReplayOnly || cfg.SharedAICachePath != "" {
-		cachePath = cfg.SharedAICachePath
-		if cachePath == "" {
+		var (
+			cache *AICache
+			err   error
+		)
+		if cfg.SharedAICachePath != "" {
+			cachePath = cfg.SharedAICachePath
+			cache, err = OpenSharedAICache(cachePath)
+		} else {
 			cachePath = filepath.Join(runDir(cfg.RunID), "ai_cache.json")
+			cache, err = LoadAICache(cachePath)
 		}
-		cache, err := LoadAICache(cachePath)
 		if err != nil {
 			return nil, fmt.Errorf("load ai cache: %w", err)
 		}
@@ -119,6 +132,8 @@ func NewRunner(cfg BacktestConfig, mcpClient mcp.AIClient) (*Runner, error) {
 
 	// Create strategy engine from backtest config for unified prompt generation
 	strategyConfig := cfg.ToStrategyConfig()
+	// Tools would fetch live market data, so backtests always preload historical data into the prompt
+	strategyConfig.Indicators.EnableToolCalling = false
 	strategyEngine := kernel.NewStrategyEngine(strategyConfig)
 
 	r := &Runner{
@@ -138,14 +153,32 @@ func NewRunner(cfg BacktestConfig, mcpClient mcp.AIClient) (*Runner, error) {
 		aiCache:        aiCache,
 		cachePath:      cachePath,
 	}
+	if cfg.IsGrid() {
+		r.grid = newGridSim(cfg.GridConfig, account.FillModel())
+	}
+	r.benchmark = loadBenchmarkKlines(&r.cfg, feed)
+	if err := saveReplayInputs(&r.cfg, feed, r.benchmark, strategyConfig); err != nil {
+		logger.Infof("failed to save replay inputs for %s: %v", cfg.RunID, err)
+	}
 
 	if err := r.initLock(); err != nil {
+		r.releaseAICache()
 		return nil, err
 	}
 
 	return r, nil
 }
 
+// releaseAICache releases the shared AI cache of the run once it is over
+func (r *Runner) releaseAICache() {
+	if r.cfg.SharedAICachePath == "" || r.aiCache == nil {
+		return
+	}
+	r.aiCacheRelease.Do(func() {
+		ReleaseSharedAICache(r.cfg.SharedAICachePath)
+	})
+}
+
 func (r *Runner) initLock() error {
 	if r.cfg.RunID == "" {
 		return fmt.Errorf("run_id required for lock")
@@ -234,6 +267,7 @@ func (r *Runner) CurrentMetadata() *RunMetadata {
 
 func (r *Runner) loop(ctx context.Context) {
 	defer close(r.doneCh)
+	defer r.releaseAICache()
 
 	for {
 		select {
@@ -285,7 +319,7 @@ func (r *Runner) stepOnce() error {
 	}
 
 	callCount := state.DecisionCycle + 1
-	shouldDecide := r.shouldTriggerDecision(state.BarIndex)
+	shouldDecide := r.grid == nil && r.shouldTriggerDecision(state.BarIndex)
 
 	var (
 		record          *store.DecisionRecord
@@ -297,6 +331,37 @@ func (r *Runner) stepOnce() error {
 
 	decisionAttempted := shouldDecide
 
+	// Protective orders from earlier decisions fire before the AI sees this bar
+	protectiveEvents, err := r.checkProtectiveOrders(ts, state.DecisionCycle)
+	if err != nil {
+		return err
+	}
+	for _, evt := range protectiveEvents {
+		tradeEvents = append(tradeEvents, evt)
+		execLog = append(execLog, fmt.Sprintf("🛡️ %s %s %s: %s", evt.Symbol, evt.Side, evt.CloseReason, evt.Note))
+	}
+	r.trailStops(ts)
+
+	if r.grid != nil {
+		gridEvents, gridNotes, err := r.stepGrid(ts, priceMap, protectiveEvents, callCount)
+		if err != nil {
+			return err
+		}
+		if len(gridEvents) > 0 || len(gridNotes) > 0 {
+			// Grid activity is logged like a decision cycle so it shows up in the decision trail
+			decisionAttempted = true
+			record = r.gridDecisionRecord(ts, priceMap, callCount)
+			for _, evt := range gridEvents {
+				decisionActions = append(decisionActions, tradeEventAction(evt))
+				execLog = append(execLog, fmt.Sprintf("📊 %s %s %.4f @ %.4f %s", evt.Symbol, evt.Action, evt.Quantity, evt.Price, evt.Note))
+			}
+			for _, note := range gridNotes {
+				execLog = append(execLog, "📊 "+note)
+			}
+			tradeEvents = append(tradeEvents, gridEvents...)
+		}
+	}
+
 	if shouldDecide {
 		ctx, rec, err := r.buildDecisionContext(ts, marketData, multiTF, priceMap, callCount)
 		if err != nil {
@@ -315,7 +380,7 @@ func (r *Runner) stepOnce() error {
 			fromCache    bool
 			cacheKey     string
 		)
-		if r.aiCache != nil {
+		if r.aiCache != nil && !r.cfg.IsRules() {
 			if key, err := computeCacheKey(ctx, r.cfg.PromptVariant, ts); err == nil {
 				cacheKey = key
 				if cached, ok := r.aiCache.Get(cacheKey); ok {
@@ -334,7 +399,12 @@ func (r *Runner) stepOnce() error {
 		}
 
 		if !fromCache {
-			fd, err := r.invokeAIWithRetry(ctx)
+			var fd *kernel.FullDecision
+			if r.cfg.IsRules() {
+				fd, err = r.ruleDecision(ctx, ts)
+			} else {
+				fd, err = r.invokeAIWithRetry(ctx)
+			}
 			if err != nil {
 				decisionAttempted = true
 				hadError = true
@@ -383,6 +453,21 @@ func (r *Runner) stepOnce() error {
 				}
 				decisionActions = append(decisionActions, actionRecord)
 			}
+
+			for _, rejected := range fullDecision.Rejected {
+				decisionActions = append(decisionActions, store.DecisionAction{
+					Action:     rejected.Action,
+					Symbol:     rejected.Symbol,
+					Leverage:   rejected.Leverage,
+					StopLoss:   rejected.StopLoss,
+					TakeProfit: rejected.TakeProfit,
+					Confidence: rejected.Confidence,
+					Reasoning:  rejected.Reasoning,
+					Timestamp:  time.UnixMilli(ts).UTC(),
+					Error:      "rejected by hybrid rules: " + rejected.RejectReason,
+				})
+				execLog = append(execLog, fmt.Sprintf("🚫 %s %s rejected: %s", rejected.Symbol, rejected.Action, rejected.RejectReason))
+			}
 		}
 	}
 
@@ -521,11 +606,17 @@ func (r *Runner) buildDecisionContext(ts int64, marketData map[string]*market.Da
 		BTCETHLeverage:  r.cfg.Leverage.BTCETHLeverage,
 		AltcoinLeverage: r.cfg.Leverage.AltcoinLeverage,
 		Timeframes:      r.cfg.Timeframes,
+		// Hybrid rules see the klines known at ts, like rule strategies
+		RuleKlines: func(symbol, timeframe string) []market.Kline {
+			return r.feed.sliceUpTo(symbol, timeframe, ts)
+		},
 	}
 
 	// Fetch quantitative data if enabled in strategy (uses current data as approximation)
+	// Replay-only runs never call the AI, so they skip these live (network) prompt extras
 	strategyConfig := r.strategyEngine.GetConfig()
-	if strategyConfig.Indicators.EnableQuantData {
+	liveExtras := !r.cfg.ReplayOnly
+	if liveExtras && strategyConfig.Indicators.EnableQuantData {
 		// Collect symbols to query (candidate coins + position coins)
 		symbolSet := make(map[string]bool)
 		for _, sym := range r.cfg.Symbols {
@@ -545,7 +636,7 @@ func (r *Runner) buildDecisionContext(ts int64, marketData map[string]*market.Da
 	}
 
 	// Fetch OI ranking data if enabled in strategy (uses current data as approximation)
-	if strategyConfig.Indicators.EnableOIRanking {
+	if liveExtras && strategyConfig.Indicators.EnableOIRanking {
 		ctx.OIRankingData = r.strategyEngine.FetchOIRankingData()
 		if ctx.OIRankingData != nil {
 			logger.Infof("📊 Backtest: OI ranking data ready: %d top, %d low positions",
@@ -554,7 +645,7 @@ func (r *Runner) buildDecisionContext(ts int64, marketData map[string]*market.Da
 	}
 
 	// Fetch NetFlow ranking data if enabled in strategy
-	if strategyConfig.Indicators.EnableNetFlowRanking {
+	if liveExtras && strategyConfig.Indicators.EnableNetFlowRanking {
 		ctx.NetFlowRankingData = r.strategyEngine.FetchNetFlowRankingData()
 		if ctx.NetFlowRankingData != nil {
 			logger.Infof("💰 Backtest: NetFlow ranking data ready: inst_in=%d, inst_out=%d",
@@ -563,7 +654,7 @@ func (r *Runner) buildDecisionContext(ts int64, marketData map[string]*market.Da
 	}
 
 	// Fetch Price ranking data if enabled in strategy
-	if strategyConfig.Indicators.EnablePriceRanking {
+	if liveExtras && strategyConfig.Indicators.EnablePriceRanking {
 		ctx.PriceRankingData = r.strategyEngine.FetchPriceRankingData()
 		if ctx.PriceRankingData != nil {
 			logger.Infof("📈 Backtest: Price ranking data ready for %d durations",
@@ -586,6 +677,9 @@ func (r *Runner) buildDecisionContext(ts int64, marketData map[string]*market.Da
 		record.CandidateCoins = append(record.CandidateCoins, coin.Symbol)
 	}
 	record.Timestamp = time.UnixMilli(ts).UTC()
+	record.PromptHash = store.PromptContentOf(strategyConfig, r.cfg.PromptVariant, r.cfg.OverrideBasePrompt).Hash()
+	record.PromptTemplateID = r.cfg.PromptTemplateID
+	record.PromptVersion = r.cfg.PromptTemplateVersion
 
 	return ctx, record, nil
 }
@@ -621,6 +715,13 @@ func (r *Runner) invokeAIWithRetry(ctx *kernel.Context) (*kernel.FullDecision, e
 	return nil, lastErr
 }
 
+// ruleDecision evaluates the strategy rules on the klines known at ts
+func (r *Runner) ruleDecision(ctx *kernel.Context, ts int64) (*kernel.FullDecision, error) {
+	return kernel.GetRuleDecision(ctx, r.strategyEngine, func(symbol, timeframe string) []market.Kline {
+		return r.feed.sliceUpTo(symbol, timeframe, ts)
+	})
+}
+
 func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float64, ts int64, cycle int) (store.DecisionAction, []TradeEvent, string, error) {
 	symbol := dec.Symbol
 	if symbol == "" {
@@ -644,6 +745,7 @@ func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float6
 		return actionRecord, nil, "", fmt.Errorf("price unavailable for %s (found=%v, price=%.4f)", symbol, ok, basePrice)
 	}
 	fillPrice := r.executionPrice(symbol, basePrice, ts)
+	volume := r.executionVolume(symbol, ts)
 
 	switch dec.Action {
 	case "open_long":
@@ -651,10 +753,14 @@ func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float6
 		if qty <= 0 {
 			return actionRecord, nil, "", fmt.Errorf("invalid qty")
 		}
+		qty, partialNote := r.applyParticipation(qty, volume)
 		pos, fee, execPrice, err := r.account.Open(symbol, "long", qty, usedLeverage, fillPrice, ts)
 		if err != nil {
 			return actionRecord, nil, "", err
 		}
+		r.account.SetProtection(symbol, "long", dec.StopLoss, dec.TakeProfit)
+		r.account.SetTrailingStop(symbol, "long", dec.TrailingStopPct, execPrice)
+		r.account.SetTakeProfitLadder(symbol, "long", takeProfitLevels(&dec, "long", execPrice, qty))
 		actionRecord.Quantity = qty
 		actionRecord.Price = execPrice
 		actionRecord.Leverage = pos.Leverage
@@ -672,18 +778,23 @@ func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float6
 			Leverage:      pos.Leverage,
 			Cycle:         cycle,
 			PositionAfter: pos.Quantity,
+			Note:          partialNote,
 		}
-		return actionRecord, []TradeEvent{trade}, "", nil
+		return actionRecord, []TradeEvent{trade}, partialNote, nil
 
 	case "open_short":
 		qty := r.determineQuantity(dec, basePrice)
 		if qty <= 0 {
 			return actionRecord, nil, "", fmt.Errorf("invalid qty")
 		}
+		qty, partialNote := r.applyParticipation(qty, volume)
 		pos, fee, execPrice, err := r.account.Open(symbol, "short", qty, usedLeverage, fillPrice, ts)
 		if err != nil {
 			return actionRecord, nil, "", err
 		}
+		r.account.SetProtection(symbol, "short", dec.StopLoss, dec.TakeProfit)
+		r.account.SetTrailingStop(symbol, "short", dec.TrailingStopPct, execPrice)
+		r.account.SetTakeProfitLadder(symbol, "short", takeProfitLevels(&dec, "short", execPrice, qty))
 		actionRecord.Quantity = qty
 		actionRecord.Price = execPrice
 		actionRecord.Leverage = pos.Leverage
@@ -701,14 +812,16 @@ func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float6
 			Leverage:      pos.Leverage,
 			Cycle:         cycle,
 			PositionAfter: pos.Quantity,
+			Note:          partialNote,
 		}
-		return actionRecord, []TradeEvent{trade}, "", nil
+		return actionRecord, []TradeEvent{trade}, partialNote, nil
 
 	case "close_long":
 		qty := r.determineCloseQuantity(symbol, "long", dec)
 		if qty <= 0 {
 			return actionRecord, nil, "", fmt.Errorf("invalid close qty")
 		}
+		qty, partialNote := r.applyParticipation(qty, volume)
 		posLev := r.account.positionLeverage(symbol, "long")
 		realized, fee, execPrice, err := r.account.Close(symbol, "long", qty, fillPrice)
 		if err != nil {
@@ -731,14 +844,17 @@ func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float6
 			Leverage:      posLev,
 			Cycle:         cycle,
 			PositionAfter: r.remainingPosition(symbol, "long"),
+			CloseReason:   "signal",
+			Note:          partialNote,
 		}
-		return actionRecord, []TradeEvent{trade}, "", nil
+		return actionRecord, []TradeEvent{trade}, partialNote, nil
 
 	case "close_short":
 		qty := r.determineCloseQuantity(symbol, "short", dec)
 		if qty <= 0 {
 			return actionRecord, nil, "", fmt.Errorf("invalid close qty")
 		}
+		qty, partialNote := r.applyParticipation(qty, volume)
 		posLev := r.account.positionLeverage(symbol, "short")
 		realized, fee, execPrice, err := r.account.Close(symbol, "short", qty, fillPrice)
 		if err != nil {
@@ -761,8 +877,14 @@ func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float6
 			Leverage:      posLev,
 			Cycle:         cycle,
 			PositionAfter: r.remainingPosition(symbol, "short"),
+			CloseReason:   "signal",
+			Note:          partialNote,
 		}
-		return actionRecord, []TradeEvent{trade}, "", nil
+		return actionRecord, []TradeEvent{trade}, partialNote, nil
+
+	case "set_trailing_stop", "move_to_breakeven":
+		note, err := r.manageStops(dec, basePrice, &actionRecord)
+		return actionRecord, nil, note, err
 
 	case "hold", "wait":
 		return actionRecord, nil, fmt.Sprintf("hold position: %s", dec.Action), nil
@@ -771,6 +893,39 @@ func (r *Runner) executeDecision(dec kernel.Decision, priceMap map[string]float6
 	}
 }
 
+// manageStops applies a trailing stop or a break-even stop to the positions of the decision's symbol
+func (r *Runner) manageStops(dec kernel.Decision, price float64, actionRecord *store.DecisionAction) (string, error) {
+	var positions []*position
+	for _, pos := range r.account.Positions() {
+		if strings.EqualFold(pos.Symbol, dec.Symbol) {
+			positions = append(positions, pos)
+		}
+	}
+	if len(positions) == 0 {
+		return "", fmt.Errorf("no open position for %s", dec.Symbol)
+	}
+
+	var notes []string
+	for _, pos := range positions {
+		if dec.Action == "set_trailing_stop" {
+			r.account.SetTrailingStop(pos.Symbol, pos.Side, dec.TrailingStopPct, price)
+			notes = append(notes, fmt.Sprintf("%s trailing stop %.2f%%, stop %.4f", pos.Side, dec.TrailingStopPct, pos.StopLoss))
+		} else {
+			if (pos.Side == "long" && price <= pos.EntryPrice) || (pos.Side == "short" && price >= pos.EntryPrice) {
+				return "", fmt.Errorf("%s %s is not in profit (entry %.4f, price %.4f), can't move stop to break-even",
+					pos.Symbol, pos.Side, pos.EntryPrice, price)
+			}
+			r.account.SetProtection(pos.Symbol, pos.Side, pos.EntryPrice, 0)
+			actionRecord.StopLoss = pos.EntryPrice
+			notes = append(notes, fmt.Sprintf("%s stop moved to entry %.4f", pos.Side, pos.EntryPrice))
+		}
+		actionRecord.Quantity = pos.Quantity
+		actionRecord.Price = price
+		actionRecord.Leverage = pos.Leverage
+	}
+	return strings.Join(notes, "; "), nil
+}
+
 // MinPositionSizeUSD is the minimum position size in USD to avoid dust positions
 const MinPositionSizeUSD = 10.0
 
@@ -939,6 +1094,28 @@ func (r *Runner) executionPrice(symbol string, markPrice float64, ts int64) floa
 	return markPrice
 }
 
+// executionVolume returns the volume of the bar the fill policy executes in (0 when unknown).
+func (r *Runner) executionVolume(symbol string, ts int64) float64 {
+	curr, next := r.feed.decisionBarSnapshot(symbol, ts)
+	if r.cfg.FillPolicy == FillPolicyNextOpen && next != nil {
+		return next.Volume
+	}
+	if curr != nil {
+		return curr.Volume
+	}
+	return 0
+}
+
+// applyParticipation caps qty to the fill model's share of bar volume and describes any partial fill.
+func (r *Runner) applyParticipation(qty, volume float64) (float64, string) {
+	filled := r.account.FillModel().Capacity(qty, volume)
+	if filled >= qty-epsilon {
+		return qty, ""
+	}
+	return filled, fmt.Sprintf("partial fill %.6f of %.6f (max participation %.0f%% of bar volume)",
+		filled, qty, r.cfg.MaxParticipation*100)
+}
+
 func (r *Runner) totalMarginUsed() float64 {
 	sum := 0.0
 	for _, pos := range r.account.Positions() {
@@ -977,6 +1154,11 @@ func (r *Runner) updateState(ts int64, equity, unrealized, marginUsed float64, p
 			MarginUsed:       pos.Margin,
 			OpenTime:         pos.OpenTime,
 			AccumulatedFee:   pos.AccumulatedFee,
+			StopLoss:         pos.StopLoss,
+			TakeProfit:       pos.TakeProfit,
+			TrailingStopPct:  pos.TrailingStopPct,
+			BestPrice:        pos.BestPrice,
+			TakeProfitLevels: append([]TakeProfitLevel(nil), pos.TakeProfitLevels...),
 		}
 	}
 
@@ -1081,6 +1263,7 @@ func (r *Runner) checkLiquidation(ts int64, priceMap map[string]float64, cycle i
 			Cycle:           cycle,
 			PositionAfter:   0,
 			LiquidationFlag: true,
+			CloseReason:     "liquidation",
 			Note:            fmt.Sprintf("forced liquidation at %.4f", finalPrice),
 		}
 		events = append(events, evt)
@@ -1100,6 +1283,148 @@ func (r *Runner) checkLiquidation(ts int64, priceMap map[string]float64, cycle i
 	return events, note, nil
 }
 
+// checkProtectiveOrders closes positions whose stop-loss or take-profit was crossed by the high/low
+// of the bar ending at ts. Only bars after the position was opened are considered.
+func (r *Runner) checkProtectiveOrders(ts int64, cycle int) ([]TradeEvent, error) {
+	positions := append([]*position(nil), r.account.Positions()...)
+	sort.Slice(positions, func(i, j int) bool {
+		return positionKey(positions[i].Symbol, positions[i].Side) < positionKey(positions[j].Symbol, positions[j].Side)
+	})
+
+	events := make([]TradeEvent, 0)
+	model := r.account.FillModel()
+	for _, pos := range positions {
+		if pos.StopLoss <= 0 && pos.TakeProfit <= 0 && len(pos.TakeProfitLevels) == 0 {
+			continue
+		}
+		if pos.OpenTime >= ts {
+			continue
+		}
+		bar, _ := r.feed.decisionBarSnapshot(pos.Symbol, ts)
+		if bar == nil {
+			continue
+		}
+
+		fills := model.Match(protectiveOrders(pos), fillmodel.Bar{
+			Time:   ts,
+			Open:   bar.Open,
+			High:   bar.High,
+			Low:    bar.Low,
+			Close:  bar.Close,
+			Volume: bar.Volume,
+		})
+		for _, fill := range fills {
+			symbol, side, leverage := pos.Symbol, pos.Side, pos.Leverage
+			level := pos.StopLoss
+			if fill.Type == fillmodel.TypeTakeProfitMarket {
+				level = pos.TakeProfit
+			}
+			if i := ladderLevel(pos, fill.OrderID); i >= 0 {
+				level = pos.TakeProfitLevels[i].Price
+				pos.TakeProfitLevels[i].Quantity -= fill.Quantity
+				if pos.TakeProfitLevels[i].Quantity <= epsilon {
+					pos.TakeProfitLevels = append(pos.TakeProfitLevels[:i], pos.TakeProfitLevels[i+1:]...)
+				}
+			}
+
+			before := pos.Quantity
+			realized, fee, execPrice, err := r.account.CloseFill(symbol, side, fill)
+			if err != nil {
+				return nil, err
+			}
+			remaining := r.remainingPosition(symbol, side)
+			qty := before - remaining
+			slippage := level - execPrice
+			if side == "short" {
+				slippage = execPrice - level
+			}
+			events = append(events, TradeEvent{
+				Timestamp:     ts,
+				Symbol:        symbol,
+				Action:        "close_" + side,
+				Side:          side,
+				Quantity:      qty,
+				Price:         execPrice,
+				Fee:           fee,
+				Slippage:      slippage,
+				OrderValue:    execPrice * qty,
+				RealizedPnL:   realized - fee,
+				Leverage:      leverage,
+				Cycle:         cycle,
+				PositionAfter: remaining,
+				CloseReason:   fill.Reason(),
+				Note:          fmt.Sprintf("%s %.4f crossed (bar low %.4f, high %.4f)", fill.Reason(), level, bar.Low, bar.High),
+			})
+		}
+	}
+	return events, nil
+}
+
+// trailStops moves trailing stops after the high/low of the bar ending at ts, so that the
+// moved stop protects the position from the next bar on.
+func (r *Runner) trailStops(ts int64) {
+	for _, pos := range r.account.Positions() {
+		if pos.TrailingStopPct <= 0 || pos.OpenTime >= ts {
+			continue
+		}
+		bar, _ := r.feed.decisionBarSnapshot(pos.Symbol, ts)
+		if bar == nil {
+			continue
+		}
+		pos.trail(bar.High, bar.Low)
+	}
+}
+
+// protectiveOrders builds the reduce-only stop-loss / take-profit orders of a position as one OCO group.
+// Take-profit ladder levels close part of the position; being in the group, at most one of them
+// fills per bar.
+func protectiveOrders(pos *position) []*fillmodel.Order {
+	side := orderSide(pos.Side, false)
+	group := positionKey(pos.Symbol, pos.Side)
+	orders := make([]*fillmodel.Order, 0, 2+len(pos.TakeProfitLevels))
+	if pos.StopLoss > 0 {
+		orders = append(orders, &fillmodel.Order{
+			ID: group + ":sl", Symbol: pos.Symbol, Side: side, Type: fillmodel.TypeStopMarket,
+			StopPrice: pos.StopLoss, Quantity: pos.Quantity, ReduceOnly: true, OCOGroup: group,
+		})
+	}
+	for i, level := range pos.TakeProfitLevels {
+		orders = append(orders, &fillmodel.Order{
+			ID: fmt.Sprintf("%s:tp%d", group, i), Symbol: pos.Symbol, Side: side, Type: fillmodel.TypeTakeProfitMarket,
+			StopPrice: level.Price, Quantity: math.Min(level.Quantity, pos.Quantity), ReduceOnly: true, OCOGroup: group,
+		})
+	}
+	if pos.TakeProfit > 0 {
+		orders = append(orders, &fillmodel.Order{
+			ID: group + ":tp", Symbol: pos.Symbol, Side: side, Type: fillmodel.TypeTakeProfitMarket,
+			StopPrice: pos.TakeProfit, Quantity: pos.Quantity, ReduceOnly: true, OCOGroup: group,
+		})
+	}
+	return orders
+}
+
+// ladderLevel returns the take-profit ladder level of a protective order id, -1 if it isn't one
+func ladderLevel(pos *position, orderID string) int {
+	suffix, ok := strings.CutPrefix(orderID, positionKey(pos.Symbol, pos.Side)+":tp")
+	if !ok {
+		return -1
+	}
+	i, err := strconv.Atoi(suffix)
+	if err != nil || i >= len(pos.TakeProfitLevels) {
+		return -1
+	}
+	return i
+}
+
+// takeProfitLevels sizes the take-profit ladder of a decision for the opened quantity
+func takeProfitLevels(dec *kernel.Decision, side string, entry, qty float64) []TakeProfitLevel {
+	var levels []TakeProfitLevel
+	for _, level := range dec.TakeProfitLadder(side, entry) {
+		levels = append(levels, TakeProfitLevel{Price: level.Price, Quantity: qty * level.Pct / 100})
+	}
+	return levels
+}
+
 func (r *Runner) shouldTriggerDecision(barIndex int) bool {
 	if r.cfg.DecisionCadenceNBars <= 1 {
 		return true
@@ -1326,7 +1651,7 @@ func (r *Runner) persistMetrics(force bool) {
 	}
 
 	state := r.snapshotState()
-	metrics, err := CalculateMetrics(r.cfg.RunID, &r.cfg, &state)
+	metrics, err := CalculateMetrics(r.cfg.RunID, &r.cfg, &state, r.benchmark)
 	if err != nil {
 		logger.Infof("failed to compute metrics for %s: %v", r.cfg.RunID, err)
 		return
@@ -1417,9 +1742,17 @@ func (r *Runner) buildCheckpointFromState(state BacktestState) *Checkpoint {
 		MinEquity:       state.MinEquity,
 		MaxDrawdownPct:  state.MaxDrawdownPct,
 		AICacheRef:      r.cachePath,
+		Grid:            r.gridCheckpoint(),
 	}
 }
 
+func (r *Runner) gridCheckpoint() *GridCheckpoint {
+	if r.grid == nil {
+		return nil
+	}
+	return r.grid.checkpoint()
+}
+
 func (r *Runner) saveCheckpoint(state BacktestState) error {
 	ckpt := r.buildCheckpointFromState(state)
 	if ckpt == nil {
@@ -1452,6 +1785,9 @@ func (r *Runner) applyCheckpoint(ckpt *Checkpoint) error {
 		return fmt.Errorf("checkpoint is nil")
 	}
 	r.account.RestoreFromSnapshots(ckpt.Cash, ckpt.RealizedPnL, ckpt.Positions)
+	if r.grid != nil && ckpt.Grid != nil {
+		r.grid.restore(ckpt.Grid)
+	}
 	r.stateMu.Lock()
 	defer r.stateMu.Unlock()
 	r.state.BarIndex = ckpt.BarIndex
@@ -1490,10 +1826,12 @@ func sortDecisionsByPriority(decisions []kernel.Decision) []kernel.Decision {
 		switch action {
 		case "close_long", "close_short":
 			return 1
-		case "open_long", "open_short":
+		case "set_trailing_stop", "move_to_breakeven":
 			return 2
-		case "hold", "wait":
+		case "open_long", "open_short":
 			return 3
+		case "hold", "wait":
+			return 4
 		default:
 			return 99
 		}
@@ -0,0 +1,162 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/trader/fillmodel"
+)
+
+// protectiveTestRunner runs 15m bars of [open, high, low, close] with a fill model without slippage
+func protectiveTestRunner(t *testing.T, tieBreak fillmodel.TieBreak, bars [][4]float64) *Runner {
+	t.Helper()
+	step := int64(15 * time.Minute / time.Millisecond)
+	series := &timeframeSeries{}
+	for i, b := range bars {
+		openTime := int64(i) * step
+		series.klines = append(series.klines, market.Kline{
+			OpenTime:  openTime,
+			Open:      b[0],
+			High:      b[1],
+			Low:       b[2],
+			Close:     b[3],
+			Volume:    1000,
+			CloseTime: openTime + step - 1,
+		})
+		series.closeTimes = append(series.closeTimes, openTime+step-1)
+	}
+	feed := &DataFeed{
+		symbols:       []string{gridTestSymbol},
+		primaryTF:     "15m",
+		decisionTimes: series.closeTimes,
+		symbolSeries: map[string]*symbolSeries{
+			gridTestSymbol: {byTF: map[string]*timeframeSeries{"15m": series}},
+		},
+	}
+	account := NewBacktestAccountWithModel(10000, fillmodel.New(fillmodel.Config{
+		Fees:     fillmodel.FlatFees(0.0004),
+		TieBreak: tieBreak,
+	}))
+	return &Runner{
+		feed:    feed,
+		account: account,
+		state:   &BacktestState{Positions: make(map[string]PositionSnapshot)},
+	}
+}
+
+// openProtected opens a position at the close of the first bar with the given stop-loss and take-profit
+func openProtected(t *testing.T, r *Runner, side string, stopLoss, takeProfit float64) {
+	t.Helper()
+	ts := r.feed.DecisionTimestamp(0)
+	bar, _ := r.feed.decisionBarSnapshot(gridTestSymbol, ts)
+	if _, _, _, err := r.account.Open(gridTestSymbol, side, 1, 2, bar.Close, ts); err != nil {
+		t.Fatalf("open %s: %v", side, err)
+	}
+	r.account.SetProtection(gridTestSymbol, side, stopLoss, takeProfit)
+}
+
+func TestProtectiveOrdersSameBarTieBreak(t *testing.T) {
+	tests := []struct {
+		name       string
+		tieBreak   fillmodel.TieBreak
+		open       float64
+		wantReason string
+		wantPrice  float64
+	}{
+		{"stop first", fillmodel.TieBreakStopFirst, 103, "stop_loss", 95},
+		{"target first", fillmodel.TieBreakTargetFirst, 103, "take_profit", 110},
+		{"nearest open, target closer", fillmodel.TieBreakNearestOpen, 103, "take_profit", 110},
+		{"nearest open, stop closer", fillmodel.TieBreakNearestOpen, 98, "stop_loss", 95},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The second bar crosses both the stop at 95 and the target at 110
+			r := protectiveTestRunner(t, tt.tieBreak, [][4]float64{
+				{100, 101, 99, 100},
+				{tt.open, 112, 94, 100},
+			})
+			openProtected(t, r, "long", 95, 110)
+
+			events, err := r.checkProtectiveOrders(r.feed.DecisionTimestamp(1), 1)
+			if err != nil {
+				t.Fatalf("checkProtectiveOrders: %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want exactly one of the OCO pair: %+v", len(events), events)
+			}
+			if got := events[0]; got.CloseReason != tt.wantReason || got.Price != tt.wantPrice || got.PositionAfter != 0 {
+				t.Errorf("closed by %s at %v (remaining %v), want %s at %v", got.CloseReason, got.Price, got.PositionAfter, tt.wantReason, tt.wantPrice)
+			}
+			if n := len(r.account.Positions()); n != 0 {
+				t.Errorf("%d positions left open", n)
+			}
+		})
+	}
+}
+
+func TestProtectiveOrderFillPrice(t *testing.T) {
+	tests := []struct {
+		name         string
+		side         string
+		stopLoss     float64
+		takeProfit   float64
+		bar          [4]float64
+		wantReason   string
+		wantPrice    float64
+		wantSlippage float64
+	}{
+		// Opening on the right side of the trigger and trading through it fills at the trigger
+		{"long stop crossed intrabar", "long", 95, 0, [4]float64{100, 101, 94, 96}, "stop_loss", 95, 0},
+		{"short stop crossed intrabar", "short", 105, 0, [4]float64{100, 106, 99, 104}, "stop_loss", 105, 0},
+		// Gapping through the trigger fills at the open: worse for stops, better for targets
+		{"long stop gapped through", "long", 95, 0, [4]float64{90, 91, 88, 89}, "stop_loss", 90, 5},
+		{"short stop gapped through", "short", 105, 0, [4]float64{108, 110, 107, 109}, "stop_loss", 108, 3},
+		{"long target gapped through", "long", 0, 110, [4]float64{113, 115, 112, 114}, "take_profit", 113, -3},
+		{"short target gapped through", "short", 0, 90, [4]float64{85, 86, 84, 85}, "take_profit", 85, -5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := protectiveTestRunner(t, fillmodel.TieBreakStopFirst, [][4]float64{
+				{100, 101, 99, 100},
+				tt.bar,
+			})
+			openProtected(t, r, tt.side, tt.stopLoss, tt.takeProfit)
+
+			events, err := r.checkProtectiveOrders(r.feed.DecisionTimestamp(1), 1)
+			if err != nil {
+				t.Fatalf("checkProtectiveOrders: %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1: %+v", len(events), events)
+			}
+			got := events[0]
+			if got.CloseReason != tt.wantReason || got.Price != tt.wantPrice {
+				t.Errorf("closed by %s at %v, want %s at %v", got.CloseReason, got.Price, tt.wantReason, tt.wantPrice)
+			}
+			// Slippage is measured against the trigger level, positive when worse
+			if math.Abs(got.Slippage-tt.wantSlippage) > 1e-9 {
+				t.Errorf("slippage = %v, want %v", got.Slippage, tt.wantSlippage)
+			}
+		})
+	}
+}
+
+func TestProtectiveOrdersSkipEntryBar(t *testing.T) {
+	// The entry bar itself crosses the stop, but protection only applies from the next bar on
+	r := protectiveTestRunner(t, fillmodel.TieBreakStopFirst, [][4]float64{
+		{100, 101, 90, 100},
+		{100, 101, 99, 100},
+	})
+	openProtected(t, r, "long", 95, 0)
+	for i := 0; i < 2; i++ {
+		events, err := r.checkProtectiveOrders(r.feed.DecisionTimestamp(i), i)
+		if err != nil {
+			t.Fatalf("bar %d: %v", i, err)
+		}
+		if len(events) != 0 {
+			t.Fatalf("bar %d: unexpected events %+v", i, events)
+		}
+	}
+}
@@ -507,11 +507,15 @@ func CreateRunExport(runID string) (string, error) {
 	defer tmpFile.Close()
 
 	zipWriter := zip.NewWriter(tmpFile)
+	replayDir := replayInputsDir(runID)
 	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 		if d.IsDir() {
+			if path == replayDir {
+				return filepath.SkipDir // packaged as the replay bundle below
+			}
 			return nil
 		}
 		rel, err := filepath.Rel(root, path)
@@ -543,6 +547,9 @@ func CreateRunExport(runID string) (string, error) {
 		src.Close()
 		return nil
 	})
+	if err == nil {
+		err = writeReplayBundle(zipWriter, runID)
+	}
 	if err != nil {
 		zipWriter.Close()
 		return "", err
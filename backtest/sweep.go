@@ -0,0 +1,933 @@
+package backtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/logger"
+	"nofx/store"
+)
+
+const (
+	sweepsRootDir = "backtest_sweeps"
+
+	maxSweepRuns            = 200
+	defaultSweepConcurrency = 2
+	maxSweepConcurrency     = 8
+
+	// SweepPhaseSweep is a plain sweep run over the full base range
+	SweepPhaseSweep = "sweep"
+	// SweepPhaseInSample is a walk-forward optimization run
+	SweepPhaseInSample = "in_sample"
+	// SweepPhaseOutOfSample replays the best in-sample variant on the following window
+	SweepPhaseOutOfSample = "out_of_sample"
+)
+
+// Metrics a sweep leaderboard can rank by; max_drawdown_pct ranks lower values first.
+const (
+	RankBySharpe       = "sharpe_ratio"
+	RankBySortino      = "sortino_ratio"
+	RankByCalmar       = "calmar_ratio"
+	RankByReturn       = "total_return_pct"
+	RankByProfitFactor = "profit_factor"
+	RankByWinRate      = "win_rate"
+	RankByDrawdown     = "max_drawdown_pct"
+)
+
+// SweepParams lists the values to try per parameter; the sweep runs their cartesian product.
+// Empty axes keep the base config value.
+type SweepParams struct {
+	Leverage             []int                `json:"leverage,omitempty"` // BTC/ETH and altcoin leverage (grid leverage for grid runs)
+	DecisionCadenceNBars []int                `json:"decision_cadence_nbars,omitempty"`
+	PromptVariant        []string             `json:"prompt_variant,omitempty"`
+	RiskControl          map[string][]float64 `json:"risk_control,omitempty"` // Keyed by RiskControlConfig JSON field, e.g. "min_confidence"
+	GridCount            []int                `json:"grid_count,omitempty"`
+	ATRMultiplier        []float64            `json:"atr_multiplier,omitempty"`
+}
+
+// WalkForwardConfig splits the base range into rolling in-sample / out-of-sample windows.
+// Every variant runs in-sample; the best one by RankBy is then run out-of-sample.
+type WalkForwardConfig struct {
+	InSampleDays    int `json:"in_sample_days"`
+	OutOfSampleDays int `json:"out_of_sample_days"`
+	StepDays        int `json:"step_days,omitempty"` // Defaults to OutOfSampleDays
+}
+
+// SweepSpec describes one sweep job submitted to the Manager.
+type SweepSpec struct {
+	SweepID        string             `json:"sweep_id"`
+	Base           BacktestConfig     `json:"base"`
+	Params         SweepParams        `json:"params"`
+	MaxConcurrency int                `json:"max_concurrency,omitempty"`
+	RankBy         string             `json:"rank_by,omitempty"`
+	WalkForward    *WalkForwardConfig `json:"walk_forward,omitempty"`
+}
+
+// SweepVariant is one point of the parameter grid. Zero values mean "use the base config".
+type SweepVariant struct {
+	ID                   string             `json:"id"`
+	Leverage             int                `json:"leverage,omitempty"`
+	DecisionCadenceNBars int                `json:"decision_cadence_nbars,omitempty"`
+	PromptVariant        string             `json:"prompt_variant,omitempty"`
+	RiskControl          map[string]float64 `json:"risk_control,omitempty"`
+	GridCount            int                `json:"grid_count,omitempty"`
+	ATRMultiplier        float64            `json:"atr_multiplier,omitempty"`
+}
+
+// SweepRun tracks one backtest run started by a sweep.
+type SweepRun struct {
+	RunID     string   `json:"run_id"`
+	VariantID string   `json:"variant_id,omitempty"` // Empty for out-of-sample runs until a variant is selected
+	Phase     string   `json:"phase"`
+	Window    int      `json:"window,omitempty"` // 1-based walk-forward window
+	StartTS   int64    `json:"start_ts"`
+	EndTS     int64    `json:"end_ts"`
+	State     RunState `json:"state"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// SweepStatus is the persisted state of a sweep job.
+type SweepStatus struct {
+	SweepID   string         `json:"sweep_id"`
+	UserID    string         `json:"user_id,omitempty"`
+	State     RunState       `json:"state"`
+	RankBy    string         `json:"rank_by"`
+	Spec      SweepSpec      `json:"spec"`
+	Variants  []SweepVariant `json:"variants"`
+	Runs      []SweepRun     `json:"runs"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// LeaderboardEntry ranks one variant; walk-forward metrics are averaged over in-sample windows.
+type LeaderboardEntry struct {
+	Rank    int          `json:"rank"`
+	Variant SweepVariant `json:"variant"`
+	RunIDs  []string     `json:"run_ids"`
+	Score   float64      `json:"score"`
+	Metrics Metrics      `json:"metrics"`
+}
+
+// WalkForwardWindow summarizes one walk-forward window.
+type WalkForwardWindow struct {
+	Window          int      `json:"window"`
+	InSampleStart   int64    `json:"in_sample_start"`
+	InSampleEnd     int64    `json:"in_sample_end"`
+	OutOfSampleEnd  int64    `json:"out_of_sample_end"`
+	BestVariantID   string   `json:"best_variant_id,omitempty"`
+	OutOfSampleRun  string   `json:"out_of_sample_run_id"`
+	OutOfSampleInfo *Metrics `json:"out_of_sample_metrics,omitempty"`
+}
+
+// Leaderboard is the aggregated ranking of a sweep.
+type Leaderboard struct {
+	SweepID     string              `json:"sweep_id"`
+	State       RunState            `json:"state"`
+	RankBy      string              `json:"rank_by"`
+	Entries     []LeaderboardEntry  `json:"entries"`
+	Windows     []WalkForwardWindow `json:"windows,omitempty"`
+	OutOfSample *Metrics            `json:"out_of_sample,omitempty"` // Chained out-of-sample performance
+}
+
+type sweepJob struct {
+	mu     sync.Mutex
+	status *SweepStatus
+	spec   SweepSpec
+	cancel context.CancelFunc
+}
+
+func sweepStatusPath(sweepID string) string {
+	return filepath.Join(sweepsRootDir, sweepID, "sweep.json")
+}
+
+// Validate checks the spec and fills in defaults. The base config is validated on a copy.
+func (spec *SweepSpec) Validate() error {
+	if spec == nil {
+		return fmt.Errorf("sweep spec is nil")
+	}
+	spec.SweepID = strings.TrimSpace(spec.SweepID)
+	if err := validateID("sweep_id", spec.SweepID); err != nil {
+		return err
+	}
+
+	spec.Base.RunID = spec.SweepID
+	base := spec.Base.clone()
+	if err := base.Validate(); err != nil {
+		return fmt.Errorf("invalid base config: %w", err)
+	}
+
+	if spec.MaxConcurrency <= 0 {
+		spec.MaxConcurrency = defaultSweepConcurrency
+	}
+	if spec.MaxConcurrency > maxSweepConcurrency {
+		spec.MaxConcurrency = maxSweepConcurrency
+	}
+
+	spec.RankBy = strings.ToLower(strings.TrimSpace(spec.RankBy))
+	if spec.RankBy == "" {
+		spec.RankBy = RankBySharpe
+	}
+	switch spec.RankBy {
+	case RankBySharpe, RankBySortino, RankByCalmar, RankByReturn, RankByProfitFactor, RankByWinRate, RankByDrawdown:
+	default:
+		return fmt.Errorf("unsupported rank_by '%s'", spec.RankBy)
+	}
+
+	p := spec.Params
+	if base.IsGrid() {
+		if len(p.DecisionCadenceNBars) > 0 || len(p.PromptVariant) > 0 || len(p.RiskControl) > 0 {
+			return fmt.Errorf("decision_cadence_nbars, prompt_variant and risk_control only apply to AI runs")
+		}
+	} else if len(p.GridCount) > 0 || len(p.ATRMultiplier) > 0 {
+		return fmt.Errorf("grid_count and atr_multiplier require a grid_config")
+	}
+	for _, v := range p.Leverage {
+		if v <= 0 {
+			return fmt.Errorf("leverage values must be positive")
+		}
+	}
+	for _, v := range p.DecisionCadenceNBars {
+		if v <= 0 {
+			return fmt.Errorf("decision_cadence_nbars values must be positive")
+		}
+	}
+	for _, v := range p.GridCount {
+		if v < 2 {
+			return fmt.Errorf("grid_count values must be at least 2")
+		}
+	}
+	for _, v := range p.ATRMultiplier {
+		if v <= 0 {
+			return fmt.Errorf("atr_multiplier values must be positive")
+		}
+	}
+	for field, values := range p.RiskControl {
+		if len(values) == 0 {
+			return fmt.Errorf("risk_control.%s has no values", field)
+		}
+	}
+
+	if wf := spec.WalkForward; wf != nil {
+		if wf.InSampleDays <= 0 || wf.OutOfSampleDays <= 0 {
+			return fmt.Errorf("walk_forward in_sample_days and out_of_sample_days must be positive")
+		}
+		if wf.StepDays <= 0 {
+			wf.StepDays = wf.OutOfSampleDays
+		}
+		if len(spec.windows()) == 0 {
+			return fmt.Errorf("backtest range is shorter than one walk-forward window")
+		}
+	}
+	return nil
+}
+
+// Expand returns the cartesian product of the sweep params.
+func (spec *SweepSpec) Expand() []SweepVariant {
+	p := spec.Params
+	type axis struct {
+		n   int
+		set func(v *SweepVariant, i int)
+	}
+	axes := []axis{
+		{len(p.Leverage), func(v *SweepVariant, i int) { v.Leverage = p.Leverage[i] }},
+		{len(p.DecisionCadenceNBars), func(v *SweepVariant, i int) { v.DecisionCadenceNBars = p.DecisionCadenceNBars[i] }},
+		{len(p.PromptVariant), func(v *SweepVariant, i int) { v.PromptVariant = strings.TrimSpace(p.PromptVariant[i]) }},
+		{len(p.GridCount), func(v *SweepVariant, i int) { v.GridCount = p.GridCount[i] }},
+		{len(p.ATRMultiplier), func(v *SweepVariant, i int) { v.ATRMultiplier = p.ATRMultiplier[i] }},
+	}
+	fields := make([]string, 0, len(p.RiskControl))
+	for field := range p.RiskControl {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		field, values := field, p.RiskControl[field]
+		axes = append(axes, axis{len(values), func(v *SweepVariant, i int) {
+			if v.RiskControl == nil {
+				v.RiskControl = make(map[string]float64)
+			}
+			v.RiskControl[field] = values[i]
+		}})
+	}
+
+	variants := []SweepVariant{{}}
+	for _, ax := range axes {
+		if ax.n == 0 {
+			continue
+		}
+		next := make([]SweepVariant, 0, len(variants)*ax.n)
+		for _, v := range variants {
+			for i := 0; i < ax.n; i++ {
+				c := v
+				if v.RiskControl != nil {
+					c.RiskControl = make(map[string]float64, len(v.RiskControl))
+					for k, val := range v.RiskControl {
+						c.RiskControl[k] = val
+					}
+				}
+				ax.set(&c, i)
+				next = append(next, c)
+			}
+		}
+		variants = next
+	}
+	for i := range variants {
+		variants[i].ID = fmt.Sprintf("v%02d", i+1)
+	}
+	return variants
+}
+
+type sweepWindow struct {
+	start, split, end int64
+}
+
+func (spec *SweepSpec) windows() []sweepWindow {
+	wf := spec.WalkForward
+	if wf == nil {
+		return nil
+	}
+	day := int64(24 * time.Hour / time.Second)
+	is, oos, step := int64(wf.InSampleDays)*day, int64(wf.OutOfSampleDays)*day, int64(wf.StepDays)*day
+	var windows []sweepWindow
+	for start := spec.Base.StartTS; start+is+oos <= spec.Base.EndTS; start += step {
+		windows = append(windows, sweepWindow{start: start, split: start + is, end: start + is + oos})
+	}
+	return windows
+}
+
+// Label describes the variant's overrides for run labels.
+func (v SweepVariant) Label() string {
+	parts := make([]string, 0, 6)
+	if v.Leverage > 0 {
+		parts = append(parts, fmt.Sprintf("lev=%d", v.Leverage))
+	}
+	if v.DecisionCadenceNBars > 0 {
+		parts = append(parts, fmt.Sprintf("cadence=%d", v.DecisionCadenceNBars))
+	}
+	if v.PromptVariant != "" {
+		parts = append(parts, "prompt="+v.PromptVariant)
+	}
+	if v.GridCount > 0 {
+		parts = append(parts, fmt.Sprintf("grids=%d", v.GridCount))
+	}
+	if v.ATRMultiplier > 0 {
+		parts = append(parts, fmt.Sprintf("atr=%g", v.ATRMultiplier))
+	}
+	fields := make([]string, 0, len(v.RiskControl))
+	for field := range v.RiskControl {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		parts = append(parts, fmt.Sprintf("%s=%g", field, v.RiskControl[field]))
+	}
+	if len(parts) == 0 {
+		return v.ID + " base"
+	}
+	return v.ID + " " + strings.Join(parts, " ")
+}
+
+// clone copies the config deeply enough that Validate and variant overrides don't leak between runs.
+func (cfg BacktestConfig) clone() BacktestConfig {
+	out := cfg
+	out.Symbols = append([]string(nil), cfg.Symbols...)
+	out.Timeframes = append([]string(nil), cfg.Timeframes...)
+	if cfg.GridConfig != nil {
+		grid := *cfg.GridConfig
+		out.GridConfig = &grid
+	}
+	if cfg.RiskControl != nil {
+		rc := *cfg.RiskControl
+		out.RiskControl = &rc
+	}
+	return out
+}
+
+// variantConfig builds the validated run config for a variant over [startTS, endTS).
+func (spec *SweepSpec) variantConfig(v SweepVariant, runID string, startTS, endTS int64) (BacktestConfig, error) {
+	cfg := spec.Base.clone()
+	cfg.RunID = runID
+	cfg.StartTS = startTS
+	cfg.EndTS = endTS
+
+	if v.Leverage > 0 {
+		cfg.Leverage = LeverageConfig{BTCETHLeverage: v.Leverage, AltcoinLeverage: v.Leverage}
+		if cfg.GridConfig != nil {
+			cfg.GridConfig.Leverage = v.Leverage
+		}
+	}
+	if v.DecisionCadenceNBars > 0 {
+		cfg.DecisionCadenceNBars = v.DecisionCadenceNBars
+	}
+	if v.PromptVariant != "" {
+		cfg.PromptVariant = v.PromptVariant
+	}
+	if cfg.GridConfig != nil {
+		if v.GridCount > 0 {
+			cfg.GridConfig.GridCount = v.GridCount
+		}
+		if v.ATRMultiplier > 0 {
+			cfg.GridConfig.ATRMultiplier = v.ATRMultiplier
+			cfg.GridConfig.UseATRBounds = true
+		}
+	}
+	if len(v.RiskControl) > 0 {
+		rc, err := overrideRiskControl(cfg.ToStrategyConfig().RiskControl, v.RiskControl)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.RiskControl = &rc
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("variant %s: %w", v.ID, err)
+	}
+	if !cfg.IsGrid() && !cfg.IsRules() {
+		// Variants with the same prompt inputs share one cache file so identical prompts are paid for once
+		cfg.CacheAI = true
+		cfg.SharedAICachePath = filepath.Join(sweepsRootDir, spec.SweepID, "ai_cache_"+promptFingerprint(&cfg)+".json")
+	}
+	return cfg, nil
+}
+
+// overrideRiskControl sets RiskControlConfig fields by their JSON names.
+func overrideRiskControl(base store.RiskControlConfig, overrides map[string]float64) (store.RiskControlConfig, error) {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return base, err
+	}
+	fields := make(map[string]any)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return base, err
+	}
+	for field, value := range overrides {
+		if _, ok := fields[field]; !ok {
+			return base, fmt.Errorf("unknown risk_control field '%s'", field)
+		}
+		fields[field] = value
+	}
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return base, err
+	}
+	var rc store.RiskControlConfig
+	if err := json.Unmarshal(data, &rc); err != nil {
+		return base, fmt.Errorf("invalid risk_control override: %w", err)
+	}
+	return rc, nil
+}
+
+// promptFingerprint hashes the settings that shape the system prompt. The AI cache key only covers
+// the market context, so runs whose prompts differ must not share a cache file.
+func promptFingerprint(cfg *BacktestConfig) string {
+	payload := struct {
+		StrategyID     string                  `json:"strategy_id"`
+		Model          string                  `json:"model"`
+		PromptVariant  string                  `json:"prompt_variant"`
+		PromptTemplate string                  `json:"prompt_template"`
+		CustomPrompt   string                  `json:"custom_prompt"`
+		Override       bool                    `json:"override_prompt"`
+		RiskControl    store.RiskControlConfig `json:"risk_control"`
+	}{
+		StrategyID:     cfg.StrategyID,
+		Model:          cfg.AIModelID + "/" + cfg.AICfg.Model,
+		PromptVariant:  cfg.PromptVariant,
+		PromptTemplate: cfg.PromptTemplate,
+		CustomPrompt:   cfg.CustomPrompt,
+		Override:       cfg.OverrideBasePrompt,
+		RiskControl:    cfg.ToStrategyConfig().RiskControl,
+	}
+	data, _ := json.Marshal(payload)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// StartSweep expands spec into backtest runs and executes them in the background
+// with at most spec.MaxConcurrency runs active at once.
+func (m *Manager) StartSweep(spec SweepSpec) (*SweepStatus, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(sweepStatusPath(spec.SweepID)); err == nil {
+		return nil, fmt.Errorf("sweep %s already exists", spec.SweepID)
+	}
+
+	variants := spec.Expand()
+	var runs []SweepRun
+	windows := spec.windows()
+	if spec.WalkForward == nil {
+		for _, v := range variants {
+			runs = append(runs, SweepRun{RunID: spec.SweepID + "-" + v.ID, VariantID: v.ID, Phase: SweepPhaseSweep,
+				StartTS: spec.Base.StartTS, EndTS: spec.Base.EndTS, State: RunStateCreated})
+		}
+	} else {
+		for i, w := range windows {
+			for _, v := range variants {
+				runs = append(runs, SweepRun{RunID: fmt.Sprintf("%s-w%02d-%s", spec.SweepID, i+1, v.ID), VariantID: v.ID,
+					Phase: SweepPhaseInSample, Window: i + 1, StartTS: w.start, EndTS: w.split, State: RunStateCreated})
+			}
+			runs = append(runs, SweepRun{RunID: fmt.Sprintf("%s-w%02d-oos", spec.SweepID, i+1),
+				Phase: SweepPhaseOutOfSample, Window: i + 1, StartTS: w.split, EndTS: w.end, State: RunStateCreated})
+		}
+	}
+	if len(runs) > maxSweepRuns {
+		return nil, fmt.Errorf("sweep expands to %d runs (max %d)", len(runs), maxSweepRuns)
+	}
+
+	// Build every config up front so bad overrides fail before anything runs
+	byID := make(map[string]SweepVariant, len(variants))
+	for _, v := range variants {
+		byID[v.ID] = v
+	}
+	for _, run := range runs {
+		if run.Phase == SweepPhaseOutOfSample {
+			continue
+		}
+		if _, err := spec.variantConfig(byID[run.VariantID], run.RunID, run.StartTS, run.EndTS); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	status := &SweepStatus{
+		SweepID:   spec.SweepID,
+		UserID:    spec.Base.UserID,
+		State:     RunStateRunning,
+		RankBy:    spec.RankBy,
+		Spec:      spec,
+		Variants:  variants,
+		Runs:      runs,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if status.UserID == "" {
+		status.UserID = "default"
+	}
+	status.Spec.Base.AICfg.APIKey = ""
+	status.Spec.Base.AICfg.SecretKey = ""
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &sweepJob{status: status, spec: spec, cancel: cancel}
+
+	m.mu.Lock()
+	if _, exists := m.sweeps[spec.SweepID]; exists {
+		m.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("sweep %s is already active", spec.SweepID)
+	}
+	m.sweeps[spec.SweepID] = job
+	m.mu.Unlock()
+
+	if err := job.save(); err != nil {
+		cancel()
+		m.mu.Lock()
+		delete(m.sweeps, spec.SweepID)
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	go m.runSweep(ctx, job, byID, windows)
+	return job.snapshot(), nil
+}
+
+func (m *Manager) runSweep(ctx context.Context, job *sweepJob, variants map[string]SweepVariant, windows []sweepWindow) {
+	defer job.cancel()
+	spec := job.spec
+	sem := make(chan struct{}, spec.MaxConcurrency)
+	runs := job.snapshot().Runs
+
+	var wg sync.WaitGroup
+	if spec.WalkForward == nil {
+		for i := range runs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				m.runSweepVariant(ctx, job, sem, i, variants[runs[i].VariantID])
+			}(i)
+		}
+	} else {
+		for w := range windows {
+			wg.Add(1)
+			go func(window int) {
+				defer wg.Done()
+				m.runWalkForwardWindow(ctx, job, sem, window, variants)
+			}(w + 1)
+		}
+	}
+	wg.Wait()
+
+	job.mu.Lock()
+	job.status.State = RunStateCompleted
+	if ctx.Err() != nil {
+		job.status.State = RunStateStopped
+	}
+	job.mu.Unlock()
+	if err := job.save(); err != nil {
+		logger.Infof("failed to save sweep %s: %v", spec.SweepID, err)
+	}
+
+	m.mu.Lock()
+	delete(m.sweeps, spec.SweepID)
+	m.mu.Unlock()
+}
+
+func (m *Manager) runWalkForwardWindow(ctx context.Context, job *sweepJob, sem chan struct{}, window int, variants map[string]SweepVariant) {
+	runs := job.snapshot().Runs
+	oos := -1
+	var wg sync.WaitGroup
+	for i, run := range runs {
+		if run.Window != window {
+			continue
+		}
+		if run.Phase == SweepPhaseOutOfSample {
+			oos = i
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.runSweepVariant(ctx, job, sem, i, variants[runs[i].VariantID])
+		}(i)
+	}
+	wg.Wait()
+	if oos < 0 || ctx.Err() != nil {
+		return
+	}
+
+	inSample := make([]SweepRun, 0, len(variants))
+	for _, run := range job.snapshot().Runs {
+		if run.Window == window && run.Phase == SweepPhaseInSample {
+			inSample = append(inSample, run)
+		}
+	}
+	best := ""
+	if entries := rankSweepRuns(inSample, variants, job.spec.RankBy); len(entries) > 0 {
+		best = entries[0].Variant.ID
+	}
+	if best == "" {
+		job.updateRun(oos, func(run *SweepRun) {
+			run.State = RunStateFailed
+			run.Error = "no in-sample run produced metrics"
+		})
+		return
+	}
+	job.updateRun(oos, func(run *SweepRun) { run.VariantID = best })
+	m.runSweepVariant(ctx, job, sem, oos, variants[best])
+}
+
+// runSweepVariant runs one sweep run to completion, holding a slot of sem while active.
+func (m *Manager) runSweepVariant(ctx context.Context, job *sweepJob, sem chan struct{}, idx int, variant SweepVariant) {
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		job.updateRun(idx, func(run *SweepRun) { run.State = RunStateStopped })
+		return
+	}
+	defer func() { <-sem }()
+
+	run := job.snapshot().Runs[idx]
+	cfg, err := job.spec.variantConfig(variant, run.RunID, run.StartTS, run.EndTS)
+	if err == nil {
+		var runner *Runner
+		runner, err = m.Start(ctx, cfg)
+		if err == nil {
+			job.updateRun(idx, func(run *SweepRun) { run.State = RunStateRunning })
+			label := fmt.Sprintf("%s %s", job.spec.SweepID, variant.Label())
+			if run.Phase != SweepPhaseSweep {
+				label = fmt.Sprintf("%s w%02d %s %s", job.spec.SweepID, run.Window, run.Phase, variant.Label())
+			}
+			if _, err := m.UpdateLabel(run.RunID, label); err != nil {
+				logger.Infof("failed to label sweep run %s: %v", run.RunID, err)
+			}
+			waitErr := runner.Wait()
+			state := runner.Status()
+			job.updateRun(idx, func(run *SweepRun) {
+				run.State = state
+				if waitErr != nil {
+					run.Error = waitErr.Error()
+				}
+			})
+			return
+		}
+	}
+	logger.Infof("sweep %s: run %s failed to start: %v", job.spec.SweepID, run.RunID, err)
+	job.updateRun(idx, func(run *SweepRun) {
+		run.State = RunStateFailed
+		run.Error = err.Error()
+	})
+}
+
+func (job *sweepJob) snapshot() *SweepStatus {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	status := *job.status
+	status.Runs = append([]SweepRun(nil), job.status.Runs...)
+	return &status
+}
+
+func (job *sweepJob) updateRun(idx int, fn func(run *SweepRun)) {
+	job.mu.Lock()
+	fn(&job.status.Runs[idx])
+	job.mu.Unlock()
+	if err := job.save(); err != nil {
+		logger.Infof("failed to save sweep %s: %v", job.spec.SweepID, err)
+	}
+}
+
+func (job *sweepJob) save() error {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.status.UpdatedAt = time.Now().UTC()
+	path := sweepStatusPath(job.status.SweepID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeJSONAtomic(path, job.status)
+}
+
+// StopSweep cancels an active sweep; runs already started are stopped through their context.
+func (m *Manager) StopSweep(sweepID string) error {
+	m.mu.RLock()
+	job, ok := m.sweeps[sweepID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("sweep %s is not active", sweepID)
+	}
+	job.cancel()
+	return nil
+}
+
+// GetSweep returns the current status of a sweep, active or persisted.
+func (m *Manager) GetSweep(sweepID string) (*SweepStatus, error) {
+	m.mu.RLock()
+	job, ok := m.sweeps[sweepID]
+	m.mu.RUnlock()
+	if ok {
+		return job.snapshot(), nil
+	}
+	data, err := os.ReadFile(sweepStatusPath(sweepID))
+	if err != nil {
+		return nil, err
+	}
+	var status SweepStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	if status.State == RunStateRunning {
+		// Interrupted by a restart; sweeps are not resumed automatically
+		status.State = RunStateStopped
+	}
+	return &status, nil
+}
+
+// ListSweeps returns all persisted sweeps, newest first.
+func (m *Manager) ListSweeps() ([]*SweepStatus, error) {
+	entries, err := os.ReadDir(sweepsRootDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*SweepStatus{}, nil
+		}
+		return nil, err
+	}
+	sweeps := make([]*SweepStatus, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		status, err := m.GetSweep(entry.Name())
+		if err != nil {
+			logger.Infof("skip sweep %s: %v", entry.Name(), err)
+			continue
+		}
+		sweeps = append(sweeps, status)
+	}
+	sort.Slice(sweeps, func(i, j int) bool {
+		return sweeps[i].CreatedAt.After(sweeps[j].CreatedAt)
+	})
+	return sweeps, nil
+}
+
+// SweepLeaderboard ranks the sweep's variants by the spec's rank_by metric.
+// Walk-forward sweeps also report each window's selection and the chained out-of-sample result.
+func (m *Manager) SweepLeaderboard(sweepID string) (*Leaderboard, error) {
+	status, err := m.GetSweep(sweepID)
+	if err != nil {
+		return nil, err
+	}
+	variants := make(map[string]SweepVariant, len(status.Variants))
+	for _, v := range status.Variants {
+		variants[v.ID] = v
+	}
+
+	ranked := make([]SweepRun, 0, len(status.Runs))
+	for _, run := range status.Runs {
+		if run.Phase != SweepPhaseOutOfSample {
+			ranked = append(ranked, run)
+		}
+	}
+	board := &Leaderboard{
+		SweepID: status.SweepID,
+		State:   status.State,
+		RankBy:  status.RankBy,
+		Entries: rankSweepRuns(ranked, variants, status.RankBy),
+	}
+
+	var oosMetrics []*Metrics
+	windows := status.Spec.windows()
+	for _, run := range status.Runs {
+		if run.Phase != SweepPhaseOutOfSample || run.Window > len(windows) {
+			continue
+		}
+		window := WalkForwardWindow{
+			Window:         run.Window,
+			InSampleStart:  windows[run.Window-1].start,
+			InSampleEnd:    run.StartTS,
+			OutOfSampleEnd: run.EndTS,
+			BestVariantID:  run.VariantID,
+			OutOfSampleRun: run.RunID,
+		}
+		if run.State == RunStateCompleted || run.State == RunStateLiquidated {
+			if metrics, err := LoadMetrics(run.RunID); err == nil {
+				window.OutOfSampleInfo = metrics
+				oosMetrics = append(oosMetrics, metrics)
+			}
+		}
+		board.Windows = append(board.Windows, window)
+	}
+	if len(oosMetrics) > 0 {
+		board.OutOfSample = chainMetrics(oosMetrics)
+	}
+	return board, nil
+}
+
+// rankSweepRuns groups finished runs by variant, averages their metrics and sorts best first.
+// Liquidated variants always rank below the rest.
+func rankSweepRuns(runs []SweepRun, variants map[string]SweepVariant, rankBy string) []LeaderboardEntry {
+	byVariant := make(map[string][]*Metrics)
+	runIDs := make(map[string][]string)
+	for _, run := range runs {
+		if run.State != RunStateCompleted && run.State != RunStateLiquidated {
+			continue
+		}
+		metrics, err := LoadMetrics(run.RunID)
+		if err != nil {
+			continue
+		}
+		byVariant[run.VariantID] = append(byVariant[run.VariantID], metrics)
+		runIDs[run.VariantID] = append(runIDs[run.VariantID], run.RunID)
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(byVariant))
+	for id, list := range byVariant {
+		metrics := averageMetrics(list)
+		entries = append(entries, LeaderboardEntry{
+			Variant: variants[id],
+			RunIDs:  runIDs[id],
+			Score:   rankScore(&metrics, rankBy),
+			Metrics: metrics,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Metrics.Liquidated != b.Metrics.Liquidated {
+			return !a.Metrics.Liquidated
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Variant.ID < b.Variant.ID
+	})
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+// rankScore maps a metric to a higher-is-better score.
+func rankScore(m *Metrics, rankBy string) float64 {
+	var score float64
+	switch rankBy {
+	case RankBySortino:
+		score = m.SortinoRatio
+	case RankByCalmar:
+		score = m.CalmarRatio
+	case RankByReturn:
+		score = m.TotalReturnPct
+	case RankByProfitFactor:
+		score = m.ProfitFactor
+	case RankByWinRate:
+		score = m.WinRate
+	case RankByDrawdown:
+		score = -m.MaxDrawdownPct
+	default:
+		score = m.SharpeRatio
+	}
+	if math.IsNaN(score) {
+		return math.Inf(-1)
+	}
+	return score
+}
+
+// averageMetrics averages ratios across runs; trade counts are summed.
+func averageMetrics(list []*Metrics) Metrics {
+	if len(list) == 1 {
+		return *list[0]
+	}
+	var out Metrics
+	for _, m := range list {
+		out.TotalReturnPct += m.TotalReturnPct
+		out.MaxDrawdownPct += m.MaxDrawdownPct
+		out.SharpeRatio += m.SharpeRatio
+		out.SortinoRatio += m.SortinoRatio
+		out.CalmarRatio += m.CalmarRatio
+		out.UlcerIndex += m.UlcerIndex
+		out.TimeInMarketPct += m.TimeInMarketPct
+		out.TotalFees += m.TotalFees
+		out.ProfitFactor += m.ProfitFactor
+		out.WinRate += m.WinRate
+		out.AvgWin += m.AvgWin
+		out.AvgLoss += m.AvgLoss
+		out.Trades += m.Trades
+		out.Liquidated = out.Liquidated || m.Liquidated
+	}
+	n := float64(len(list))
+	out.TotalReturnPct /= n
+	out.MaxDrawdownPct /= n
+	out.SharpeRatio /= n
+	out.SortinoRatio /= n
+	out.CalmarRatio /= n
+	out.UlcerIndex /= n
+	out.TimeInMarketPct /= n
+	out.TotalFees /= n
+	out.ProfitFactor /= n
+	out.WinRate /= n
+	out.AvgWin /= n
+	out.AvgLoss /= n
+	return out
+}
+
+// chainMetrics combines consecutive out-of-sample windows: returns compound,
+// drawdown is the worst window and the rest are averaged.
+func chainMetrics(list []*Metrics) *Metrics {
+	out := averageMetrics(list)
+	growth := 1.0
+	worst := 0.0
+	for _, m := range list {
+		growth *= 1 + m.TotalReturnPct/100
+		worst = math.Max(worst, m.MaxDrawdownPct)
+	}
+	out.TotalReturnPct = (growth - 1) * 100
+	out.MaxDrawdownPct = worst
+	return &out
+}
@@ -0,0 +1,183 @@
+package backtest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSweepExpand(t *testing.T) {
+	tests := []struct {
+		name   string
+		params SweepParams
+		want   int
+	}{
+		{"no params", SweepParams{}, 1},
+		{"one axis", SweepParams{Leverage: []int{2, 3, 5}}, 3},
+		{"two axes", SweepParams{Leverage: []int{2, 3}, DecisionCadenceNBars: []int{1, 2, 4}}, 6},
+		{"risk control", SweepParams{
+			Leverage: []int{2, 3},
+			RiskControl: map[string][]float64{
+				"max_margin_usage":      {0.5, 0.8},
+				"min_risk_reward_ratio": {1, 2, 3},
+			},
+		}, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := SweepSpec{Params: tt.params}
+			variants := spec.Expand()
+			if len(variants) != tt.want {
+				t.Fatalf("expanded %d variants, want %d", len(variants), tt.want)
+			}
+			ids := make(map[string]bool)
+			for i, v := range variants {
+				if ids[v.ID] {
+					t.Errorf("duplicate variant ID %s", v.ID)
+				}
+				ids[v.ID] = true
+				for j := 0; j < i; j++ {
+					other := variants[j]
+					other.ID = v.ID
+					if reflect.DeepEqual(v, other) {
+						t.Errorf("variants %s and %s have the same overrides", variants[j].ID, v.ID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSweepWalkForwardWindows(t *testing.T) {
+	day := int64(24 * time.Hour / time.Second)
+	tests := []struct {
+		name         string
+		rangeDays    int64
+		wf           WalkForwardConfig
+		want         int
+		overlapTests bool // Out-of-sample windows may overlap when stepping less than their length
+	}{
+		{"step equals out-of-sample", 30, WalkForwardConfig{InSampleDays: 10, OutOfSampleDays: 5, StepDays: 5}, 4, false},
+		{"step longer than out-of-sample", 30, WalkForwardConfig{InSampleDays: 10, OutOfSampleDays: 5, StepDays: 10}, 2, false},
+		{"step shorter than out-of-sample", 30, WalkForwardConfig{InSampleDays: 10, OutOfSampleDays: 10, StepDays: 5}, 3, true},
+		{"range shorter than one window", 10, WalkForwardConfig{InSampleDays: 10, OutOfSampleDays: 5, StepDays: 5}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wf := tt.wf
+			spec := SweepSpec{
+				Base:        BacktestConfig{StartTS: 1_700_000_000, EndTS: 1_700_000_000 + tt.rangeDays*day},
+				WalkForward: &wf,
+			}
+			windows := spec.windows()
+			if len(windows) != tt.want {
+				t.Fatalf("got %d windows, want %d", len(windows), tt.want)
+			}
+			for i, w := range windows {
+				if w.start < spec.Base.StartTS || w.end > spec.Base.EndTS {
+					t.Errorf("window %d [%d, %d) outside the backtest range", i, w.start, w.end)
+				}
+				// Train on [start, split), test on [split, end)
+				if w.split-w.start != int64(wf.InSampleDays)*day || w.end-w.split != int64(wf.OutOfSampleDays)*day {
+					t.Errorf("window %d has train %d and test %d seconds", i, w.split-w.start, w.end-w.split)
+				}
+				if i == 0 {
+					continue
+				}
+				prev := windows[i-1]
+				if w.start-prev.start != int64(wf.StepDays)*day {
+					t.Errorf("window %d starts %d seconds after the previous one", i, w.start-prev.start)
+				}
+				if !tt.overlapTests && w.split < prev.end {
+					t.Errorf("test window %d [%d, %d) overlaps test window %d [%d, %d)", i, w.split, w.end, i-1, prev.split, prev.end)
+				}
+			}
+		})
+	}
+}
+
+func TestRankSweepRuns(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	metrics := map[string]*Metrics{
+		"s-v01":    {SharpeRatio: 1.0, MaxDrawdownPct: 20},
+		"s-v02-w1": {SharpeRatio: 2.0, MaxDrawdownPct: 10},
+		"s-v02-w2": {SharpeRatio: 1.0, MaxDrawdownPct: 30},
+		"s-v03":    {SharpeRatio: 3.0, MaxDrawdownPct: 5, Liquidated: true},
+		"s-v04":    {SharpeRatio: 1.5, MaxDrawdownPct: 15},
+		"s-v05":    {SharpeRatio: 9.0, MaxDrawdownPct: 1},
+	}
+	for runID, m := range metrics {
+		if err := saveMetrics(runID, m); err != nil {
+			t.Fatalf("saveMetrics(%s): %v", runID, err)
+		}
+	}
+	runs := []SweepRun{
+		{RunID: "s-v01", VariantID: "v01", State: RunStateCompleted},
+		{RunID: "s-v02-w1", VariantID: "v02", State: RunStateCompleted},
+		{RunID: "s-v02-w2", VariantID: "v02", State: RunStateCompleted},
+		{RunID: "s-v03", VariantID: "v03", State: RunStateLiquidated},
+		{RunID: "s-v04", VariantID: "v04", State: RunStateCompleted},
+		{RunID: "s-v05", VariantID: "v05", State: RunStateRunning}, // Unfinished runs are not ranked
+	}
+	variants := make(map[string]SweepVariant)
+	for _, id := range []string{"v01", "v02", "v03", "v04", "v05"} {
+		variants[id] = SweepVariant{ID: id}
+	}
+
+	tests := []struct {
+		rankBy string
+		want   []string
+	}{
+		// v02 averages to 1.5 and ties with v04, broken by variant ID; v03 is liquidated
+		{RankBySharpe, []string{"v02", "v04", "v01", "v03"}},
+		{RankByDrawdown, []string{"v04", "v01", "v02", "v03"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rankBy, func(t *testing.T) {
+			entries := rankSweepRuns(runs, variants, tt.rankBy)
+			var got []string
+			for i, e := range entries {
+				if e.Rank != i+1 {
+					t.Errorf("entry %d has rank %d", i, e.Rank)
+				}
+				got = append(got, e.Variant.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ranked %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	entries := rankSweepRuns(runs, variants, RankBySharpe)
+	if ids := entries[0].RunIDs; !reflect.DeepEqual(ids, []string{"s-v02-w1", "s-v02-w2"}) {
+		t.Errorf("v02 run IDs = %v", ids)
+	}
+	if dd := entries[0].Metrics.MaxDrawdownPct; dd != 20 {
+		t.Errorf("v02 averaged drawdown = %v, want 20", dd)
+	}
+}
+
+func TestRunIDValidation(t *testing.T) {
+	tests := []struct {
+		id           string
+		newRun, load bool
+	}{
+		{"run-1", true, true},
+		{"bt_20240101.v2", true, true},
+		{"legacy run 2024-01-01 12:00", false, true},
+		{"", false, false},
+		{"..", false, false},
+		{"../etc", false, false},
+		{`a\b`, false, false},
+		{"-flag", false, true},
+	}
+	for _, tt := range tests {
+		if err := validateID("run_id", tt.id); (err == nil) != tt.newRun {
+			t.Errorf("validateID(%q) = %v, want ok=%v", tt.id, err, tt.newRun)
+		}
+		if err := checkStoredID("run_id", tt.id); (err == nil) != tt.load {
+			t.Errorf("checkStoredID(%q) = %v, want ok=%v", tt.id, err, tt.load)
+		}
+	}
+}
@@ -17,15 +17,26 @@ const (
 
 // PositionSnapshot represents core position data for backtest state and persistence.
 type PositionSnapshot struct {
-	Symbol           string  `json:"symbol"`
-	Side             string  `json:"side"`
-	Quantity         float64 `json:"quantity"`
-	AvgPrice         float64 `json:"avg_price"`
-	Leverage         int     `json:"leverage"`
-	LiquidationPrice float64 `json:"liquidation_price"`
-	MarginUsed       float64 `json:"margin_used"`
-	OpenTime         int64   `json:"open_time"`
-	AccumulatedFee   float64 `json:"accumulated_fee,omitempty"` // Opening fees accumulated
+	Symbol           string            `json:"symbol"`
+	Side             string            `json:"side"`
+	Quantity         float64           `json:"quantity"`
+	AvgPrice         float64           `json:"avg_price"`
+	Leverage         int               `json:"leverage"`
+	LiquidationPrice float64           `json:"liquidation_price"`
+	MarginUsed       float64           `json:"margin_used"`
+	OpenTime         int64             `json:"open_time"`
+	AccumulatedFee   float64           `json:"accumulated_fee,omitempty"` // Opening fees accumulated
+	StopLoss         float64           `json:"stop_loss,omitempty"`
+	TakeProfit       float64           `json:"take_profit,omitempty"`
+	TrailingStopPct  float64           `json:"trailing_stop_pct,omitempty"`
+	BestPrice        float64           `json:"best_price,omitempty"` // Best price since the trailing stop was set
+	TakeProfitLevels []TakeProfitLevel `json:"take_profit_levels,omitempty"`
+}
+
+// TakeProfitLevel partial take-profit of a position, part of a take-profit ladder
+type TakeProfitLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"` // Quantity still to close at Price
 }
 
 // BacktestState represents the real-time state during execution (in-memory state).
@@ -74,11 +85,13 @@ type TradeEvent struct {
 	Cycle           int     `json:"cycle"`
 	PositionAfter   float64 `json:"position_after"`
 	LiquidationFlag bool    `json:"liquidation"`
+	CloseReason     string  `json:"close_reason,omitempty"` // signal / stop_loss / take_profit / liquidation
 	Note            string  `json:"note,omitempty"`
 }
 
 // Metrics summarizes backtest performance metrics.
 type Metrics struct {
+	Version        int                      `json:"version,omitempty"`
 	TotalReturnPct float64                  `json:"total_return_pct"`
 	MaxDrawdownPct float64                  `json:"max_drawdown_pct"`
 	SharpeRatio    float64                  `json:"sharpe_ratio"`
@@ -90,10 +103,32 @@ type Metrics struct {
 	BestSymbol     string                   `json:"best_symbol"`
 	WorstSymbol    string                   `json:"worst_symbol"`
 	SymbolStats    map[string]SymbolMetrics `json:"symbol_stats"`
+	SideStats      map[string]SymbolMetrics `json:"side_stats,omitempty"` // Keyed by "long" / "short"
 	Liquidated     bool                     `json:"liquidated"`
+
+	// Risk-adjusted returns
+	AnnualizedReturnPct  float64 `json:"annualized_return_pct"`
+	SortinoRatio         float64 `json:"sortino_ratio"`
+	CalmarRatio          float64 `json:"calmar_ratio"`
+	UlcerIndex           float64 `json:"ulcer_index"`
+	LongestDrawdownHours float64 `json:"longest_drawdown_hours"`
+
+	// Exposure (positions marked at their last fill price)
+	TimeInMarketPct float64 `json:"time_in_market_pct"`
+	AvgExposurePct  float64 `json:"avg_exposure_pct"` // Gross notional / equity while in market
+	AvgLeverage     float64 `json:"avg_leverage"`
+
+	// Trading costs; drag is relative to the initial balance
+	TotalFees       float64 `json:"total_fees"`
+	TotalSlippage   float64 `json:"total_slippage"`
+	FeeDragPct      float64 `json:"fee_drag_pct"`
+	SlippageDragPct float64 `json:"slippage_drag_pct"`
+
+	MonthlyReturns []MonthlyReturn   `json:"monthly_returns,omitempty"`
+	Benchmark      *BenchmarkMetrics `json:"benchmark,omitempty"`
 }
 
-// SymbolMetrics records performance for a single symbol.
+// SymbolMetrics records performance for a single symbol (or side, in SideStats).
 type SymbolMetrics struct {
 	TotalTrades   int     `json:"total_trades"`
 	WinningTrades int     `json:"winning_trades"`
@@ -101,6 +136,27 @@ type SymbolMetrics struct {
 	TotalPnL      float64 `json:"total_pnl"`
 	AvgPnL        float64 `json:"avg_pnl"`
 	WinRate       float64 `json:"win_rate"`
+	LongPnL       float64 `json:"long_pnl"`
+	ShortPnL      float64 `json:"short_pnl"`
+	Fees          float64 `json:"fees"`
+}
+
+// MonthlyReturn is one row of the monthly returns table (UTC calendar months).
+type MonthlyReturn struct {
+	Month       string  `json:"month"` // YYYY-MM
+	StartEquity float64 `json:"start_equity"`
+	EndEquity   float64 `json:"end_equity"`
+	ReturnPct   float64 `json:"return_pct"`
+}
+
+// BenchmarkMetrics compares the run with buying and holding the benchmark over the same window.
+type BenchmarkMetrics struct {
+	Symbol          string  `json:"symbol"`
+	StartPrice      float64 `json:"start_price"`
+	EndPrice        float64 `json:"end_price"`
+	ReturnPct       float64 `json:"return_pct"`
+	MaxDrawdownPct  float64 `json:"max_drawdown_pct"`
+	ExcessReturnPct float64 `json:"excess_return_pct"` // Run return minus benchmark return
 }
 
 // Checkpoint represents checkpoint information saved to disk for pause, resume, and crash recovery.
@@ -121,6 +177,7 @@ type Checkpoint struct {
 	AICacheRef      string                    `json:"ai_cache_ref,omitempty"`
 	Liquidated      bool                      `json:"liquidated"`
 	LiquidationNote string                    `json:"liquidation_note,omitempty"`
+	Grid            *GridCheckpoint           `json:"grid,omitempty"` // Set for grid_trading runs
 }
 
 // RunMetadata records the summary required for run.json.
@@ -0,0 +1,120 @@
+// Offline kline store tool
+// Usage:
+//
+//	go run ./cmd/klines import   -symbol=BTCUSDT -tf=1h -file=BTCUSDT-1h-2024-01.csv [-exchange=binance]
+//	go run ./cmd/klines gaps     -symbol=BTCUSDT -tf=1h -start=2024-01-01 -end=2024-06-01
+//	go run ./cmd/klines backfill -symbol=BTCUSDT -tf=1h -start=2024-01-01 -end=2024-06-01
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"nofx/market"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	dir := fs.String("dir", envOr("KLINE_STORE_DIR", "data/klines"), "Kline store directory")
+	exchange := fs.String("exchange", "binance", "Exchange key")
+	symbol := fs.String("symbol", "", "Symbol, e.g. BTCUSDT (required)")
+	tf := fs.String("tf", "", "Timeframe, e.g. 1h (required)")
+	file := fs.String("file", "", "CSV file to import")
+	start := fs.String("start", "", "Range start, YYYY-MM-DD (UTC)")
+	end := fs.String("end", "", "Range end, YYYY-MM-DD (UTC, default now)")
+	fs.Parse(os.Args[2:])
+
+	if *symbol == "" || *tf == "" {
+		usage()
+	}
+	klineStore := market.NewKlineStore(*dir, false)
+
+	switch cmd {
+	case "import":
+		if *file == "" {
+			fail(fmt.Errorf("-file is required"))
+		}
+		f, err := os.Open(*file)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		added, err := klineStore.ImportCSV(*exchange, *symbol, *tf, f)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("Imported %d new klines into %s\n", added, *dir)
+
+	case "gaps":
+		from, to := parseRange(*start, *end)
+		gaps, err := klineStore.Gaps(*exchange, *symbol, *tf, from, to)
+		if err != nil {
+			fail(err)
+		}
+		if len(gaps) == 0 {
+			fmt.Println("No gaps")
+			return
+		}
+		for _, gap := range gaps {
+			fmt.Printf("%s -> %s\n", gap.Start.UTC().Format(time.RFC3339), gap.End.UTC().Format(time.RFC3339))
+		}
+
+	case "backfill":
+		from, to := parseRange(*start, *end)
+		added, err := klineStore.Backfill(*symbol, *tf, from, to)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("Backfilled %d klines into %s\n", added, *dir)
+
+	default:
+		usage()
+	}
+}
+
+func parseRange(start, end string) (time.Time, time.Time) {
+	if start == "" {
+		fail(fmt.Errorf("-start is required"))
+	}
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		fail(fmt.Errorf("invalid -start: %w", err))
+	}
+	to := time.Now().UTC()
+	if end != "" {
+		if to, err = time.Parse("2006-01-02", end); err != nil {
+			fail(fmt.Errorf("invalid -end: %w", err))
+		}
+	}
+	return from, to
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func usage() {
+	fmt.Println("Usage: go run ./cmd/klines <import|gaps|backfill> -symbol=BTCUSDT -tf=1h [options]")
+	fmt.Println("Options:")
+	fmt.Println("  -dir        Kline store directory (default: $KLINE_STORE_DIR or data/klines)")
+	fmt.Println("  -exchange   Exchange key (default: binance)")
+	fmt.Println("  -file       CSV file to import (import)")
+	fmt.Println("  -start      Range start YYYY-MM-DD (gaps, backfill)")
+	fmt.Println("  -end        Range end YYYY-MM-DD, default now (gaps, backfill)")
+	os.Exit(1)
+}
+
+func fail(err error) {
+	fmt.Printf("ERROR: %v\n", err)
+	os.Exit(1)
+}
@@ -44,6 +44,10 @@ type Config struct {
 	AlpacaAPIKey    string // Alpaca API key for US stocks
 	AlpacaSecretKey string // Alpaca secret key
 	TwelveDataKey   string // TwelveData API key for forex & metals
+
+	// Offline kline store (local candle cache for backtests and market data fallback)
+	KlineStoreDir     string // Root directory, empty disables the store
+	KlineStoreOffline bool   // Serve cached candles only, never fetch missing ones
 }
 
 // Init initializes global configuration (from .env)
@@ -61,6 +65,8 @@ func Init() {
 		DBUser:    "postgres",
 		DBName:    "nofx",
 		DBSSLMode: "disable",
+		// Kline store defaults
+		KlineStoreDir: "data/klines",
 	}
 
 	// Load from environment variables
@@ -132,6 +138,19 @@ func Init() {
 		cfg.DBSSLMode = v
 	}
 
+	// Kline store: set KLINE_STORE_DIR=off to disable
+	if v := os.Getenv("KLINE_STORE_DIR"); v != "" {
+		switch strings.ToLower(v) {
+		case "off", "none":
+			cfg.KlineStoreDir = ""
+		default:
+			cfg.KlineStoreDir = v
+		}
+	}
+	if v := os.Getenv("KLINE_STORE_OFFLINE"); v != "" {
+		cfg.KlineStoreOffline = strings.ToLower(v) == "true"
+	}
+
 	global = cfg
 
 	// Initialize experience improvement (installation ID will be set after database init)
@@ -27,7 +27,7 @@ type DebateEngine struct {
 	debateStore   *store.DebateStore
 	strategyStore *store.StrategyStore
 	aiModelStore  *store.AIModelStore
-	clients       map[string]mcp.AIClient
+	clients       map[string]map[string]mcp.AIClient // session ID -> AI model ID -> client
 	clientsMu     sync.RWMutex
 
 	// Event callbacks for SSE streaming
@@ -45,7 +45,7 @@ func NewDebateEngine(debateStore *store.DebateStore, strategyStore *store.Strate
 		debateStore:   debateStore,
 		strategyStore: strategyStore,
 		aiModelStore:  aiModelStore,
-		clients:       make(map[string]mcp.AIClient),
+		clients:       make(map[string]map[string]mcp.AIClient),
 	}
 
 	// Cleanup stale running/voting debates on startup
@@ -70,11 +70,10 @@ func (e *DebateEngine) cleanupStaleDebates() {
 	}
 }
 
-// InitializeClients initializes AI clients for all participants
-func (e *DebateEngine) InitializeClients(participants []*store.DebateParticipant) error {
-	e.clientsMu.Lock()
-	defer e.clientsMu.Unlock()
-
+// InitializeClients initializes the AI clients of a session's participants. Every session gets
+// its own clients, so concurrent debates sharing an AI model bill usage to their own session.
+func (e *DebateEngine) InitializeClients(sessionID, userID string, participants []*store.DebateParticipant) error {
+	clients := make(map[string]mcp.AIClient, len(participants))
 	for _, p := range participants {
 		aiModel, err := e.aiModelStore.GetByID(p.AIModelID)
 		if err != nil {
@@ -97,19 +96,40 @@ func (e *DebateEngine) InitializeClients(participants []*store.DebateParticipant
 			client = mcp.NewGrokClient()
 		case "kimi":
 			client = mcp.NewKimiClient()
+		case "local":
+			client = mcp.NewLocalClient()
 		default:
 			client = mcp.New()
 		}
 
 		// Configure client (convert EncryptedString to string)
 		client.SetAPIKey(string(aiModel.APIKey), aiModel.CustomAPIURL, aiModel.CustomModelName)
+		mcp.AttributeUsage(client, mcp.UsageAttribution{UserID: userID, Source: mcp.UsageSourceDebate, SourceID: sessionID})
 
-		e.clients[p.AIModelID] = client
+		clients[p.AIModelID] = client
 	}
 
+	e.clientsMu.Lock()
+	e.clients[sessionID] = clients
+	e.clientsMu.Unlock()
 	return nil
 }
 
+// client returns the AI client of a participant in a session
+func (e *DebateEngine) client(sessionID, aiModelID string) (mcp.AIClient, bool) {
+	e.clientsMu.RLock()
+	defer e.clientsMu.RUnlock()
+	client, ok := e.clients[sessionID][aiModelID]
+	return client, ok
+}
+
+// releaseClients drops the AI clients of a finished session
+func (e *DebateEngine) releaseClients(sessionID string) {
+	e.clientsMu.Lock()
+	delete(e.clients, sessionID)
+	e.clientsMu.Unlock()
+}
+
 // StartDebate starts a debate session with strategy-based market data
 func (e *DebateEngine) StartDebate(sessionID string) error {
 	// Get session with details
@@ -127,23 +147,26 @@ func (e *DebateEngine) StartDebate(sessionID string) error {
 	}
 
 	// Initialize AI clients
-	if err := e.InitializeClients(session.Participants); err != nil {
+	if err := e.InitializeClients(sessionID, session.UserID, session.Participants); err != nil {
 		return fmt.Errorf("failed to initialize clients: %w", err)
 	}
 
 	// Get strategy config
 	strategy, err := e.strategyStore.Get(session.UserID, session.StrategyID)
 	if err != nil {
+		e.releaseClients(sessionID)
 		return fmt.Errorf("failed to get strategy: %w", err)
 	}
 
 	strategyConfig, err := strategy.ParseConfig()
 	if err != nil {
+		e.releaseClients(sessionID)
 		return fmt.Errorf("failed to parse strategy config: %w", err)
 	}
 
 	// Update status to running
 	if err := e.debateStore.UpdateSessionStatus(sessionID, store.DebateStatusRunning); err != nil {
+		e.releaseClients(sessionID)
 		return fmt.Errorf("failed to update status: %w", err)
 	}
 
@@ -155,6 +178,7 @@ func (e *DebateEngine) StartDebate(sessionID string) error {
 
 // runDebate runs the actual debate rounds
 func (e *DebateEngine) runDebate(session *store.DebateSessionWithDetails, strategyConfig *store.StrategyConfig) {
+	defer e.releaseClients(session.ID)
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Errorf("Debate panic recovered: %v", r)
@@ -470,9 +494,7 @@ func (e *DebateEngine) getParticipantResponse(
 	systemPrompt, userPrompt string,
 	round int,
 ) (*store.DebateMessage, error) {
-	e.clientsMu.RLock()
-	client, ok := e.clients[participant.AIModelID]
-	e.clientsMu.RUnlock()
+	client, ok := e.client(session.ID, participant.AIModelID)
 
 	if !ok {
 		return nil, fmt.Errorf("client not found for %s", participant.AIModelID)
@@ -581,9 +603,7 @@ func (e *DebateEngine) getParticipantVote(
 	baseSystemPrompt string,
 	allMessages []*store.DebateMessage,
 ) (*store.DebateVote, error) {
-	e.clientsMu.RLock()
-	client, ok := e.clients[participant.AIModelID]
-	e.clientsMu.RUnlock()
+	client, ok := e.client(session.ID, participant.AIModelID)
 
 	if !ok {
 		return nil, fmt.Errorf("client not found for %s", participant.AIModelID)
@@ -765,7 +785,6 @@ func (e *DebateEngine) determineMultiCoinConsensus(votes []*store.DebateVote) []
 	// Collect all coin decisions from all votes
 	// Map: symbol -> action -> weighted score and decision data
 	type actionData struct {
-		score         float64
 		totalConf     int
 		totalLeverage int
 		totalPosPct   float64
@@ -776,6 +795,7 @@ func (e *DebateEngine) determineMultiCoinConsensus(votes []*store.DebateVote) []
 	}
 
 	symbolActions := make(map[string]map[string]*actionData)
+	symbolTallies := make(map[string]*kernel.ActionTally)
 
 	// Process all votes
 	logger.Infof("[Debate] Determining multi-coin consensus from %d votes:", len(votes))
@@ -795,16 +815,13 @@ func (e *DebateEngine) determineMultiCoinConsensus(votes []*store.DebateVote) []
 				decisionsProcessed = true
 				if _, ok := symbolActions[symbol]; !ok {
 					symbolActions[symbol] = make(map[string]*actionData)
+					symbolTallies[symbol] = kernel.NewActionTally()
 				}
 				if _, ok := symbolActions[symbol][d.Action]; !ok {
 					symbolActions[symbol][d.Action] = &actionData{}
 				}
 				ad := symbolActions[symbol][d.Action]
-				weight := float64(d.Confidence) / 100.0
-				if weight < 0.1 {
-					weight = 0.5 // Default weight for low confidence
-				}
-				ad.score += weight
+				symbolTallies[symbol].Add(d.Action, kernel.ConsensusWeight(d.Confidence))
 				ad.totalConf += d.Confidence
 				if d.Leverage > 0 {
 					ad.totalLeverage += d.Leverage
@@ -830,16 +847,13 @@ func (e *DebateEngine) determineMultiCoinConsensus(votes []*store.DebateVote) []
 		if !decisionsProcessed && vote.Symbol != "" && isValidAction(vote.Action) {
 			if _, ok := symbolActions[vote.Symbol]; !ok {
 				symbolActions[vote.Symbol] = make(map[string]*actionData)
+				symbolTallies[vote.Symbol] = kernel.NewActionTally()
 			}
 			if _, ok := symbolActions[vote.Symbol][vote.Action]; !ok {
 				symbolActions[vote.Symbol][vote.Action] = &actionData{}
 			}
 			ad := symbolActions[vote.Symbol][vote.Action]
-			weight := float64(vote.Confidence) / 100.0
-			if weight < 0.1 {
-				weight = 0.5 // Default weight for low confidence
-			}
-			ad.score += weight
+			symbolTallies[vote.Symbol].Add(vote.Action, kernel.ConsensusWeight(vote.Confidence))
 			ad.totalConf += vote.Confidence
 			if vote.Leverage > 0 {
 				ad.totalLeverage += vote.Leverage
@@ -864,15 +878,7 @@ func (e *DebateEngine) determineMultiCoinConsensus(votes []*store.DebateVote) []
 	// Determine winning action for each symbol
 	var results []*store.DebateDecision
 	for symbol, actions := range symbolActions {
-		var winningAction string
-		var maxScore float64
-		for action, ad := range actions {
-			if ad.score > maxScore {
-				maxScore = ad.score
-				winningAction = action
-			}
-		}
-
+		winningAction, maxScore := symbolTallies[symbol].Winner()
 		if winningAction == "" {
 			continue
 		}
@@ -0,0 +1,272 @@
+package kernel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/logger"
+	"nofx/mcp"
+	"nofx/store"
+)
+
+// ============================================================================
+// Consensus Voting (shared by debate consensus and ensemble traders)
+// ============================================================================
+
+// Ensemble vote modes
+const (
+	EnsembleVoteMajority   = "majority"   // One vote per model
+	EnsembleVoteConfidence = "confidence" // Votes weighted by each decision's confidence
+)
+
+// abstainAction vote cast by a model that made no decision for a symbol
+const abstainAction = ""
+
+// ConsensusWeight vote weight of a 0-100 confidence; missing or very low confidence counts as 0.5
+func ConsensusWeight(confidence int) float64 {
+	weight := float64(confidence) / 100.0
+	if weight < 0.1 {
+		weight = 0.5 // Default weight for low confidence
+	}
+	return weight
+}
+
+// ActionTally accumulates weighted votes per action for one symbol
+type ActionTally struct {
+	scores map[string]float64
+	counts map[string]int
+	order  []string // Actions in first-vote order, for deterministic tie breaks
+}
+
+// NewActionTally creates an empty tally
+func NewActionTally() *ActionTally {
+	return &ActionTally{scores: make(map[string]float64), counts: make(map[string]int)}
+}
+
+// Add casts a vote for action
+func (t *ActionTally) Add(action string, weight float64) {
+	if _, ok := t.scores[action]; !ok {
+		t.order = append(t.order, action)
+	}
+	t.scores[action] += weight
+	t.counts[action]++
+}
+
+// Winner returns the highest scoring action and its score. Ties go to the action that does not
+// open a position, then to the action voted first.
+func (t *ActionTally) Winner() (string, float64) {
+	winner, best := "", 0.0
+	for _, action := range t.order {
+		score := t.scores[action]
+		if score > best || (score == best && winner != "" && isOpenAction(winner) && !isOpenAction(action)) {
+			winner, best = action, score
+		}
+	}
+	return winner, best
+}
+
+// Count returns the number of votes cast for action
+func (t *ActionTally) Count(action string) int {
+	return t.counts[action]
+}
+
+func isOpenAction(action string) bool {
+	return action == "open_long" || action == "open_short"
+}
+
+// ensembleWeight vote weight of one decision under the given vote mode
+func ensembleWeight(mode string, confidence int) float64 {
+	if mode == EnsembleVoteMajority {
+		return 1
+	}
+	return ConsensusWeight(confidence)
+}
+
+// MergeDecisions merges the decision lists of several models into one list with a vote per
+// symbol. A model without a decision for a symbol abstains; if abstention wins, the symbol is
+// dropped. Numeric parameters are averaged over the models that voted for the winning action.
+func MergeDecisions(lists [][]Decision, mode string) []Decision {
+	var symbols []string
+	bySymbol := make(map[string][][]Decision) // symbol -> per model decisions
+	for i, list := range lists {
+		for _, d := range list {
+			if d.Symbol == "" || d.Action == "" {
+				continue
+			}
+			if _, ok := bySymbol[d.Symbol]; !ok {
+				symbols = append(symbols, d.Symbol)
+				bySymbol[d.Symbol] = make([][]Decision, len(lists))
+			}
+			bySymbol[d.Symbol][i] = append(bySymbol[d.Symbol][i], d)
+		}
+	}
+
+	var merged []Decision
+	for _, symbol := range symbols {
+		tally := NewActionTally()
+		voters := make(map[string][]Decision)
+		for _, decisions := range bySymbol[symbol] {
+			if len(decisions) == 0 {
+				tally.Add(abstainAction, ensembleWeight(mode, 0))
+				continue
+			}
+			for _, d := range decisions {
+				tally.Add(d.Action, ensembleWeight(mode, d.Confidence))
+				voters[d.Action] = append(voters[d.Action], d)
+			}
+		}
+
+		action, score := tally.Winner()
+		if action == abstainAction {
+			logger.Infof("🗳️  Ensemble %s: no consensus to act (abstain score %.2f)", symbol, score)
+			continue
+		}
+		decision := averageDecisions(voters[action])
+		decision.Reasoning = fmt.Sprintf("[ensemble %d/%d] %s", tally.Count(action), len(lists), decision.Reasoning)
+		logger.Infof("🗳️  Ensemble %s: %s (score %.2f, %d/%d models)", symbol, action, score, tally.Count(action), len(lists))
+		merged = append(merged, decision)
+	}
+	return merged
+}
+
+// averageDecisions averages the parameters of decisions that share symbol and action.
+// Zero values (parameters a model left out) are not averaged in.
+func averageDecisions(decisions []Decision) Decision {
+	merged := decisions[0]
+	avg := func(get func(d Decision) float64) float64 {
+		sum, n := 0.0, 0
+		for _, d := range decisions {
+			if v := get(d); v > 0 {
+				sum += v
+				n++
+			}
+		}
+		if n == 0 {
+			return 0
+		}
+		return sum / float64(n)
+	}
+
+	merged.Leverage = int(avg(func(d Decision) float64 { return float64(d.Leverage) }) + 0.5)
+	merged.PositionSizeUSD = avg(func(d Decision) float64 { return d.PositionSizeUSD })
+	merged.StopLoss = avg(func(d Decision) float64 { return d.StopLoss })
+	merged.TakeProfit = avg(func(d Decision) float64 { return d.TakeProfit })
+	merged.TrailingStopPct = avg(func(d Decision) float64 { return d.TrailingStopPct })
+	merged.TakeProfitLevels = mergeTakeProfitLevels(decisions)
+	merged.RiskUSD = avg(func(d Decision) float64 { return d.RiskUSD })
+	merged.Confidence = int(avg(func(d Decision) float64 { return float64(d.Confidence) }) + 0.5)
+	// The ladder must still fit between the averaged stop loss and take profit
+	if err := validateTakeProfitLevels(&merged); err != nil {
+		logger.Infof("⚠️ Dropping the take-profit ladder of the merged %s decision: %v", merged.Symbol, err)
+		merged.TakeProfitLevels = nil
+	}
+
+	var reasons []string
+	for _, d := range decisions {
+		if d.Reasoning != "" {
+			reasons = append(reasons, d.Reasoning)
+		}
+	}
+	merged.Reasoning = strings.Join(reasons, "; ")
+	return merged
+}
+
+// mergeTakeProfitLevels returns the take-profit ladder of the first decision; ladders of different
+// models don't average. When the voters' ladders differ only its R-multiple levels are kept: they
+// follow the averaged stop loss, fixed prices chosen against another stop loss may not.
+func mergeTakeProfitLevels(decisions []Decision) []TakeProfitLevel {
+	first := decisions[0].TakeProfitLevels
+	agree := true
+	for _, d := range decisions[1:] {
+		if !slices.Equal(d.TakeProfitLevels, first) {
+			agree = false
+			break
+		}
+	}
+	if agree {
+		return first
+	}
+	var levels []TakeProfitLevel
+	for _, l := range first {
+		if l.Price <= 0 {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+// GetEnsembleDecision queries all clients in parallel on the same market snapshot and merges
+// their decisions by vote (EnsembleVoteMajority or EnsembleVoteConfidence). Models that fail
+// are left out of the vote; an error is returned only if all of them fail.
+func GetEnsembleDecision(ctx *Context, clients []mcp.AIClient, engine *StrategyEngine, variant, mode string) (*FullDecision, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("context is nil")
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("ensemble has no AI clients")
+	}
+	if engine == nil {
+		defaultConfig := store.GetDefaultStrategyConfig("en")
+		engine = NewStrategyEngine(&defaultConfig)
+	}
+	if err := prepareDecisionContext(ctx, engine, engine.useToolCalling(clients[0])); err != nil {
+		return nil, err
+	}
+
+	results := make([]*FullDecision, len(clients))
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client mcp.AIClient) {
+			defer wg.Done()
+			results[i], errs[i] = decideWithPreparedContext(context.Background(), ctx, client, engine, variant, nil)
+		}(i, client)
+	}
+	wg.Wait()
+
+	full := &FullDecision{Timestamp: time.Now()}
+	var lists [][]Decision
+	var cot, raw []string
+	var failures []error
+	for i, result := range results {
+		name := ensembleMemberName(clients[i], i)
+		if errs[i] != nil || result == nil {
+			logger.Warnf("⚠️  Ensemble model %s failed: %v", name, errs[i])
+			failures = append(failures, fmt.Errorf("%s: %w", name, errs[i]))
+			continue
+		}
+		if full.SystemPrompt == "" {
+			full.SystemPrompt = result.SystemPrompt
+			full.UserPrompt = result.UserPrompt
+		}
+		lists = append(lists, result.Decisions)
+		cot = append(cot, fmt.Sprintf("## %s\n%s", name, result.CoTTrace))
+		raw = append(raw, fmt.Sprintf("## %s\n%s", name, result.RawResponse))
+		full.ToolCalls = append(full.ToolCalls, result.ToolCalls...)
+		if result.AIRequestDurationMs > full.AIRequestDurationMs {
+			full.AIRequestDurationMs = result.AIRequestDurationMs // Models run in parallel
+		}
+	}
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("all %d ensemble models failed: %w", len(clients), errors.Join(failures...))
+	}
+
+	full.Decisions = MergeDecisions(lists, mode)
+	full.CoTTrace = strings.Join(cot, "\n\n")
+	full.RawResponse = strings.Join(raw, "\n\n")
+	applyHybridValidators(ctx, engine, full)
+	return full, nil
+}
+
+func ensembleMemberName(client mcp.AIClient, i int) string {
+	if s, ok := client.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("model #%d", i+1)
+}
@@ -0,0 +1,89 @@
+package kernel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeDecisionsMajority(t *testing.T) {
+	lists := [][]Decision{
+		{{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 100, StopLoss: 90, Confidence: 80}},
+		{{Symbol: "BTCUSDT", Action: "open_long", Leverage: 10, PositionSizeUSD: 300, StopLoss: 94, Confidence: 60}},
+		{{Symbol: "BTCUSDT", Action: "open_short", Leverage: 3, Confidence: 99}},
+	}
+	merged := MergeDecisions(lists, EnsembleVoteMajority)
+	if len(merged) != 1 {
+		t.Fatalf("expected 1 decision, got %d", len(merged))
+	}
+	d := merged[0]
+	if d.Action != "open_long" {
+		t.Fatalf("expected open_long, got %s", d.Action)
+	}
+	if d.Leverage != 8 || d.PositionSizeUSD != 200 || d.StopLoss != 92 || d.Confidence != 70 {
+		t.Errorf("parameters not averaged: %+v", d)
+	}
+	if !strings.HasPrefix(d.Reasoning, "[ensemble 2/3]") {
+		t.Errorf("unexpected reasoning: %q", d.Reasoning)
+	}
+}
+
+func TestMergeDecisionsConfidenceWeighted(t *testing.T) {
+	lists := [][]Decision{
+		{{Symbol: "ETHUSDT", Action: "open_long", Confidence: 40}},
+		{{Symbol: "ETHUSDT", Action: "open_long", Confidence: 40}},
+		{{Symbol: "ETHUSDT", Action: "close_long", Confidence: 95}},
+	}
+	merged := MergeDecisions(lists, EnsembleVoteConfidence)
+	if len(merged) != 1 || merged[0].Action != "close_long" {
+		t.Fatalf("confidence vote should pick close_long, got %+v", merged)
+	}
+}
+
+func TestMergeDecisionsAbstainAndTies(t *testing.T) {
+	// Only one of three models wants to trade SOL: abstention wins and the symbol is dropped
+	lists := [][]Decision{
+		{{Symbol: "SOLUSDT", Action: "open_short", Confidence: 90}},
+		{},
+		{},
+	}
+	if merged := MergeDecisions(lists, EnsembleVoteMajority); len(merged) != 0 {
+		t.Errorf("expected abstention, got %+v", merged)
+	}
+
+	// A tie between opening and holding resolves to the action that does not open a position
+	lists = [][]Decision{
+		{{Symbol: "BTCUSDT", Action: "open_long"}},
+		{{Symbol: "BTCUSDT", Action: "hold"}},
+	}
+	merged := MergeDecisions(lists, EnsembleVoteMajority)
+	if len(merged) != 1 || merged[0].Action != "hold" {
+		t.Errorf("tie should resolve to hold, got %+v", merged)
+	}
+}
+
+func TestMergeDecisionsTakeProfitLadder(t *testing.T) {
+	ladder := []TakeProfitLevel{{Price: 104, Pct: 30}, {RMultiple: 2, Pct: 40}}
+	long := func(stop, tp float64, levels []TakeProfitLevel) []Decision {
+		return []Decision{{Symbol: "BTCUSDT", Action: "open_long", StopLoss: stop, TakeProfit: tp, TakeProfitLevels: levels}}
+	}
+
+	// Voters agreeing on the ladder keep it whole
+	merged := MergeDecisions([][]Decision{long(90, 120, ladder), long(94, 130, ladder)}, EnsembleVoteMajority)
+	if len(merged) != 1 || len(merged[0].TakeProfitLevels) != 2 {
+		t.Fatalf("agreed ladder not kept: %+v", merged)
+	}
+
+	// Disagreeing voters: the fixed price is dropped, the R-multiple follows the averaged stop
+	other := []TakeProfitLevel{{Price: 110, Pct: 50}}
+	merged = MergeDecisions([][]Decision{long(90, 120, ladder), long(94, 130, other)}, EnsembleVoteMajority)
+	if levels := merged[0].TakeProfitLevels; len(levels) != 1 || levels[0].RMultiple != 2 {
+		t.Errorf("unexpected merged ladder: %+v", levels)
+	}
+
+	// A fixed level the averaged stop loss passes is invalid and dropped with the ladder
+	low := []TakeProfitLevel{{Price: 93, Pct: 30}}
+	merged = MergeDecisions([][]Decision{long(92, 120, low), long(96, 120, low)}, EnsembleVoteMajority)
+	if levels := merged[0].TakeProfitLevels; len(levels) != 0 {
+		t.Errorf("invalid ladder kept: %+v", levels)
+	}
+}
@@ -1,9 +1,11 @@
 package kernel
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"nofx/logger"
 	"nofx/market"
@@ -12,6 +14,8 @@ import (
 	"nofx/security"
 	"nofx/store"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 )
@@ -33,6 +37,10 @@ var (
 	reDecisionTag  = regexp.MustCompile(`(?s)<decision>(.*?)</decision>`)
 )
 
+// decisionStreamTimeout bounds a streamed decision including retries; the HTTP client timeout
+// only covers a single attempt
+const decisionStreamTimeout = 5 * time.Minute
+
 // ============================================================================
 // Type Definitions
 // ============================================================================
@@ -125,12 +133,23 @@ type Context struct {
 	BTCETHLeverage     int                          `json:"-"`
 	AltcoinLeverage int                                `json:"-"`
 	Timeframes      []string                           `json:"-"`
+
+	// Trade memory: closed trades with entry features (input) and the ones most similar to each
+	// symbol's current market state (filled before the prompt is built)
+	TradeMemories []*store.ClosedTradeMemory `json:"-"`
+	SimilarTrades map[string][]SimilarTrade  `json:"similar_trades,omitempty"`
+
+	// Hybrid rules: klines they are evaluated on (nil: fetched live) and the candidate coins
+	// removed by pre-filters with the reason
+	RuleKlines         KlineSource       `json:"-"`
+	FilteredCandidates map[string]string `json:"-"`
 }
 
 // Decision AI trading decision
 type Decision struct {
 	Symbol string `json:"symbol"`
 	Action string `json:"action"` // Standard: "open_long", "open_short", "close_long", "close_short", "hold", "wait"
+	// Stop management: "set_trailing_stop", "move_to_breakeven"
 	// Grid actions: "place_buy_limit", "place_sell_limit", "cancel_order", "cancel_all_orders", "pause_grid", "resume_grid", "adjust_grid"
 
 	// Opening position parameters
@@ -138,6 +157,9 @@ type Decision struct {
 	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
 	StopLoss        float64 `json:"stop_loss,omitempty"`
 	TakeProfit      float64 `json:"take_profit,omitempty"`
+	TrailingStopPct float64 `json:"trailing_stop_pct,omitempty"` // Trailing stop callback in % of price (open and set_trailing_stop)
+	// Scaled exits (open actions); the rest of the position exits at take_profit or by the trailing stop
+	TakeProfitLevels []TakeProfitLevel `json:"take_profit_levels,omitempty"`
 
 	// Grid trading parameters
 	Price      float64 `json:"price,omitempty"`       // Limit order price (for grid)
@@ -151,6 +173,35 @@ type Decision struct {
 	Reasoning  string  `json:"reasoning"`
 }
 
+// TakeProfitLevel one level of a take-profit ladder: closes Pct percent of the opened quantity at
+// Price, or at RMultiple times the stop loss distance from the entry when Price is 0
+type TakeProfitLevel struct {
+	Price     float64 `json:"price,omitempty"`
+	RMultiple float64 `json:"r_multiple,omitempty"`
+	Pct       float64 `json:"pct"`
+}
+
+// TakeProfitLadder returns the take-profit levels with their prices resolved for a position of
+// side ("long" or "short") opened at entry, nearest to the entry first
+func (d *Decision) TakeProfitLadder(side string, entry float64) []TakeProfitLevel {
+	levels := make([]TakeProfitLevel, 0, len(d.TakeProfitLevels))
+	risk := math.Abs(entry - d.StopLoss)
+	for _, l := range d.TakeProfitLevels {
+		if l.Price <= 0 {
+			if side == "short" {
+				l.Price = entry - l.RMultiple*risk
+			} else {
+				l.Price = entry + l.RMultiple*risk
+			}
+		}
+		levels = append(levels, l)
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		return math.Abs(levels[i].Price-entry) < math.Abs(levels[j].Price-entry)
+	})
+	return levels
+}
+
 // FullDecision AI's complete decision (including chain of thought)
 type FullDecision struct {
 	SystemPrompt        string     `json:"system_prompt"`
@@ -160,6 +211,10 @@ type FullDecision struct {
 	RawResponse         string     `json:"raw_response"`
 	Timestamp           time.Time  `json:"timestamp"`
 	AIRequestDurationMs int64      `json:"ai_request_duration_ms,omitempty"`
+
+	ToolCalls    []mcp.ToolInvocation `json:"tool_calls,omitempty"`    // Data tools the AI called (tool calling mode)
+	PromptBudget *PromptBudgetReport  `json:"prompt_budget,omitempty"` // Set when the user prompt was compacted
+	Rejected     []RejectedDecision   `json:"rejected,omitempty"`      // AI entries rejected by hybrid rules
 }
 
 // QuantData quantitative data structure (fund flow, position changes, price changes)
@@ -263,12 +318,61 @@ func GetFullDecisionWithStrategy(ctx *Context, mcpClient mcp.AIClient, engine *S
 	}
 
 	// 1. Fetch market data using strategy config
+	if err := prepareDecisionContext(ctx, engine, engine.useToolCalling(mcpClient)); err != nil {
+		return nil, err
+	}
+	decision, err := decideWithPreparedContext(context.Background(), ctx, mcpClient, engine, variant, nil)
+	if err == nil {
+		applyHybridValidators(ctx, engine, decision)
+	}
+	return decision, err
+}
+
+// GetFullDecisionWithStrategyStream is GetFullDecisionWithStrategy with the model's answer streamed
+// to onChunk while it is generated. Streaming uses free-form output (chain of thought, then JSON);
+// clients without streaming support and tool calling mode fall back to a regular call.
+// Cancelling runCtx aborts the stream; it is also bounded by decisionStreamTimeout.
+func GetFullDecisionWithStrategyStream(runCtx context.Context, ctx *Context, mcpClient mcp.AIClient, engine *StrategyEngine, variant string, onChunk func(chunk string)) (*FullDecision, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("context is nil")
+	}
+	if engine == nil {
+		defaultConfig := store.GetDefaultStrategyConfig("en")
+		engine = NewStrategyEngine(&defaultConfig)
+	}
+
+	if err := prepareDecisionContext(ctx, engine, engine.useToolCalling(mcpClient)); err != nil {
+		return nil, err
+	}
+	decision, err := decideWithPreparedContext(runCtx, ctx, mcpClient, engine, variant, onChunk)
+	if err == nil {
+		applyHybridValidators(ctx, engine, decision)
+	}
+	return decision, err
+}
+
+// useToolCalling reports whether decisions are requested in tool calling mode, where the AI
+// fetches candidate data on demand instead of receiving it all up front
+func (e *StrategyEngine) useToolCalling(mcpClient mcp.AIClient) bool {
+	_, ok := mcpClient.(mcp.ToolCaller)
+	return ok && e.config.Indicators.EnableToolCalling
+}
+
+// prepareDecisionContext fetches market and OI ranking data not yet present in ctx, applies the
+// hybrid pre-filters to the candidate coins and retrieves similar past trades
+func prepareDecisionContext(ctx *Context, engine *StrategyEngine, useTools bool) error {
 	if len(ctx.MarketDataMap) == 0 {
-		if err := fetchMarketDataWithStrategy(ctx, engine); err != nil {
-			return nil, fmt.Errorf("failed to fetch market data: %w", err)
+		if err := fetchMarketDataWithStrategy(ctx, engine, useTools); err != nil {
+			return fmt.Errorf("failed to fetch market data: %w", err)
 		}
 	}
 
+	applyHybridPreFilters(ctx, engine)
+
+	if engine.config.Indicators.EnableTradeMemory && ctx.SimilarTrades == nil {
+		ctx.SimilarTrades = engine.findSimilarTrades(ctx)
+	}
+
 	// Ensure OITopDataMap is initialized
 	if ctx.OITopDataMap == nil {
 		ctx.OITopDataMap = make(map[string]*OITopData)
@@ -284,21 +388,63 @@ func GetFullDecisionWithStrategy(ctx *Context, mcpClient mcp.AIClient, engine *S
 			}
 		}
 	}
+	return nil
+}
+
+// decideWithPreparedContext builds prompts from a prepared ctx, calls the AI and parses decisions.
+// ctx is only read, so it may be shared by concurrent calls. A non-nil onChunk streams the answer
+// for as long as runCtx lives.
+func decideWithPreparedContext(runCtx context.Context, ctx *Context, mcpClient mcp.AIClient, engine *StrategyEngine, variant string, onChunk func(chunk string)) (*FullDecision, error) {
+	toolCaller, _ := mcpClient.(mcp.ToolCaller)
+	useTools := engine.useToolCalling(mcpClient)
 
 	// 2. Build System Prompt using strategy engine
 	riskConfig := engine.GetRiskControlConfig()
 	systemPrompt := engine.BuildSystemPrompt(ctx.Account.TotalEquity, variant)
+	if useTools {
+		systemPrompt += engine.toolUsePrompt()
+	}
 
-	// 3. Build User Prompt using strategy engine
-	userPrompt := engine.BuildUserPrompt(ctx)
+	// 3. Build User Prompt using strategy engine (compacted to fit the model context window)
+	promptBudget := 0
+	if budgeter, ok := mcpClient.(mcp.PromptBudgeter); ok {
+		promptBudget = budgeter.UserPromptBudget(systemPrompt)
+	}
+	userPrompt, budgetReport := engine.BuildUserPromptWithBudget(ctx, promptBudget)
 
 	// 4. Call AI API
 	aiCallStart := time.Now()
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
-	aiCallDuration := time.Since(aiCallStart)
-	if err != nil {
-		return nil, fmt.Errorf("AI API call failed: %w", err)
+	var aiResponse string
+	var toolCalls []mcp.ToolInvocation
+	if useTools {
+		result, err := callWithMarketTools(toolCaller, engine, systemPrompt, userPrompt)
+		if err != nil {
+			return nil, fmt.Errorf("AI API call failed: %w", err)
+		}
+		aiResponse = result.Content
+		toolCalls = result.Calls
+	} else if streamer, ok := mcpClient.(mcp.StreamCaller); ok && onChunk != nil {
+		req, err := mcp.NewRequestBuilder().
+			WithSystemPrompt(systemPrompt).
+			WithUserPrompt(userPrompt).
+			Build()
+		if err != nil {
+			return nil, err
+		}
+		streamCtx, cancel := context.WithTimeout(runCtx, decisionStreamTimeout)
+		aiResponse, err = streamer.CallStream(streamCtx, req, onChunk)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("AI API call failed: %w", err)
+		}
+	} else {
+		var err error
+		aiResponse, systemPrompt, err = callDecisionAI(mcpClient, systemPrompt, userPrompt, engine.GetLanguage(), standardDecisionActions)
+		if err != nil {
+			return nil, fmt.Errorf("AI API call failed: %w", err)
+		}
 	}
+	aiCallDuration := time.Since(aiCallStart)
 
 	// 5. Parse AI response
 	decision, err := parseFullDecisionResponse(
@@ -316,6 +462,10 @@ func GetFullDecisionWithStrategy(ctx *Context, mcpClient mcp.AIClient, engine *S
 		decision.UserPrompt = userPrompt
 		decision.AIRequestDurationMs = aiCallDuration.Milliseconds()
 		decision.RawResponse = aiResponse
+		decision.ToolCalls = toolCalls
+		if budgetReport.Compacted() {
+			decision.PromptBudget = budgetReport
+		}
 	}
 
 	if err != nil {
@@ -329,14 +479,12 @@ func GetFullDecisionWithStrategy(ctx *Context, mcpClient mcp.AIClient, engine *S
 // Market Data Fetching
 // ============================================================================
 
-// fetchMarketDataWithStrategy fetches market data using strategy config (multiple timeframes)
-func fetchMarketDataWithStrategy(ctx *Context, engine *StrategyEngine) error {
-	config := engine.GetConfig()
-	ctx.MarketDataMap = make(map[string]*market.Data)
-
-	timeframes := config.Indicators.Klines.SelectedTimeframes
-	primaryTimeframe := config.Indicators.Klines.PrimaryTimeframe
-	klineCount := config.Indicators.Klines.PrimaryCount
+// klineSettings resolves the timeframes, primary timeframe and kline count from strategy config
+func (e *StrategyEngine) klineSettings() ([]string, string, int) {
+	klines := e.config.Indicators.Klines
+	timeframes := klines.SelectedTimeframes
+	primaryTimeframe := klines.PrimaryTimeframe
+	klineCount := klines.PrimaryCount
 
 	// Compatible with old configuration
 	if len(timeframes) == 0 {
@@ -345,8 +493,8 @@ func fetchMarketDataWithStrategy(ctx *Context, engine *StrategyEngine) error {
 		} else {
 			timeframes = append(timeframes, "3m")
 		}
-		if config.Indicators.Klines.LongerTimeframe != "" {
-			timeframes = append(timeframes, config.Indicators.Klines.LongerTimeframe)
+		if klines.LongerTimeframe != "" {
+			timeframes = append(timeframes, klines.LongerTimeframe)
 		}
 	}
 	if primaryTimeframe == "" {
@@ -356,6 +504,28 @@ func fetchMarketDataWithStrategy(ctx *Context, engine *StrategyEngine) error {
 		klineCount = 30
 	}
 
+	// Rule strategies also need the timeframes their rules name and enough klines to warm up indicators
+	if e.config.IsRuleStrategy() {
+		if rules, err := CompileRules(e.config.RuleConfig); err == nil {
+			timeframes = slices.Clone(timeframes)
+			for _, tf := range rules.Timeframes() {
+				if !slices.Contains(timeframes, tf) {
+					timeframes = append(timeframes, tf)
+				}
+			}
+			klineCount = max(klineCount, rules.Lookback())
+		}
+	}
+	return timeframes, primaryTimeframe, klineCount
+}
+
+// fetchMarketDataWithStrategy fetches market data using strategy config (multiple timeframes).
+// With positionsOnly, candidate coins are left for the AI to fetch through tools.
+func fetchMarketDataWithStrategy(ctx *Context, engine *StrategyEngine, positionsOnly bool) error {
+	ctx.MarketDataMap = make(map[string]*market.Data)
+
+	timeframes, primaryTimeframe, klineCount := engine.klineSettings()
+
 	logger.Infof("📊 Strategy timeframes: %v, Primary: %s, Kline count: %d", timeframes, primaryTimeframe, klineCount)
 
 	// 1. First fetch data for position coins (must fetch)
@@ -367,6 +537,10 @@ func fetchMarketDataWithStrategy(ctx *Context, engine *StrategyEngine) error {
 		}
 		ctx.MarketDataMap[pos.Symbol] = data
 	}
+	if positionsOnly {
+		logger.Infof("📊 Tool calling enabled, fetched market data for %d positions only", len(ctx.MarketDataMap))
+		return nil
+	}
 
 	// 2. Fetch data for all candidate coins
 	positionSymbols := make(map[string]bool)
@@ -1041,9 +1215,12 @@ func (e *StrategyEngine) BuildSystemPrompt(accountEquity float64, variant string
 	sb.WriteString("]\n```\n")
 	sb.WriteString("</decision>\n\n")
 	sb.WriteString("## Field Description\n\n")
-	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
+	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait | set_trailing_stop | move_to_breakeven\n")
 	sb.WriteString(fmt.Sprintf("- `confidence`: 0-100 (opening recommended ≥ %d)\n", riskControl.MinConfidence))
 	sb.WriteString("- Required when opening: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd\n")
+	sb.WriteString(fmt.Sprintf("- `trailing_stop_pct`: optional when opening, required for set_trailing_stop; trails the best price by this percent (%.1f-%.0f)\n", minTrailingStopPct, maxTrailingStopPct))
+	sb.WriteString("- `take_profit_levels`: optional scaled exits when opening, e.g. `[{\"r_multiple\": 1, \"pct\": 30}, {\"r_multiple\": 2, \"pct\": 40}]` closes 30% at 1R and 40% at 2R (R = entry to stop_loss distance; use `price` instead of `r_multiple` for a fixed price); the rest exits at take_profit or by trailing_stop_pct\n")
+	sb.WriteString("- `set_trailing_stop` / `move_to_breakeven`: manage the stop of an open position of the symbol; move_to_breakeven moves the stop-loss to the entry price once the position is in profit\n")
 	sb.WriteString("- **IMPORTANT**: All numeric values must be calculated numbers, NOT formulas/expressions (e.g., use `27.76` not `3000 * 0.01`)\n\n")
 
 	// 8. Custom Prompt
@@ -1131,6 +1308,11 @@ func (e *StrategyEngine) writeAvailableIndicators(sb *strings.Builder) {
 
 // BuildUserPrompt builds User Prompt based on strategy configuration
 func (e *StrategyEngine) BuildUserPrompt(ctx *Context) string {
+	return e.buildUserPrompt(ctx, fullPromptDetail)
+}
+
+// buildUserPrompt builds the user prompt at the given detail level (see BuildUserPromptWithBudget)
+func (e *StrategyEngine) buildUserPrompt(ctx *Context, detail promptDetail) string {
 	var sb strings.Builder
 
 	// System status
@@ -1231,11 +1413,14 @@ func (e *StrategyEngine) BuildUserPrompt(ctx *Context) string {
 		sb.WriteString("\n")
 	}
 
+	// Outcomes of past trades opened in a similar market state
+	sb.WriteString(e.formatSimilarTrades(ctx))
+
 	// Position information
 	if len(ctx.Positions) > 0 {
 		sb.WriteString("## Current Positions\n")
 		for i, pos := range ctx.Positions {
-			sb.WriteString(e.formatPositionInfo(i+1, pos, ctx))
+			sb.WriteString(e.formatPositionInfo(i+1, pos, ctx, detail))
 		}
 	} else {
 		sb.WriteString("Current Positions: None\n\n")
@@ -1249,8 +1434,12 @@ func (e *StrategyEngine) BuildUserPrompt(ctx *Context) string {
 		positionSymbols[normalizedSymbol] = true
 	}
 
-	sb.WriteString(fmt.Sprintf("## Candidate Coins (%d coins)\n\n", len(ctx.MarketDataMap)))
-	displayedCount := 0
+	candidateCount := len(ctx.MarketDataMap)
+	if e.config.Indicators.EnableToolCalling {
+		candidateCount = len(ctx.CandidateCoins)
+	}
+	sb.WriteString(fmt.Sprintf("## Candidate Coins (%d coins)\n\n", candidateCount))
+	displayedCount, omittedCount := 0, 0
 	for _, coin := range ctx.CandidateCoins {
 		// Skip if this coin is already a position (data already shown in positions section)
 		normalizedCoinSymbol := market.Normalize(coin.Symbol)
@@ -1262,11 +1451,15 @@ func (e *StrategyEngine) BuildUserPrompt(ctx *Context) string {
 		if !hasData {
 			continue
 		}
+		if detail.maxCandidates >= 0 && displayedCount >= detail.maxCandidates {
+			omittedCount++
+			continue
+		}
 		displayedCount++
 
 		sourceTags := e.formatCoinSourceTag(coin.Sources)
 		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
-		sb.WriteString(e.formatMarketData(marketData))
+		sb.WriteString(e.formatMarketDataWith(marketData, detail))
 
 		if ctx.QuantDataMap != nil {
 			if quantData, hasQuant := ctx.QuantDataMap[coin.Symbol]; hasQuant {
@@ -1275,6 +1468,21 @@ func (e *StrategyEngine) BuildUserPrompt(ctx *Context) string {
 		}
 		sb.WriteString("\n")
 	}
+	if omittedCount > 0 {
+		sb.WriteString(fmt.Sprintf("(%d lower-ranked candidates omitted to fit the model context window)\n", omittedCount))
+	}
+	if e.config.Indicators.EnableToolCalling {
+		// Candidates not preloaded in tool calling mode; the AI fetches what it needs
+		var pending []string
+		for _, coin := range ctx.CandidateCoins {
+			if _, hasData := ctx.MarketDataMap[coin.Symbol]; !hasData && !positionSymbols[market.Normalize(coin.Symbol)] {
+				pending = append(pending, coin.Symbol+e.formatCoinSourceTag(coin.Sources))
+			}
+		}
+		if len(pending) > 0 {
+			sb.WriteString(fmt.Sprintf("Not preloaded (fetch with tools as needed): %s\n", strings.Join(pending, ", ")))
+		}
+	}
 	sb.WriteString("\n")
 
 	// Get language for market data formatting
@@ -1304,7 +1512,7 @@ func (e *StrategyEngine) BuildUserPrompt(ctx *Context) string {
 	return sb.String()
 }
 
-func (e *StrategyEngine) formatPositionInfo(index int, pos PositionInfo, ctx *Context) string {
+func (e *StrategyEngine) formatPositionInfo(index int, pos PositionInfo, ctx *Context, detail promptDetail) string {
 	var sb strings.Builder
 
 	holdingDuration := ""
@@ -1331,7 +1539,7 @@ func (e *StrategyEngine) formatPositionInfo(index int, pos PositionInfo, ctx *Co
 		pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration))
 
 	if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
-		sb.WriteString(e.formatMarketData(marketData))
+		sb.WriteString(e.formatMarketDataWith(marketData, detail))
 
 		if ctx.QuantDataMap != nil {
 			if quantData, hasQuant := ctx.QuantDataMap[pos.Symbol]; hasQuant {
@@ -1390,6 +1598,10 @@ func (e *StrategyEngine) formatCoinSourceTag(sources []string) string {
 // ============================================================================
 
 func (e *StrategyEngine) formatMarketData(data *market.Data) string {
+	return e.formatMarketDataWith(data, fullPromptDetail)
+}
+
+func (e *StrategyEngine) formatMarketDataWith(data *market.Data, detail promptDetail) string {
 	var sb strings.Builder
 	indicators := e.config.Indicators
 
@@ -1429,7 +1641,7 @@ func (e *StrategyEngine) formatMarketData(data *market.Data) string {
 		for _, tf := range timeframeOrder {
 			if tfData, ok := data.TimeframeData[tf]; ok {
 				sb.WriteString(fmt.Sprintf("=== %s Timeframe (oldest → latest) ===\n\n", strings.ToUpper(tf)))
-				e.formatTimeframeSeriesData(&sb, tfData, indicators)
+				e.formatTimeframeSeriesData(&sb, tfData, indicators, detail)
 			}
 		}
 	} else {
@@ -1439,28 +1651,28 @@ func (e *StrategyEngine) formatMarketData(data *market.Data) string {
 			sb.WriteString(fmt.Sprintf("Intraday series (%s intervals, oldest → latest):\n\n", klineConfig.PrimaryTimeframe))
 
 			if len(data.IntradaySeries.MidPrices) > 0 {
-				sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatFloatSlice(data.IntradaySeries.MidPrices)))
+				sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", detail.series(data.IntradaySeries.MidPrices)))
 			}
 
 			if indicators.EnableEMA && len(data.IntradaySeries.EMA20Values) > 0 {
-				sb.WriteString(fmt.Sprintf("EMA indicators (20-period): %s\n\n", formatFloatSlice(data.IntradaySeries.EMA20Values)))
+				sb.WriteString(fmt.Sprintf("EMA indicators (20-period): %s\n\n", detail.series(data.IntradaySeries.EMA20Values)))
 			}
 
 			if indicators.EnableMACD && len(data.IntradaySeries.MACDValues) > 0 {
-				sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.IntradaySeries.MACDValues)))
+				sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", detail.series(data.IntradaySeries.MACDValues)))
 			}
 
 			if indicators.EnableRSI {
 				if len(data.IntradaySeries.RSI7Values) > 0 {
-					sb.WriteString(fmt.Sprintf("RSI indicators (7-Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI7Values)))
+					sb.WriteString(fmt.Sprintf("RSI indicators (7-Period): %s\n\n", detail.series(data.IntradaySeries.RSI7Values)))
 				}
 				if len(data.IntradaySeries.RSI14Values) > 0 {
-					sb.WriteString(fmt.Sprintf("RSI indicators (14-Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI14Values)))
+					sb.WriteString(fmt.Sprintf("RSI indicators (14-Period): %s\n\n", detail.series(data.IntradaySeries.RSI14Values)))
 				}
 			}
 
 			if indicators.EnableVolume && len(data.IntradaySeries.Volume) > 0 {
-				sb.WriteString(fmt.Sprintf("Volume: %s\n\n", formatFloatSlice(data.IntradaySeries.Volume)))
+				sb.WriteString(fmt.Sprintf("Volume: %s\n\n", detail.series(data.IntradaySeries.Volume)))
 			}
 
 			if indicators.EnableATR {
@@ -1487,11 +1699,11 @@ func (e *StrategyEngine) formatMarketData(data *market.Data) string {
 			}
 
 			if indicators.EnableMACD && len(data.LongerTermContext.MACDValues) > 0 {
-				sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.LongerTermContext.MACDValues)))
+				sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", detail.series(data.LongerTermContext.MACDValues)))
 			}
 
 			if indicators.EnableRSI && len(data.LongerTermContext.RSI14Values) > 0 {
-				sb.WriteString(fmt.Sprintf("RSI indicators (14-Period): %s\n\n", formatFloatSlice(data.LongerTermContext.RSI14Values)))
+				sb.WriteString(fmt.Sprintf("RSI indicators (14-Period): %s\n\n", detail.series(data.LongerTermContext.RSI14Values)))
 			}
 		}
 	}
@@ -1499,14 +1711,17 @@ func (e *StrategyEngine) formatMarketData(data *market.Data) string {
 	return sb.String()
 }
 
-func (e *StrategyEngine) formatTimeframeSeriesData(sb *strings.Builder, data *market.TimeframeSeriesData, indicators store.IndicatorConfig) {
-	if len(data.Klines) > 0 {
+func (e *StrategyEngine) formatTimeframeSeriesData(sb *strings.Builder, data *market.TimeframeSeriesData, indicators store.IndicatorConfig, detail promptDetail) {
+	if len(data.Klines) > 0 && detail.summarize {
+		sb.WriteString(summarizeKlines(data.Klines))
+	} else if len(data.Klines) > 0 {
+		klines := tailOf(data.Klines, detail.klineLimit)
 		sb.WriteString("Time(UTC)      Open      High      Low       Close     Volume\n")
-		for i, k := range data.Klines {
+		for i, k := range klines {
 			t := time.Unix(k.Time/1000, 0).UTC()
 			timeStr := t.Format("01-02 15:04")
 			marker := ""
-			if i == len(data.Klines)-1 {
+			if i == len(klines)-1 {
 				marker = "  <- current"
 			}
 			sb.WriteString(fmt.Sprintf("%-14s %-9.4f %-9.4f %-9.4f %-9.4f %-12.2f%s\n",
@@ -1514,31 +1729,31 @@ func (e *StrategyEngine) formatTimeframeSeriesData(sb *strings.Builder, data *ma
 		}
 		sb.WriteString("\n")
 	} else if len(data.MidPrices) > 0 {
-		sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatFloatSlice(data.MidPrices)))
+		sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", detail.series(data.MidPrices)))
 		if indicators.EnableVolume && len(data.Volume) > 0 {
-			sb.WriteString(fmt.Sprintf("Volume: %s\n\n", formatFloatSlice(data.Volume)))
+			sb.WriteString(fmt.Sprintf("Volume: %s\n\n", detail.series(data.Volume)))
 		}
 	}
 
 	if indicators.EnableEMA {
 		if len(data.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("EMA20: %s\n", formatFloatSlice(data.EMA20Values)))
+			sb.WriteString(fmt.Sprintf("EMA20: %s\n", detail.series(data.EMA20Values)))
 		}
 		if len(data.EMA50Values) > 0 {
-			sb.WriteString(fmt.Sprintf("EMA50: %s\n", formatFloatSlice(data.EMA50Values)))
+			sb.WriteString(fmt.Sprintf("EMA50: %s\n", detail.series(data.EMA50Values)))
 		}
 	}
 
 	if indicators.EnableMACD && len(data.MACDValues) > 0 {
-		sb.WriteString(fmt.Sprintf("MACD: %s\n", formatFloatSlice(data.MACDValues)))
+		sb.WriteString(fmt.Sprintf("MACD: %s\n", detail.series(data.MACDValues)))
 	}
 
 	if indicators.EnableRSI {
 		if len(data.RSI7Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI7: %s\n", formatFloatSlice(data.RSI7Values)))
+			sb.WriteString(fmt.Sprintf("RSI7: %s\n", detail.series(data.RSI7Values)))
 		}
 		if len(data.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI14: %s\n", formatFloatSlice(data.RSI14Values)))
+			sb.WriteString(fmt.Sprintf("RSI14: %s\n", detail.series(data.RSI14Values)))
 		}
 	}
 
@@ -1547,9 +1762,9 @@ func (e *StrategyEngine) formatTimeframeSeriesData(sb *strings.Builder, data *ma
 	}
 
 	if indicators.EnableBOLL && len(data.BOLLUpper) > 0 {
-		sb.WriteString(fmt.Sprintf("BOLL Upper: %s\n", formatFloatSlice(data.BOLLUpper)))
-		sb.WriteString(fmt.Sprintf("BOLL Middle: %s\n", formatFloatSlice(data.BOLLMiddle)))
-		sb.WriteString(fmt.Sprintf("BOLL Lower: %s\n", formatFloatSlice(data.BOLLLower)))
+		sb.WriteString(fmt.Sprintf("BOLL Upper: %s\n", detail.series(data.BOLLUpper)))
+		sb.WriteString(fmt.Sprintf("BOLL Middle: %s\n", detail.series(data.BOLLMiddle)))
+		sb.WriteString(fmt.Sprintf("BOLL Lower: %s\n", detail.series(data.BOLLLower)))
 	}
 
 	sb.WriteString("\n")
@@ -1672,14 +1887,19 @@ func formatFloatSlice(values []float64) string {
 // ============================================================================
 
 func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int, btcEthPosRatio, altcoinPosRatio float64) (*FullDecision, error) {
-	cotTrace := extractCoTTrace(aiResponse)
+	// Structured output responses are plain JSON; free-form text goes through extraction and repair
+	cotTrace, decisions, structured := decodeStructuredDecisions(aiResponse)
+	if !structured {
+		cotTrace = extractCoTTrace(aiResponse)
 
-	decisions, err := extractDecisions(aiResponse)
-	if err != nil {
-		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: []Decision{},
-		}, fmt.Errorf("failed to extract decisions: %w", err)
+		var err error
+		decisions, err = extractDecisions(aiResponse)
+		if err != nil {
+			return &FullDecision{
+				CoTTrace:  cotTrace,
+				Decisions: []Decision{},
+			}, fmt.Errorf("failed to extract decisions: %w", err)
+		}
 	}
 
 	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage, btcEthPosRatio, altcoinPosRatio); err != nil {
@@ -1848,6 +2068,12 @@ func compactArrayOpen(s string) string {
 // Decision Validation
 // ============================================================================
 
+// Trailing stop callback range accepted from the AI (the narrowest of the exchanges' limits)
+const (
+	minTrailingStopPct = 0.1
+	maxTrailingStopPct = 10.0
+)
+
 func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int, btcEthPosRatio, altcoinPosRatio float64) error {
 	for i := range decisions {
 		if err := validateDecision(&decisions[i], accountEquity, btcEthLeverage, altcoinLeverage, btcEthPosRatio, altcoinPosRatio); err != nil {
@@ -1858,17 +2084,19 @@ func validateDecisions(decisions []Decision, accountEquity float64, btcEthLevera
 }
 
 func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int, btcEthPosRatio, altcoinPosRatio float64) error {
-	validActions := map[string]bool{
-		"open_long":   true,
-		"open_short":  true,
-		"close_long":  true,
-		"close_short": true,
-		"hold":        true,
-		"wait":        true,
+	if !slices.Contains(standardDecisionActions, d.Action) {
+		return fmt.Errorf("invalid action: %s", d.Action)
 	}
 
-	if !validActions[d.Action] {
-		return fmt.Errorf("invalid action: %s", d.Action)
+	if d.Action == "set_trailing_stop" && d.TrailingStopPct <= 0 {
+		return fmt.Errorf("set_trailing_stop requires trailing_stop_pct")
+	}
+	if d.TrailingStopPct != 0 && (d.TrailingStopPct < minTrailingStopPct || d.TrailingStopPct > maxTrailingStopPct) {
+		return fmt.Errorf("trailing_stop_pct must be between %.1f and %.0f: %.2f", minTrailingStopPct, maxTrailingStopPct, d.TrailingStopPct)
+	}
+
+	if len(d.TakeProfitLevels) > 0 && d.Action != "open_long" && d.Action != "open_short" {
+		return fmt.Errorf("take_profit_levels only apply to open actions")
 	}
 
 	if d.Action == "open_long" || d.Action == "open_short" {
@@ -1927,6 +2155,9 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 				return fmt.Errorf("for short positions, stop loss price must be greater than take profit price")
 			}
 		}
+		if err := validateTakeProfitLevels(d); err != nil {
+			return err
+		}
 
 		var entryPrice float64
 		if d.Action == "open_long" {
@@ -1959,6 +2190,31 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 	return nil
 }
 
+// validateTakeProfitLevels checks the take-profit ladder of an open decision: the levels close at
+// most the whole position and fixed prices lie between the stop loss and the final take profit
+func validateTakeProfitLevels(d *Decision) error {
+	total := 0.0
+	for i, l := range d.TakeProfitLevels {
+		if l.Pct <= 0 {
+			return fmt.Errorf("take_profit_levels[%d]: pct must be greater than 0", i)
+		}
+		total += l.Pct
+		switch {
+		case l.Price > 0:
+			long := d.Action == "open_long"
+			if (long && (l.Price <= d.StopLoss || l.Price > d.TakeProfit)) || (!long && (l.Price >= d.StopLoss || l.Price < d.TakeProfit)) {
+				return fmt.Errorf("take_profit_levels[%d]: price %.4f must lie between stop loss and take profit", i, l.Price)
+			}
+		case l.RMultiple <= 0:
+			return fmt.Errorf("take_profit_levels[%d]: price or r_multiple must be greater than 0", i)
+		}
+	}
+	if total > 100+1e-6 {
+		return fmt.Errorf("take_profit_levels close %.1f%% of the position, at most 100%%", total)
+	}
+	return nil
+}
+
 // ============================================================================
 // Helper Functions
 // ============================================================================
@@ -7,6 +7,7 @@ import (
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/store"
+	"slices"
 	"strings"
 	"time"
 )
@@ -450,7 +451,11 @@ func GetGridDecisions(ctx *GridContext, mcpClient mcp.AIClient, config *store.Gr
 	logger.Infof("🤖 [Grid] Calling AI for grid decisions...")
 
 	// Call AI
-	response, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	promptLang := LangEnglish
+	if lang == "zh" {
+		promptLang = LangChinese
+	}
+	response, systemPrompt, err := callDecisionAI(mcpClient, systemPrompt, userPrompt, promptLang, gridDecisionActions)
 	if err != nil {
 		return nil, fmt.Errorf("AI call failed: %w", err)
 	}
@@ -472,7 +477,10 @@ func GetGridDecisions(ctx *GridContext, mcpClient mcp.AIClient, config *store.Gr
 	logger.Infof("⏱️ [Grid] AI call duration: %d ms, decisions: %d", duration, len(decisions))
 
 	// Extract chain of thought from response
-	cotTrace := extractCoTTrace(response)
+	cotTrace, _, structured := decodeStructuredDecisions(response)
+	if !structured {
+		cotTrace = extractCoTTrace(response)
+	}
 
 	return &FullDecision{
 		SystemPrompt:        systemPrompt,
@@ -487,15 +495,16 @@ func GetGridDecisions(ctx *GridContext, mcpClient mcp.AIClient, config *store.Gr
 
 // parseGridDecisions parses AI response into grid decisions
 func parseGridDecisions(response string, symbol string) ([]Decision, error) {
-	// Try to find JSON array in response
-	jsonStr := extractJSONArray(response)
-	if jsonStr == "" {
-		return nil, fmt.Errorf("no JSON array found in response")
-	}
-
-	var decisions []Decision
-	if err := json.Unmarshal([]byte(jsonStr), &decisions); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	_, decisions, structured := decodeStructuredDecisions(response)
+	if !structured {
+		// Try to find JSON array in response
+		jsonStr := extractJSONArray(response)
+		if jsonStr == "" {
+			return nil, fmt.Errorf("no JSON array found in response")
+		}
+		if err := json.Unmarshal([]byte(jsonStr), &decisions); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
 	}
 
 	// Validate and set default symbol
@@ -531,22 +540,7 @@ func extractJSONArray(response string) string {
 
 // isValidGridAction checks if action is a valid grid action
 func isValidGridAction(action string) bool {
-	validActions := map[string]bool{
-		"place_buy_limit":   true,
-		"place_sell_limit":  true,
-		"cancel_order":      true,
-		"cancel_all_orders": true,
-		"pause_grid":        true,
-		"resume_grid":       true,
-		"adjust_grid":       true,
-		"hold":              true,
-		// Also support standard actions for compatibility
-		"open_long":  true,
-		"open_short": true,
-		"close_long": true,
-		"close_short": true,
-	}
-	return validActions[action]
+	return slices.Contains(gridDecisionActions, action)
 }
 
 // ============================================================================
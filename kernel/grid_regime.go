@@ -0,0 +1,386 @@
+package kernel
+
+import (
+	"math"
+	"nofx/market"
+	"nofx/store"
+	"time"
+)
+
+// ============================================================================
+// Box Breakout Detection
+// ============================================================================
+// Shared by live grid trading (trader) and grid backtests (backtest)
+
+// DetectBoxBreakout checks if price has broken out of any box level
+// Returns the highest breakout level and direction
+func DetectBoxBreakout(box *market.BoxData) (market.BreakoutLevel, string) {
+	if box == nil {
+		return market.BreakoutNone, ""
+	}
+
+	price := box.CurrentPrice
+
+	// Check long box first (highest priority)
+	if price > box.LongUpper {
+		return market.BreakoutLong, "up"
+	}
+	if price < box.LongLower {
+		return market.BreakoutLong, "down"
+	}
+
+	// Check mid box
+	if price > box.MidUpper {
+		return market.BreakoutMid, "up"
+	}
+	if price < box.MidLower {
+		return market.BreakoutMid, "down"
+	}
+
+	// Check short box
+	if price > box.ShortUpper {
+		return market.BreakoutShort, "up"
+	}
+	if price < box.ShortLower {
+		return market.BreakoutShort, "down"
+	}
+
+	return market.BreakoutNone, ""
+}
+
+// BreakoutConfirmRequired is the number of candles needed to confirm a breakout
+const BreakoutConfirmRequired = 3
+
+// BreakoutState tracks the current breakout state
+type BreakoutState struct {
+	Level        market.BreakoutLevel
+	Direction    string
+	ConfirmCount int
+	StartTime    time.Time
+}
+
+// ConfirmBreakout updates breakout state and returns true if breakout is confirmed
+func ConfirmBreakout(state *BreakoutState, currentLevel market.BreakoutLevel, direction string) bool {
+	return ConfirmBreakoutAt(state, currentLevel, direction, time.Now())
+}
+
+// ConfirmBreakoutAt is ConfirmBreakout with an explicit clock, used when replaying historical bars
+func ConfirmBreakoutAt(state *BreakoutState, currentLevel market.BreakoutLevel, direction string, now time.Time) bool {
+	// If price returned to box, reset state
+	if currentLevel == market.BreakoutNone {
+		state.ConfirmCount = 0
+		state.Level = market.BreakoutNone
+		state.Direction = ""
+		return false
+	}
+
+	// If same breakout continues, increment count
+	if state.Level == currentLevel && state.Direction == direction {
+		state.ConfirmCount++
+	} else {
+		// New breakout, reset count
+		state.Level = currentLevel
+		state.Direction = direction
+		state.ConfirmCount = 1
+		state.StartTime = now
+	}
+
+	return state.ConfirmCount >= BreakoutConfirmRequired
+}
+
+// ============================================================================
+// Breakout Actions
+// ============================================================================
+
+// BreakoutAction represents the action to take on breakout
+type BreakoutAction int
+
+const (
+	BreakoutActionNone            BreakoutAction = iota
+	BreakoutActionReducePosition                 // Short box breakout: reduce to 50%
+	BreakoutActionPauseGrid                      // Mid box breakout: pause grid + cancel orders
+	BreakoutActionCloseAll                       // Long box breakout: pause + cancel + close all
+	BreakoutActionAdjustDirection                // Adjust grid direction based on breakout
+)
+
+// GetBreakoutAction returns the appropriate action for a breakout level
+func GetBreakoutAction(level market.BreakoutLevel) BreakoutAction {
+	switch level {
+	case market.BreakoutShort:
+		return BreakoutActionReducePosition
+	case market.BreakoutMid:
+		return BreakoutActionPauseGrid
+	case market.BreakoutLong:
+		return BreakoutActionCloseAll
+	default:
+		return BreakoutActionNone
+	}
+}
+
+// GetBreakoutActionWithDirection returns the appropriate action for a breakout level
+// when direction adjustment is enabled
+func GetBreakoutActionWithDirection(level market.BreakoutLevel, enableDirectionAdjust bool) BreakoutAction {
+	if !enableDirectionAdjust {
+		// Fall back to original behavior
+		return GetBreakoutAction(level)
+	}
+
+	switch level {
+	case market.BreakoutShort:
+		// Short box breakout with direction adjustment: adjust direction instead of reducing position
+		return BreakoutActionAdjustDirection
+	case market.BreakoutMid:
+		// Mid box breakout with direction adjustment: adjust to full direction
+		return BreakoutActionAdjustDirection
+	case market.BreakoutLong:
+		// Long box breakout: always trigger emergency handling
+		return BreakoutActionCloseAll
+	default:
+		return BreakoutActionNone
+	}
+}
+
+// ============================================================================
+// Grid Direction Adjustment
+// ============================================================================
+
+// DetermineGridDirection determines the new grid direction based on box breakout
+// currentDirection: the current grid direction
+// breakoutLevel: which box level has been broken (short/mid/long)
+// direction: breakout direction ("up" or "down")
+// Returns: the new grid direction
+func DetermineGridDirection(box *market.BoxData, currentDirection market.GridDirection, breakoutLevel market.BreakoutLevel, direction string) market.GridDirection {
+	if box == nil {
+		return currentDirection
+	}
+
+	price := box.CurrentPrice
+
+	switch breakoutLevel {
+	case market.BreakoutShort:
+		// Short box breakout: bias direction
+		// Still within mid box, so not a full trend yet
+		if direction == "up" {
+			return market.GridDirectionLongBias
+		}
+		return market.GridDirectionShortBias
+
+	case market.BreakoutMid:
+		// Mid box breakout: full direction
+		// More significant move, commit fully
+		if direction == "up" {
+			return market.GridDirectionLong
+		}
+		return market.GridDirectionShort
+
+	case market.BreakoutLong:
+		// Long box breakout: handled by existing emergency logic
+		// Return current direction, let existing handlers take over
+		return currentDirection
+
+	case market.BreakoutNone:
+		// No breakout - check if we should recover toward neutral
+		return DetermineRecoveryDirection(price, box, currentDirection)
+
+	default:
+		return currentDirection
+	}
+}
+
+// DetermineRecoveryDirection determines if grid direction should recover toward neutral
+// This implements the gradual recovery logic: long → long_bias → neutral ← short_bias ← short
+func DetermineRecoveryDirection(price float64, box *market.BoxData, currentDirection market.GridDirection) market.GridDirection {
+	// Check if price is back inside the short box
+	insideShortBox := price >= box.ShortLower && price <= box.ShortUpper
+
+	if !insideShortBox {
+		// Still outside short box, maintain current direction
+		return currentDirection
+	}
+
+	// Price is inside short box, start recovery toward neutral
+	switch currentDirection {
+	case market.GridDirectionLong:
+		// Full long → bias long
+		return market.GridDirectionLongBias
+	case market.GridDirectionLongBias:
+		// Bias long → neutral
+		return market.GridDirectionNeutral
+	case market.GridDirectionShort:
+		// Full short → bias short
+		return market.GridDirectionShortBias
+	case market.GridDirectionShortBias:
+		// Bias short → neutral
+		return market.GridDirectionNeutral
+	default:
+		return currentDirection
+	}
+}
+
+// ShouldRecoverDirection checks if the current grid direction should start recovering toward neutral
+func ShouldRecoverDirection(box *market.BoxData, currentDirection market.GridDirection) bool {
+	if box == nil || currentDirection == market.GridDirectionNeutral {
+		return false
+	}
+
+	price := box.CurrentPrice
+	// Check if price is back inside the short box
+	return price >= box.ShortLower && price <= box.ShortUpper
+}
+
+// ============================================================================
+// Grid Layout
+// ============================================================================
+
+// DefaultGridBounds returns ±3% around price, scaled by grid count
+func DefaultGridBounds(price float64, gridCount int) (upper, lower float64) {
+	multiplier := 0.03 * float64(gridCount) / 10
+	return price * (1 + multiplier), price * (1 - multiplier)
+}
+
+// ATRGridBounds returns price ± ATR*multiplier (default multiplier 2).
+// Falls back to DefaultGridBounds when ATR is unknown.
+func ATRGridBounds(price, atr float64, config *store.GridStrategyConfig) (upper, lower float64) {
+	if atr <= 0 {
+		return DefaultGridBounds(price, config.GridCount)
+	}
+
+	multiplier := config.ATRMultiplier
+	if multiplier <= 0 {
+		multiplier = 2.0
+	}
+
+	halfRange := atr * multiplier
+	return price + halfRange, price - halfRange
+}
+
+// BuildGridLevels creates GridCount empty levels from lower to upper, allocating
+// TotalInvestment by the configured distribution (uniform / gaussian / pyramid).
+// Levels below the current price buy, levels above sell.
+func BuildGridLevels(lower, spacing, currentPrice float64, config *store.GridStrategyConfig) []GridLevelInfo {
+	levels := make([]GridLevelInfo, config.GridCount)
+	totalWeight := 0.0
+	weights := make([]float64, config.GridCount)
+
+	// Calculate weights based on distribution
+	for i := 0; i < config.GridCount; i++ {
+		switch config.Distribution {
+		case "gaussian":
+			// Gaussian distribution - more weight in the middle
+			center := float64(config.GridCount-1) / 2
+			sigma := float64(config.GridCount) / 4
+			weights[i] = math.Exp(-math.Pow(float64(i)-center, 2) / (2 * sigma * sigma))
+		case "pyramid":
+			// Pyramid - more weight at bottom
+			weights[i] = float64(config.GridCount - i)
+		default: // uniform
+			weights[i] = 1.0
+		}
+		totalWeight += weights[i]
+	}
+
+	// Create levels
+	for i := 0; i < config.GridCount; i++ {
+		price := lower + float64(i)*spacing
+		allocatedUSD := config.TotalInvestment * weights[i] / totalWeight
+
+		// Determine initial side (below current price = buy, above = sell)
+		side := "buy"
+		if price > currentPrice {
+			side = "sell"
+		}
+
+		levels[i] = GridLevelInfo{
+			Index:        i,
+			Price:        price,
+			State:        "empty",
+			Side:         side,
+			AllocatedUSD: allocatedUSD,
+		}
+	}
+
+	return levels
+}
+
+// ApplyGridDirection reassigns level sides for a grid direction.
+// biasRatio is the buy (or sell) share for biased directions; out-of-range values default to 0.7.
+// Returns the buy ratio the sides were assigned with.
+func ApplyGridDirection(levels []GridLevelInfo, direction market.GridDirection, biasRatio, currentPrice float64) float64 {
+	if biasRatio <= 0 || biasRatio > 1 {
+		biasRatio = 0.7
+	}
+
+	buyRatio, _ := direction.GetBuySellRatio(biasRatio)
+
+	// Calculate how many levels should be buy vs sell based on direction
+	totalLevels := len(levels)
+	targetBuyLevels := int(float64(totalLevels) * buyRatio)
+
+	// For neutral: use price-based assignment (buy below, sell above)
+	if direction == market.GridDirectionNeutral {
+		for i := range levels {
+			if levels[i].Price <= currentPrice {
+				levels[i].Side = "buy"
+			} else {
+				levels[i].Side = "sell"
+			}
+		}
+		return buyRatio
+	}
+
+	// For long/long_bias: more buy levels
+	// For short/short_bias: more sell levels
+	switch direction {
+	case market.GridDirectionLong:
+		// 100% buy - all levels are buy
+		for i := range levels {
+			levels[i].Side = "buy"
+		}
+
+	case market.GridDirectionShort:
+		// 100% sell - all levels are sell
+		for i := range levels {
+			levels[i].Side = "sell"
+		}
+
+	case market.GridDirectionLongBias, market.GridDirectionShortBias:
+		// Assign sides based on position relative to current price
+		// For long_bias: keep all below as buy, convert some above to buy
+		// For short_bias: keep all above as sell, convert some below to sell
+		buyCount := 0
+		sellCount := 0
+
+		for i := range levels {
+			needMoreBuys := buyCount < targetBuyLevels
+			needMoreSells := sellCount < (totalLevels - targetBuyLevels)
+
+			if levels[i].Price <= currentPrice {
+				// Level below or at current price
+				if needMoreBuys {
+					levels[i].Side = "buy"
+					buyCount++
+				} else {
+					levels[i].Side = "sell"
+					sellCount++
+				}
+			} else {
+				// Level above current price
+				if needMoreSells && direction == market.GridDirectionShortBias {
+					levels[i].Side = "sell"
+					sellCount++
+				} else if needMoreBuys && direction == market.GridDirectionLongBias {
+					levels[i].Side = "buy"
+					buyCount++
+				} else if needMoreSells {
+					levels[i].Side = "sell"
+					sellCount++
+				} else {
+					levels[i].Side = "buy"
+					buyCount++
+				}
+			}
+		}
+	}
+
+	return buyRatio
+}
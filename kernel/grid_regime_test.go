@@ -0,0 +1,116 @@
+package kernel
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+func TestGridBounds(t *testing.T) {
+	upper, lower := DefaultGridBounds(100, 10)
+	if math.Abs(upper-103) > 1e-9 || math.Abs(lower-97) > 1e-9 {
+		t.Errorf("Expected default bounds 97-103, got %.4f-%.4f", lower, upper)
+	}
+
+	config := &store.GridStrategyConfig{GridCount: 10}
+	upper, lower = ATRGridBounds(100, 1.5, config)
+	if upper != 103 || lower != 97 {
+		t.Errorf("Expected ATR bounds 97-103 with default multiplier, got %.4f-%.4f", lower, upper)
+	}
+
+	// Unknown ATR falls back to default bounds
+	config.GridCount = 20
+	upper, lower = ATRGridBounds(100, 0, config)
+	if math.Abs(upper-106) > 1e-9 || math.Abs(lower-94) > 1e-9 {
+		t.Errorf("Expected fallback bounds 94-106, got %.4f-%.4f", lower, upper)
+	}
+}
+
+func TestBuildGridLevels(t *testing.T) {
+	config := &store.GridStrategyConfig{GridCount: 5, TotalInvestment: 1000, Distribution: "pyramid"}
+	levels := BuildGridLevels(90, 5, 101, config)
+
+	if len(levels) != 5 {
+		t.Fatalf("Expected 5 levels, got %d", len(levels))
+	}
+
+	total := 0.0
+	for i, level := range levels {
+		if level.Price != 90+float64(i)*5 {
+			t.Errorf("Level %d: expected price %.2f, got %.2f", i, 90+float64(i)*5, level.Price)
+		}
+		wantSide := "buy"
+		if level.Price > 101 {
+			wantSide = "sell"
+		}
+		if level.Side != wantSide || level.State != "empty" {
+			t.Errorf("Level %d: expected empty %s, got %s %s", i, wantSide, level.State, level.Side)
+		}
+		total += level.AllocatedUSD
+	}
+
+	if math.Abs(total-1000) > 1e-9 {
+		t.Errorf("Expected allocations to sum to 1000, got %.4f", total)
+	}
+	if levels[0].AllocatedUSD <= levels[4].AllocatedUSD {
+		t.Errorf("Pyramid should weight the bottom level more: %.2f vs %.2f", levels[0].AllocatedUSD, levels[4].AllocatedUSD)
+	}
+}
+
+func TestApplyGridDirection(t *testing.T) {
+	config := &store.GridStrategyConfig{GridCount: 10, TotalInvestment: 1000}
+	countBuys := func(levels []GridLevelInfo) int {
+		buys := 0
+		for _, level := range levels {
+			if level.Side == "buy" {
+				buys++
+			}
+		}
+		return buys
+	}
+
+	tests := []struct {
+		direction market.GridDirection
+		wantBuys  int
+		wantRatio float64
+	}{
+		{market.GridDirectionNeutral, 5, 0.5},
+		{market.GridDirectionLong, 10, 1},
+		{market.GridDirectionShort, 0, 0},
+		{market.GridDirectionLongBias, 7, 0.7},
+		{market.GridDirectionShortBias, 3, 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.direction), func(t *testing.T) {
+			levels := BuildGridLevels(90, 2, 99, config)
+			ratio := ApplyGridDirection(levels, tt.direction, 0.7, 99)
+			if got := countBuys(levels); got != tt.wantBuys {
+				t.Errorf("Expected %d buy levels, got %d", tt.wantBuys, got)
+			}
+			if math.Abs(ratio-tt.wantRatio) > 1e-9 {
+				t.Errorf("Expected buy ratio %.2f, got %.2f", tt.wantRatio, ratio)
+			}
+		})
+	}
+}
+
+func TestConfirmBreakoutAt(t *testing.T) {
+	state := &BreakoutState{Level: market.BreakoutNone}
+	start := time.UnixMilli(1_700_000_000_000)
+
+	for i := 0; i < BreakoutConfirmRequired-1; i++ {
+		if ConfirmBreakoutAt(state, market.BreakoutMid, "down", start.Add(time.Duration(i)*time.Hour)) {
+			t.Fatalf("Breakout confirmed too early at candle %d", i+1)
+		}
+	}
+	if !ConfirmBreakoutAt(state, market.BreakoutMid, "down", start.Add(3*time.Hour)) {
+		t.Errorf("Expected breakout confirmed after %d candles", BreakoutConfirmRequired)
+	}
+	if !state.StartTime.Equal(start) {
+		t.Errorf("Expected start time from the first candle, got %v", state.StartTime)
+	}
+}
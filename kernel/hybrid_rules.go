@@ -0,0 +1,261 @@
+package kernel
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"nofx/logger"
+	"nofx/market"
+	"nofx/store"
+)
+
+// ============================================================================
+// Hybrid Mode (rule pre-filters and post-validators around AI decisions)
+// ============================================================================
+
+// Validator sides
+const (
+	validatorSideLong  = "long"
+	validatorSideShort = "short"
+	validatorSideBoth  = "both"
+)
+
+// HybridRules compiled hybrid rules of an AI strategy
+type HybridRules struct {
+	PreFilters           []*Rule
+	Validators           []HybridValidator
+	EnforceMinConfidence bool
+	EnforceRiskReward    bool
+}
+
+// HybridValidator compiled store.RuleValidator
+type HybridValidator struct {
+	BlockWhen *Rule
+	Side      string // long, short or both
+	Reason    string
+}
+
+// RejectedDecision AI decision rejected by a hybrid rule
+type RejectedDecision struct {
+	Decision
+	RejectReason string `json:"reject_reason"`
+}
+
+// CompileHybridRules parses and validates hybrid rules; a nil cfg compiles to nil
+func CompileHybridRules(cfg *store.HybridRuleConfig) (*HybridRules, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	h := &HybridRules{EnforceMinConfidence: cfg.EnforceMinConfidence, EnforceRiskReward: cfg.EnforceRiskReward}
+	for i, text := range cfg.PreFilters {
+		rule, err := ParseRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("pre_filters[%d]: %w", i, err)
+		}
+		h.PreFilters = append(h.PreFilters, rule)
+	}
+	for i, v := range cfg.Validators {
+		rule, err := ParseRule(v.BlockWhen)
+		if err != nil {
+			return nil, fmt.Errorf("validators[%d]: %w", i, err)
+		}
+		side := strings.ToLower(strings.TrimSpace(v.Side))
+		switch side {
+		case "":
+			side = validatorSideBoth
+		case validatorSideLong, validatorSideShort, validatorSideBoth:
+		default:
+			return nil, fmt.Errorf("validators[%d]: side must be long, short or both, got %q", i, v.Side)
+		}
+		reason := strings.TrimSpace(v.Reason)
+		if reason == "" {
+			reason = rule.String()
+		}
+		h.Validators = append(h.Validators, HybridValidator{BlockWhen: rule, Side: side, Reason: reason})
+	}
+	return h, nil
+}
+
+func (h *HybridRules) rules() []*Rule {
+	rules := append([]*Rule(nil), h.PreFilters...)
+	for _, v := range h.Validators {
+		rules = append(rules, v.BlockWhen)
+	}
+	return rules
+}
+
+// Timeframes returns the timeframes the rules name explicitly (besides the primary timeframe)
+func (h *HybridRules) Timeframes() []string {
+	return rulesTimeframes(h.rules())
+}
+
+// Lookback returns the number of klines per timeframe the rules need
+func (h *HybridRules) Lookback() int {
+	return rulesLookback(h.rules())
+}
+
+// hybridRules returns the strategy's compiled hybrid rules, nil when there are none.
+// Rule and grid strategies don't ask the AI, so they have no hybrid rules.
+func (e *StrategyEngine) hybridRules() *HybridRules {
+	if e.config.HybridRules == nil || e.config.IsRuleStrategy() || e.config.StrategyType == "grid_trading" {
+		return nil
+	}
+	h, err := CompileHybridRules(e.config.HybridRules)
+	if err != nil {
+		logger.Warnf("⚠️  Invalid hybrid rules ignored: %v", err)
+		return nil
+	}
+	return h
+}
+
+// liveKlineSource fetches klines once per symbol and timeframe
+func liveKlineSource(limit int) KlineSource {
+	var mu sync.Mutex
+	cache := make(map[string][]market.Kline)
+	return func(symbol, timeframe string) []market.Kline {
+		mu.Lock()
+		defer mu.Unlock()
+		key := symbol + "|" + timeframe
+		if klines, ok := cache[key]; ok {
+			return klines
+		}
+		klines, err := market.GetKlines(symbol, timeframe, limit)
+		if err != nil {
+			logger.Infof("⚠️  Failed to fetch %s %s klines for hybrid rules: %v", symbol, timeframe, err)
+		}
+		cache[key] = klines
+		return klines
+	}
+}
+
+// ruleInput evaluation input of a symbol; live klines are fetched unless ctx provides them
+func (h *HybridRules) ruleInput(ctx *Context, engine *StrategyEngine, symbol string) RuleInput {
+	if ctx.RuleKlines == nil {
+		ctx.RuleKlines = liveKlineSource(h.Lookback())
+	}
+	klines := ctx.RuleKlines
+	_, primaryTimeframe, _ := engine.klineSettings()
+	return RuleInput{
+		Data:             ctx.MarketDataMap[symbol],
+		Klines:           func(tf string) []market.Kline { return klines(symbol, tf) },
+		PrimaryTimeframe: primaryTimeframe,
+	}
+}
+
+// applyHybridPreFilters removes the candidate coins failing a pre-filter from ctx and records why in
+// ctx.FilteredCandidates. Filters that can't be evaluated (e.g. missing data) exclude the coin.
+// Held coins are kept so the AI still sees their positions.
+func applyHybridPreFilters(ctx *Context, engine *StrategyEngine) {
+	h := engine.hybridRules()
+	if h == nil || len(h.PreFilters) == 0 || ctx.FilteredCandidates != nil {
+		return
+	}
+	held := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		held[market.Normalize(pos.Symbol)] = true
+	}
+
+	ctx.FilteredCandidates = make(map[string]string)
+	kept := make([]CandidateCoin, 0, len(ctx.CandidateCoins))
+	for _, coin := range ctx.CandidateCoins {
+		if held[market.Normalize(coin.Symbol)] {
+			kept = append(kept, coin)
+			continue
+		}
+		in := h.ruleInput(ctx, engine, coin.Symbol)
+		reason := ""
+		for _, rule := range h.PreFilters {
+			ok, err := rule.Eval(in)
+			if err != nil {
+				reason = fmt.Sprintf("pre-filter %q not evaluated: %v", rule, err)
+				break
+			}
+			if !ok {
+				reason = fmt.Sprintf("pre-filter %q not matched", rule)
+				break
+			}
+		}
+		if reason != "" {
+			ctx.FilteredCandidates[coin.Symbol] = reason
+			continue
+		}
+		kept = append(kept, coin)
+	}
+	if len(ctx.FilteredCandidates) > 0 {
+		logger.Infof("🔎 Hybrid pre-filters kept %d of %d candidate coins", len(kept), len(ctx.CandidateCoins))
+	}
+	ctx.CandidateCoins = kept
+}
+
+// applyHybridValidators moves the AI entries violating a hybrid rule from decision.Decisions to
+// decision.Rejected. Validators that can't be evaluated reject the entry.
+func applyHybridValidators(ctx *Context, engine *StrategyEngine, decision *FullDecision) {
+	h := engine.hybridRules()
+	if h == nil || decision == nil {
+		return
+	}
+	kept := make([]Decision, 0, len(decision.Decisions))
+	for _, d := range decision.Decisions {
+		reason := h.rejectReason(ctx, engine, d)
+		if reason == "" {
+			kept = append(kept, d)
+			continue
+		}
+		logger.Infof("🚫 %s %s rejected by hybrid rules: %s", d.Symbol, d.Action, reason)
+		decision.Rejected = append(decision.Rejected, RejectedDecision{Decision: d, RejectReason: reason})
+	}
+	decision.Decisions = kept
+}
+
+// rejectReason returns why an AI decision violates the hybrid rules, empty if it doesn't
+func (h *HybridRules) rejectReason(ctx *Context, engine *StrategyEngine, d Decision) string {
+	var side string
+	switch d.Action {
+	case "open_long":
+		side = validatorSideLong
+	case "open_short":
+		side = validatorSideShort
+	default:
+		return ""
+	}
+	risk := engine.GetRiskControlConfig()
+
+	if h.EnforceMinConfidence && risk.MinConfidence > 0 && d.Confidence < risk.MinConfidence {
+		return fmt.Sprintf("confidence %d below minimum %d", d.Confidence, risk.MinConfidence)
+	}
+	if h.EnforceRiskReward && risk.MinRiskRewardRatio > 0 {
+		data := ctx.MarketDataMap[d.Symbol]
+		if data == nil || data.CurrentPrice <= 0 {
+			return "no current price to check risk/reward"
+		}
+		reward, loss := d.TakeProfit-data.CurrentPrice, data.CurrentPrice-d.StopLoss
+		if side == validatorSideShort {
+			reward, loss = -reward, -loss
+		}
+		if reward <= 0 || loss <= 0 {
+			return fmt.Sprintf("stop loss %.4f / take profit %.4f are not on both sides of price %.4f", d.StopLoss, d.TakeProfit, data.CurrentPrice)
+		}
+		if ratio := reward / loss; ratio < risk.MinRiskRewardRatio {
+			return fmt.Sprintf("risk/reward 1:%.2f below 1:%.1f", ratio, risk.MinRiskRewardRatio)
+		}
+	}
+
+	if len(h.Validators) == 0 {
+		return ""
+	}
+	in := h.ruleInput(ctx, engine, d.Symbol)
+	for _, v := range h.Validators {
+		if v.Side != validatorSideBoth && v.Side != side {
+			continue
+		}
+		blocked, err := v.BlockWhen.Eval(in)
+		if err != nil {
+			return fmt.Sprintf("validator %q not evaluated: %v", v.BlockWhen, err)
+		}
+		if blocked {
+			return v.Reason
+		}
+	}
+	return ""
+}
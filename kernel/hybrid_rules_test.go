@@ -0,0 +1,128 @@
+package kernel
+
+import (
+	"strings"
+	"testing"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+func TestCompileHybridRules(t *testing.T) {
+	if h, err := CompileHybridRules(nil); h != nil || err != nil {
+		t.Errorf("nil config: got %v, %v", h, err)
+	}
+	h, err := CompileHybridRules(&store.HybridRuleConfig{
+		PreFilters: []string{"volume > 0 on 15m"},
+		Validators: []store.RuleValidator{
+			{BlockWhen: "close < ema200 on 4h", Side: "Long"},
+			{BlockWhen: "funding_rate > 0.1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Validators[0].Side != "long" || h.Validators[1].Side != "both" || h.Validators[1].Reason != "funding_rate > 0.1" {
+		t.Errorf("unexpected validators: %+v", h.Validators)
+	}
+	if got := strings.Join(h.Timeframes(), ","); got != "15m,4h" {
+		t.Errorf("timeframes: got %q", got)
+	}
+
+	for _, cfg := range []*store.HybridRuleConfig{
+		{PreFilters: []string{"close >"}},
+		{Validators: []store.RuleValidator{{BlockWhen: ""}}},
+		{Validators: []store.RuleValidator{{BlockWhen: "close > 1", Side: "up"}}},
+	} {
+		if _, err := CompileHybridRules(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestHybridPreFiltersAndValidators(t *testing.T) {
+	engine := NewStrategyEngine(&store.StrategyConfig{
+		HybridRules: &store.HybridRuleConfig{
+			PreFilters: []string{"close > sma20"},
+			Validators: []store.RuleValidator{
+				{BlockWhen: "close < sma20 on 4h", Side: "long", Reason: "4h downtrend"},
+				{BlockWhen: "funding_rate > 0.1", Side: "long"},
+			},
+			EnforceMinConfidence: true,
+			EnforceRiskReward:    true,
+		},
+		RiskControl: store.RiskControlConfig{MinConfidence: 70, MinRiskRewardRatio: 2},
+	})
+
+	series := map[string][]market.Kline{
+		"BTCUSDT|5m":  ruleKlines(flatThen(30, 100, 110)...),
+		"BTCUSDT|4h":  ruleKlines(flatThen(30, 100, 110)...),
+		"ETHUSDT|5m":  ruleKlines(flatThen(30, 100, 90)...), // Below its average: filtered out
+		"SOLUSDT|5m":  ruleKlines(flatThen(30, 100, 110)...),
+		"SOLUSDT|4h":  ruleKlines(flatThen(30, 100, 90)...), // 4h downtrend
+		"DOGEUSDT|5m": ruleKlines(flatThen(30, 100, 110)...),
+		"DOGEUSDT|4h": ruleKlines(flatThen(30, 100, 110)...),
+	}
+	ctx := &Context{
+		Positions:      []PositionInfo{{Symbol: "XRPUSDT", Side: "long"}},
+		CandidateCoins: []CandidateCoin{{Symbol: "BTCUSDT"}, {Symbol: "ETHUSDT"}, {Symbol: "SOLUSDT"}, {Symbol: "DOGEUSDT"}, {Symbol: "XRPUSDT"}, {Symbol: "ADAUSDT"}},
+		MarketDataMap: map[string]*market.Data{
+			"BTCUSDT":  {CurrentPrice: 100, FundingRate: 0.0001},
+			"SOLUSDT":  {CurrentPrice: 100},
+			"DOGEUSDT": {CurrentPrice: 100, FundingRate: 0.002},
+		},
+		RuleKlines: func(symbol, timeframe string) []market.Kline { return series[symbol+"|"+timeframe] },
+	}
+	engine.config.Indicators.Klines.PrimaryTimeframe = "5m"
+
+	applyHybridPreFilters(ctx, engine)
+	var kept []string
+	for _, coin := range ctx.CandidateCoins {
+		kept = append(kept, coin.Symbol)
+	}
+	if got := strings.Join(kept, ","); got != "BTCUSDT,SOLUSDT,DOGEUSDT,XRPUSDT" {
+		t.Errorf("kept candidates: got %s", got)
+	}
+	if !strings.Contains(ctx.FilteredCandidates["ETHUSDT"], "not matched") || !strings.Contains(ctx.FilteredCandidates["ADAUSDT"], "not evaluated") {
+		t.Errorf("unexpected filter reasons: %v", ctx.FilteredCandidates)
+	}
+
+	long := func(symbol string, confidence int, sl, tp float64) Decision {
+		return Decision{Symbol: symbol, Action: "open_long", Confidence: confidence, StopLoss: sl, TakeProfit: tp}
+	}
+	decision := &FullDecision{Decisions: []Decision{
+		long("BTCUSDT", 80, 95, 110),  // Passes
+		long("BTCUSDT", 60, 95, 110),  // Low confidence
+		long("BTCUSDT", 80, 95, 105),  // 1:1 risk/reward
+		long("SOLUSDT", 80, 95, 110),  // 4h downtrend
+		long("DOGEUSDT", 80, 95, 110), // Funding too high
+		{Symbol: "SOLUSDT", Action: "open_short", Confidence: 80, StopLoss: 105, TakeProfit: 85}, // Long validators don't apply
+		{Symbol: "XRPUSDT", Action: "close_long"},                                                // Closes are never rejected
+	}}
+	applyHybridValidators(ctx, engine, decision)
+
+	if len(decision.Decisions) != 3 || decision.Decisions[1].Action != "open_short" || decision.Decisions[2].Action != "close_long" {
+		t.Errorf("unexpected kept decisions: %+v", decision.Decisions)
+	}
+	wantReasons := []string{
+		"confidence 60 below minimum 70",
+		"risk/reward 1:1.00 below 1:2.0",
+		"4h downtrend",
+		"funding_rate > 0.1",
+	}
+	if len(decision.Rejected) != len(wantReasons) {
+		t.Fatalf("got %d rejections, want %d: %+v", len(decision.Rejected), len(wantReasons), decision.Rejected)
+	}
+	for i, want := range wantReasons {
+		if decision.Rejected[i].RejectReason != want {
+			t.Errorf("rejection %d: got %q, want %q", i, decision.Rejected[i].RejectReason, want)
+		}
+	}
+
+	// Rule strategies have no hybrid rules
+	engine.config.StrategyType = store.StrategyTypeRules
+	engine.config.RuleConfig = &store.RuleStrategyConfig{EntryLong: "close > 1"}
+	if engine.hybridRules() != nil {
+		t.Error("rule strategies should ignore hybrid rules")
+	}
+}
@@ -0,0 +1,195 @@
+package kernel
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"nofx/logger"
+	"nofx/mcp"
+)
+
+// ============================================================================
+// Structured Output (provider-native JSON schema for decisions)
+// ============================================================================
+
+const decisionSchemaName = "trading_decisions"
+
+var (
+	// standardDecisionActions actions accepted from the AI trading strategy
+	standardDecisionActions = []string{
+		"open_long", "open_short", "close_long", "close_short", "hold", "wait",
+		"set_trailing_stop", "move_to_breakeven",
+	}
+
+	// gridDecisionActions actions accepted from the grid strategy (standard actions kept for compatibility)
+	gridDecisionActions = []string{
+		"place_buy_limit", "place_sell_limit", "cancel_order", "cancel_all_orders",
+		"pause_grid", "resume_grid", "adjust_grid", "hold",
+		"open_long", "open_short", "close_long", "close_short",
+	}
+
+	decisionFieldDescriptions = map[string]string{
+		"symbol":            "Trading pair, e.g. BTCUSDT",
+		"action":            "Decision action",
+		"leverage":          "Exchange leverage (open actions)",
+		"position_size_usd": "Position notional value in USDT (open actions)",
+		"stop_loss":         "Stop loss price",
+		"take_profit":       "Take profit price",
+		"trailing_stop_pct": "Trailing stop callback in percent of price (open and set_trailing_stop actions)",
+		"price":             "Limit order price (grid)",
+		"quantity":          "Order quantity (grid)",
+		"level_index":       "Grid level index (grid)",
+		"order_id":          "Order ID to cancel (grid)",
+		"confidence":        "Confidence level 0-100",
+		"risk_usd":          "Maximum USD risk",
+		"reasoning":         "Short reason for this decision",
+
+		// Take-profit ladder, fields of nested objects are keyed by "<field>.<nested field>"
+		"take_profit_levels":            "Scaled exits (open actions), each closing pct percent of the position",
+		"take_profit_levels.price":      "Take profit price of the level",
+		"take_profit_levels.r_multiple": "Take profit at this multiple of the entry to stop loss distance (when price is null)",
+		"take_profit_levels.pct":        "Percent of the opened quantity closed at this level",
+	}
+
+	// decisionRequiredFields fields that must be non-null; all others may be null
+	decisionRequiredFields = map[string]bool{"symbol": true, "action": true, "reasoning": true}
+)
+
+// decisionResponseSchema builds the response JSON Schema from the Decision struct.
+// Every property is listed as required with optional ones nullable, as strict mode demands.
+func decisionResponseSchema(actions []string) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"reasoning": map[string]any{
+				"type":        "string",
+				"description": "Chain of thought: market analysis behind the decisions",
+			},
+			"decisions": map[string]any{
+				"type":  "array",
+				"items": objectSchema(reflect.TypeOf(Decision{}), "", actions),
+			},
+		},
+		"required":             []string{"reasoning", "decisions"},
+		"additionalProperties": false,
+	}
+}
+
+// objectSchema builds the strict-mode schema of a struct; descriptions and required fields of
+// nested structs are looked up as "<field>.<nested field>"
+func objectSchema(t reflect.Type, prefix string, actions []string) map[string]any {
+	properties := make(map[string]any)
+	var names []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property := map[string]any{}
+		var jsonType string
+		switch field.Type.Kind() {
+		case reflect.String:
+			jsonType = "string"
+		case reflect.Int, reflect.Int64:
+			jsonType = "integer"
+		case reflect.Float64:
+			jsonType = "number"
+		case reflect.Slice:
+			if field.Type.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			jsonType = "array"
+			property["items"] = objectSchema(field.Type.Elem(), prefix+name+".", nil)
+		default:
+			continue
+		}
+
+		property["type"] = jsonType
+		if !decisionRequiredFields[prefix+name] {
+			property["type"] = []string{jsonType, "null"}
+		}
+		if name == "action" && prefix == "" {
+			property["enum"] = actions
+		}
+		if desc, ok := decisionFieldDescriptions[prefix+name]; ok {
+			property["description"] = desc
+		}
+		properties[name] = property
+		names = append(names, name)
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             names,
+		"additionalProperties": false,
+	}
+}
+
+// structuredDecisionResponse the JSON object returned in structured output mode
+type structuredDecisionResponse struct {
+	Reasoning string      `json:"reasoning"`
+	Decisions *[]Decision `json:"decisions"`
+}
+
+// decodeStructuredDecisions parses a structured output response. ok is false for free-form
+// text (or a malformed object), which is then handled by the text extraction path.
+func decodeStructuredDecisions(response string) (cotTrace string, decisions []Decision, ok bool) {
+	s := strings.TrimSpace(response)
+	if !strings.HasPrefix(s, "{") {
+		return "", nil, false
+	}
+	var parsed structuredDecisionResponse
+	if err := json.Unmarshal([]byte(s), &parsed); err != nil || parsed.Decisions == nil {
+		return "", nil, false
+	}
+	return strings.TrimSpace(parsed.Reasoning), *parsed.Decisions, true
+}
+
+// structuredOutputPrompt overrides the text output format of the system prompt
+func structuredOutputPrompt(lang Language) string {
+	if lang == LangChinese {
+		return "\n\n# 输出格式（覆盖上文格式要求）\n\n" +
+			"请只输出一个符合给定 JSON Schema 的 JSON 对象：思维链分析写入 \"reasoning\"，决策数组写入 \"decisions\"。" +
+			"不适用的字段填 null。\n"
+	}
+	return "\n\n# Output Format (overrides the format above)\n\n" +
+		"Respond only with a JSON object matching the provided JSON Schema: put your chain of thought analysis in " +
+		"\"reasoning\" and the decision array in \"decisions\". Use null for fields that do not apply.\n"
+}
+
+// callDecisionAI requests decisions with provider-native structured output when the client
+// supports it, falling back to free-form text otherwise. Returns the system prompt actually sent.
+func callDecisionAI(mcpClient mcp.AIClient, systemPrompt, userPrompt string, lang Language, actions []string) (string, string, error) {
+	if caller, ok := mcpClient.(mcp.StructuredCaller); ok && caller.StructuredOutputMode() != mcp.StructuredOutputNone {
+		structuredPrompt := systemPrompt + structuredOutputPrompt(lang)
+		req, err := mcp.NewRequestBuilder().
+			WithSystemPrompt(structuredPrompt).
+			WithUserPrompt(userPrompt).
+			WithJSONSchema(decisionSchemaName, decisionResponseSchema(actions)).
+			Build()
+		if err != nil {
+			return "", "", err
+		}
+
+		response, err := caller.CallStructured(req)
+		switch {
+		case err == nil:
+			return response, structuredPrompt, nil
+		case errors.Is(err, mcp.ErrStructuredOutputUnsupported):
+		case strings.Contains(err.Error(), "status 400"):
+			// Endpoint rejected the structured request (e.g. a proxy without response_format support)
+			logger.Warnf("⚠️  Structured output rejected, falling back to text output: %v", err)
+		default:
+			return "", "", err
+		}
+	}
+
+	response, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	return response, systemPrompt, err
+}
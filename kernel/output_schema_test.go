@@ -0,0 +1,93 @@
+package kernel
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecisionResponseSchema(t *testing.T) {
+	schema := decisionResponseSchema(standardDecisionActions)
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("schema should be JSON serializable: %v", err)
+	}
+
+	items := schema["properties"].(map[string]any)["decisions"].(map[string]any)["items"].(map[string]any)
+	properties := items["properties"].(map[string]any)
+	required := items["required"].([]string)
+
+	// Strict mode: every property is required, optional ones are nullable
+	if len(required) != len(properties) {
+		t.Errorf("expected all %d properties required, got %d", len(properties), len(required))
+	}
+	for _, name := range []string{"symbol", "action", "leverage", "position_size_usd", "stop_loss", "level_index", "order_id", "reasoning"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("missing property %s", name)
+		}
+	}
+	if typ := properties["symbol"].(map[string]any)["type"]; typ != "string" {
+		t.Errorf("symbol should be a non-null string, got %v", typ)
+	}
+	if typ := properties["leverage"].(map[string]any)["type"].([]string); typ[0] != "integer" || typ[1] != "null" {
+		t.Errorf("leverage should be a nullable integer, got %v", typ)
+	}
+	ladder := properties["take_profit_levels"].(map[string]any)
+	if typ := ladder["type"].([]string); typ[0] != "array" || typ[1] != "null" {
+		t.Errorf("take_profit_levels should be a nullable array, got %v", typ)
+	}
+	level := ladder["items"].(map[string]any)
+	if levelProps := level["properties"].(map[string]any); len(levelProps) != 3 || len(level["required"].([]string)) != 3 {
+		t.Errorf("unexpected take profit level schema: %v", level)
+	} else if _, ok := levelProps["pct"].(map[string]any)["description"]; !ok {
+		t.Error("take profit level fields should be described")
+	}
+	actions := properties["action"].(map[string]any)["enum"].([]string)
+	if len(actions) != len(standardDecisionActions) {
+		t.Errorf("unexpected action enum: %v", actions)
+	}
+
+	gridItems := decisionResponseSchema(gridDecisionActions)["properties"].(map[string]any)["decisions"].(map[string]any)["items"].(map[string]any)
+	gridActions := gridItems["properties"].(map[string]any)["action"].(map[string]any)["enum"].([]string)
+	if gridActions[0] != "place_buy_limit" {
+		t.Errorf("grid schema should use grid actions, got %v", gridActions)
+	}
+}
+
+func TestParseFullDecisionResponse_Structured(t *testing.T) {
+	response := `{"reasoning":"BTC ranging, no edge","decisions":[
+		{"symbol":"BTCUSDT","action":"hold","leverage":null,"position_size_usd":null,"stop_loss":null,"take_profit":null,
+		 "price":null,"quantity":null,"level_index":null,"order_id":null,"confidence":60,"risk_usd":null,"reasoning":"wait for breakout"}]}`
+
+	decision, err := parseFullDecisionResponse(response, 1000, 10, 5, 1, 1)
+	if err != nil {
+		t.Fatalf("parseFullDecisionResponse: %v", err)
+	}
+	if decision.CoTTrace != "BTC ranging, no edge" {
+		t.Errorf("unexpected CoT trace: %q", decision.CoTTrace)
+	}
+	if len(decision.Decisions) != 1 || decision.Decisions[0].Action != "hold" || decision.Decisions[0].Confidence != 60 {
+		t.Errorf("unexpected decisions: %+v", decision.Decisions)
+	}
+
+	// Free-form text still goes through extraction
+	text := "<reasoning>analysis</reasoning><decision>[{\"symbol\":\"ETHUSDT\",\"action\":\"wait\",\"reasoning\":\"no setup\"}]</decision>"
+	decision, err = parseFullDecisionResponse(text, 1000, 10, 5, 1, 1)
+	if err != nil || decision.CoTTrace != "analysis" || decision.Decisions[0].Symbol != "ETHUSDT" {
+		t.Errorf("text fallback failed: %+v err=%v", decision, err)
+	}
+
+	// Objects without a decisions field are not treated as structured output
+	if _, _, ok := decodeStructuredDecisions(`{"reasoning":"x"}`); ok {
+		t.Error("object without decisions should not decode as structured output")
+	}
+}
+
+func TestParseGridDecisions_Structured(t *testing.T) {
+	response := `{"reasoning":"price near level 2","decisions":[{"symbol":"","action":"place_buy_limit","price":94000,"quantity":0.01,"level_index":2,"reasoning":"level 2"}]}`
+	decisions, err := parseGridDecisions(response, "BTCUSDT")
+	if err != nil {
+		t.Fatalf("parseGridDecisions: %v", err)
+	}
+	if len(decisions) != 1 || decisions[0].Symbol != "BTCUSDT" || decisions[0].Price != 94000 {
+		t.Errorf("unexpected grid decisions: %+v", decisions)
+	}
+}
@@ -0,0 +1,380 @@
+package kernel
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+)
+
+// ============================================================================
+// User Prompt Budgeting (small context windows, e.g. local models)
+// ============================================================================
+
+const (
+	minPromptKlines     = 10 // Fewest raw klines kept per timeframe before candidates are dropped
+	minPromptCandidates = 3  // Fewest candidates kept before series are summarized
+)
+
+// promptDetail how much market data the user prompt carries
+type promptDetail struct {
+	klineLimit    int  // Latest klines and series points kept per timeframe (0: all)
+	maxCandidates int  // Candidate coins shown, lowest ranked omitted first (-1: all)
+	summarize     bool // Series replaced by one-line summaries
+}
+
+var fullPromptDetail = promptDetail{maxCandidates: -1}
+
+// series formats an indicator series at this detail level
+func (d promptDetail) series(values []float64) string {
+	if d.summarize {
+		return summarizeSeries(values)
+	}
+	return formatFloatSlice(tailOf(values, d.klineLimit))
+}
+
+// PromptBudgetReport how the user prompt was fitted to the model context window
+type PromptBudgetReport struct {
+	BudgetTokens      int                   `json:"budget_tokens"` // 0: no limit
+	OriginalTokens    int                   `json:"original_tokens"`
+	EstimatedTokens   int                   `json:"estimated_tokens"`
+	Sections          []PromptSectionTokens `json:"sections"`
+	KlineLimit        int                   `json:"kline_limit,omitempty"`        // Klines kept per timeframe (0: all)
+	DroppedCandidates []string              `json:"dropped_candidates,omitempty"` // Lowest ranked candidates omitted
+	SummarizedSeries  bool                  `json:"summarized_series,omitempty"`
+	DroppedSections   int                   `json:"dropped_sections,omitempty"` // Rankings/history sections omitted
+	Truncated         bool                  `json:"truncated,omitempty"`
+}
+
+// PromptSectionTokens estimated size of one prompt section
+type PromptSectionTokens struct {
+	Name   string `json:"name"`
+	Tokens int    `json:"tokens"`
+}
+
+// Compacted reports whether anything was left out of the prompt
+func (r *PromptBudgetReport) Compacted() bool {
+	return r.KlineLimit > 0 || len(r.DroppedCandidates) > 0 || r.SummarizedSeries || r.DroppedSections > 0 || r.Truncated
+}
+
+// BuildUserPromptWithBudget builds the user prompt within maxTokens estimated tokens (<= 0: no limit).
+// Over budget, detail is reduced in order: fewer raw klines per timeframe (down to minPromptKlines),
+// then the lowest ranked candidates are dropped (down to minPromptCandidates), then series are
+// summarized, keeping as many candidates as then fit. As a last resort rankings and trade history
+// are dropped and the prompt is cut.
+func (e *StrategyEngine) BuildUserPromptWithBudget(ctx *Context, maxTokens int) (string, *PromptBudgetReport) {
+	detail := fullPromptDetail
+	prompt := e.buildUserPrompt(ctx, detail)
+	report := &PromptBudgetReport{BudgetTokens: maxTokens, OriginalTokens: mcp.EstimateTokens(prompt)}
+	fits := func(p string) bool { return maxTokens <= 0 || mcp.EstimateTokens(p) <= maxTokens }
+
+	// 1. Fewer raw klines
+	for _, limit := range klineLimitSteps(maxPromptSeriesLen(ctx)) {
+		if fits(prompt) {
+			break
+		}
+		detail.klineLimit = limit
+		prompt = e.buildUserPrompt(ctx, detail)
+	}
+
+	// 2. Drop low ranked candidates, 3. summarize series (and keep as many candidates as then fit)
+	candidates := promptCandidates(ctx)
+	fitCandidates := func(keep int) {
+		n := len(candidates)
+		maxDropped := n - keep
+		if maxDropped < 0 {
+			maxDropped = 0
+		}
+		dropped := sort.Search(maxDropped, func(d int) bool {
+			trial := detail
+			trial.maxCandidates = n - d
+			return fits(e.buildUserPrompt(ctx, trial))
+		})
+		detail.maxCandidates = n - dropped
+		prompt = e.buildUserPrompt(ctx, detail)
+	}
+	if !fits(prompt) && len(candidates) > minPromptCandidates {
+		fitCandidates(minPromptCandidates)
+	}
+	if !fits(prompt) {
+		detail.summarize = true
+		fitCandidates(0)
+	}
+
+	// 4. Drop whole sections, then cut
+	if !fits(prompt) {
+		prompt, report.DroppedSections, report.Truncated = compactUserPrompt(prompt, maxTokens)
+	}
+
+	report.KlineLimit = detail.klineLimit
+	report.SummarizedSeries = detail.summarize
+	if detail.maxCandidates >= 0 && detail.maxCandidates < len(candidates) {
+		for _, coin := range candidates[detail.maxCandidates:] {
+			report.DroppedCandidates = append(report.DroppedCandidates, coin.Symbol)
+		}
+	}
+	report.EstimatedTokens = mcp.EstimateTokens(prompt)
+	for _, p := range splitUserPrompt(prompt) {
+		report.Sections = append(report.Sections, PromptSectionTokens{Name: promptPieceName(p.text), Tokens: p.tokens})
+	}
+
+	if report.Compacted() {
+		logger.Infof("✂️  User prompt compacted to fit context window: ~%d → ~%d tokens (klines: %d, dropped candidates: %d, summarized: %v, dropped sections: %d, truncated: %v)",
+			report.OriginalTokens, report.EstimatedTokens, report.KlineLimit, len(report.DroppedCandidates),
+			report.SummarizedSeries, report.DroppedSections, report.Truncated)
+	}
+	return prompt, report
+}
+
+// promptCandidates candidate coins shown in the user prompt, in ranking order
+func promptCandidates(ctx *Context) []CandidateCoin {
+	positionSymbols := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		positionSymbols[market.Normalize(pos.Symbol)] = true
+	}
+	var candidates []CandidateCoin
+	for _, coin := range ctx.CandidateCoins {
+		if positionSymbols[market.Normalize(coin.Symbol)] {
+			continue
+		}
+		if _, hasData := ctx.MarketDataMap[coin.Symbol]; hasData {
+			candidates = append(candidates, coin)
+		}
+	}
+	return candidates
+}
+
+// maxPromptSeriesLen longest kline or indicator series in the market data
+func maxPromptSeriesLen(ctx *Context) int {
+	longest := 0
+	for _, data := range ctx.MarketDataMap {
+		if data == nil {
+			continue
+		}
+		for _, tf := range data.TimeframeData {
+			if tf == nil {
+				continue
+			}
+			if len(tf.Klines) > longest {
+				longest = len(tf.Klines)
+			}
+			if len(tf.MidPrices) > longest {
+				longest = len(tf.MidPrices)
+			}
+		}
+		if data.IntradaySeries != nil && len(data.IntradaySeries.MidPrices) > longest {
+			longest = len(data.IntradaySeries.MidPrices)
+		}
+	}
+	return longest
+}
+
+// klineLimitSteps halves the series length down to minPromptKlines
+func klineLimitSteps(longest int) []int {
+	var steps []int
+	for limit := longest / 2; limit > minPromptKlines; limit /= 2 {
+		steps = append(steps, limit)
+	}
+	if longest > minPromptKlines {
+		steps = append(steps, minPromptKlines)
+	}
+	return steps
+}
+
+// tailOf returns the last n items (n <= 0: all)
+func tailOf[T any](items []T, n int) []T {
+	if n <= 0 || len(items) <= n {
+		return items
+	}
+	return items[len(items)-n:]
+}
+
+// summarizeSeries one-line summary of an indicator series
+func summarizeSeries(values []float64) string {
+	if len(values) == 0 {
+		return "[]"
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	return fmt.Sprintf("latest %.4f (first %.4f, min %.4f, max %.4f over %d points)",
+		values[len(values)-1], values[0], lo, hi, len(values))
+}
+
+// summarizeKlines one-line summary of a kline series
+func summarizeKlines(klines []market.KlineBar) string {
+	first, last := klines[0], klines[len(klines)-1]
+	high, low, volume := first.High, first.Low, 0.0
+	for _, k := range klines {
+		if k.High > high {
+			high = k.High
+		}
+		if k.Low < low {
+			low = k.Low
+		}
+		volume += k.Volume
+	}
+	change := 0.0
+	if first.Open > 0 {
+		change = (last.Close - first.Open) / first.Open * 100
+	}
+	return fmt.Sprintf("Klines (%d, summarized): open %.4f → close %.4f (%+.2f%%) | high %.4f low %.4f | volume %.2f | current bar O %.4f H %.4f L %.4f C %.4f\n\n",
+		len(klines), first.Open, last.Close, change, high, low, volume, last.Open, last.High, last.Low, last.Close)
+}
+
+// promptPieceName section name for the budget report
+func promptPieceName(text string) string {
+	trimmed := strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(trimmed, "---"):
+		return "Closing instruction"
+	case strings.HasPrefix(trimmed, "#"):
+		line, _, _ := strings.Cut(trimmed, "\n")
+		return strings.TrimSpace(strings.TrimLeft(line, "#"))
+	default:
+		return "Overview"
+	}
+}
+
+// Drop priority of user prompt pieces when the prompt exceeds the budget (lowest dropped first)
+const (
+	promptPieceKeep      = iota // Header, account, positions, closing instruction
+	promptPieceRanking          // Market-wide rankings after the candidates
+	promptPieceCandidate        // One candidate coin (dropped from the lowest ranked)
+	promptPieceHistory          // Recent trades and trading statistics
+)
+
+var candidateHeadingRe = regexp.MustCompile(`(?m)^### \d+\. `)
+
+type promptPiece struct {
+	text     string
+	priority int
+	tokens   int
+	dropped  bool
+}
+
+// compactUserPrompt shrinks a user prompt to maxTokens estimated tokens by dropping whole sections:
+// rankings, then candidates from the end of the list, then trade history. Account, positions and
+// the closing instruction are kept; if they alone still exceed the budget the prompt is cut.
+func compactUserPrompt(prompt string, maxTokens int) (result string, omitted int, truncated bool) {
+	total := mcp.EstimateTokens(prompt)
+	if maxTokens <= 0 || total <= maxTokens {
+		return prompt, 0, false
+	}
+
+	pieces := splitUserPrompt(prompt)
+	for priority := promptPieceRanking; priority <= promptPieceHistory && total > maxTokens; priority++ {
+		for i := len(pieces) - 1; i >= 0 && total > maxTokens; i-- {
+			p := pieces[i]
+			if p.priority != priority || p.dropped {
+				continue
+			}
+			p.dropped = true
+			total -= p.tokens
+			omitted++
+		}
+	}
+
+	tail := pieces[len(pieces)-1]
+	var sb strings.Builder
+	for _, p := range pieces[:len(pieces)-1] {
+		if !p.dropped {
+			sb.WriteString(p.text)
+		}
+	}
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("(%d sections omitted to fit the model context window)\n\n", omitted))
+	}
+	kept := sb.String()
+	if budget := maxTokens - tail.tokens; mcp.EstimateTokens(kept) > budget {
+		kept = truncateToTokens(kept, budget)
+		truncated = true
+	}
+	return kept + tail.text, omitted, truncated
+}
+
+// splitUserPrompt splits a user prompt into "## " sections, candidate coins into one piece each.
+// The last piece is always the closing instruction after the final "---" separator.
+func splitUserPrompt(prompt string) []*promptPiece {
+	body, tail := prompt, ""
+	if i := strings.LastIndex(prompt, "---\n"); i >= 0 {
+		body, tail = prompt[:i], prompt[i:]
+	}
+
+	var pieces []*promptPiece
+	add := func(text string, priority int) {
+		if text != "" {
+			pieces = append(pieces, &promptPiece{text: text, priority: priority, tokens: mcp.EstimateTokens(text)})
+		}
+	}
+
+	sections := strings.Split(body, "\n## ")
+	add(sections[0], promptPieceKeep)
+	afterCandidates := false
+	for _, section := range sections[1:] {
+		section = "\n## " + section
+		header := strings.TrimPrefix(section, "\n## ")
+		switch {
+		case strings.HasPrefix(header, "Candidate Coins"):
+			afterCandidates = true
+			bounds := candidateHeadingRe.FindAllStringIndex(section, -1)
+			if len(bounds) == 0 {
+				add(section, promptPieceKeep)
+				continue
+			}
+			add(section[:bounds[0][0]], promptPieceKeep)
+			for i, b := range bounds {
+				end := len(section)
+				if i+1 < len(bounds) {
+					end = bounds[i+1][0]
+				}
+				add(section[b[0]:end], promptPieceCandidate)
+			}
+		case strings.HasPrefix(header, "Current Positions"):
+			add(section, promptPieceKeep)
+		case afterCandidates:
+			add(section, promptPieceRanking)
+		default:
+			add(section, promptPieceHistory)
+		}
+	}
+	pieces = append(pieces, &promptPiece{text: tail, priority: promptPieceKeep, tokens: mcp.EstimateTokens(tail)})
+	return pieces
+}
+
+// truncateToTokens cuts text to roughly maxTokens estimated tokens at a line boundary
+func truncateToTokens(text string, maxTokens int) string {
+	tokens, ascii, cut := 0, 0, 0
+	if maxTokens <= 0 {
+		return "...(truncated to fit the model context window)\n"
+	}
+	for i, r := range text {
+		if r < 128 {
+			ascii++
+			if ascii%4 == 1 {
+				tokens++
+			}
+		} else {
+			tokens++
+		}
+		if tokens > maxTokens {
+			break
+		}
+		cut = i + utf8.RuneLen(r)
+	}
+	if i := strings.LastIndex(text[:cut], "\n"); i > 0 {
+		cut = i + 1
+	}
+	return text[:cut] + "...(truncated to fit the model context window)\n"
+}
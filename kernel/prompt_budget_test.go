@@ -0,0 +1,150 @@
+package kernel
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/store"
+)
+
+func buildTestUserPrompt(candidates int) string {
+	var sb strings.Builder
+	sb.WriteString("Time: 2026-01-01 | Period: #1 | Runtime: 5 minutes\n\n")
+	sb.WriteString("Account: Equity 1000.00 | Balance 800.00 (80.0%) | PnL +0.00% | Margin 20.0% | Positions 1\n\n")
+	sb.WriteString("## Recent Completed Trades\n1. ETHUSDT long | Entry 3000 Exit 3100 | Profit: +10.00 USDT\n\n")
+	sb.WriteString("## Current Positions\n1. BTCUSDT LONG | Entry 60000 Current 61000\n\n")
+	sb.WriteString(fmt.Sprintf("## Candidate Coins (%d coins)\n\n", candidates))
+	for i := 1; i <= candidates; i++ {
+		sb.WriteString(fmt.Sprintf("### %d. COIN%dUSDT\n\n%s\n\n", i, i, strings.Repeat("kline data ", 40)))
+	}
+	sb.WriteString("\n## Fund Flow Ranking (1h)\n\n" + strings.Repeat("flow ", 40) + "\n\n")
+	sb.WriteString("---\n\n")
+	sb.WriteString("Now please analyze and output your decision (Chain of Thought + JSON)\n")
+	return sb.String()
+}
+
+func TestCompactUserPromptDropsLowPrioritySections(t *testing.T) {
+	prompt := buildTestUserPrompt(10)
+	if got, _, _ := compactUserPrompt(prompt, 0); got != prompt {
+		t.Error("no budget should leave the prompt unchanged")
+	}
+	if got, _, _ := compactUserPrompt(prompt, mcp.EstimateTokens(prompt)); got != prompt {
+		t.Error("prompt within budget should be unchanged")
+	}
+
+	budget := mcp.EstimateTokens(prompt) / 2
+	got, omitted, truncated := compactUserPrompt(prompt, budget)
+	if omitted == 0 || truncated {
+		t.Errorf("expected sections dropped without a cut, got omitted=%d truncated=%v", omitted, truncated)
+	}
+	if tokens := mcp.EstimateTokens(got); tokens > budget {
+		t.Errorf("trimmed prompt has ~%d tokens, budget %d", tokens, budget)
+	}
+	for _, keep := range []string{"Account: Equity", "## Current Positions", "### 1. COIN1USDT", "Recent Completed Trades", "Now please analyze", "sections omitted"} {
+		if !strings.Contains(got, keep) {
+			t.Errorf("trimmed prompt lost %q", keep)
+		}
+	}
+	for _, drop := range []string{"Fund Flow Ranking", "### 10. COIN10USDT"} {
+		if strings.Contains(got, drop) {
+			t.Errorf("trimmed prompt should have dropped %q first", drop)
+		}
+	}
+}
+
+func TestCompactUserPromptCutsWhenSectionsAreNotEnough(t *testing.T) {
+	prompt := buildTestUserPrompt(3)
+	got, _, truncated := compactUserPrompt(prompt, 40)
+	if !truncated {
+		t.Error("expected the prompt to be cut")
+	}
+	if tokens := mcp.EstimateTokens(got); tokens > 60 {
+		t.Errorf("cut prompt still has ~%d tokens", tokens)
+	}
+	if !strings.HasSuffix(got, "Now please analyze and output your decision (Chain of Thought + JSON)\n") {
+		t.Error("closing instruction must survive the cut")
+	}
+}
+
+func buildTestBudgetContext(candidates, klines int) *Context {
+	ctx := &Context{
+		Account:       AccountInfo{TotalEquity: 1000, AvailableBalance: 1000},
+		MarketDataMap: map[string]*market.Data{},
+	}
+	for i := 1; i <= candidates; i++ {
+		symbol := fmt.Sprintf("COIN%dUSDT", i)
+		series := &market.TimeframeSeriesData{Timeframe: "5m"}
+		for j := 0; j < klines; j++ {
+			price := 100 + float64(j)
+			series.Klines = append(series.Klines, market.KlineBar{Time: int64(j) * 300000, Open: price, High: price + 1, Low: price - 1, Close: price + 0.5, Volume: 10})
+			series.EMA20Values = append(series.EMA20Values, price)
+		}
+		ctx.CandidateCoins = append(ctx.CandidateCoins, CandidateCoin{Symbol: symbol})
+		ctx.MarketDataMap[symbol] = &market.Data{
+			Symbol:        symbol,
+			CurrentPrice:  100,
+			TimeframeData: map[string]*market.TimeframeSeriesData{"5m": series},
+		}
+	}
+	return ctx
+}
+
+func TestBuildUserPromptWithBudgetDegradesInOrder(t *testing.T) {
+	config := store.GetDefaultStrategyConfig("en")
+	config.Indicators.EnableEMA = true
+	engine := NewStrategyEngine(&config)
+	ctx := buildTestBudgetContext(5, 80)
+
+	full := engine.BuildUserPrompt(ctx)
+	fullTokens := mcp.EstimateTokens(full)
+	if got, report := engine.BuildUserPromptWithBudget(ctx, 0); got != full || report.Compacted() {
+		t.Fatal("no budget should leave the prompt unchanged")
+	}
+
+	// Slightly over budget: fewer klines is enough
+	got, report := engine.BuildUserPromptWithBudget(ctx, fullTokens*3/4)
+	if report.KlineLimit == 0 || len(report.DroppedCandidates) > 0 || report.SummarizedSeries {
+		t.Errorf("expected only fewer klines, got %+v", report)
+	}
+	if report.EstimatedTokens > report.BudgetTokens || report.OriginalTokens != fullTokens {
+		t.Errorf("unexpected token estimates: %+v", report)
+	}
+	if !strings.Contains(got, "### 5. COIN5USDT") {
+		t.Error("all candidates should be kept")
+	}
+
+	// Minimum klines still too large: the lowest ranked candidates go first
+	minimal := engine.buildUserPrompt(ctx, promptDetail{klineLimit: minPromptKlines, maxCandidates: -1})
+	got, report = engine.BuildUserPromptWithBudget(ctx, mcp.EstimateTokens(minimal)*3/4)
+	if report.KlineLimit != minPromptKlines || len(report.DroppedCandidates) == 0 || report.SummarizedSeries {
+		t.Errorf("expected dropped candidates at minimum klines, got %+v", report)
+	}
+	if last := report.DroppedCandidates[len(report.DroppedCandidates)-1]; last != "COIN5USDT" {
+		t.Errorf("lowest ranked candidate should be dropped, got %v", report.DroppedCandidates)
+	}
+	if !strings.Contains(got, "### 1. COIN1USDT") || strings.Contains(got, "COIN5USDT") {
+		t.Error("top candidate should be kept and the last one dropped")
+	}
+	if !strings.Contains(got, "lower-ranked candidates omitted") {
+		t.Error("prompt should mention the omitted candidates")
+	}
+
+	// The fewest candidates with raw klines do not fit: series are summarized, candidates come back
+	floor := engine.buildUserPrompt(ctx, promptDetail{klineLimit: minPromptKlines, maxCandidates: minPromptCandidates})
+	got, report = engine.BuildUserPromptWithBudget(ctx, mcp.EstimateTokens(floor)-1)
+	if !report.SummarizedSeries || len(report.DroppedCandidates) > 0 || report.Truncated {
+		t.Errorf("expected summarized series with all candidates, got %+v", report)
+	}
+	if !strings.Contains(got, "summarized") || strings.Contains(got, "<- current") {
+		t.Error("klines should be summarized")
+	}
+	if report.EstimatedTokens > report.BudgetTokens {
+		t.Errorf("prompt over budget: %+v", report)
+	}
+	if len(report.Sections) == 0 || report.Sections[len(report.Sections)-1].Name != "Closing instruction" {
+		t.Errorf("unexpected sections: %+v", report.Sections)
+	}
+}
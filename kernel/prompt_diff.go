@@ -0,0 +1,108 @@
+package kernel
+
+import (
+	"fmt"
+	"strings"
+
+	"nofx/store"
+)
+
+// ============================================================================
+// Prompt Template Diff
+// ============================================================================
+
+// Diff line operations
+const (
+	DiffEqual  = " "
+	DiffInsert = "+"
+	DiffDelete = "-"
+)
+
+// DiffLine one line of a line diff
+type DiffLine struct {
+	Op   string `json:"op"` // " " unchanged, "+" added, "-" removed
+	Text string `json:"text"`
+}
+
+// PromptFieldDiff line diff of one changed prompt template field
+type PromptFieldDiff struct {
+	Field string     `json:"field"`
+	Lines []DiffLine `json:"lines"`
+}
+
+// DiffPromptContent compares two prompt template versions field by field; unchanged fields are left out
+func DiffPromptContent(from, to store.PromptTemplateContent) []PromptFieldDiff {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"prompt_variant", from.PromptVariant, to.PromptVariant},
+		{"override_base_prompt", fmt.Sprint(from.OverrideBasePrompt), fmt.Sprint(to.OverrideBasePrompt)},
+		{"role_definition", from.PromptSections.RoleDefinition, to.PromptSections.RoleDefinition},
+		{"trading_frequency", from.PromptSections.TradingFrequency, to.PromptSections.TradingFrequency},
+		{"entry_standards", from.PromptSections.EntryStandards, to.PromptSections.EntryStandards},
+		{"decision_process", from.PromptSections.DecisionProcess, to.PromptSections.DecisionProcess},
+		{"custom_prompt", from.CustomPrompt, to.CustomPrompt},
+	}
+
+	var diffs []PromptFieldDiff
+	for _, f := range fields {
+		if f.from != f.to {
+			diffs = append(diffs, PromptFieldDiff{Field: f.name, Lines: DiffLines(f.from, f.to)})
+		}
+	}
+	return diffs
+}
+
+// DiffLines returns a line diff turning a into b (longest common subsequence, removals before additions)
+func DiffLines(a, b string) []DiffLine {
+	from, to := splitDiffLines(a), splitDiffLines(b)
+
+	// lcs[i][j] = common lines of from[i:] and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: from[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		lines = append(lines, DiffLine{Op: DiffDelete, Text: from[i]})
+	}
+	for ; j < len(to); j++ {
+		lines = append(lines, DiffLine{Op: DiffInsert, Text: to[j]})
+	}
+	return lines
+}
+
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
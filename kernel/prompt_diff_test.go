@@ -0,0 +1,63 @@
+package kernel
+
+import (
+	"testing"
+
+	"nofx/store"
+)
+
+func TestDiffLines(t *testing.T) {
+	got := DiffLines("a\nb\nc\n", "a\nB\nc\nd")
+	want := []DiffLine{
+		{DiffEqual, "a"},
+		{DiffDelete, "b"},
+		{DiffInsert, "B"},
+		{DiffEqual, "c"},
+		{DiffInsert, "d"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if lines := DiffLines("", "x"); len(lines) != 1 || lines[0] != (DiffLine{DiffInsert, "x"}) {
+		t.Errorf("adding to empty text: %v", lines)
+	}
+	if lines := DiffLines("x", ""); len(lines) != 1 || lines[0] != (DiffLine{DiffDelete, "x"}) {
+		t.Errorf("clearing text: %v", lines)
+	}
+}
+
+func TestDiffPromptContent(t *testing.T) {
+	from := store.PromptTemplateContent{
+		PromptVariant:  "balanced",
+		PromptSections: store.PromptSectionsConfig{RoleDefinition: "You are a trader", EntryStandards: "RSI < 30"},
+		CustomPrompt:   "Be careful",
+	}
+	to := from
+	to.PromptSections.EntryStandards = "RSI < 25"
+	to.OverrideBasePrompt = true
+
+	if diffs := DiffPromptContent(from, from); len(diffs) != 0 {
+		t.Errorf("identical content should have no diff, got %v", diffs)
+	}
+
+	diffs := DiffPromptContent(from, to)
+	if len(diffs) != 2 || diffs[0].Field != "override_base_prompt" || diffs[1].Field != "entry_standards" {
+		t.Fatalf("unexpected changed fields: %+v", diffs)
+	}
+	if lines := diffs[1].Lines; len(lines) != 2 || lines[0].Op != DiffDelete || lines[1].Text != "RSI < 25" {
+		t.Errorf("unexpected entry standards diff: %+v", lines)
+	}
+
+	if from.Hash() == to.Hash() {
+		t.Error("different content should hash differently")
+	}
+	if from.Hash() != store.PromptContentOf(&store.StrategyConfig{PromptSections: from.PromptSections, CustomPrompt: from.CustomPrompt}, "balanced", false).Hash() {
+		t.Error("same prompt settings should hash equal")
+	}
+}
@@ -0,0 +1,258 @@
+package kernel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/store"
+)
+
+// ============================================================================
+// Decision Replay (what-if evaluation of recorded decision cycles)
+// ============================================================================
+
+// Decision diff change types
+const (
+	DecisionSame    = "same"    // Same trades (sizes may differ)
+	DecisionChanged = "changed" // Both trade the symbol, differently
+	DecisionAdded   = "added"   // Only the replayed decisions trade the symbol
+	DecisionRemoved = "removed" // Only the original decisions trade the symbol
+)
+
+// DecisionDiff difference between the original and replayed decisions for one symbol
+type DecisionDiff struct {
+	Symbol          string  `json:"symbol"`
+	Change          string  `json:"change"`
+	Original        string  `json:"original,omitempty"` // Trade actions joined by "+", empty for hold/wait
+	Replayed        string  `json:"replayed,omitempty"`
+	OriginalSizeUSD float64 `json:"original_size_usd,omitempty"`
+	ReplayedSizeUSD float64 `json:"replayed_size_usd,omitempty"`
+}
+
+// IsToolCallingPrompt reports whether a recorded system prompt let the model fetch market data through
+// tools. Such cycles can't be replayed faithfully: the data was fetched live and is not in the user prompt.
+func IsToolCallingPrompt(systemPrompt string) bool {
+	return strings.Contains(systemPrompt, "\n\n# Data Tools\n\n") || strings.Contains(systemPrompt, "\n\n# 数据工具\n\n")
+}
+
+// ParseRecordedDecisions parses the decision JSON stored in a DecisionRecord
+func ParseRecordedDecisions(decisionJSON string) ([]Decision, error) {
+	if strings.TrimSpace(decisionJSON) == "" {
+		return nil, nil
+	}
+	var decisions []Decision
+	if err := json.Unmarshal([]byte(decisionJSON), &decisions); err != nil {
+		return nil, fmt.Errorf("failed to parse recorded decisions: %w", err)
+	}
+	return decisions, nil
+}
+
+// ReplayDecision re-sends a recorded system and user prompt to another model and parses its decisions
+// with the engine's risk limits. The structured output instructions of the original call are replaced
+// by those matching the new model.
+func ReplayDecision(mcpClient mcp.AIClient, engine *StrategyEngine, systemPrompt, userPrompt string, accountEquity float64) (*FullDecision, error) {
+	for _, lang := range []Language{LangEnglish, LangChinese} {
+		if i := strings.Index(systemPrompt, structuredOutputPrompt(lang)); i >= 0 {
+			systemPrompt = systemPrompt[:i]
+		}
+	}
+
+	riskConfig := engine.GetRiskControlConfig()
+	aiCallStart := time.Now()
+	aiResponse, sentPrompt, err := callDecisionAI(mcpClient, systemPrompt, userPrompt, engine.GetLanguage(), standardDecisionActions)
+	if err != nil {
+		return nil, fmt.Errorf("AI API call failed: %w", err)
+	}
+
+	decision, err := parseFullDecisionResponse(
+		aiResponse,
+		accountEquity,
+		riskConfig.BTCETHMaxLeverage,
+		riskConfig.AltcoinMaxLeverage,
+		riskConfig.BTCETHMaxPositionValueRatio,
+		riskConfig.AltcoinMaxPositionValueRatio,
+	)
+	if decision != nil {
+		decision.Timestamp = time.Now()
+		decision.SystemPrompt = sentPrompt
+		decision.UserPrompt = userPrompt
+		decision.AIRequestDurationMs = time.Since(aiCallStart).Milliseconds()
+		decision.RawResponse = aiResponse
+	}
+	if err != nil {
+		return decision, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+	return decision, nil
+}
+
+// DiffDecisions compares two decision lists per symbol; symbols neither list trades are left out
+func DiffDecisions(original, replayed []Decision) []DecisionDiff {
+	type side struct {
+		actions []string
+		sizeUSD float64
+	}
+	collect := func(decisions []Decision) map[string]*side {
+		bySymbol := make(map[string]*side)
+		for _, d := range decisions {
+			if !isTradeAction(d.Action) {
+				continue
+			}
+			s := bySymbol[d.Symbol]
+			if s == nil {
+				s = &side{}
+				bySymbol[d.Symbol] = s
+			}
+			s.actions = append(s.actions, d.Action)
+			s.sizeUSD += d.PositionSizeUSD
+		}
+		return bySymbol
+	}
+	from, to := collect(original), collect(replayed)
+
+	// Keep the order decisions were made in: original symbols first, then symbols only the replay trades
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, list := range [][]Decision{original, replayed} {
+		for _, d := range list {
+			if (from[d.Symbol] != nil || to[d.Symbol] != nil) && !seen[d.Symbol] {
+				seen[d.Symbol] = true
+				symbols = append(symbols, d.Symbol)
+			}
+		}
+	}
+
+	diffs := make([]DecisionDiff, 0, len(symbols))
+	for _, symbol := range symbols {
+		diff := DecisionDiff{Symbol: symbol}
+		a, b := from[symbol], to[symbol]
+		if a != nil {
+			diff.Original = strings.Join(a.actions, "+")
+			diff.OriginalSizeUSD = a.sizeUSD
+		}
+		if b != nil {
+			diff.Replayed = strings.Join(b.actions, "+")
+			diff.ReplayedSizeUSD = b.sizeUSD
+		}
+		switch {
+		case a == nil:
+			diff.Change = DecisionAdded
+		case b == nil:
+			diff.Change = DecisionRemoved
+		case diff.Original == diff.Replayed:
+			diff.Change = DecisionSame
+		default:
+			diff.Change = DecisionChanged
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+func isTradeAction(action string) bool {
+	switch action {
+	case "open_long", "open_short", "close_long", "close_short":
+		return true
+	}
+	return false
+}
+
+// EstimateHorizonPnL estimates the PnL (after fees) a decision list would have produced from the decision
+// time until the end of the horizon, on historical klines (milliseconds, [from, until)):
+//   - positions it keeps open run until the horizon ends
+//   - positions it closes stop earning at the first bar open
+//   - positions it opens enter at the first bar open and exit at their stop loss / take profit
+//     (stop loss first when a bar crosses both) or at the last close of the horizon
+//
+// Evaluating the original and the replayed decisions over the same horizon makes them comparable.
+func EstimateHorizonPnL(decisions []Decision, positions []store.PositionSnapshot, klines map[string][]market.Kline, from, until int64, feeRate float64) float64 {
+	closed := make(map[string]bool)
+	for _, d := range decisions {
+		switch d.Action {
+		case "close_long":
+			closed[d.Symbol+"_long"] = true
+		case "close_short":
+			closed[d.Symbol+"_short"] = true
+		}
+	}
+
+	pnl := 0.0
+	for _, pos := range positions {
+		bars := horizonBars(klines[pos.Symbol], from, until)
+		if len(bars) == 0 {
+			continue
+		}
+		qty := math.Abs(pos.PositionAmt)
+		entry := bars[0].Open
+		if closed[pos.Symbol+"_"+strings.ToLower(pos.Side)] {
+			pnl -= qty * entry * feeRate
+			continue
+		}
+		pnl += positionPnL(strings.EqualFold(pos.Side, "short"), qty, entry, bars[len(bars)-1].Close)
+	}
+
+	for _, d := range decisions {
+		if d.Action != "open_long" && d.Action != "open_short" || d.PositionSizeUSD <= 0 {
+			continue
+		}
+		bars := horizonBars(klines[d.Symbol], from, until)
+		if len(bars) == 0 || bars[0].Open <= 0 {
+			continue
+		}
+		short := d.Action == "open_short"
+		entry := bars[0].Open
+		qty := d.PositionSizeUSD / entry
+		exit := exitPrice(bars, short, d.StopLoss, d.TakeProfit)
+		pnl += positionPnL(short, qty, entry, exit) - qty*(entry+exit)*feeRate
+	}
+	return pnl
+}
+
+// exitPrice walks the bars until the stop loss or take profit is crossed, else exits at the last close
+func exitPrice(bars []market.Kline, short bool, stopLoss, takeProfit float64) float64 {
+	for _, bar := range bars {
+		if short {
+			if stopLoss > 0 && bar.High >= stopLoss {
+				return stopLoss
+			}
+			if takeProfit > 0 && bar.Low <= takeProfit {
+				return takeProfit
+			}
+		} else {
+			if stopLoss > 0 && bar.Low <= stopLoss {
+				return stopLoss
+			}
+			if takeProfit > 0 && bar.High >= takeProfit {
+				return takeProfit
+			}
+		}
+	}
+	return bars[len(bars)-1].Close
+}
+
+func positionPnL(short bool, qty, entry, exit float64) float64 {
+	if short {
+		return qty * (entry - exit)
+	}
+	return qty * (exit - entry)
+}
+
+// horizonBars returns the bars opening within [from, until) of an ascending kline series
+func horizonBars(klines []market.Kline, from, until int64) []market.Kline {
+	start := len(klines)
+	for i, k := range klines {
+		if k.OpenTime >= from {
+			start = i
+			break
+		}
+	}
+	end := start
+	for end < len(klines) && klines[end].OpenTime < until {
+		end++
+	}
+	return klines[start:end]
+}
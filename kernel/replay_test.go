@@ -0,0 +1,139 @@
+package kernel
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/store"
+)
+
+// replayClient answers with a fixed response and records the prompts it was sent
+type replayClient struct {
+	response     string
+	systemPrompt string
+	userPrompt   string
+}
+
+func (c *replayClient) SetAPIKey(string, string, string) {}
+func (c *replayClient) SetTimeout(time.Duration)         {}
+func (c *replayClient) CallWithRequest(*mcp.Request) (string, error) {
+	return c.response, nil
+}
+func (c *replayClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.systemPrompt, c.userPrompt = systemPrompt, userPrompt
+	return c.response, nil
+}
+
+func TestReplayDecisionStripsStructuredOutputPrompt(t *testing.T) {
+	client := &replayClient{response: "<reasoning>flat market</reasoning><decision>[{\"symbol\":\"BTCUSDT\",\"action\":\"wait\",\"reasoning\":\"no setup\"}]</decision>"}
+	engine := NewStrategyEngine(&store.StrategyConfig{})
+	recorded := "You are a trader" + structuredOutputPrompt(LangEnglish)
+
+	full, err := ReplayDecision(client, engine, recorded, "market data", 1000)
+	if err != nil {
+		t.Fatalf("ReplayDecision: %v", err)
+	}
+	if client.systemPrompt != "You are a trader" || client.userPrompt != "market data" {
+		t.Errorf("unexpected prompts sent: %q / %q", client.systemPrompt, client.userPrompt)
+	}
+	if len(full.Decisions) != 1 || full.Decisions[0].Action != "wait" {
+		t.Errorf("unexpected decisions: %+v", full.Decisions)
+	}
+}
+
+func TestIsToolCallingPrompt(t *testing.T) {
+	engine := NewStrategyEngine(&store.StrategyConfig{})
+	if !IsToolCallingPrompt("base prompt" + engine.toolUsePrompt()) {
+		t.Error("tool calling prompt not detected")
+	}
+	if IsToolCallingPrompt("base prompt") {
+		t.Error("plain prompt detected as tool calling")
+	}
+}
+
+func TestDiffDecisions(t *testing.T) {
+	original := []Decision{
+		{Symbol: "BTCUSDT", Action: "open_long", PositionSizeUSD: 500},
+		{Symbol: "ETHUSDT", Action: "close_short"},
+		{Symbol: "SOLUSDT", Action: "hold"},
+		{Symbol: "XRPUSDT", Action: "open_short", PositionSizeUSD: 100},
+	}
+	replayed := []Decision{
+		{Symbol: "BTCUSDT", Action: "open_long", PositionSizeUSD: 300},
+		{Symbol: "ETHUSDT", Action: "hold"},
+		{Symbol: "XRPUSDT", Action: "open_long", PositionSizeUSD: 100},
+		{Symbol: "DOGEUSDT", Action: "open_short", PositionSizeUSD: 50},
+	}
+
+	diffs := DiffDecisions(original, replayed)
+	want := []struct{ symbol, change string }{
+		{"BTCUSDT", DecisionSame},
+		{"ETHUSDT", DecisionRemoved},
+		{"XRPUSDT", DecisionChanged},
+		{"DOGEUSDT", DecisionAdded},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d diffs, want %d: %+v", len(diffs), len(want), diffs)
+	}
+	for i, w := range want {
+		if diffs[i].Symbol != w.symbol || diffs[i].Change != w.change {
+			t.Errorf("diff %d: got %s %s, want %s %s", i, diffs[i].Symbol, diffs[i].Change, w.symbol, w.change)
+		}
+	}
+	if diffs[0].OriginalSizeUSD != 500 || diffs[0].ReplayedSizeUSD != 300 {
+		t.Errorf("sizes not kept: %+v", diffs[0])
+	}
+}
+
+func TestEstimateHorizonPnL(t *testing.T) {
+	// BTC rises 100 -> 110, dipping to 95 in the second bar
+	klines := map[string][]market.Kline{
+		"BTCUSDT": {
+			{OpenTime: 0, Open: 99, High: 101, Low: 98, Close: 100},
+			{OpenTime: 60_000, Open: 100, High: 104, Low: 99, Close: 103},
+			{OpenTime: 120_000, Open: 103, High: 106, Low: 95, Close: 105},
+			{OpenTime: 180_000, Open: 105, High: 111, Low: 104, Close: 110},
+		},
+	}
+	const from, until = 60_000, 240_000
+	approx := func(name string, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: got %.4f, want %.4f", name, got, want)
+		}
+	}
+
+	// Open long 1000 USD at 100 without stops: 10 units * (110 - 100)
+	open := []Decision{{Symbol: "BTCUSDT", Action: "open_long", PositionSizeUSD: 1000}}
+	approx("open long", EstimateHorizonPnL(open, nil, klines, from, until, 0), 100)
+
+	// Stop loss at 96 is hit in the second bar: 10 * (96 - 100), fees on 1000 + 960
+	open[0].StopLoss = 96
+	approx("stopped out", EstimateHorizonPnL(open, nil, klines, from, until, 0.001), -40-1.96)
+
+	// Short position kept over the horizon: 2 * (100 - 110); closing it only costs the fee
+	positions := []store.PositionSnapshot{{Symbol: "BTCUSDT", Side: "short", PositionAmt: -2}}
+	approx("hold short", EstimateHorizonPnL(nil, positions, klines, from, until, 0.001), -20)
+	closeShort := []Decision{{Symbol: "BTCUSDT", Action: "close_short"}}
+	approx("close short", EstimateHorizonPnL(closeShort, positions, klines, from, until, 0.001), -0.2)
+
+	// No klines in range: nothing to estimate
+	approx("no data", EstimateHorizonPnL(open, positions, klines, 300_000, 400_000, 0.001), 0)
+}
+
+func TestParseRecordedDecisions(t *testing.T) {
+	decisions, err := ParseRecordedDecisions(`[{"symbol":"BTCUSDT","action":"open_long","position_size_usd":100}]`)
+	if err != nil || len(decisions) != 1 || decisions[0].PositionSizeUSD != 100 {
+		t.Fatalf("unexpected result: %+v, %v", decisions, err)
+	}
+	if decisions, err := ParseRecordedDecisions(""); err != nil || decisions != nil {
+		t.Errorf("empty JSON: %+v, %v", decisions, err)
+	}
+	if _, err := ParseRecordedDecisions("{not json"); err == nil || !strings.Contains(err.Error(), "recorded decisions") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package kernel
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+// ============================================================================
+// Rule-based Strategy Engine (StrategyType == "rules")
+// ============================================================================
+
+// Defaults of rule strategies
+const (
+	defaultRulePositionSizePct = 20.0
+	defaultRuleLeverage        = 3
+	defaultRuleStopLossPct     = 2.0
+	defaultRuleTakeProfitPct   = 6.0
+)
+
+// RuleSet compiled rules of a rule-based strategy; nil rules never match
+type RuleSet struct {
+	EntryLong  *Rule
+	EntryShort *Rule
+	ExitLong   *Rule
+	ExitShort  *Rule
+	config     store.RuleStrategyConfig
+}
+
+// CompileRules parses and validates a rule strategy configuration
+func CompileRules(cfg *store.RuleStrategyConfig) (*RuleSet, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("rule_config is required for rule strategies")
+	}
+	rs := &RuleSet{config: *cfg}
+	for _, r := range []struct {
+		name string
+		text string
+		dest **Rule
+	}{
+		{"entry_long", cfg.EntryLong, &rs.EntryLong},
+		{"entry_short", cfg.EntryShort, &rs.EntryShort},
+		{"exit_long", cfg.ExitLong, &rs.ExitLong},
+		{"exit_short", cfg.ExitShort, &rs.ExitShort},
+	} {
+		if strings.TrimSpace(r.text) == "" {
+			continue
+		}
+		rule, err := ParseRule(r.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.name, err)
+		}
+		*r.dest = rule
+	}
+	if rs.EntryLong == nil && rs.EntryShort == nil {
+		return nil, fmt.Errorf("at least one of entry_long and entry_short is required")
+	}
+	if cfg.PositionSizePct < 0 || cfg.Leverage < 0 || cfg.StopLossPct < 0 || cfg.TakeProfitPct < 0 {
+		return nil, fmt.Errorf("position size, leverage, stop loss and take profit cannot be negative")
+	}
+	if cfg.StopLossPct >= 100 {
+		return nil, fmt.Errorf("stop_loss_pct must be below 100")
+	}
+	return rs, nil
+}
+
+func (rs *RuleSet) rules() []*Rule {
+	var rules []*Rule
+	for _, r := range []*Rule{rs.EntryLong, rs.EntryShort, rs.ExitLong, rs.ExitShort} {
+		if r != nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// Timeframes returns the timeframes the rules name explicitly (besides the primary timeframe)
+func (rs *RuleSet) Timeframes() []string {
+	return rulesTimeframes(rs.rules())
+}
+
+// Lookback returns the number of klines per timeframe the rules need
+func (rs *RuleSet) Lookback() int {
+	return rulesLookback(rs.rules())
+}
+
+// rulesTimeframes returns the distinct timeframes named by rules, in order of appearance
+func rulesTimeframes(rules []*Rule) []string {
+	var timeframes []string
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		for _, tf := range r.timeframes {
+			if !seen[tf] {
+				seen[tf] = true
+				timeframes = append(timeframes, tf)
+			}
+		}
+	}
+	return timeframes
+}
+
+// rulesLookback returns the largest kline lookback of rules
+func rulesLookback(rules []*Rule) int {
+	n := 0
+	for _, r := range rules {
+		if r.lookback > n {
+			n = r.lookback
+		}
+	}
+	return n
+}
+
+// KlineSource returns the klines of a symbol on a timeframe, oldest first
+type KlineSource func(symbol, timeframe string) []market.Kline
+
+// marketDataKlines reads klines from the timeframe series of fetched market data
+func marketDataKlines(ctx *Context) KlineSource {
+	return func(symbol, timeframe string) []market.Kline {
+		data := ctx.MarketDataMap[symbol]
+		if data == nil || data.TimeframeData[timeframe] == nil {
+			return nil
+		}
+		bars := data.TimeframeData[timeframe].Klines
+		klines := make([]market.Kline, len(bars))
+		for i, b := range bars {
+			klines[i] = market.Kline{OpenTime: b.Time, Open: b.Open, High: b.High, Low: b.Low, Close: b.Close, Volume: b.Volume}
+		}
+		return klines
+	}
+}
+
+// GetRuleDecision makes the decisions of a rule strategy: positions are closed when their exit rule
+// matches, candidates are opened when an entry rule matches. No AI is called. The decisions go
+// through the same validation as AI decisions.
+// klines may be nil, in which case market data is fetched (if ctx has none) and its klines are used.
+func GetRuleDecision(ctx *Context, engine *StrategyEngine, klines KlineSource) (*FullDecision, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("context is nil")
+	}
+	rules, err := CompileRules(engine.config.RuleConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	if len(ctx.MarketDataMap) == 0 {
+		if err := fetchMarketDataWithStrategy(ctx, engine, false); err != nil {
+			return nil, fmt.Errorf("failed to fetch market data: %w", err)
+		}
+	}
+	if klines == nil {
+		klines = marketDataKlines(ctx)
+	}
+
+	_, primaryTimeframe, _ := engine.klineSettings()
+	var trace strings.Builder
+	eval := func(rule *Rule, symbol string) bool {
+		if rule == nil {
+			return false
+		}
+		ok, err := rule.Eval(RuleInput{
+			Data:             ctx.MarketDataMap[symbol],
+			Klines:           func(tf string) []market.Kline { return klines(symbol, tf) },
+			PrimaryTimeframe: primaryTimeframe,
+		})
+		if err != nil {
+			fmt.Fprintf(&trace, "%s: %q not evaluated: %v\n", symbol, rule.String(), err)
+			return false
+		}
+		return ok
+	}
+
+	var decisions []Decision
+	openPositions := 0
+	held := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		held[market.Normalize(pos.Symbol)] = true
+		exitRule := rules.ExitLong
+		action := "close_long"
+		if strings.EqualFold(pos.Side, "short") {
+			exitRule, action = rules.ExitShort, "close_short"
+		}
+		if eval(exitRule, pos.Symbol) {
+			reason := fmt.Sprintf("exit rule matched: %s", exitRule)
+			decisions = append(decisions, Decision{Symbol: pos.Symbol, Action: action, Reasoning: reason})
+			fmt.Fprintf(&trace, "%s %s: %s\n", pos.Symbol, action, reason)
+			continue
+		}
+		openPositions++
+		decisions = append(decisions, Decision{Symbol: pos.Symbol, Action: "hold", Reasoning: "no exit rule matched"})
+	}
+
+	riskConfig := engine.GetRiskControlConfig()
+	for _, coin := range ctx.CandidateCoins {
+		if held[market.Normalize(coin.Symbol)] {
+			continue
+		}
+		data := ctx.MarketDataMap[coin.Symbol]
+		if data == nil || data.CurrentPrice <= 0 {
+			continue
+		}
+
+		var action string
+		var rule *Rule
+		switch {
+		case eval(rules.EntryLong, coin.Symbol):
+			action, rule = "open_long", rules.EntryLong
+		case eval(rules.EntryShort, coin.Symbol):
+			action, rule = "open_short", rules.EntryShort
+		default:
+			continue
+		}
+		if riskConfig.MaxPositions > 0 && openPositions >= riskConfig.MaxPositions {
+			fmt.Fprintf(&trace, "%s %s skipped: max positions (%d) reached\n", coin.Symbol, action, riskConfig.MaxPositions)
+			continue
+		}
+
+		d := rules.openDecision(coin.Symbol, action, data.CurrentPrice, ctx.Account.TotalEquity, riskConfig)
+		d.Reasoning = fmt.Sprintf("entry rule matched: %s", rule)
+		if err := validateDecision(&d, ctx.Account.TotalEquity, riskConfig.BTCETHMaxLeverage, riskConfig.AltcoinMaxLeverage,
+			riskConfig.BTCETHMaxPositionValueRatio, riskConfig.AltcoinMaxPositionValueRatio); err != nil {
+			fmt.Fprintf(&trace, "%s %s rejected: %v\n", coin.Symbol, action, err)
+			continue
+		}
+		openPositions++
+		decisions = append(decisions, d)
+		fmt.Fprintf(&trace, "%s %s: %s (%.2f USDT, %dx)\n", coin.Symbol, action, d.Reasoning, d.PositionSizeUSD, d.Leverage)
+	}
+
+	if trace.Len() == 0 {
+		trace.WriteString("No rule matched\n")
+	}
+	return &FullDecision{
+		UserPrompt: rules.describe(),
+		CoTTrace:   trace.String(),
+		Decisions:  decisions,
+		Timestamp:  time.Now(),
+	}, nil
+}
+
+// openDecision sizes an entry from the rule configuration within the risk control limits
+func (rs *RuleSet) openDecision(symbol, action string, price, equity float64, risk store.RiskControlConfig) Decision {
+	cfg := rs.config
+	sizePct := cfg.PositionSizePct
+	if sizePct <= 0 {
+		sizePct = defaultRulePositionSizePct
+	}
+	leverage := cfg.Leverage
+	if leverage <= 0 {
+		leverage = defaultRuleLeverage
+	}
+	stopLossPct := cfg.StopLossPct
+	if stopLossPct <= 0 {
+		stopLossPct = defaultRuleStopLossPct
+	}
+	takeProfitPct := cfg.TakeProfitPct
+	if takeProfitPct <= 0 {
+		takeProfitPct = defaultRuleTakeProfitPct
+	}
+
+	maxLeverage, posRatio := risk.AltcoinMaxLeverage, risk.AltcoinMaxPositionValueRatio
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		maxLeverage, posRatio = risk.BTCETHMaxLeverage, risk.BTCETHMaxPositionValueRatio
+	}
+	if maxLeverage > 0 && leverage > maxLeverage {
+		leverage = maxLeverage
+	}
+	size := equity * sizePct / 100
+	if posRatio > 0 {
+		size = math.Min(size, equity*posRatio)
+	}
+
+	d := Decision{Symbol: symbol, Action: action, Leverage: leverage, PositionSizeUSD: size}
+	if action == "open_long" {
+		d.StopLoss = price * (1 - stopLossPct/100)
+		d.TakeProfit = price * (1 + takeProfitPct/100)
+	} else {
+		d.StopLoss = price * (1 + stopLossPct/100)
+		d.TakeProfit = price * (1 - takeProfitPct/100)
+	}
+	return d
+}
+
+// describe lists the rules, stored as the input of rule decisions
+func (rs *RuleSet) describe() string {
+	var sb strings.Builder
+	sb.WriteString("Rule strategy\n")
+	for _, r := range []struct {
+		name string
+		rule *Rule
+	}{
+		{"entry_long", rs.EntryLong},
+		{"entry_short", rs.EntryShort},
+		{"exit_long", rs.ExitLong},
+		{"exit_short", rs.ExitShort},
+	} {
+		if r.rule != nil {
+			fmt.Fprintf(&sb, "%s: %s\n", r.name, r.rule)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,528 @@
+package kernel
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"nofx/market"
+)
+
+// ============================================================================
+// Strategy Rules DSL
+// ============================================================================
+//
+// A rule is a condition over market indicators, for example:
+//
+//	ema20 crosses above ema50 on 1h and rsi14 < 70
+//	(close > boll_upper or change_1h > 3) and not funding_rate > 0.05
+//
+// Grammar (case-insensitive):
+//
+//	rule       := and { "or" and }
+//	and        := unary { "and" unary }
+//	unary      := "not" unary | "(" rule ")" | comparison
+//	comparison := operand op operand [ "on" timeframe ]
+//	op         := "<" | "<=" | ">" | ">=" | "==" | "!=" | "crosses above" | "crosses below"
+//
+// Kline operands are evaluated on the comparison's timeframe (the strategy's primary timeframe
+// without "on"): price/close, open, high, low, volume, emaN, smaN, rsiN, atrN, macd,
+// boll_upper, boll_middle, boll_lower (20 periods, 2 standard deviations).
+// Snapshot operands don't depend on the timeframe and can't be used with crosses:
+// funding_rate (%), oi_change (latest OI vs average, %), change_1h, change_4h (%).
+// Numbers are constants.
+
+// Rule a parsed rule condition
+type Rule struct {
+	text       string
+	root       ruleNode
+	timeframes []string // Timeframes named with "on"
+	lookback   int      // Klines needed to evaluate the rule
+}
+
+// String returns the rule source text
+func (r *Rule) String() string {
+	return r.text
+}
+
+// RuleInput market data a rule is evaluated on
+type RuleInput struct {
+	Data             *market.Data                          // Snapshot operands
+	Klines           func(timeframe string) []market.Kline // Klines of a timeframe, oldest first
+	PrimaryTimeframe string                                // Timeframe of comparisons without "on"
+}
+
+// Eval evaluates the rule. An error (e.g. missing klines) means the rule can't be decided.
+func (r *Rule) Eval(in RuleInput) (bool, error) {
+	return r.root.eval(in, r.lookback)
+}
+
+type ruleNode interface {
+	eval(in RuleInput, lookback int) (bool, error)
+}
+
+type ruleAnd struct{ left, right ruleNode }
+type ruleOr struct{ left, right ruleNode }
+type ruleNot struct{ inner ruleNode }
+
+func (n ruleAnd) eval(in RuleInput, lookback int) (bool, error) {
+	ok, err := n.left.eval(in, lookback)
+	if err != nil || !ok {
+		return false, err
+	}
+	return n.right.eval(in, lookback)
+}
+
+func (n ruleOr) eval(in RuleInput, lookback int) (bool, error) {
+	ok, err := n.left.eval(in, lookback)
+	if err != nil || ok {
+		return ok, err
+	}
+	return n.right.eval(in, lookback)
+}
+
+func (n ruleNot) eval(in RuleInput, lookback int) (bool, error) {
+	ok, err := n.inner.eval(in, lookback)
+	return !ok && err == nil, err
+}
+
+// Comparison operators
+const (
+	ruleOpLT           = "<"
+	ruleOpLE           = "<="
+	ruleOpGT           = ">"
+	ruleOpGE           = ">="
+	ruleOpEQ           = "=="
+	ruleOpNE           = "!="
+	ruleOpCrossesAbove = "crosses above"
+	ruleOpCrossesBelow = "crosses below"
+)
+
+type ruleComparison struct {
+	left, right ruleOperand
+	op          string
+	timeframe   string // Empty: primary timeframe
+}
+
+func (c ruleComparison) eval(in RuleInput, lookback int) (bool, error) {
+	tf := c.timeframe
+	if tf == "" {
+		tf = in.PrimaryTimeframe
+	}
+	var klines []market.Kline
+	if (c.left.klineBased() || c.right.klineBased()) && in.Klines != nil {
+		klines = in.Klines(tf)
+		if len(klines) > lookback {
+			klines = klines[len(klines)-lookback:]
+		}
+	}
+
+	l, err := c.left.value(in.Data, klines, tf, 0)
+	if err != nil {
+		return false, err
+	}
+	r, err := c.right.value(in.Data, klines, tf, 0)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.op {
+	case ruleOpLT:
+		return l < r, nil
+	case ruleOpLE:
+		return l <= r, nil
+	case ruleOpGT:
+		return l > r, nil
+	case ruleOpGE:
+		return l >= r, nil
+	case ruleOpEQ:
+		return l == r, nil
+	case ruleOpNE:
+		return l != r, nil
+	}
+
+	prevL, err := c.left.value(in.Data, klines, tf, 1)
+	if err != nil {
+		return false, err
+	}
+	prevR, err := c.right.value(in.Data, klines, tf, 1)
+	if err != nil {
+		return false, err
+	}
+	if c.op == ruleOpCrossesAbove {
+		return prevL <= prevR && l > r, nil
+	}
+	return prevL >= prevR && l < r, nil
+}
+
+// Operand kinds
+const (
+	operandConst    = iota
+	operandKline    // price, open, high, low, volume
+	operandPeriod   // emaN, smaN, rsiN, atrN
+	operandMACD     // macd
+	operandBOLL     // boll_upper, boll_middle, boll_lower
+	operandSnapshot // funding_rate, oi_change, change_1h, change_4h
+)
+
+const rulesBOLLPeriod = 20
+
+type ruleOperand struct {
+	kind   int
+	name   string
+	period int
+	number float64
+}
+
+var rulePeriodOperandRe = regexp.MustCompile(`^(ema|sma|rsi|atr)(\d+)$`)
+
+func parseRuleOperand(word string) (ruleOperand, error) {
+	if v, err := strconv.ParseFloat(word, 64); err == nil {
+		return ruleOperand{kind: operandConst, name: word, number: v}, nil
+	}
+	switch word {
+	case "price", "close", "open", "high", "low", "volume":
+		return ruleOperand{kind: operandKline, name: word}, nil
+	case "macd":
+		return ruleOperand{kind: operandMACD, name: word}, nil
+	case "boll_upper", "boll_middle", "boll_lower":
+		return ruleOperand{kind: operandBOLL, name: word, period: rulesBOLLPeriod}, nil
+	case "funding_rate", "oi_change", "change_1h", "change_4h":
+		return ruleOperand{kind: operandSnapshot, name: word}, nil
+	}
+	if m := rulePeriodOperandRe.FindStringSubmatch(word); m != nil {
+		period, _ := strconv.Atoi(m[2])
+		if period < 1 || period > 500 {
+			return ruleOperand{}, fmt.Errorf("%s period must be between 1 and 500", m[1])
+		}
+		return ruleOperand{kind: operandPeriod, name: m[1], period: period}, nil
+	}
+	return ruleOperand{}, fmt.Errorf("unknown indicator %q", word)
+}
+
+func (o ruleOperand) klineBased() bool {
+	return o.kind == operandKline || o.kind == operandPeriod || o.kind == operandMACD || o.kind == operandBOLL
+}
+
+// lookback klines needed for a stable value; EMA based indicators get three times their period to warm up
+func (o ruleOperand) lookback() int {
+	switch o.kind {
+	case operandKline:
+		return 1
+	case operandPeriod:
+		if o.name == "sma" {
+			return o.period
+		}
+		return 3*o.period + 1
+	case operandMACD:
+		return 3 * 26
+	case operandBOLL:
+		return o.period
+	}
+	return 0
+}
+
+// minBars klines needed to compute the operand at all
+func (o ruleOperand) minBars() int {
+	switch o.kind {
+	case operandKline:
+		return 1
+	case operandPeriod:
+		if o.name == "rsi" || o.name == "atr" {
+			return o.period + 1
+		}
+		return o.period
+	case operandMACD:
+		return 26
+	case operandBOLL:
+		return o.period
+	}
+	return 0
+}
+
+// value evaluates the operand offset bars before the latest kline
+func (o ruleOperand) value(data *market.Data, klines []market.Kline, timeframe string, offset int) (float64, error) {
+	switch o.kind {
+	case operandConst:
+		return o.number, nil
+	case operandSnapshot:
+		return o.snapshotValue(data)
+	}
+
+	n := len(klines) - offset
+	if need := o.minBars(); n < need || n <= 0 {
+		return 0, fmt.Errorf("not enough %s klines for %s (%d, need %d)", timeframe, o.name, n, need)
+	}
+	bars := klines[:n]
+	last := bars[n-1]
+	switch o.kind {
+	case operandKline:
+		switch o.name {
+		case "open":
+			return last.Open, nil
+		case "high":
+			return last.High, nil
+		case "low":
+			return last.Low, nil
+		case "volume":
+			return last.Volume, nil
+		}
+		return last.Close, nil
+	case operandPeriod:
+		switch o.name {
+		case "ema":
+			return market.ExportCalculateEMA(bars, o.period), nil
+		case "sma":
+			sum := 0.0
+			for _, k := range bars[n-o.period:] {
+				sum += k.Close
+			}
+			return sum / float64(o.period), nil
+		case "rsi":
+			return market.ExportCalculateRSI(bars, o.period), nil
+		}
+		return market.ExportCalculateATR(bars, o.period), nil
+	case operandMACD:
+		return market.ExportCalculateMACD(bars), nil
+	}
+
+	upper, middle, lower := market.ExportCalculateBOLL(bars, o.period, 2)
+	switch o.name {
+	case "boll_upper":
+		return upper, nil
+	case "boll_lower":
+		return lower, nil
+	}
+	return middle, nil
+}
+
+func (o ruleOperand) snapshotValue(data *market.Data) (float64, error) {
+	if data == nil {
+		return 0, fmt.Errorf("no market data for %s", o.name)
+	}
+	switch o.name {
+	case "funding_rate":
+		return data.FundingRate * 100, nil
+	case "oi_change":
+		if data.OpenInterest == nil || data.OpenInterest.Average <= 0 {
+			return 0, fmt.Errorf("no open interest data")
+		}
+		return (data.OpenInterest.Latest - data.OpenInterest.Average) / data.OpenInterest.Average * 100, nil
+	case "change_1h":
+		return data.PriceChange1h, nil
+	}
+	return data.PriceChange4h, nil
+}
+
+// ParseRule parses a rule condition
+func ParseRule(text string) (*Rule, error) {
+	tokens, err := tokenizeRule(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty rule")
+	}
+	p := &ruleParser{tokens: tokens, rule: &Rule{text: strings.TrimSpace(text), lookback: 2}}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	p.rule.root = root
+	return p.rule, nil
+}
+
+// tokenizeRule splits a rule into lowercase words, numbers, operators and parentheses
+func tokenizeRule(text string) ([]string, error) {
+	var tokens []string
+	s := strings.ToLower(text)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '<' || c == '>' || c == '=' || c == '!':
+			if i+1 < len(s) && s[i+1] == '=' {
+				tokens = append(tokens, s[i:i+2])
+				i += 2
+			} else if c == '<' || c == '>' {
+				tokens = append(tokens, string(c))
+				i++
+			} else {
+				return nil, fmt.Errorf("invalid operator %q", string(c))
+			}
+		case isRuleWordChar(c) || c == '-':
+			j := i + 1
+			for j < len(s) && isRuleWordChar(s[j]) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", string(c))
+		}
+	}
+	return tokens, nil
+}
+
+func isRuleWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '.'
+}
+
+type ruleParser struct {
+	tokens []string
+	pos    int
+	rule   *Rule
+}
+
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *ruleParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseUnary() (ruleNode, error) {
+	switch p.peek() {
+	case "not":
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return ruleNot{inner: inner}, nil
+	case "(":
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *ruleParser) parseComparison() (ruleNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var op string
+	switch tok := p.next(); tok {
+	case ruleOpLT, ruleOpLE, ruleOpGT, ruleOpGE, ruleOpEQ, ruleOpNE:
+		op = tok
+	case "crosses":
+		switch p.next() {
+		case "above":
+			op = ruleOpCrossesAbove
+		case "below":
+			op = ruleOpCrossesBelow
+		default:
+			return nil, fmt.Errorf("expected \"above\" or \"below\" after \"crosses\"")
+		}
+	case "crosses_above":
+		op = ruleOpCrossesAbove
+	case "crosses_below":
+		op = ruleOpCrossesBelow
+	case "":
+		return nil, fmt.Errorf("missing comparison after %q", left.name)
+	default:
+		return nil, fmt.Errorf("expected a comparison operator after %q, got %q", left.name, tok)
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	cmp := ruleComparison{left: left, right: right, op: op}
+	if op == ruleOpCrossesAbove || op == ruleOpCrossesBelow {
+		if left.kind == operandSnapshot || right.kind == operandSnapshot {
+			return nil, fmt.Errorf("%q can't be used with snapshot values", op)
+		}
+	}
+
+	if p.peek() == "on" {
+		p.next()
+		tf, err := market.NormalizeTimeframe(p.next())
+		if err != nil {
+			return nil, err
+		}
+		cmp.timeframe = tf
+		p.addTimeframe(tf)
+	}
+	for _, o := range []ruleOperand{left, right} {
+		if need := o.lookback() + 1; need > p.rule.lookback {
+			p.rule.lookback = need
+		}
+	}
+	return cmp, nil
+}
+
+func (p *ruleParser) parseOperand() (ruleOperand, error) {
+	tok := p.next()
+	if tok == "" {
+		return ruleOperand{}, fmt.Errorf("unexpected end of rule")
+	}
+	if tok == "(" || tok == ")" || strings.ContainsAny(tok, "<>=!") {
+		return ruleOperand{}, fmt.Errorf("expected an indicator or number, got %q", tok)
+	}
+	return parseRuleOperand(tok)
+}
+
+func (p *ruleParser) addTimeframe(tf string) {
+	for _, existing := range p.rule.timeframes {
+		if existing == tf {
+			return
+		}
+	}
+	p.rule.timeframes = append(p.rule.timeframes, tf)
+}
@@ -0,0 +1,220 @@
+package kernel
+
+import (
+	"strings"
+	"testing"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+// ruleKlines builds klines closing at the given prices, oldest first
+func ruleKlines(closes ...float64) []market.Kline {
+	klines := make([]market.Kline, len(closes))
+	for i, c := range closes {
+		klines[i] = market.Kline{OpenTime: int64(i) * 60000, Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 10}
+	}
+	return klines
+}
+
+// flatThen returns n flat closes at base followed by the given closes
+func flatThen(n int, base float64, last ...float64) []float64 {
+	closes := make([]float64, 0, n+len(last))
+	for i := 0; i < n; i++ {
+		closes = append(closes, base)
+	}
+	return append(closes, last...)
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"close >",
+		"close > 100 and",
+		"(close > 100",
+		"close > 100)",
+		"foo > 1",
+		"ema0 > 1",
+		"ema501 > 1",
+		"close > 100 on 7x",
+		"funding_rate crosses above 0.01",
+		"close 100",
+		"close > 100 # comment",
+	} {
+		if _, err := ParseRule(text); err == nil {
+			t.Errorf("ParseRule(%q): expected error", text)
+		}
+	}
+}
+
+func TestRuleTimeframesAndLookback(t *testing.T) {
+	rules, err := CompileRules(&store.RuleStrategyConfig{
+		EntryLong: "EMA20 crosses above ema50 on 1H and rsi14 < 70",
+		ExitLong:  "close < sma10 on 4h or close < ema20 on 1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(rules.Timeframes(), ","); got != "1h,4h" {
+		t.Errorf("timeframes: got %q, want 1h,4h", got)
+	}
+	if got := rules.Lookback(); got != 152 {
+		t.Errorf("lookback: got %d, want 152 (ema50 warm-up + previous bar)", got)
+	}
+	if rules.EntryLong.String() != "EMA20 crosses above ema50 on 1H and rsi14 < 70" {
+		t.Errorf("rule text not kept: %q", rules.EntryLong)
+	}
+
+	if _, err := CompileRules(&store.RuleStrategyConfig{ExitLong: "close < 1"}); err == nil {
+		t.Error("expected error without entry rule")
+	}
+	if _, err := CompileRules(&store.RuleStrategyConfig{EntryLong: "close >"}); err == nil || !strings.HasPrefix(err.Error(), "entry_long:") {
+		t.Errorf("expected entry_long parse error, got %v", err)
+	}
+	if _, err := CompileRules(&store.RuleStrategyConfig{EntryLong: "close > 1", StopLossPct: 100}); err == nil {
+		t.Error("expected error for 100% stop loss")
+	}
+}
+
+func TestRuleEval(t *testing.T) {
+	primary := ruleKlines(flatThen(30, 100, 120)...)
+	hourly := ruleKlines(flatThen(30, 100, 90)...)
+	in := RuleInput{
+		Data: &market.Data{FundingRate: 0.0002, PriceChange1h: 2.5},
+		Klines: func(tf string) []market.Kline {
+			if tf == "1h" {
+				return hourly
+			}
+			return primary
+		},
+		PrimaryTimeframe: "5m",
+	}
+
+	cases := []struct {
+		text string
+		want bool
+	}{
+		{"close > 110", true},
+		{"close crosses above sma20", true},
+		{"close crosses below sma20", false},
+		{"close crosses below sma20 on 1h", true},
+		{"close crosses above 100", true},
+		{"open == 120 and high == 121 and low == 119", true},
+		{"rsi14 > 70", true},
+		{"boll_upper > boll_middle and boll_middle > boll_lower", true},
+		{"funding_rate > 0.01", true},
+		{"funding_rate > 0.05 or change_1h >= 2.5", true},
+		{"not (close > 110 and change_1h > 3)", true},
+		{"close > 110 and not close > 110 on 1h", true},
+		{"close > 110 on 1h", false},
+	}
+	for _, tc := range cases {
+		rule, err := ParseRule(tc.text)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", tc.text, err)
+		}
+		got, err := rule.Eval(in)
+		if err != nil {
+			t.Fatalf("Eval(%q): %v", tc.text, err)
+		}
+		if got != tc.want {
+			t.Errorf("Eval(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+
+	// Not enough klines to compute the indicator
+	rule, _ := ParseRule("ema50 > 1")
+	if _, err := rule.Eval(RuleInput{Klines: func(string) []market.Kline { return ruleKlines(1, 2, 3) }}); err == nil {
+		t.Error("expected error with too few klines")
+	}
+	// Snapshot operand without market data
+	rule, _ = ParseRule("oi_change > 1")
+	if _, err := rule.Eval(RuleInput{Data: &market.Data{}}); err == nil {
+		t.Error("expected error without open interest data")
+	}
+}
+
+func TestGetRuleDecision(t *testing.T) {
+	engine := NewStrategyEngine(&store.StrategyConfig{
+		StrategyType: store.StrategyTypeRules,
+		RuleConfig: &store.RuleStrategyConfig{
+			EntryLong:       "close crosses above sma20",
+			EntryShort:      "close crosses below sma20",
+			ExitLong:        "close < sma20",
+			PositionSizePct: 50,
+			Leverage:        10,
+			StopLossPct:     2,
+			TakeProfitPct:   4,
+		},
+		RiskControl: store.RiskControlConfig{
+			MaxPositions:                 2,
+			BTCETHMaxLeverage:            5,
+			AltcoinMaxLeverage:           5,
+			BTCETHMaxPositionValueRatio:  5,
+			AltcoinMaxPositionValueRatio: 1,
+		},
+	})
+
+	series := map[string][]market.Kline{
+		"ETHUSDT":  ruleKlines(flatThen(30, 100, 90)...),  // Open long position crossing down: exit
+		"SOLUSDT":  ruleKlines(flatThen(30, 100, 120)...), // Crosses up: open long
+		"DOGEUSDT": ruleKlines(flatThen(30, 100, 80)...),  // Crosses down: open short, but max positions reached
+		"XRPUSDT":  ruleKlines(flatThen(30, 100, 100)...), // No signal
+	}
+	ctx := &Context{
+		Account:   AccountInfo{TotalEquity: 1000, AvailableBalance: 1000},
+		Positions: []PositionInfo{{Symbol: "ETHUSDT", Side: "long"}, {Symbol: "BTCUSDT", Side: "short"}},
+		CandidateCoins: []CandidateCoin{
+			{Symbol: "BTCUSDT"}, {Symbol: "XRPUSDT"}, {Symbol: "SOLUSDT"}, {Symbol: "DOGEUSDT"},
+		},
+		MarketDataMap: map[string]*market.Data{
+			"BTCUSDT":  {Symbol: "BTCUSDT", CurrentPrice: 50000},
+			"ETHUSDT":  {Symbol: "ETHUSDT", CurrentPrice: 90},
+			"SOLUSDT":  {Symbol: "SOLUSDT", CurrentPrice: 120},
+			"DOGEUSDT": {Symbol: "DOGEUSDT", CurrentPrice: 80},
+			"XRPUSDT":  {Symbol: "XRPUSDT", CurrentPrice: 100},
+		},
+	}
+	klines := func(symbol, timeframe string) []market.Kline { return series[symbol] }
+
+	decision, err := GetRuleDecision(ctx, engine, klines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make(map[string]Decision)
+	for _, d := range decision.Decisions {
+		actions[d.Symbol] = d
+	}
+	if len(decision.Decisions) != 3 {
+		t.Fatalf("got %d decisions, want 3: %+v", len(decision.Decisions), decision.Decisions)
+	}
+	if actions["ETHUSDT"].Action != "close_long" {
+		t.Errorf("ETHUSDT: got %q, want close_long", actions["ETHUSDT"].Action)
+	}
+	// No short exit rule and no klines: the short is held
+	if actions["BTCUSDT"].Action != "hold" {
+		t.Errorf("BTCUSDT: got %q, want hold", actions["BTCUSDT"].Action)
+	}
+
+	sol := actions["SOLUSDT"]
+	if sol.Action != "open_long" || sol.Leverage != 5 || sol.PositionSizeUSD != 500 {
+		t.Errorf("unexpected SOLUSDT entry (leverage capped to 5, size 50%% of equity): %+v", sol)
+	}
+	if sol.StopLoss < 117.59 || sol.StopLoss > 117.61 || sol.TakeProfit < 124.79 || sol.TakeProfit > 124.81 {
+		t.Errorf("unexpected SOLUSDT stop loss / take profit: %.2f / %.2f", sol.StopLoss, sol.TakeProfit)
+	}
+	if _, ok := actions["DOGEUSDT"]; ok {
+		t.Error("DOGEUSDT should be skipped once max positions is reached")
+	}
+	if !strings.Contains(decision.CoTTrace, "DOGEUSDT open_short skipped: max positions (2) reached") {
+		t.Errorf("trace missing max positions skip:\n%s", decision.CoTTrace)
+	}
+	if !strings.Contains(decision.UserPrompt, "entry_long: close crosses above sma20") {
+		t.Errorf("rules not described:\n%s", decision.UserPrompt)
+	}
+
+	// Not a rule strategy
+	if _, err := GetRuleDecision(ctx, NewStrategyEngine(&store.StrategyConfig{}), klines); err == nil {
+		t.Error("expected error without rule config")
+	}
+}
@@ -0,0 +1,240 @@
+package kernel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"nofx/logger"
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/provider/nofxos"
+)
+
+// ============================================================================
+// Market Data Tools (tool calling mode)
+// ============================================================================
+
+const (
+	toolDefaultKlineLimit = 30
+	toolMaxKlineLimit     = 200
+	toolMaxRankingLimit   = 50
+)
+
+type symbolArgs struct {
+	Symbol string `json:"symbol"`
+}
+
+type klineArgs struct {
+	Symbol    string `json:"symbol"`
+	Timeframe string `json:"timeframe"`
+	Limit     int    `json:"limit"`
+}
+
+type oiRankingArgs struct {
+	Duration string `json:"duration"`
+	Limit    int    `json:"limit"`
+}
+
+// NewMarketTools builds the tool registry the AI can use to request market data mid-decision
+func NewMarketTools(engine *StrategyEngine) *mcp.ToolRegistry {
+	registry := mcp.NewToolRegistry()
+	symbolSchema := map[string]any{
+		"type":        "string",
+		"description": "Trading pair, e.g. BTCUSDT",
+	}
+
+	registry.Register("get_market_data",
+		"Get current price, indicators and multi-timeframe klines for a coin, in the same format as the prompt",
+		map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"symbol": symbolSchema},
+			"required":   []string{"symbol"},
+		},
+		func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args symbolArgs
+			if err := decodeSymbolArgs(raw, &args, &args.Symbol); err != nil {
+				return "", err
+			}
+			timeframes, primaryTimeframe, klineCount := engine.klineSettings()
+			data, err := market.GetWithTimeframes(args.Symbol, timeframes, primaryTimeframe, klineCount)
+			if err != nil {
+				return "", err
+			}
+			return engine.formatMarketData(data), nil
+		})
+
+	registry.Register("get_klines",
+		"Get recent OHLCV klines for a coin at a timeframe",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"symbol": symbolSchema,
+				"timeframe": map[string]any{
+					"type":        "string",
+					"description": "Kline interval",
+					"enum":        market.SupportedTimeframes(),
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of klines (default %d, max %d)", toolDefaultKlineLimit, toolMaxKlineLimit),
+				},
+			},
+			"required": []string{"symbol", "timeframe"},
+		},
+		func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args klineArgs
+			if err := decodeSymbolArgs(raw, &args, &args.Symbol); err != nil {
+				return "", err
+			}
+			timeframe, err := market.NormalizeTimeframe(args.Timeframe)
+			if err != nil {
+				return "", err
+			}
+			duration, err := market.TFDuration(timeframe)
+			if err != nil {
+				return "", err
+			}
+			limit := clampToolLimit(args.Limit, toolDefaultKlineLimit, toolMaxKlineLimit)
+
+			end := time.Now()
+			start := end.Add(-duration * time.Duration(limit+1))
+			klines, err := market.GetKlinesRange(args.Symbol, timeframe, start, end)
+			if err != nil {
+				return "", err
+			}
+			if len(klines) > limit {
+				klines = klines[len(klines)-limit:]
+			}
+			return formatToolKlines(args.Symbol, timeframe, klines), nil
+		})
+
+	registry.Register("get_funding_rate",
+		"Get the current funding rate and open interest for a coin",
+		map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"symbol": symbolSchema},
+			"required":   []string{"symbol"},
+		},
+		func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args symbolArgs
+			if err := decodeSymbolArgs(raw, &args, &args.Symbol); err != nil {
+				return "", err
+			}
+			data, err := market.Get(args.Symbol)
+			if err != nil {
+				return "", err
+			}
+			result := fmt.Sprintf("%s funding rate: %.6f%% | price: %.6f", data.Symbol, data.FundingRate*100, data.CurrentPrice)
+			if data.OpenInterest != nil {
+				result += fmt.Sprintf(" | OI latest: %.2f, average: %.2f", data.OpenInterest.Latest, data.OpenInterest.Average)
+			}
+			return result, nil
+		})
+
+	registry.Register("get_oi_ranking",
+		"Get the market-wide open interest increase/decrease ranking",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"duration": map[string]any{
+					"type": "string",
+					"enum": []string{"1h", "4h", "24h"},
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Number of entries (default 10)",
+				},
+			},
+		},
+		func(ctx context.Context, raw json.RawMessage) (string, error) {
+			var args oiRankingArgs
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			if args.Duration == "" {
+				args.Duration = "1h"
+			}
+			data, err := engine.nofxosClient.GetOIRanking(args.Duration, clampToolLimit(args.Limit, 10, toolMaxRankingLimit))
+			if err != nil {
+				return "", err
+			}
+			lang := nofxos.LangEnglish
+			if engine.GetLanguage() == LangChinese {
+				lang = nofxos.LangChinese
+			}
+			return nofxos.FormatOIRankingForAI(data, lang), nil
+		})
+
+	return registry
+}
+
+// callWithMarketTools runs the decision request as a tool calling loop
+func callWithMarketTools(caller mcp.ToolCaller, engine *StrategyEngine, systemPrompt, userPrompt string) (*mcp.ToolLoopResult, error) {
+	req, err := mcp.NewRequestBuilder().
+		WithSystemPrompt(systemPrompt).
+		WithUserPrompt(userPrompt).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	limits := mcp.ToolLoopLimits{MaxIterations: engine.config.Indicators.ToolMaxIterations}
+	result, err := caller.CallWithTools(context.Background(), req, NewMarketTools(engine), limits)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("🔧 AI decision used %d tool calls in %d turns", len(result.Calls), result.Iterations)
+	return result, nil
+}
+
+// toolUsePrompt tells the AI that candidate data must be fetched through tools
+func (e *StrategyEngine) toolUsePrompt() string {
+	if e.GetLanguage() == LangChinese {
+		return "\n\n# 数据工具\n\n" +
+			"候选币种的行情数据未预加载。请使用工具按需获取：get_market_data（完整行情与指标）、get_klines（K线）、" +
+			"get_funding_rate（资金费率与持仓量）、get_oi_ranking（全市场持仓量排行）。" +
+			"只获取做决策所需的数据，获取完毕后按要求的格式输出最终决策。\n"
+	}
+	return "\n\n# Data Tools\n\n" +
+		"Market data for candidate coins is not preloaded. Fetch what you need with the tools: " +
+		"get_market_data (full market data and indicators), get_klines (OHLCV), " +
+		"get_funding_rate (funding rate and open interest), get_oi_ranking (market-wide OI ranking). " +
+		"Only fetch data you need for the decision, then output the final decision in the required format.\n"
+}
+
+// decodeSymbolArgs unmarshals tool arguments and normalizes the required symbol field
+func decodeSymbolArgs(raw json.RawMessage, v any, symbol *string) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	if strings.TrimSpace(*symbol) == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	*symbol = market.Normalize(*symbol)
+	return nil
+}
+
+func clampToolLimit(limit, fallback, max int) int {
+	if limit <= 0 {
+		return fallback
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// formatToolKlines compact OHLCV table, oldest first
+func formatToolKlines(symbol, timeframe string, klines []market.Kline) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s klines (%d, oldest first)\n", symbol, timeframe, len(klines)))
+	sb.WriteString("time(UTC) open high low close volume\n")
+	for _, k := range klines {
+		sb.WriteString(fmt.Sprintf("%s %.6g %.6g %.6g %.6g %.4g\n",
+			time.UnixMilli(k.OpenTime).UTC().Format("01-02 15:04"),
+			k.Open, k.High, k.Low, k.Close, k.Volume))
+	}
+	return sb.String()
+}
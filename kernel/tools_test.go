@@ -0,0 +1,95 @@
+package kernel
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+func TestNewMarketTools(t *testing.T) {
+	config := store.GetDefaultStrategyConfig("en")
+	registry := NewMarketTools(NewStrategyEngine(&config))
+
+	var names []string
+	for _, tool := range registry.Tools() {
+		names = append(names, tool.Function.Name)
+	}
+	if got := strings.Join(names, ","); got != "get_market_data,get_klines,get_funding_rate,get_oi_ranking" {
+		t.Fatalf("unexpected tools: %s", got)
+	}
+
+	// Argument validation happens before any network access
+	invalid := []struct {
+		tool string
+		args string
+	}{
+		{"get_market_data", `{}`},
+		{"get_funding_rate", `{"symbol":"  "}`},
+		{"get_klines", `{"symbol":"BTCUSDT","timeframe":"7m"}`},
+		{"get_klines", `{"symbol":42}`},
+	}
+	for _, tc := range invalid {
+		if _, err := registry.Call(context.Background(), tc.tool, json.RawMessage(tc.args)); err == nil {
+			t.Errorf("%s(%s): expected error", tc.tool, tc.args)
+		}
+	}
+}
+
+func TestClampToolLimit(t *testing.T) {
+	if got := clampToolLimit(0, 30, 200); got != 30 {
+		t.Errorf("expected default 30, got %d", got)
+	}
+	if got := clampToolLimit(500, 30, 200); got != 200 {
+		t.Errorf("expected max 200, got %d", got)
+	}
+	if got := clampToolLimit(50, 30, 200); got != 50 {
+		t.Errorf("expected 50, got %d", got)
+	}
+}
+
+func TestFormatToolKlines(t *testing.T) {
+	open := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC).UnixMilli()
+	out := formatToolKlines("BTCUSDT", "1h", []market.Kline{
+		{OpenTime: open, Open: 42000, High: 42100, Low: 41900, Close: 42050, Volume: 12.5},
+	})
+	if !strings.Contains(out, "BTCUSDT 1h klines (1, oldest first)") || !strings.Contains(out, "01-01 08:00 42000 42100 41900 42050 12.5") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestBuildUserPrompt_ToolCallingListsPendingCandidates(t *testing.T) {
+	config := store.GetDefaultStrategyConfig("en")
+	config.Indicators.EnableToolCalling = true
+	engine := NewStrategyEngine(&config)
+
+	ctx := &Context{
+		Account: AccountInfo{TotalEquity: 1000, AvailableBalance: 1000},
+		Positions: []PositionInfo{
+			{Symbol: "BTCUSDT", Side: "long", EntryPrice: 42000, MarkPrice: 42100, Quantity: 0.01, Leverage: 5},
+		},
+		CandidateCoins: []CandidateCoin{
+			{Symbol: "BTCUSDT"},
+			{Symbol: "ETHUSDT"},
+			{Symbol: "SOLUSDT"},
+		},
+		MarketDataMap: map[string]*market.Data{},
+	}
+
+	prompt := engine.BuildUserPrompt(ctx)
+	if !strings.Contains(prompt, "## Candidate Coins (3 coins)") {
+		t.Error("candidate count should include coins that are not preloaded")
+	}
+	if !strings.Contains(prompt, "Not preloaded (fetch with tools as needed): ETHUSDT, SOLUSDT") {
+		t.Errorf("pending candidates missing from prompt:\n%s", prompt)
+	}
+
+	config.Indicators.EnableToolCalling = false
+	if strings.Contains(engine.BuildUserPrompt(ctx), "Not preloaded") {
+		t.Error("pending candidates should only be listed in tool calling mode")
+	}
+}
@@ -0,0 +1,355 @@
+package kernel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+// ============================================================================
+// Trade Memory (retrieval of closed trades opened in a similar market state)
+// ============================================================================
+
+// Trade memory feature names
+const (
+	featureRSI7          = "rsi7"
+	featureRSI14         = "rsi14"
+	featureMACDPct       = "macd_pct"        // MACD relative to price (%)
+	featurePriceEMA20Pct = "price_ema20_pct" // Price distance from EMA20 (%)
+	featureEMA20EMA50Pct = "ema20_ema50_pct" // Longer-term EMA20 distance from EMA50 (%)
+	featureATRPct        = "atr_pct"         // Longer-term ATR14 relative to price (%)
+	featureBollWidthPct  = "boll_width_pct"  // Intraday Bollinger band width (%)
+	featureChange1hPct   = "change_1h_pct"
+	featureChange4hPct   = "change_4h_pct"
+	featureFundingPct    = "funding_rate_pct"
+	featureOIChangePct   = "oi_change_pct" // Latest OI vs its average (%)
+)
+
+// Trend regimes of the longer-term timeframe
+const (
+	regimeUptrend   = "uptrend"
+	regimeDowntrend = "downtrend"
+	regimeRange     = "range"
+)
+
+// featureScaleFloors minimum spread of each feature when normalizing distances, so that a handful of
+// almost identical memories doesn't turn tiny differences into large distances
+var featureScaleFloors = map[string]float64{
+	featureRSI7:          5,
+	featureRSI14:         5,
+	featureMACDPct:       0.05,
+	featurePriceEMA20Pct: 0.5,
+	featureEMA20EMA50Pct: 0.5,
+	featureATRPct:        0.2,
+	featureBollWidthPct:  0.5,
+	featureChange1hPct:   0.5,
+	featureChange4hPct:   1,
+	featureFundingPct:    0.005,
+	featureOIChangePct:   1,
+}
+
+// MaxTradeMemories number of most recently closed trades considered per retrieval
+const MaxTradeMemories = 500
+
+const (
+	defaultTradeMemoryK   = 3
+	minSharedFeatures     = 3   // Memories sharing fewer features with the current state are skipped
+	regimeMismatchPenalty = 1.0 // Added to the distance when the trend regimes differ
+)
+
+// TradeFeatures market state of a symbol, stored when a position is opened
+type TradeFeatures struct {
+	Regime string             `json:"regime,omitempty"`
+	Values map[string]float64 `json:"values"` // Only features with data are present
+}
+
+// SimilarTrade closed trade whose entry market state resembles the current state of a symbol
+type SimilarTrade struct {
+	Symbol       string  `json:"symbol"`
+	Side         string  `json:"side"` // long/short
+	Regime       string  `json:"regime,omitempty"`
+	Similarity   float64 `json:"similarity"` // 0-100
+	RealizedPnL  float64 `json:"realized_pnl"`
+	PnLPct       float64 `json:"pnl_pct"` // Leveraged price move, same as RecentOrder.PnLPct
+	HoldDuration string  `json:"hold_duration,omitempty"`
+	CloseReason  string  `json:"close_reason,omitempty"`
+}
+
+// ExtractTradeFeatures builds the feature vector of a symbol's current market state
+func ExtractTradeFeatures(data *market.Data) TradeFeatures {
+	features := TradeFeatures{Values: make(map[string]float64)}
+	if data == nil || data.CurrentPrice <= 0 {
+		return features
+	}
+	price := data.CurrentPrice
+	set := func(name string, value float64) {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			features.Values[name] = value
+		}
+	}
+
+	if data.CurrentRSI7 > 0 {
+		set(featureRSI7, data.CurrentRSI7)
+	}
+	set(featureMACDPct, data.CurrentMACD/price*100)
+	if data.CurrentEMA20 > 0 {
+		set(featurePriceEMA20Pct, (price-data.CurrentEMA20)/data.CurrentEMA20*100)
+	}
+	set(featureChange1hPct, data.PriceChange1h)
+	set(featureChange4hPct, data.PriceChange4h)
+	set(featureFundingPct, data.FundingRate*100)
+
+	if series := data.IntradaySeries; series != nil {
+		if n := len(series.RSI14Values); n > 0 {
+			set(featureRSI14, series.RSI14Values[n-1])
+		}
+		if width, ok := bollingerWidthPct(series.MidPrices, 20); ok {
+			set(featureBollWidthPct, width)
+		}
+	}
+
+	if lt := data.LongerTermContext; lt != nil {
+		if lt.EMA20 > 0 && lt.EMA50 > 0 {
+			set(featureEMA20EMA50Pct, (lt.EMA20-lt.EMA50)/lt.EMA50*100)
+			switch {
+			case price > lt.EMA20 && lt.EMA20 > lt.EMA50:
+				features.Regime = regimeUptrend
+			case price < lt.EMA20 && lt.EMA20 < lt.EMA50:
+				features.Regime = regimeDowntrend
+			default:
+				features.Regime = regimeRange
+			}
+		}
+		if lt.ATR14 > 0 {
+			set(featureATRPct, lt.ATR14/price*100)
+		}
+	}
+
+	if oi := data.OpenInterest; oi != nil && oi.Average > 0 {
+		set(featureOIChangePct, (oi.Latest-oi.Average)/oi.Average*100)
+	}
+	return features
+}
+
+// bollingerWidthPct width of the Bollinger bands (2 standard deviations) over the last period prices,
+// relative to the middle band
+func bollingerWidthPct(prices []float64, period int) (float64, bool) {
+	if len(prices) < period {
+		return 0, false
+	}
+	window := prices[len(prices)-period:]
+	mean := 0.0
+	for _, p := range window {
+		mean += p
+	}
+	mean /= float64(period)
+	if mean <= 0 {
+		return 0, false
+	}
+	variance := 0.0
+	for _, p := range window {
+		variance += (p - mean) * (p - mean)
+	}
+	stdDev := math.Sqrt(variance / float64(period))
+	return 4 * stdDev / mean * 100, true
+}
+
+// tradeMemoryIndex decoded trade memories with per-feature scales for distance normalization
+type tradeMemoryIndex struct {
+	memories []*store.ClosedTradeMemory
+	features []TradeFeatures
+	scales   map[string]float64
+}
+
+// newTradeMemoryIndex decodes the memories' features; memories with unreadable features are dropped
+func newTradeMemoryIndex(memories []*store.ClosedTradeMemory) *tradeMemoryIndex {
+	idx := &tradeMemoryIndex{scales: make(map[string]float64)}
+	for _, m := range memories {
+		var f TradeFeatures
+		if err := json.Unmarshal([]byte(m.Features), &f); err != nil || len(f.Values) == 0 {
+			continue
+		}
+		idx.memories = append(idx.memories, m)
+		idx.features = append(idx.features, f)
+	}
+
+	// Standard deviation of each feature over all memories, at least its floor
+	for name, floor := range featureScaleFloors {
+		var sum, sumSq float64
+		n := 0
+		for _, f := range idx.features {
+			if v, ok := f.Values[name]; ok {
+				sum += v
+				sumSq += v * v
+				n++
+			}
+		}
+		scale := floor
+		if n > 1 {
+			mean := sum / float64(n)
+			if std := math.Sqrt(math.Max(sumSq/float64(n)-mean*mean, 0)); std > scale {
+				scale = std
+			}
+		}
+		idx.scales[name] = scale
+	}
+	return idx
+}
+
+// distance normalized RMS distance over the features both vectors have, plus a penalty when the
+// trend regimes differ. ok is false when they share too few features to compare.
+func (idx *tradeMemoryIndex) distance(a, b TradeFeatures) (float64, bool) {
+	sum := 0.0
+	n := 0
+	for name, va := range a.Values {
+		vb, ok := b.Values[name]
+		scale := idx.scales[name]
+		if !ok || scale <= 0 {
+			continue
+		}
+		d := (va - vb) / scale
+		sum += d * d
+		n++
+	}
+	if n < minSharedFeatures {
+		return 0, false
+	}
+	dist := math.Sqrt(sum / float64(n))
+	if a.Regime != "" && b.Regime != "" && a.Regime != b.Regime {
+		dist += regimeMismatchPenalty
+	}
+	return dist, true
+}
+
+// nearest returns the k memories closest to the current features, most similar first
+func (idx *tradeMemoryIndex) nearest(current TradeFeatures, k int) []SimilarTrade {
+	type match struct {
+		i    int
+		dist float64
+	}
+	var matches []match
+	for i, f := range idx.features {
+		if dist, ok := idx.distance(current, f); ok {
+			matches = append(matches, match{i: i, dist: dist})
+		}
+	}
+	// Stable sort keeps the more recently closed trade first on ties
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].dist < matches[b].dist })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+
+	trades := make([]SimilarTrade, 0, len(matches))
+	for _, m := range matches {
+		mem := idx.memories[m.i]
+		side := strings.ToLower(mem.Side)
+		trade := SimilarTrade{
+			Symbol:       mem.Symbol,
+			Side:         side,
+			Regime:       idx.features[m.i].Regime,
+			Similarity:   100 / (1 + m.dist),
+			RealizedPnL:  mem.RealizedPnL,
+			HoldDuration: mem.HoldDuration,
+			CloseReason:  mem.CloseReason,
+		}
+		if mem.EntryPrice > 0 {
+			move := (mem.ExitPrice - mem.EntryPrice) / mem.EntryPrice * 100 * float64(mem.Leverage)
+			if side == "short" {
+				move = -move
+			}
+			trade.PnLPct = move
+		}
+		trades = append(trades, trade)
+	}
+	return trades
+}
+
+// FindSimilarTrades returns the k closed trades whose entry market state is closest to current,
+// most similar first
+func FindSimilarTrades(current TradeFeatures, memories []*store.ClosedTradeMemory, k int) []SimilarTrade {
+	if k <= 0 || len(current.Values) == 0 {
+		return nil
+	}
+	return newTradeMemoryIndex(memories).nearest(current, k)
+}
+
+// findSimilarTrades retrieves similar past trades for every position and candidate with market data
+func (e *StrategyEngine) findSimilarTrades(ctx *Context) map[string][]SimilarTrade {
+	if len(ctx.TradeMemories) == 0 || len(ctx.MarketDataMap) == 0 {
+		return nil
+	}
+	k := e.config.Indicators.TradeMemoryK
+	if k <= 0 {
+		k = defaultTradeMemoryK
+	}
+
+	idx := newTradeMemoryIndex(ctx.TradeMemories)
+	result := make(map[string][]SimilarTrade)
+	for symbol, data := range ctx.MarketDataMap {
+		current := ExtractTradeFeatures(data)
+		if len(current.Values) == 0 {
+			continue
+		}
+		if trades := idx.nearest(current, k); len(trades) > 0 {
+			result[symbol] = trades
+		}
+	}
+	return result
+}
+
+// formatSimilarTrades renders the similar past trades of positions and candidates (in prompt order)
+func (e *StrategyEngine) formatSimilarTrades(ctx *Context) string {
+	if len(ctx.SimilarTrades) == 0 {
+		return ""
+	}
+	var symbols []string
+	seen := make(map[string]bool)
+	addSymbol := func(symbol string) {
+		if !seen[symbol] && len(ctx.SimilarTrades[symbol]) > 0 {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, pos := range ctx.Positions {
+		addSymbol(pos.Symbol)
+	}
+	for _, coin := range ctx.CandidateCoins {
+		addSymbol(coin.Symbol)
+	}
+	if len(symbols) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	if e.GetLanguage() == LangChinese {
+		sb.WriteString("## 相似历史行情\n")
+		sb.WriteString("开仓时市场状态与当前最相似的已平仓交易（相似度 0-100）:\n")
+	} else {
+		sb.WriteString("## Similar Past Setups\n")
+		sb.WriteString("Closed trades opened in the market state closest to each symbol's current one (similarity 0-100):\n")
+	}
+	for _, symbol := range symbols {
+		sb.WriteString(symbol + ":\n")
+		for _, t := range ctx.SimilarTrades[symbol] {
+			line := fmt.Sprintf("- %s %s | sim %.0f", t.Symbol, t.Side, t.Similarity)
+			if t.Regime != "" {
+				line += " | " + t.Regime
+			}
+			line += fmt.Sprintf(" | %+.2f USDT (%+.2f%%)", t.RealizedPnL, t.PnLPct)
+			if t.HoldDuration != "" {
+				line += " | held " + t.HoldDuration
+			}
+			if t.CloseReason != "" {
+				line += " | " + t.CloseReason
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,124 @@
+package kernel
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"nofx/market"
+	"nofx/store"
+)
+
+func tradeMemory(t *testing.T, id int64, side string, pnl float64, features TradeFeatures) *store.ClosedTradeMemory {
+	t.Helper()
+	raw, err := json.Marshal(features)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &store.ClosedTradeMemory{
+		PositionID: id, Symbol: "BTCUSDT", Side: side, Features: string(raw),
+		EntryPrice: 100, ExitPrice: 100 + pnl, Leverage: 2, RealizedPnL: pnl, HoldDuration: "1h",
+	}
+}
+
+func TestExtractTradeFeatures(t *testing.T) {
+	data := &market.Data{
+		CurrentPrice:      110,
+		CurrentEMA20:      100,
+		CurrentRSI7:       70,
+		PriceChange1h:     1.5,
+		FundingRate:       0.0001,
+		OpenInterest:      &market.OIData{Latest: 120, Average: 100},
+		LongerTermContext: &market.LongerTermData{EMA20: 105, EMA50: 100, ATR14: 2.2},
+	}
+	f := ExtractTradeFeatures(data)
+	if f.Regime != regimeUptrend {
+		t.Errorf("regime: got %q, want %q", f.Regime, regimeUptrend)
+	}
+	want := map[string]float64{
+		featureRSI7:          70,
+		featurePriceEMA20Pct: 10,
+		featureEMA20EMA50Pct: 5,
+		featureATRPct:        2,
+		featureOIChangePct:   20,
+		featureChange1hPct:   1.5,
+		featureFundingPct:    0.01,
+	}
+	for name, v := range want {
+		if got, ok := f.Values[name]; !ok || got < v-1e-9 || got > v+1e-9 {
+			t.Errorf("%s: got %v (present %v), want %v", name, got, ok, v)
+		}
+	}
+	if _, ok := f.Values[featureRSI14]; ok {
+		t.Error("rsi14 must be absent without intraday series")
+	}
+	if len(ExtractTradeFeatures(nil).Values) != 0 {
+		t.Error("nil data should have no features")
+	}
+}
+
+func TestFindSimilarTrades(t *testing.T) {
+	current := TradeFeatures{Regime: regimeUptrend, Values: map[string]float64{
+		featureRSI7: 65, featureATRPct: 1.5, featureChange1hPct: 1, featureFundingPct: 0.01,
+	}}
+	memories := []*store.ClosedTradeMemory{
+		// Same numbers but opposite regime
+		tradeMemory(t, 1, "LONG", -5, TradeFeatures{Regime: regimeDowntrend, Values: map[string]float64{
+			featureRSI7: 65, featureATRPct: 1.5, featureChange1hPct: 1, featureFundingPct: 0.01,
+		}}),
+		// Close match
+		tradeMemory(t, 2, "LONG", 8, TradeFeatures{Regime: regimeUptrend, Values: map[string]float64{
+			featureRSI7: 63, featureATRPct: 1.4, featureChange1hPct: 1.2, featureFundingPct: 0.01,
+		}}),
+		// Far away
+		tradeMemory(t, 3, "SHORT", 3, TradeFeatures{Regime: regimeUptrend, Values: map[string]float64{
+			featureRSI7: 25, featureATRPct: 4, featureChange1hPct: -3, featureFundingPct: -0.02,
+		}}),
+		// Too few shared features to compare
+		tradeMemory(t, 4, "LONG", 1, TradeFeatures{Values: map[string]float64{featureRSI7: 65}}),
+		// Unreadable features
+		{PositionID: 5, Features: "{"},
+	}
+
+	trades := FindSimilarTrades(current, memories, 2)
+	if len(trades) != 2 {
+		t.Fatalf("got %d trades, want 2: %+v", len(trades), trades)
+	}
+	if trades[0].RealizedPnL != 8 || trades[1].RealizedPnL != -5 {
+		t.Errorf("unexpected order: %+v", trades)
+	}
+	if trades[0].Side != "long" || trades[0].PnLPct != 16 || trades[0].Similarity <= trades[1].Similarity {
+		t.Errorf("unexpected first trade: %+v", trades[0])
+	}
+	if got := FindSimilarTrades(current, memories, 0); got != nil {
+		t.Errorf("k=0 should return nothing, got %+v", got)
+	}
+}
+
+func TestSimilarTradesPromptSectionIsDroppable(t *testing.T) {
+	engine := NewStrategyEngine(&store.StrategyConfig{})
+	ctx := &Context{
+		CandidateCoins: []CandidateCoin{{Symbol: "ETHUSDT"}, {Symbol: "BTCUSDT"}},
+		SimilarTrades: map[string][]SimilarTrade{
+			"BTCUSDT": {{Symbol: "SOLUSDT", Side: "short", Similarity: 81, Regime: regimeRange, RealizedPnL: -4.5, PnLPct: -9, HoldDuration: "2h", CloseReason: "stop_loss"}},
+			"ETHUSDT": {{Symbol: "ETHUSDT", Side: "long", Similarity: 64, RealizedPnL: 12, PnLPct: 6}},
+		},
+	}
+
+	section := engine.formatSimilarTrades(ctx)
+	if !strings.HasPrefix(section, "## Similar Past Setups\n") {
+		t.Fatalf("unexpected header: %q", section)
+	}
+	if strings.Index(section, "ETHUSDT:") > strings.Index(section, "BTCUSDT:") {
+		t.Error("symbols should follow the candidate order")
+	}
+	if !strings.Contains(section, "- SOLUSDT short | sim 81 | range | -4.50 USDT (-9.00%) | held 2h | stop_loss\n") {
+		t.Errorf("unexpected trade line:\n%s", section)
+	}
+
+	for _, piece := range splitUserPrompt("Time: now\n\n" + section + "## Candidate Coins (2 coins)\n\n---\nDecide") {
+		if strings.Contains(piece.text, "Similar Past Setups") && piece.priority != promptPieceHistory {
+			t.Errorf("similar trades section has priority %d, want history", piece.priority)
+		}
+	}
+}
@@ -115,3 +115,107 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+// TestTrailingStopValidation tests the trailing stop and break-even actions
+func TestTrailingStopValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		decision  Decision
+		wantError bool
+	}{
+		{
+			name:     "Set trailing stop",
+			decision: Decision{Symbol: "BTCUSDT", Action: "set_trailing_stop", TrailingStopPct: 1.5},
+		},
+		{
+			name:      "Set trailing stop without percent",
+			decision:  Decision{Symbol: "BTCUSDT", Action: "set_trailing_stop"},
+			wantError: true,
+		},
+		{
+			name:      "Trailing stop percent too wide",
+			decision:  Decision{Symbol: "BTCUSDT", Action: "set_trailing_stop", TrailingStopPct: 25},
+			wantError: true,
+		},
+		{
+			name:     "Move to break-even",
+			decision: Decision{Symbol: "ETHUSDT", Action: "move_to_breakeven"},
+		},
+		{
+			name: "Open with trailing stop",
+			decision: Decision{
+				Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 100,
+				StopLoss: 50, TakeProfit: 200, TrailingStopPct: 2,
+			},
+		},
+		{
+			name: "Open with trailing stop below minimum",
+			decision: Decision{
+				Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 100,
+				StopLoss: 50, TakeProfit: 200, TrailingStopPct: 0.01,
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDecision(&tt.decision, 100, 10, 5, 10.0, 1.5)
+			if (err != nil) != tt.wantError {
+				t.Errorf("validateDecision() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestTakeProfitLevelsValidation(t *testing.T) {
+	open := func(levels ...TakeProfitLevel) Decision {
+		return Decision{
+			Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 100,
+			StopLoss: 50, TakeProfit: 200, TakeProfitLevels: levels,
+		}
+	}
+	tests := []struct {
+		name      string
+		decision  Decision
+		wantError bool
+	}{
+		{name: "R multiple ladder", decision: open(TakeProfitLevel{RMultiple: 1, Pct: 30}, TakeProfitLevel{RMultiple: 2, Pct: 40})},
+		{name: "Price ladder closing everything", decision: open(TakeProfitLevel{Price: 100, Pct: 50}, TakeProfitLevel{Price: 200, Pct: 50})},
+		{name: "Levels above 100%", decision: open(TakeProfitLevel{RMultiple: 1, Pct: 60}, TakeProfitLevel{RMultiple: 2, Pct: 60}), wantError: true},
+		{name: "Level without percent", decision: open(TakeProfitLevel{RMultiple: 1}), wantError: true},
+		{name: "Level without price", decision: open(TakeProfitLevel{Pct: 30}), wantError: true},
+		{name: "Level below stop loss", decision: open(TakeProfitLevel{Price: 40, Pct: 30}), wantError: true},
+		{name: "Level beyond take profit", decision: open(TakeProfitLevel{Price: 250, Pct: 30}), wantError: true},
+		{
+			name:      "Ladder on a close",
+			decision:  Decision{Symbol: "SOLUSDT", Action: "close_long", TakeProfitLevels: []TakeProfitLevel{{RMultiple: 1, Pct: 30}}},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDecision(&tt.decision, 100, 10, 5, 10.0, 1.5)
+			if (err != nil) != tt.wantError {
+				t.Errorf("validateDecision() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestTakeProfitLadder(t *testing.T) {
+	d := Decision{StopLoss: 110, TakeProfitLevels: []TakeProfitLevel{
+		{RMultiple: 2, Pct: 40},
+		{Price: 95, Pct: 30},
+	}}
+
+	// Short from 100 risking 10: 2R is 80, the fixed 95 comes first
+	ladder := d.TakeProfitLadder("short", 100)
+	if len(ladder) != 2 || ladder[0].Price != 95 || ladder[1].Price != 80 || ladder[1].Pct != 40 {
+		t.Errorf("unexpected ladder: %+v", ladder)
+	}
+	if d.TakeProfitLevels[0].Price != 0 {
+		t.Error("resolving the ladder changed the decision")
+	}
+}
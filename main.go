@@ -9,11 +9,13 @@ import (
 	"nofx/experience"
 	"nofx/logger"
 	"nofx/manager"
+	"nofx/market"
 	"nofx/mcp"
 	"nofx/store"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
 	"syscall"
 
 	"github.com/google/uuid"
@@ -83,6 +85,10 @@ func main() {
 	// Initialize installation ID for experience improvement (anonymous statistics)
 	initInstallationID(st)
 
+	// Persist attributed AI token usage for cost accounting (telemetry callback is kept)
+	flushAIUsage := recordAIUsage(st)
+	defer flushAIUsage() // Before st.Close
+
 	// Set JWT secret
 	auth.SetJWTSecret(cfg.JWTSecret)
 	logger.Info("🔑 JWT secret configured")
@@ -95,6 +101,12 @@ func main() {
 	// time.Sleep(500 * time.Millisecond)
 	logger.Info("📊 Using CoinAnk API for all market data (WebSocket cache disabled)")
 
+	// Local kline store: backtests reuse cached candles, live data falls back to it when the provider is down
+	if cfg.KlineStoreDir != "" {
+		market.SetKlineStore(market.NewKlineStore(cfg.KlineStoreDir, cfg.KlineStoreOffline))
+		logger.Infof("🗄️ Kline store enabled at %s (offline: %v)", cfg.KlineStoreDir, cfg.KlineStoreOffline)
+	}
+
 	// Create TraderManager and BacktestManager
 	traderManager := manager.NewTraderManager()
 	mcpClient := newSharedMCPClient()
@@ -151,6 +163,62 @@ func main() {
 	logger.Info("✅ System shut down safely")
 }
 
+// aiUsageQueueSize usage records buffered for the database writer, so AI calls never wait on it
+const aiUsageQueueSize = 1024
+
+// recordAIUsage chains a store writer onto the AI token usage callback. Records are written by a
+// background goroutine; the returned function writes the queued records and stops it.
+func recordAIUsage(st *store.Store) (flush func()) {
+	queue := make(chan *store.AIUsageRecord, aiUsageQueueSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for record := range queue {
+			if err := st.AIUsage().Record(record); err != nil {
+				logger.Warnf("⚠️ Failed to record AI usage: %v", err)
+			}
+		}
+	}()
+
+	var (
+		mu     sync.RWMutex
+		closed bool
+	)
+	telemetry := mcp.TokenUsageCallback
+	mcp.TokenUsageCallback = func(usage mcp.TokenUsage) {
+		if telemetry != nil {
+			telemetry(usage)
+		}
+		record := &store.AIUsageRecord{
+			UserID:           usage.Attribution.UserID,
+			Source:           usage.Attribution.Source,
+			SourceID:         usage.Attribution.SourceID,
+			Provider:         usage.Provider,
+			Model:            usage.Model,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+		}
+		mu.RLock()
+		defer mu.RUnlock()
+		if closed {
+			return
+		}
+		select {
+		case queue <- record:
+		default:
+			logger.Warnf("⚠️ AI usage queue full, dropping %d tokens of %s %s", usage.TotalTokens, usage.Attribution.Source, usage.Attribution.SourceID)
+		}
+	}
+
+	return func() {
+		mu.Lock()
+		closed = true
+		close(queue)
+		mu.Unlock()
+		<-done
+	}
+}
+
 // newSharedMCPClient creates a shared MCP AI client (for backtesting)
 func newSharedMCPClient() mcp.AIClient {
 	apiKey := os.Getenv("DEEPSEEK_API_KEY")
@@ -674,6 +674,12 @@ func (tm *TraderManager) addTraderFromStore(traderCfg *store.Trader, aiModelCfg
 	logger.Infof("📊 Loading trader %s: ScanIntervalMinutes=%d (from DB), ScanInterval=%v",
 		traderCfg.Name, traderCfg.ScanIntervalMinutes, traderConfig.ScanInterval)
 
+	// Prompt experiment: the assigned template version replaces the strategy's prompts
+	promptContent, err := applyPromptAssignment(st, traderCfg, &traderConfig)
+	if err != nil {
+		return err
+	}
+
 	// Set API keys based on exchange type (convert EncryptedString to string)
 	switch exchangeCfg.ExchangeType {
 	case "binance":
@@ -710,6 +716,8 @@ func (tm *TraderManager) addTraderFromStore(traderCfg *store.Trader, aiModelCfg
 		traderConfig.LighterAPIKeyPrivateKey = string(exchangeCfg.LighterAPIKeyPrivateKey)
 		traderConfig.LighterAPIKeyIndex = exchangeCfg.LighterAPIKeyIndex
 		traderConfig.LighterTestnet = exchangeCfg.Testnet
+	case "paper":
+		// Simulated account backed by the store, no API keys required
 	}
 
 	// Set API keys based on AI model (convert EncryptedString to string)
@@ -723,6 +731,25 @@ func (tm *TraderManager) addTraderFromStore(traderCfg *store.Trader, aiModelCfg
 		traderConfig.CustomAPIKey = string(aiModelCfg.APIKey)
 	}
 
+	// Backup AI models (fallback chain or ensemble voters)
+	if traderCfg.AIMode == store.TraderAIModeFallback || traderCfg.AIMode == store.TraderAIModeEnsemble {
+		traderConfig.AIMode = traderCfg.AIMode
+		traderConfig.EnsembleVote = traderCfg.EnsembleVote
+		for _, modelID := range traderCfg.BackupAIModelIDList() {
+			backup, err := st.AIModel().Get(traderCfg.UserID, modelID)
+			if err != nil || !backup.Enabled {
+				logger.Warnf("⚠️  Backup AI model %s for trader %s is missing or disabled, skipping", modelID, traderCfg.Name)
+				continue
+			}
+			traderConfig.BackupAIModels = append(traderConfig.BackupAIModels, trader.AIModelConfig{
+				Provider:        backup.Provider,
+				APIKey:          string(backup.APIKey),
+				CustomAPIURL:    backup.CustomAPIURL,
+				CustomModelName: backup.CustomModelName,
+			})
+		}
+	}
+
 	// Create trader instance
 	at, err := trader.NewAutoTrader(traderConfig, st, traderCfg.UserID)
 	if err != nil {
@@ -739,6 +766,9 @@ func (tm *TraderManager) addTraderFromStore(traderCfg *store.Trader, aiModelCfg
 			logger.Infof("✓ Set custom trading strategy prompt (supplementing base prompt)")
 		}
 	}
+	if promptContent != nil {
+		at.SetOverrideBasePrompt(promptContent.OverrideBasePrompt)
+	}
 
 	tm.traders[traderCfg.ID] = at
 	logger.Infof("✓ Trader '%s' (%s + %s/%s) loaded to memory", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ExchangeType, exchangeCfg.AccountName)
@@ -761,6 +791,35 @@ func (tm *TraderManager) addTraderFromStore(traderCfg *store.Trader, aiModelCfg
 	return nil
 }
 
+// applyPromptAssignment applies the prompt template version a trader is assigned to in an active
+// prompt experiment. Returns the applied content, nil if the trader takes part in no experiment.
+func applyPromptAssignment(st *store.Store, traderCfg *store.Trader, traderConfig *trader.AutoTraderConfig) (*store.PromptTemplateContent, error) {
+	assignment, err := st.PromptTemplate().ActiveAssignment(store.PromptTargetTrader, traderCfg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt experiment of trader %s: %w", traderCfg.Name, err)
+	}
+	if assignment == nil {
+		return nil, nil
+	}
+
+	version, err := st.PromptTemplate().GetVersion(assignment.TemplateID, assignment.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt template %s v%d for trader %s: %w", assignment.TemplateID, assignment.Version, traderCfg.Name, err)
+	}
+	content, err := version.ParseContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template for trader %s: %w", traderCfg.Name, err)
+	}
+
+	content.ApplyTo(traderConfig.StrategyConfig) // Parsed per trader, nothing else shares it
+	traderConfig.PromptVariant = content.PromptVariant
+	traderConfig.PromptTemplateID = version.TemplateID
+	traderConfig.PromptTemplateVersion = version.Version
+
+	logger.Infof("🧪 Trader %s uses prompt template %s v%d (experiment %s)", traderCfg.Name, version.TemplateID, version.Version, assignment.ExperimentID)
+	return content, nil
+}
+
 // GetTraderExecutor returns a TraderExecutor for the given trader ID
 // This is used by the debate module to execute consensus trades
 func (tm *TraderManager) GetTraderExecutor(traderID string) (debate.TraderExecutor, error) {
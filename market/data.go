@@ -281,6 +281,24 @@ func GetWithExchange(symbol, exchange string) (*Data, error) {
 	}, nil
 }
 
+// GetKlines retrieves the latest limit K-lines of a symbol on a timeframe, oldest first.
+// Falls back to the local kline store when the provider is down.
+func GetKlines(symbol, timeframe string, limit int) ([]Kline, error) {
+	symbol = Normalize(symbol)
+	if IsXyzDexAsset(symbol) {
+		return getKlinesFromHyperliquid(symbol, timeframe, limit)
+	}
+	klines, err := getKlinesFromCoinAnk(symbol, timeframe, "binance", limit)
+	if err != nil {
+		if cached := cachedRecentKlines("binance", symbol, timeframe, limit); len(cached) > 0 {
+			return cached, nil
+		}
+		return nil, err
+	}
+	cacheLiveKlines("binance", symbol, timeframe, klines)
+	return klines, nil
+}
+
 // GetWithTimeframes retrieves market data for specified multiple timeframes
 // timeframes: list of timeframes, e.g. ["5m", "15m", "1h", "4h"]
 // primaryTimeframe: primary timeframe (used for calculating current indicators), defaults to timeframes[0]
@@ -332,8 +350,16 @@ func GetWithTimeframes(symbol string, timeframes []string, primaryTimeframe stri
 			// Use CoinAnk for regular crypto assets (default to Binance)
 			klines, err = getKlinesFromCoinAnk(symbol, tf, "binance", 200)
 			if err != nil {
-				logger.Infof("⚠️ Failed to get %s %s K-line from CoinAnk: %v", symbol, tf, err)
-				continue
+				// Provider down: fall back to recent candles from the local kline store
+				if cached := cachedRecentKlines("binance", symbol, tf, 200); len(cached) > 0 {
+					logger.Warnf("⚠️ Failed to get %s %s K-line from CoinAnk, using kline store: %v", symbol, tf, err)
+					klines = cached
+				} else {
+					logger.Infof("⚠️ Failed to get %s %s K-line from CoinAnk: %v", symbol, tf, err)
+					continue
+				}
+			} else {
+				cacheLiveKlines("binance", symbol, tf, klines)
 			}
 		}
 
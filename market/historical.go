@@ -14,7 +14,29 @@ const (
 )
 
 // GetKlinesRange fetches K-line series within specified time range (closed interval), returns data sorted by time in ascending order.
+// When a kline store is installed, cached candles are reused and only missing stretches are fetched.
 func GetKlinesRange(symbol string, timeframe string, start, end time.Time) ([]Kline, error) {
+	if s := GetKlineStore(); s != nil {
+		if !end.After(start) {
+			return nil, fmt.Errorf("end time must be after start time")
+		}
+		return s.rangeWithBackfill(Normalize(symbol), timeframe, start, end)
+	}
+	return fetchKlinesRange(symbol, timeframe, start, end)
+}
+
+// GetStoredKlinesRange returns the K-lines within [start, end] already in the kline store, never fetching
+// missing ones. Fails when no kline store is installed.
+func GetStoredKlinesRange(symbol string, timeframe string, start, end time.Time) ([]Kline, error) {
+	s := GetKlineStore()
+	if s == nil {
+		return nil, fmt.Errorf("no kline store installed")
+	}
+	return s.Load(klineStoreExchange, Normalize(symbol), timeframe, start, end)
+}
+
+// fetchKlinesRange fetches K-lines within [start, end] from Binance futures
+func fetchKlinesRange(symbol string, timeframe string, start, end time.Time) ([]Kline, error) {
 	symbol = Normalize(symbol)
 	normTF, err := NormalizeTimeframe(timeframe)
 	if err != nil {
@@ -0,0 +1,551 @@
+package market
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/logger"
+)
+
+// klineStoreExchange is the exchange key used for candles fetched by GetKlinesRange (Binance futures)
+const klineStoreExchange = "binance"
+
+var klineCSVHeader = []string{
+	"open_time", "open", "high", "low", "close", "volume", "close_time",
+	"quote_volume", "trades", "taker_buy_base_volume", "taker_buy_quote_volume",
+}
+
+// KlineStore is a local cache of closed klines keyed by exchange/symbol/timeframe.
+// Series are kept as monthly CSV partitions in the Binance dump column order:
+//
+//	<dir>/<exchange>/<SYMBOL>/<timeframe>/<YYYY-MM>.csv
+type KlineStore struct {
+	dir     string
+	offline bool
+	mu      sync.Mutex
+}
+
+// KlineGap is a missing stretch of candles, [Start, End)
+type KlineGap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+var (
+	klineStoreMu sync.RWMutex
+	klineStore   *KlineStore
+)
+
+// NewKlineStore creates a store rooted at dir. An offline store never fetches missing candles.
+func NewKlineStore(dir string, offline bool) *KlineStore {
+	return &KlineStore{dir: dir, offline: offline}
+}
+
+// SetKlineStore installs the store used by GetKlinesRange and GetWithTimeframes (nil disables caching)
+func SetKlineStore(s *KlineStore) {
+	klineStoreMu.Lock()
+	defer klineStoreMu.Unlock()
+	klineStore = s
+}
+
+// GetKlineStore returns the installed store, or nil
+func GetKlineStore() *KlineStore {
+	klineStoreMu.RLock()
+	defer klineStoreMu.RUnlock()
+	return klineStore
+}
+
+// Dir returns the store root directory
+func (s *KlineStore) Dir() string {
+	return s.dir
+}
+
+// Offline reports whether the store serves cached candles only
+func (s *KlineStore) Offline() bool {
+	return s.offline
+}
+
+// Load returns stored klines with OpenTime in [start, end], sorted by time
+func (s *KlineStore) Load(exchange, symbol, timeframe string, start, end time.Time) ([]Kline, error) {
+	dir, _, err := s.seriesDir(exchange, symbol, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Kline
+	for month := monthStart(start); !month.After(end); month = month.AddDate(0, 1, 0) {
+		klines, err := readKlineFile(partitionPath(dir, month))
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range klines {
+			if k.OpenTime >= startMs && k.OpenTime <= endMs {
+				result = append(result, k)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Recent returns up to limit of the latest stored klines
+func (s *KlineStore) Recent(exchange, symbol, timeframe string, limit int) ([]Kline, error) {
+	dir, _, err := s.seriesDir(exchange, symbol, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var result []Kline
+	for i := len(files) - 1; i >= 0 && len(result) < limit; i-- {
+		klines, err := readKlineFile(files[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(klines, result...)
+	}
+	if len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+	return result, nil
+}
+
+// Save merges klines into the store, replacing candles with the same OpenTime.
+// Candles that have not closed yet are skipped. Returns the number of new candles.
+func (s *KlineStore) Save(exchange, symbol, timeframe string, klines []Kline) (int, error) {
+	dir, dur, err := s.seriesDir(exchange, symbol, timeframe)
+	if err != nil {
+		return 0, err
+	}
+	nowMs := time.Now().UnixMilli()
+	byMonth := make(map[time.Time][]Kline)
+	for _, k := range klines {
+		if k.OpenTime <= 0 {
+			continue
+		}
+		if k.CloseTime <= 0 {
+			k.CloseTime = k.OpenTime + dur.Milliseconds() - 1
+		}
+		if k.CloseTime >= nowMs {
+			continue
+		}
+		month := monthStart(time.UnixMilli(k.OpenTime))
+		byMonth[month] = append(byMonth[month], k)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create kline store dir: %w", err)
+	}
+	added := 0
+	for month, batch := range byMonth {
+		n, err := mergeKlineFile(partitionPath(dir, month), batch)
+		if err != nil {
+			return added, err
+		}
+		added += n
+	}
+	return added, nil
+}
+
+// Gaps lists stretches of at least one candle missing from the store within [start, end].
+// The part of the range that has not closed yet is ignored.
+func (s *KlineStore) Gaps(exchange, symbol, timeframe string, start, end time.Time) ([]KlineGap, error) {
+	_, dur, err := s.seriesDir(exchange, symbol, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	if !end.After(start) {
+		return nil, nil
+	}
+	klines, err := s.Load(exchange, symbol, timeframe, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var gaps []KlineGap
+	cursor := start.UnixMilli()
+	step := dur.Milliseconds()
+	for _, k := range klines {
+		if k.OpenTime-cursor >= step {
+			gaps = append(gaps, KlineGap{Start: time.UnixMilli(cursor), End: time.UnixMilli(k.OpenTime)})
+		}
+		cursor = k.OpenTime + step
+	}
+	if end.UnixMilli()-cursor >= step {
+		gaps = append(gaps, KlineGap{Start: time.UnixMilli(cursor), End: end})
+	}
+	return gaps, nil
+}
+
+// Backfill fetches the store's gaps in [start, end] from Binance futures.
+// Returns the number of candles added.
+func (s *KlineStore) Backfill(symbol, timeframe string, start, end time.Time) (int, error) {
+	if s.offline {
+		return 0, fmt.Errorf("kline store is offline")
+	}
+	gaps, err := s.Gaps(klineStoreExchange, symbol, timeframe, start, end)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, gap := range gaps {
+		klines, err := fetchKlinesRange(symbol, timeframe, gap.Start, gap.End.Add(-time.Millisecond))
+		if err != nil {
+			return added, err
+		}
+		n, err := s.Save(klineStoreExchange, symbol, timeframe, klines)
+		added += n
+		if err != nil {
+			return added, err
+		}
+	}
+	return added, nil
+}
+
+// ImportCSV loads a CSV dump (Binance kline dump columns, optional header row) into the store.
+// Timestamps may be in milliseconds or microseconds. Returns the number of new candles.
+func (s *KlineStore) ImportCSV(exchange, symbol, timeframe string, r io.Reader) (int, error) {
+	klines, err := parseKlineCSV(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(klines) == 0 {
+		return 0, fmt.Errorf("no klines found in CSV")
+	}
+	return s.Save(exchange, symbol, timeframe, klines)
+}
+
+// rangeWithBackfill serves GetKlinesRange from the store, fetching only the missing stretches.
+// Stored candles are returned when the network fails, even if some gaps remain.
+func (s *KlineStore) rangeWithBackfill(symbol, timeframe string, start, end time.Time) ([]Kline, error) {
+	gaps, err := s.Gaps(klineStoreExchange, symbol, timeframe, start, end)
+	if err != nil {
+		logger.Warnf("⚠️ Kline store unavailable for %s %s, fetching directly: %v", symbol, timeframe, err)
+		return fetchKlinesRange(symbol, timeframe, start, end)
+	}
+
+	var fetchErr error
+	if !s.offline {
+		for _, gap := range gaps {
+			klines, err := fetchKlinesRange(symbol, timeframe, gap.Start, gap.End.Add(-time.Millisecond))
+			if err != nil {
+				fetchErr = err
+				break
+			}
+			if _, err := s.Save(klineStoreExchange, symbol, timeframe, klines); err != nil {
+				logger.Warnf("⚠️ Failed to cache %s %s klines: %v", symbol, timeframe, err)
+			}
+		}
+	}
+
+	klines, err := s.Load(klineStoreExchange, symbol, timeframe, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(klines) == 0 {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		if s.offline {
+			return nil, fmt.Errorf("no cached klines for %s %s (kline store is offline)", symbol, timeframe)
+		}
+	}
+	if len(gaps) > 0 && (s.offline || fetchErr != nil) {
+		logger.Warnf("⚠️ Serving %s %s from kline store with %d gap(s) (fetch error: %v)", symbol, timeframe, len(gaps), fetchErr)
+	}
+	return klines, nil
+}
+
+// seriesDir validates the key and returns the series directory and candle duration
+func (s *KlineStore) seriesDir(exchange, symbol, timeframe string) (string, time.Duration, error) {
+	if s == nil || s.dir == "" {
+		return "", 0, fmt.Errorf("kline store is not configured")
+	}
+	tf, err := NormalizeTimeframe(timeframe)
+	if err != nil {
+		return "", 0, err
+	}
+	exchange = strings.ToLower(strings.TrimSpace(exchange))
+	if exchange == "" {
+		exchange = klineStoreExchange
+	}
+	symbol = Normalize(symbol)
+	for _, part := range []string{exchange, symbol} {
+		if part == "" || strings.ContainsAny(part, `/\`) || strings.Contains(part, "..") {
+			return "", 0, fmt.Errorf("invalid kline store key %q", part)
+		}
+	}
+	symbol = strings.ReplaceAll(symbol, ":", "_")
+	return filepath.Join(s.dir, exchange, symbol, tf), supportedTimeframes[tf], nil
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func partitionPath(dir string, month time.Time) string {
+	return filepath.Join(dir, month.Format("2006-01")+".csv")
+}
+
+func readKlineFile(path string) ([]Kline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	klines, err := parseKlineCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return klines, nil
+}
+
+// mergeKlineFile merges batch into a partition file, appending when every new candle
+// comes after the stored ones and rewriting the file otherwise.
+func mergeKlineFile(path string, batch []Kline) (int, error) {
+	existing, err := readKlineFile(path)
+	if err != nil {
+		return 0, err
+	}
+	byOpen := make(map[int64]int, len(existing))
+	for i, k := range existing {
+		byOpen[k.OpenTime] = i
+	}
+	var lastOpen int64
+	if len(existing) > 0 {
+		lastOpen = existing[len(existing)-1].OpenTime
+	}
+
+	var fresh []Kline
+	changed := false
+	for _, k := range batch {
+		if i, ok := byOpen[k.OpenTime]; ok {
+			if existing[i] != k {
+				existing[i] = k
+				changed = true
+			}
+			continue
+		}
+		byOpen[k.OpenTime] = -1
+		fresh = append(fresh, k)
+	}
+	if len(fresh) == 0 && !changed {
+		return 0, nil
+	}
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].OpenTime < fresh[j].OpenTime })
+
+	if !changed && len(existing) > 0 && fresh[0].OpenTime > lastOpen {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return 0, err
+		}
+		if err := writeKlineRows(f, fresh, false); err != nil {
+			f.Close()
+			return 0, err
+		}
+		return len(fresh), f.Close()
+	}
+
+	merged := append(existing, fresh...)
+	sort.Slice(merged, func(i, j int) bool { return merged[i].OpenTime < merged[j].OpenTime })
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeKlineRows(f, merged, true); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, err
+	}
+	return len(fresh), nil
+}
+
+func writeKlineRows(w io.Writer, klines []Kline, header bool) error {
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	if header {
+		if err := cw.Write(klineCSVHeader); err != nil {
+			return err
+		}
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, k := range klines {
+		row := []string{
+			strconv.FormatInt(k.OpenTime, 10), f(k.Open), f(k.High), f(k.Low), f(k.Close), f(k.Volume),
+			strconv.FormatInt(k.CloseTime, 10), f(k.QuoteVolume), strconv.Itoa(k.Trades),
+			f(k.TakerBuyBaseVolume), f(k.TakerBuyQuoteVolume),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// parseKlineCSV reads rows of open_time, open, high, low, close, volume[, close_time, quote_volume,
+// trades, taker_buy_base_volume, taker_buy_quote_volume, ...]. Non-numeric leading rows are treated as headers.
+func parseKlineCSV(r io.Reader) ([]Kline, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	var klines []Kline
+	line := 0
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line++
+		if len(rec) == 0 || (len(rec) == 1 && strings.TrimSpace(rec[0]) == "") {
+			continue
+		}
+		if len(rec) < 6 {
+			return nil, fmt.Errorf("line %d: expected at least 6 columns, got %d", line, len(rec))
+		}
+		openTime, err := strconv.ParseInt(strings.TrimSpace(rec[0]), 10, 64)
+		if err != nil {
+			if len(klines) == 0 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: invalid open_time %q", line, rec[0])
+		}
+
+		var values [5]float64
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(strings.TrimSpace(rec[i+1]), 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s %q", line, klineCSVHeader[i+1], rec[i+1])
+			}
+		}
+		k := Kline{
+			OpenTime: normalizeMillis(openTime),
+			Open:     values[0],
+			High:     values[1],
+			Low:      values[2],
+			Close:    values[3],
+			Volume:   values[4],
+		}
+		optional := func(i int) string {
+			if i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		if v, err := strconv.ParseInt(optional(6), 10, 64); err == nil {
+			k.CloseTime = normalizeMillis(v)
+		}
+		k.QuoteVolume, _ = strconv.ParseFloat(optional(7), 64)
+		k.Trades, _ = strconv.Atoi(optional(8))
+		k.TakerBuyBaseVolume, _ = strconv.ParseFloat(optional(9), 64)
+		k.TakerBuyQuoteVolume, _ = strconv.ParseFloat(optional(10), 64)
+		klines = append(klines, k)
+	}
+	return klines, nil
+}
+
+// normalizeMillis converts microsecond timestamps (newer Binance dumps) to milliseconds
+func normalizeMillis(ts int64) int64 {
+	if ts > 1e14 {
+		return ts / 1000
+	}
+	return ts
+}
+
+var (
+	liveKlineSaveMu sync.Mutex
+	liveKlineSaved  = make(map[string]time.Time)
+)
+
+// cacheLiveKlines writes live klines to the store at most once per candle per series,
+// so GetWithTimeframes can fall back to them when the provider is down.
+func cacheLiveKlines(exchange, symbol, timeframe string, klines []Kline) {
+	s := GetKlineStore()
+	if s == nil || len(klines) == 0 {
+		return
+	}
+	dur, err := TFDuration(timeframe)
+	if err != nil {
+		return
+	}
+	key := exchange + "/" + symbol + "/" + timeframe
+	liveKlineSaveMu.Lock()
+	if time.Since(liveKlineSaved[key]) < dur {
+		liveKlineSaveMu.Unlock()
+		return
+	}
+	liveKlineSaved[key] = time.Now()
+	liveKlineSaveMu.Unlock()
+
+	batch := append([]Kline(nil), klines...)
+	go func() {
+		if _, err := s.Save(exchange, symbol, timeframe, batch); err != nil {
+			logger.Warnf("⚠️ Failed to cache %s %s klines: %v", symbol, timeframe, err)
+		}
+	}()
+}
+
+// cachedRecentKlines returns stored klines when the latest one closed within the last
+// three candles; older data is not a safe stand-in for live prices.
+func cachedRecentKlines(exchange, symbol, timeframe string, limit int) []Kline {
+	s := GetKlineStore()
+	if s == nil {
+		return nil
+	}
+	dur, err := TFDuration(timeframe)
+	if err != nil {
+		return nil
+	}
+	klines, err := s.Recent(exchange, symbol, timeframe, limit)
+	if err != nil || len(klines) == 0 {
+		return nil
+	}
+	if time.Since(time.UnixMilli(klines[len(klines)-1].CloseTime)) > 3*dur {
+		return nil
+	}
+	return klines
+}
@@ -0,0 +1,154 @@
+package market
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func hourlyKlines(start time.Time, count int) []Kline {
+	klines := make([]Kline, count)
+	for i := range klines {
+		open := start.Add(time.Duration(i) * time.Hour).UnixMilli()
+		klines[i] = Kline{
+			OpenTime:  open,
+			Open:      100 + float64(i),
+			High:      101 + float64(i),
+			Low:       99 + float64(i),
+			Close:     100.5 + float64(i),
+			Volume:    10,
+			CloseTime: open + time.Hour.Milliseconds() - 1,
+		}
+	}
+	return klines
+}
+
+func TestKlineStore_SaveLoad(t *testing.T) {
+	s := NewKlineStore(t.TempDir(), false)
+	// Spans a month boundary so two partitions are written
+	start := time.Date(2024, 1, 31, 20, 0, 0, 0, time.UTC)
+	klines := hourlyKlines(start, 10)
+
+	added, err := s.Save("binance", "btcusdt", "1h", klines[:6])
+	if err != nil || added != 6 {
+		t.Fatalf("Save: added=%d err=%v", added, err)
+	}
+	// Overlapping save only adds the new candles (append path)
+	added, err = s.Save("binance", "BTCUSDT", "1h", klines[4:])
+	if err != nil || added != 4 {
+		t.Fatalf("Save overlap: added=%d err=%v", added, err)
+	}
+	// Out-of-order rewrite keeps the series sorted
+	changed := klines[2]
+	changed.Close = 42
+	if _, err := s.Save("binance", "BTCUSDT", "1h", []Kline{changed}); err != nil {
+		t.Fatalf("Save rewrite: %v", err)
+	}
+
+	got, err := s.Load("binance", "BTCUSDT", "1h", start, start.Add(9*time.Hour))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 10 {
+		t.Fatalf("Expected 10 klines, got %d", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].OpenTime <= got[i-1].OpenTime {
+			t.Fatalf("Klines not sorted at %d", i)
+		}
+	}
+	if got[2].Close != 42 {
+		t.Errorf("Expected replaced close 42, got %.2f", got[2].Close)
+	}
+
+	recent, err := s.Recent("binance", "BTCUSDT", "1h", 3)
+	if err != nil || len(recent) != 3 || recent[2].OpenTime != klines[9].OpenTime {
+		t.Errorf("Recent: got %d klines, err=%v", len(recent), err)
+	}
+}
+
+func TestKlineStore_SkipsOpenCandle(t *testing.T) {
+	s := NewKlineStore(t.TempDir(), false)
+	current := time.Now().Truncate(time.Hour)
+	added, err := s.Save("binance", "BTCUSDT", "1h", hourlyKlines(current.Add(-2*time.Hour), 3))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected only the 2 closed candles to be stored, got %d", added)
+	}
+}
+
+func TestKlineStore_Gaps(t *testing.T) {
+	s := NewKlineStore(t.TempDir(), false)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	klines := hourlyKlines(start, 10)
+	// Drop hours 3-4 and leave hours 10-11 uncovered
+	stored := append(append([]Kline{}, klines[:3]...), klines[5:]...)
+	if _, err := s.Save("binance", "BTCUSDT", "1h", stored); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	gaps, err := s.Gaps("binance", "BTCUSDT", "1h", start, start.Add(12*time.Hour))
+	if err != nil {
+		t.Fatalf("Gaps: %v", err)
+	}
+	want := []KlineGap{
+		{Start: start.Add(3 * time.Hour), End: start.Add(5 * time.Hour)},
+		{Start: start.Add(10 * time.Hour), End: start.Add(12 * time.Hour)},
+	}
+	if len(gaps) != len(want) {
+		t.Fatalf("Expected %d gaps, got %+v", len(want), gaps)
+	}
+	for i := range want {
+		if !gaps[i].Start.Equal(want[i].Start) || !gaps[i].End.Equal(want[i].End) {
+			t.Errorf("Gap %d: expected %v-%v, got %v-%v", i, want[i].Start, want[i].End, gaps[i].Start, gaps[i].End)
+		}
+	}
+
+	gaps, err = s.Gaps("binance", "BTCUSDT", "1h", start, start.Add(3*time.Hour-time.Millisecond))
+	if err != nil || len(gaps) != 0 {
+		t.Errorf("Expected no gaps in a fully stored range, got %+v (err=%v)", gaps, err)
+	}
+}
+
+func TestKlineStore_ImportCSV(t *testing.T) {
+	s := NewKlineStore(t.TempDir(), false)
+	// Binance dump with header; the second row uses microsecond timestamps
+	csvData := strings.Join([]string{
+		"open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore",
+		"1704067200000,42000.1,42100,41900,42050,12.5,1704070799999,525000,1200,6.1,256000,0",
+		"1704070800000000,42050,42200,42000,42150,8.25,1704074399999999,347000,900,4,168000,0",
+		"1704074400000,42150,42160,42100,42120,3",
+	}, "\n")
+
+	added, err := s.ImportCSV("binance", "BTCUSDT", "1h", strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("ImportCSV: %v", err)
+	}
+	if added != 3 {
+		t.Fatalf("Expected 3 imported klines, got %d", added)
+	}
+
+	start := time.UnixMilli(1704067200000)
+	got, err := s.Load("binance", "BTCUSDT", "1h", start, start.Add(3*time.Hour))
+	if err != nil || len(got) != 3 {
+		t.Fatalf("Load: got %d klines, err=%v", len(got), err)
+	}
+	if got[1].OpenTime != 1704070800000 || got[1].CloseTime != 1704074399999 {
+		t.Errorf("Microsecond timestamps not converted: %d-%d", got[1].OpenTime, got[1].CloseTime)
+	}
+	if got[0].Trades != 1200 || got[0].QuoteVolume != 525000 {
+		t.Errorf("Optional columns not parsed: %+v", got[0])
+	}
+	if got[2].CloseTime != 1704077999999 {
+		t.Errorf("Expected derived close time 1704077999999, got %d", got[2].CloseTime)
+	}
+
+	if _, err := s.ImportCSV("binance", "BTCUSDT", "1h", strings.NewReader("1704067200000,1,2\n")); err == nil {
+		t.Error("Expected error for short rows")
+	}
+	if _, err := s.ImportCSV("binance", "../etc", "1h", strings.NewReader(csvData)); err == nil {
+		t.Error("Expected error for path traversal in symbol")
+	}
+}
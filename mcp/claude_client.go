@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -122,16 +123,7 @@ func (c *ClaudeClient) parseMCPResponse(body []byte) (string, error) {
 	}
 
 	// Report token usage if callback is set
-	totalTokens := response.Usage.InputTokens + response.Usage.OutputTokens
-	if TokenUsageCallback != nil && totalTokens > 0 {
-		TokenUsageCallback(TokenUsage{
-			Provider:         c.Provider,
-			Model:            c.Model,
-			PromptTokens:     response.Usage.InputTokens,
-			CompletionTokens: response.Usage.OutputTokens,
-			TotalTokens:      totalTokens,
-		})
-	}
+	c.reportTokenUsage(response.Usage.InputTokens, response.Usage.OutputTokens, response.Usage.InputTokens+response.Usage.OutputTokens)
 
 	// Find text content
 	for _, content := range response.Content {
@@ -142,3 +134,231 @@ func (c *ClaudeClient) parseMCPResponse(body []byte) (string, error) {
 
 	return "", fmt.Errorf("no text content in Claude response")
 }
+
+// buildToolRequestBody Claude uses tool_use / tool_result content blocks and a top-level system prompt
+func (c *ClaudeClient) buildToolRequestBody(req *Request) map[string]any {
+	var system []string
+	messages := make([]map[string]any, 0, len(req.Messages))
+	var toolResults []map[string]any
+
+	flushToolResults := func() {
+		if len(toolResults) > 0 {
+			messages = append(messages, map[string]any{"role": "user", "content": toolResults})
+			toolResults = nil
+		}
+	}
+
+	for _, msg := range req.Messages {
+		switch {
+		case msg.Role == "system":
+			system = append(system, msg.Content)
+		case msg.Role == "tool":
+			// Consecutive tool results are sent back in a single user message
+			toolResults = append(toolResults, map[string]any{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     msg.Content,
+			})
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			flushToolResults()
+			blocks := make([]map[string]any, 0, len(msg.ToolCalls)+1)
+			if msg.Content != "" {
+				blocks = append(blocks, map[string]any{"type": "text", "text": msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, map[string]any{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Function.Name,
+					"input": json.RawMessage(rawArguments(json.RawMessage(call.Function.Arguments))),
+				})
+			}
+			messages = append(messages, map[string]any{"role": "assistant", "content": blocks})
+		default:
+			flushToolResults()
+			messages = append(messages, map[string]any{"role": msg.Role, "content": msg.Content})
+		}
+	}
+	flushToolResults()
+
+	maxTokens := c.MaxTokens
+	if req.MaxTokens != nil {
+		maxTokens = *req.MaxTokens
+	}
+	requestBody := map[string]any{
+		"model":      req.Model,
+		"max_tokens": maxTokens,
+		"messages":   messages,
+	}
+	if len(system) > 0 {
+		requestBody["system"] = strings.Join(system, "\n\n")
+	}
+	if req.Temperature != nil {
+		requestBody["temperature"] = *req.Temperature
+	}
+
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			tools = append(tools, map[string]any{
+				"name":         tool.Function.Name,
+				"description":  tool.Function.Description,
+				"input_schema": tool.Function.Parameters,
+			})
+		}
+		requestBody["tools"] = tools
+	}
+	switch req.ToolChoice {
+	case "auto":
+		requestBody["tool_choice"] = map[string]any{"type": "auto"}
+	case "none":
+		requestBody["tool_choice"] = map[string]any{"type": "none"}
+	case "required":
+		requestBody["tool_choice"] = map[string]any{"type": "any"}
+	}
+
+	return requestBody
+}
+
+// parseToolResponse extracts text and tool_use blocks from a Claude response
+func (c *ClaudeClient) parseToolResponse(body []byte) (*toolResponse, error) {
+	var response struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse Claude response: %w, body: %s", err, string(body))
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("Claude API error: %s - %s", response.Error.Type, response.Error.Message)
+	}
+	if len(response.Content) == 0 {
+		return nil, fmt.Errorf("Claude returned empty content, body: %s", string(body))
+	}
+
+	c.reportTokenUsage(response.Usage.InputTokens, response.Usage.OutputTokens, response.Usage.InputTokens+response.Usage.OutputTokens)
+
+	parsed := &toolResponse{}
+	var text []string
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			parsed.ToolCalls = append(parsed.ToolCalls, ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: ToolCallFunction{
+					Name:      block.Name,
+					Arguments: rawArguments(block.Input),
+				},
+			})
+		}
+	}
+	parsed.Content = strings.Join(text, "\n")
+	return parsed, nil
+}
+
+// structuredOutputMode Claude has no response_format; a forced tool call carries the answer
+func (c *ClaudeClient) structuredOutputMode() string {
+	return StructuredOutputTool
+}
+
+// buildStructuredRequestBody forces a single tool whose input schema is the response schema
+func (c *ClaudeClient) buildStructuredRequestBody(req *Request) map[string]any {
+	format := req.ResponseFormat.JSONSchema
+	conv := *req
+	conv.ResponseFormat = nil
+	conv.Tools = []Tool{{
+		Type: "function",
+		Function: FunctionDef{
+			Name:        format.Name,
+			Description: format.Description,
+			Parameters:  format.Schema,
+		},
+	}}
+	conv.ToolChoice = ""
+
+	requestBody := c.buildToolRequestBody(&conv)
+	requestBody["tool_choice"] = map[string]any{"type": "tool", "name": format.Name}
+	return requestBody
+}
+
+// parseStructuredResponse returns the forced tool call's input as the JSON answer
+func (c *ClaudeClient) parseStructuredResponse(body []byte) (string, error) {
+	parsed, err := c.parseToolResponse(body)
+	if err != nil {
+		return "", err
+	}
+	if len(parsed.ToolCalls) == 0 {
+		return "", fmt.Errorf("no structured output in Claude response")
+	}
+	return parsed.ToolCalls[0].Function.Arguments, nil
+}
+
+// buildStreamRequestBody Claude messages request with stream enabled
+func (c *ClaudeClient) buildStreamRequestBody(req *Request) map[string]any {
+	requestBody := c.buildToolRequestBody(req)
+	requestBody["stream"] = true
+	return requestBody
+}
+
+// parseStreamEvent parses Claude stream events (message_start, content_block_delta, message_delta, ...)
+func (c *ClaudeClient) parseStreamEvent(data []byte) (*streamDelta, error) {
+	var event struct {
+		Type  string `json:"type"`
+		Delta struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta"`
+		Message struct {
+			Usage struct {
+				InputTokens int `json:"input_tokens"`
+			} `json:"usage"`
+		} `json:"message"`
+		Usage struct {
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		Error *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse Claude stream event: %w", err)
+	}
+
+	delta := &streamDelta{}
+	switch event.Type {
+	case "error":
+		if event.Error != nil {
+			return nil, fmt.Errorf("Claude API error: %s - %s", event.Error.Type, event.Error.Message)
+		}
+		return nil, fmt.Errorf("Claude API stream error")
+	case "message_start":
+		delta.PromptTokens = event.Message.Usage.InputTokens
+	case "content_block_delta":
+		if event.Delta.Type == "text_delta" {
+			delta.Text = event.Delta.Text
+		}
+	case "message_delta":
+		delta.CompletionTokens = event.Usage.OutputTokens
+	case "message_stop":
+		delta.Done = true
+	}
+	return delta, nil
+}
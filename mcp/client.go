@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -43,6 +44,7 @@ type TokenUsage struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+	Attribution      UsageAttribution // Who the call is billed to (zero value if not attributed)
 }
 
 // Client AI API configuration
@@ -62,6 +64,8 @@ type Client struct {
 	// When DeepSeekClient embeds Client, hooks point to DeepSeekClient
 	// This way methods called in call() are automatically dispatched to the overridden version in subclass
 	hooks clientHooks
+
+	attribution UsageAttribution // Attached to every TokenUsage reported by this client
 }
 
 // New creates default client (backward compatible)
@@ -254,15 +258,7 @@ func (client *Client) parseMCPResponse(body []byte) (string, error) {
 	}
 
 	// Report token usage if callback is set
-	if TokenUsageCallback != nil && result.Usage.TotalTokens > 0 {
-		TokenUsageCallback(TokenUsage{
-			Provider:         client.Provider,
-			Model:            client.Model,
-			PromptTokens:     result.Usage.PromptTokens,
-			CompletionTokens: result.Usage.CompletionTokens,
-			TotalTokens:      result.Usage.TotalTokens,
-		})
-	}
+	client.reportTokenUsage(result.Usage.PromptTokens, result.Usage.CompletionTokens, result.Usage.TotalTokens)
 
 	return result.Choices[0].Message.Content, nil
 }
@@ -477,15 +473,50 @@ func (client *Client) callWithRequest(req *Request) (string, error) {
 	return result, nil
 }
 
+// post sends a request body to the provider endpoint and returns the raw response body
+func (client *Client) post(ctx context.Context, requestBody map[string]any) ([]byte, error) {
+	jsonData, err := client.hooks.marshalRequestBody(requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := client.hooks.buildRequest(client.hooks.buildUrl(), jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.httpClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
 // buildRequestBodyFromRequest builds request body from Request object
 func (client *Client) buildRequestBodyFromRequest(req *Request) map[string]any {
 	// Convert Message to API format
-	messages := make([]map[string]string, 0, len(req.Messages))
+	messages := make([]map[string]any, 0, len(req.Messages))
 	for _, msg := range req.Messages {
-		messages = append(messages, map[string]string{
+		message := map[string]any{
 			"role":    msg.Role,
 			"content": msg.Content,
-		})
+		}
+		if len(msg.ToolCalls) > 0 {
+			message["tool_calls"] = msg.ToolCalls
+		}
+		if msg.ToolCallID != "" {
+			message["tool_call_id"] = msg.ToolCallID
+		}
+		messages = append(messages, message)
 	}
 
 	// Build basic request body
@@ -538,6 +569,10 @@ func (client *Client) buildRequestBodyFromRequest(req *Request) map[string]any {
 		requestBody["tool_choice"] = req.ToolChoice
 	}
 
+	if req.ResponseFormat != nil {
+		requestBody["response_format"] = req.ResponseFormat
+	}
+
 	if req.Stream {
 		requestBody["stream"] = true
 	}
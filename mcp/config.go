@@ -30,6 +30,9 @@ type Config struct {
 	// Timeout configuration
 	Timeout time.Duration
 
+	// ContextWindow model context window in tokens (0 = unlimited, set for local models)
+	ContextWindow int
+
 	// Dependency injection
 	Logger     Logger
 	HTTPClient *http.Client
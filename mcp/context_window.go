@@ -0,0 +1,118 @@
+package mcp
+
+import "strings"
+
+// promptBudgetMargin tokens kept free for chat template overhead and estimation error
+const promptBudgetMargin = 256
+
+// PromptBudgeter is implemented by clients that know their model's context window.
+// UserPromptBudget returns the estimated tokens left for the user prompt next to
+// systemPrompt (<= 0: no known limit). All built-in clients implement it.
+type PromptBudgeter interface {
+	UserPromptBudget(systemPrompt string) int
+}
+
+// providerContextWindows context window of each provider's default model
+var providerContextWindows = map[string]int{
+	ProviderDeepSeek: 128000,
+	ProviderQwen:     262144,
+	ProviderOpenAI:   400000,
+	ProviderClaude:   200000,
+	ProviderGemini:   1048576,
+	ProviderGrok:     131072,
+	ProviderKimi:     131072,
+	ProviderLocal:    DefaultLocalContextWindow,
+}
+
+// modelContextWindows context window by model name prefix (longest match wins)
+var modelContextWindows = map[string]int{
+	"gpt-5":            400000,
+	"gpt-4.1":          1047576,
+	"gpt-4o":           128000,
+	"o3":               200000,
+	"o4-mini":          200000,
+	"claude":           200000,
+	"gemini":           1048576,
+	"grok-4":           256000,
+	"grok-3":           131072,
+	"deepseek":         128000,
+	"qwen3-max":        262144,
+	"qwen-max":         32768,
+	"qwen-plus":        131072,
+	"moonshot-v1-8k":   8192,
+	"moonshot-v1-32k":  32768,
+	"moonshot-v1-128k": 131072,
+	"kimi-k2":          262144,
+}
+
+// ContextWindowFor returns the context window in tokens of a provider's model, 0 if unknown
+// (custom endpoints). Models are matched by name prefix, falling back to the provider default.
+func ContextWindowFor(provider, model string) int {
+	model = strings.ToLower(model)
+	best, window := 0, 0
+	for prefix, tokens := range modelContextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, window = len(prefix), tokens
+		}
+	}
+	if window > 0 {
+		return window
+	}
+	return providerContextWindows[provider]
+}
+
+// PromptBudget tokens left for the user prompt in a context window after the system prompt,
+// the response (maxTokens) and a safety margin; 0 if the window is unknown
+func PromptBudget(contextWindow, maxTokens int, systemPrompt string) int {
+	if contextWindow <= 0 {
+		return 0
+	}
+	budget := contextWindow - maxTokens - EstimateTokens(systemPrompt) - promptBudgetMargin
+	if budget < 1 {
+		budget = 1 // Context too small for the system prompt; keep the user prompt minimal rather than unlimited
+	}
+	return budget
+}
+
+// ContextWindow returns the model context window in tokens: the configured value
+// (WithContextWindow), else the known window of the model, else 0
+func (client *Client) ContextWindow() int {
+	if client.config.ContextWindow > 0 {
+		return client.config.ContextWindow
+	}
+	return ContextWindowFor(client.Provider, client.Model)
+}
+
+// UserPromptBudget tokens left for the user prompt after the system prompt and the response
+func (client *Client) UserPromptBudget(systemPrompt string) int {
+	return PromptBudget(client.ContextWindow(), client.MaxTokens, systemPrompt)
+}
+
+// UserPromptBudget smallest budget of the chain, since any member may answer
+func (f *FallbackClient) UserPromptBudget(systemPrompt string) int {
+	budget := 0
+	for _, m := range f.members {
+		b, ok := m.client.(PromptBudgeter)
+		if !ok {
+			continue
+		}
+		if mb := b.UserPromptBudget(systemPrompt); mb > 0 && (budget == 0 || mb < budget) {
+			budget = mb
+		}
+	}
+	return budget
+}
+
+// EstimateTokens rough token count of text: ~4 ASCII characters per token, one token per other rune
+// (CJK text). Deliberately conservative; good enough to stay inside a context window.
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < 128 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
@@ -0,0 +1,50 @@
+package mcp
+
+import "testing"
+
+func TestContextWindowFor(t *testing.T) {
+	cases := []struct {
+		provider, model string
+		want            int
+	}{
+		{ProviderKimi, "moonshot-v1-8k", 8192},
+		{ProviderKimi, "moonshot-v1-auto", 131072}, // Provider default
+		{ProviderOpenAI, "gpt-4o-mini", 128000},
+		{ProviderGrok, "grok-4-fast", 256000},
+		{ProviderLocal, "qwen2.5:14b", DefaultLocalContextWindow},
+		{ProviderCustom, "my-finetune", 0},
+	}
+	for _, tc := range cases {
+		if got := ContextWindowFor(tc.provider, tc.model); got != tc.want {
+			t.Errorf("ContextWindowFor(%s, %s) = %d, want %d", tc.provider, tc.model, got, tc.want)
+		}
+	}
+}
+
+func TestUserPromptBudget(t *testing.T) {
+	client := NewKimiClientWithOptions(WithLogger(NewMockLogger()), WithModel("moonshot-v1-8k"), WithMaxTokens(2000)).(*KimiClient)
+	system := "abcd"
+	if got, want := client.UserPromptBudget(system), 8192-2000-1-promptBudgetMargin; got != want {
+		t.Errorf("budget = %d, want %d", got, want)
+	}
+
+	custom := NewClient(WithLogger(NewMockLogger()))
+	custom.SetAPIKey("key", "https://llm.example.com/v1", "my-finetune")
+	if got := custom.(PromptBudgeter).UserPromptBudget(system); got != 0 {
+		t.Errorf("unknown model should have no budget, got %d", got)
+	}
+
+	fallback := NewFallbackClient(CircuitBreakerConfig{}, custom, client)
+	if got := fallback.UserPromptBudget(system); got != client.UserPromptBudget(system) {
+		t.Errorf("fallback budget should be the smallest known member budget, got %d", got)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens("abcdefgh"); got != 2 {
+		t.Errorf("8 ASCII chars = %d tokens, want 2", got)
+	}
+	if got := EstimateTokens("比特币"); got != 3 {
+		t.Errorf("3 CJK runes = %d tokens, want 3", got)
+	}
+}
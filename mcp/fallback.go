@@ -0,0 +1,191 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"nofx/logger"
+)
+
+const (
+	DefaultBreakerFailureThreshold = 3
+	DefaultBreakerCooldown         = 5 * time.Minute
+)
+
+// ErrAllProvidersUnavailable is returned when every client of a FallbackClient failed or is tripped
+var ErrAllProvidersUnavailable = errors.New("all AI providers unavailable")
+
+// CircuitBreakerConfig trips a provider after consecutive failures and retries it after a cooldown
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Consecutive failures before the breaker opens (default 3)
+	Cooldown         time.Duration // Time the breaker stays open before a trial call (default 5m)
+}
+
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = DefaultBreakerFailureThreshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = DefaultBreakerCooldown
+	}
+	return c
+}
+
+// CircuitBreaker per-provider breaker: closed -> open after FailureThreshold consecutive
+// failures -> half-open (one trial call) after Cooldown -> closed on success
+type CircuitBreaker struct {
+	config   CircuitBreakerConfig
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool // A half-open trial call is in flight
+	now      func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{config: config.withDefaults(), now: time.Now}
+}
+
+// Allow reports whether a call may go through; after the cooldown a single trial call is let through
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.config.FailureThreshold {
+		return true
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.config.Cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Success closes the breaker
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call and (re)opens the breaker once the threshold is reached
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.failures >= b.config.FailureThreshold {
+		b.openedAt = b.now()
+	}
+}
+
+// Open reports whether the breaker is currently rejecting calls
+func (b *CircuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.config.FailureThreshold
+}
+
+type fallbackMember struct {
+	name    string
+	client  AIClient
+	breaker *CircuitBreaker
+}
+
+// FallbackClient composite AIClient that tries clients in order, skipping providers whose
+// circuit breaker is open. Calls are plain text calls: the composite does not expose
+// structured output or tool calling of its members.
+type FallbackClient struct {
+	members []*fallbackMember
+	logger  Logger
+}
+
+// NewFallbackClient creates a composite client over clients in priority order (nil clients are skipped)
+func NewFallbackClient(breaker CircuitBreakerConfig, clients ...AIClient) *FallbackClient {
+	f := &FallbackClient{logger: logger.NewMCPLogger()}
+	for i, client := range clients {
+		if client == nil {
+			continue
+		}
+		name := fmt.Sprintf("client#%d", i+1)
+		if s, ok := client.(fmt.Stringer); ok {
+			name = s.String()
+		}
+		f.members = append(f.members, &fallbackMember{name: name, client: client, breaker: NewCircuitBreaker(breaker)})
+	}
+	return f
+}
+
+// SetLogger sets the logger used for failover messages
+func (f *FallbackClient) SetLogger(l Logger) {
+	f.logger = l
+}
+
+// Len returns the number of clients in the chain
+func (f *FallbackClient) Len() int {
+	return len(f.members)
+}
+
+// SetAPIKey configures the primary client only; backups keep their own credentials
+func (f *FallbackClient) SetAPIKey(apiKey string, customURL string, customModel string) {
+	if len(f.members) > 0 {
+		f.members[0].client.SetAPIKey(apiKey, customURL, customModel)
+	}
+}
+
+// SetTimeout sets the timeout of every client in the chain
+func (f *FallbackClient) SetTimeout(timeout time.Duration) {
+	for _, m := range f.members {
+		m.client.SetTimeout(timeout)
+	}
+}
+
+// SetUsageAttribution forwards attribution to every client in the chain
+func (f *FallbackClient) SetUsageAttribution(attribution UsageAttribution) {
+	for _, m := range f.members {
+		AttributeUsage(m.client, attribution)
+	}
+}
+
+// CallWithMessages calls the first available client, failing over on error
+func (f *FallbackClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return f.call(func(client AIClient) (string, error) {
+		return client.CallWithMessages(systemPrompt, userPrompt)
+	})
+}
+
+// CallWithRequest calls the first available client, failing over on error
+func (f *FallbackClient) CallWithRequest(req *Request) (string, error) {
+	return f.call(func(client AIClient) (string, error) {
+		return client.CallWithRequest(req)
+	})
+}
+
+func (f *FallbackClient) call(fn func(client AIClient) (string, error)) (string, error) {
+	var errs []error
+	for i, m := range f.members {
+		if !m.breaker.Allow() {
+			errs = append(errs, fmt.Errorf("%s: circuit open", m.name))
+			continue
+		}
+		result, err := fn(m.client)
+		if err == nil {
+			m.breaker.Success()
+			if i > 0 {
+				f.logger.Infof("🔀 [MCP] Fallback answered by %s", m.name)
+			}
+			return result, nil
+		}
+		m.breaker.Failure()
+		if m.breaker.Open() {
+			f.logger.Warnf("⚠️  [MCP] %s failed, circuit open for %v: %v", m.name, m.breaker.config.Cooldown, err)
+		} else {
+			f.logger.Warnf("⚠️  [MCP] %s failed, trying next provider: %v", m.name, err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+	}
+	return "", fmt.Errorf("%w: %w", ErrAllProvidersUnavailable, errors.Join(errs...))
+}
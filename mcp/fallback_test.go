@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubAIClient struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (s *stubAIClient) SetAPIKey(apiKey string, customURL string, customModel string) {}
+func (s *stubAIClient) SetTimeout(timeout time.Duration)                              {}
+func (s *stubAIClient) String() string                                                { return s.name }
+
+func (s *stubAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.name, nil
+}
+
+func (s *stubAIClient) CallWithRequest(req *Request) (string, error) {
+	return s.CallWithMessages("", "")
+}
+
+func TestFallbackClientFailsOverInOrder(t *testing.T) {
+	primary := &stubAIClient{name: "primary", err: errors.New("503")}
+	backup := &stubAIClient{name: "backup"}
+	f := NewFallbackClient(CircuitBreakerConfig{}, primary, nil, backup)
+	f.SetLogger(NewMockLogger())
+
+	if f.Len() != 2 {
+		t.Fatalf("nil clients should be skipped, got %d members", f.Len())
+	}
+	got, err := f.CallWithMessages("sys", "user")
+	if err != nil {
+		t.Fatalf("CallWithMessages: %v", err)
+	}
+	if got != "backup" || primary.calls != 1 || backup.calls != 1 {
+		t.Errorf("got %q, primary calls %d, backup calls %d", got, primary.calls, backup.calls)
+	}
+
+	backup.err = errors.New("timeout")
+	if _, err := f.CallWithMessages("sys", "user"); !errors.Is(err, ErrAllProvidersUnavailable) {
+		t.Errorf("expected ErrAllProvidersUnavailable, got %v", err)
+	}
+}
+
+func TestFallbackClientCircuitBreaker(t *testing.T) {
+	primary := &stubAIClient{name: "primary", err: errors.New("503")}
+	backup := &stubAIClient{name: "backup"}
+	f := NewFallbackClient(CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}, primary, backup)
+	f.SetLogger(NewMockLogger())
+
+	now := time.Now()
+	breaker := f.members[0].breaker
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := f.CallWithMessages("sys", "user"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if primary.calls != 2 || !breaker.Open() {
+		t.Fatalf("breaker should open after 2 failures, primary calls %d", primary.calls)
+	}
+
+	// After the cooldown a single trial call is let through; success closes the breaker
+	now = now.Add(time.Minute)
+	primary.err = nil
+	got, err := f.CallWithMessages("sys", "user")
+	if err != nil || got != "primary" {
+		t.Fatalf("trial call: got %q, %v", got, err)
+	}
+	if breaker.Open() {
+		t.Error("breaker should close after a successful trial")
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsOneTrial(t *testing.T) {
+	b := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Second})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("open breaker should reject calls during the cooldown")
+	}
+	now = now.Add(time.Second)
+	if !b.Allow() {
+		t.Fatal("breaker should allow a trial after the cooldown")
+	}
+	if b.Allow() {
+		t.Error("only one trial call may be in flight")
+	}
+	b.Failure()
+	if b.Allow() {
+		t.Error("failed trial should reopen the breaker")
+	}
+}
@@ -69,3 +69,60 @@ func (c *GeminiClient) SetAPIKey(apiKey string, customURL string, customModel st
 func (c *GeminiClient) setAuthHeader(reqHeaders http.Header) {
 	c.Client.setAuthHeader(reqHeaders)
 }
+
+// buildStructuredRequestBody Gemini maps response_format onto its responseSchema, which only
+// accepts an OpenAPI subset: nullable instead of type unions and no additionalProperties
+func (c *GeminiClient) buildStructuredRequestBody(req *Request) map[string]any {
+	format := *req.ResponseFormat.JSONSchema
+	format.Schema, _ = toGeminiSchema(format.Schema).(map[string]any)
+
+	conv := *req
+	conv.ResponseFormat = &ResponseFormat{Type: StructuredOutputJSONSchema, JSONSchema: &format}
+	return c.Client.buildStructuredRequestBody(&conv)
+}
+
+func toGeminiSchema(node any) any {
+	switch v := node.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			switch key {
+			case "additionalProperties", "$schema":
+				continue
+			case "type":
+				if types, ok := value.([]any); ok {
+					value = geminiType(types, out)
+				} else if types, ok := value.([]string); ok {
+					generic := make([]any, len(types))
+					for i, t := range types {
+						generic[i] = t
+					}
+					value = geminiType(generic, out)
+				}
+			}
+			out[key] = toGeminiSchema(value)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = toGeminiSchema(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// geminiType collapses ["number", "null"] to "number" with nullable set on the schema
+func geminiType(types []any, schema map[string]any) any {
+	var primary any
+	for _, t := range types {
+		if t == "null" {
+			schema["nullable"] = true
+		} else if primary == nil {
+			primary = t
+		}
+	}
+	return primary
+}
@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"net/http"
 	"time"
 )
@@ -13,6 +14,25 @@ type AIClient interface {
 	CallWithRequest(req *Request) (string, error) // Builder pattern API (supports advanced features)
 }
 
+// ToolCaller is implemented by clients that can run a multi-turn tool calling loop.
+// All built-in clients implement it; check with a type assertion on AIClient.
+type ToolCaller interface {
+	CallWithTools(ctx context.Context, req *Request, registry *ToolRegistry, limits ToolLoopLimits) (*ToolLoopResult, error)
+}
+
+// StructuredCaller is implemented by clients that can enforce a JSON schema on the response.
+// CallStructured returns ErrStructuredOutputUnsupported when the provider has no native support.
+type StructuredCaller interface {
+	CallStructured(req *Request) (string, error)
+	StructuredOutputMode() string
+}
+
+// StreamCaller is implemented by clients that can stream the response as it is generated.
+// All built-in clients implement it; check with a type assertion on AIClient.
+type StreamCaller interface {
+	CallStream(ctx context.Context, req *Request, onChunk func(chunk string)) (string, error)
+}
+
 // clientHooks internal hook interface (for subclass to override specific steps)
 // These methods are only used inside the package to implement dynamic dispatch
 type clientHooks interface {
@@ -0,0 +1,205 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProviderLocal       = "local"
+	DefaultLocalBaseURL = "http://localhost:11434/v1" // Ollama (llama.cpp server: http://localhost:8080/v1)
+	DefaultLocalModel   = ""                          // Empty: first model reported by /v1/models
+
+	// LocalNoAPIKey placeholder key of local servers that need no authentication.
+	// No Authorization header is sent while it is set.
+	LocalNoAPIKey = "local"
+
+	// DefaultLocalContextWindow context window assumed for local models (Ollama's default num_ctx is smaller,
+	// so raise it on the server or lower LOCAL_LLM_CONTEXT_WINDOW to match)
+	DefaultLocalContextWindow = 8192
+)
+
+// DefaultLocalTimeout local models on consumer hardware can take minutes per decision
+var DefaultLocalTimeout = 10 * time.Minute
+
+// LocalClient client for local OpenAI-compatible servers (Ollama, llama.cpp, LM Studio, vLLM)
+type LocalClient struct {
+	*Client
+
+	modelMu sync.Mutex
+}
+
+// NewLocalClient creates local model client (backward compatible)
+func NewLocalClient() AIClient {
+	return NewLocalClientWithOptions()
+}
+
+// NewLocalClientWithOptions creates local model client (supports options pattern)
+func NewLocalClientWithOptions(opts ...ClientOption) AIClient {
+	// 1. Create local preset options: no key, long timeout, fewer retries (a slow model is not a flaky one)
+	localOpts := []ClientOption{
+		WithProvider(ProviderLocal),
+		WithModel(DefaultLocalModel),
+		WithBaseURL(DefaultLocalBaseURL),
+		WithAPIKey(LocalNoAPIKey),
+		WithTimeout(DefaultLocalTimeout),
+		WithMaxRetries(2),
+		WithContextWindow(getEnvInt("LOCAL_LLM_CONTEXT_WINDOW", DefaultLocalContextWindow)),
+	}
+
+	// 2. Merge user options (user options have higher priority)
+	allOpts := append(localOpts, opts...)
+
+	// 3. Create base client
+	baseClient := NewClient(allOpts...).(*Client)
+
+	// 4. Create local client
+	localClient := &LocalClient{
+		Client: baseClient,
+	}
+
+	// 5. Set hooks to point to LocalClient (implement dynamic dispatch)
+	baseClient.hooks = localClient
+
+	return localClient
+}
+
+// SetAPIKey configures the server; an empty key means the server needs no authentication
+func (c *LocalClient) SetAPIKey(apiKey string, customURL string, customModel string) {
+	if apiKey == "" {
+		apiKey = LocalNoAPIKey
+	}
+	c.APIKey = apiKey
+
+	if customURL != "" {
+		c.BaseURL = strings.TrimSuffix(customURL, "/")
+		c.logger.Infof("🔧 [MCP] Local model using custom BaseURL: %s", c.BaseURL)
+	} else {
+		c.logger.Infof("🔧 [MCP] Local model using default BaseURL: %s", c.BaseURL)
+	}
+	if customModel != "" {
+		c.Model = customModel
+		c.logger.Infof("🔧 [MCP] Local model using custom Model: %s", customModel)
+	} else {
+		c.logger.Infof("🔧 [MCP] Local model will use the first model served at %s", c.BaseURL)
+	}
+}
+
+// setAuthHeader local servers usually run without auth; only send a key that was configured
+func (c *LocalClient) setAuthHeader(reqHeaders http.Header) {
+	if c.APIKey != LocalNoAPIKey {
+		c.Client.setAuthHeader(reqHeaders)
+	}
+}
+
+// structuredOutputMode Ollama (0.5+) and llama.cpp accept response_format json_schema
+func (c *LocalClient) structuredOutputMode() string {
+	return StructuredOutputJSONSchema
+}
+
+// CallWithMessages resolves the model if needed, then calls the server
+func (c *LocalClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	if err := c.ensureModel(); err != nil {
+		return "", err
+	}
+	return c.Client.CallWithMessages(systemPrompt, userPrompt)
+}
+
+// CallWithRequest resolves the model if needed, then calls the server
+func (c *LocalClient) CallWithRequest(req *Request) (string, error) {
+	if err := c.ensureModel(); err != nil {
+		return "", err
+	}
+	return c.Client.CallWithRequest(req)
+}
+
+// CallStructured resolves the model if needed, then calls the server with a JSON schema
+func (c *LocalClient) CallStructured(req *Request) (string, error) {
+	if err := c.ensureModel(); err != nil {
+		return "", err
+	}
+	return c.Client.CallStructured(req)
+}
+
+// CallWithTools resolves the model if needed, then runs the tool calling loop
+func (c *LocalClient) CallWithTools(ctx context.Context, req *Request, registry *ToolRegistry, limits ToolLoopLimits) (*ToolLoopResult, error) {
+	if err := c.ensureModel(); err != nil {
+		return nil, err
+	}
+	return c.Client.CallWithTools(ctx, req, registry, limits)
+}
+
+// CallStream resolves the model if needed, then streams the response
+func (c *LocalClient) CallStream(ctx context.Context, req *Request, onChunk func(chunk string)) (string, error) {
+	if err := c.ensureModel(); err != nil {
+		return "", err
+	}
+	return c.Client.CallStream(ctx, req, onChunk)
+}
+
+// ListModels returns the models served by the local server (GET /v1/models)
+func (c *LocalClient) ListModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.BaseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("fail to build request: %w", err)
+	}
+	c.setAuthHeader(req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+	models := make([]string, 0, len(result.Data))
+	for _, m := range result.Data {
+		if m.ID != "" {
+			models = append(models, m.ID)
+		}
+	}
+	return models, nil
+}
+
+// ensureModel picks the first served model when none is configured
+func (c *LocalClient) ensureModel() error {
+	c.modelMu.Lock()
+	defer c.modelMu.Unlock()
+	if c.Model != "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("no local model configured and discovery failed: %w", err)
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("no models served at %s, pull or load a model first", c.BaseURL)
+	}
+	c.Model = models[0]
+	c.logger.Infof("🔧 [MCP] Local model discovered: %s", c.Model)
+	return nil
+}
@@ -0,0 +1,82 @@
+package mcp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLocalClientDiscoversModelWithoutAuth(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/models") {
+			return jsonResponse(`{"object":"list","data":[{"id":"qwen2.5:14b"},{"id":"llama3.1:8b"}]}`), nil
+		}
+		return jsonResponse(`{"choices":[{"message":{"content":"ok"}}]}`), nil
+	}
+	client := NewLocalClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+	).(*LocalClient)
+	client.SetAPIKey("", "http://127.0.0.1:8080/v1/", "")
+
+	if _, err := client.CallWithMessages("sys", "user"); err != nil {
+		t.Fatalf("CallWithMessages: %v", err)
+	}
+	if client.Model != "qwen2.5:14b" {
+		t.Errorf("expected first served model, got %q", client.Model)
+	}
+
+	requests := mockHTTP.GetRequests()
+	if len(requests) != 2 {
+		t.Fatalf("expected discovery and chat requests, got %d", len(requests))
+	}
+	if got := requests[0].URL.String(); got != "http://127.0.0.1:8080/v1/models" {
+		t.Errorf("unexpected discovery URL %s", got)
+	}
+	body := readRequestBody(t, requests[1])
+	if body["model"] != "qwen2.5:14b" {
+		t.Errorf("chat request should use the discovered model, got %v", body["model"])
+	}
+	for _, req := range requests {
+		if auth := req.Header.Get("Authorization"); auth != "" {
+			t.Errorf("local server without key should get no Authorization header, got %q", auth)
+		}
+	}
+}
+
+func TestLocalClientSendsConfiguredKey(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.SetSuccessResponse("ok")
+	client := NewLocalClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+	)
+	client.SetAPIKey("secret-key", "", "llama3.1:8b")
+
+	if _, err := client.CallWithMessages("sys", "user"); err != nil {
+		t.Fatalf("CallWithMessages: %v", err)
+	}
+	if auth := mockHTTP.GetLastRequest().Header.Get("Authorization"); auth != "Bearer secret-key" {
+		t.Errorf("expected configured key to be sent, got %q", auth)
+	}
+}
+
+func TestLocalClientUserPromptBudget(t *testing.T) {
+	client := NewLocalClientWithOptions(
+		WithLogger(NewMockLogger()),
+		WithContextWindow(4096),
+		WithMaxTokens(1000),
+	).(*LocalClient)
+
+	system := strings.Repeat("abcd", 500) // ~500 tokens
+	if got, want := client.UserPromptBudget(system), 4096-1000-500-promptBudgetMargin; got != want {
+		t.Errorf("budget = %d, want %d", got, want)
+	}
+
+	// Without a configured window the shared budgeter falls back to the local default
+	unset := NewLocalClientWithOptions(WithLogger(NewMockLogger()), WithContextWindow(0), WithMaxTokens(1000)).(*LocalClient)
+	if got, want := unset.UserPromptBudget(system), PromptBudget(DefaultLocalContextWindow, 1000, system); got != want {
+		t.Errorf("budget = %d, want %d", got, want)
+	}
+}
@@ -92,6 +92,16 @@ func WithTemperature(temperature float64) ClientOption {
 	}
 }
 
+// WithContextWindow sets the model context window in tokens, used to budget prompt size
+//
+// Usage example:
+//   client := mcp.NewLocalClientWithOptions(mcp.WithContextWindow(32768))
+func WithContextWindow(tokens int) ClientOption {
+	return func(c *Config) {
+		c.ContextWindow = tokens
+	}
+}
+
 // ============================================================
 // Provider Configuration Options
 // ============================================================
@@ -2,8 +2,25 @@ package mcp
 
 // Message represents a conversation message
 type Message struct {
-	Role    string `json:"role"`    // "system", "user", "assistant"
+	Role    string `json:"role"`    // "system", "user", "assistant", "tool"
 	Content string `json:"content"` // Message content
+
+	// Tool calling (OpenAI wire format)
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Tool calls requested by the assistant
+	ToolCallID string     `json:"tool_call_id,omitempty"` // ID of the call a "tool" message answers
+}
+
+// ToolCall a tool invocation requested by the model
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"` // Always "function"
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction function name and JSON-encoded arguments of a tool call
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // Tool represents a tool/function that AI can call
@@ -37,6 +54,23 @@ type Request struct {
 	// Advanced features
 	Tools      []Tool `json:"tools,omitempty"`       // Available tools list
 	ToolChoice string `json:"tool_choice,omitempty"` // Tool choice strategy ("auto", "none", {"type": "function", "function": {"name": "xxx"}})
+
+	// Structured output (use CallStructured for provider-native enforcement)
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat requested response format (OpenAI wire format)
+type ResponseFormat struct {
+	Type       string            `json:"type"` // "json_schema" or "json_object"
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat named JSON Schema the response must conform to
+type JSONSchemaFormat struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema"`
+	Strict      bool           `json:"strict,omitempty"`
 }
 
 // NewMessage creates a message
@@ -70,3 +104,12 @@ func NewAssistantMessage(content string) Message {
 		Content: content,
 	}
 }
+
+// NewToolResultMessage creates a tool result message answering the given tool call
+func NewToolResultMessage(toolCallID, content string) Message {
+	return Message{
+		Role:       "tool",
+		Content:    content,
+		ToolCallID: toolCallID,
+	}
+}
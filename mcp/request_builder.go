@@ -17,6 +17,7 @@ type RequestBuilder struct {
 	stop             []string
 	tools            []Tool
 	toolChoice       string
+	responseFormat   *ResponseFormat
 }
 
 // NewRequestBuilder creates request builder
@@ -217,6 +218,29 @@ func (b *RequestBuilder) WithToolChoice(choice string) *RequestBuilder {
 	return b
 }
 
+// ============================================================
+// Structured Output Related
+// ============================================================
+
+// WithJSONSchema requires the response to be a JSON object matching schema (strict mode)
+func (b *RequestBuilder) WithJSONSchema(name string, schema map[string]any) *RequestBuilder {
+	b.responseFormat = &ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &JSONSchemaFormat{
+			Name:   name,
+			Schema: schema,
+			Strict: true,
+		},
+	}
+	return b
+}
+
+// WithJSONObject requires the response to be a JSON object without a fixed schema
+func (b *RequestBuilder) WithJSONObject() *RequestBuilder {
+	b.responseFormat = &ResponseFormat{Type: "json_object"}
+	return b
+}
+
 // ============================================================
 // Build Methods
 // ============================================================
@@ -230,12 +254,13 @@ func (b *RequestBuilder) Build() (*Request, error) {
 
 	// Create request
 	req := &Request{
-		Model:      b.model,
-		Messages:   b.messages,
-		Stream:     b.stream,
-		Stop:       b.stop,
-		Tools:      b.tools,
-		ToolChoice: b.toolChoice,
+		Model:          b.model,
+		Messages:       b.messages,
+		Stream:         b.stream,
+		Stop:           b.stop,
+		Tools:          b.tools,
+		ToolChoice:     b.toolChoice,
+		ResponseFormat: b.responseFormat,
 	}
 
 	// Only set non-nil optional parameters (avoid sending 0 values that override server defaults)
@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxStreamLineSize largest single SSE line accepted (a data line carries one JSON chunk)
+const maxStreamLineSize = 1024 * 1024
+
+// streamDelta one parsed stream event
+type streamDelta struct {
+	Text             string
+	Done             bool
+	PromptTokens     int // Non-zero when the event reports usage
+	CompletionTokens int
+}
+
+// streamHooks optional hooks for providers whose streaming wire format differs from OpenAI.
+// Kept separate from clientHooks so existing hook implementations stay valid.
+type streamHooks interface {
+	buildStreamRequestBody(req *Request) map[string]any
+	parseStreamEvent(data []byte) (*streamDelta, error)
+}
+
+// CallStream calls the AI with a streaming response (server-sent events). onChunk receives the
+// text as it is generated; the full text is returned when the stream ends. A failed call is only
+// retried if nothing was streamed yet, so onChunk never sees duplicated text.
+func (client *Client) CallStream(ctx context.Context, req *Request, onChunk func(chunk string)) (string, error) {
+	if client.APIKey == "" {
+		return "", fmt.Errorf("AI API key not set, please call SetAPIKey first")
+	}
+	if onChunk == nil {
+		onChunk = func(string) {}
+	}
+
+	conv := *req
+	conv.Stream = true
+	if conv.Model == "" {
+		conv.Model = client.Model
+	}
+
+	var lastErr error
+	maxRetries := client.config.MaxRetries
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if attempt > 1 {
+			client.logger.Warnf("⚠️  AI API call failed, retrying (%d/%d)...", attempt, maxRetries)
+		}
+
+		result, streamed, err := client.callStream(ctx, &conv, onChunk)
+		if err == nil {
+			if attempt > 1 {
+				client.logger.Infof("✓ AI API retry succeeded")
+			}
+			return result, nil
+		}
+
+		lastErr = err
+		if streamed || ctx.Err() != nil || !client.hooks.isRetryableError(err) {
+			return "", err
+		}
+
+		if attempt < maxRetries {
+			waitTime := client.config.RetryWaitBase * time.Duration(attempt)
+			client.logger.Infof("⏳ Waiting %v before retry...", waitTime)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return "", lastErr
+			}
+		}
+	}
+
+	return "", fmt.Errorf("still failed after %d retries: %w", maxRetries, lastErr)
+}
+
+// callStream single streaming call; streamed reports whether any text reached onChunk
+func (client *Client) callStream(ctx context.Context, req *Request, onChunk func(chunk string)) (result string, streamed bool, err error) {
+	hooks, ok := client.hooks.(streamHooks)
+	if !ok {
+		hooks = client
+	}
+
+	client.logger.Infof("📡 [%s] Request AI Server with streaming: BaseURL: %s", client.String(), client.BaseURL)
+	jsonData, err := client.hooks.marshalRequestBody(hooks.buildStreamRequestBody(req))
+	if err != nil {
+		return "", false, err
+	}
+	httpReq, err := client.hooks.buildRequest(client.hooks.buildUrl(), jsonData)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.httpClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", false, fmt.Errorf("API returned error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var sb strings.Builder
+	promptTokens, completionTokens := 0, 0
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue // event names, comments and keep-alives
+		}
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if string(data) == "[DONE]" {
+			break
+		}
+
+		delta, err := hooks.parseStreamEvent(data)
+		if err != nil {
+			return "", sb.Len() > 0, fmt.Errorf("fail to parse AI stream: %w", err)
+		}
+		if delta.PromptTokens > 0 {
+			promptTokens = delta.PromptTokens
+		}
+		if delta.CompletionTokens > 0 {
+			completionTokens = delta.CompletionTokens
+		}
+		if delta.Text != "" {
+			sb.WriteString(delta.Text)
+			onChunk(delta.Text)
+		}
+		if delta.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", sb.Len() > 0, fmt.Errorf("failed to read stream: %w", err)
+	}
+	if sb.Len() == 0 {
+		return "", false, fmt.Errorf("API returned empty response")
+	}
+
+	if promptTokens > 0 || completionTokens > 0 {
+		client.reportTokenUsage(promptTokens, completionTokens, promptTokens+completionTokens)
+	}
+	return sb.String(), true, nil
+}
+
+// buildStreamRequestBody OpenAI-compatible streaming request
+func (client *Client) buildStreamRequestBody(req *Request) map[string]any {
+	requestBody := client.buildRequestBodyFromRequest(req)
+	switch client.Provider {
+	case ProviderOpenAI, ProviderDeepSeek, ProviderQwen:
+		// Ask for a final usage chunk so streamed calls are still billed per trader
+		requestBody["stream_options"] = map[string]any{"include_usage": true}
+	}
+	return requestBody
+}
+
+// parseStreamEvent parses an OpenAI-compatible chat.completion.chunk
+func (client *Client) parseStreamEvent(data []byte) (*streamDelta, error) {
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content string `json:"content"`
+			} `json:"delta"`
+		} `json:"choices"`
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+	}
+	if chunk.Error != nil {
+		return nil, fmt.Errorf("API stream error: %s", chunk.Error.Message)
+	}
+
+	delta := &streamDelta{}
+	for _, choice := range chunk.Choices {
+		delta.Text += choice.Delta.Content
+	}
+	if chunk.Usage != nil {
+		delta.PromptTokens = chunk.Usage.PromptTokens
+		delta.CompletionTokens = chunk.Usage.CompletionTokens
+	}
+	return delta, nil
+}
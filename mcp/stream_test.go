@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func sseResponse(events ...string) *http.Response {
+	var body strings.Builder
+	for _, e := range events {
+		body.WriteString(e)
+		body.WriteString("\n\n")
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(body.String())),
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+	}
+}
+
+func TestCallStreamOpenAICompatible(t *testing.T) {
+	reported := captureTokenUsage(t)
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		return sseResponse(
+			`data: {"choices":[{"delta":{"role":"assistant","content":""}}]}`,
+			`data: {"choices":[{"delta":{"content":"BTC looks "}}]}`,
+			`: keep-alive`,
+			`data: {"choices":[{"delta":{"content":"strong"}}]}`,
+			`data: {"choices":[],"usage":{"prompt_tokens":50,"completion_tokens":4,"total_tokens":54}}`,
+			`data: [DONE]`,
+		), nil
+	}
+	client := NewDeepSeekClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	)
+
+	var chunks []string
+	req, _ := NewRequestBuilder().WithSystemPrompt("sys").WithUserPrompt("user").Build()
+	got, err := client.(StreamCaller).CallStream(context.Background(), req, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatalf("CallStream: %v", err)
+	}
+	if got != "BTC looks strong" || len(chunks) != 2 {
+		t.Errorf("got %q in chunks %q", got, chunks)
+	}
+
+	body := readRequestBody(t, mockHTTP.GetLastRequest())
+	if body["stream"] != true || body["stream_options"] == nil {
+		t.Errorf("request should enable streaming with usage, got %v", body)
+	}
+	if len(*reported) != 1 || (*reported)[0].PromptTokens != 50 || (*reported)[0].CompletionTokens != 4 {
+		t.Errorf("unexpected usage reports: %+v", *reported)
+	}
+}
+
+func TestCallStreamClaude(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		return sseResponse(
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":30}}}",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hold \"}}",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"all\"}}",
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":2}}",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}",
+		), nil
+	}
+	client := NewClaudeClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	)
+
+	req, _ := NewRequestBuilder().WithSystemPrompt("sys").WithUserPrompt("user").Build()
+	got, err := client.(StreamCaller).CallStream(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("CallStream: %v", err)
+	}
+	if got != "Hold all" {
+		t.Errorf("got %q", got)
+	}
+	body := readRequestBody(t, mockHTTP.GetLastRequest())
+	if body["stream"] != true || body["system"] != "sys" {
+		t.Errorf("unexpected Claude stream request: %v", body)
+	}
+}
+
+func TestCallStreamDoesNotRetryAfterOutput(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(io.MultiReader(strings.NewReader("data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\n"), failingReader{})),
+			Header:     make(http.Header),
+		}, nil
+	}
+	client := NewDeepSeekClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	)
+
+	req, _ := NewRequestBuilder().WithUserPrompt("user").Build()
+	var chunks []string
+	if _, err := client.(StreamCaller).CallStream(context.Background(), req, func(chunk string) {
+		chunks = append(chunks, chunk)
+	}); err == nil {
+		t.Fatal("expected error for broken stream")
+	}
+	if len(mockHTTP.GetRequests()) != 1 || len(chunks) != 1 {
+		t.Errorf("streamed call must not be retried: %d requests, chunks %q", len(mockHTTP.GetRequests()), chunks)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset by peer") }
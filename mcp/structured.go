@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStructuredOutputUnsupported is returned by CallStructured when the provider has no
+// native structured output; callers should fall back to parsing free-form text.
+var ErrStructuredOutputUnsupported = errors.New("structured output not supported by provider")
+
+// Structured output modes
+const (
+	StructuredOutputNone       = ""            // No native support
+	StructuredOutputJSONSchema = "json_schema" // response_format json_schema (OpenAI, Grok, Gemini)
+	StructuredOutputJSONObject = "json_object" // response_format json_object, schema sent in the prompt (DeepSeek, Qwen, Kimi)
+	StructuredOutputTool       = "tool"        // Forced tool call whose input is the answer (Claude)
+)
+
+// structuredOutputHooks optional hooks for providers that enforce schemas differently
+type structuredOutputHooks interface {
+	structuredOutputMode() string
+	buildStructuredRequestBody(req *Request) map[string]any
+	parseStructuredResponse(body []byte) (string, error)
+}
+
+// StructuredOutputMode reports how this client enforces a JSON schema
+func (client *Client) StructuredOutputMode() string {
+	return client.structuredHooks().structuredOutputMode()
+}
+
+func (client *Client) structuredHooks() structuredOutputHooks {
+	if hooks, ok := client.hooks.(structuredOutputHooks); ok {
+		return hooks
+	}
+	return client
+}
+
+func (client *Client) structuredOutputMode() string {
+	switch client.Provider {
+	case ProviderOpenAI, ProviderGrok, ProviderGemini:
+		return StructuredOutputJSONSchema
+	case ProviderDeepSeek, ProviderQwen, ProviderKimi:
+		return StructuredOutputJSONObject
+	default:
+		// Custom OpenAI-compatible endpoints may not accept response_format
+		return StructuredOutputNone
+	}
+}
+
+// CallStructured calls the AI with provider-native structured output and returns the JSON
+// answer. req.ResponseFormat must carry a JSON schema (see RequestBuilder.WithJSONSchema).
+// Returns ErrStructuredOutputUnsupported without calling the API if the provider lacks support.
+func (client *Client) CallStructured(req *Request) (string, error) {
+	if client.APIKey == "" {
+		return "", fmt.Errorf("AI API key not set, please call SetAPIKey first")
+	}
+	if req.ResponseFormat == nil || req.ResponseFormat.JSONSchema == nil {
+		return "", fmt.Errorf("structured call requires a JSON schema response format")
+	}
+	hooks := client.structuredHooks()
+	if hooks.structuredOutputMode() == StructuredOutputNone {
+		return "", ErrStructuredOutputUnsupported
+	}
+
+	conv := *req
+	conv.Stream = false
+	if conv.Model == "" {
+		conv.Model = client.Model
+	}
+
+	var lastErr error
+	maxRetries := client.config.MaxRetries
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if attempt > 1 {
+			client.logger.Warnf("⚠️  AI API call failed, retrying (%d/%d)...", attempt, maxRetries)
+		}
+
+		client.logger.Infof("📡 [%s] Request AI Server with structured output (%s): BaseURL: %s",
+			client.String(), hooks.structuredOutputMode(), client.BaseURL)
+		body, err := client.post(context.Background(), hooks.buildStructuredRequestBody(&conv))
+		if err == nil {
+			var result string
+			result, err = hooks.parseStructuredResponse(body)
+			if err == nil {
+				if attempt > 1 {
+					client.logger.Infof("✓ AI API retry succeeded")
+				}
+				return result, nil
+			}
+			err = fmt.Errorf("fail to parse AI server response: %w", err)
+		}
+
+		lastErr = err
+		if !client.hooks.isRetryableError(err) {
+			return "", err
+		}
+
+		if attempt < maxRetries {
+			waitTime := client.config.RetryWaitBase * time.Duration(attempt)
+			client.logger.Infof("⏳ Waiting %v before retry...", waitTime)
+			time.Sleep(waitTime)
+		}
+	}
+
+	return "", fmt.Errorf("still failed after %d retries: %w", maxRetries, lastErr)
+}
+
+// buildStructuredRequestBody OpenAI-compatible response_format request
+func (client *Client) buildStructuredRequestBody(req *Request) map[string]any {
+	if client.structuredOutputMode() != StructuredOutputJSONObject {
+		return client.buildRequestBodyFromRequest(req)
+	}
+
+	// json_object only guarantees valid JSON, so the schema goes into the system prompt
+	schema, _ := json.Marshal(req.ResponseFormat.JSONSchema.Schema)
+	instruction := fmt.Sprintf("Respond with a single JSON object that conforms to this JSON Schema:\n%s", schema)
+
+	conv := *req
+	conv.ResponseFormat = &ResponseFormat{Type: StructuredOutputJSONObject}
+	conv.Messages = make([]Message, 0, len(req.Messages)+1)
+	hasSystem := false
+	for _, msg := range req.Messages {
+		if msg.Role == "system" && !hasSystem {
+			msg.Content += "\n\n" + instruction
+			hasSystem = true
+		}
+		conv.Messages = append(conv.Messages, msg)
+	}
+	if !hasSystem {
+		conv.Messages = append([]Message{NewSystemMessage(instruction)}, conv.Messages...)
+	}
+	return client.buildRequestBodyFromRequest(&conv)
+}
+
+// parseStructuredResponse the JSON answer is the message content
+func (client *Client) parseStructuredResponse(body []byte) (string, error) {
+	return client.hooks.parseMCPResponse(body)
+}
@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testAnswerSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"answer": map[string]any{"type": "string"},
+			"score":  map[string]any{"type": []string{"number", "null"}},
+		},
+		"required":             []string{"answer", "score"},
+		"additionalProperties": false,
+	}
+}
+
+func testStructuredRequest() *Request {
+	return NewRequestBuilder().
+		WithSystemPrompt("sys").
+		WithUserPrompt("question").
+		WithJSONSchema("answer", testAnswerSchema()).
+		MustBuild()
+}
+
+func TestCallStructured_JSONSchema(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	var body map[string]any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		body = readRequestBody(t, req)
+		return jsonResponse(`{"choices":[{"message":{"content":"{\"answer\":\"yes\",\"score\":1}"}}]}`), nil
+	}
+
+	client := NewOpenAIClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(StructuredCaller)
+
+	if client.StructuredOutputMode() != StructuredOutputJSONSchema {
+		t.Fatalf("expected json_schema mode, got %q", client.StructuredOutputMode())
+	}
+	result, err := client.CallStructured(testStructuredRequest())
+	if err != nil {
+		t.Fatalf("CallStructured: %v", err)
+	}
+	if result != `{"answer":"yes","score":1}` {
+		t.Errorf("unexpected result: %s", result)
+	}
+
+	format := body["response_format"].(map[string]any)
+	schema := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || schema["name"] != "answer" || schema["strict"] != true {
+		t.Errorf("unexpected response_format: %v", format)
+	}
+}
+
+func TestCallStructured_JSONObjectPutsSchemaInPrompt(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	var body map[string]any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		body = readRequestBody(t, req)
+		return jsonResponse(`{"choices":[{"message":{"content":"{\"answer\":\"no\",\"score\":null}"}}]}`), nil
+	}
+
+	client := NewDeepSeekClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(StructuredCaller)
+
+	if _, err := client.CallStructured(testStructuredRequest()); err != nil {
+		t.Fatalf("CallStructured: %v", err)
+	}
+	if format := body["response_format"].(map[string]any); format["type"] != "json_object" || format["json_schema"] != nil {
+		t.Errorf("expected bare json_object format, got %v", format)
+	}
+	system := body["messages"].([]any)[0].(map[string]any)["content"].(string)
+	if !strings.HasPrefix(system, "sys\n\n") || !strings.Contains(system, `"additionalProperties":false`) {
+		t.Errorf("schema should be appended to the system prompt, got %q", system)
+	}
+}
+
+func TestCallStructured_ClaudeForcedTool(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	var body map[string]any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		body = readRequestBody(t, req)
+		return jsonResponse(`{"content":[{"type":"tool_use","id":"toolu_1","name":"answer","input":{"answer":"yes","score":0.5}}]}`), nil
+	}
+
+	client := NewClaudeClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(StructuredCaller)
+
+	result, err := client.CallStructured(testStructuredRequest())
+	if err != nil {
+		t.Fatalf("CallStructured: %v", err)
+	}
+	if result != `{"answer":"yes","score":0.5}` {
+		t.Errorf("unexpected result: %s", result)
+	}
+	if choice := body["tool_choice"].(map[string]any); choice["type"] != "tool" || choice["name"] != "answer" {
+		t.Errorf("expected forced tool choice, got %v", choice)
+	}
+	if body["response_format"] != nil || body["system"] != "sys" {
+		t.Errorf("unexpected Claude request: %v", body)
+	}
+}
+
+func TestCallStructured_GeminiSchema(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	var body map[string]any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		body = readRequestBody(t, req)
+		return jsonResponse(`{"choices":[{"message":{"content":"{\"answer\":\"yes\",\"score\":null}"}}]}`), nil
+	}
+
+	client := NewGeminiClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(StructuredCaller)
+
+	req := testStructuredRequest()
+	if _, err := client.CallStructured(req); err != nil {
+		t.Fatalf("CallStructured: %v", err)
+	}
+	schema := body["response_format"].(map[string]any)["json_schema"].(map[string]any)["schema"].(map[string]any)
+	if _, ok := schema["additionalProperties"]; ok {
+		t.Error("additionalProperties should be stripped for Gemini")
+	}
+	score := schema["properties"].(map[string]any)["score"].(map[string]any)
+	if score["type"] != "number" || score["nullable"] != true {
+		t.Errorf("expected nullable number, got %v", score)
+	}
+	if _, ok := req.ResponseFormat.JSONSchema.Schema["additionalProperties"]; !ok {
+		t.Error("caller's schema should not be modified")
+	}
+}
+
+func TestCallStructured_Unsupported(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	client := NewClient(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+	).(*Client)
+	client.SetAPIKey("test-key", "https://llm.example.com/v1", "local-model")
+
+	_, err := client.CallStructured(testStructuredRequest())
+	if !errors.Is(err, ErrStructuredOutputUnsupported) {
+		t.Fatalf("expected ErrStructuredOutputUnsupported, got %v", err)
+	}
+	if len(mockHTTP.GetRequests()) != 0 {
+		t.Error("unsupported providers should not be called")
+	}
+}
@@ -0,0 +1,364 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultToolMaxIterations = 5
+	DefaultToolLoopTimeout   = 90 * time.Second
+)
+
+// ToolHandler executes a tool call. args is the JSON object produced by the model;
+// the returned string is sent back to the model as the tool result.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+type registeredTool struct {
+	tool    Tool
+	handler ToolHandler
+}
+
+// ToolRegistry maps tool names to Go handlers.
+// Register all tools before use; the registry is read-only during a tool loop.
+type ToolRegistry struct {
+	tools []registeredTool
+	index map[string]int
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{index: make(map[string]int)}
+}
+
+// Register adds a tool. parameters is the JSON Schema of the arguments object.
+func (r *ToolRegistry) Register(name, description string, parameters map[string]any, handler ToolHandler) error {
+	if name == "" {
+		return fmt.Errorf("tool name cannot be empty")
+	}
+	if handler == nil {
+		return fmt.Errorf("tool %s has no handler", name)
+	}
+	if _, exists := r.index[name]; exists {
+		return fmt.Errorf("tool %s already registered", name)
+	}
+	if parameters == nil {
+		parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	r.index[name] = len(r.tools)
+	r.tools = append(r.tools, registeredTool{
+		tool: Tool{
+			Type: "function",
+			Function: FunctionDef{
+				Name:        name,
+				Description: description,
+				Parameters:  parameters,
+			},
+		},
+		handler: handler,
+	})
+	return nil
+}
+
+// Tools returns the tool definitions in registration order
+func (r *ToolRegistry) Tools() []Tool {
+	tools := make([]Tool, 0, len(r.tools))
+	for _, t := range r.tools {
+		tools = append(tools, t.tool)
+	}
+	return tools
+}
+
+// Len returns the number of registered tools
+func (r *ToolRegistry) Len() int {
+	return len(r.tools)
+}
+
+// Call runs the named tool with JSON arguments
+func (r *ToolRegistry) Call(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	i, ok := r.index[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+	args = json.RawMessage(strings.TrimSpace(string(args)))
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return "", fmt.Errorf("arguments are not valid JSON")
+	}
+	return r.tools[i].handler(ctx, args)
+}
+
+// execute runs a tool call. Unknown tools and handler errors are reported to the model
+// as the tool result instead of aborting the loop, so it can correct itself.
+func (r *ToolRegistry) execute(ctx context.Context, call ToolCall) (string, error) {
+	output, err := r.Call(ctx, call.Function.Name, json.RawMessage(call.Function.Arguments))
+	if err != nil {
+		return "error: " + err.Error(), err
+	}
+	return output, nil
+}
+
+// ToolLoopLimits bounds a tool calling loop
+type ToolLoopLimits struct {
+	MaxIterations int           // Maximum model turns, including the final answer (default 5)
+	Timeout       time.Duration // Total time budget for the loop (default 90s)
+}
+
+func (l ToolLoopLimits) withDefaults() ToolLoopLimits {
+	if l.MaxIterations <= 0 {
+		l.MaxIterations = DefaultToolMaxIterations
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultToolLoopTimeout
+	}
+	return l
+}
+
+// ToolInvocation records one executed tool call
+type ToolInvocation struct {
+	Name      string        `json:"name"`
+	Arguments string        `json:"arguments"`
+	Output    string        `json:"output"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// ToolLoopResult final answer of a tool calling loop
+type ToolLoopResult struct {
+	Content    string           `json:"content"`
+	Iterations int              `json:"iterations"`
+	Calls      []ToolInvocation `json:"calls,omitempty"`
+}
+
+// toolResponse a parsed model turn: text and/or requested tool calls
+type toolResponse struct {
+	Content   string
+	ToolCalls []ToolCall
+}
+
+// toolCallHooks optional hooks for providers whose tool calling wire format differs from OpenAI.
+// Kept separate from clientHooks so existing hook implementations stay valid.
+type toolCallHooks interface {
+	buildToolRequestBody(req *Request) map[string]any
+	parseToolResponse(body []byte) (*toolResponse, error)
+}
+
+// CallWithTools runs a multi-turn tool calling loop: the model may request tools from the
+// registry, their results are appended to the conversation and the model is called again
+// until it answers without tool calls. The last allowed turn disables tools to force an answer.
+func (client *Client) CallWithTools(ctx context.Context, req *Request, registry *ToolRegistry, limits ToolLoopLimits) (*ToolLoopResult, error) {
+	if client.APIKey == "" {
+		return nil, fmt.Errorf("AI API key not set, please call SetAPIKey first")
+	}
+	if registry == nil || registry.Len() == 0 {
+		return nil, fmt.Errorf("no tools registered")
+	}
+	limits = limits.withDefaults()
+
+	ctx, cancel := context.WithTimeout(ctx, limits.Timeout)
+	defer cancel()
+
+	conv := *req
+	conv.Messages = append([]Message(nil), req.Messages...)
+	conv.Tools = registry.Tools()
+	conv.Stream = false
+	if conv.Model == "" {
+		conv.Model = client.Model
+	}
+	if conv.ToolChoice == "" {
+		conv.ToolChoice = "auto"
+	}
+
+	result := &ToolLoopResult{}
+	for iteration := 1; iteration <= limits.MaxIterations; iteration++ {
+		final := iteration == limits.MaxIterations
+		if final {
+			conv.ToolChoice = "none"
+		}
+
+		resp, err := client.callToolsWithRetry(ctx, &conv)
+		if err != nil {
+			return nil, err
+		}
+		result.Iterations = iteration
+
+		if len(resp.ToolCalls) == 0 {
+			result.Content = resp.Content
+			return result, nil
+		}
+		if final {
+			return nil, fmt.Errorf("model still requested tools after %d iterations", limits.MaxIterations)
+		}
+
+		for i := range resp.ToolCalls {
+			if resp.ToolCalls[i].ID == "" {
+				resp.ToolCalls[i].ID = fmt.Sprintf("call_%d_%d", iteration, i)
+			}
+			resp.ToolCalls[i].Type = "function"
+		}
+		conv.Messages = append(conv.Messages, Message{
+			Role:      "assistant",
+			Content:   resp.Content,
+			ToolCalls: resp.ToolCalls,
+		})
+
+		for _, call := range resp.ToolCalls {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("tool loop time budget exceeded: %w", err)
+			}
+			start := time.Now()
+			output, err := registry.execute(ctx, call)
+			invocation := ToolInvocation{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+				Output:    output,
+				Duration:  time.Since(start),
+			}
+			if err != nil {
+				invocation.Error = err.Error()
+				client.logger.Warnf("⚠️  [MCP] Tool %s failed: %v", call.Function.Name, err)
+			} else {
+				client.logger.Infof("🔧 [MCP] Tool %s(%s) returned %d chars", call.Function.Name, call.Function.Arguments, len(output))
+			}
+			result.Calls = append(result.Calls, invocation)
+			conv.Messages = append(conv.Messages, NewToolResultMessage(call.ID, output))
+		}
+	}
+
+	return nil, fmt.Errorf("tool loop ended without an answer")
+}
+
+// callToolsWithRetry single model turn with the same retry policy as CallWithRequest,
+// bounded by the loop's context
+func (client *Client) callToolsWithRetry(ctx context.Context, req *Request) (*toolResponse, error) {
+	var lastErr error
+	maxRetries := client.config.MaxRetries
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if attempt > 1 {
+			client.logger.Warnf("⚠️  AI API call failed, retrying (%d/%d)...", attempt, maxRetries)
+		}
+
+		resp, err := client.callTools(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("tool loop time budget exceeded: %w", err)
+		}
+
+		lastErr = err
+		if !client.hooks.isRetryableError(err) {
+			return nil, err
+		}
+
+		if attempt < maxRetries {
+			waitTime := client.config.RetryWaitBase * time.Duration(attempt)
+			client.logger.Infof("⏳ Waiting %v before retry...", waitTime)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("tool loop time budget exceeded: %w", lastErr)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("still failed after %d retries: %w", maxRetries, lastErr)
+}
+
+// callTools single AI API call in tool calling mode
+func (client *Client) callTools(ctx context.Context, req *Request) (*toolResponse, error) {
+	hooks, ok := client.hooks.(toolCallHooks)
+	if !ok {
+		hooks = client
+	}
+
+	client.logger.Infof("📡 [%s] Request AI Server with tools: BaseURL: %s", client.String(), client.BaseURL)
+	client.logger.Debugf("[%s] Messages count: %d", client.String(), len(req.Messages))
+
+	body, err := client.post(ctx, hooks.buildToolRequestBody(req))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := hooks.parseToolResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse AI server response: %w", err)
+	}
+	return result, nil
+}
+
+// buildToolRequestBody OpenAI-compatible tool calling request (also used by Gemini, DeepSeek, Qwen...)
+func (client *Client) buildToolRequestBody(req *Request) map[string]any {
+	return client.buildRequestBodyFromRequest(req)
+}
+
+// parseToolResponse parses an OpenAI-compatible response with optional tool_calls
+func (client *Client) parseToolResponse(body []byte) (*toolResponse, error) {
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string          `json:"name"`
+						Arguments json.RawMessage `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("API returned empty response")
+	}
+
+	client.reportTokenUsage(result.Usage.PromptTokens, result.Usage.CompletionTokens, result.Usage.TotalTokens)
+
+	message := result.Choices[0].Message
+	parsed := &toolResponse{Content: message.Content}
+	for _, call := range message.ToolCalls {
+		parsed.ToolCalls = append(parsed.ToolCalls, ToolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: ToolCallFunction{
+				Name:      call.Function.Name,
+				Arguments: rawArguments(call.Function.Arguments),
+			},
+		})
+	}
+	return parsed, nil
+}
+
+// rawArguments normalizes tool arguments: OpenAI sends a JSON-encoded string,
+// some compatible APIs (Gemini) send the object itself
+func rawArguments(raw json.RawMessage) string {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return "{}"
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			if strings.TrimSpace(s) == "" {
+				return "{}"
+			}
+			return s
+		}
+	}
+	return string(raw)
+}
@@ -0,0 +1,297 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func jsonResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Header:     make(http.Header),
+	}
+}
+
+func readRequestBody(t *testing.T, req *http.Request) map[string]any {
+	t.Helper()
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("read request body: %v", err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("decode request body: %v", err)
+	}
+	return body
+}
+
+func newPriceRegistry(t *testing.T, calls *[]string) *ToolRegistry {
+	t.Helper()
+	registry := NewToolRegistry()
+	err := registry.Register("get_price", "Get the latest price", map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"symbol": map[string]any{"type": "string"},
+		},
+		"required": []string{"symbol"},
+	}, func(ctx context.Context, args json.RawMessage) (string, error) {
+		var in struct {
+			Symbol string `json:"symbol"`
+		}
+		if err := json.Unmarshal(args, &in); err != nil {
+			return "", err
+		}
+		*calls = append(*calls, in.Symbol)
+		return in.Symbol + "=42000", nil
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return registry
+}
+
+func TestToolRegistry_Register(t *testing.T) {
+	registry := NewToolRegistry()
+	handler := func(ctx context.Context, args json.RawMessage) (string, error) { return "ok", nil }
+
+	if err := registry.Register("", "", nil, handler); err == nil {
+		t.Error("expected error for empty name")
+	}
+	if err := registry.Register("a", "", nil, nil); err == nil {
+		t.Error("expected error for nil handler")
+	}
+	if err := registry.Register("a", "first", nil, handler); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := registry.Register("a", "again", nil, handler); err == nil {
+		t.Error("expected error for duplicate name")
+	}
+
+	tools := registry.Tools()
+	if len(tools) != 1 || tools[0].Type != "function" || tools[0].Function.Parameters["type"] != "object" {
+		t.Errorf("unexpected tools: %+v", tools)
+	}
+
+	output, err := registry.execute(context.Background(), ToolCall{Function: ToolCallFunction{Name: "missing"}})
+	if err == nil || !strings.HasPrefix(output, "error:") {
+		t.Errorf("expected error result for unknown tool, got %q", output)
+	}
+	output, err = registry.execute(context.Background(), ToolCall{Function: ToolCallFunction{Name: "a", Arguments: "{bad"}})
+	if err == nil || !strings.HasPrefix(output, "error:") {
+		t.Errorf("expected error result for invalid arguments, got %q", output)
+	}
+}
+
+func TestCallWithTools_OpenAIFormat(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	var bodies []map[string]any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		bodies = append(bodies, readRequestBody(t, req))
+		if len(bodies) == 1 {
+			return jsonResponse(`{"choices":[{"message":{"content":null,"tool_calls":[
+				{"id":"call_a","type":"function","function":{"name":"get_price","arguments":"{\"symbol\":\"BTCUSDT\"}"}},
+				{"id":"call_b","type":"function","function":{"name":"get_price","arguments":"{\"symbol\":\"ETHUSDT\"}"}}]}}]}`), nil
+		}
+		return jsonResponse(`{"choices":[{"message":{"content":"hold"}}]}`), nil
+	}
+
+	client := NewClient(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(*Client)
+
+	var calls []string
+	req := NewRequestBuilder().WithSystemPrompt("sys").WithUserPrompt("decide").MustBuild()
+	result, err := client.CallWithTools(context.Background(), req, newPriceRegistry(t, &calls), ToolLoopLimits{})
+	if err != nil {
+		t.Fatalf("CallWithTools: %v", err)
+	}
+
+	if result.Content != "hold" || result.Iterations != 2 || len(result.Calls) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if strings.Join(calls, ",") != "BTCUSDT,ETHUSDT" {
+		t.Errorf("unexpected handler calls: %v", calls)
+	}
+	if len(req.Messages) != 2 {
+		t.Errorf("caller's request should not be modified, got %d messages", len(req.Messages))
+	}
+
+	if bodies[0]["tool_choice"] != "auto" || len(bodies[0]["tools"].([]any)) != 1 {
+		t.Errorf("first request should offer tools: %v", bodies[0])
+	}
+	messages := bodies[1]["messages"].([]any)
+	if len(messages) != 5 {
+		t.Fatalf("expected system, user, assistant and 2 tool messages, got %d", len(messages))
+	}
+	assistant := messages[2].(map[string]any)
+	if assistant["role"] != "assistant" || len(assistant["tool_calls"].([]any)) != 2 {
+		t.Errorf("unexpected assistant message: %v", assistant)
+	}
+	toolMsg := messages[4].(map[string]any)
+	if toolMsg["role"] != "tool" || toolMsg["tool_call_id"] != "call_b" || toolMsg["content"] != "ETHUSDT=42000" {
+		t.Errorf("unexpected tool message: %v", toolMsg)
+	}
+}
+
+func TestCallWithTools_GeminiObjectArguments(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	turn := 0
+	var secondBody map[string]any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		turn++
+		if turn == 1 {
+			// Gemini's OpenAI-compatible endpoint may omit the call ID and send arguments as an object
+			return jsonResponse(`{"choices":[{"message":{"content":"","tool_calls":[
+				{"function":{"name":"get_price","arguments":{"symbol":"SOLUSDT"}}}]}}]}`), nil
+		}
+		secondBody = readRequestBody(t, req)
+		return jsonResponse(`{"choices":[{"message":{"content":"done"}}]}`), nil
+	}
+
+	client := NewGeminiClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(ToolCaller)
+
+	var calls []string
+	req := NewRequestBuilder().WithUserPrompt("decide").MustBuild()
+	result, err := client.CallWithTools(context.Background(), req, newPriceRegistry(t, &calls), ToolLoopLimits{})
+	if err != nil {
+		t.Fatalf("CallWithTools: %v", err)
+	}
+	if result.Content != "done" || len(calls) != 1 || calls[0] != "SOLUSDT" {
+		t.Fatalf("unexpected result: %+v calls=%v", result, calls)
+	}
+	messages := secondBody["messages"].([]any)
+	toolMsg := messages[len(messages)-1].(map[string]any)
+	if toolMsg["tool_call_id"] != "call_1_0" {
+		t.Errorf("expected synthesized call ID, got %v", toolMsg["tool_call_id"])
+	}
+}
+
+func TestCallWithTools_ClaudeFormat(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	var bodies []map[string]any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		if !strings.HasSuffix(req.URL.Path, "/messages") {
+			t.Errorf("expected Claude messages endpoint, got %s", req.URL.Path)
+		}
+		bodies = append(bodies, readRequestBody(t, req))
+		if len(bodies) == 1 {
+			return jsonResponse(`{"content":[
+				{"type":"text","text":"Checking price"},
+				{"type":"tool_use","id":"toolu_1","name":"get_price","input":{"symbol":"BTCUSDT"}}],
+				"usage":{"input_tokens":10,"output_tokens":5}}`), nil
+		}
+		return jsonResponse(`{"content":[{"type":"text","text":"open_long"}]}`), nil
+	}
+
+	client := NewClaudeClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(ToolCaller)
+
+	var calls []string
+	req := NewRequestBuilder().WithSystemPrompt("sys").WithUserPrompt("decide").WithToolChoice("required").MustBuild()
+	result, err := client.CallWithTools(context.Background(), req, newPriceRegistry(t, &calls), ToolLoopLimits{})
+	if err != nil {
+		t.Fatalf("CallWithTools: %v", err)
+	}
+	if result.Content != "open_long" || len(calls) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	first := bodies[0]
+	if first["system"] != "sys" {
+		t.Errorf("system prompt should be top-level, got %v", first["system"])
+	}
+	if choice := first["tool_choice"].(map[string]any); choice["type"] != "any" {
+		t.Errorf("expected tool_choice any, got %v", choice)
+	}
+	tool := first["tools"].([]any)[0].(map[string]any)
+	if tool["name"] != "get_price" || tool["input_schema"] == nil {
+		t.Errorf("unexpected Claude tool definition: %v", tool)
+	}
+
+	messages := bodies[1]["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected user, assistant and tool_result messages, got %d", len(messages))
+	}
+	assistant := messages[1].(map[string]any)["content"].([]any)
+	toolUse := assistant[1].(map[string]any)
+	if toolUse["type"] != "tool_use" || toolUse["id"] != "toolu_1" || toolUse["input"].(map[string]any)["symbol"] != "BTCUSDT" {
+		t.Errorf("unexpected tool_use block: %v", toolUse)
+	}
+	resultMsg := messages[2].(map[string]any)
+	block := resultMsg["content"].([]any)[0].(map[string]any)
+	if resultMsg["role"] != "user" || block["type"] != "tool_result" || block["tool_use_id"] != "toolu_1" {
+		t.Errorf("unexpected tool_result message: %v", resultMsg)
+	}
+}
+
+func TestCallWithTools_MaxIterations(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	var choices []any
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		choices = append(choices, readRequestBody(t, req)["tool_choice"])
+		// Model keeps asking for tools
+		return jsonResponse(fmt.Sprintf(`{"choices":[{"message":{"tool_calls":[
+			{"id":"c%d","function":{"name":"get_price","arguments":"{\"symbol\":\"BTCUSDT\"}"}}]}}]}`, len(choices))), nil
+	}
+
+	client := NewClient(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(*Client)
+
+	var calls []string
+	req := NewRequestBuilder().WithUserPrompt("decide").MustBuild()
+	_, err := client.CallWithTools(context.Background(), req, newPriceRegistry(t, &calls), ToolLoopLimits{MaxIterations: 3})
+	if err == nil {
+		t.Fatal("expected error when the model never stops calling tools")
+	}
+	if len(choices) != 3 || choices[2] != "none" {
+		t.Errorf("expected 3 turns with tools disabled on the last, got %v", choices)
+	}
+	if len(calls) != 2 {
+		t.Errorf("expected 2 executed tool calls, got %d", len(calls))
+	}
+}
+
+func TestCallWithTools_Timeout(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+
+	client := NewClient(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	).(*Client)
+
+	var calls []string
+	req := NewRequestBuilder().WithUserPrompt("decide").MustBuild()
+	start := time.Now()
+	_, err := client.CallWithTools(context.Background(), req, newPriceRegistry(t, &calls), ToolLoopLimits{Timeout: 50 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "time budget") {
+		t.Fatalf("expected time budget error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("loop did not respect its time budget: %v", time.Since(start))
+	}
+}
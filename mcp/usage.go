@@ -0,0 +1,127 @@
+package mcp
+
+// Usage attribution sources
+const (
+	UsageSourceTrader       = "trader"        // Live/paper trader decision cycles (SourceID = trader ID)
+	UsageSourceBacktest     = "backtest"      // Backtest runs (SourceID = run ID)
+	UsageSourceDebate       = "debate"        // Debate sessions (SourceID = session ID)
+	UsageSourceStrategyTest = "strategy_test" // Strategy editor test calls (SourceID = AI model ID)
+	UsageSourceReplay       = "replay"        // Decision replays (SourceID = replay ID)
+)
+
+// UsageAttribution identifies who an AI call is billed to
+type UsageAttribution struct {
+	UserID   string
+	Source   string // One of the UsageSource* constants
+	SourceID string
+}
+
+// UsageAttributor is implemented by clients that tag reported token usage
+type UsageAttributor interface {
+	SetUsageAttribution(attribution UsageAttribution)
+}
+
+// SetUsageAttribution attaches attribution to all token usage reported by this client.
+// Set it before the client is shared; it is not synchronized with in-flight calls.
+func (client *Client) SetUsageAttribution(attribution UsageAttribution) {
+	client.attribution = attribution
+}
+
+// UsageAttribution returns the attribution attached to this client
+func (client *Client) UsageAttribution() UsageAttribution {
+	return client.attribution
+}
+
+// AttributeUsage sets attribution on any client that supports it (no-op otherwise)
+func AttributeUsage(client AIClient, attribution UsageAttribution) {
+	if attributor, ok := client.(UsageAttributor); ok {
+		attributor.SetUsageAttribution(attribution)
+	}
+}
+
+// reportTokenUsage forwards token counts from a parsed response to TokenUsageCallback
+func (client *Client) reportTokenUsage(promptTokens, completionTokens, totalTokens int) {
+	if TokenUsageCallback == nil || totalTokens <= 0 {
+		return
+	}
+	TokenUsageCallback(TokenUsage{
+		Provider:         client.Provider,
+		Model:            client.Model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      totalTokens,
+		Attribution:      client.attribution,
+	})
+}
+
+// Clone returns an independent copy of the client with hooks bound to the copy, so its own
+// API key and attribution are used. The copy behaves as a plain OpenAI-compatible client:
+// use CloneClient to keep the overrides of a provider client.
+func (client *Client) Clone() *Client {
+	cp := *client
+	cp.hooks = &cp
+	return &cp
+}
+
+// CloneClient returns an independent copy of a client with its provider hooks rebound to the copy,
+// so the copy keeps the provider wire format but uses its own API key and attribution.
+// Returns nil for clients it cannot copy.
+func CloneClient(client AIClient) AIClient {
+	switch c := client.(type) {
+	case *Client:
+		if c != nil {
+			return c.Clone()
+		}
+	case *DeepSeekClient:
+		if c != nil && c.Client != nil {
+			cp := &DeepSeekClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	case *QwenClient:
+		if c != nil && c.Client != nil {
+			cp := &QwenClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	case *ClaudeClient:
+		if c != nil && c.Client != nil {
+			cp := &ClaudeClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	case *KimiClient:
+		if c != nil && c.Client != nil {
+			cp := &KimiClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	case *GeminiClient:
+		if c != nil && c.Client != nil {
+			cp := &GeminiClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	case *GrokClient:
+		if c != nil && c.Client != nil {
+			cp := &GrokClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	case *OpenAIClient:
+		if c != nil && c.Client != nil {
+			cp := &OpenAIClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	case *LocalClient:
+		if c != nil && c.Client != nil {
+			c.modelMu.Lock() // The model may be being discovered
+			defer c.modelMu.Unlock()
+			cp := &LocalClient{Client: c.Client.Clone()}
+			cp.hooks = cp
+			return cp
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"net/http"
+	"testing"
+)
+
+const usageResponse = `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":120,"completion_tokens":30,"total_tokens":150}}`
+
+func captureTokenUsage(t *testing.T) *[]TokenUsage {
+	t.Helper()
+	var reported []TokenUsage
+	previous := TokenUsageCallback
+	TokenUsageCallback = func(usage TokenUsage) { reported = append(reported, usage) }
+	t.Cleanup(func() { TokenUsageCallback = previous })
+	return &reported
+}
+
+func TestTokenUsageAttribution(t *testing.T) {
+	reported := captureTokenUsage(t)
+
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(usageResponse), nil
+	}
+	client := NewDeepSeekClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("test-key"),
+	)
+	attribution := UsageAttribution{UserID: "user-1", Source: UsageSourceTrader, SourceID: "trader-1"}
+	AttributeUsage(client, attribution)
+
+	if _, err := client.CallWithMessages("sys", "user"); err != nil {
+		t.Fatalf("CallWithMessages: %v", err)
+	}
+	if len(*reported) != 1 {
+		t.Fatalf("expected 1 usage report, got %d", len(*reported))
+	}
+	usage := (*reported)[0]
+	if usage.Attribution != attribution {
+		t.Errorf("unexpected attribution: %+v", usage.Attribution)
+	}
+	if usage.Provider != ProviderDeepSeek || usage.PromptTokens != 120 || usage.CompletionTokens != 30 || usage.TotalTokens != 150 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestCloneReportsOwnAttribution(t *testing.T) {
+	reported := captureTokenUsage(t)
+
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.ResponseFunc = func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(usageResponse), nil
+	}
+	base := NewDeepSeekClientWithOptions(
+		WithHTTPClient(mockHTTP.ToHTTPClient()),
+		WithLogger(NewMockLogger()),
+		WithAPIKey("base-key"),
+	).(*DeepSeekClient)
+	base.SetUsageAttribution(UsageAttribution{Source: UsageSourceTrader, SourceID: "base"})
+
+	clone := base.Client.Clone()
+	clone.SetAPIKey("clone-key", "https://llm.example.com/v1", "clone-model")
+	clone.SetUsageAttribution(UsageAttribution{Source: UsageSourceBacktest, SourceID: "run-1"})
+
+	if _, err := clone.CallWithMessages("sys", "user"); err != nil {
+		t.Fatalf("CallWithMessages: %v", err)
+	}
+	if got := (*reported)[0].Attribution.SourceID; got != "run-1" {
+		t.Errorf("clone usage attributed to %q", got)
+	}
+	if auth := mockHTTP.GetRequests()[0].Header.Get("Authorization"); auth != "Bearer clone-key" {
+		t.Errorf("clone should authenticate with its own key, got %q", auth)
+	}
+	if base.UsageAttribution().SourceID != "base" || base.APIKey != "base-key" {
+		t.Error("cloning should not modify the base client")
+	}
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AIUsageStore LLM token usage and model pricing storage
+// All time fields use int64 millisecond timestamps (UTC) to avoid timezone issues
+type AIUsageStore struct {
+	db *gorm.DB
+}
+
+// AIUsageRecord token usage of a single AI call
+type AIUsageRecord struct {
+	ID               int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           string `gorm:"column:user_id;not null;default:'';index:idx_ai_usage_user_day,priority:1" json:"user_id"`
+	Source           string `gorm:"column:source;not null;default:'';index:idx_ai_usage_source,priority:1" json:"source"` // trader/backtest/debate/strategy_test
+	SourceID         string `gorm:"column:source_id;not null;default:'';index:idx_ai_usage_source,priority:2" json:"source_id"`
+	Provider         string `gorm:"column:provider;not null;default:''" json:"provider"`
+	Model            string `gorm:"column:model;not null;default:''" json:"model"`
+	PromptTokens     int    `gorm:"column:prompt_tokens;not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int    `gorm:"column:completion_tokens;not null;default:0" json:"completion_tokens"`
+	Day              string `gorm:"column:day;not null;index:idx_ai_usage_user_day,priority:2" json:"day"` // YYYY-MM-DD (UTC), kept for portable per-day grouping
+	CreatedAt        int64  `gorm:"column:created_at;index:idx_ai_usage_created" json:"created_at"`        // Unix milliseconds UTC
+}
+
+// TableName returns the table name for AIUsageRecord
+func (AIUsageRecord) TableName() string {
+	return "ai_usage"
+}
+
+// AIModelPrice USD price per million tokens for a model
+type AIModelPrice struct {
+	Model            string  `gorm:"column:model;primaryKey" json:"model"`
+	InputPerMillion  float64 `gorm:"column:input_per_million;not null;default:0" json:"input_per_million"`
+	OutputPerMillion float64 `gorm:"column:output_per_million;not null;default:0" json:"output_per_million"`
+	UpdatedAt        int64   `gorm:"column:updated_at" json:"updated_at"` // Unix milliseconds UTC
+}
+
+// TableName returns the table name for AIModelPrice
+func (AIModelPrice) TableName() string {
+	return "ai_model_prices"
+}
+
+// Cost returns the USD cost of the given token counts
+func (p AIModelPrice) Cost(promptTokens, completionTokens int64) float64 {
+	return (float64(promptTokens)*p.InputPerMillion + float64(completionTokens)*p.OutputPerMillion) / 1e6
+}
+
+// defaultModelPrices list prices (USD per 1M tokens) seeded on first start; edit them via the API
+var defaultModelPrices = []AIModelPrice{
+	{Model: "deepseek-chat", InputPerMillion: 0.28, OutputPerMillion: 0.42},
+	{Model: "deepseek-reasoner", InputPerMillion: 0.28, OutputPerMillion: 0.42},
+	{Model: "qwen3-max", InputPerMillion: 1.2, OutputPerMillion: 6},
+	{Model: "gpt-4o", InputPerMillion: 2.5, OutputPerMillion: 10},
+	{Model: "gpt-4o-mini", InputPerMillion: 0.15, OutputPerMillion: 0.6},
+	{Model: "gemini-2.5-pro", InputPerMillion: 1.25, OutputPerMillion: 10},
+	{Model: "gemini-2.5-flash", InputPerMillion: 0.3, OutputPerMillion: 2.5},
+	{Model: "grok-3-latest", InputPerMillion: 3, OutputPerMillion: 15},
+}
+
+// AIUsageDaily aggregated usage of one source and model on one day
+type AIUsageDaily struct {
+	Day              string  `json:"day"`
+	Source           string  `json:"source"`
+	SourceID         string  `json:"source_id"`
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+	Priced           bool    `json:"priced"` // false if the model has no price configured (cost reported as 0)
+}
+
+// AIUsageFilter selects usage records; empty fields match everything
+type AIUsageFilter struct {
+	UserID   string
+	Source   string
+	SourceID string
+	Since    int64 // Unix milliseconds UTC, 0 = no lower bound
+}
+
+// NewAIUsageStore creates a new AIUsageStore
+func NewAIUsageStore(db *gorm.DB) *AIUsageStore {
+	return &AIUsageStore{db: db}
+}
+
+// initTables initializes AI usage tables
+func (s *AIUsageStore) initTables() error {
+	return s.db.AutoMigrate(&AIUsageRecord{}, &AIModelPrice{})
+}
+
+// initDefaultData seeds default model prices without overwriting user edits
+func (s *AIUsageStore) initDefaultData() error {
+	now := time.Now().UTC().UnixMilli()
+	for _, price := range defaultModelPrices {
+		price.UpdatedAt = now
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&price).Error; err != nil {
+			return fmt.Errorf("failed to seed price for %s: %w", price.Model, err)
+		}
+	}
+	return nil
+}
+
+// Record saves token usage of one AI call
+func (s *AIUsageStore) Record(record *AIUsageRecord) error {
+	if record.CreatedAt == 0 {
+		record.CreatedAt = time.Now().UTC().UnixMilli()
+	}
+	record.Day = time.UnixMilli(record.CreatedAt).UTC().Format("2006-01-02")
+
+	// Omit ID to let PostgreSQL sequence auto-generate it
+	if err := s.db.Omit("ID").Create(record).Error; err != nil {
+		return fmt.Errorf("failed to save AI usage: %w", err)
+	}
+	return nil
+}
+
+// Daily aggregates usage per day, source and model, priced with the current price table.
+// Results are ordered by day (newest first).
+func (s *AIUsageStore) Daily(filter AIUsageFilter) ([]AIUsageDaily, error) {
+	var rows []AIUsageDaily
+	err := s.filtered(filter).
+		Select("day, source, source_id, provider, model, COUNT(*) AS calls, " +
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, COALESCE(SUM(completion_tokens), 0) AS completion_tokens").
+		Group("day, source, source_id, provider, model").
+		Order("day DESC, source_id, model").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AI usage: %w", err)
+	}
+
+	prices, err := s.Prices()
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if price, ok := prices[strings.ToLower(rows[i].Model)]; ok {
+			rows[i].CostUSD = price.Cost(rows[i].PromptTokens, rows[i].CompletionTokens)
+			rows[i].Priced = true
+		}
+	}
+	return rows, nil
+}
+
+// TotalCost sums the USD cost of matching usage records
+func (s *AIUsageStore) TotalCost(filter AIUsageFilter) (float64, error) {
+	rows, err := s.Daily(filter)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, row := range rows {
+		total += row.CostUSD
+	}
+	return total, nil
+}
+
+func (s *AIUsageStore) filtered(filter AIUsageFilter) *gorm.DB {
+	query := s.db.Model(&AIUsageRecord{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.SourceID != "" {
+		query = query.Where("source_id = ?", filter.SourceID)
+	}
+	if filter.Since > 0 {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	return query
+}
+
+// Prices returns the price table keyed by lowercase model name
+func (s *AIUsageStore) Prices() (map[string]AIModelPrice, error) {
+	var list []AIModelPrice
+	if err := s.db.Find(&list).Error; err != nil {
+		return nil, fmt.Errorf("failed to query model prices: %w", err)
+	}
+	prices := make(map[string]AIModelPrice, len(list))
+	for _, price := range list {
+		prices[strings.ToLower(price.Model)] = price
+	}
+	return prices, nil
+}
+
+// ListPrices returns all model prices ordered by model
+func (s *AIUsageStore) ListPrices() ([]AIModelPrice, error) {
+	var list []AIModelPrice
+	if err := s.db.Order("model").Find(&list).Error; err != nil {
+		return nil, fmt.Errorf("failed to query model prices: %w", err)
+	}
+	return list, nil
+}
+
+// SetPrice creates or updates the price of a model
+func (s *AIUsageStore) SetPrice(price *AIModelPrice) error {
+	price.Model = strings.ToLower(strings.TrimSpace(price.Model))
+	if price.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if price.InputPerMillion < 0 || price.OutputPerMillion < 0 {
+		return fmt.Errorf("prices cannot be negative")
+	}
+	price.UpdatedAt = time.Now().UTC().UnixMilli()
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "model"}},
+		DoUpdates: clause.AssignmentColumns([]string{"input_per_million", "output_per_million", "updated_at"}),
+	}).Create(price).Error
+}
@@ -178,6 +178,7 @@ type BacktestTrade struct {
 	Cycle         int     `gorm:"column:cycle;default:0"`
 	PositionAfter float64 `gorm:"column:position_after;default:0"`
 	Liquidation   bool    `gorm:"column:liquidation;default:false"`
+	CloseReason   string  `gorm:"column:close_reason;default:''"`
 	Note          string  `gorm:"column:note;default:''"`
 }
 
@@ -221,6 +222,7 @@ func (s *BacktestStore) initTables() error {
 			// Fix ts column type from INTEGER to BIGINT (timestamps in milliseconds exceed int4 max)
 			s.db.Exec(`ALTER TABLE backtest_equity ALTER COLUMN ts TYPE BIGINT`)
 			s.db.Exec(`ALTER TABLE backtest_trades ALTER COLUMN ts TYPE BIGINT`)
+			s.db.Exec(`ALTER TABLE backtest_trades ADD COLUMN IF NOT EXISTS close_reason TEXT DEFAULT ''`)
 			s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_backtest_equity_run_ts ON backtest_equity(run_id, ts)`)
 			s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_backtest_trades_run_ts ON backtest_trades(run_id, ts)`)
 			s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_backtest_decisions_run_cycle ON backtest_decisions(run_id, cycle)`)
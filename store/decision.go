@@ -27,9 +27,14 @@ type DecisionRecordDB struct {
 	CandidateCoins      string    `gorm:"column:candidate_coins;default:''"`
 	ExecutionLog        string    `gorm:"column:execution_log;default:''"`
 	Decisions           string    `gorm:"column:decisions;default:'[]'"`
+	AccountState        string    `gorm:"column:account_state;default:'{}'"`
+	Positions           string    `gorm:"column:positions;default:'[]'"`
 	Success             bool      `gorm:"default:false"`
 	ErrorMessage        string    `gorm:"column:error_message;default:''"`
 	AIRequestDurationMs int64     `gorm:"column:ai_request_duration_ms;default:0"`
+	PromptHash          string    `gorm:"column:prompt_hash;default:'';index"`
+	PromptTemplateID    string    `gorm:"column:prompt_template_id;default:''"`
+	PromptVersion       int       `gorm:"column:prompt_version;default:0"`
 	CreatedAt           time.Time `json:"created_at"`
 }
 
@@ -51,6 +56,9 @@ type DecisionRecord struct {
 	Success             bool               `json:"success"`
 	ErrorMessage        string             `json:"error_message"`
 	AIRequestDurationMs int64              `json:"ai_request_duration_ms"`
+	PromptHash          string             `json:"prompt_hash,omitempty"`        // PromptTemplateContent hash of the prompt settings used
+	PromptTemplateID    string             `json:"prompt_template_id,omitempty"` // Set when a prompt experiment assigned a template
+	PromptVersion       int                `json:"prompt_version,omitempty"`
 	AccountState        AccountSnapshot    `json:"account_state"`
 	Positions           []PositionSnapshot `json:"positions"`
 	Decisions           []DecisionAction   `json:"decisions"`
@@ -116,6 +124,13 @@ func (s *DecisionStore) initTables() error {
 		var tableExists int64
 		s.db.Raw(`SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'decision_records'`).Scan(&tableExists)
 		if tableExists > 0 {
+			// Prompt version columns (added later)
+			s.db.Exec(`ALTER TABLE decision_records ADD COLUMN IF NOT EXISTS prompt_hash TEXT DEFAULT ''`)
+			s.db.Exec(`ALTER TABLE decision_records ADD COLUMN IF NOT EXISTS prompt_template_id TEXT DEFAULT ''`)
+			s.db.Exec(`ALTER TABLE decision_records ADD COLUMN IF NOT EXISTS prompt_version INTEGER DEFAULT 0`)
+			// Account and position snapshot columns (added later, used by decision replay)
+			s.db.Exec(`ALTER TABLE decision_records ADD COLUMN IF NOT EXISTS account_state TEXT DEFAULT '{}'`)
+			s.db.Exec(`ALTER TABLE decision_records ADD COLUMN IF NOT EXISTS positions TEXT DEFAULT '[]'`)
 			return nil
 		}
 	}
@@ -137,10 +152,15 @@ func (db *DecisionRecordDB) toRecord() *DecisionRecord {
 		Success:             db.Success,
 		ErrorMessage:        db.ErrorMessage,
 		AIRequestDurationMs: db.AIRequestDurationMs,
+		PromptHash:          db.PromptHash,
+		PromptTemplateID:    db.PromptTemplateID,
+		PromptVersion:       db.PromptVersion,
 	}
 	json.Unmarshal([]byte(db.CandidateCoins), &record.CandidateCoins)
 	json.Unmarshal([]byte(db.ExecutionLog), &record.ExecutionLog)
 	json.Unmarshal([]byte(db.Decisions), &record.Decisions)
+	json.Unmarshal([]byte(db.AccountState), &record.AccountState)
+	json.Unmarshal([]byte(db.Positions), &record.Positions)
 	return record
 }
 
@@ -156,6 +176,8 @@ func (s *DecisionStore) LogDecision(record *DecisionRecord) error {
 	candidateCoinsJSON, _ := json.Marshal(record.CandidateCoins)
 	executionLogJSON, _ := json.Marshal(record.ExecutionLog)
 	decisionsJSON, _ := json.Marshal(record.Decisions)
+	accountStateJSON, _ := json.Marshal(record.AccountState)
+	positionsJSON, _ := json.Marshal(record.Positions)
 
 	dbRecord := &DecisionRecordDB{
 		TraderID:            record.TraderID,
@@ -169,9 +191,14 @@ func (s *DecisionStore) LogDecision(record *DecisionRecord) error {
 		CandidateCoins:      string(candidateCoinsJSON),
 		ExecutionLog:        string(executionLogJSON),
 		Decisions:           string(decisionsJSON),
+		AccountState:        string(accountStateJSON),
+		Positions:           string(positionsJSON),
 		Success:             record.Success,
 		ErrorMessage:        record.ErrorMessage,
 		AIRequestDurationMs: record.AIRequestDurationMs,
+		PromptHash:          record.PromptHash,
+		PromptTemplateID:    record.PromptTemplateID,
+		PromptVersion:       record.PromptVersion,
 	}
 
 	if err := s.db.Create(dbRecord).Error; err != nil {
@@ -246,6 +273,24 @@ func (s *DecisionStore) GetRecordsByDate(traderID string, date time.Time) ([]*De
 	return records, nil
 }
 
+// GetRecordsBetween gets up to limit records of a trader within [start, end], oldest first
+func (s *DecisionStore) GetRecordsBetween(traderID string, start, end time.Time, limit int) ([]*DecisionRecord, error) {
+	var dbRecords []*DecisionRecordDB
+	err := s.db.Where("trader_id = ? AND timestamp >= ? AND timestamp <= ?", traderID, start.UTC(), end.UTC()).
+		Order("timestamp ASC").
+		Limit(limit).
+		Find(&dbRecords).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query decision records: %w", err)
+	}
+
+	records := make([]*DecisionRecord, len(dbRecords))
+	for i, db := range dbRecords {
+		records[i] = db.toRecord()
+	}
+	return records, nil
+}
+
 // CleanOldRecords cleans old records from N days ago
 func (s *DecisionStore) CleanOldRecords(traderID string, days int) (int64, error) {
 	cutoffTime := time.Now().AddDate(0, 0, -days)
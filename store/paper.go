@@ -0,0 +1,383 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaperStore simulated exchange storage (paper trading accounts, positions, orders and closed PnL)
+// All time fields use int64 millisecond timestamps (UTC) to avoid timezone issues
+type PaperStore struct {
+	db *gorm.DB
+}
+
+// PaperAccount simulated account balance for a paper trader
+type PaperAccount struct {
+	TraderID       string  `gorm:"column:trader_id;primaryKey" json:"trader_id"`
+	InitialBalance float64 `gorm:"column:initial_balance;not null;default:0" json:"initial_balance"`
+	Cash           float64 `gorm:"column:cash;not null;default:0" json:"cash"` // Wallet balance excluding margin locked in positions
+	RealizedPnL    float64 `gorm:"column:realized_pnl;not null;default:0" json:"realized_pnl"`
+	TotalFees      float64 `gorm:"column:total_fees;not null;default:0" json:"total_fees"`
+	CreatedAt      int64   `gorm:"column:created_at" json:"created_at"` // Unix milliseconds UTC
+	UpdatedAt      int64   `gorm:"column:updated_at" json:"updated_at"` // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PaperAccount
+func (PaperAccount) TableName() string {
+	return "paper_accounts"
+}
+
+// PaperPosition simulated open position
+type PaperPosition struct {
+	ID               int64   `gorm:"primaryKey;autoIncrement" json:"id"`
+	TraderID         string  `gorm:"column:trader_id;not null;uniqueIndex:idx_paper_pos_unique,priority:1" json:"trader_id"`
+	Symbol           string  `gorm:"column:symbol;not null;uniqueIndex:idx_paper_pos_unique,priority:2" json:"symbol"`
+	Side             string  `gorm:"column:side;not null;uniqueIndex:idx_paper_pos_unique,priority:3" json:"side"` // long/short
+	Quantity         float64 `gorm:"column:quantity;not null;default:0" json:"quantity"`
+	EntryPrice       float64 `gorm:"column:entry_price;not null;default:0" json:"entry_price"`
+	Leverage         int     `gorm:"column:leverage;not null;default:1" json:"leverage"`
+	Margin           float64 `gorm:"column:margin;not null;default:0" json:"margin"`
+	AccumulatedFee   float64 `gorm:"column:accumulated_fee;not null;default:0" json:"accumulated_fee"` // Opening fees not yet attributed to a close
+	LiquidationPrice float64 `gorm:"column:liquidation_price;default:0" json:"liquidation_price"`
+	CreatedAt        int64   `gorm:"column:created_at" json:"created_at"` // Unix milliseconds UTC
+	UpdatedAt        int64   `gorm:"column:updated_at" json:"updated_at"` // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PaperPosition
+func (PaperPosition) TableName() string {
+	return "paper_positions"
+}
+
+// PaperOrder simulated order (market, limit, stop-loss and take-profit)
+type PaperOrder struct {
+	ID           int64   `gorm:"primaryKey;autoIncrement" json:"id"`
+	TraderID     string  `gorm:"column:trader_id;not null;index:idx_paper_orders_trader" json:"trader_id"`
+	OrderID      string  `gorm:"column:order_id;not null;uniqueIndex:idx_paper_orders_order_id" json:"order_id"`
+	ClientID     string  `gorm:"column:client_id;default:''" json:"client_id"`
+	Symbol       string  `gorm:"column:symbol;not null" json:"symbol"`
+	Side         string  `gorm:"column:side;not null" json:"side"`                     // BUY/SELL
+	PositionSide string  `gorm:"column:position_side;default:''" json:"position_side"` // LONG/SHORT
+	Type         string  `gorm:"column:type;not null" json:"type"`                     // MARKET/LIMIT/STOP_MARKET/TAKE_PROFIT_MARKET
+	Price        float64 `gorm:"column:price;default:0" json:"price"`
+	StopPrice    float64 `gorm:"column:stop_price;default:0" json:"stop_price"`
+	Quantity     float64 `gorm:"column:quantity;not null" json:"quantity"`
+	ExecutedQty  float64 `gorm:"column:executed_qty;default:0" json:"executed_qty"`
+	AvgPrice     float64 `gorm:"column:avg_price;default:0" json:"avg_price"`
+	Commission   float64 `gorm:"column:commission;default:0" json:"commission"`
+	Leverage     int     `gorm:"column:leverage;default:1" json:"leverage"`
+	ReduceOnly   bool    `gorm:"column:reduce_only;default:false" json:"reduce_only"`
+	Status       string  `gorm:"column:status;not null;default:NEW;index:idx_paper_orders_status" json:"status"` // NEW/PARTIALLY_FILLED/FILLED/CANCELED/REJECTED
+	CreatedAt    int64   `gorm:"column:created_at" json:"created_at"`                                            // Unix milliseconds UTC
+	UpdatedAt    int64   `gorm:"column:updated_at" json:"updated_at"`                                            // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PaperOrder
+func (PaperOrder) TableName() string {
+	return "paper_orders"
+}
+
+// PaperClosedPnL simulated closed position record
+type PaperClosedPnL struct {
+	ID          int64   `gorm:"primaryKey;autoIncrement" json:"id"`
+	TraderID    string  `gorm:"column:trader_id;not null;index:idx_paper_closed_trader_time,priority:1" json:"trader_id"`
+	Symbol      string  `gorm:"column:symbol;not null" json:"symbol"`
+	Side        string  `gorm:"column:side;not null" json:"side"` // long/short
+	EntryPrice  float64 `gorm:"column:entry_price;not null" json:"entry_price"`
+	ExitPrice   float64 `gorm:"column:exit_price;not null" json:"exit_price"`
+	Quantity    float64 `gorm:"column:quantity;not null" json:"quantity"`
+	RealizedPnL float64 `gorm:"column:realized_pnl;not null" json:"realized_pnl"`
+	Fee         float64 `gorm:"column:fee;default:0" json:"fee"`
+	Leverage    int     `gorm:"column:leverage;default:1" json:"leverage"`
+	OrderID     string  `gorm:"column:order_id;default:''" json:"order_id"`
+	CloseType   string  `gorm:"column:close_type;default:'manual'" json:"close_type"`                            // manual/stop_loss/take_profit/liquidation
+	EntryTime   int64   `gorm:"column:entry_time" json:"entry_time"`                                             // Unix milliseconds UTC
+	ExitTime    int64   `gorm:"column:exit_time;index:idx_paper_closed_trader_time,priority:2" json:"exit_time"` // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PaperClosedPnL
+func (PaperClosedPnL) TableName() string {
+	return "paper_closed_pnl"
+}
+
+// PaperFill simulated execution (one per position change, used by order sync)
+type PaperFill struct {
+	ID           int64   `gorm:"primaryKey;autoIncrement" json:"id"`
+	TraderID     string  `gorm:"column:trader_id;not null;index:idx_paper_fills_trader_time,priority:1" json:"trader_id"`
+	TradeID      string  `gorm:"column:trade_id;not null;uniqueIndex:idx_paper_fills_trade_id" json:"trade_id"`
+	OrderID      string  `gorm:"column:order_id;not null" json:"order_id"`
+	Symbol       string  `gorm:"column:symbol;not null" json:"symbol"`
+	Side         string  `gorm:"column:side;not null" json:"side"`                   // BUY/SELL
+	PositionSide string  `gorm:"column:position_side;not null" json:"position_side"` // LONG/SHORT
+	OrderAction  string  `gorm:"column:order_action;not null" json:"order_action"`   // open_long/open_short/close_long/close_short
+	Price        float64 `gorm:"column:price;not null" json:"price"`
+	Quantity     float64 `gorm:"column:quantity;not null" json:"quantity"`
+	RealizedPnL  float64 `gorm:"column:realized_pnl;default:0" json:"realized_pnl"`
+	Fee          float64 `gorm:"column:fee;default:0" json:"fee"`
+	IsMaker      bool    `gorm:"column:is_maker;default:false" json:"is_maker"`
+	CreatedAt    int64   `gorm:"column:created_at;index:idx_paper_fills_trader_time,priority:2" json:"created_at"` // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PaperFill
+func (PaperFill) TableName() string {
+	return "paper_fills"
+}
+
+// NewPaperStore creates paper trading storage instance
+func NewPaperStore(db *gorm.DB) *PaperStore {
+	return &PaperStore{db: db}
+}
+
+// Transaction runs fn against a PaperStore bound to one database transaction,
+// so the writes of a simulated fill are committed together or not at all
+func (s *PaperStore) Transaction(fn func(tx *PaperStore) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&PaperStore{db: tx})
+	})
+}
+
+// InitTables initializes paper trading tables
+func (s *PaperStore) InitTables() error {
+	// For PostgreSQL with existing tables, skip AutoMigrate
+	if s.db.Dialector.Name() == "postgres" {
+		var tableExists int64
+		s.db.Raw(`SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'paper_closed_pnl'`).Scan(&tableExists)
+		if tableExists > 0 {
+			return nil
+		}
+	}
+	return s.db.AutoMigrate(&PaperAccount{}, &PaperPosition{}, &PaperOrder{}, &PaperFill{}, &PaperClosedPnL{})
+}
+
+// GetOrCreateAccount gets the paper account for a trader, creating it with initialBalance if absent
+func (s *PaperStore) GetOrCreateAccount(traderID string, initialBalance float64) (*PaperAccount, error) {
+	var account PaperAccount
+	err := s.db.Where("trader_id = ?", traderID).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to query paper account: %w", err)
+	}
+
+	now := time.Now().UTC().UnixMilli()
+	account = PaperAccount{
+		TraderID:       traderID,
+		InitialBalance: initialBalance,
+		Cash:           initialBalance,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.db.Create(&account).Error; err != nil {
+		return nil, fmt.Errorf("failed to create paper account: %w", err)
+	}
+	return &account, nil
+}
+
+// LockAccount gets the paper account for a trader like GetOrCreateAccount, locking its row until
+// the transaction ends. Run inside Transaction: writers of a trader's account and positions take
+// this lock first, so their read-modify-write cycles don't overwrite each other.
+func (s *PaperStore) LockAccount(traderID string, initialBalance float64) (*PaperAccount, error) {
+	var account PaperAccount
+	err := s.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("trader_id = ?", traderID).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to lock paper account: %w", err)
+	}
+	// A new row stays locked by this transaction until it ends
+	return s.GetOrCreateAccount(traderID, initialBalance)
+}
+
+// SaveAccount persists account balances
+func (s *PaperStore) SaveAccount(account *PaperAccount) error {
+	account.UpdatedAt = time.Now().UTC().UnixMilli()
+	if err := s.db.Save(account).Error; err != nil {
+		return fmt.Errorf("failed to save paper account: %w", err)
+	}
+	return nil
+}
+
+// ResetAccount deletes all simulated state for a trader and restores the initial balance
+func (s *PaperStore) ResetAccount(traderID string, initialBalance float64) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&PaperPosition{}, &PaperOrder{}, &PaperFill{}, &PaperClosedPnL{}, &PaperAccount{}} {
+			if err := tx.Where("trader_id = ?", traderID).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to reset paper account: %w", err)
+			}
+		}
+		now := time.Now().UTC().UnixMilli()
+		return tx.Create(&PaperAccount{
+			TraderID:       traderID,
+			InitialBalance: initialBalance,
+			Cash:           initialBalance,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}).Error
+	})
+}
+
+// ListPositions gets all open paper positions for a trader
+func (s *PaperStore) ListPositions(traderID string) ([]*PaperPosition, error) {
+	var positions []*PaperPosition
+	if err := s.db.Where("trader_id = ? AND quantity > 0", traderID).Order("created_at ASC").Find(&positions).Error; err != nil {
+		return nil, fmt.Errorf("failed to query paper positions: %w", err)
+	}
+	return positions, nil
+}
+
+// GetPosition gets the open position for trader/symbol/side, returns nil if none
+func (s *PaperStore) GetPosition(traderID, symbol, side string) (*PaperPosition, error) {
+	var pos PaperPosition
+	err := s.db.Where("trader_id = ? AND symbol = ? AND side = ?", traderID, symbol, side).First(&pos).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query paper position: %w", err)
+	}
+	if pos.Quantity <= 0 {
+		return nil, nil
+	}
+	return &pos, nil
+}
+
+// SavePosition creates or updates a position, deleting it once fully closed
+func (s *PaperStore) SavePosition(pos *PaperPosition) error {
+	now := time.Now().UTC().UnixMilli()
+	if pos.Quantity <= 0 {
+		if pos.ID == 0 {
+			return nil
+		}
+		if err := s.db.Delete(&PaperPosition{}, pos.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete paper position: %w", err)
+		}
+		return nil
+	}
+	pos.UpdatedAt = now
+	if pos.ID == 0 {
+		pos.CreatedAt = now
+		if err := s.db.Omit("ID").Create(pos).Error; err != nil {
+			return fmt.Errorf("failed to create paper position: %w", err)
+		}
+		return nil
+	}
+	if err := s.db.Save(pos).Error; err != nil {
+		return fmt.Errorf("failed to update paper position: %w", err)
+	}
+	return nil
+}
+
+// CreateOrder records a new paper order
+func (s *PaperStore) CreateOrder(order *PaperOrder) error {
+	now := time.Now().UTC().UnixMilli()
+	if order.CreatedAt == 0 {
+		order.CreatedAt = now
+	}
+	order.UpdatedAt = now
+	if err := s.db.Omit("ID").Create(order).Error; err != nil {
+		return fmt.Errorf("failed to create paper order: %w", err)
+	}
+	return nil
+}
+
+// UpdateOrder persists order status and fill data
+func (s *PaperStore) UpdateOrder(order *PaperOrder) error {
+	order.UpdatedAt = time.Now().UTC().UnixMilli()
+	if err := s.db.Save(order).Error; err != nil {
+		return fmt.Errorf("failed to update paper order: %w", err)
+	}
+	return nil
+}
+
+// GetOrder gets a paper order by its order ID
+func (s *PaperStore) GetOrder(traderID, orderID string) (*PaperOrder, error) {
+	var order PaperOrder
+	if err := s.db.Where("trader_id = ? AND order_id = ?", traderID, orderID).First(&order).Error; err != nil {
+		return nil, fmt.Errorf("failed to query paper order: %w", err)
+	}
+	return &order, nil
+}
+
+// openOrderStatuses statuses of orders still resting in the simulated book
+var openOrderStatuses = []string{"NEW", "PARTIALLY_FILLED"}
+
+// ListOpenOrders gets pending paper orders, symbol empty means all symbols
+func (s *PaperStore) ListOpenOrders(traderID, symbol string) ([]*PaperOrder, error) {
+	var orders []*PaperOrder
+	query := s.db.Where("trader_id = ? AND status IN ?", traderID, openOrderStatuses)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	if err := query.Order("created_at ASC").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to query paper open orders: %w", err)
+	}
+	return orders, nil
+}
+
+// CancelOrders cancels pending orders for a symbol matching any of the given types (all types if empty)
+func (s *PaperStore) CancelOrders(traderID, symbol string, types ...string) (int64, error) {
+	query := s.db.Model(&PaperOrder{}).Where("trader_id = ? AND symbol = ? AND status IN ?", traderID, symbol, openOrderStatuses)
+	if len(types) > 0 {
+		query = query.Where("type IN ?", types)
+	}
+	result := query.Updates(map[string]interface{}{
+		"status":     "CANCELED",
+		"updated_at": time.Now().UTC().UnixMilli(),
+	})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to cancel paper orders: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// CreateFill records a simulated execution
+func (s *PaperStore) CreateFill(fill *PaperFill) error {
+	if fill.CreatedAt == 0 {
+		fill.CreatedAt = time.Now().UTC().UnixMilli()
+	}
+	if err := s.db.Omit("ID").Create(fill).Error; err != nil {
+		return fmt.Errorf("failed to create paper fill: %w", err)
+	}
+	return nil
+}
+
+// ListFills gets simulated executions since startTime (ascending by time)
+func (s *PaperStore) ListFills(traderID string, startTime time.Time, limit int) ([]*PaperFill, error) {
+	var fills []*PaperFill
+	query := s.db.Where("trader_id = ? AND created_at >= ?", traderID, startTime.UTC().UnixMilli()).
+		Order("created_at ASC, id ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&fills).Error; err != nil {
+		return nil, fmt.Errorf("failed to query paper fills: %w", err)
+	}
+	return fills, nil
+}
+
+// CreateClosedPnL records a closed paper position
+func (s *PaperStore) CreateClosedPnL(record *PaperClosedPnL) error {
+	if err := s.db.Omit("ID").Create(record).Error; err != nil {
+		return fmt.Errorf("failed to create paper closed pnl: %w", err)
+	}
+	return nil
+}
+
+// ListClosedPnL gets closed paper positions since startTime (ascending by exit time)
+func (s *PaperStore) ListClosedPnL(traderID string, startTime time.Time, limit int) ([]*PaperClosedPnL, error) {
+	var records []*PaperClosedPnL
+	query := s.db.Where("trader_id = ? AND exit_time >= ?", traderID, startTime.UTC().UnixMilli()).
+		Order("exit_time ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to query paper closed pnl: %w", err)
+	}
+	return records, nil
+}
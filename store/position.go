@@ -433,6 +433,34 @@ func (s *PositionStore) GetPositionStats(traderID string) (map[string]interface{
 	return stats, nil
 }
 
+// ClosedTradeCounts closed position counts over a time window
+type ClosedTradeCounts struct {
+	Total    int     `gorm:"column:total" json:"total_trades"`
+	Wins     int     `gorm:"column:wins" json:"profitable_trades"`
+	TotalPnL float64 `gorm:"column:total_pnl" json:"realized_pnl"`
+}
+
+// GetClosedTradeCounts counts closed positions (and profitable ones) exited since sinceMs (0 = all time)
+func (s *PositionStore) GetClosedTradeCounts(traderID string, sinceMs int64) (*ClosedTradeCounts, error) {
+	return s.GetClosedTradeCountsBetween(traderID, sinceMs, 0)
+}
+
+// GetClosedTradeCountsBetween counts closed positions exited in [sinceMs, untilMs) (untilMs 0 = no upper bound)
+func (s *PositionStore) GetClosedTradeCountsBetween(traderID string, sinceMs, untilMs int64) (*ClosedTradeCounts, error) {
+	var r ClosedTradeCounts
+	query := s.db.Model(&TraderPosition{}).
+		Select("COUNT(*) as total, COALESCE(SUM(CASE WHEN realized_pnl > 0 THEN 1 ELSE 0 END), 0) as wins, COALESCE(SUM(realized_pnl), 0) as total_pnl").
+		Where("trader_id = ? AND status = ? AND exit_time >= ?", traderID, "CLOSED", sinceMs)
+	if untilMs > 0 {
+		query = query.Where("exit_time < ?", untilMs)
+	}
+	err := query.Scan(&r).Error
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
 // GetFullStats gets complete trading statistics
 func (s *PositionStore) GetFullStats(traderID string) (*TraderStats, error) {
 	stats := &TraderStats{}
@@ -0,0 +1,422 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PromptTemplateStore versioned prompt templates and prompt experiments storage
+// All time fields use int64 millisecond timestamps (UTC) to avoid timezone issues
+type PromptTemplateStore struct {
+	db *gorm.DB
+}
+
+// PromptTemplateContent the prompt settings a template version pins
+type PromptTemplateContent struct {
+	PromptVariant      string               `json:"prompt_variant,omitempty"`
+	PromptSections     PromptSectionsConfig `json:"prompt_sections"`
+	CustomPrompt       string               `json:"custom_prompt,omitempty"`
+	OverrideBasePrompt bool                 `json:"override_base_prompt,omitempty"`
+}
+
+// PromptContentOf returns the prompt settings a trader or backtest runs with
+func PromptContentOf(config *StrategyConfig, variant string, overrideBasePrompt bool) PromptTemplateContent {
+	content := PromptTemplateContent{PromptVariant: variant, OverrideBasePrompt: overrideBasePrompt}
+	if config != nil {
+		content.PromptSections = config.PromptSections
+		content.CustomPrompt = config.CustomPrompt
+	}
+	return content
+}
+
+// Hash returns the SHA-256 hex digest of the content; equal settings always hash equal
+func (c PromptTemplateContent) Hash() string {
+	data, _ := json.Marshal(c) // Struct field order makes the encoding canonical
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ApplyTo writes the template's prompt sections and custom prompt into a strategy config
+func (c PromptTemplateContent) ApplyTo(config *StrategyConfig) {
+	config.PromptSections = c.PromptSections
+	config.CustomPrompt = c.CustomPrompt
+}
+
+// PromptTemplate named prompt template; its content lives in immutable versions
+type PromptTemplate struct {
+	ID             string `gorm:"primaryKey" json:"id"`
+	UserID         string `gorm:"column:user_id;not null;default:'';index" json:"user_id"`
+	Name           string `gorm:"column:name;not null" json:"name"`
+	Description    string `gorm:"column:description;default:''" json:"description"`
+	CurrentVersion int    `gorm:"column:current_version;not null;default:0" json:"current_version"`
+	CreatedAt      int64  `gorm:"column:created_at" json:"created_at"` // Unix milliseconds UTC
+	UpdatedAt      int64  `gorm:"column:updated_at" json:"updated_at"` // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PromptTemplate
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
+
+// PromptTemplateVersion one immutable revision of a prompt template
+type PromptTemplateVersion struct {
+	ID          int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	TemplateID  string `gorm:"column:template_id;not null;uniqueIndex:idx_prompt_template_version,priority:1" json:"template_id"`
+	Version     int    `gorm:"column:version;not null;uniqueIndex:idx_prompt_template_version,priority:2" json:"version"`
+	Content     string `gorm:"column:content;not null;default:'{}'" json:"content"` // PromptTemplateContent JSON
+	ContentHash string `gorm:"column:content_hash;not null;default:'';index" json:"content_hash"`
+	Note        string `gorm:"column:note;default:''" json:"note"`  // What changed, like a commit message
+	CreatedAt   int64  `gorm:"column:created_at" json:"created_at"` // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PromptTemplateVersion
+func (PromptTemplateVersion) TableName() string {
+	return "prompt_template_versions"
+}
+
+// ParseContent parses the version content
+func (v *PromptTemplateVersion) ParseContent() (*PromptTemplateContent, error) {
+	var content PromptTemplateContent
+	if err := json.Unmarshal([]byte(v.Content), &content); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt template content: %w", err)
+	}
+	return &content, nil
+}
+
+// Prompt experiment status
+const (
+	PromptExperimentActive  = "active"
+	PromptExperimentStopped = "stopped"
+)
+
+// Prompt experiment assignment targets
+const (
+	PromptTargetTrader   = "trader"
+	PromptTargetBacktest = "backtest"
+)
+
+// PromptExperimentArm one template version under test
+type PromptExperimentArm struct {
+	TemplateID string `json:"template_id"`
+	Version    int    `json:"version"`
+	Label      string `json:"label,omitempty"` // e.g. "A"/"B"; defaults to "<template name> v<version>"
+}
+
+// PromptExperiment compares prompt template versions across traders and backtest runs
+type PromptExperiment struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	UserID      string `gorm:"column:user_id;not null;default:'';index" json:"user_id"`
+	Name        string `gorm:"column:name;not null" json:"name"`
+	Description string `gorm:"column:description;default:''" json:"description"`
+	Status      string `gorm:"column:status;not null;default:active" json:"status"`
+	Arms        string `gorm:"column:arms;not null;default:'[]'" json:"-"`    // []PromptExperimentArm JSON
+	CreatedAt   int64  `gorm:"column:created_at" json:"created_at"`           // Unix milliseconds UTC
+	StoppedAt   int64  `gorm:"column:stopped_at;default:0" json:"stopped_at"` // 0 while active
+
+	ArmList []PromptExperimentArm `gorm:"-" json:"arms"`
+}
+
+// TableName returns the table name for PromptExperiment
+func (PromptExperiment) TableName() string {
+	return "prompt_experiments"
+}
+
+// PromptExperimentAssignment a trader or backtest run running one arm of an experiment
+type PromptExperimentAssignment struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	ExperimentID string `gorm:"column:experiment_id;not null;index" json:"experiment_id"`
+	TemplateID   string `gorm:"column:template_id;not null" json:"template_id"`
+	Version      int    `gorm:"column:version;not null" json:"version"`
+	TargetType   string `gorm:"column:target_type;not null;index:idx_prompt_assignment_target,priority:1" json:"target_type"` // trader/backtest
+	TargetID     string `gorm:"column:target_id;not null;index:idx_prompt_assignment_target,priority:2" json:"target_id"`     // Trader ID or backtest run ID
+	AssignedAt   int64  `gorm:"column:assigned_at" json:"assigned_at"`                                                        // Unix milliseconds UTC
+}
+
+// TableName returns the table name for PromptExperimentAssignment
+func (PromptExperimentAssignment) TableName() string {
+	return "prompt_experiment_assignments"
+}
+
+// NewPromptTemplateStore creates a new PromptTemplateStore
+func NewPromptTemplateStore(db *gorm.DB) *PromptTemplateStore {
+	return &PromptTemplateStore{db: db}
+}
+
+// initTables initializes prompt template and experiment tables
+func (s *PromptTemplateStore) initTables() error {
+	return s.db.AutoMigrate(&PromptTemplate{}, &PromptTemplateVersion{}, &PromptExperiment{}, &PromptExperimentAssignment{})
+}
+
+// CreateTemplate saves a new template with content as version 1
+func (s *PromptTemplateStore) CreateTemplate(template *PromptTemplate, content PromptTemplateContent, note string) (*PromptTemplateVersion, error) {
+	now := time.Now().UTC().UnixMilli()
+	template.CreatedAt = now
+	template.UpdatedAt = now
+	template.CurrentVersion = 0
+
+	var version *PromptTemplateVersion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(template).Error; err != nil {
+			return fmt.Errorf("failed to create prompt template: %w", err)
+		}
+		var err error
+		version, err = commitVersion(tx, template, content, note)
+		return err
+	})
+	return version, err
+}
+
+// ListTemplates returns the user's templates, most recently updated first
+func (s *PromptTemplateStore) ListTemplates(userID string) ([]*PromptTemplate, error) {
+	var templates []*PromptTemplate
+	if err := s.db.Where("user_id = ?", userID).Order("updated_at DESC").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to query prompt templates: %w", err)
+	}
+	return templates, nil
+}
+
+// GetTemplate returns a template of the user
+func (s *PromptTemplateStore) GetTemplate(userID, id string) (*PromptTemplate, error) {
+	var template PromptTemplate
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// UpdateTemplateInfo renames a template or changes its description
+func (s *PromptTemplateStore) UpdateTemplateInfo(userID, id, name, description string) error {
+	return s.db.Model(&PromptTemplate{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Updates(map[string]interface{}{
+			"name":        name,
+			"description": description,
+			"updated_at":  time.Now().UTC().UnixMilli(),
+		}).Error
+}
+
+// DeleteTemplate deletes a template with all its versions
+func (s *PromptTemplateStore) DeleteTemplate(userID, id string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&PromptTemplate{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("template_id = ?", id).Delete(&PromptTemplateVersion{}).Error
+	})
+}
+
+// CommitVersion saves content as the template's next version. Content equal to the current
+// version is not saved again; the current version is returned instead.
+func (s *PromptTemplateStore) CommitVersion(userID, templateID string, content PromptTemplateContent, note string) (*PromptTemplateVersion, error) {
+	var version *PromptTemplateVersion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var template PromptTemplate
+		if err := tx.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+			return err
+		}
+		var err error
+		version, err = commitVersion(tx, &template, content, note)
+		return err
+	})
+	return version, err
+}
+
+// Rollback makes an earlier version current again by committing its content as a new version,
+// so the history is never rewritten
+func (s *PromptTemplateStore) Rollback(userID, templateID string, version int) (*PromptTemplateVersion, error) {
+	target, err := s.GetVersion(templateID, version)
+	if err != nil {
+		return nil, err
+	}
+	content, err := target.ParseContent()
+	if err != nil {
+		return nil, err
+	}
+	return s.CommitVersion(userID, templateID, *content, fmt.Sprintf("Rollback to v%d", target.Version))
+}
+
+func commitVersion(tx *gorm.DB, template *PromptTemplate, content PromptTemplateContent, note string) (*PromptTemplateVersion, error) {
+	hash := content.Hash()
+	if template.CurrentVersion > 0 {
+		var current PromptTemplateVersion
+		err := tx.Where("template_id = ? AND version = ?", template.ID, template.CurrentVersion).First(&current).Error
+		if err == nil && current.ContentHash == hash {
+			return &current, nil
+		}
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize prompt template content: %w", err)
+	}
+	now := time.Now().UTC().UnixMilli()
+	version := &PromptTemplateVersion{
+		TemplateID:  template.ID,
+		Version:     template.CurrentVersion + 1,
+		Content:     string(data),
+		ContentHash: hash,
+		Note:        strings.TrimSpace(note),
+		CreatedAt:   now,
+	}
+	// Omit ID to let PostgreSQL sequence auto-generate it
+	if err := tx.Omit("ID").Create(version).Error; err != nil {
+		return nil, fmt.Errorf("failed to save prompt template version: %w", err)
+	}
+	if err := tx.Model(&PromptTemplate{}).Where("id = ?", template.ID).
+		Updates(map[string]interface{}{"current_version": version.Version, "updated_at": now}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update prompt template: %w", err)
+	}
+	template.CurrentVersion = version.Version
+	template.UpdatedAt = now
+	return version, nil
+}
+
+// ListVersions returns all versions of a template, newest first
+func (s *PromptTemplateStore) ListVersions(templateID string) ([]*PromptTemplateVersion, error) {
+	var versions []*PromptTemplateVersion
+	if err := s.db.Where("template_id = ?", templateID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to query prompt template versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetVersion returns one version of a template (version <= 0: the current version)
+func (s *PromptTemplateStore) GetVersion(templateID string, version int) (*PromptTemplateVersion, error) {
+	if version <= 0 {
+		var template PromptTemplate
+		if err := s.db.Where("id = ?", templateID).First(&template).Error; err != nil {
+			return nil, err
+		}
+		version = template.CurrentVersion
+	}
+	var v PromptTemplateVersion
+	if err := s.db.Where("template_id = ? AND version = ?", templateID, version).First(&v).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// CreateExperiment saves a new active experiment
+func (s *PromptTemplateStore) CreateExperiment(experiment *PromptExperiment) error {
+	arms, err := json.Marshal(experiment.ArmList)
+	if err != nil {
+		return fmt.Errorf("failed to serialize experiment arms: %w", err)
+	}
+	experiment.Arms = string(arms)
+	experiment.Status = PromptExperimentActive
+	experiment.CreatedAt = time.Now().UTC().UnixMilli()
+	experiment.StoppedAt = 0
+	return s.db.Create(experiment).Error
+}
+
+// ListExperiments returns the user's experiments, newest first
+func (s *PromptTemplateStore) ListExperiments(userID string) ([]*PromptExperiment, error) {
+	var experiments []*PromptExperiment
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&experiments).Error; err != nil {
+		return nil, fmt.Errorf("failed to query prompt experiments: %w", err)
+	}
+	for _, e := range experiments {
+		e.parseArms()
+	}
+	return experiments, nil
+}
+
+// GetExperiment returns an experiment of the user
+func (s *PromptTemplateStore) GetExperiment(userID, id string) (*PromptExperiment, error) {
+	var experiment PromptExperiment
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&experiment).Error; err != nil {
+		return nil, err
+	}
+	experiment.parseArms()
+	return &experiment, nil
+}
+
+func (e *PromptExperiment) parseArms() {
+	json.Unmarshal([]byte(e.Arms), &e.ArmList)
+}
+
+// StopExperiment ends an experiment; its traders return to their strategy prompt on next start
+func (s *PromptTemplateStore) StopExperiment(userID, id string) error {
+	return s.db.Model(&PromptExperiment{}).
+		Where("id = ? AND user_id = ? AND status = ?", id, userID, PromptExperimentActive).
+		Updates(map[string]interface{}{
+			"status":     PromptExperimentStopped,
+			"stopped_at": time.Now().UTC().UnixMilli(),
+		}).Error
+}
+
+// DeleteExperiment deletes an experiment with its assignments
+func (s *PromptTemplateStore) DeleteExperiment(userID, id string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&PromptExperiment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("experiment_id = ?", id).Delete(&PromptExperimentAssignment{}).Error
+	})
+}
+
+// Assign records that a trader or backtest run uses one arm of an active experiment.
+// A target takes part in at most one active experiment; reassigning replaces its arm.
+func (s *PromptTemplateStore) Assign(assignment *PromptExperimentAssignment) error {
+	current, err := s.ActiveAssignment(assignment.TargetType, assignment.TargetID)
+	if err != nil {
+		return err
+	}
+	if current != nil {
+		if current.ExperimentID != assignment.ExperimentID {
+			return fmt.Errorf("%s %s is already in experiment %s", assignment.TargetType, assignment.TargetID, current.ExperimentID)
+		}
+		if err := s.db.Delete(current).Error; err != nil {
+			return fmt.Errorf("failed to replace assignment: %w", err)
+		}
+	}
+
+	assignment.AssignedAt = time.Now().UTC().UnixMilli()
+	// Omit ID to let PostgreSQL sequence auto-generate it
+	if err := s.db.Omit("ID").Create(assignment).Error; err != nil {
+		return fmt.Errorf("failed to save assignment: %w", err)
+	}
+	return nil
+}
+
+// ListAssignments returns the assignments of an experiment in assignment order
+func (s *PromptTemplateStore) ListAssignments(experimentID string) ([]*PromptExperimentAssignment, error) {
+	var assignments []*PromptExperimentAssignment
+	if err := s.db.Where("experiment_id = ?", experimentID).Order("assigned_at ASC, id ASC").Find(&assignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to query assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// ActiveAssignment returns the assignment of a target in an active experiment (nil if it takes part in none)
+func (s *PromptTemplateStore) ActiveAssignment(targetType, targetID string) (*PromptExperimentAssignment, error) {
+	var assignment PromptExperimentAssignment
+	err := s.db.Table("prompt_experiment_assignments AS a").
+		Select("a.*").
+		Joins("JOIN prompt_experiments e ON e.id = a.experiment_id").
+		Where("a.target_type = ? AND a.target_id = ? AND e.status = ?", targetType, targetID, PromptExperimentActive).
+		Order("a.assigned_at DESC").
+		Take(&assignment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query assignment: %w", err)
+	}
+	return &assignment, nil
+}
@@ -29,6 +29,11 @@ type Store struct {
 	equity   *EquityStore
 	order    *OrderStore
 	grid     *GridStore
+	paper    *PaperStore
+	aiUsage  *AIUsageStore
+	prompt   *PromptTemplateStore
+	memory   *TradeMemoryStore
+	trailing *TrailingStopStore
 
 	mu sync.RWMutex
 }
@@ -160,6 +165,21 @@ func (s *Store) initTables() error {
 	if err := s.Grid().InitTables(); err != nil {
 		return fmt.Errorf("failed to initialize grid tables: %w", err)
 	}
+	if err := s.Paper().InitTables(); err != nil {
+		return fmt.Errorf("failed to initialize paper trading tables: %w", err)
+	}
+	if err := s.AIUsage().initTables(); err != nil {
+		return fmt.Errorf("failed to initialize AI usage tables: %w", err)
+	}
+	if err := s.PromptTemplate().initTables(); err != nil {
+		return fmt.Errorf("failed to initialize prompt template tables: %w", err)
+	}
+	if err := s.TradeMemory().initTables(); err != nil {
+		return fmt.Errorf("failed to initialize trade memory tables: %w", err)
+	}
+	if err := s.TrailingStop().initTables(); err != nil {
+		return fmt.Errorf("failed to initialize trailing stop tables: %w", err)
+	}
 	return nil
 }
 
@@ -174,6 +194,9 @@ func (s *Store) initDefaultData() error {
 	if err := s.Strategy().initDefaultData(); err != nil {
 		return err
 	}
+	if err := s.AIUsage().initDefaultData(); err != nil {
+		return err
+	}
 	// Migrate old decision_account_snapshots data to new trader_equity_snapshots table
 	if migrated, err := s.Equity().MigrateFromDecision(); err != nil {
 		logger.Warnf("failed to migrate equity data: %v", err)
@@ -293,6 +316,56 @@ func (s *Store) Grid() *GridStore {
 	return s.grid
 }
 
+// Paper gets paper trading (simulated exchange) storage
+func (s *Store) Paper() *PaperStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paper == nil {
+		s.paper = NewPaperStore(s.gdb)
+	}
+	return s.paper
+}
+
+// AIUsage gets LLM token usage and model pricing storage
+func (s *Store) AIUsage() *AIUsageStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aiUsage == nil {
+		s.aiUsage = NewAIUsageStore(s.gdb)
+	}
+	return s.aiUsage
+}
+
+// PromptTemplate gets versioned prompt template and experiment storage
+func (s *Store) PromptTemplate() *PromptTemplateStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prompt == nil {
+		s.prompt = NewPromptTemplateStore(s.gdb)
+	}
+	return s.prompt
+}
+
+// TradeMemory gets storage of position entry features for similar trade retrieval
+func (s *Store) TradeMemory() *TradeMemoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.memory == nil {
+		s.memory = NewTradeMemoryStore(s.gdb)
+	}
+	return s.memory
+}
+
+// TrailingStop gets trailing stop storage
+func (s *Store) TrailingStop() *TrailingStopStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trailing == nil {
+		s.trailing = NewTrailingStopStore(s.gdb)
+	}
+	return s.trailing
+}
+
 // Close closes database connection
 func (s *Store) Close() error {
 	if s.driver != nil {
@@ -32,7 +32,7 @@ func (Strategy) TableName() string { return "strategies" }
 
 // StrategyConfig strategy configuration details (JSON structure)
 type StrategyConfig struct {
-	// Strategy type: "ai_trading" (default) or "grid_trading"
+	// Strategy type: "ai_trading" (default), "grid_trading" or "rules"
 	StrategyType string `json:"strategy_type,omitempty"`
 
 	// language setting: "zh" for Chinese, "en" for English
@@ -51,6 +51,62 @@ type StrategyConfig struct {
 
 	// Grid trading configuration (only used when StrategyType == "grid_trading")
 	GridConfig *GridStrategyConfig `json:"grid_config,omitempty"`
+
+	// Rule-based strategy configuration (only used when StrategyType == "rules")
+	RuleConfig *RuleStrategyConfig `json:"rule_config,omitempty"`
+
+	// Hybrid mode: rule pre-filters and post-validators around AI decisions (AI strategies only)
+	HybridRules *HybridRuleConfig `json:"hybrid_rules,omitempty"`
+}
+
+// StrategyTypeRules strategy type whose decisions come from declarative rules instead of the AI
+const StrategyTypeRules = "rules"
+
+// IsRuleStrategy reports whether decisions are made by the rule engine
+func (c *StrategyConfig) IsRuleStrategy() bool {
+	return c != nil && c.StrategyType == StrategyTypeRules && c.RuleConfig != nil
+}
+
+// RuleStrategyConfig rule-based strategy configuration.
+// Rules are conditions over indicators, e.g. "ema20 crosses above ema50 on 1h and rsi14 < 70";
+// an empty rule never matches.
+type RuleStrategyConfig struct {
+	// Conditions to open a long / short position on a candidate coin
+	EntryLong  string `json:"entry_long,omitempty"`
+	EntryShort string `json:"entry_short,omitempty"`
+	// Conditions to close an open long / short position
+	ExitLong  string `json:"exit_long,omitempty"`
+	ExitShort string `json:"exit_short,omitempty"`
+	// Position value as a percentage of account equity (default 20)
+	PositionSizePct float64 `json:"position_size_pct,omitempty"`
+	// Leverage (default 3, capped by the risk control leverage)
+	Leverage int `json:"leverage,omitempty"`
+	// Stop loss / take profit distance from the entry price in percent (default 2 / 6)
+	StopLossPct   float64 `json:"stop_loss_pct,omitempty"`
+	TakeProfitPct float64 `json:"take_profit_pct,omitempty"`
+}
+
+// HybridRuleConfig rules gating AI decisions, written in the rule strategy condition language.
+// Pre-filters decide which candidate coins are sent to the AI, validators reject AI entries.
+type HybridRuleConfig struct {
+	// Candidate coins are sent to the AI only when every pre-filter matches (positions are always sent)
+	PreFilters []string `json:"pre_filters,omitempty"`
+	// AI open_long / open_short decisions are rejected while a validator's condition matches
+	Validators []RuleValidator `json:"validators,omitempty"`
+	// Reject AI entries below RiskControl.MinConfidence (CODE ENFORCED instead of AI guided)
+	EnforceMinConfidence bool `json:"enforce_min_confidence,omitempty"`
+	// Reject AI entries below RiskControl.MinRiskRewardRatio at the current price (CODE ENFORCED instead of AI guided)
+	EnforceRiskReward bool `json:"enforce_risk_reward,omitempty"`
+}
+
+// RuleValidator blocks AI entries on a side while its condition matches,
+// e.g. {"block_when": "close < ema200 on 4h", "side": "long"}
+type RuleValidator struct {
+	BlockWhen string `json:"block_when"`
+	// "long", "short" or "both" (default)
+	Side string `json:"side,omitempty"`
+	// Rejection reason (default: the condition)
+	Reason string `json:"reason,omitempty"`
 }
 
 // GridStrategyConfig grid trading specific configuration
@@ -171,6 +227,16 @@ type IndicatorConfig struct {
 	EnablePriceRanking   bool   `json:"enable_price_ranking"`             // whether to enable price ranking data
 	PriceRankingDuration string `json:"price_ranking_duration,omitempty"` // durations: "1h" or "1h,4h,24h"
 	PriceRankingLimit    int    `json:"price_ranking_limit,omitempty"`    // number of entries per ranking (default 10)
+
+	// Tool calling: candidate coin data is not preloaded into the prompt, the AI requests
+	// klines, funding rates and OI rankings through tools (requires a tool-capable model)
+	EnableToolCalling bool `json:"enable_tool_calling,omitempty"`
+	ToolMaxIterations int  `json:"tool_max_iterations,omitempty"` // maximum AI turns per decision (default 5)
+
+	// Trade memory: closed trades whose market state at entry resembles a symbol's current state
+	// are shown to the AI together with their outcome
+	EnableTradeMemory bool `json:"enable_trade_memory,omitempty"`
+	TradeMemoryK      int  `json:"trade_memory_k,omitempty"` // similar trades per symbol (default 3)
 }
 
 // KlineConfig K-line configuration
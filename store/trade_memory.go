@@ -0,0 +1,93 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TradeMemory market state captured when a position was opened.
+// Once the position is closed, its outcome is read from trader_positions.
+// All time fields use int64 millisecond timestamps (UTC) to avoid timezone issues
+type TradeMemory struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	TraderID   string `gorm:"column:trader_id;not null;index:idx_trade_memories_trader" json:"trader_id"`
+	PositionID int64  `gorm:"column:position_id;not null;uniqueIndex:idx_trade_memories_position" json:"position_id"`
+	Symbol     string `gorm:"column:symbol;not null" json:"symbol"`
+	Side       string `gorm:"column:side;not null" json:"side"`                   // LONG/SHORT
+	Features   string `gorm:"column:features;type:text;not null" json:"features"` // JSON encoded feature vector
+	CreatedAt  int64  `gorm:"column:created_at" json:"created_at"`                // Unix milliseconds UTC
+}
+
+// TableName returns the table name
+func (TradeMemory) TableName() string {
+	return "trade_memories"
+}
+
+// ClosedTradeMemory trade memory of a closed position with its outcome
+type ClosedTradeMemory struct {
+	PositionID    int64   `json:"position_id"`
+	Symbol        string  `json:"symbol"`
+	Side          string  `json:"side"`
+	Features      string  `json:"features"`
+	EntryPrice    float64 `json:"entry_price"`
+	ExitPrice     float64 `json:"exit_price"`
+	EntryQuantity float64 `json:"entry_quantity"`
+	Leverage      int     `json:"leverage"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	Fee           float64 `json:"fee"`
+	EntryTime     int64   `json:"entry_time"` // Unix milliseconds UTC
+	ExitTime      int64   `json:"exit_time"`  // Unix milliseconds UTC
+	CloseReason   string  `json:"close_reason"`
+	HoldDuration  string  `gorm:"-" json:"hold_duration"` // e.g. "2h30m"
+}
+
+// TradeMemoryStore trade memory storage
+type TradeMemoryStore struct {
+	db *gorm.DB
+}
+
+// NewTradeMemoryStore creates trade memory storage instance
+func NewTradeMemoryStore(db *gorm.DB) *TradeMemoryStore {
+	return &TradeMemoryStore{db: db}
+}
+
+// initTables initializes trade memory tables
+func (s *TradeMemoryStore) initTables() error {
+	return s.db.AutoMigrate(&TradeMemory{})
+}
+
+// Save stores the entry features of a position
+func (s *TradeMemoryStore) Save(memory *TradeMemory) error {
+	if memory.CreatedAt == 0 {
+		memory.CreatedAt = time.Now().UTC().UnixMilli()
+	}
+	// Omit ID to let PostgreSQL sequence auto-generate it
+	if err := s.db.Omit("ID").Create(memory).Error; err != nil {
+		return fmt.Errorf("failed to save trade memory: %w", err)
+	}
+	return nil
+}
+
+// ListClosed returns the trade memories of a trader's closed positions, most recently closed first
+func (s *TradeMemoryStore) ListClosed(traderID string, limit int) ([]*ClosedTradeMemory, error) {
+	var rows []*ClosedTradeMemory
+	err := s.db.Table("trade_memories AS m").
+		Select("m.position_id, m.symbol, m.side, m.features, p.entry_price, p.exit_price, p.entry_quantity, "+
+			"p.leverage, p.realized_pnl, p.fee, p.entry_time, p.exit_time, p.close_reason").
+		Joins("JOIN trader_positions AS p ON p.id = m.position_id").
+		Where("m.trader_id = ? AND p.status = ?", traderID, "CLOSED").
+		Order("p.exit_time DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trade memories: %w", err)
+	}
+	for _, row := range rows {
+		if row.ExitTime > row.EntryTime {
+			row.HoldDuration = formatDurationMs(row.ExitTime - row.EntryTime)
+		}
+	}
+	return rows, nil
+}
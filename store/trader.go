@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -30,6 +31,9 @@ type Trader struct {
 	IsRunning           bool      `gorm:"column:is_running;default:false" json:"is_running"`
 	IsCrossMargin       bool      `gorm:"column:is_cross_margin;default:true" json:"is_cross_margin"`
 	ShowInCompetition   bool      `gorm:"column:show_in_competition;default:true" json:"show_in_competition"`
+	AIMode              string    `gorm:"column:ai_mode;default:single" json:"ai_mode"`                     // single/fallback/ensemble
+	BackupAIModelIDs    string    `gorm:"column:backup_ai_model_ids;default:''" json:"backup_ai_model_ids"` // Comma-separated AI model IDs: fallback order after the primary, or extra ensemble voters
+	EnsembleVote        string    `gorm:"column:ensemble_vote;default:confidence" json:"ensemble_vote"`     // Ensemble vote: majority/confidence
 	CreatedAt           time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 	UpdatedAt           time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 
@@ -44,6 +48,24 @@ type Trader struct {
 	SystemPromptTemplate string `gorm:"column:system_prompt_template;default:default" json:"system_prompt_template,omitempty"`
 }
 
+// Trader AI modes
+const (
+	TraderAIModeSingle   = "single"   // Only the primary AI model
+	TraderAIModeFallback = "fallback" // Primary, then backups in order when a provider fails
+	TraderAIModeEnsemble = "ensemble" // Primary and backups queried in parallel, decisions merged by vote
+)
+
+// BackupAIModelIDList returns the backup AI model IDs in order
+func (t *Trader) BackupAIModelIDList() []string {
+	var ids []string
+	for _, id := range strings.Split(t.BackupAIModelIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" && id != t.AIModelID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 // TableName returns the table name for Trader
 func (Trader) TableName() string {
 	return "traders"
@@ -63,6 +85,10 @@ func (s *TraderStore) initTables() error {
 		var tableExists int64
 		s.db.Raw(`SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'traders'`).Scan(&tableExists)
 		if tableExists > 0 {
+			// Multi-model columns (added later)
+			s.db.Exec(`ALTER TABLE traders ADD COLUMN IF NOT EXISTS ai_mode TEXT DEFAULT 'single'`)
+			s.db.Exec(`ALTER TABLE traders ADD COLUMN IF NOT EXISTS backup_ai_model_ids TEXT DEFAULT ''`)
+			s.db.Exec(`ALTER TABLE traders ADD COLUMN IF NOT EXISTS ensemble_vote TEXT DEFAULT 'confidence'`)
 			return nil
 		}
 	}
@@ -116,6 +142,9 @@ func (s *TraderStore) Update(trader *Trader) error {
 		"strategy_id":    trader.StrategyID,
 		"is_cross_margin": trader.IsCrossMargin,
 		"show_in_competition": trader.ShowInCompetition,
+		"ai_mode":             trader.AIMode,
+		"backup_ai_model_ids": trader.BackupAIModelIDs,
+		"ensemble_vote":       trader.EnsembleVote,
 	}
 
 	// Only update these if > 0
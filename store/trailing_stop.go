@@ -0,0 +1,74 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TrailingStopStore trailing stops of open positions, so emulated trailing stops keep following
+// the price after a restart
+type TrailingStopStore struct {
+	db *gorm.DB
+}
+
+// TrailingStop trailing stop of one position
+type TrailingStop struct {
+	TraderID    string  `gorm:"column:trader_id;primaryKey" json:"trader_id"`
+	Symbol      string  `gorm:"primaryKey" json:"symbol"`
+	Side        string  `gorm:"primaryKey" json:"side"` // long/short
+	CallbackPct float64 `gorm:"column:callback_pct;not null" json:"callback_pct"`
+	Native      bool    `gorm:"not null;default:false" json:"native"`          // Exchange trailing order
+	BestPrice   float64 `gorm:"column:best_price;default:0" json:"best_price"` // Emulated only
+	StopPrice   float64 `gorm:"column:stop_price;default:0" json:"stop_price"` // Emulated only
+	UpdatedAt   int64   `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (TrailingStop) TableName() string { return "trader_trailing_stops" }
+
+// NewTrailingStopStore creates a new TrailingStopStore
+func NewTrailingStopStore(db *gorm.DB) *TrailingStopStore {
+	return &TrailingStopStore{db: db}
+}
+
+// initTables initializes trailing stop tables
+func (s *TrailingStopStore) initTables() error {
+	// For PostgreSQL with existing table, skip AutoMigrate
+	if s.db.Dialector.Name() == "postgres" {
+		var tableExists int64
+		s.db.Raw(`SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'trader_trailing_stops'`).Scan(&tableExists)
+		if tableExists > 0 {
+			return nil
+		}
+	}
+	return s.db.AutoMigrate(&TrailingStop{})
+}
+
+// Save creates or replaces the trailing stop of a position
+func (s *TrailingStopStore) Save(ts *TrailingStop) error {
+	ts.UpdatedAt = time.Now().UTC().UnixMilli()
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(ts).Error; err != nil {
+		return fmt.Errorf("failed to save trailing stop: %w", err)
+	}
+	return nil
+}
+
+// List gets the trailing stops of a trader
+func (s *TrailingStopStore) List(traderID string) ([]*TrailingStop, error) {
+	var stops []*TrailingStop
+	if err := s.db.Where("trader_id = ?", traderID).Find(&stops).Error; err != nil {
+		return nil, fmt.Errorf("failed to query trailing stops: %w", err)
+	}
+	return stops, nil
+}
+
+// Delete deletes the trailing stop of a position
+func (s *TrailingStopStore) Delete(traderID, symbol, side string) error {
+	err := s.db.Where("trader_id = ? AND symbol = ? AND side = ?", traderID, symbol, side).Delete(&TrailingStop{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete trailing stop: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package trader
+
+import (
+	"nofx/logger"
+	"nofx/mcp"
+	"nofx/store"
+)
+
+// AIModelConfig credentials of an additional AI model (fallback backup or ensemble voter)
+type AIModelConfig struct {
+	Provider        string // deepseek/qwen/claude/kimi/gemini/grok/openai/local/custom
+	APIKey          string
+	CustomAPIURL    string
+	CustomModelName string
+}
+
+// newAIClient creates a configured client for a provider
+func newAIClient(m AIModelConfig) mcp.AIClient {
+	var client mcp.AIClient
+	switch m.Provider {
+	case "deepseek":
+		client = mcp.NewDeepSeekClient()
+	case "qwen":
+		client = mcp.NewQwenClient()
+	case "claude":
+		client = mcp.NewClaudeClient()
+	case "kimi":
+		client = mcp.NewKimiClient()
+	case "gemini":
+		client = mcp.NewGeminiClient()
+	case "grok":
+		client = mcp.NewGrokClient()
+	case "openai":
+		client = mcp.NewOpenAIClient()
+	case "local":
+		client = mcp.NewLocalClient()
+	default:
+		client = mcp.New()
+	}
+	client.SetAPIKey(m.APIKey, m.CustomAPIURL, m.CustomModelName)
+	return client
+}
+
+// configureMultiModel wraps the primary client according to the AI mode: a fallback chain
+// replaces it, an ensemble returns the voter clients (primary first)
+func configureMultiModel(config AutoTraderConfig, primary mcp.AIClient) (mcp.AIClient, []mcp.AIClient) {
+	if len(config.BackupAIModels) == 0 {
+		return primary, nil
+	}
+
+	clients := []mcp.AIClient{primary}
+	for _, m := range config.BackupAIModels {
+		clients = append(clients, newAIClient(m))
+	}
+
+	switch config.AIMode {
+	case store.TraderAIModeFallback:
+		logger.Infof("🔀 [%s] AI fallback chain: %s + %d backup model(s)", config.Name, config.AIModel, len(config.BackupAIModels))
+		return mcp.NewFallbackClient(mcp.CircuitBreakerConfig{}, clients...), nil
+	case store.TraderAIModeEnsemble:
+		logger.Infof("🗳️ [%s] AI ensemble: %d models, %s vote", config.Name, len(clients), config.EnsembleVote)
+		return primary, clients
+	default:
+		return primary, nil
+	}
+}
@@ -1,6 +1,7 @@
 package trader
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -19,6 +20,8 @@ import (
 	"nofx/trader/kucoin"
 	"nofx/trader/lighter"
 	"nofx/trader/okx"
+	"nofx/trader/paper"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -32,7 +35,7 @@ type AutoTraderConfig struct {
 	AIModel string // AI model: "qwen" or "deepseek"
 
 	// Trading platform selection
-	Exchange   string // Exchange type: "binance", "bybit", "okx", "bitget", "gate", "hyperliquid", "aster" or "lighter"
+	Exchange   string // Exchange type: "binance", "bybit", "okx", "bitget", "gate", "hyperliquid", "aster", "lighter" or "paper"
 	ExchangeID string // Exchange account UUID (for multi-account support)
 
 	// Binance API configuration
@@ -89,6 +92,11 @@ type AutoTraderConfig struct {
 	CustomAPIKey    string
 	CustomModelName string
 
+	// Multi-model configuration
+	AIMode         string          // "single" (default), "fallback" or "ensemble"
+	BackupAIModels []AIModelConfig // Fallback order after the primary model, or extra ensemble voters
+	EnsembleVote   string          // Ensemble vote mode: "majority" or "confidence" (default)
+
 	// Scan configuration
 	ScanInterval time.Duration // Scan interval (recommended 3 minutes)
 
@@ -108,6 +116,11 @@ type AutoTraderConfig struct {
 
 	// Strategy configuration (use complete strategy config)
 	StrategyConfig *store.StrategyConfig // Strategy configuration (includes coin sources, indicators, risk control, prompts, etc.)
+
+	// Prompt configuration
+	PromptVariant         string // Trading mode variant of the system prompt (default "balanced")
+	PromptTemplateID      string // Prompt template applied to StrategyConfig (empty if none)
+	PromptTemplateVersion int    // Version of the applied prompt template
 }
 
 // AutoTrader automatic trader
@@ -121,6 +134,7 @@ type AutoTrader struct {
 	config                AutoTraderConfig
 	trader                Trader // Use Trader interface (supports multiple platforms)
 	mcpClient             mcp.AIClient
+	ensembleClients       []mcp.AIClient // Voter clients in ensemble mode (nil otherwise)
 	store                 *store.Store             // Data storage (decision records, etc.)
 	strategyEngine        *kernel.StrategyEngine // Strategy engine (uses strategy configuration)
 	cycleNumber           int                      // Current cycle number
@@ -136,12 +150,24 @@ type AutoTrader struct {
 	callCount             int                // AI call count
 	positionFirstSeenTime map[string]int64   // Position first seen time (symbol_side -> timestamp in milliseconds)
 	stopMonitorCh         chan struct{}      // Used to stop monitoring goroutine
+	runCtx                context.Context    // Cancelled by Stop, aborts AI calls in flight
+	runCancel             context.CancelFunc
 	monitorWg             sync.WaitGroup     // Used to wait for monitoring goroutine to finish
 	peakPnLCache          map[string]float64 // Peak profit cache (symbol -> peak P&L percentage)
 	peakPnLCacheMutex     sync.RWMutex       // Cache read-write lock
+	trailingStops         *trailingStopEngine     // Trailing stops of open positions (exchange orders or emulated)
+	takeProfitLadders     *takeProfitLadderEngine // Take-profit ladders of open positions (exchange orders or emulated)
 	lastBalanceSyncTime   time.Time          // Last balance sync time
 	userID                string             // User ID
 	gridState             *GridState         // Grid trading state (only used when StrategyType == "grid_trading")
+	decisionStream        *decisionStream    // Live AI output for dashboard subscribers
+	cycleMarketData       map[string]*market.Data // Market data of the last decision cycle (trade memory features)
+	cycleMarketDataMutex  sync.RWMutex
+	userData              *userDataHub // Order state from the exchange user data stream (nil when not streaming)
+	userDataMutex         sync.RWMutex // Protects userData
+	orderSyncMutex        sync.Mutex   // Protects orderSyncRunning and orderSyncPending
+	orderSyncRunning      bool         // Event-triggered order sync in progress
+	orderSyncPending      bool         // Another order sync was requested while one was running
 }
 
 // NewAutoTrader creates an automatic trader
@@ -204,6 +230,11 @@ func NewAutoTrader(config AutoTraderConfig, st *store.Store, userID string) (*Au
 		mcpClient.SetAPIKey(apiKey, config.CustomAPIURL, config.CustomModelName)
 		logger.Infof("🤖 [%s] Using Alibaba Cloud Qwen AI", config.Name)
 
+	case "local":
+		mcpClient = mcp.NewLocalClient()
+		mcpClient.SetAPIKey(config.CustomAPIKey, config.CustomAPIURL, config.CustomModelName)
+		logger.Infof("🤖 [%s] Using local model server", config.Name)
+
 	case "custom":
 		mcpClient = mcp.New()
 		mcpClient.SetAPIKey(config.CustomAPIKey, config.CustomAPIURL, config.CustomModelName)
@@ -222,6 +253,12 @@ func NewAutoTrader(config AutoTraderConfig, st *store.Store, userID string) (*Au
 	if config.CustomAPIURL != "" || config.CustomModelName != "" {
 		logger.Infof("🔧 [%s] Custom config - URL: %s, Model: %s", config.Name, config.CustomAPIURL, config.CustomModelName)
 	}
+	mcpClient, ensembleClients := configureMultiModel(config, mcpClient)
+	attribution := mcp.UsageAttribution{UserID: userID, Source: mcp.UsageSourceTrader, SourceID: config.ID}
+	mcp.AttributeUsage(mcpClient, attribution)
+	for _, client := range ensembleClients {
+		mcp.AttributeUsage(client, attribution)
+	}
 
 	// Set default trading platform
 	if config.Exchange == "" {
@@ -288,6 +325,12 @@ func NewAutoTrader(config AutoTraderConfig, st *store.Store, userID string) (*Au
 			return nil, fmt.Errorf("failed to initialize LIGHTER trader: %w", err)
 		}
 		logger.Infof("✓ LIGHTER trader initialized successfully")
+	case "paper":
+		logger.Infof("🏦 [%s] Using paper trading (simulated account, live market prices)", config.Name)
+		trader, err = paper.NewPaperTrader(st, config.ID, config.InitialBalance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize paper trader: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported trading platform: %s", config.Exchange)
 	}
@@ -348,6 +391,7 @@ func NewAutoTrader(config AutoTraderConfig, st *store.Store, userID string) (*Au
 		config:                config,
 		trader:                trader,
 		mcpClient:             mcpClient,
+		ensembleClients:       ensembleClients,
 		store:                 st,
 		strategyEngine:        strategyEngine,
 		cycleNumber:           cycleNumber,
@@ -355,12 +399,15 @@ func NewAutoTrader(config AutoTraderConfig, st *store.Store, userID string) (*Au
 		lastResetTime:         time.Now(),
 		startTime:             time.Now(),
 		callCount:             0,
+		decisionStream:        newDecisionStream(),
 		isRunning:             false,
 		positionFirstSeenTime: make(map[string]int64),
 		stopMonitorCh:         make(chan struct{}),
 		monitorWg:             sync.WaitGroup{},
 		peakPnLCache:          make(map[string]float64),
 		peakPnLCacheMutex:     sync.RWMutex{},
+		trailingStops:         newTrailingStopEngine(trader, st, config.ID),
+		takeProfitLadders:     newTakeProfitLadderEngine(trader),
 		lastBalanceSyncTime:   time.Now(),
 		userID:                userID,
 	}, nil
@@ -373,6 +420,7 @@ func (at *AutoTrader) Run() error {
 	at.isRunningMutex.Unlock()
 
 	at.stopMonitorCh = make(chan struct{})
+	at.runCtx, at.runCancel = context.WithCancel(context.Background())
 	at.startTime = time.Now()
 
 	logger.Info("🚀 AI-driven automatic trading system started")
@@ -384,6 +432,8 @@ func (at *AutoTrader) Run() error {
 
 	// Start drawdown monitoring
 	at.startDrawdownMonitor()
+	at.trailingStops.load()
+	at.startExitMonitor()
 
 	// Start Lighter order sync if using Lighter exchange
 	if at.exchange == "lighter" {
@@ -457,6 +507,25 @@ func (at *AutoTrader) Run() error {
 		}
 	}
 
+	// Start paper order sync if using paper trading
+	if at.exchange == "paper" {
+		if paperTrader, ok := at.trader.(*paper.PaperTrader); ok && at.store != nil {
+			paperTrader.StartOrderSync(at.id, at.exchangeID, at.exchange, at.store, 30*time.Second)
+			logger.Infof("🔄 [%s] Paper order+position sync enabled (every 30s)", at.name)
+		}
+		// Stops, take-profits, limit orders and liquidations fire on the market price, not on the next cycle
+		if paperTrader, ok := at.trader.(*paper.PaperTrader); ok {
+			at.monitorWg.Add(1)
+			go func() {
+				defer at.monitorWg.Done()
+				paperTrader.RunTriggers(paper.TriggerInterval, at.stopMonitorCh)
+			}()
+		}
+	}
+
+	// Stream order updates and fills if the exchange supports it
+	at.startUserDataStream()
+
 	ticker := time.NewTicker(at.config.ScanInterval)
 	defer ticker.Stop()
 
@@ -520,11 +589,22 @@ func (at *AutoTrader) Stop() {
 	at.isRunning = false
 	at.isRunningMutex.Unlock()
 
+	if at.runCancel != nil {
+		at.runCancel() // Abort the AI call of a cycle in progress
+	}
 	close(at.stopMonitorCh) // Notify monitoring goroutine to stop
 	at.monitorWg.Wait()     // Wait for monitoring goroutine to finish
 	logger.Info("⏹ Automatic trading system stopped")
 }
 
+// runContext returns the context of the current run, Background if the trader isn't running
+func (at *AutoTrader) runContext() context.Context {
+	if at.runCtx == nil {
+		return context.Background()
+	}
+	return at.runCtx
+}
+
 // runCycle runs one trading cycle (using AI full decision-making)
 func (at *AutoTrader) runCycle() error {
 	at.callCount++
@@ -599,12 +679,78 @@ func (at *AutoTrader) runCycle() error {
 		record.CandidateCoins = append(record.CandidateCoins, coin.Symbol)
 	}
 
+	// Account and positions the AI decided on (lets decision replays estimate what-if PnL)
+	record.AccountState = store.AccountSnapshot{
+		TotalBalance:          ctx.Account.TotalEquity,
+		AvailableBalance:      ctx.Account.AvailableBalance,
+		TotalUnrealizedProfit: ctx.Account.UnrealizedPnL,
+		PositionCount:         ctx.Account.PositionCount,
+		MarginUsedPct:         ctx.Account.MarginUsedPct,
+		InitialBalance:        at.initialBalance,
+	}
+	for _, pos := range ctx.Positions {
+		record.Positions = append(record.Positions, store.PositionSnapshot{
+			Symbol:           pos.Symbol,
+			Side:             pos.Side,
+			PositionAmt:      pos.Quantity,
+			EntryPrice:       pos.EntryPrice,
+			MarkPrice:        pos.MarkPrice,
+			UnrealizedProfit: pos.UnrealizedPnL,
+			Leverage:         float64(pos.Leverage),
+			LiquidationPrice: pos.LiquidationPrice,
+		})
+	}
+
 	logger.Infof("📊 Account equity: %.2f USDT | Available: %.2f USDT | Positions: %d",
 		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
 	// 5. Use strategy engine to call AI for decision
 	logger.Infof("🤖 Requesting AI analysis and decision... [Strategy Engine]")
-	aiDecision, err := kernel.GetFullDecisionWithStrategy(ctx, at.mcpClient, at.strategyEngine, "balanced")
+	var aiDecision *kernel.FullDecision
+	// Stream the model output only while someone watches the dashboard
+	_, canStream := at.mcpClient.(mcp.StreamCaller)
+	ruleStrategy := at.config.StrategyConfig.IsRuleStrategy()
+	streaming := canStream && !ruleStrategy && len(at.ensembleClients) <= 1 && at.decisionStream.hasSubscribers()
+	at.decisionStream.publish(DecisionStreamEvent{Type: DecisionStreamCycleStart, Cycle: at.callCount, Streaming: streaming})
+	switch {
+	case ruleStrategy:
+		// Deterministic rules replace the AI call; execution and risk checks stay the same
+		aiDecision, err = kernel.GetRuleDecision(ctx, at.strategyEngine, nil)
+	case len(at.ensembleClients) > 1:
+		aiDecision, err = kernel.GetEnsembleDecision(ctx, at.ensembleClients, at.strategyEngine, at.promptVariant(), at.config.EnsembleVote)
+	case streaming:
+		// Aborted when the trader stops or the last dashboard subscriber disconnects
+		streamCtx, cancel := at.decisionStream.watch(at.runContext())
+		aiDecision, err = kernel.GetFullDecisionWithStrategyStream(streamCtx, ctx, at.mcpClient, at.strategyEngine, at.promptVariant(), func(chunk string) {
+			at.decisionStream.publish(DecisionStreamEvent{Type: DecisionStreamChunk, Cycle: at.callCount, Text: chunk})
+		})
+		cancel()
+	default:
+		aiDecision, err = kernel.GetFullDecisionWithStrategy(ctx, at.mcpClient, at.strategyEngine, at.promptVariant())
+	}
+	endEvent := DecisionStreamEvent{Type: DecisionStreamCycleEnd, Cycle: at.callCount}
+	if err != nil {
+		endEvent.Error = err.Error()
+	} else if aiDecision != nil {
+		endEvent.Decisions = aiDecision.Decisions
+		if !streaming {
+			endEvent.Text = aiDecision.CoTTrace
+		}
+	}
+	at.decisionStream.publish(endEvent)
+	at.cycleMarketDataMutex.Lock()
+	at.cycleMarketData = ctx.MarketDataMap
+	at.cycleMarketDataMutex.Unlock()
+
+	// Candidate coins the hybrid pre-filters removed before the AI saw them
+	filtered := make([]string, 0, len(ctx.FilteredCandidates))
+	for symbol := range ctx.FilteredCandidates {
+		filtered = append(filtered, symbol)
+	}
+	sort.Strings(filtered)
+	for _, symbol := range filtered {
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🔎 %s filtered out: %s", symbol, ctx.FilteredCandidates[symbol]))
+	}
 
 	if aiDecision != nil && aiDecision.AIRequestDurationMs > 0 {
 		record.AIRequestDurationMs = aiDecision.AIRequestDurationMs
@@ -735,6 +881,12 @@ func (at *AutoTrader) runCycle() error {
 		record.Decisions = append(record.Decisions, actionRecord)
 	}
 
+	// AI entries rejected by hybrid rules are recorded as failed actions with the reason
+	for _, rejected := range aiDecision.Rejected {
+		record.Decisions = append(record.Decisions, rejectedDecisionAction(rejected))
+		record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🚫 %s %s rejected: %s", rejected.Symbol, rejected.Action, rejected.RejectReason))
+	}
+
 	// 9. Save decision record
 	if err := at.saveDecision(record); err != nil {
 		logger.Infof("⚠ Failed to save decision record: %v", err)
@@ -743,6 +895,22 @@ func (at *AutoTrader) runCycle() error {
 	return nil
 }
 
+// rejectedDecisionAction records an AI decision rejected by hybrid rules
+func rejectedDecisionAction(rejected kernel.RejectedDecision) store.DecisionAction {
+	return store.DecisionAction{
+		Action:     rejected.Action,
+		Symbol:     rejected.Symbol,
+		Leverage:   rejected.Leverage,
+		StopLoss:   rejected.StopLoss,
+		TakeProfit: rejected.TakeProfit,
+		Confidence: rejected.Confidence,
+		Reasoning:  rejected.Reasoning,
+		Timestamp:  time.Now().UTC(),
+		Success:    false,
+		Error:      "rejected by hybrid rules: " + rejected.RejectReason,
+	}
+}
+
 // buildTradingContext builds trading context
 func (at *AutoTrader) buildTradingContext() (*kernel.Context, error) {
 	// 1. Get account information
@@ -775,8 +943,9 @@ func (at *AutoTrader) buildTradingContext() (*kernel.Context, error) {
 		totalEquity = totalWalletBalance + totalUnrealizedProfit
 	}
 
-	// 2. Get position information
-	positions, err := at.trader.GetPositions()
+	// 2. Get position information (closed positions with quantity 0 are dropped, preventing
+	// "ghost positions" from being passed to AI)
+	positions, err := AsTraderV2(at.trader).GetPositionsV2()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get positions: %w", err)
 	}
@@ -788,27 +957,14 @@ func (at *AutoTrader) buildTradingContext() (*kernel.Context, error) {
 	currentPositionKeys := make(map[string]bool)
 
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity // Short position quantity is negative, convert to positive
-		}
-
-		// Skip closed positions (quantity = 0), prevent "ghost positions" from being passed to AI
-		if quantity == 0 {
-			continue
-		}
-
-		unrealizedPnl := pos["unRealizedProfit"].(float64)
-		liquidationPrice := pos["liquidationPrice"].(float64)
+		symbol, side := pos.Symbol, pos.Side
+		entryPrice, markPrice, quantity := pos.EntryPrice, pos.MarkPrice, pos.Quantity
+		unrealizedPnl, liquidationPrice := pos.UnrealizedPnL, pos.LiquidationPrice
 
 		// Calculate margin used (estimated)
-		leverage := 10 // Default value, should actually be fetched from position info
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
+		leverage := 10 // Default value when the exchange doesn't report it
+		if pos.Leverage > 0 {
+			leverage = pos.Leverage
 		}
 		marginUsed := (quantity * markPrice) / float64(leverage)
 		totalMarginUsed += marginUsed
@@ -830,10 +986,8 @@ func (at *AutoTrader) buildTradingContext() (*kernel.Context, error) {
 			}
 		}
 		// Priority 2: Get from exchange API (Bybit: createdTime, OKX: createdTime)
-		if updateTime == 0 {
-			if createdTime, ok := pos["createdTime"].(int64); ok && createdTime > 0 {
-				updateTime = createdTime
-			}
+		if updateTime == 0 && pos.CreatedTime > 0 {
+			updateTime = pos.CreatedTime
 		}
 		// Priority 3: Fallback to local tracking
 		if updateTime == 0 {
@@ -979,6 +1133,15 @@ func (at *AutoTrader) buildTradingContext() (*kernel.Context, error) {
 			logger.Infof("📈 [%s] Trading stats: %d trades, %.1f%% win rate, PF=%.2f, Sharpe=%.2f, DD=%.1f%%",
 				at.name, stats.TotalTrades, stats.WinRate, stats.ProfitFactor, stats.SharpeRatio, stats.MaxDrawdownPct)
 		}
+		// Closed trades with entry features, matched against the market data once it is fetched
+		if strategyConfig.Indicators.EnableTradeMemory {
+			memories, err := at.store.TradeMemory().ListClosed(at.id, kernel.MaxTradeMemories)
+			if err != nil {
+				logger.Infof("⚠️ [%s] Failed to get trade memories: %v", at.name, err)
+			} else {
+				ctx.TradeMemories = memories
+			}
+		}
 	} else {
 		logger.Infof("⚠️ [%s] Store is nil, cannot get recent trades", at.name)
 	}
@@ -1048,6 +1211,10 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *kernel.Decision, actio
 		return at.executeCloseLongWithRecord(decision, actionRecord)
 	case "close_short":
 		return at.executeCloseShortWithRecord(decision, actionRecord)
+	case "set_trailing_stop":
+		return at.executeSetTrailingStopWithRecord(decision, actionRecord)
+	case "move_to_breakeven":
+		return at.executeMoveToBreakEvenWithRecord(decision, actionRecord)
 	case "hold", "wait":
 		// No execution needed, just record
 		return nil
@@ -1189,13 +1356,21 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *kernel.Decision, actio
 	posKey := decision.Symbol + "_long"
 	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
 
-	// Set stop loss and take profit
+	// Set stop loss and take profit; a take-profit ladder scales out first, the final
+	// take-profit and the trailing stop cover what it leaves
 	if err := at.trader.SetStopLoss(decision.Symbol, "LONG", quantity, decision.StopLoss); err != nil {
 		logger.Infof("  ⚠ Failed to set stop loss: %v", err)
 	}
-	if err := at.trader.SetTakeProfit(decision.Symbol, "LONG", quantity, decision.TakeProfit); err != nil {
+	remaining := at.placeTakeProfitLadder(decision, "long", quantity, order, marketData.CurrentPrice)
+	if remaining <= 0 {
+		return nil
+	}
+	if err := at.trader.SetTakeProfit(decision.Symbol, "LONG", remaining, decision.TakeProfit); err != nil {
 		logger.Infof("  ⚠ Failed to set take profit: %v", err)
 	}
+	if decision.TrailingStopPct > 0 {
+		at.setTrailingStop(decision.Symbol, "long", remaining, decision.TrailingStopPct, decision.StopLoss)
+	}
 
 	return nil
 }
@@ -1306,13 +1481,21 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *kernel.Decision, acti
 	posKey := decision.Symbol + "_short"
 	at.positionFirstSeenTime[posKey] = time.Now().UnixMilli()
 
-	// Set stop loss and take profit
+	// Set stop loss and take profit; a take-profit ladder scales out first, the final
+	// take-profit and the trailing stop cover what it leaves
 	if err := at.trader.SetStopLoss(decision.Symbol, "SHORT", quantity, decision.StopLoss); err != nil {
 		logger.Infof("  ⚠ Failed to set stop loss: %v", err)
 	}
-	if err := at.trader.SetTakeProfit(decision.Symbol, "SHORT", quantity, decision.TakeProfit); err != nil {
+	remaining := at.placeTakeProfitLadder(decision, "short", quantity, order, marketData.CurrentPrice)
+	if remaining <= 0 {
+		return nil
+	}
+	if err := at.trader.SetTakeProfit(decision.Symbol, "SHORT", remaining, decision.TakeProfit); err != nil {
 		logger.Infof("  ⚠ Failed to set take profit: %v", err)
 	}
+	if decision.TrailingStopPct > 0 {
+		at.setTrailingStop(decision.Symbol, "short", remaining, decision.TrailingStopPct, decision.StopLoss)
+	}
 
 	return nil
 }
@@ -1491,6 +1674,14 @@ func (at *AutoTrader) SetOverrideBasePrompt(override bool) {
 	at.overrideBasePrompt = override
 }
 
+// promptVariant returns the trading mode variant of the system prompt
+func (at *AutoTrader) promptVariant() string {
+	if at.config.PromptVariant != "" {
+		return at.config.PromptVariant
+	}
+	return "balanced"
+}
+
 // GetSystemPromptTemplate gets current system prompt template name (from strategy config)
 func (at *AutoTrader) GetSystemPromptTemplate() string {
 	if at.strategyEngine != nil {
@@ -1537,6 +1728,13 @@ func (at *AutoTrader) saveDecision(record *store.DecisionRecord) error {
 		record.Timestamp = time.Now().UTC()
 	}
 
+	// Tag the decision with the prompt it was made with, so prompt changes can be traced in results
+	if at.strategyEngine != nil {
+		record.PromptHash = store.PromptContentOf(at.strategyEngine.GetConfig(), at.promptVariant(), at.overrideBasePrompt).Hash()
+	}
+	record.PromptTemplateID = at.config.PromptTemplateID
+	record.PromptVersion = at.config.PromptTemplateVersion
+
 	if err := at.store.Decision().LogDecision(record); err != nil {
 		logger.Infof("⚠️ Failed to save decision record: %v", err)
 		return err
@@ -1621,7 +1819,7 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
 	}
 
 	// Get positions to calculate total margin
-	positions, err := at.trader.GetPositions()
+	positions, err := AsTraderV2(at.trader).GetPositionsV2()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get positions: %w", err)
 	}
@@ -1629,19 +1827,13 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
 	totalMarginUsed := 0.0
 	totalUnrealizedPnLCalculated := 0.0
 	for _, pos := range positions {
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity
-		}
-		unrealizedPnl := pos["unRealizedProfit"].(float64)
-		totalUnrealizedPnLCalculated += unrealizedPnl
+		totalUnrealizedPnLCalculated += pos.UnrealizedPnL
 
 		leverage := 10
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
+		if pos.Leverage > 0 {
+			leverage = pos.Leverage
 		}
-		marginUsed := (quantity * markPrice) / float64(leverage)
+		marginUsed := (pos.Quantity * pos.MarkPrice) / float64(leverage)
 		totalMarginUsed += marginUsed
 	}
 
@@ -1688,27 +1880,20 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
 
 // GetPositions gets position list (for API)
 func (at *AutoTrader) GetPositions() ([]map[string]interface{}, error) {
-	positions, err := at.trader.GetPositions()
+	positions, err := AsTraderV2(at.trader).GetPositionsV2()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get positions: %w", err)
 	}
 
 	var result []map[string]interface{}
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity
-		}
-		unrealizedPnl := pos["unRealizedProfit"].(float64)
-		liquidationPrice := pos["liquidationPrice"].(float64)
+		symbol, side := pos.Symbol, pos.Side
+		entryPrice, markPrice, quantity := pos.EntryPrice, pos.MarkPrice, pos.Quantity
+		unrealizedPnl, liquidationPrice := pos.UnrealizedPnL, pos.LiquidationPrice
 
 		leverage := 10
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
+		if pos.Leverage > 0 {
+			leverage = pos.Leverage
 		}
 
 		// Calculate margin used
@@ -1755,6 +1940,8 @@ func sortDecisionsByPriority(decisions []kernel.Decision) []kernel.Decision {
 		switch action {
 		case "close_long", "close_short":
 			return 1 // Highest priority: close positions first
+		case "set_trailing_stop", "move_to_breakeven":
+			return 1 // Protect existing positions before opening new ones
 		case "open_long", "open_short":
 			return 2 // Second priority: open positions later
 		case "hold", "wait":
@@ -1806,26 +1993,23 @@ func (at *AutoTrader) startDrawdownMonitor() {
 // checkPositionDrawdown checks position drawdown situation
 func (at *AutoTrader) checkPositionDrawdown() {
 	// Get current positions
-	positions, err := at.trader.GetPositions()
+	positions, err := AsTraderV2(at.trader).GetPositionsV2()
 	if err != nil {
 		logger.Infof("❌ Drawdown monitoring: failed to get positions: %v", err)
 		return
 	}
 
 	for _, pos := range positions {
-		symbol := pos["symbol"].(string)
-		side := pos["side"].(string)
-		entryPrice := pos["entryPrice"].(float64)
-		markPrice := pos["markPrice"].(float64)
-		quantity := pos["positionAmt"].(float64)
-		if quantity < 0 {
-			quantity = -quantity // Short position quantity is negative, convert to positive
+		symbol, side := pos.Symbol, pos.Side
+		entryPrice, markPrice := pos.EntryPrice, pos.MarkPrice
+		if entryPrice <= 0 {
+			continue
 		}
 
 		// Calculate current P&L percentage
 		leverage := 10 // Default value
-		if lev, ok := pos["leverage"].(float64); ok {
-			leverage = int(lev)
+		if pos.Leverage > 0 {
+			leverage = pos.Leverage
 		}
 
 		var currentPnLPct float64
@@ -1835,6 +2019,11 @@ func (at *AutoTrader) checkPositionDrawdown() {
 			currentPnLPct = ((entryPrice - markPrice) / entryPrice) * float64(leverage) * 100
 		}
 
+		// Positions with a trailing stop are managed by it
+		if at.trailingStops.has(symbol, side) {
+			continue
+		}
+
 		// Construct unique position identifier (distinguish long/short)
 		posKey := symbol + "_" + side
 
@@ -1940,6 +2129,15 @@ func (at *AutoTrader) ClearPeakPnLCache(symbol, side string) {
 	delete(at.peakPnLCache, posKey)
 }
 
+// hasOrderSync whether fills of the exchange are recorded by OrderSync from its trade history
+func (at *AutoTrader) hasOrderSync() bool {
+	switch at.exchange {
+	case "binance", "lighter", "hyperliquid", "bybit", "okx", "bitget", "aster", "kucoin", "gate", "paper":
+		return true
+	}
+	return false
+}
+
 // recordAndConfirmOrder polls order status for actual fill data and records position
 // action: open_long, open_short, close_long, close_short
 // entryPrice: entry price when closing (0 when opening)
@@ -1981,8 +2179,7 @@ func (at *AutoTrader) recordAndConfirmOrder(orderResult map[string]interface{},
 
 	// Exchanges with OrderSync: Skip immediate order recording, let OrderSync handle it
 	// This ensures accurate data from GetTrades API and avoids duplicate records
-	switch at.exchange {
-	case "binance", "lighter", "hyperliquid", "bybit", "okx", "bitget", "aster", "kucoin", "gate":
+	if at.hasOrderSync() {
 		logger.Infof("  📝 Order submitted (id: %s), will be synced by OrderSync", orderID)
 		return
 	}
@@ -1996,45 +2193,36 @@ func (at *AutoTrader) recordAndConfirmOrder(orderResult map[string]interface{},
 	}
 
 	// Wait for order to be filled and get actual fill data
-	time.Sleep(500 * time.Millisecond)
-	for i := 0; i < 5; i++ {
-		status, err := at.trader.GetOrderStatus(symbol, orderID)
-		if err == nil {
-			statusStr, _ := status["status"].(string)
-			if statusStr == "FILLED" {
-				// Get actual fill price
-				if avgPrice, ok := status["avgPrice"].(float64); ok && avgPrice > 0 {
-					actualPrice = avgPrice
-				}
-				// Get actual executed quantity
-				if execQty, ok := status["executedQty"].(float64); ok && execQty > 0 {
-					actualQty = execQty
-				}
-				// Get commission/fee
-				if commission, ok := status["commission"].(float64); ok {
-					fee = commission
-				}
-				logger.Infof("  ✅ Order filled: avgPrice=%.6f, qty=%.6f, fee=%.6f", actualPrice, actualQty, fee)
+	if status := at.awaitOrderResult(symbol, orderID); status != nil {
+		if status.Status == "FILLED" {
+			// Get actual fill price
+			if status.AvgPrice > 0 {
+				actualPrice = status.AvgPrice
+			}
+			// Get actual executed quantity
+			if status.ExecutedQty > 0 {
+				actualQty = status.ExecutedQty
+			}
+			// Get commission/fee
+			fee = status.Commission
+			logger.Infof("  ✅ Order filled: avgPrice=%.6f, qty=%.6f, fee=%.6f", actualPrice, actualQty, fee)
 
-				// Update order status to FILLED
-				if err := at.store.Order().UpdateOrderStatus(orderRecord.ID, "FILLED", actualQty, actualPrice, fee); err != nil {
-					logger.Infof("  ⚠️ Failed to update order status: %v", err)
-				}
+			// Update order status to FILLED
+			if err := at.store.Order().UpdateOrderStatus(orderRecord.ID, "FILLED", actualQty, actualPrice, fee); err != nil {
+				logger.Infof("  ⚠️ Failed to update order status: %v", err)
+			}
 
-				// Record fill details
-				at.recordOrderFill(orderRecord.ID, orderID, symbol, action, actualPrice, actualQty, fee)
-				break
-			} else if statusStr == "CANCELED" || statusStr == "EXPIRED" || statusStr == "REJECTED" {
-				logger.Infof("  ⚠️ Order %s, skipping position record", statusStr)
+			// Record fill details
+			at.recordOrderFill(orderRecord.ID, orderID, symbol, action, actualPrice, actualQty, fee)
+		} else {
+			logger.Infof("  ⚠️ Order %s, skipping position record", status.Status)
 
-				// Update order status
-				if err := at.store.Order().UpdateOrderStatus(orderRecord.ID, statusStr, 0, 0, 0); err != nil {
-					logger.Infof("  ⚠️ Failed to update order status: %v", err)
-				}
-				return
+			// Update order status
+			if err := at.store.Order().UpdateOrderStatus(orderRecord.ID, status.Status, 0, 0, 0); err != nil {
+				logger.Infof("  ⚠️ Failed to update order status: %v", err)
 			}
+			return
 		}
-		time.Sleep(500 * time.Millisecond)
 	}
 
 	// Normalize symbol for position record consistency
@@ -2088,6 +2276,7 @@ func (at *AutoTrader) recordPositionChange(orderID, symbol, side, action string,
 			logger.Infof("  ⚠️ Failed to record position: %v", err)
 		} else {
 			logger.Infof("  📊 Position recorded [%s] %s %s @ %.4f", at.id[:8], symbol, side, price)
+			at.saveTradeMemory(pos)
 		}
 
 	case "close_long", "close_short":
@@ -2109,6 +2298,40 @@ func (at *AutoTrader) recordPositionChange(orderID, symbol, side, action string,
 	}
 }
 
+// saveTradeMemory stores the market state at entry of a newly opened position, so that trades opened
+// in a similar state can be retrieved for the AI once it is closed. The last cycle's market data is
+// used; it is only fetched again when trade memory is enabled.
+func (at *AutoTrader) saveTradeMemory(pos *store.TraderPosition) {
+	at.cycleMarketDataMutex.RLock()
+	data := at.cycleMarketData[pos.Symbol]
+	at.cycleMarketDataMutex.RUnlock()
+	if data == nil {
+		if at.strategyEngine == nil || !at.strategyEngine.GetConfig().Indicators.EnableTradeMemory {
+			return
+		}
+		var err error
+		if data, err = market.Get(pos.Symbol); err != nil {
+			logger.Infof("  ⚠️ Failed to get market data for trade memory: %v", err)
+			return
+		}
+	}
+
+	features, err := json.Marshal(kernel.ExtractTradeFeatures(data))
+	if err != nil {
+		return
+	}
+	memory := &store.TradeMemory{
+		TraderID:   at.id,
+		PositionID: pos.ID,
+		Symbol:     pos.Symbol,
+		Side:       pos.Side,
+		Features:   string(features),
+	}
+	if err := at.store.TradeMemory().Save(memory); err != nil {
+		logger.Infof("  ⚠️ Failed to save trade memory: %v", err)
+	}
+}
+
 // createOrderRecord creates an order record struct from order details
 func (at *AutoTrader) createOrderRecord(orderID, symbol, action, positionSide string, quantity, price float64, leverage int) *store.TraderOrder {
 	// Determine order type (market for auto trader)
@@ -560,9 +560,7 @@ func (at *AutoTrader) InitializeGrid() error {
 // calculateDefaultBounds calculates default bounds based on price
 func (at *AutoTrader) calculateDefaultBounds(price float64, config *store.GridStrategyConfig) {
 	// Default: ±3% from current price
-	multiplier := 0.03 * float64(config.GridCount) / 10
-	at.gridState.UpperPrice = price * (1 + multiplier)
-	at.gridState.LowerPrice = price * (1 - multiplier)
+	at.gridState.UpperPrice, at.gridState.LowerPrice = kernel.DefaultGridBounds(price, config.GridCount)
 }
 
 // calculateATRBounds calculates bounds using ATR
@@ -571,66 +569,12 @@ func (at *AutoTrader) calculateATRBounds(price float64, mktData *market.Data, co
 	if mktData.LongerTermContext != nil {
 		atr = mktData.LongerTermContext.ATR14
 	}
-
-	if atr <= 0 {
-		at.calculateDefaultBounds(price, config)
-		return
-	}
-
-	multiplier := config.ATRMultiplier
-	if multiplier <= 0 {
-		multiplier = 2.0
-	}
-
-	halfRange := atr * multiplier
-	at.gridState.UpperPrice = price + halfRange
-	at.gridState.LowerPrice = price - halfRange
+	at.gridState.UpperPrice, at.gridState.LowerPrice = kernel.ATRGridBounds(price, atr, config)
 }
 
 // initializeGridLevels creates the grid level structure
 func (at *AutoTrader) initializeGridLevels(currentPrice float64, config *store.GridStrategyConfig) {
-	levels := make([]kernel.GridLevelInfo, config.GridCount)
-	totalWeight := 0.0
-	weights := make([]float64, config.GridCount)
-
-	// Calculate weights based on distribution
-	for i := 0; i < config.GridCount; i++ {
-		switch config.Distribution {
-		case "gaussian":
-			// Gaussian distribution - more weight in the middle
-			center := float64(config.GridCount-1) / 2
-			sigma := float64(config.GridCount) / 4
-			weights[i] = math.Exp(-math.Pow(float64(i)-center, 2) / (2 * sigma * sigma))
-		case "pyramid":
-			// Pyramid - more weight at bottom
-			weights[i] = float64(config.GridCount - i)
-		default: // uniform
-			weights[i] = 1.0
-		}
-		totalWeight += weights[i]
-	}
-
-	// Create levels
-	for i := 0; i < config.GridCount; i++ {
-		price := at.gridState.LowerPrice + float64(i)*at.gridState.GridSpacing
-		allocatedUSD := config.TotalInvestment * weights[i] / totalWeight
-
-		// Determine initial side (below current price = buy, above = sell)
-		side := "buy"
-		if price > currentPrice {
-			side = "sell"
-		}
-
-		levels[i] = kernel.GridLevelInfo{
-			Index:        i,
-			Price:        price,
-			State:        "empty",
-			Side:         side,
-			AllocatedUSD: allocatedUSD,
-		}
-	}
-
-	at.gridState.Levels = levels
+	at.gridState.Levels = kernel.BuildGridLevels(at.gridState.LowerPrice, at.gridState.GridSpacing, currentPrice, config)
 
 	// Apply direction-based side assignment if enabled
 	if config.EnableDirectionAdjust {
@@ -641,87 +585,8 @@ func (at *AutoTrader) initializeGridLevels(currentPrice float64, config *store.G
 // applyGridDirection adjusts grid level sides based on the current direction
 // This redistributes buy/sell levels according to the direction bias ratio
 func (at *AutoTrader) applyGridDirection(currentPrice float64) {
-	config := at.gridState.Config
 	direction := at.gridState.CurrentDirection
-
-	// Get bias ratio from config, default to 0.7 (70%/30%)
-	biasRatio := config.DirectionBiasRatio
-	if biasRatio <= 0 || biasRatio > 1 {
-		biasRatio = 0.7
-	}
-
-	buyRatio, _ := direction.GetBuySellRatio(biasRatio)
-
-	// Calculate how many levels should be buy vs sell based on direction
-	totalLevels := len(at.gridState.Levels)
-	targetBuyLevels := int(float64(totalLevels) * buyRatio)
-
-	// For neutral: use price-based assignment (buy below, sell above)
-	if direction == market.GridDirectionNeutral {
-		for i := range at.gridState.Levels {
-			if at.gridState.Levels[i].Price <= currentPrice {
-				at.gridState.Levels[i].Side = "buy"
-			} else {
-				at.gridState.Levels[i].Side = "sell"
-			}
-		}
-		return
-	}
-
-	// For long/long_bias: more buy levels
-	// For short/short_bias: more sell levels
-	switch direction {
-	case market.GridDirectionLong:
-		// 100% buy - all levels are buy
-		for i := range at.gridState.Levels {
-			at.gridState.Levels[i].Side = "buy"
-		}
-
-	case market.GridDirectionShort:
-		// 100% sell - all levels are sell
-		for i := range at.gridState.Levels {
-			at.gridState.Levels[i].Side = "sell"
-		}
-
-	case market.GridDirectionLongBias, market.GridDirectionShortBias:
-		// Assign sides based on position relative to current price
-		// For long_bias: keep all below as buy, convert some above to buy
-		// For short_bias: keep all above as sell, convert some below to sell
-		buyCount := 0
-		sellCount := 0
-
-		for i := range at.gridState.Levels {
-			needMoreBuys := buyCount < targetBuyLevels
-			needMoreSells := sellCount < (totalLevels - targetBuyLevels)
-
-			if at.gridState.Levels[i].Price <= currentPrice {
-				// Level below or at current price
-				if needMoreBuys {
-					at.gridState.Levels[i].Side = "buy"
-					buyCount++
-				} else {
-					at.gridState.Levels[i].Side = "sell"
-					sellCount++
-				}
-			} else {
-				// Level above current price
-				if needMoreSells && direction == market.GridDirectionShortBias {
-					at.gridState.Levels[i].Side = "sell"
-					sellCount++
-				} else if needMoreBuys && direction == market.GridDirectionLongBias {
-					at.gridState.Levels[i].Side = "buy"
-					buyCount++
-				} else if needMoreSells {
-					at.gridState.Levels[i].Side = "sell"
-					sellCount++
-				} else {
-					at.gridState.Levels[i].Side = "buy"
-					buyCount++
-				}
-			}
-		}
-	}
-
+	buyRatio := kernel.ApplyGridDirection(at.gridState.Levels, direction, at.gridState.Config.DirectionBiasRatio, currentPrice)
 	logger.Infof("[Grid] Applied direction %s: buy_ratio=%.0f%%, levels reconfigured",
 		direction, buyRatio*100)
 }
@@ -1200,6 +1065,14 @@ func (at *AutoTrader) adjustGrid(d *kernel.Decision) error {
 func (at *AutoTrader) syncGridState() {
 	gridConfig := at.config.StrategyConfig.GridConfig
 
+	// With a connected user data stream, fills and cancels are applied as they happen
+	// (applyGridOrderEvent); REST reconciliation is only needed after (re)connecting
+	if hub := at.getUserData(); hub != nil && hub.isSynced() {
+		at.checkAndExecuteStopLoss()
+		at.autoAdjustGrid()
+		return
+	}
+
 	// Get open orders from exchange
 	openOrders, err := at.trader.GetOpenOrders(gridConfig.Symbol)
 	if err != nil {
@@ -1263,6 +1136,9 @@ func (at *AutoTrader) syncGridState() {
 		}
 	}
 	at.gridState.mu.Unlock()
+	if hub := at.getUserData(); hub != nil && err == nil {
+		hub.setSynced(true)
+	}
 
 	logger.Debugf("[Grid] Synced state: position=%.4f, orders=%d", currentPositionSize, len(openOrders))
 
@@ -1482,9 +1358,7 @@ func (at *AutoTrader) autoAdjustGrid() {
 // calculateDefaultBoundsLocked calculates default bounds (caller must hold lock)
 func (at *AutoTrader) calculateDefaultBoundsLocked(price float64, config *store.GridStrategyConfig) {
 	// Default: ±3% from current price, scaled by grid count
-	multiplier := 0.03 * float64(config.GridCount) / 10
-	at.gridState.UpperPrice = price * (1 + multiplier)
-	at.gridState.LowerPrice = price * (1 - multiplier)
+	at.gridState.UpperPrice, at.gridState.LowerPrice = kernel.DefaultGridBounds(price, config.GridCount)
 }
 
 // calculateATRBoundsLocked calculates bounds using ATR (caller must hold lock)
@@ -1493,66 +1367,12 @@ func (at *AutoTrader) calculateATRBoundsLocked(price float64, mktData *market.Da
 	if mktData.LongerTermContext != nil {
 		atr = mktData.LongerTermContext.ATR14
 	}
-
-	if atr <= 0 {
-		at.calculateDefaultBoundsLocked(price, config)
-		return
-	}
-
-	multiplier := config.ATRMultiplier
-	if multiplier <= 0 {
-		multiplier = 2.0
-	}
-
-	halfRange := atr * multiplier
-	at.gridState.UpperPrice = price + halfRange
-	at.gridState.LowerPrice = price - halfRange
+	at.gridState.UpperPrice, at.gridState.LowerPrice = kernel.ATRGridBounds(price, atr, config)
 }
 
 // initializeGridLevelsLocked creates the grid level structure (caller must hold lock)
 func (at *AutoTrader) initializeGridLevelsLocked(currentPrice float64, config *store.GridStrategyConfig) {
-	levels := make([]kernel.GridLevelInfo, config.GridCount)
-	totalWeight := 0.0
-	weights := make([]float64, config.GridCount)
-
-	// Calculate weights based on distribution
-	for i := 0; i < config.GridCount; i++ {
-		switch config.Distribution {
-		case "gaussian":
-			// Gaussian distribution - more weight in the middle
-			center := float64(config.GridCount-1) / 2
-			sigma := float64(config.GridCount) / 4
-			weights[i] = math.Exp(-math.Pow(float64(i)-center, 2) / (2 * sigma * sigma))
-		case "pyramid":
-			// Pyramid - more weight at bottom
-			weights[i] = float64(config.GridCount - i)
-		default: // uniform
-			weights[i] = 1.0
-		}
-		totalWeight += weights[i]
-	}
-
-	// Create levels
-	for i := 0; i < config.GridCount; i++ {
-		price := at.gridState.LowerPrice + float64(i)*at.gridState.GridSpacing
-		allocatedUSD := config.TotalInvestment * weights[i] / totalWeight
-
-		// Determine initial side (below current price = buy, above = sell)
-		side := "buy"
-		if price > currentPrice {
-			side = "sell"
-		}
-
-		levels[i] = kernel.GridLevelInfo{
-			Index:        i,
-			Price:        price,
-			State:        "empty",
-			Side:         side,
-			AllocatedUSD: allocatedUSD,
-		}
-	}
-
-	at.gridState.Levels = levels
+	at.gridState.Levels = kernel.BuildGridLevels(at.gridState.LowerPrice, at.gridState.GridSpacing, currentPrice, config)
 
 	// Apply direction-based side assignment if enabled (note: caller holds lock)
 	if config.EnableDirectionAdjust {
@@ -1562,76 +1382,7 @@ func (at *AutoTrader) initializeGridLevelsLocked(currentPrice float64, config *s
 
 // applyGridDirectionLocked adjusts grid level sides based on the current direction (caller must hold lock)
 func (at *AutoTrader) applyGridDirectionLocked(currentPrice float64) {
-	config := at.gridState.Config
-	direction := at.gridState.CurrentDirection
-
-	// Get bias ratio from config, default to 0.7 (70%/30%)
-	biasRatio := config.DirectionBiasRatio
-	if biasRatio <= 0 || biasRatio > 1 {
-		biasRatio = 0.7
-	}
-
-	buyRatio, _ := direction.GetBuySellRatio(biasRatio)
-
-	// For neutral: use price-based assignment (buy below, sell above)
-	if direction == market.GridDirectionNeutral {
-		for i := range at.gridState.Levels {
-			if at.gridState.Levels[i].Price <= currentPrice {
-				at.gridState.Levels[i].Side = "buy"
-			} else {
-				at.gridState.Levels[i].Side = "sell"
-			}
-		}
-		return
-	}
-
-	totalLevels := len(at.gridState.Levels)
-	targetBuyLevels := int(float64(totalLevels) * buyRatio)
-
-	switch direction {
-	case market.GridDirectionLong:
-		for i := range at.gridState.Levels {
-			at.gridState.Levels[i].Side = "buy"
-		}
-
-	case market.GridDirectionShort:
-		for i := range at.gridState.Levels {
-			at.gridState.Levels[i].Side = "sell"
-		}
-
-	case market.GridDirectionLongBias, market.GridDirectionShortBias:
-		buyCount := 0
-		sellCount := 0
-
-		for i := range at.gridState.Levels {
-			needMoreBuys := buyCount < targetBuyLevels
-			needMoreSells := sellCount < (totalLevels - targetBuyLevels)
-
-			if at.gridState.Levels[i].Price <= currentPrice {
-				if needMoreBuys {
-					at.gridState.Levels[i].Side = "buy"
-					buyCount++
-				} else {
-					at.gridState.Levels[i].Side = "sell"
-					sellCount++
-				}
-			} else {
-				if needMoreSells && direction == market.GridDirectionShortBias {
-					at.gridState.Levels[i].Side = "sell"
-					sellCount++
-				} else if needMoreBuys && direction == market.GridDirectionLongBias {
-					at.gridState.Levels[i].Side = "buy"
-					buyCount++
-				} else if needMoreSells {
-					at.gridState.Levels[i].Side = "sell"
-					sellCount++
-				} else {
-					at.gridState.Levels[i].Side = "buy"
-					buyCount++
-				}
-			}
-		}
-	}
+	kernel.ApplyGridDirection(at.gridState.Levels, at.gridState.CurrentDirection, at.gridState.Config.DirectionBiasRatio, currentPrice)
 }
 
 // GridRiskInfo contains risk information for frontend display
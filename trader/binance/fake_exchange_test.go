@@ -0,0 +1,151 @@
+package binance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nofx/trader/testutil"
+	"nofx/trader/testutil/fakeexchange"
+	"nofx/trader/types"
+)
+
+// newFakeExchangeTrader creates a hedge mode trader against an in-memory exchange
+func newFakeExchangeTrader(t *testing.T) (*FuturesTrader, *fakeexchange.Exchange) {
+	ex := fakeexchange.New()
+	server := fakeexchange.NewBinanceServer(ex)
+	t.Cleanup(server.Close)
+
+	client := futures.NewClient("test_api_key", "test_secret_key")
+	client.BaseURL = server.URL
+	client.HTTPClient = server.Client()
+
+	trader := &FuturesTrader{client: client, cacheDuration: 0}
+	require.NoError(t, trader.setDualSidePosition())
+	return trader, ex
+}
+
+// TestFuturesTrader_FakeExchange runs the common suite against the stateful fake exchange
+func TestFuturesTrader_FakeExchange(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	suite := testutil.NewTraderTestSuite(t, trader)
+	defer suite.Cleanup()
+	suite.BeforeEach = ex.SuiteSetup()
+	suite.SeedAccount = ex.SuiteSeed()
+
+	suite.RunAllTests()
+
+	assert.InDelta(t, -0.49, ex.Position("BTCUSDT", fakeexchange.SideShort).Amount, 1e-9, "CloseShort should have bought back 0.01")
+}
+
+func TestFuturesTrader_FakeExchangeFaults(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+
+	t.Run("rate limit", func(t *testing.T) {
+		ex.Inject(fakeexchange.FaultRateLimit, "/fapi/v2/account", 1)
+		_, err := trader.GetBalance()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "-1003")
+
+		balance, err := trader.GetBalance()
+		require.NoError(t, err)
+		assert.Equal(t, 10000.0, balance["totalWalletBalance"])
+	})
+
+	t.Run("server error leaves the position open", func(t *testing.T) {
+		ex.Reset()
+		ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 50000)
+		ex.Inject(fakeexchange.FaultServerError, "/fapi/v1/order", 1)
+
+		_, err := trader.CloseLong("BTCUSDT", 0.1)
+		require.Error(t, err)
+		assert.Equal(t, 0.1, ex.Position("BTCUSDT", fakeexchange.SideLong).Amount)
+
+		_, err = trader.CloseLong("BTCUSDT", 0.1)
+		require.NoError(t, err)
+		assert.Empty(t, ex.Positions())
+	})
+
+	t.Run("partial fill", func(t *testing.T) {
+		ex.Reset()
+		ex.SetPartialFill(0.5)
+
+		// Marketable buy: half fills at 50000, the rest rests at 50100
+		result, err := trader.PlaceLimitOrder(&types.LimitOrderRequest{Symbol: "BTCUSDT", Side: "BUY", Price: 50100, Quantity: 0.01})
+		require.NoError(t, err)
+		assert.Equal(t, "PARTIALLY_FILLED", result.Status)
+
+		status, err := trader.GetOrderStatus("BTCUSDT", result.OrderID)
+		require.NoError(t, err)
+		assert.Equal(t, "PARTIALLY_FILLED", status["status"])
+		assert.InDelta(t, 0.005, status["executedQty"], 1e-9)
+
+		ex.SetPrice("BTCUSDT", 50050)
+		status, err = trader.GetOrderStatus("BTCUSDT", result.OrderID)
+		require.NoError(t, err)
+		assert.Equal(t, "FILLED", status["status"])
+		assert.InDelta(t, 0.01, status["executedQty"], 1e-9)
+		assert.InDelta(t, 50050.0, status["avgPrice"], 1e-9)
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		ex.Reset()
+		// Local clock 10s ahead of the exchange
+		ex.SetClockSkew(-10 * time.Second)
+
+		_, err := trader.GetBalance()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "-1021")
+
+		syncBinanceServerTime(trader.client)
+		_, err = trader.GetBalance()
+		assert.NoError(t, err)
+	})
+}
+
+func TestFuturesTrader_FakeExchangeTrailingStop(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 50000)
+
+	require.Error(t, trader.SetTrailingStop("BTCUSDT", "LONG", 0.1, 20, 0), "callback above 10% should be rejected")
+	require.NoError(t, trader.SetTrailingStop("BTCUSDT", "LONG", 0.1, 1, 0))
+	require.NoError(t, trader.SetStopLoss("BTCUSDT", "LONG", 0.1, 48000))
+
+	// Canceling stop-losses keeps the trailing stop and vice versa
+	require.NoError(t, trader.CancelStopLossOrders("BTCUSDT"))
+	orders := ex.Orders(func(o fakeexchange.Order) bool { return o.IsOpen() })
+	require.Len(t, orders, 1)
+	assert.Equal(t, fakeexchange.OrderTypeTrailingStop, orders[0].Type)
+	assert.Equal(t, 1.0, orders[0].CallbackRate)
+
+	ex.SetPrice("BTCUSDT", 52000)
+	ex.SetPrice("BTCUSDT", 51400)
+	assert.Equal(t, 0.0, ex.Position("BTCUSDT", fakeexchange.SideLong).Amount, "1% retrace from 52000 should close the long")
+
+	ex.SetPosition("BTCUSDT", fakeexchange.SideShort, 0.1, 51400)
+	require.NoError(t, trader.SetTrailingStop("BTCUSDT", "SHORT", 0.1, 2, 50000))
+	require.NoError(t, trader.CancelTrailingStopOrders("BTCUSDT"))
+	assert.Empty(t, ex.Orders(func(o fakeexchange.Order) bool { return o.IsOpen() }))
+}
+
+func TestFuturesTrader_FakeExchangePartialTakeProfit(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 50000)
+
+	require.NoError(t, trader.SetPartialTakeProfit("BTCUSDT", "LONG", 0.03, 51000))
+	require.NoError(t, trader.SetPartialTakeProfit("BTCUSDT", "LONG", 0.04, 52000))
+
+	orders := ex.Orders(func(o fakeexchange.Order) bool { return o.IsOpen() })
+	require.Len(t, orders, 2)
+	for _, o := range orders {
+		assert.Equal(t, fakeexchange.OrderTypeTakeProfit, o.Type)
+		assert.False(t, o.ClosePosition)
+	}
+
+	ex.SetPrice("BTCUSDT", 51000)
+	assert.InDelta(t, 0.07, ex.Position("BTCUSDT", fakeexchange.SideLong).Amount, 1e-9, "first level closes 0.03")
+	ex.SetPrice("BTCUSDT", 52000)
+	assert.InDelta(t, 0.03, ex.Position("BTCUSDT", fakeexchange.SideLong).Amount, 1e-9, "second level closes 0.04")
+}
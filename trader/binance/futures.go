@@ -60,6 +60,9 @@ type FuturesTrader struct {
 
 	// Cache validity period (15 seconds)
 	cacheDuration time.Duration
+
+	// User data stream base URL, empty for the production/testnet endpoint
+	userStreamURL string
 }
 
 // NewFuturesTrader creates futures trader
@@ -1049,6 +1052,115 @@ func (t *FuturesTrader) SetTakeProfit(symbol string, positionSide string, quanti
 	return nil
 }
 
+var _ types.PartialTakeProfitTrader = (*FuturesTrader)(nil)
+
+// SetPartialTakeProfit places a TAKE_PROFIT_MARKET Algo Order for quantity instead of the whole position
+func (t *FuturesTrader) SetPartialTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	var side futures.SideType
+	var posSide futures.PositionSideType
+
+	if positionSide == "LONG" {
+		side = futures.SideTypeSell
+		posSide = futures.PositionSideTypeLong
+	} else {
+		side = futures.SideTypeBuy
+		posSide = futures.PositionSideTypeShort
+	}
+
+	quantityStr, err := t.FormatQuantity(symbol, quantity)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.NewCreateAlgoOrderService().
+		Symbol(symbol).
+		Side(side).
+		PositionSide(posSide).
+		Type(futures.AlgoOrderTypeTakeProfitMarket).
+		TriggerPrice(fmt.Sprintf("%.8f", takeProfitPrice)).
+		Quantity(quantityStr).
+		WorkingType(futures.WorkingTypeContractPrice).
+		ClientAlgoId(getBrOrderID()).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to set partial take-profit: %w", err)
+	}
+
+	logger.Infof("  Partial take-profit set (Algo Order): %s @ %.4f", quantityStr, takeProfitPrice)
+	return nil
+}
+
+var _ types.TrailingStopper = (*FuturesTrader)(nil)
+
+// SetTrailingStop places a TRAILING_STOP_MARKET Algo Order closing quantity of the position
+// Binance accepts callback rates from 0.1% to 10%
+func (t *FuturesTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackPct, activationPrice float64) error {
+	if callbackPct < 0.1 || callbackPct > 10 {
+		return fmt.Errorf("trailing stop callback %.2f%% out of range (0.1%% - 10%%)", callbackPct)
+	}
+
+	var side futures.SideType
+	var posSide futures.PositionSideType
+
+	if positionSide == "LONG" {
+		side = futures.SideTypeSell
+		posSide = futures.PositionSideTypeLong
+	} else {
+		side = futures.SideTypeBuy
+		posSide = futures.PositionSideTypeShort
+	}
+
+	quantityStr, err := t.FormatQuantity(symbol, quantity)
+	if err != nil {
+		return err
+	}
+
+	service := t.client.NewCreateAlgoOrderService().
+		Symbol(symbol).
+		Side(side).
+		PositionSide(posSide).
+		Type(futures.AlgoOrderTypeTrailingStopMarket).
+		Quantity(quantityStr).
+		CallbackRate(fmt.Sprintf("%.1f", callbackPct)).
+		WorkingType(futures.WorkingTypeContractPrice).
+		ClientAlgoId(getBrOrderID())
+	if activationPrice > 0 {
+		service = service.ActivationPrice(fmt.Sprintf("%.8f", activationPrice))
+	}
+
+	if _, err := service.Do(context.Background()); err != nil {
+		return fmt.Errorf("failed to set trailing stop: %w", err)
+	}
+
+	logger.Infof("  Trailing stop set (Algo Order): callback %.1f%%, activation %.4f", callbackPct, activationPrice)
+	return nil
+}
+
+// CancelTrailingStopOrders cancels only trailing stop Algo Orders
+func (t *FuturesTrader) CancelTrailingStopOrders(symbol string) error {
+	algoOrders, err := t.client.NewListOpenAlgoOrdersService().
+		Symbol(symbol).
+		Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get open algo orders: %w", err)
+	}
+
+	for _, algoOrder := range algoOrders {
+		if algoOrder.OrderType != futures.AlgoOrderTypeTrailingStopMarket {
+			continue
+		}
+		_, err := t.client.NewCancelAlgoOrderService().
+			AlgoID(algoOrder.AlgoId).
+			Do(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to cancel trailing stop (Algo ID %d): %w", algoOrder.AlgoId, err)
+		}
+		logger.Infof("  ✓ Canceled trailing stop order (Algo ID: %d)", algoOrder.AlgoId)
+	}
+
+	return nil
+}
+
 // GetMinNotional gets minimum notional value (Binance requirement)
 func (t *FuturesTrader) GetMinNotional(symbol string) float64 {
 	// Use conservative default value of 10 USDT to ensure order passes exchange validation
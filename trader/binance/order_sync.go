@@ -181,95 +181,9 @@ func (t *FuturesTrader) SyncOrdersFromBinance(traderID string, exchangeID string
 			continue // Trade already exists, skip
 		}
 
-		// Normalize symbol
-		symbol := market.Normalize(trade.Symbol)
-
-		// Determine order action based on side and position side
-		orderAction := t.determineOrderAction(trade.Side, trade.PositionSide, trade.RealizedPnL)
-
-		// Determine position side for position builder
-		positionSide := trade.PositionSide
-		if positionSide == "" || positionSide == "BOTH" {
-			// Infer from order action
-			if strings.Contains(orderAction, "long") {
-				positionSide = "LONG"
-			} else {
-				positionSide = "SHORT"
-			}
-		}
-
-		// Normalize side
-		side := strings.ToUpper(trade.Side)
-
-		// Create order record - use Unix milliseconds UTC
-		tradeTimeMs := trade.Time.UTC().UnixMilli()
-		orderRecord := &store.TraderOrder{
-			TraderID:        traderID,
-			ExchangeID:      exchangeID,
-			ExchangeType:    exchangeType,
-			ExchangeOrderID: trade.TradeID,
-			Symbol:          symbol,
-			Side:            side,
-			PositionSide:    positionSide,
-			Type:            "MARKET",
-			OrderAction:     orderAction,
-			Quantity:        trade.Quantity,
-			Price:           trade.Price,
-			Status:          "FILLED",
-			FilledQuantity:  trade.Quantity,
-			AvgFillPrice:    trade.Price,
-			Commission:      trade.Fee,
-			FilledAt:        tradeTimeMs,
-			CreatedAt:       tradeTimeMs,
-			UpdatedAt:       tradeTimeMs,
-		}
-
-		// Insert order record
-		if err := orderStore.CreateOrder(orderRecord); err != nil {
-			logger.Infof("  ⚠️ Failed to sync trade %s: %v", trade.TradeID, err)
-			continue
-		}
-
-		// Create fill record - use Unix milliseconds UTC
-		fillRecord := &store.TraderFill{
-			TraderID:        traderID,
-			ExchangeID:      exchangeID,
-			ExchangeType:    exchangeType,
-			OrderID:         orderRecord.ID,
-			ExchangeOrderID: trade.TradeID,
-			ExchangeTradeID: trade.TradeID,
-			Symbol:          symbol,
-			Side:            side,
-			Price:           trade.Price,
-			Quantity:        trade.Quantity,
-			QuoteQuantity:   trade.Price * trade.Quantity,
-			Commission:      trade.Fee,
-			CommissionAsset: "USDT",
-			RealizedPnL:     trade.RealizedPnL,
-			IsMaker:         false,
-			CreatedAt:       tradeTimeMs,
-		}
-
-		if err := orderStore.CreateFill(fillRecord); err != nil {
-			logger.Infof("  ⚠️ Failed to sync fill for trade %s: %v", trade.TradeID, err)
+		if t.recordTrade(orderStore, posBuilder, traderID, exchangeID, exchangeType, trade) {
+			syncedCount++
 		}
-
-		// Create/update position record using PositionBuilder
-		if err := posBuilder.ProcessTrade(
-			traderID, exchangeID, exchangeType,
-			symbol, positionSide, orderAction,
-			trade.Quantity, trade.Price, trade.Fee, trade.RealizedPnL,
-			tradeTimeMs, trade.TradeID,
-		); err != nil {
-			logger.Infof("  ⚠️ Failed to sync position for trade %s: %v", trade.TradeID, err)
-		} else {
-			logger.Infof("  📍 Position updated for trade: %s (action: %s, qty: %.6f)", trade.TradeID, orderAction, trade.Quantity)
-		}
-
-		syncedCount++
-		logger.Infof("  ✅ Synced trade: %s %s %s qty=%.6f price=%.6f pnl=%.2f fee=%.6f action=%s time=%s(UTC)",
-			trade.TradeID, symbol, side, trade.Quantity, trade.Price, trade.RealizedPnL, trade.Fee, orderAction,
-			trade.Time.UTC().Format("01-02 15:04:05"))
 	}
 
 	// Update lastSyncTime to the LATEST trade time (not current time!)
@@ -290,6 +204,127 @@ func (t *FuturesTrader) SyncOrdersFromBinance(traderID string, exchangeID string
 	return nil
 }
 
+// recordTrade stores a trade as an order, a fill and a position update; returns false if the
+// order couldn't be stored
+func (t *FuturesTrader) recordTrade(orderStore *store.OrderStore, posBuilder *store.PositionBuilder, traderID, exchangeID, exchangeType string, trade types.TradeRecord) bool {
+	// Normalize symbol
+	symbol := market.Normalize(trade.Symbol)
+
+	// Determine order action based on side and position side
+	orderAction := t.determineOrderAction(trade.Side, trade.PositionSide, trade.RealizedPnL)
+
+	// Determine position side for position builder
+	positionSide := trade.PositionSide
+	if positionSide == "" || positionSide == "BOTH" {
+		// Infer from order action
+		if strings.Contains(orderAction, "long") {
+			positionSide = "LONG"
+		} else {
+			positionSide = "SHORT"
+		}
+	}
+
+	// Normalize side
+	side := strings.ToUpper(trade.Side)
+
+	// Create order record - use Unix milliseconds UTC
+	tradeTimeMs := trade.Time.UTC().UnixMilli()
+	orderRecord := &store.TraderOrder{
+		TraderID:        traderID,
+		ExchangeID:      exchangeID,
+		ExchangeType:    exchangeType,
+		ExchangeOrderID: trade.TradeID,
+		Symbol:          symbol,
+		Side:            side,
+		PositionSide:    positionSide,
+		Type:            "MARKET",
+		OrderAction:     orderAction,
+		Quantity:        trade.Quantity,
+		Price:           trade.Price,
+		Status:          "FILLED",
+		FilledQuantity:  trade.Quantity,
+		AvgFillPrice:    trade.Price,
+		Commission:      trade.Fee,
+		FilledAt:        tradeTimeMs,
+		CreatedAt:       tradeTimeMs,
+		UpdatedAt:       tradeTimeMs,
+	}
+
+	// Insert order record
+	if err := orderStore.CreateOrder(orderRecord); err != nil {
+		logger.Infof("  ⚠️ Failed to sync trade %s: %v", trade.TradeID, err)
+		return false
+	}
+
+	// Create fill record - use Unix milliseconds UTC
+	fillRecord := &store.TraderFill{
+		TraderID:        traderID,
+		ExchangeID:      exchangeID,
+		ExchangeType:    exchangeType,
+		OrderID:         orderRecord.ID,
+		ExchangeOrderID: trade.TradeID,
+		ExchangeTradeID: trade.TradeID,
+		Symbol:          symbol,
+		Side:            side,
+		Price:           trade.Price,
+		Quantity:        trade.Quantity,
+		QuoteQuantity:   trade.Price * trade.Quantity,
+		Commission:      trade.Fee,
+		CommissionAsset: "USDT",
+		RealizedPnL:     trade.RealizedPnL,
+		IsMaker:         false,
+		CreatedAt:       tradeTimeMs,
+	}
+
+	if err := orderStore.CreateFill(fillRecord); err != nil {
+		logger.Infof("  ⚠️ Failed to sync fill for trade %s: %v", trade.TradeID, err)
+	}
+
+	// Create/update position record using PositionBuilder
+	if err := posBuilder.ProcessTrade(
+		traderID, exchangeID, exchangeType,
+		symbol, positionSide, orderAction,
+		trade.Quantity, trade.Price, trade.Fee, trade.RealizedPnL,
+		tradeTimeMs, trade.TradeID,
+	); err != nil {
+		logger.Infof("  ⚠️ Failed to sync position for trade %s: %v", trade.TradeID, err)
+	} else {
+		logger.Infof("  📍 Position updated for trade: %s (action: %s, qty: %.6f)", trade.TradeID, orderAction, trade.Quantity)
+	}
+
+	logger.Infof("  ✅ Synced trade: %s %s %s qty=%.6f price=%.6f pnl=%.2f fee=%.6f action=%s time=%s(UTC)",
+		trade.TradeID, symbol, side, trade.Quantity, trade.Price, trade.RealizedPnL, trade.Fee, orderAction,
+		trade.Time.UTC().Format("01-02 15:04:05"))
+	return true
+}
+
+// RecordFill stores a fill of the user data stream like SyncOrdersFromBinance stores a trade, so
+// it doesn't wait for the next sync; fills already stored are skipped
+func (t *FuturesTrader) RecordFill(traderID, exchangeID, exchangeType string, st *store.Store, fill types.UserDataEvent) error {
+	if st == nil {
+		return fmt.Errorf("store is nil")
+	}
+	orderStore := st.Order()
+	if existing, err := orderStore.GetOrderByExchangeID(exchangeID, fill.TradeID); err == nil && existing != nil {
+		return nil
+	}
+	trade := types.TradeRecord{
+		TradeID:      fill.TradeID,
+		Symbol:       fill.Symbol,
+		Side:         fill.Side,
+		PositionSide: fill.PositionSide,
+		Price:        fill.Price,
+		Quantity:     fill.Quantity,
+		RealizedPnL:  fill.RealizedPnL,
+		Fee:          fill.Fee,
+		Time:         time.UnixMilli(fill.Time),
+	}
+	if !t.recordTrade(orderStore, store.NewPositionBuilder(st.Position()), traderID, exchangeID, exchangeType, trade) {
+		return fmt.Errorf("failed to record fill %s", fill.TradeID)
+	}
+	return nil
+}
+
 // getPositionSymbols returns list of symbols that have active positions
 // Used as fallback when COMMISSION detection fails
 func (t *FuturesTrader) getPositionSymbols() []string {
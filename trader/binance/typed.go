@@ -0,0 +1,100 @@
+package binance
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"nofx/trader/types"
+)
+
+// GetBalanceV2 gets the account balance (uncached), failing on fields that don't parse
+func (t *FuturesTrader) GetBalanceV2() (*types.AccountBalance, error) {
+	account, err := t.client.NewGetAccountService().Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account info: %w", err)
+	}
+
+	var p types.NumberParser
+	balance := &types.AccountBalance{
+		TotalWalletBalance:    p.Float("totalWalletBalance", account.TotalWalletBalance),
+		AvailableBalance:      p.Float("availableBalance", account.AvailableBalance),
+		TotalUnrealizedProfit: p.Float("totalUnrealizedProfit", account.TotalUnrealizedProfit),
+	}
+	if p.Err != nil {
+		return nil, fmt.Errorf("invalid account info: %w", p.Err)
+	}
+	balance.TotalEquity = balance.TotalWalletBalance + balance.TotalUnrealizedProfit
+	return balance, nil
+}
+
+// GetPositionsV2 gets all open positions (uncached), failing on positions that don't parse
+func (t *FuturesTrader) GetPositionsV2() ([]types.Position, error) {
+	risks, err := t.client.NewGetPositionRiskService().Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	positions := make([]types.Position, 0, len(risks))
+	for _, risk := range risks {
+		var p types.NumberParser
+		amount := p.Float("positionAmt", risk.PositionAmt)
+		pos := types.Position{
+			Symbol:           risk.Symbol,
+			Quantity:         math.Abs(amount),
+			EntryPrice:       p.Float("entryPrice", risk.EntryPrice),
+			MarkPrice:        p.Float("markPrice", risk.MarkPrice),
+			UnrealizedPnL:    p.Float("unRealizedProfit", risk.UnRealizedProfit),
+			LiquidationPrice: p.Float("liquidationPrice", risk.LiquidationPrice),
+			Leverage:         int(p.Float("leverage", risk.Leverage)),
+		}
+		if p.Err != nil {
+			return nil, fmt.Errorf("invalid %s position: %w", risk.Symbol, p.Err)
+		}
+		if pos.Quantity == 0 {
+			continue
+		}
+		// Hedge mode reports the side, one-way mode (BOTH) the sign of the amount
+		switch risk.PositionSide {
+		case "LONG":
+			pos.Side = "long"
+		case "SHORT":
+			pos.Side = "short"
+		default:
+			pos.Side = "long"
+			if amount < 0 {
+				pos.Side = "short"
+			}
+		}
+		positions = append(positions, pos)
+	}
+	return positions, nil
+}
+
+// GetOrderStatusV2 gets the status of an order
+func (t *FuturesTrader) GetOrderStatusV2(symbol string, orderID string) (*types.OrderResult, error) {
+	id, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order ID: %s", orderID)
+	}
+
+	order, err := t.client.NewGetOrderService().Symbol(symbol).OrderID(id).Do(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status: %w", err)
+	}
+
+	// Commission is only reported by the trade history, not by the order
+	var p types.NumberParser
+	result := &types.OrderResult{
+		OrderID:     strconv.FormatInt(order.OrderID, 10),
+		Symbol:      order.Symbol,
+		Status:      string(order.Status),
+		AvgPrice:    p.Float("avgPrice", order.AvgPrice),
+		ExecutedQty: p.Float("executedQty", order.ExecutedQuantity),
+	}
+	if p.Err != nil {
+		return nil, fmt.Errorf("invalid order %s: %w", orderID, p.Err)
+	}
+	return result, nil
+}
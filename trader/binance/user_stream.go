@@ -0,0 +1,206 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nofx/trader/types"
+	"nofx/trader/userstream"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Binance futures user data stream endpoints (the listen key is appended)
+const (
+	binanceUserStreamURL        = "wss://fstream.binance.com/ws/"
+	binanceTestnetUserStreamURL = "wss://stream.binancefuture.com/ws/"
+)
+
+var _ types.UserDataStreamer = (*FuturesTrader)(nil)
+
+// StreamUserData streams order updates, fills, position and balance changes over the listen key
+// user data stream. The listen key is extended every 30 minutes and recreated on reconnect.
+func (t *FuturesTrader) StreamUserData(ctx context.Context, onReconnect func()) (<-chan types.UserDataEvent, error) {
+	var mu sync.Mutex
+	var listenKey string
+
+	return userstream.Start(ctx, userstream.Config{
+		Name: "Binance",
+		URL: func(ctx context.Context) (string, error) {
+			key, err := t.client.NewStartUserStreamService().Do(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to create listen key: %w", err)
+			}
+			mu.Lock()
+			listenKey = key
+			mu.Unlock()
+			return t.userStreamBaseURL() + key, nil
+		},
+		Parse: t.parseUserData,
+		KeepAlive: func(ctx context.Context) error {
+			mu.Lock()
+			key := listenKey
+			mu.Unlock()
+			return t.client.NewKeepaliveUserStreamService().ListenKey(key).Do(ctx)
+		},
+		KeepAliveInterval: 30 * time.Minute,
+		// Binance sends no messages while the account is idle
+		ReadTimeout: 10 * time.Minute,
+	}, onReconnect)
+}
+
+func (t *FuturesTrader) userStreamBaseURL() string {
+	if t.userStreamURL != "" {
+		return t.userStreamURL
+	}
+	if strings.Contains(t.client.BaseURL, "testnet") {
+		return binanceTestnetUserStreamURL
+	}
+	return binanceUserStreamURL
+}
+
+// binanceUserDataMessage ORDER_TRADE_UPDATE and ACCOUNT_UPDATE payloads
+type binanceUserDataMessage struct {
+	Event string `json:"e"`
+	Time  int64  `json:"E"`
+	Order *struct {
+		Symbol        string `json:"s"`
+		ClientOrderID string `json:"c"`
+		Side          string `json:"S"`
+		OrigQty       string `json:"q"`
+		Price         string `json:"p"`
+		AvgPrice      string `json:"ap"`
+		ExecutionType string `json:"x"`
+		Status        string `json:"X"`
+		OrderID       int64  `json:"i"`
+		LastFilledQty string `json:"l"`
+		CumFilledQty  string `json:"z"`
+		LastPrice     string `json:"L"`
+		Commission    string `json:"n"`
+		TradeID       int64  `json:"t"`
+		PositionSide  string `json:"ps"`
+		RealizedPnL   string `json:"rp"`
+	} `json:"o"`
+	Account *struct {
+		Balances []struct {
+			Asset         string `json:"a"`
+			WalletBalance string `json:"wb"`
+		} `json:"B"`
+		Positions []struct {
+			Symbol        string `json:"s"`
+			Amount        string `json:"pa"`
+			EntryPrice    string `json:"ep"`
+			UnrealizedPnL string `json:"up"`
+			PositionSide  string `json:"ps"`
+		} `json:"P"`
+	} `json:"a"`
+}
+
+// parseUserData converts a user data stream message into events
+func (t *FuturesTrader) parseUserData(msg []byte) ([]types.UserDataEvent, error) {
+	var m binanceUserDataMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+
+	var events []types.UserDataEvent
+	switch m.Event {
+	case "ORDER_TRADE_UPDATE":
+		if m.Order == nil {
+			return nil, fmt.Errorf("order update without order")
+		}
+		o := m.Order
+		status := o.Status
+		if status == "EXPIRED_IN_MATCH" {
+			status = types.OrderStatusExpired
+		}
+		orderID := strconv.FormatInt(o.OrderID, 10)
+		events = append(events, types.UserDataEvent{
+			Type:          types.UserDataOrder,
+			Symbol:        o.Symbol,
+			Time:          m.Time,
+			OrderID:       orderID,
+			ClientOrderID: o.ClientOrderID,
+			Side:          o.Side,
+			PositionSide:  o.PositionSide,
+			Status:        status,
+			Price:         parseFloat(o.Price),
+			Quantity:      parseFloat(o.OrigQty),
+			AvgPrice:      parseFloat(o.AvgPrice),
+			ExecutedQty:   parseFloat(o.CumFilledQty),
+		})
+		if o.ExecutionType == "TRADE" {
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataFill,
+				Symbol:        o.Symbol,
+				Time:          m.Time,
+				OrderID:       orderID,
+				ClientOrderID: o.ClientOrderID,
+				TradeID:       strconv.FormatInt(o.TradeID, 10),
+				Side:          o.Side,
+				PositionSide:  o.PositionSide,
+				Price:         parseFloat(o.LastPrice),
+				Quantity:      parseFloat(o.LastFilledQty),
+				Fee:           parseFloat(o.Commission),
+				RealizedPnL:   parseFloat(o.RealizedPnL),
+			})
+		}
+
+	case "ACCOUNT_UPDATE":
+		if m.Account == nil {
+			return nil, fmt.Errorf("account update without account")
+		}
+		t.invalidateCache()
+		for _, b := range m.Account.Balances {
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataBalance,
+				Time:          m.Time,
+				Asset:         b.Asset,
+				WalletBalance: parseFloat(b.WalletBalance),
+			})
+		}
+		for _, p := range m.Account.Positions {
+			amount := parseFloat(p.Amount)
+			side := p.PositionSide
+			if side == "BOTH" && amount < 0 {
+				side = "SHORT"
+			} else if side == "BOTH" && amount > 0 {
+				side = "LONG"
+			}
+			if amount < 0 {
+				amount = -amount
+			}
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataPosition,
+				Symbol:        p.Symbol,
+				Time:          m.Time,
+				PositionSide:  side,
+				PositionAmt:   amount,
+				EntryPrice:    parseFloat(p.EntryPrice),
+				UnrealizedPnL: parseFloat(p.UnrealizedPnL),
+			})
+		}
+
+	case "listenKeyExpired":
+		return nil, fmt.Errorf("listen key expired: %w", userstream.ErrReconnect)
+	}
+	return events, nil
+}
+
+// invalidateCache drops the cached balance and positions after the account changed
+func (t *FuturesTrader) invalidateCache() {
+	t.balanceCacheMutex.Lock()
+	t.cachedBalance = nil
+	t.balanceCacheMutex.Unlock()
+
+	t.positionsCacheMutex.Lock()
+	t.cachedPositions = nil
+	t.positionsCacheMutex.Unlock()
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,50 @@
+package binance
+
+import (
+	"errors"
+	"testing"
+
+	"nofx/trader/types"
+	"nofx/trader/userstream"
+)
+
+func TestParseUserData(t *testing.T) {
+	trader := &FuturesTrader{cachedPositions: []map[string]interface{}{{"symbol": "BTCUSDT"}}}
+
+	events, err := trader.parseUserData([]byte(`{"e":"ORDER_TRADE_UPDATE","E":1700000000000,"o":{"s":"BTCUSDT","c":"cl1","S":"BUY","q":"0.010","p":"0","ap":"50000.5","x":"TRADE","X":"FILLED","i":8886774,"l":"0.010","z":"0.010","L":"50000.5","n":"0.2","t":42,"ps":"LONG","rp":"0"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want order and fill: %+v", len(events), events)
+	}
+	order, fill := events[0], events[1]
+	if order.Type != types.UserDataOrder || order.OrderID != "8886774" || order.Status != types.OrderStatusFilled || !order.IsFinal() ||
+		order.AvgPrice != 50000.5 || order.ExecutedQty != 0.01 || order.PositionSide != "LONG" {
+		t.Errorf("unexpected order event: %+v", order)
+	}
+	if fill.Type != types.UserDataFill || fill.TradeID != "42" || fill.Price != 50000.5 || fill.Quantity != 0.01 || fill.Fee != 0.2 {
+		t.Errorf("unexpected fill event: %+v", fill)
+	}
+
+	// A new order is no fill
+	events, _ = trader.parseUserData([]byte(`{"e":"ORDER_TRADE_UPDATE","E":1,"o":{"s":"BTCUSDT","x":"NEW","X":"NEW","i":1}}`))
+	if len(events) != 1 || events[0].IsFinal() {
+		t.Errorf("unexpected events for new order: %+v", events)
+	}
+
+	events, err = trader.parseUserData([]byte(`{"e":"ACCOUNT_UPDATE","E":1700000000000,"a":{"m":"ORDER","B":[{"a":"USDT","wb":"1000.5"}],"P":[{"s":"ETHUSDT","pa":"-2","ep":"3000","up":"-5","ps":"BOTH"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].WalletBalance != 1000.5 || events[1].PositionSide != "SHORT" || events[1].PositionAmt != 2 {
+		t.Errorf("unexpected account events: %+v", events)
+	}
+	if trader.cachedPositions != nil {
+		t.Error("position cache not invalidated by account update")
+	}
+
+	if _, err := trader.parseUserData([]byte(`{"e":"listenKeyExpired","E":1}`)); !errors.Is(err, userstream.ErrReconnect) {
+		t.Errorf("expected reconnect on expired listen key, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package bybit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nofx/trader/testutil"
+	"nofx/trader/testutil/fakeexchange"
+	"nofx/trader/types"
+)
+
+// newFakeExchangeTrader creates a trader against an in-memory exchange (one-way mode)
+func newFakeExchangeTrader(t *testing.T) (*BybitTrader, *fakeexchange.Exchange) {
+	ex := fakeexchange.New()
+	server := fakeexchange.NewBybitServer(ex)
+	t.Cleanup(server.Close)
+
+	trader := NewBybitTrader("test_api_key", "test_secret_key")
+	trader.client.BaseURL = server.URL
+	trader.cacheDuration = 0
+	return trader, ex
+}
+
+// TestBybitTrader_FakeExchange runs the common suite against the stateful fake exchange
+func TestBybitTrader_FakeExchange(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	suite := testutil.NewTraderTestSuite(t, trader)
+	defer suite.Cleanup()
+	suite.BeforeEach = ex.SuiteSetup()
+	suite.SeedAccount = ex.SuiteSeed()
+
+	suite.RunAllTests()
+
+	assert.InDelta(t, -0.49, ex.Position("BTCUSDT", fakeexchange.SideBoth).Amount, 1e-9, "CloseShort should have bought back 0.01")
+}
+
+func TestBybitTrader_FakeExchangeFaults(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+
+	t.Run("rate limit", func(t *testing.T) {
+		ex.Inject(fakeexchange.FaultRateLimit, "/v5/account/wallet-balance", 1)
+		_, err := trader.GetBalance()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Too many visits")
+
+		balance, err := trader.GetBalance()
+		require.NoError(t, err)
+		assert.Equal(t, 10000.0, balance["totalWalletBalance"])
+	})
+
+	t.Run("server error leaves the position open", func(t *testing.T) {
+		ex.Reset()
+		ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 50000)
+		ex.Inject(fakeexchange.FaultServerError, "/v5/order/create", 1)
+
+		_, err := trader.CloseLong("BTCUSDT", 0.1)
+		require.Error(t, err)
+		assert.Equal(t, 0.1, ex.Position("BTCUSDT", fakeexchange.SideBoth).Amount)
+
+		_, err = trader.CloseLong("BTCUSDT", 0)
+		require.NoError(t, err)
+		assert.Empty(t, ex.Positions())
+	})
+
+	t.Run("partial fill", func(t *testing.T) {
+		ex.Reset()
+		ex.SetPartialFill(0.5)
+
+		result, err := trader.PlaceLimitOrder(&types.LimitOrderRequest{Symbol: "BTCUSDT", Side: "BUY", Price: 50100, Quantity: 0.01})
+		require.NoError(t, err)
+
+		status, err := trader.GetOrderStatus("BTCUSDT", result.OrderID)
+		require.NoError(t, err)
+		assert.Equal(t, "PARTIALLY_FILLED", status["status"])
+		assert.InDelta(t, 0.005, status["executedQty"], 1e-9)
+
+		ex.SetPrice("BTCUSDT", 50000)
+		status, err = trader.GetOrderStatus("BTCUSDT", result.OrderID)
+		require.NoError(t, err)
+		assert.Equal(t, "FILLED", status["status"])
+		assert.InDelta(t, 0.01, status["executedQty"], 1e-9)
+		assert.Greater(t, status["commission"], 0.0)
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		ex.Reset()
+		ex.SetClockSkew(-10 * time.Second)
+
+		// No server time sync on Bybit: requests fail until the clocks agree
+		_, err := trader.GetBalance()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "server timestamp")
+
+		ex.SetClockSkew(0)
+		_, err = trader.GetBalance()
+		assert.NoError(t, err)
+	})
+}
+
+func TestBybitTrader_FakeExchangeTrailingStop(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+
+	require.Error(t, trader.SetTrailingStop("BTCUSDT", "LONG", 0.1, 1, 0), "no position to trail")
+
+	ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 50000)
+	require.NoError(t, trader.SetTrailingStop("BTCUSDT", "LONG", 0.1, 1, 0))
+	require.NoError(t, trader.SetStopLoss("BTCUSDT", "LONG", 0.1, 48000))
+
+	// 1% of 50000 becomes a fixed 500 distance; canceling stop-losses keeps it
+	require.NoError(t, trader.CancelStopLossOrders("BTCUSDT"))
+	orders := ex.Orders(func(o fakeexchange.Order) bool { return o.IsOpen() })
+	require.Len(t, orders, 1)
+	assert.Equal(t, 500.0, orders[0].CallbackDistance)
+
+	require.NoError(t, trader.CancelTrailingStopOrders("BTCUSDT"))
+	assert.Empty(t, ex.Orders(func(o fakeexchange.Order) bool { return o.IsOpen() }))
+
+	require.NoError(t, trader.SetTrailingStop("BTCUSDT", "LONG", 0.1, 1, 0))
+	ex.SetPrice("BTCUSDT", 52000)
+	ex.SetPrice("BTCUSDT", 51400)
+	assert.Empty(t, ex.Positions(), "a 500 retrace from 52000 should close the long")
+}
+
+func TestBybitTrader_FakeExchangePartialTakeProfit(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	ex.SetPosition("BTCUSDT", fakeexchange.SideShort, 0.1, 50000)
+
+	require.NoError(t, trader.SetPartialTakeProfit("BTCUSDT", "SHORT", 0.03, 49000))
+	require.NoError(t, trader.SetTakeProfit("BTCUSDT", "SHORT", 0.07, 48000))
+
+	ex.SetPrice("BTCUSDT", 49000)
+	// One-way mode: the short is a negative SideBoth position
+	assert.InDelta(t, -0.07, ex.Position("BTCUSDT", fakeexchange.SideBoth).Amount, 1e-9, "first level closes 0.03")
+	ex.SetPrice("BTCUSDT", 48000)
+	assert.Empty(t, ex.Positions(), "final take-profit closes the rest")
+}
@@ -11,6 +11,7 @@ import (
 	"nofx/logger"
 	"nofx/market"
 	"nofx/store"
+	"nofx/trader/types"
 	"sort"
 	"strconv"
 	"strings"
@@ -43,7 +44,7 @@ func (t *BybitTrader) GetTrades(startTime time.Time, limit int) ([]BybitTrade, e
 func (t *BybitTrader) getTradesViaHTTP(startTime time.Time, limit int) ([]BybitTrade, error) {
 	// Build query string
 	queryParams := fmt.Sprintf("category=linear&startTime=%d&limit=%d", startTime.UnixMilli(), limit)
-	url := "https://api.bybit.com/v5/execution/list?" + queryParams
+	url := t.client.BaseURL + "/v5/execution/list?" + queryParams
 
 	// Generate timestamp
 	timestamp := fmt.Sprintf("%d", time.Now().UnixMilli())
@@ -129,26 +130,6 @@ func (t *BybitTrader) parseTradesResult(list []map[string]interface{}) ([]BybitT
 		execTimeMs, _ := strconv.ParseInt(execTimeStr, 10, 64)
 		execTime := time.UnixMilli(execTimeMs).UTC()
 
-		// Determine order action based on side and closedSize
-		// If closedSize > 0, it's a close trade
-		// Side: Buy = long direction, Sell = short direction
-		orderAction := "open_long"
-		if closedSize > 0 {
-			// This is a close trade
-			if strings.ToLower(side) == "sell" {
-				orderAction = "close_long" // Selling to close a long
-			} else {
-				orderAction = "close_short" // Buying to close a short
-			}
-		} else {
-			// This is an open trade
-			if strings.ToLower(side) == "buy" {
-				orderAction = "open_long"
-			} else {
-				orderAction = "open_short"
-			}
-		}
-
 		trade := BybitTrade{
 			Symbol:      symbol,
 			OrderID:     orderID,
@@ -162,7 +143,7 @@ func (t *BybitTrader) parseTradesResult(list []map[string]interface{}) ([]BybitT
 			OrderType:   orderType,
 			ClosedSize:  closedSize,
 			ClosedPnL:   closedPnl,
-			OrderAction: orderAction,
+			OrderAction: bybitOrderAction(side, closedSize),
 		}
 
 		trades = append(trades, trade)
@@ -171,6 +152,22 @@ func (t *BybitTrader) parseTradesResult(list []map[string]interface{}) ([]BybitT
 	return trades, nil
 }
 
+// bybitOrderAction determines the order action of an execution from its side and closed size:
+// a closed size means it closed (part of) a position, Buy is the long direction
+func bybitOrderAction(side string, closedSize float64) string {
+	buy := strings.ToLower(side) == "buy"
+	switch {
+	case closedSize > 0 && buy:
+		return "close_short" // Buying to close a short
+	case closedSize > 0:
+		return "close_long" // Selling to close a long
+	case buy:
+		return "open_long"
+	default:
+		return "open_short"
+	}
+}
+
 // SyncOrdersFromBybit syncs Bybit exchange order history to local database
 // Also creates/updates position records to ensure orders/fills/positions data consistency
 // exchangeID: Exchange account UUID (from exchanges.id)
@@ -211,89 +208,127 @@ func (t *BybitTrader) SyncOrdersFromBybit(traderID string, exchangeID string, ex
 			continue // Order already exists, skip
 		}
 
-		// Normalize symbol
-		symbol := market.Normalize(trade.Symbol)
-
-		// Determine position side from order action
-		positionSide := "LONG"
-		if strings.Contains(trade.OrderAction, "short") {
-			positionSide = "SHORT"
+		if t.recordTrade(orderStore, posBuilder, traderID, exchangeID, exchangeType, trade) {
+			syncedCount++
 		}
+	}
 
-		// Normalize side for storage
-		side := strings.ToUpper(trade.Side)
-
-		// Create order record - use UTC time in milliseconds to avoid timezone issues
-		execTimeMs := trade.ExecTime.UTC().UnixMilli()
-		orderRecord := &store.TraderOrder{
-			TraderID:        traderID,
-			ExchangeID:      exchangeID,   // UUID
-			ExchangeType:    exchangeType, // Exchange type
-			ExchangeOrderID: trade.ExecID, // Use ExecID as unique identifier
-			Symbol:          symbol,
-			Side:            side,
-			PositionSide:    "BOTH", // Bybit uses one-way position mode
-			Type:            trade.OrderType,
-			OrderAction:     trade.OrderAction,
-			Quantity:        trade.ExecQty,
-			Price:           trade.ExecPrice,
-			Status:          "FILLED",
-			FilledQuantity:  trade.ExecQty,
-			AvgFillPrice:    trade.ExecPrice,
-			Commission:      trade.ExecFee,
-			FilledAt:        execTimeMs,
-			CreatedAt:       execTimeMs,
-			UpdatedAt:       execTimeMs,
-		}
+	logger.Infof("✅ Bybit order sync completed: %d new trades synced", syncedCount)
+	return nil
+}
 
-		// Insert order record
-		if err := orderStore.CreateOrder(orderRecord); err != nil {
-			logger.Infof("  ⚠️ Failed to sync trade %s: %v", trade.ExecID, err)
-			continue
-		}
+// recordTrade stores a trade as an order, a fill and a position update; returns false if the
+// order couldn't be stored
+func (t *BybitTrader) recordTrade(orderStore *store.OrderStore, posBuilder *store.PositionBuilder, traderID, exchangeID, exchangeType string, trade BybitTrade) bool {
+	// Normalize symbol
+	symbol := market.Normalize(trade.Symbol)
 
-		// Create fill record - use UTC time
-		fillRecord := &store.TraderFill{
-			TraderID:        traderID,
-			ExchangeID:      exchangeID,   // UUID
-			ExchangeType:    exchangeType, // Exchange type
-			OrderID:         orderRecord.ID,
-			ExchangeOrderID: trade.OrderID,
-			ExchangeTradeID: trade.ExecID,
-			Symbol:          symbol,
-			Side:            side,
-			Price:           trade.ExecPrice,
-			Quantity:        trade.ExecQty,
-			QuoteQuantity:   trade.ExecPrice * trade.ExecQty,
-			Commission:      trade.ExecFee,
-			CommissionAsset: "USDT",
-			RealizedPnL:     trade.ClosedPnL,
-			IsMaker:         trade.IsMaker,
-			CreatedAt:       execTimeMs,
-		}
+	// Determine position side from order action
+	positionSide := "LONG"
+	if strings.Contains(trade.OrderAction, "short") {
+		positionSide = "SHORT"
+	}
 
-		if err := orderStore.CreateFill(fillRecord); err != nil {
-			logger.Infof("  ⚠️ Failed to sync fill for trade %s: %v", trade.ExecID, err)
-		}
+	// Normalize side for storage
+	side := strings.ToUpper(trade.Side)
+
+	// Create order record - use UTC time in milliseconds to avoid timezone issues
+	execTimeMs := trade.ExecTime.UTC().UnixMilli()
+	orderRecord := &store.TraderOrder{
+		TraderID:        traderID,
+		ExchangeID:      exchangeID,   // UUID
+		ExchangeType:    exchangeType, // Exchange type
+		ExchangeOrderID: trade.ExecID, // Use ExecID as unique identifier
+		Symbol:          symbol,
+		Side:            side,
+		PositionSide:    "BOTH", // Bybit uses one-way position mode
+		Type:            trade.OrderType,
+		OrderAction:     trade.OrderAction,
+		Quantity:        trade.ExecQty,
+		Price:           trade.ExecPrice,
+		Status:          "FILLED",
+		FilledQuantity:  trade.ExecQty,
+		AvgFillPrice:    trade.ExecPrice,
+		Commission:      trade.ExecFee,
+		FilledAt:        execTimeMs,
+		CreatedAt:       execTimeMs,
+		UpdatedAt:       execTimeMs,
+	}
 
-		// Create/update position record using PositionBuilder
-		if err := posBuilder.ProcessTrade(
-			traderID, exchangeID, exchangeType,
-			symbol, positionSide, trade.OrderAction,
-			trade.ExecQty, trade.ExecPrice, trade.ExecFee, trade.ClosedPnL,
-			execTimeMs, trade.ExecID,
-		); err != nil {
-			logger.Infof("  ⚠️ Failed to sync position for trade %s: %v", trade.ExecID, err)
-		} else {
-			logger.Infof("  📍 Position updated for trade: %s (action: %s, qty: %.6f)", trade.ExecID, trade.OrderAction, trade.ExecQty)
-		}
+	// Insert order record
+	if err := orderStore.CreateOrder(orderRecord); err != nil {
+		logger.Infof("  ⚠️ Failed to sync trade %s: %v", trade.ExecID, err)
+		return false
+	}
 
-		syncedCount++
-		logger.Infof("  ✅ Synced trade: %s %s %s qty=%.6f price=%.6f pnl=%.2f fee=%.6f action=%s",
-			trade.ExecID, symbol, side, trade.ExecQty, trade.ExecPrice, trade.ClosedPnL, trade.ExecFee, trade.OrderAction)
+	// Create fill record - use UTC time
+	fillRecord := &store.TraderFill{
+		TraderID:        traderID,
+		ExchangeID:      exchangeID,   // UUID
+		ExchangeType:    exchangeType, // Exchange type
+		OrderID:         orderRecord.ID,
+		ExchangeOrderID: trade.OrderID,
+		ExchangeTradeID: trade.ExecID,
+		Symbol:          symbol,
+		Side:            side,
+		Price:           trade.ExecPrice,
+		Quantity:        trade.ExecQty,
+		QuoteQuantity:   trade.ExecPrice * trade.ExecQty,
+		Commission:      trade.ExecFee,
+		CommissionAsset: "USDT",
+		RealizedPnL:     trade.ClosedPnL,
+		IsMaker:         trade.IsMaker,
+		CreatedAt:       execTimeMs,
 	}
 
-	logger.Infof("✅ Bybit order sync completed: %d new trades synced", syncedCount)
+	if err := orderStore.CreateFill(fillRecord); err != nil {
+		logger.Infof("  ⚠️ Failed to sync fill for trade %s: %v", trade.ExecID, err)
+	}
+
+	// Create/update position record using PositionBuilder
+	if err := posBuilder.ProcessTrade(
+		traderID, exchangeID, exchangeType,
+		symbol, positionSide, trade.OrderAction,
+		trade.ExecQty, trade.ExecPrice, trade.ExecFee, trade.ClosedPnL,
+		execTimeMs, trade.ExecID,
+	); err != nil {
+		logger.Infof("  ⚠️ Failed to sync position for trade %s: %v", trade.ExecID, err)
+	} else {
+		logger.Infof("  📍 Position updated for trade: %s (action: %s, qty: %.6f)", trade.ExecID, trade.OrderAction, trade.ExecQty)
+	}
+
+	logger.Infof("  ✅ Synced trade: %s %s %s qty=%.6f price=%.6f pnl=%.2f fee=%.6f action=%s",
+		trade.ExecID, symbol, side, trade.ExecQty, trade.ExecPrice, trade.ClosedPnL, trade.ExecFee, trade.OrderAction)
+	return true
+}
+
+// RecordFill stores an execution of the user data stream like SyncOrdersFromBybit stores a trade,
+// so it doesn't wait for the next sync; executions already stored are skipped
+func (t *BybitTrader) RecordFill(traderID, exchangeID, exchangeType string, st *store.Store, fill types.UserDataEvent) error {
+	if st == nil {
+		return fmt.Errorf("store is nil")
+	}
+	orderStore := st.Order()
+	if existing, err := orderStore.GetOrderByExchangeID(exchangeID, fill.TradeID); err == nil && existing != nil {
+		return nil
+	}
+	trade := BybitTrade{
+		Symbol:      fill.Symbol,
+		OrderID:     fill.OrderID,
+		ExecID:      fill.TradeID,
+		Side:        fill.Side,
+		ExecPrice:   fill.Price,
+		ExecQty:     fill.Quantity,
+		ExecFee:     fill.Fee,
+		ExecTime:    time.UnixMilli(fill.Time).UTC(),
+		OrderType:   "MARKET",
+		ClosedSize:  fill.ClosedQty,
+		ClosedPnL:   fill.RealizedPnL,
+		OrderAction: bybitOrderAction(fill.Side, fill.ClosedQty),
+	}
+	if !t.recordTrade(orderStore, store.NewPositionBuilder(st.Position()), traderID, exchangeID, exchangeType, trade) {
+		return fmt.Errorf("failed to record execution %s", fill.TradeID)
+	}
 	return nil
 }
 
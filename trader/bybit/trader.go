@@ -42,6 +42,9 @@ type BybitTrader struct {
 
 	// Cache duration (15 seconds)
 	cacheDuration time.Duration
+
+	// Private stream URL, empty for production
+	userStreamURL string
 }
 
 // NewBybitTrader creates a Bybit trader
@@ -317,7 +320,7 @@ func (t *BybitTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 	// Clear cache
 	t.clearCache()
 
-	return t.parseOrderResult(result)
+	return t.parseOrderResult(result, symbol)
 }
 
 // OpenShort opens a short position
@@ -360,7 +363,7 @@ func (t *BybitTrader) OpenShort(symbol string, quantity float64, leverage int) (
 	// Clear cache
 	t.clearCache()
 
-	return t.parseOrderResult(result)
+	return t.parseOrderResult(result, symbol)
 }
 
 // CloseLong closes a long position
@@ -405,7 +408,7 @@ func (t *BybitTrader) CloseLong(symbol string, quantity float64) (map[string]int
 	// Clear cache
 	t.clearCache()
 
-	return t.parseOrderResult(result)
+	return t.parseOrderResult(result, symbol)
 }
 
 // CloseShort closes a short position
@@ -450,7 +453,7 @@ func (t *BybitTrader) CloseShort(symbol string, quantity float64) (map[string]in
 	// Clear cache
 	t.clearCache()
 
-	return t.parseOrderResult(result)
+	return t.parseOrderResult(result, symbol)
 }
 
 // SetLeverage sets leverage
@@ -635,6 +638,80 @@ func (t *BybitTrader) SetTakeProfit(symbol string, positionSide string, quantity
 	return nil
 }
 
+var _ types.PartialTakeProfitTrader = (*BybitTrader)(nil)
+
+// SetPartialTakeProfit places a take-profit for part of the position.
+// Bybit conditional take-profit orders are reduce-only for qty, so SetTakeProfit already closes only quantity
+func (t *BybitTrader) SetPartialTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.SetTakeProfit(symbol, positionSide, quantity, takeProfitPrice)
+}
+
+var _ types.TrailingStopper = (*BybitTrader)(nil)
+
+// SetTrailingStop sets the trailing stop of the position (/v5/position/trading-stop)
+// Bybit trails by a price distance on the whole position, so quantity is ignored and the
+// callback is converted at the activation price (current price if 0)
+func (t *BybitTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackPct, activationPrice float64) error {
+	if callbackPct <= 0 {
+		return fmt.Errorf("invalid trailing stop callback: %.2f%%", callbackPct)
+	}
+
+	basePrice := activationPrice
+	if basePrice <= 0 {
+		price, err := t.GetMarketPrice(symbol)
+		if err != nil {
+			return err
+		}
+		basePrice = price
+	}
+	distance := math.Round(basePrice*callbackPct/100*1e8) / 1e8
+
+	params := map[string]interface{}{
+		"category":     "linear",
+		"symbol":       symbol,
+		"tpslMode":     "Full",
+		"positionIdx":  0,
+		"trailingStop": strconv.FormatFloat(distance, 'f', -1, 64),
+	}
+	if activationPrice > 0 {
+		params["activePrice"] = strconv.FormatFloat(activationPrice, 'f', -1, 64)
+	}
+
+	result, err := t.client.NewUtaBybitServiceWithParams(params).SetPositionTradingStop(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to set trailing stop: %w", err)
+	}
+
+	if result.RetCode != 0 {
+		return fmt.Errorf("failed to set trailing stop: %s", result.RetMsg)
+	}
+
+	logger.Infof("  ✓ [Bybit] Trailing stop set: %s distance %v (%.2f%%)", symbol, distance, callbackPct)
+	return nil
+}
+
+// CancelTrailingStopOrders removes the trailing stop of the position
+func (t *BybitTrader) CancelTrailingStopOrders(symbol string) error {
+	params := map[string]interface{}{
+		"category":     "linear",
+		"symbol":       symbol,
+		"tpslMode":     "Full",
+		"positionIdx":  0,
+		"trailingStop": "0",
+	}
+
+	result, err := t.client.NewUtaBybitServiceWithParams(params).SetPositionTradingStop(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to cancel trailing stop: %w", err)
+	}
+
+	if result.RetCode != 0 {
+		return fmt.Errorf("failed to cancel trailing stop: %s", result.RetMsg)
+	}
+
+	return nil
+}
+
 // CancelStopLossOrders cancels stop loss orders
 func (t *BybitTrader) CancelStopLossOrders(symbol string) error {
 	return t.cancelConditionalOrders(symbol, "StopLoss")
@@ -682,7 +759,7 @@ func (t *BybitTrader) getQtyStep(symbol string) float64 {
 	t.qtyStepCacheMutex.RUnlock()
 
 	// Call public API directly to get contract information
-	url := fmt.Sprintf("https://api.bybit.com/v5/market/instruments-info?category=linear&symbol=%s", symbol)
+	url := fmt.Sprintf("%s/v5/market/instruments-info?category=linear&symbol=%s", t.client.BaseURL, symbol)
 	resp, err := http.Get(url)
 	if err != nil {
 		logger.Infof("⚠️ [Bybit] Failed to get precision info for %s: %v", symbol, err)
@@ -765,7 +842,7 @@ func (t *BybitTrader) clearCache() {
 	t.positionsCacheMutex.Unlock()
 }
 
-func (t *BybitTrader) parseOrderResult(result *bybit.ServerResponse) (map[string]interface{}, error) {
+func (t *BybitTrader) parseOrderResult(result *bybit.ServerResponse, symbol string) (map[string]interface{}, error) {
 	if result.RetCode != 0 {
 		return nil, fmt.Errorf("order placement failed: %s", result.RetMsg)
 	}
@@ -779,6 +856,7 @@ func (t *BybitTrader) parseOrderResult(result *bybit.ServerResponse) (map[string
 
 	return map[string]interface{}{
 		"orderId": orderId,
+		"symbol":  symbol,
 		"status":  "NEW",
 	}, nil
 }
@@ -910,7 +988,7 @@ func (t *BybitTrader) GetClosedPnL(startTime time.Time, limit int) ([]types.Clos
 func (t *BybitTrader) getClosedPnLViaHTTP(startTime time.Time, limit int) ([]types.ClosedPnLRecord, error) {
 	// Build query string
 	queryParams := fmt.Sprintf("category=linear&startTime=%d&limit=%d", startTime.UnixMilli(), limit)
-	url := "https://api.bybit.com/v5/position/closed-pnl?" + queryParams
+	url := t.client.BaseURL + "/v5/position/closed-pnl?" + queryParams
 
 	// Generate timestamp
 	timestamp := fmt.Sprintf("%d", time.Now().UnixMilli())
@@ -1212,7 +1290,7 @@ func (t *BybitTrader) GetOrderBook(symbol string, depth int) (bids, asks [][]flo
 	}
 
 	// Use HTTP request directly since the SDK doesn't expose GetOrderbook
-	url := fmt.Sprintf("https://api.bybit.com/v5/market/orderbook?category=linear&symbol=%s&limit=%d", symbol, depth)
+	url := fmt.Sprintf("%s/v5/market/orderbook?category=linear&symbol=%s&limit=%d", t.client.BaseURL, symbol, depth)
 	resp, err := http.Get(url)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get order book: %w", err)
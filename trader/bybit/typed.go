@@ -0,0 +1,150 @@
+package bybit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"nofx/trader/types"
+)
+
+// resultList returns the list of a Bybit v5 response
+func resultList(retCode int, retMsg string, result interface{}) ([]interface{}, error) {
+	if retCode != 0 {
+		return nil, fmt.Errorf("Bybit API error: %s", retMsg)
+	}
+	data, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Bybit response format error")
+	}
+	list, _ := data["list"].([]interface{})
+	return list, nil
+}
+
+// stringField returns a string field of a Bybit response item, "" if absent
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// GetBalanceV2 gets the unified account balance (uncached), failing on fields that don't parse
+func (t *BybitTrader) GetBalanceV2() (*types.AccountBalance, error) {
+	params := map[string]interface{}{"accountType": "UNIFIED"}
+	result, err := t.client.NewUtaBybitServiceWithParams(params).GetAccountWallet(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Bybit balance: %w", err)
+	}
+	list, err := resultList(result.RetCode, result.RetMsg, result.Result)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no Bybit account in the balance response")
+	}
+	account, ok := list[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Bybit balance format error")
+	}
+
+	var p types.NumberParser
+	balance := &types.AccountBalance{
+		TotalWalletBalance:    p.Float("totalWalletBalance", stringField(account, "totalWalletBalance")),
+		AvailableBalance:      p.Float("totalAvailableBalance", stringField(account, "totalAvailableBalance")),
+		TotalUnrealizedProfit: p.Float("totalPerpUPL", stringField(account, "totalPerpUPL")),
+		TotalEquity:           p.Float("totalEquity", stringField(account, "totalEquity")),
+	}
+	if p.Err != nil {
+		return nil, fmt.Errorf("invalid Bybit balance: %w", p.Err)
+	}
+	if balance.TotalWalletBalance == 0 {
+		balance.TotalWalletBalance = balance.TotalEquity
+	}
+	if balance.TotalEquity <= 0 {
+		balance.TotalEquity = balance.TotalWalletBalance + balance.TotalUnrealizedProfit
+	}
+	return balance, nil
+}
+
+// GetPositionsV2 gets all open USDT positions (uncached), failing on positions that don't parse
+func (t *BybitTrader) GetPositionsV2() ([]types.Position, error) {
+	params := map[string]interface{}{"category": "linear", "settleCoin": "USDT"}
+	result, err := t.client.NewUtaBybitServiceWithParams(params).GetPositionList(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Bybit positions: %w", err)
+	}
+	list, err := resultList(result.RetCode, result.RetMsg, result.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]types.Position, 0, len(list))
+	for i, item := range list {
+		raw, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Bybit position #%d format error", i+1)
+		}
+		symbol := stringField(raw, "symbol")
+		var p types.NumberParser
+		pos := types.Position{
+			Symbol:           symbol,
+			Quantity:         p.Float("size", stringField(raw, "size")),
+			EntryPrice:       p.Float("avgPrice", stringField(raw, "avgPrice")),
+			MarkPrice:        p.Float("markPrice", stringField(raw, "markPrice")),
+			UnrealizedPnL:    p.Float("unrealisedPnl", stringField(raw, "unrealisedPnl")),
+			LiquidationPrice: p.Float("liqPrice", stringField(raw, "liqPrice")),
+			Leverage:         int(p.Float("leverage", stringField(raw, "leverage"))),
+			CreatedTime:      p.Int("createdTime", stringField(raw, "createdTime")),
+		}
+		if p.Err != nil {
+			return nil, fmt.Errorf("invalid Bybit %s position: %w", symbol, p.Err)
+		}
+		if pos.Quantity == 0 {
+			continue
+		}
+		// Bybit reports the size unsigned with side Buy (long) or Sell (short)
+		switch side := stringField(raw, "side"); strings.ToLower(side) {
+		case "buy":
+			pos.Side = "long"
+		case "sell":
+			pos.Side = "short"
+		default:
+			return nil, fmt.Errorf("invalid Bybit %s position side %q", symbol, side)
+		}
+		positions = append(positions, pos)
+	}
+	return positions, nil
+}
+
+// GetOrderStatusV2 gets the status of an order
+func (t *BybitTrader) GetOrderStatusV2(symbol string, orderID string) (*types.OrderResult, error) {
+	params := map[string]interface{}{"category": "linear", "symbol": symbol, "orderId": orderID}
+	result, err := t.client.NewUtaBybitServiceWithParams(params).GetOrderHistory(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status: %w", err)
+	}
+	list, err := resultList(result.RetCode, result.RetMsg, result.Result)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+	order, ok := list[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Bybit order format error")
+	}
+
+	var p types.NumberParser
+	status := &types.OrderResult{
+		OrderID:     orderID,
+		Symbol:      symbol,
+		Status:      bybitOrderStatus(stringField(order, "orderStatus")),
+		AvgPrice:    p.Float("avgPrice", stringField(order, "avgPrice")),
+		ExecutedQty: p.Float("cumExecQty", stringField(order, "cumExecQty")),
+		Commission:  p.Float("cumExecFee", stringField(order, "cumExecFee")),
+	}
+	if p.Err != nil {
+		return nil, fmt.Errorf("invalid order %s: %w", orderID, p.Err)
+	}
+	return status, nil
+}
@@ -0,0 +1,266 @@
+package bybit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"nofx/trader/types"
+	"nofx/trader/userstream"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// bybitPrivateWsURL Bybit V5 private stream
+const bybitPrivateWsURL = "wss://stream.bybit.com/v5/private"
+
+var _ types.UserDataStreamer = (*BybitTrader)(nil)
+
+// StreamUserData streams the order, execution, position and wallet topics of the V5 private stream
+func (t *BybitTrader) StreamUserData(ctx context.Context, onReconnect func()) (<-chan types.UserDataEvent, error) {
+	return userstream.Start(ctx, userstream.Config{
+		Name: "Bybit",
+		URL: func(ctx context.Context) (string, error) {
+			if t.userStreamURL != "" {
+				return t.userStreamURL, nil
+			}
+			return bybitPrivateWsURL, nil
+		},
+		OnConnect:   t.authenticateUserStream,
+		Parse:       t.parseUserData,
+		PingMessage: []byte(`{"op":"ping"}`),
+	}, onReconnect)
+}
+
+// authenticateUserStream authenticates and subscribes to the private topics
+func (t *BybitTrader) authenticateUserStream(conn *websocket.Conn) error {
+	expires := time.Now().Add(10 * time.Second).UnixMilli()
+	h := hmac.New(sha256.New, []byte(t.secretKey))
+	h.Write([]byte(fmt.Sprintf("GET/realtime%d", expires)))
+	signature := hex.EncodeToString(h.Sum(nil))
+
+	auth := map[string]interface{}{"op": "auth", "args": []interface{}{t.apiKey, expires, signature}}
+	if err := userstream.Request(conn, auth, bybitOpReply("auth")); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	subscribe := map[string]interface{}{"op": "subscribe", "args": []string{"order", "execution", "position", "wallet"}}
+	if err := userstream.Request(conn, subscribe, bybitOpReply("subscribe")); err != nil {
+		return fmt.Errorf("subscription failed: %w", err)
+	}
+	return nil
+}
+
+// bybitOpReply recognizes the response to an operation
+func bybitOpReply(op string) func(msg []byte) (bool, error) {
+	return func(msg []byte) (bool, error) {
+		var resp struct {
+			Op      string `json:"op"`
+			Success bool   `json:"success"`
+			RetMsg  string `json:"ret_msg"`
+		}
+		if err := json.Unmarshal(msg, &resp); err != nil || resp.Op != op {
+			return false, nil
+		}
+		if !resp.Success {
+			return false, fmt.Errorf("%s rejected: %s", op, resp.RetMsg)
+		}
+		return true, nil
+	}
+}
+
+// bybitUserDataMessage topic message of the private stream
+type bybitUserDataMessage struct {
+	Topic        string          `json:"topic"`
+	CreationTime int64           `json:"creationTime"`
+	Data         json.RawMessage `json:"data"`
+}
+
+// parseUserData converts a private stream message into events
+func (t *BybitTrader) parseUserData(msg []byte) ([]types.UserDataEvent, error) {
+	var m bybitUserDataMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+	if m.Topic == "" {
+		return nil, nil // Pong or operation response
+	}
+
+	var events []types.UserDataEvent
+	switch m.Topic {
+	case "order":
+		var orders []struct {
+			Symbol      string `json:"symbol"`
+			OrderID     string `json:"orderId"`
+			OrderLinkID string `json:"orderLinkId"`
+			Side        string `json:"side"`
+			PositionIdx int    `json:"positionIdx"`
+			OrderStatus string `json:"orderStatus"`
+			Price       string `json:"price"`
+			Qty         string `json:"qty"`
+			AvgPrice    string `json:"avgPrice"`
+			CumExecQty  string `json:"cumExecQty"`
+			UpdatedTime string `json:"updatedTime"`
+		}
+		if err := json.Unmarshal(m.Data, &orders); err != nil {
+			return nil, fmt.Errorf("failed to parse orders: %w", err)
+		}
+		for _, o := range orders {
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataOrder,
+				Symbol:        o.Symbol,
+				Time:          parseMillis(o.UpdatedTime, m.CreationTime),
+				OrderID:       o.OrderID,
+				ClientOrderID: o.OrderLinkID,
+				Side:          strings.ToUpper(o.Side),
+				PositionSide:  bybitPositionSide(o.PositionIdx, ""), // BOTH in one-way mode
+				Status:        bybitOrderStatus(o.OrderStatus),
+				Price:         parseFloat(o.Price),
+				Quantity:      parseFloat(o.Qty),
+				AvgPrice:      parseFloat(o.AvgPrice),
+				ExecutedQty:   parseFloat(o.CumExecQty),
+			})
+		}
+
+	case "execution":
+		var executions []struct {
+			Symbol      string `json:"symbol"`
+			OrderID     string `json:"orderId"`
+			OrderLinkID string `json:"orderLinkId"`
+			Side        string `json:"side"`
+			ExecID      string `json:"execId"`
+			ExecType    string `json:"execType"`
+			ExecPrice   string `json:"execPrice"`
+			ExecQty     string `json:"execQty"`
+			ExecFee     string `json:"execFee"`
+			ExecPnl     string `json:"execPnl"`
+			ClosedSize  string `json:"closedSize"`
+			ExecTime    string `json:"execTime"`
+		}
+		if err := json.Unmarshal(m.Data, &executions); err != nil {
+			return nil, fmt.Errorf("failed to parse executions: %w", err)
+		}
+		for _, e := range executions {
+			if e.ExecType != "" && e.ExecType != "Trade" {
+				continue // Funding and settlement are not fills
+			}
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataFill,
+				Symbol:        e.Symbol,
+				Time:          parseMillis(e.ExecTime, m.CreationTime),
+				OrderID:       e.OrderID,
+				ClientOrderID: e.OrderLinkID,
+				TradeID:       e.ExecID,
+				Side:          strings.ToUpper(e.Side),
+				Price:         parseFloat(e.ExecPrice),
+				Quantity:      parseFloat(e.ExecQty),
+				Fee:           parseFloat(e.ExecFee),
+				RealizedPnL:   parseFloat(e.ExecPnl),
+				ClosedQty:     parseFloat(e.ClosedSize),
+			})
+		}
+
+	case "position":
+		t.clearCache()
+		var positions []struct {
+			Symbol        string `json:"symbol"`
+			Side          string `json:"side"`
+			Size          string `json:"size"`
+			EntryPrice    string `json:"entryPrice"`
+			UnrealisedPnl string `json:"unrealisedPnl"`
+			PositionIdx   int    `json:"positionIdx"`
+			UpdatedTime   string `json:"updatedTime"`
+		}
+		if err := json.Unmarshal(m.Data, &positions); err != nil {
+			return nil, fmt.Errorf("failed to parse positions: %w", err)
+		}
+		for _, p := range positions {
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataPosition,
+				Symbol:        p.Symbol,
+				Time:          parseMillis(p.UpdatedTime, m.CreationTime),
+				PositionSide:  bybitPositionSide(p.PositionIdx, p.Side),
+				PositionAmt:   parseFloat(p.Size),
+				EntryPrice:    parseFloat(p.EntryPrice),
+				UnrealizedPnL: parseFloat(p.UnrealisedPnl),
+			})
+		}
+
+	case "wallet":
+		t.clearCache()
+		var wallets []struct {
+			Coin []struct {
+				Coin          string `json:"coin"`
+				WalletBalance string `json:"walletBalance"`
+			} `json:"coin"`
+			TotalAvailableBalance string `json:"totalAvailableBalance"`
+		}
+		if err := json.Unmarshal(m.Data, &wallets); err != nil {
+			return nil, fmt.Errorf("failed to parse wallet: %w", err)
+		}
+		for _, w := range wallets {
+			for _, c := range w.Coin {
+				event := types.UserDataEvent{
+					Type:          types.UserDataBalance,
+					Time:          m.CreationTime,
+					Asset:         c.Coin,
+					WalletBalance: parseFloat(c.WalletBalance),
+				}
+				if c.Coin == "USDT" {
+					event.AvailableBalance = parseFloat(w.TotalAvailableBalance)
+				}
+				events = append(events, event)
+			}
+		}
+	}
+	return events, nil
+}
+
+// bybitOrderStatus maps V5 order statuses to types.OrderStatus*
+func bybitOrderStatus(status string) string {
+	switch status {
+	case "PartiallyFilled":
+		return types.OrderStatusPartiallyFilled
+	case "Filled":
+		return types.OrderStatusFilled
+	case "Cancelled", "PartiallyFilledCanceled", "Deactivated":
+		return types.OrderStatusCanceled
+	case "Rejected":
+		return types.OrderStatusRejected
+	default: // New, Created, Untriggered, Triggered
+		return types.OrderStatusNew
+	}
+}
+
+// bybitPositionSide maps positionIdx (1 hedge buy side, 2 hedge sell side, 0 one-way) to LONG/SHORT.
+// In one-way mode the position side (Buy/Sell) decides; BOTH when there is none.
+func bybitPositionSide(positionIdx int, side string) string {
+	switch {
+	case positionIdx == 1:
+		return "LONG"
+	case positionIdx == 2:
+		return "SHORT"
+	case side == "Buy":
+		return "LONG"
+	case side == "Sell":
+		return "SHORT"
+	}
+	return "BOTH"
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// parseMillis parses a millisecond timestamp string, fallback when it is missing
+func parseMillis(s string, fallback int64) int64 {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil && ms > 0 {
+		return ms
+	}
+	return fallback
+}
@@ -0,0 +1,121 @@
+package bybit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"nofx/trader/types"
+)
+
+// newFakePrivateStream fake V5 private stream: verifies the auth signature and subscription,
+// then pushes the given messages
+func newFakePrivateStream(t *testing.T, secret string, pushes ...string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var auth struct {
+			Op   string        `json:"op"`
+			Args []interface{} `json:"args"`
+		}
+		if err := conn.ReadJSON(&auth); err != nil || auth.Op != "auth" || len(auth.Args) != 3 {
+			return
+		}
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write([]byte(fmt.Sprintf("GET/realtime%.0f", auth.Args[1])))
+		ok := hex.EncodeToString(h.Sum(nil)) == auth.Args[2]
+		conn.WriteJSON(map[string]interface{}{"op": "auth", "success": ok, "ret_msg": "invalid signature"})
+		if !ok {
+			return
+		}
+
+		var sub struct {
+			Op   string   `json:"op"`
+			Args []string `json:"args"`
+		}
+		if err := conn.ReadJSON(&sub); err != nil || sub.Op != "subscribe" {
+			return
+		}
+		conn.WriteJSON(map[string]interface{}{"op": "subscribe", "success": true})
+		for _, msg := range pushes {
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBybitStreamUserData(t *testing.T) {
+	srv := newFakePrivateStream(t, "secret",
+		`{"topic":"order","creationTime":1700000000000,"data":[{"symbol":"BTCUSDT","orderId":"abc","orderLinkId":"cl1","side":"Buy","positionIdx":0,"orderStatus":"Filled","price":"0","qty":"0.01","avgPrice":"50000.5","cumExecQty":"0.01","updatedTime":"1700000000001"}]}`,
+		`{"topic":"execution","creationTime":1700000000000,"data":[{"symbol":"BTCUSDT","orderId":"abc","side":"Buy","execId":"e1","execType":"Trade","execPrice":"50000.5","execQty":"0.01","execFee":"0.3","execTime":"1700000000002"},{"symbol":"BTCUSDT","execType":"Funding","execId":"f1"}]}`,
+		`{"topic":"position","creationTime":1700000000000,"data":[{"symbol":"BTCUSDT","side":"Sell","size":"0.5","entryPrice":"51000","unrealisedPnl":"-12.5","positionIdx":0}]}`,
+		`{"topic":"wallet","creationTime":1700000000003,"data":[{"totalAvailableBalance":"900","coin":[{"coin":"USDT","walletBalance":"1000"}]}]}`,
+	)
+	trader := &BybitTrader{apiKey: "key", secretKey: "secret", userStreamURL: "ws" + strings.TrimPrefix(srv.URL, "http")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := trader.StreamUserData(ctx, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var got []types.UserDataEvent
+	for len(got) < 4 {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout, got %d events", len(got))
+		}
+	}
+
+	order, fill, position, balance := got[0], got[1], got[2], got[3]
+	assert.Equal(t, types.UserDataOrder, order.Type)
+	assert.Equal(t, "abc", order.OrderID)
+	assert.Equal(t, types.OrderStatusFilled, order.Status)
+	assert.Equal(t, "BUY", order.Side)
+	assert.Equal(t, "BOTH", order.PositionSide)
+	assert.Equal(t, 50000.5, order.AvgPrice)
+	assert.Equal(t, int64(1700000000001), order.Time)
+
+	assert.Equal(t, types.UserDataFill, fill.Type)
+	assert.Equal(t, "e1", fill.TradeID)
+	assert.Equal(t, 0.3, fill.Fee)
+
+	assert.Equal(t, types.UserDataPosition, position.Type)
+	assert.Equal(t, "SHORT", position.PositionSide)
+	assert.Equal(t, 0.5, position.PositionAmt)
+
+	assert.Equal(t, types.UserDataBalance, balance.Type)
+	assert.Equal(t, 1000.0, balance.WalletBalance)
+	assert.Equal(t, 900.0, balance.AvailableBalance)
+}
+
+func TestBybitStreamUserDataBadCredentials(t *testing.T) {
+	srv := newFakePrivateStream(t, "secret")
+	trader := &BybitTrader{apiKey: "key", secretKey: "wrong", userStreamURL: "ws" + strings.TrimPrefix(srv.URL, "http")}
+
+	_, err := trader.StreamUserData(context.Background(), nil)
+	assert.ErrorContains(t, err, "authentication failed")
+}
@@ -0,0 +1,132 @@
+package trader
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/kernel"
+)
+
+// Decision stream event types
+const (
+	DecisionStreamCycleStart = "cycle_start" // AI request started
+	DecisionStreamChunk      = "chunk"       // New model output
+	DecisionStreamCycleEnd   = "cycle_end"   // AI answered (decisions) or failed (error)
+)
+
+// decisionStreamBuffer events buffered per subscriber; a subscriber that falls further behind misses chunks
+const decisionStreamBuffer = 256
+
+// DecisionStreamEvent live update of an AI decision cycle
+type DecisionStreamEvent struct {
+	Type      string            `json:"type"`
+	Cycle     int               `json:"cycle"`
+	Text      string            `json:"text,omitempty"`      // chunk: new text; snapshot: text so far
+	Streaming bool              `json:"streaming,omitempty"` // cycle_start: false if the model output is not streamed
+	Decisions []kernel.Decision `json:"decisions,omitempty"` // cycle_end
+	Error     string            `json:"error,omitempty"`     // cycle_end
+	Timestamp int64             `json:"timestamp"`
+}
+
+// decisionStream fans decision cycle events out to dashboard subscribers and keeps the
+// text of the cycle in progress for late subscribers
+type decisionStream struct {
+	mu        sync.Mutex
+	subs      map[chan DecisionStreamEvent]struct{}
+	cycle     int
+	active    bool
+	streaming bool
+	text      strings.Builder
+	idle      context.CancelFunc // Cancels the watched cycle once the last subscriber leaves
+}
+
+func newDecisionStream() *decisionStream {
+	return &decisionStream{subs: make(map[chan DecisionStreamEvent]struct{})}
+}
+
+// subscribe registers a subscriber; snapshot is non-nil while a cycle is in progress
+func (s *decisionStream) subscribe() (ch chan DecisionStreamEvent, snapshot *DecisionStreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch = make(chan DecisionStreamEvent, decisionStreamBuffer)
+	s.subs[ch] = struct{}{}
+	if s.active {
+		snapshot = &DecisionStreamEvent{
+			Type:      DecisionStreamCycleStart,
+			Cycle:     s.cycle,
+			Text:      s.text.String(),
+			Streaming: s.streaming,
+			Timestamp: time.Now().UnixMilli(),
+		}
+	}
+	return ch, snapshot
+}
+
+func (s *decisionStream) unsubscribe(ch chan DecisionStreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	if len(s.subs) == 0 && s.idle != nil {
+		s.idle()
+	}
+}
+
+// watch returns a context derived from parent that is cancelled once nobody is subscribed
+// anymore, so a streamed decision isn't generated for a dashboard that was closed
+func (s *decisionStream) watch(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	s.mu.Lock()
+	s.idle = cancel
+	s.mu.Unlock()
+	return ctx, func() {
+		s.mu.Lock()
+		s.idle = nil
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+func (s *decisionStream) hasSubscribers() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs) > 0
+}
+
+// publish records the event and sends it to every subscriber without blocking the trader
+func (s *decisionStream) publish(event DecisionStreamEvent) {
+	event.Timestamp = time.Now().UnixMilli()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch event.Type {
+	case DecisionStreamCycleStart:
+		s.cycle = event.Cycle
+		s.active = true
+		s.streaming = event.Streaming
+		s.text.Reset()
+	case DecisionStreamChunk:
+		s.text.WriteString(event.Text)
+	case DecisionStreamCycleEnd:
+		s.active = false
+		s.text.Reset()
+	}
+
+	for ch := range s.subs {
+		select {
+		case ch <- event:
+		default: // Slow subscriber, drop rather than stall the trading cycle
+		}
+	}
+}
+
+// SubscribeDecisionStream subscribes to live AI decision events of this trader. snapshot holds the
+// model output so far if a cycle is in progress. Call cancel when done to release the subscription.
+func (at *AutoTrader) SubscribeDecisionStream() (events <-chan DecisionStreamEvent, snapshot *DecisionStreamEvent, cancel func()) {
+	ch, snapshot := at.decisionStream.subscribe()
+	return ch, snapshot, func() { at.decisionStream.unsubscribe(ch) }
+}
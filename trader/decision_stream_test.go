@@ -0,0 +1,77 @@
+package trader
+
+import (
+	"context"
+	"testing"
+
+	"nofx/kernel"
+)
+
+func TestDecisionStreamSnapshotAndFanOut(t *testing.T) {
+	s := newDecisionStream()
+	if s.hasSubscribers() {
+		t.Fatal("new stream should have no subscribers")
+	}
+
+	s.publish(DecisionStreamEvent{Type: DecisionStreamCycleStart, Cycle: 7, Streaming: true})
+	s.publish(DecisionStreamEvent{Type: DecisionStreamChunk, Cycle: 7, Text: "BTC trend "})
+
+	// A subscriber joining mid-cycle gets the output so far
+	ch, snapshot := s.subscribe()
+	if snapshot == nil || snapshot.Cycle != 7 || snapshot.Text != "BTC trend " || !snapshot.Streaming {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	s.publish(DecisionStreamEvent{Type: DecisionStreamChunk, Cycle: 7, Text: "is up"})
+	s.publish(DecisionStreamEvent{Type: DecisionStreamCycleEnd, Cycle: 7, Decisions: []kernel.Decision{{Symbol: "BTCUSDT", Action: "hold"}}})
+
+	if ev := <-ch; ev.Type != DecisionStreamChunk || ev.Text != "is up" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev := <-ch; ev.Type != DecisionStreamCycleEnd || len(ev.Decisions) != 1 || ev.Timestamp == 0 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	if _, snapshot := s.subscribe(); snapshot != nil {
+		t.Error("no snapshot expected between cycles")
+	}
+
+	s.unsubscribe(ch)
+	if _, open := <-ch; open {
+		t.Error("unsubscribe should close the channel")
+	}
+}
+
+func TestDecisionStreamDropsForSlowSubscriber(t *testing.T) {
+	s := newDecisionStream()
+	ch, _ := s.subscribe()
+	for i := 0; i < decisionStreamBuffer+10; i++ {
+		s.publish(DecisionStreamEvent{Type: DecisionStreamChunk, Text: "x"})
+	}
+	if len(ch) != decisionStreamBuffer {
+		t.Errorf("expected a full buffer of %d events, got %d", decisionStreamBuffer, len(ch))
+	}
+}
+
+func TestDecisionStreamWatchCancelsWhenIdle(t *testing.T) {
+	s := newDecisionStream()
+	a, _ := s.subscribe()
+	b, _ := s.subscribe()
+
+	ctx, cancel := s.watch(context.Background())
+	defer cancel()
+
+	s.unsubscribe(a)
+	if ctx.Err() != nil {
+		t.Fatal("watched context cancelled while a subscriber is left")
+	}
+	s.unsubscribe(b)
+	if ctx.Err() == nil {
+		t.Error("watched context should be cancelled once the last subscriber leaves")
+	}
+
+	// After the cycle, subscribers coming and going don't affect anything
+	cancel()
+	c, _ := s.subscribe()
+	s.unsubscribe(c)
+}
@@ -0,0 +1,141 @@
+package fillmodel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Book keeps resting orders in submission order so limit orders at the same price
+// keep their FIFO queue priority. It is not safe for concurrent use.
+type Book struct {
+	model  *Model
+	orders []*Order
+	seq    int
+}
+
+// NewBook creates an empty order book matched by model
+func NewBook(model *Model) *Book {
+	return &Book{model: model}
+}
+
+// Submit adds a resting order. lastPrice is used to reject post-only orders that would cross.
+// An empty ID is assigned automatically.
+func (b *Book) Submit(o *Order, lastPrice float64) error {
+	if o == nil {
+		return fmt.Errorf("order is nil")
+	}
+	if o.Side != SideBuy && o.Side != SideSell {
+		return fmt.Errorf("invalid order side: %s", o.Side)
+	}
+	if o.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	switch o.Type {
+	case TypeLimit:
+		if o.Price <= 0 {
+			return fmt.Errorf("limit price must be positive")
+		}
+		if o.PostOnly && b.model.WouldCross(o, lastPrice) {
+			return fmt.Errorf("post-only order would immediately match: price %.6f, market %.6f", o.Price, lastPrice)
+		}
+	case TypeStopMarket, TypeTakeProfitMarket:
+		if o.StopPrice <= 0 {
+			return fmt.Errorf("stop price must be positive")
+		}
+	case TypeMarket:
+	default:
+		return fmt.Errorf("unsupported order type: %s", o.Type)
+	}
+
+	o.Symbol = strings.ToUpper(o.Symbol)
+	if o.ID == "" {
+		b.seq++
+		o.ID = fmt.Sprintf("sim-%d", b.seq)
+	}
+	b.orders = append(b.orders, o)
+	return nil
+}
+
+// Cancel cancels an order by ID, reporting whether it was open
+func (b *Book) Cancel(id string) bool {
+	for _, o := range b.orders {
+		if o.ID == id && o.Active() {
+			o.Canceled = true
+			b.prune()
+			return true
+		}
+	}
+	return false
+}
+
+// CancelWhere cancels open orders selected by match and returns how many were cancelled
+func (b *Book) CancelWhere(match func(*Order) bool) int {
+	count := 0
+	for _, o := range b.orders {
+		if o.Active() && match(o) {
+			o.Canceled = true
+			count++
+		}
+	}
+	b.prune()
+	return count
+}
+
+// Orders returns open orders for symbol (all symbols when empty)
+func (b *Book) Orders(symbol string) []*Order {
+	symbol = strings.ToUpper(symbol)
+	list := make([]*Order, 0, len(b.orders))
+	for _, o := range b.orders {
+		if o.Active() && (symbol == "" || o.Symbol == symbol) {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// Match runs symbol's open orders against bar and drops orders that are done
+func (b *Book) Match(symbol string, bar Bar) []Fill {
+	fills := b.model.Match(b.Orders(symbol), bar)
+	b.prune()
+	return fills
+}
+
+// BookState open orders of a Book in submission order, with the ID sequence, for checkpoints
+type BookState struct {
+	Orders []Order `json:"orders"`
+	Seq    int     `json:"seq"`
+}
+
+// State returns a copy of the open orders
+func (b *Book) State() BookState {
+	state := BookState{Orders: make([]Order, 0, len(b.orders)), Seq: b.seq}
+	for _, o := range b.orders {
+		if o.Active() {
+			state.Orders = append(state.Orders, *o)
+		}
+	}
+	return state
+}
+
+// Restore replaces the open orders with a saved state, keeping their queue priority
+func (b *Book) Restore(state BookState) {
+	b.orders = make([]*Order, 0, len(state.Orders))
+	for i := range state.Orders {
+		o := state.Orders[i]
+		b.orders = append(b.orders, &o)
+	}
+	b.seq = state.Seq
+}
+
+func (b *Book) prune() {
+	kept := b.orders[:0]
+	for _, o := range b.orders {
+		if o.Active() {
+			kept = append(kept, o)
+		}
+	}
+	for i := len(kept); i < len(b.orders); i++ {
+		b.orders[i] = nil
+	}
+	b.orders = kept
+}
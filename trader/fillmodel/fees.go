@@ -0,0 +1,52 @@
+package fillmodel
+
+import "strings"
+
+// FeeSchedule holds maker/taker fee rates as fractions of notional (0.0005 = 5 bps)
+type FeeSchedule struct {
+	Maker float64 `json:"maker"`
+	Taker float64 `json:"taker"`
+}
+
+// Rate returns the maker or taker rate
+func (f FeeSchedule) Rate(isMaker bool) float64 {
+	if isMaker {
+		return f.Maker
+	}
+	return f.Taker
+}
+
+// FlatFees returns a schedule charging the same rate for maker and taker fills
+func FlatFees(rate float64) FeeSchedule {
+	return FeeSchedule{Maker: rate, Taker: rate}
+}
+
+// DefaultExchange is the schedule used when an exchange has no entry
+const DefaultExchange = "binance"
+
+// exchangeFees are base-tier (VIP0 / no discount) USDT perpetual fee rates per exchange type
+var exchangeFees = map[string]FeeSchedule{
+	"binance":     {Maker: 0.0002, Taker: 0.0005},
+	"bybit":       {Maker: 0.0002, Taker: 0.00055},
+	"okx":         {Maker: 0.0002, Taker: 0.0005},
+	"bitget":      {Maker: 0.0002, Taker: 0.0006},
+	"gate":        {Maker: 0.0002, Taker: 0.0005},
+	"kucoin":      {Maker: 0.0002, Taker: 0.0006},
+	"hyperliquid": {Maker: 0.00015, Taker: 0.00045},
+	"aster":       {Maker: 0.0001, Taker: 0.00035},
+	"lighter":     {Maker: 0, Taker: 0},
+}
+
+// ExchangeFees returns the fee schedule for an exchange type (binance, bybit, okx, ...)
+func ExchangeFees(exchange string) (FeeSchedule, bool) {
+	fees, ok := exchangeFees[strings.ToLower(strings.TrimSpace(exchange))]
+	return fees, ok
+}
+
+// FeesFor returns the schedule for exchange, falling back to DefaultExchange
+func FeesFor(exchange string) FeeSchedule {
+	if fees, ok := ExchangeFees(exchange); ok {
+		return fees
+	}
+	return exchangeFees[DefaultExchange]
+}
@@ -0,0 +1,394 @@
+// Package fillmodel simulates order execution against OHLCV bars.
+//
+// It is shared by the backtest runner and the paper trader so that market orders,
+// stop-loss / take-profit triggers, resting limit orders, fees and slippage behave
+// the same way in both. Live prices are fed in as single-price bars (see PriceBar).
+package fillmodel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const epsilon = 1e-9
+
+// Order sides
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+)
+
+// Order types, named like exchange order types
+const (
+	TypeMarket           = "MARKET"
+	TypeLimit            = "LIMIT"
+	TypeStopMarket       = "STOP_MARKET"
+	TypeTakeProfitMarket = "TAKE_PROFIT_MARKET"
+)
+
+// TieBreak decides which protective order wins when a single bar crosses both
+// the stop-loss and the take-profit of the same position
+type TieBreak string
+
+const (
+	// TieBreakStopFirst assumes the stop was hit first (conservative default)
+	TieBreakStopFirst TieBreak = "stop_first"
+	// TieBreakTargetFirst assumes the take-profit was hit first
+	TieBreakTargetFirst TieBreak = "target_first"
+	// TieBreakNearestOpen assumes the level closer to the bar open was hit first
+	TieBreakNearestOpen TieBreak = "nearest_open"
+)
+
+// ParseTieBreak validates a tie-break name; empty means TieBreakStopFirst
+func ParseTieBreak(name string) (TieBreak, error) {
+	switch tb := TieBreak(strings.ToLower(strings.TrimSpace(name))); tb {
+	case "":
+		return TieBreakStopFirst, nil
+	case TieBreakStopFirst, TieBreakTargetFirst, TieBreakNearestOpen:
+		return tb, nil
+	default:
+		return "", fmt.Errorf("unsupported tie-break '%s'", name)
+	}
+}
+
+// Bar is one OHLCV candle (Time in milliseconds)
+type Bar struct {
+	Time   int64
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// PriceBar wraps a single observed price as a bar, for live/paper price polling.
+// Volume is unknown, so queue position and participation limits do not apply.
+func PriceBar(ts int64, price float64) Bar {
+	return Bar{Time: ts, Open: price, High: price, Low: price, Close: price}
+}
+
+// Order is a simulated order. Match updates Filled, QueueAhead and Canceled in place.
+type Order struct {
+	ID         string
+	Symbol     string
+	Side       string // BUY / SELL
+	Type       string // MARKET / LIMIT / STOP_MARKET / TAKE_PROFIT_MARKET
+	Price      float64
+	StopPrice  float64
+	Quantity   float64
+	Filled     float64
+	PostOnly   bool
+	ReduceOnly bool
+
+	// OCOGroup links protective orders of one position: only one member fills per bar,
+	// and the rest are cancelled once it is completely filled
+	OCOGroup string
+
+	// QueueAhead is the volume resting before this limit order at its price.
+	// A bar that only touches the price must trade through the queue before the order fills.
+	QueueAhead float64
+
+	Canceled bool
+}
+
+// Remaining returns the unfilled quantity
+func (o *Order) Remaining() float64 {
+	remaining := o.Quantity - o.Filled
+	if remaining < epsilon {
+		return 0
+	}
+	return remaining
+}
+
+// Active reports whether the order can still fill
+func (o *Order) Active() bool {
+	return !o.Canceled && o.Remaining() > 0
+}
+
+// Fill is one execution produced by the model
+type Fill struct {
+	OrderID  string
+	Symbol   string
+	Side     string
+	Type     string
+	Time     int64
+	Price    float64
+	Quantity float64
+	Fee      float64
+	IsMaker  bool
+	Partial  bool // order still has quantity left after this fill
+}
+
+// Notional returns price * quantity
+func (f Fill) Notional() float64 {
+	return f.Price * f.Quantity
+}
+
+// Reason describes why the fill happened: stop_loss, take_profit, limit or market
+func (f Fill) Reason() string {
+	switch f.Type {
+	case TypeStopMarket:
+		return "stop_loss"
+	case TypeTakeProfitMarket:
+		return "take_profit"
+	case TypeLimit:
+		return "limit"
+	default:
+		return "market"
+	}
+}
+
+// Config controls fees, slippage and matching behavior
+type Config struct {
+	Fees FeeSchedule
+	// SlippageRate moves taker fills against the order side (fraction of price)
+	SlippageRate float64
+	TieBreak     TieBreak
+	// MaxParticipation caps each fill at this fraction of bar volume (0 = no cap).
+	// Orders larger than the cap fill partially over several bars.
+	MaxParticipation float64
+	// TradeThrough requires price to trade beyond a limit price; a touch is not enough
+	TradeThrough bool
+}
+
+// Model executes orders according to Config. It holds no order state and is safe for concurrent use.
+type Model struct {
+	cfg Config
+}
+
+// New creates a fill model, defaulting an empty tie-break to TieBreakStopFirst
+func New(cfg Config) *Model {
+	if cfg.TieBreak == "" {
+		cfg.TieBreak = TieBreakStopFirst
+	}
+	if cfg.SlippageRate < 0 {
+		cfg.SlippageRate = 0
+	}
+	if cfg.MaxParticipation < 0 {
+		cfg.MaxParticipation = 0
+	}
+	return &Model{cfg: cfg}
+}
+
+// Config returns the model configuration
+func (m *Model) Config() Config {
+	return m.cfg
+}
+
+// Fee returns the commission for a fill of notional
+func (m *Model) Fee(notional float64, isMaker bool) float64 {
+	return math.Abs(notional) * m.cfg.Fees.Rate(isMaker)
+}
+
+// Slip applies adverse slippage: buys fill higher, sells lower
+func (m *Model) Slip(price float64, side string) float64 {
+	if m.cfg.SlippageRate <= 0 {
+		return price
+	}
+	if side == SideBuy {
+		return price * (1 + m.cfg.SlippageRate)
+	}
+	return price * (1 - m.cfg.SlippageRate)
+}
+
+// Capacity caps quantity by the participation limit for a bar of volume (volume <= 0 means unknown)
+func (m *Model) Capacity(quantity, volume float64) float64 {
+	if m.cfg.MaxParticipation <= 0 || volume <= 0 {
+		return quantity
+	}
+	return math.Min(quantity, volume*m.cfg.MaxParticipation)
+}
+
+// FillMarket executes a taker order at price with slippage, capped by the participation limit.
+// The returned fill is Partial when the cap cut the quantity.
+func (m *Model) FillMarket(symbol, side string, quantity, price, volume float64, ts int64) Fill {
+	qty := m.Capacity(quantity, volume)
+	execPrice := m.Slip(price, side)
+	return Fill{
+		Symbol:   symbol,
+		Side:     side,
+		Type:     TypeMarket,
+		Time:     ts,
+		Price:    execPrice,
+		Quantity: qty,
+		Fee:      m.Fee(execPrice*qty, false),
+		Partial:  qty < quantity-epsilon,
+	}
+}
+
+// WouldCross reports whether a limit order would match immediately at the current price,
+// which post-only orders must reject
+func (m *Model) WouldCross(o *Order, price float64) bool {
+	if o.Type != TypeLimit || price <= 0 {
+		return false
+	}
+	if o.Side == SideBuy {
+		return o.Price >= price
+	}
+	return o.Price <= price
+}
+
+// trigger is one order reached during a bar
+type trigger struct {
+	order *Order
+	price float64 // execution price before slippage
+	dist  float64 // distance from the open, used to order events inside the bar
+	touch bool    // limit price was touched but not traded through
+}
+
+// Match runs resting orders against one bar and returns the fills in the order they
+// are assumed to happen inside the bar (levels closer to the open first).
+//
+//   - Stop and take-profit orders trigger from the bar high/low and fill as takers at the
+//     trigger price, or at the open when the bar gapped through it, plus slippage
+//   - Limit orders fill as makers at their own price; a bare touch must first clear QueueAhead
+//   - For OCO groups only one member fills per bar, chosen by the tie-break
+func (m *Model) Match(orders []*Order, bar Bar) []Fill {
+	if bar.Open <= 0 {
+		bar.Open = bar.Close
+	}
+
+	hits := make([]trigger, 0)
+	for _, o := range orders {
+		if o == nil || !o.Active() {
+			continue
+		}
+		if hit, ok := m.trigger(o, bar); ok {
+			hits = append(hits, hit)
+		}
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+	// Stable sort keeps submission (FIFO) priority between orders at the same level
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].dist < hits[j].dist })
+
+	winners := make(map[string]*Order)
+	for _, hit := range hits {
+		group := hit.order.OCOGroup
+		if group == "" {
+			continue
+		}
+		if current, ok := winners[group]; !ok || m.outranks(hit.order, current) {
+			winners[group] = hit.order
+		}
+	}
+
+	fills := make([]Fill, 0, len(hits))
+	for _, hit := range hits {
+		o := hit.order
+		if o.Canceled {
+			continue
+		}
+		if o.OCOGroup != "" && winners[o.OCOGroup] != o {
+			continue
+		}
+
+		qty := o.Remaining()
+		if hit.touch && o.QueueAhead > 0 && bar.Volume > 0 {
+			consumed := math.Min(o.QueueAhead, bar.Volume)
+			o.QueueAhead -= consumed
+			if o.QueueAhead > epsilon {
+				continue
+			}
+			qty = math.Min(qty, bar.Volume-consumed)
+		}
+		qty = m.Capacity(qty, bar.Volume)
+		if qty <= epsilon {
+			continue
+		}
+
+		isMaker := o.Type == TypeLimit
+		price := hit.price
+		if !isMaker {
+			price = m.Slip(price, o.Side)
+		}
+		o.Filled += qty
+
+		fills = append(fills, Fill{
+			OrderID:  o.ID,
+			Symbol:   o.Symbol,
+			Side:     o.Side,
+			Type:     o.Type,
+			Time:     bar.Time,
+			Price:    price,
+			Quantity: qty,
+			Fee:      m.Fee(price*qty, isMaker),
+			IsMaker:  isMaker,
+			Partial:  o.Remaining() > 0,
+		})
+
+		if o.OCOGroup != "" && o.Remaining() == 0 {
+			for _, sibling := range orders {
+				if sibling != nil && sibling != o && sibling.OCOGroup == o.OCOGroup {
+					sibling.Canceled = true
+				}
+			}
+		}
+	}
+	return fills
+}
+
+// trigger reports whether o executes during bar and at which price
+func (m *Model) trigger(o *Order, bar Bar) (trigger, bool) {
+	hit := trigger{order: o}
+	switch o.Type {
+	case TypeMarket:
+		hit.price = bar.Open
+		return hit, bar.Open > 0
+
+	case TypeLimit:
+		if o.Price <= 0 {
+			return hit, false
+		}
+		hit.price = o.Price
+		hit.dist = math.Abs(o.Price - bar.Open)
+		if o.Side == SideBuy {
+			if bar.Low < o.Price-epsilon {
+				return hit, true
+			}
+			hit.touch = true
+			return hit, !m.cfg.TradeThrough && bar.Low <= o.Price+epsilon
+		}
+		if bar.High > o.Price+epsilon {
+			return hit, true
+		}
+		hit.touch = true
+		return hit, !m.cfg.TradeThrough && bar.High >= o.Price-epsilon
+
+	case TypeStopMarket, TypeTakeProfitMarket:
+		if o.StopPrice <= 0 {
+			return hit, false
+		}
+		// A sell stop and a buy take-profit fire on the way down, the others on the way up
+		falling := (o.Type == TypeStopMarket) == (o.Side == SideSell)
+		if falling {
+			if bar.Low > o.StopPrice {
+				return hit, false
+			}
+			hit.price = math.Min(o.StopPrice, bar.Open)
+		} else {
+			if bar.High < o.StopPrice {
+				return hit, false
+			}
+			hit.price = math.Max(o.StopPrice, bar.Open)
+		}
+		hit.dist = math.Abs(hit.price - bar.Open)
+		return hit, true
+	}
+	return hit, false
+}
+
+// outranks reports whether a should win over b inside the same OCO group
+func (m *Model) outranks(a, b *Order) bool {
+	switch m.cfg.TieBreak {
+	case TieBreakStopFirst:
+		return a.Type == TypeStopMarket && b.Type == TypeTakeProfitMarket
+	case TieBreakTargetFirst:
+		return a.Type == TypeTakeProfitMarket && b.Type == TypeStopMarket
+	}
+	// nearest_open: hits are visited closest-first, so the first member stays
+	return false
+}
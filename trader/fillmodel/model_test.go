@@ -0,0 +1,180 @@
+package fillmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func protectiveOrders(side string, stop, target float64) (*Order, *Order) {
+	closeSide := SideSell
+	if side == "short" {
+		closeSide = SideBuy
+	}
+	sl := &Order{ID: "sl", Symbol: "BTCUSDT", Side: closeSide, Type: TypeStopMarket, StopPrice: stop, Quantity: 1, ReduceOnly: true, OCOGroup: "BTCUSDT:" + side}
+	tp := &Order{ID: "tp", Symbol: "BTCUSDT", Side: closeSide, Type: TypeTakeProfitMarket, StopPrice: target, Quantity: 1, ReduceOnly: true, OCOGroup: "BTCUSDT:" + side}
+	return sl, tp
+}
+
+func TestExchangeFees(t *testing.T) {
+	fees, ok := ExchangeFees("Bybit")
+	require.True(t, ok)
+	assert.InDelta(t, 0.00055, fees.Rate(false), 1e-12)
+	assert.InDelta(t, 0.0002, fees.Rate(true), 1e-12)
+
+	_, ok = ExchangeFees("unknown")
+	assert.False(t, ok)
+	assert.Equal(t, exchangeFees[DefaultExchange], FeesFor("unknown"))
+}
+
+func TestParseTieBreak(t *testing.T) {
+	tb, err := ParseTieBreak("")
+	require.NoError(t, err)
+	assert.Equal(t, TieBreakStopFirst, tb)
+
+	tb, err = ParseTieBreak("Nearest_Open")
+	require.NoError(t, err)
+	assert.Equal(t, TieBreakNearestOpen, tb)
+
+	_, err = ParseTieBreak("random")
+	assert.Error(t, err)
+}
+
+func TestFillMarket(t *testing.T) {
+	m := New(Config{Fees: FeeSchedule{Maker: 0.0002, Taker: 0.0005}, SlippageRate: 0.001, MaxParticipation: 0.1})
+
+	buy := m.FillMarket("BTCUSDT", SideBuy, 1, 100, 0, 0)
+	assert.InDelta(t, 100.1, buy.Price, 1e-9)
+	assert.InDelta(t, 1.0, buy.Quantity, 1e-9)
+	assert.InDelta(t, 100.1*0.0005, buy.Fee, 1e-9)
+	assert.False(t, buy.Partial)
+
+	// Volume 5 with 10% participation leaves room for 0.5
+	sell := m.FillMarket("BTCUSDT", SideSell, 1, 100, 5, 0)
+	assert.InDelta(t, 99.9, sell.Price, 1e-9)
+	assert.InDelta(t, 0.5, sell.Quantity, 1e-9)
+	assert.True(t, sell.Partial)
+}
+
+func TestMatch_StopsTriggerFromHighLow(t *testing.T) {
+	m := New(Config{Fees: FeeSchedule{Taker: 0.0005}})
+
+	tests := []struct {
+		name      string
+		side      string
+		bar       Bar
+		wantType  string
+		wantPrice float64
+	}{
+		{name: "long stop from low", side: "long", bar: Bar{Open: 100, High: 101, Low: 94, Close: 99}, wantType: TypeStopMarket, wantPrice: 95},
+		{name: "long target from high", side: "long", bar: Bar{Open: 100, High: 111, Low: 99, Close: 104}, wantType: TypeTakeProfitMarket, wantPrice: 110},
+		{name: "long stop gap fills at open", side: "long", bar: Bar{Open: 90, High: 92, Low: 88, Close: 91}, wantType: TypeStopMarket, wantPrice: 90},
+		{name: "short stop from high", side: "short", bar: Bar{Open: 100, High: 106, Low: 99, Close: 101}, wantType: TypeStopMarket, wantPrice: 105},
+		{name: "short target from low", side: "short", bar: Bar{Open: 100, High: 101, Low: 89, Close: 95}, wantType: TypeTakeProfitMarket, wantPrice: 90},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stop, target := 95.0, 110.0
+			if tt.side == "short" {
+				stop, target = 105, 90
+			}
+			sl, tp := protectiveOrders(tt.side, stop, target)
+			fills := m.Match([]*Order{sl, tp}, tt.bar)
+			require.Len(t, fills, 1)
+			assert.Equal(t, tt.wantType, fills[0].Type)
+			assert.InDelta(t, tt.wantPrice, fills[0].Price, 1e-9)
+			assert.False(t, fills[0].IsMaker)
+			assert.True(t, sl.Canceled || tp.Canceled, "sibling should be cancelled")
+		})
+	}
+
+	sl, tp := protectiveOrders("long", 95, 110)
+	assert.Empty(t, m.Match([]*Order{sl, tp}, Bar{Open: 100, High: 105, Low: 96, Close: 101}))
+}
+
+func TestMatch_TieBreak(t *testing.T) {
+	// Both levels crossed; the open (98) is closer to the stop (95) than the target (110)
+	bar := Bar{Open: 98, High: 112, Low: 93, Close: 100}
+
+	tests := []struct {
+		tieBreak TieBreak
+		wantType string
+	}{
+		{TieBreakStopFirst, TypeStopMarket},
+		{TieBreakTargetFirst, TypeTakeProfitMarket},
+		{TieBreakNearestOpen, TypeStopMarket},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.tieBreak), func(t *testing.T) {
+			sl, tp := protectiveOrders("long", 95, 110)
+			fills := New(Config{TieBreak: tt.tieBreak}).Match([]*Order{sl, tp}, bar)
+			require.Len(t, fills, 1)
+			assert.Equal(t, tt.wantType, fills[0].Type)
+		})
+	}
+
+	sl, tp := protectiveOrders("long", 95, 110)
+	fills := New(Config{TieBreak: TieBreakNearestOpen}).Match([]*Order{sl, tp}, Bar{Open: 108, High: 112, Low: 93, Close: 100})
+	require.Len(t, fills, 1)
+	assert.Equal(t, TypeTakeProfitMarket, fills[0].Type)
+}
+
+func TestMatch_LimitQueueAndPartialFills(t *testing.T) {
+	m := New(Config{Fees: FeeSchedule{Maker: 0.0002, Taker: 0.0005}, MaxParticipation: 0.5})
+
+	// Touching the price only: 30 ahead in the queue, bar volume 20 is not enough
+	order := &Order{ID: "l1", Symbol: "BTCUSDT", Side: SideBuy, Type: TypeLimit, Price: 100, Quantity: 10, QueueAhead: 30}
+	assert.Empty(t, m.Match([]*Order{order}, Bar{Open: 102, High: 103, Low: 100, Close: 101, Volume: 20}))
+	assert.InDelta(t, 10.0, order.QueueAhead, 1e-9)
+
+	// Next touch clears the queue; 10 remaining volume, capped at 50% of the bar = 10
+	fills := m.Match([]*Order{order}, Bar{Open: 102, High: 103, Low: 100, Close: 101, Volume: 20})
+	require.Len(t, fills, 1)
+	assert.InDelta(t, 10.0, fills[0].Quantity, 1e-9)
+	assert.InDelta(t, 100.0, fills[0].Price, 1e-9)
+	assert.True(t, fills[0].IsMaker)
+	assert.InDelta(t, 100*10*0.0002, fills[0].Fee, 1e-9)
+	assert.False(t, fills[0].Partial)
+
+	// Trading through ignores the queue but still respects participation
+	big := &Order{ID: "l2", Symbol: "BTCUSDT", Side: SideSell, Type: TypeLimit, Price: 105, Quantity: 8, QueueAhead: 1000}
+	fills = m.Match([]*Order{big}, Bar{Open: 103, High: 107, Low: 102, Close: 106, Volume: 10})
+	require.Len(t, fills, 1)
+	assert.InDelta(t, 5.0, fills[0].Quantity, 1e-9)
+	assert.True(t, fills[0].Partial)
+	assert.InDelta(t, 3.0, big.Remaining(), 1e-9)
+}
+
+func TestMatch_TradeThroughRequired(t *testing.T) {
+	m := New(Config{TradeThrough: true})
+	order := &Order{ID: "l1", Side: SideBuy, Type: TypeLimit, Price: 100, Quantity: 1}
+	assert.Empty(t, m.Match([]*Order{order}, PriceBar(0, 100)))
+	assert.Len(t, m.Match([]*Order{order}, PriceBar(0, 99.9)), 1)
+}
+
+func TestBook(t *testing.T) {
+	book := NewBook(New(Config{}))
+
+	require.Error(t, book.Submit(&Order{Side: SideBuy, Type: TypeLimit, Price: 101, Quantity: 1, PostOnly: true}, 100))
+
+	first := &Order{Symbol: "btcusdt", Side: SideBuy, Type: TypeLimit, Price: 99, Quantity: 1}
+	second := &Order{Symbol: "BTCUSDT", Side: SideBuy, Type: TypeLimit, Price: 99, Quantity: 1}
+	other := &Order{Symbol: "ETHUSDT", Side: SideSell, Type: TypeLimit, Price: 4000, Quantity: 1}
+	require.NoError(t, book.Submit(first, 100))
+	require.NoError(t, book.Submit(second, 100))
+	require.NoError(t, book.Submit(other, 3900))
+	assert.Len(t, book.Orders("BTCUSDT"), 2)
+
+	// Same price level fills in submission order
+	fills := book.Match("BTCUSDT", PriceBar(0, 98))
+	require.Len(t, fills, 2)
+	assert.Equal(t, first.ID, fills[0].OrderID)
+	assert.Equal(t, second.ID, fills[1].OrderID)
+	assert.Empty(t, book.Orders("BTCUSDT"))
+
+	assert.True(t, book.Cancel(other.ID))
+	assert.False(t, book.Cancel(other.ID))
+	assert.Empty(t, book.Orders(""))
+}
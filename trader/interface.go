@@ -8,15 +8,27 @@ import (
 
 // Re-export types for backward compatibility
 type (
-	ClosedPnLRecord   = types.ClosedPnLRecord
-	TradeRecord       = types.TradeRecord
-	Trader            = types.Trader
-	OpenOrder         = types.OpenOrder
-	LimitOrderRequest = types.LimitOrderRequest
-	LimitOrderResult  = types.LimitOrderResult
-	GridTrader        = types.GridTrader
+	ClosedPnLRecord         = types.ClosedPnLRecord
+	TradeRecord             = types.TradeRecord
+	Trader                  = types.Trader
+	OpenOrder               = types.OpenOrder
+	LimitOrderRequest       = types.LimitOrderRequest
+	LimitOrderResult        = types.LimitOrderResult
+	GridTrader              = types.GridTrader
+	TrailingStopper         = types.TrailingStopper
+	PartialTakeProfitTrader = types.PartialTakeProfitTrader
+	TraderV2                = types.TraderV2
+	TypedQueries            = types.TypedQueries
+	Position                = types.Position
+	AccountBalance          = types.AccountBalance
+	OrderResult             = types.OrderResult
 )
 
+// AsTraderV2 returns the typed interface of a Trader (see types.AsTraderV2)
+func AsTraderV2(t Trader) TraderV2 {
+	return types.AsTraderV2(t)
+}
+
 // GridTraderAdapter wraps a basic Trader to provide GridTrader interface
 // Uses stop orders as a fallback when limit orders aren't directly available
 type GridTraderAdapter struct {
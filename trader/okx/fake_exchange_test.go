@@ -0,0 +1,202 @@
+package okx
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"nofx/trader/testutil"
+	"nofx/trader/testutil/fakeexchange"
+	"nofx/trader/types"
+)
+
+// newFakeExchangeTrader creates a hedge mode trader against an in-memory exchange
+func newFakeExchangeTrader(t *testing.T) (*OKXTrader, *fakeexchange.Exchange) {
+	ex := fakeexchange.New()
+	if err := ex.SetHedgeMode(true); err != nil {
+		t.Fatal(err)
+	}
+	server := fakeexchange.NewOKXServer(ex)
+	t.Cleanup(server.Close)
+
+	trader := &OKXTrader{
+		apiKey:           "test_api_key",
+		secretKey:        "test_secret_key",
+		passphrase:       "test_passphrase",
+		positionMode:     "long_short_mode",
+		httpClient:       server.Client(),
+		instrumentsCache: make(map[string]*OKXInstrument),
+		restURL:          server.URL,
+	}
+	return trader, ex
+}
+
+// TestOKXTrader_FakeExchange runs the common suite against the stateful fake exchange
+func TestOKXTrader_FakeExchange(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	suite := testutil.NewTraderTestSuite(t, trader)
+	defer suite.Cleanup()
+	suite.BeforeEach = ex.SuiteSetup()
+	suite.SeedAccount = ex.SuiteSeed()
+	suite.Skip = map[string]string{
+		"CloseLong":  "closing without a position returns status NO_POSITION instead of an error",
+		"CloseShort": "closing without a position returns status NO_POSITION instead of an error",
+	}
+
+	suite.RunAllTests()
+}
+
+func TestOKXTrader_FakeExchangeClose(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.5, 50000)
+
+	result, err := trader.CloseLong("BTCUSDT", 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["symbol"] != "BTCUSDT" {
+		t.Errorf("unexpected close result: %v", result)
+	}
+	if pos := ex.Position("BTCUSDT", fakeexchange.SideLong); math.Abs(pos.Amount-0.4) > 1e-9 {
+		t.Errorf("got %v BTC long, want 0.4 left", pos.Amount)
+	}
+
+	result, err = trader.CloseShort("BTCUSDT", 0)
+	if err != nil || result["status"] != "NO_POSITION" {
+		t.Errorf("closing a flat short: got %v, %v, want status NO_POSITION", result, err)
+	}
+}
+
+func TestOKXTrader_FakeExchangeFaults(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+
+	t.Run("rate limit", func(t *testing.T) {
+		ex.Inject(fakeexchange.FaultRateLimit, "/api/v5/account/balance", 1)
+		if _, err := trader.GetBalance(); err == nil || !strings.Contains(err.Error(), "50011") {
+			t.Fatalf("got %v, want rate limit error 50011", err)
+		}
+		if _, err := trader.GetBalance(); err != nil {
+			t.Fatalf("retry after rate limit: %v", err)
+		}
+	})
+
+	t.Run("server error leaves the position open", func(t *testing.T) {
+		ex.Reset()
+		ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 50000)
+		ex.Inject(fakeexchange.FaultServerError, okxOrderPath, 1)
+
+		if _, err := trader.CloseLong("BTCUSDT", 0.1); err == nil {
+			t.Fatal("expected an error from the failed close")
+		}
+		if pos := ex.Position("BTCUSDT", fakeexchange.SideLong); pos.Amount != 0.1 {
+			t.Fatalf("position changed by a failed request: %+v", pos)
+		}
+		if _, err := trader.CloseLong("BTCUSDT", 0.1); err != nil {
+			t.Fatal(err)
+		}
+		if positions := ex.Positions(); len(positions) != 0 {
+			t.Errorf("position not closed: %+v", positions)
+		}
+	})
+
+	t.Run("partial fill", func(t *testing.T) {
+		ex.Reset()
+		ex.SetPartialFill(0.5)
+
+		result, err := trader.PlaceLimitOrder(&types.LimitOrderRequest{Symbol: "BTCUSDT", Side: "BUY", Price: 50100, Quantity: 0.02})
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, err := trader.GetOrderStatus("BTCUSDT", result.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status["status"] != "PARTIALLY_FILLED" || math.Abs(status["executedQty"].(float64)-0.01) > 1e-9 {
+			t.Errorf("expected half of 2 contracts filled: %v", status)
+		}
+
+		ex.SetPrice("BTCUSDT", 50000)
+		status, err = trader.GetOrderStatus("BTCUSDT", result.OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status["status"] != "FILLED" || math.Abs(status["executedQty"].(float64)-0.02) > 1e-9 {
+			t.Errorf("expected the remainder to fill: %v", status)
+		}
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		ex.Reset()
+		ex.SetClockSkew(-time.Minute)
+
+		if _, err := trader.GetBalance(); err == nil || !strings.Contains(err.Error(), "50102") {
+			t.Fatalf("got %v, want timestamp error 50102", err)
+		}
+		ex.SetClockSkew(0)
+		if _, err := trader.GetBalance(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestOKXTrader_FakeExchangeTrailingStop(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	ex.SetPosition("BTCUSDT", fakeexchange.SideShort, 0.1, 50000)
+
+	if err := trader.SetTrailingStop("BTCUSDT", "SHORT", 0.1, 1.5, 49000); err != nil {
+		t.Fatal(err)
+	}
+	if err := trader.SetStopLoss("BTCUSDT", "SHORT", 0.1, 52000); err != nil {
+		t.Fatal(err)
+	}
+
+	// Canceling stop-losses keeps the trailing stop
+	if err := trader.CancelStopLossOrders("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	orders := ex.Orders(func(o fakeexchange.Order) bool { return o.IsOpen() })
+	if len(orders) != 1 || orders[0].Type != fakeexchange.OrderTypeTrailingStop || math.Abs(orders[0].CallbackRate-1.5) > 1e-9 ||
+		orders[0].ActivationPrice != 49000 || math.Abs(orders[0].Quantity-0.1) > 1e-9 {
+		t.Fatalf("unexpected open orders: %+v", orders)
+	}
+
+	// Activates at 48000 and triggers 1.5% above it
+	ex.SetPrice("BTCUSDT", 48000)
+	ex.SetPrice("BTCUSDT", 48800)
+	if positions := ex.Positions(); len(positions) != 0 {
+		t.Errorf("short not closed by the trailing stop: %+v", positions)
+	}
+
+	ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 48800)
+	if err := trader.SetTrailingStop("BTCUSDT", "LONG", 0.1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := trader.CancelTrailingStopOrders("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if orders := ex.Orders(func(o fakeexchange.Order) bool { return o.IsOpen() }); len(orders) != 0 {
+		t.Errorf("trailing stop not canceled: %+v", orders)
+	}
+}
+
+func TestOKXTrader_FakeExchangePartialTakeProfit(t *testing.T) {
+	trader, ex := newFakeExchangeTrader(t)
+	ex.SetPosition("BTCUSDT", fakeexchange.SideLong, 0.1, 50000)
+
+	if err := trader.SetPartialTakeProfit("BTCUSDT", "LONG", 0.03, 51000); err != nil {
+		t.Fatal(err)
+	}
+	if err := trader.SetTakeProfit("BTCUSDT", "LONG", 0.07, 52000); err != nil {
+		t.Fatal(err)
+	}
+
+	ex.SetPrice("BTCUSDT", 51000)
+	if amount := ex.Position("BTCUSDT", fakeexchange.SideLong).Amount; math.Abs(amount-0.07) > 1e-9 {
+		t.Errorf("first level should close 0.03, position is %v", amount)
+	}
+	ex.SetPrice("BTCUSDT", 52000)
+	if positions := ex.Positions(); len(positions) != 0 {
+		t.Errorf("final take-profit should close the rest: %+v", positions)
+	}
+}
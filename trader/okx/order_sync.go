@@ -6,6 +6,7 @@ import (
 	"nofx/logger"
 	"nofx/market"
 	"nofx/store"
+	"nofx/trader/types"
 	"sort"
 	"strconv"
 	"strings"
@@ -90,37 +91,6 @@ func (t *OKXTrader) GetTrades(startTime time.Time, limit int) ([]OKXTrade, error
 			fillQtyBase = fillSz * inst.CtVal
 		}
 
-		// Determine order action based on side and posSide
-		// OKX uses dual position mode:
-		// - buy + long = open long
-		// - sell + long = close long
-		// - sell + short = open short
-		// - buy + short = close short
-		orderAction := "open_long"
-		posSide := strings.ToLower(fill.PosSide)
-		side := strings.ToLower(fill.Side)
-
-		if posSide == "long" {
-			if side == "buy" {
-				orderAction = "open_long"
-			} else {
-				orderAction = "close_long"
-			}
-		} else if posSide == "short" {
-			if side == "sell" {
-				orderAction = "open_short"
-			} else {
-				orderAction = "close_short"
-			}
-		} else {
-			// One-way mode (net position)
-			if side == "buy" {
-				orderAction = "open_long"
-			} else {
-				orderAction = "open_short"
-			}
-		}
-
 		trade := OKXTrade{
 			InstID:      fill.InstID,
 			Symbol:      symbol,
@@ -136,7 +106,7 @@ func (t *OKXTrader) GetTrades(startTime time.Time, limit int) ([]OKXTrade, error
 			ExecTime:    time.UnixMilli(ts).UTC(),
 			IsMaker:     fill.ExecType == "M",
 			OrderType:   "MARKET",
-			OrderAction: orderAction,
+			OrderAction: okxOrderAction(fill.PosSide, fill.Side),
 		}
 
 		trades = append(trades, trade)
@@ -145,6 +115,34 @@ func (t *OKXTrader) GetTrades(startTime time.Time, limit int) ([]OKXTrade, error
 	return trades, nil
 }
 
+// okxOrderAction determines the order action of a fill from its position side and side.
+// OKX uses dual position mode:
+// - buy + long = open long
+// - sell + long = close long
+// - sell + short = open short
+// - buy + short = close short
+func okxOrderAction(posSide, side string) string {
+	buy := strings.ToLower(side) == "buy"
+	switch strings.ToLower(posSide) {
+	case "long":
+		if buy {
+			return "open_long"
+		}
+		return "close_long"
+	case "short":
+		if buy {
+			return "close_short"
+		}
+		return "open_short"
+	default:
+		// One-way mode (net position)
+		if buy {
+			return "open_long"
+		}
+		return "open_short"
+	}
+}
+
 // SyncOrdersFromOKX syncs OKX exchange order history to local database
 // Also creates/updates position records to ensure orders/fills/positions data consistency
 // exchangeID: Exchange account UUID (from exchanges.id)
@@ -185,89 +183,133 @@ func (t *OKXTrader) SyncOrdersFromOKX(traderID string, exchangeID string, exchan
 			continue // Order already exists, skip
 		}
 
-		// Normalize symbol
-		symbol := market.Normalize(trade.Symbol)
-
-		// Determine position side from order action
-		positionSide := "LONG"
-		if strings.Contains(trade.OrderAction, "short") {
-			positionSide = "SHORT"
+		if t.recordTrade(orderStore, posBuilder, traderID, exchangeID, exchangeType, trade) {
+			syncedCount++
 		}
+	}
 
-		// Normalize side for storage
-		side := strings.ToUpper(trade.Side)
-
-		// Create order record - use UTC time in milliseconds to avoid timezone issues
-		execTimeMs := trade.ExecTime.UTC().UnixMilli()
-		orderRecord := &store.TraderOrder{
-			TraderID:        traderID,
-			ExchangeID:      exchangeID,   // UUID
-			ExchangeType:    exchangeType, // Exchange type
-			ExchangeOrderID: trade.TradeID,
-			Symbol:          symbol,
-			Side:            side,
-			PositionSide:    positionSide,
-			Type:            trade.OrderType,
-			OrderAction:     trade.OrderAction,
-			Quantity:        trade.FillQtyBase,
-			Price:           trade.FillPrice,
-			Status:          "FILLED",
-			FilledQuantity:  trade.FillQtyBase,
-			AvgFillPrice:    trade.FillPrice,
-			Commission:      trade.Fee,
-			FilledAt:        execTimeMs,
-			CreatedAt:       execTimeMs,
-			UpdatedAt:       execTimeMs,
-		}
+	logger.Infof("✅ OKX order sync completed: %d new trades synced", syncedCount)
+	return nil
+}
 
-		// Insert order record
-		if err := orderStore.CreateOrder(orderRecord); err != nil {
-			logger.Infof("  ⚠️ Failed to sync trade %s: %v", trade.TradeID, err)
-			continue
-		}
+// recordTrade stores a trade as an order, a fill and a position update; returns false if the
+// order couldn't be stored
+func (t *OKXTrader) recordTrade(orderStore *store.OrderStore, posBuilder *store.PositionBuilder, traderID, exchangeID, exchangeType string, trade OKXTrade) bool {
+	// Normalize symbol
+	symbol := market.Normalize(trade.Symbol)
 
-		// Create fill record - use UTC time in milliseconds
-		fillRecord := &store.TraderFill{
-			TraderID:        traderID,
-			ExchangeID:      exchangeID,   // UUID
-			ExchangeType:    exchangeType, // Exchange type
-			OrderID:         orderRecord.ID,
-			ExchangeOrderID: trade.OrderID,
-			ExchangeTradeID: trade.TradeID,
-			Symbol:          symbol,
-			Side:            side,
-			Price:           trade.FillPrice,
-			Quantity:        trade.FillQtyBase,
-			QuoteQuantity:   trade.FillPrice * trade.FillQtyBase,
-			Commission:      trade.Fee,
-			CommissionAsset: trade.FeeAsset,
-			RealizedPnL:     0, // OKX fills don't include PnL per trade
-			IsMaker:         trade.IsMaker,
-			CreatedAt:       execTimeMs,
-		}
+	// Determine position side from order action
+	positionSide := "LONG"
+	if strings.Contains(trade.OrderAction, "short") {
+		positionSide = "SHORT"
+	}
 
-		if err := orderStore.CreateFill(fillRecord); err != nil {
-			logger.Infof("  ⚠️ Failed to sync fill for trade %s: %v", trade.TradeID, err)
-		}
+	// Normalize side for storage
+	side := strings.ToUpper(trade.Side)
+
+	// Create order record - use UTC time in milliseconds to avoid timezone issues
+	execTimeMs := trade.ExecTime.UTC().UnixMilli()
+	orderRecord := &store.TraderOrder{
+		TraderID:        traderID,
+		ExchangeID:      exchangeID,   // UUID
+		ExchangeType:    exchangeType, // Exchange type
+		ExchangeOrderID: trade.TradeID,
+		Symbol:          symbol,
+		Side:            side,
+		PositionSide:    positionSide,
+		Type:            trade.OrderType,
+		OrderAction:     trade.OrderAction,
+		Quantity:        trade.FillQtyBase,
+		Price:           trade.FillPrice,
+		Status:          "FILLED",
+		FilledQuantity:  trade.FillQtyBase,
+		AvgFillPrice:    trade.FillPrice,
+		Commission:      trade.Fee,
+		FilledAt:        execTimeMs,
+		CreatedAt:       execTimeMs,
+		UpdatedAt:       execTimeMs,
+	}
 
-		// Create/update position record using PositionBuilder
-		if err := posBuilder.ProcessTrade(
-			traderID, exchangeID, exchangeType,
-			symbol, positionSide, trade.OrderAction,
-			trade.FillQtyBase, trade.FillPrice, trade.Fee, 0, // No per-trade PnL from OKX
-			execTimeMs, trade.TradeID,
-		); err != nil {
-			logger.Infof("  ⚠️ Failed to sync position for trade %s: %v", trade.TradeID, err)
-		} else {
-			logger.Infof("  📍 Position updated for trade: %s (action: %s, qty: %.6f)", trade.TradeID, trade.OrderAction, trade.FillQtyBase)
-		}
+	// Insert order record
+	if err := orderStore.CreateOrder(orderRecord); err != nil {
+		logger.Infof("  ⚠️ Failed to sync trade %s: %v", trade.TradeID, err)
+		return false
+	}
 
-		syncedCount++
-		logger.Infof("  ✅ Synced trade: %s %s %s qty=%.6f price=%.6f fee=%.6f action=%s",
-			trade.TradeID, trade.Symbol, side, trade.FillQtyBase, trade.FillPrice, trade.Fee, trade.OrderAction)
+	// Create fill record - use UTC time in milliseconds
+	fillRecord := &store.TraderFill{
+		TraderID:        traderID,
+		ExchangeID:      exchangeID,   // UUID
+		ExchangeType:    exchangeType, // Exchange type
+		OrderID:         orderRecord.ID,
+		ExchangeOrderID: trade.OrderID,
+		ExchangeTradeID: trade.TradeID,
+		Symbol:          symbol,
+		Side:            side,
+		Price:           trade.FillPrice,
+		Quantity:        trade.FillQtyBase,
+		QuoteQuantity:   trade.FillPrice * trade.FillQtyBase,
+		Commission:      trade.Fee,
+		CommissionAsset: trade.FeeAsset,
+		RealizedPnL:     0, // OKX fills don't include PnL per trade
+		IsMaker:         trade.IsMaker,
+		CreatedAt:       execTimeMs,
 	}
 
-	logger.Infof("✅ OKX order sync completed: %d new trades synced", syncedCount)
+	if err := orderStore.CreateFill(fillRecord); err != nil {
+		logger.Infof("  ⚠️ Failed to sync fill for trade %s: %v", trade.TradeID, err)
+	}
+
+	// Create/update position record using PositionBuilder
+	if err := posBuilder.ProcessTrade(
+		traderID, exchangeID, exchangeType,
+		symbol, positionSide, trade.OrderAction,
+		trade.FillQtyBase, trade.FillPrice, trade.Fee, 0, // No per-trade PnL from OKX
+		execTimeMs, trade.TradeID,
+	); err != nil {
+		logger.Infof("  ⚠️ Failed to sync position for trade %s: %v", trade.TradeID, err)
+	} else {
+		logger.Infof("  📍 Position updated for trade: %s (action: %s, qty: %.6f)", trade.TradeID, trade.OrderAction, trade.FillQtyBase)
+	}
+
+	logger.Infof("  ✅ Synced trade: %s %s %s qty=%.6f price=%.6f fee=%.6f action=%s",
+		trade.TradeID, trade.Symbol, side, trade.FillQtyBase, trade.FillPrice, trade.Fee, trade.OrderAction)
+	return true
+}
+
+// RecordFill stores a fill of the user data stream like SyncOrdersFromOKX stores a trade, so it
+// doesn't wait for the next sync; fills already stored are skipped
+func (t *OKXTrader) RecordFill(traderID, exchangeID, exchangeType string, st *store.Store, fill types.UserDataEvent) error {
+	if st == nil {
+		return fmt.Errorf("store is nil")
+	}
+	orderStore := st.Order()
+	if existing, err := orderStore.GetOrderByExchangeID(exchangeID, fill.TradeID); err == nil && existing != nil {
+		return nil
+	}
+	// The stream reports the position side upper-cased, with BOTH for net mode
+	posSide := strings.ToLower(fill.PositionSide)
+	if posSide == "both" {
+		posSide = "net"
+	}
+	trade := OKXTrade{
+		InstID:      t.convertSymbol(fill.Symbol),
+		Symbol:      fill.Symbol,
+		TradeID:     fill.TradeID,
+		OrderID:     fill.OrderID,
+		Side:        fill.Side,
+		PosSide:     posSide,
+		FillPrice:   fill.Price,
+		FillQtyBase: fill.Quantity,
+		Fee:         fill.Fee,
+		FeeAsset:    "USDT",
+		ExecTime:    time.UnixMilli(fill.Time).UTC(),
+		OrderType:   "MARKET",
+		OrderAction: okxOrderAction(posSide, fill.Side),
+	}
+	if !t.recordTrade(orderStore, store.NewPositionBuilder(st.Position()), traderID, exchangeID, exchangeType, trade) {
+		return fmt.Errorf("failed to record fill %s", fill.TradeID)
+	}
 	return nil
 }
 
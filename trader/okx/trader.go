@@ -69,6 +69,12 @@ type OKXTrader struct {
 
 	// Cache duration
 	cacheDuration time.Duration
+
+	// Private WebSocket URL, empty for production
+	userStreamURL string
+
+	// REST base URL, empty for production
+	restURL string
 }
 
 // OKXInstrument OKX instrument info
@@ -205,7 +211,11 @@ func (t *OKXTrader) doRequest(method, path string, body interface{}) ([]byte, er
 	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
 	signature := t.sign(timestamp, method, path, string(bodyBytes))
 
-	req, err := http.NewRequest(method, okxBaseURL+path, bytes.NewReader(bodyBytes))
+	baseURL := okxBaseURL
+	if t.restURL != "" {
+		baseURL = t.restURL
+	}
+	req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(bodyBytes))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -1079,12 +1089,82 @@ func (t *OKXTrader) CancelTakeProfitOrders(symbol string) error {
 	return t.cancelAlgoOrders(symbol, "tp")
 }
 
+var _ types.PartialTakeProfitTrader = (*OKXTrader)(nil)
+
+// SetPartialTakeProfit places a take-profit for part of the position.
+// OKX conditional take-profit algo orders close sz contracts, so SetTakeProfit already closes only quantity
+func (t *OKXTrader) SetPartialTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.SetTakeProfit(symbol, positionSide, quantity, takeProfitPrice)
+}
+
+var _ types.TrailingStopper = (*OKXTrader)(nil)
+
+// SetTrailingStop places a move_order_stop algo order closing quantity of the position
+func (t *OKXTrader) SetTrailingStop(symbol string, positionSide string, quantity, callbackPct, activationPrice float64) error {
+	if callbackPct <= 0 {
+		return fmt.Errorf("invalid trailing stop callback: %.2f%%", callbackPct)
+	}
+
+	instId := t.convertSymbol(symbol)
+
+	// Get instrument info
+	inst, err := t.getInstrument(symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get instrument info: %w", err)
+	}
+
+	// Calculate contract size: quantity (in base asset) / ctVal (asset per contract)
+	sz := quantity / inst.CtVal
+	szStr := t.formatSize(sz, inst)
+
+	// Determine direction
+	side := "sell"
+	posSide := "long"
+	if strings.ToUpper(positionSide) == "SHORT" {
+		side = "buy"
+		posSide = "short"
+	}
+
+	body := map[string]interface{}{
+		"instId":        instId,
+		"tdMode":        "cross",
+		"side":          side,
+		"posSide":       posSide,
+		"ordType":       "move_order_stop",
+		"sz":            szStr,
+		"callbackRatio": strconv.FormatFloat(callbackPct/100, 'f', -1, 64), // 0.01 = 1%
+		"tag":           okxTag,
+	}
+	if activationPrice > 0 {
+		body["activePx"] = fmt.Sprintf("%.8f", activationPrice)
+	}
+
+	_, err = t.doRequest("POST", okxAlgoOrderPath, body)
+	if err != nil {
+		return fmt.Errorf("failed to set trailing stop: %w", err)
+	}
+
+	logger.Infof("  Trailing stop set: callback %.2f%%, activation %.4f", callbackPct, activationPrice)
+	return nil
+}
+
+// CancelTrailingStopOrders cancels trailing stop (move_order_stop) orders
+func (t *OKXTrader) CancelTrailingStopOrders(symbol string) error {
+	return t.cancelAlgoOrders(symbol, "trailing")
+}
+
 // cancelAlgoOrders cancels algo orders
+// orderType "trailing" cancels move_order_stop orders, others the conditional (TP/SL) orders
 func (t *OKXTrader) cancelAlgoOrders(symbol string, orderType string) error {
 	instId := t.convertSymbol(symbol)
 
+	ordType := "conditional"
+	if orderType == "trailing" {
+		ordType = "move_order_stop"
+	}
+
 	// Get pending algo orders
-	path := fmt.Sprintf("%s?instType=SWAP&instId=%s&ordType=conditional", okxAlgoPendingPath, instId)
+	path := fmt.Sprintf("%s?instType=SWAP&instId=%s&ordType=%s", okxAlgoPendingPath, instId, ordType)
 	data, err := t.doRequest("GET", path, nil)
 	if err != nil {
 		return err
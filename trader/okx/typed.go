@@ -0,0 +1,168 @@
+package okx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"nofx/trader/types"
+)
+
+// GetBalanceV2 gets the USDT balance (uncached), failing on fields that don't parse
+func (t *OKXTrader) GetBalanceV2() (*types.AccountBalance, error) {
+	data, err := t.doRequest("GET", okxAccountPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account balance: %w", err)
+	}
+
+	var balances []struct {
+		Details []struct {
+			Ccy      string `json:"ccy"`
+			Eq       string `json:"eq"`
+			CashBal  string `json:"cashBal"`
+			AvailBal string `json:"availBal"`
+			UPL      string `json:"upl"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(data, &balances); err != nil {
+		return nil, fmt.Errorf("failed to parse balance data: %w", err)
+	}
+	if len(balances) == 0 {
+		return nil, fmt.Errorf("no balance data received")
+	}
+
+	for _, detail := range balances[0].Details {
+		if detail.Ccy != "USDT" {
+			continue
+		}
+		var p types.NumberParser
+		balance := &types.AccountBalance{
+			TotalWalletBalance:    p.Float("cashBal", detail.CashBal),
+			AvailableBalance:      p.Float("availBal", detail.AvailBal),
+			TotalUnrealizedProfit: p.Float("upl", detail.UPL),
+			TotalEquity:           p.Float("eq", detail.Eq),
+		}
+		if p.Err != nil {
+			return nil, fmt.Errorf("invalid USDT balance: %w", p.Err)
+		}
+		if balance.TotalEquity <= 0 {
+			balance.TotalEquity = balance.TotalWalletBalance + balance.TotalUnrealizedProfit
+		}
+		return balance, nil
+	}
+	// No USDT in the account yet
+	return &types.AccountBalance{}, nil
+}
+
+// GetPositionsV2 gets all open swap positions (uncached), with quantities converted from contracts
+// to base asset; fails on positions that don't parse or whose contract size is unknown
+func (t *OKXTrader) GetPositionsV2() ([]types.Position, error) {
+	data, err := t.doRequest("GET", okxPositionPath+"?instType=SWAP", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+
+	var raw []struct {
+		InstId  string `json:"instId"`
+		PosSide string `json:"posSide"`
+		Pos     string `json:"pos"`
+		AvgPx   string `json:"avgPx"`
+		MarkPx  string `json:"markPx"`
+		Upl     string `json:"upl"`
+		Lever   string `json:"lever"`
+		LiqPx   string `json:"liqPx"`
+		CTime   string `json:"cTime"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse position data: %w", err)
+	}
+
+	positions := make([]types.Position, 0, len(raw))
+	for _, r := range raw {
+		symbol := t.convertSymbolBack(r.InstId)
+		var p types.NumberParser
+		contracts := p.Float("pos", r.Pos)
+		pos := types.Position{
+			Symbol:           symbol,
+			EntryPrice:       p.Float("avgPx", r.AvgPx),
+			MarkPrice:        p.Float("markPx", r.MarkPx),
+			UnrealizedPnL:    p.Float("upl", r.Upl),
+			LiquidationPrice: p.Float("liqPx", r.LiqPx),
+			Leverage:         int(p.Float("lever", r.Lever)),
+			CreatedTime:      p.Int("cTime", r.CTime),
+		}
+		if p.Err != nil {
+			return nil, fmt.Errorf("invalid %s position: %w", r.InstId, p.Err)
+		}
+		if contracts == 0 {
+			continue
+		}
+		inst, err := t.getInstrument(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s contract size: %w", r.InstId, err)
+		}
+		if inst.CtVal <= 0 {
+			return nil, fmt.Errorf("invalid %s contract size %v", r.InstId, inst.CtVal)
+		}
+		pos.Quantity = math.Abs(contracts) * inst.CtVal
+
+		// Long/short mode reports the side, net mode the sign of the contracts
+		switch r.PosSide {
+		case "long", "short":
+			pos.Side = r.PosSide
+		default:
+			pos.Side = "long"
+			if contracts < 0 {
+				pos.Side = "short"
+			}
+		}
+		positions = append(positions, pos)
+	}
+	return positions, nil
+}
+
+// GetOrderStatusV2 gets the status of an order, with the executed quantity in base asset
+func (t *OKXTrader) GetOrderStatusV2(symbol string, orderID string) (*types.OrderResult, error) {
+	path := fmt.Sprintf("/api/v5/trade/order?instId=%s&ordId=%s", t.convertSymbol(symbol), orderID)
+	data, err := t.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status: %w", err)
+	}
+
+	var orders []struct {
+		OrdId     string `json:"ordId"`
+		State     string `json:"state"`
+		AvgPx     string `json:"avgPx"`
+		AccFillSz string `json:"accFillSz"`
+		Fee       string `json:"fee"`
+	}
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, fmt.Errorf("failed to parse order data: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+	order := orders[0]
+
+	var p types.NumberParser
+	result := &types.OrderResult{
+		OrderID:  order.OrdId,
+		Symbol:   symbol,
+		Status:   okxOrderStatus(order.State),
+		AvgPrice: p.Float("avgPx", order.AvgPx),
+		// OKX reports the fee negative
+		Commission: -p.Float("fee", order.Fee),
+	}
+	filled := p.Float("accFillSz", order.AccFillSz)
+	if p.Err != nil {
+		return nil, fmt.Errorf("invalid order %s: %w", orderID, p.Err)
+	}
+	if filled != 0 {
+		inst, err := t.getInstrument(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s contract size: %w", symbol, err)
+		}
+		result.ExecutedQty = filled * inst.CtVal
+	}
+	return result, nil
+}
@@ -0,0 +1,270 @@
+package okx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nofx/trader/types"
+	"nofx/trader/userstream"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// okxPrivateWsURL OKX V5 private WebSocket
+const okxPrivateWsURL = "wss://ws.okx.com:8443/ws/v5/private"
+
+var _ types.UserDataStreamer = (*OKXTrader)(nil)
+
+// StreamUserData streams the orders, positions and account channels of the private WebSocket
+func (t *OKXTrader) StreamUserData(ctx context.Context, onReconnect func()) (<-chan types.UserDataEvent, error) {
+	return userstream.Start(ctx, userstream.Config{
+		Name: "OKX",
+		URL: func(ctx context.Context) (string, error) {
+			if t.userStreamURL != "" {
+				return t.userStreamURL, nil
+			}
+			return okxPrivateWsURL, nil
+		},
+		OnConnect:   t.loginUserStream,
+		Parse:       t.parseUserData,
+		PingMessage: []byte("ping"),
+	}, onReconnect)
+}
+
+// loginUserStream logs in and subscribes to the private channels
+func (t *OKXTrader) loginUserStream(conn *websocket.Conn) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	login := map[string]interface{}{
+		"op": "login",
+		"args": []map[string]string{{
+			"apiKey":     t.apiKey,
+			"passphrase": t.passphrase,
+			"timestamp":  timestamp,
+			"sign":       t.sign(timestamp, "GET", "/users/self/verify", ""),
+		}},
+	}
+	if err := userstream.Request(conn, login, okxEventReply("login", 1)); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	channels := []map[string]string{
+		{"channel": "orders", "instType": "SWAP"},
+		{"channel": "positions", "instType": "SWAP"},
+		{"channel": "account"},
+	}
+	subscribe := map[string]interface{}{"op": "subscribe", "args": channels}
+	if err := userstream.Request(conn, subscribe, okxEventReply("subscribe", len(channels))); err != nil {
+		return fmt.Errorf("subscription failed: %w", err)
+	}
+	return nil
+}
+
+// okxEventReply recognizes count responses with the given event; an error event fails
+func okxEventReply(event string, count int) func(msg []byte) (bool, error) {
+	return func(msg []byte) (bool, error) {
+		var resp struct {
+			Event string `json:"event"`
+			Code  string `json:"code"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			return false, nil
+		}
+		switch resp.Event {
+		case "error":
+			return false, fmt.Errorf("%s (code %s)", resp.Msg, resp.Code)
+		case event:
+			count--
+			return count <= 0, nil
+		}
+		return false, nil
+	}
+}
+
+// okxUserDataMessage channel push of the private WebSocket
+type okxUserDataMessage struct {
+	Arg struct {
+		Channel string `json:"channel"`
+	} `json:"arg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// parseUserData converts a private WebSocket message into events. Sizes are converted from
+// contracts to base asset quantities.
+func (t *OKXTrader) parseUserData(msg []byte) ([]types.UserDataEvent, error) {
+	if string(msg) == "pong" {
+		return nil, nil
+	}
+	var m okxUserDataMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return nil, err
+	}
+	if len(m.Data) == 0 {
+		return nil, nil // Operation response
+	}
+
+	var events []types.UserDataEvent
+	switch m.Arg.Channel {
+	case "orders":
+		var orders []struct {
+			InstId    string `json:"instId"`
+			OrdId     string `json:"ordId"`
+			ClOrdId   string `json:"clOrdId"`
+			Side      string `json:"side"`
+			PosSide   string `json:"posSide"`
+			State     string `json:"state"`
+			Px        string `json:"px"`
+			Sz        string `json:"sz"`
+			AvgPx     string `json:"avgPx"`
+			AccFillSz string `json:"accFillSz"`
+			FillSz    string `json:"fillSz"`
+			FillPx    string `json:"fillPx"`
+			TradeId   string `json:"tradeId"`
+			FillFee   string `json:"fillFee"`
+			FillPnl   string `json:"fillPnl"`
+			UTime     string `json:"uTime"`
+		}
+		if err := json.Unmarshal(m.Data, &orders); err != nil {
+			return nil, fmt.Errorf("failed to parse orders: %w", err)
+		}
+		for _, o := range orders {
+			symbol := t.convertSymbolBack(o.InstId)
+			ctVal := t.contractValue(symbol)
+			uTime, _ := strconv.ParseInt(o.UTime, 10, 64)
+			positionSide := strings.ToUpper(o.PosSide)
+			if positionSide == "NET" {
+				positionSide = "BOTH"
+			}
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataOrder,
+				Symbol:        symbol,
+				Time:          uTime,
+				OrderID:       o.OrdId,
+				ClientOrderID: o.ClOrdId,
+				Side:          strings.ToUpper(o.Side),
+				PositionSide:  positionSide,
+				Status:        okxOrderStatus(o.State),
+				Price:         parseFloat(o.Px),
+				Quantity:      parseFloat(o.Sz) * ctVal,
+				AvgPrice:      parseFloat(o.AvgPx),
+				ExecutedQty:   parseFloat(o.AccFillSz) * ctVal,
+			})
+			if fillSz := parseFloat(o.FillSz); fillSz > 0 && o.TradeId != "" {
+				events = append(events, types.UserDataEvent{
+					Type:          types.UserDataFill,
+					Symbol:        symbol,
+					Time:          uTime,
+					OrderID:       o.OrdId,
+					ClientOrderID: o.ClOrdId,
+					TradeID:       o.TradeId,
+					Side:          strings.ToUpper(o.Side),
+					PositionSide:  positionSide,
+					Price:         parseFloat(o.FillPx),
+					Quantity:      fillSz * ctVal,
+					Fee:           -parseFloat(o.FillFee), // OKX reports fees paid as negative
+					RealizedPnL:   parseFloat(o.FillPnl),
+				})
+			}
+		}
+
+	case "positions":
+		t.InvalidatePositionCache()
+		var positions []struct {
+			InstId  string `json:"instId"`
+			PosSide string `json:"posSide"`
+			Pos     string `json:"pos"`
+			AvgPx   string `json:"avgPx"`
+			Upl     string `json:"upl"`
+			UTime   string `json:"uTime"`
+		}
+		if err := json.Unmarshal(m.Data, &positions); err != nil {
+			return nil, fmt.Errorf("failed to parse positions: %w", err)
+		}
+		for _, p := range positions {
+			symbol := t.convertSymbolBack(p.InstId)
+			contracts := parseFloat(p.Pos)
+			side := "LONG"
+			if p.PosSide == "short" || (p.PosSide == "net" && contracts < 0) {
+				side = "SHORT"
+			}
+			if contracts < 0 {
+				contracts = -contracts
+			}
+			uTime, _ := strconv.ParseInt(p.UTime, 10, 64)
+			events = append(events, types.UserDataEvent{
+				Type:          types.UserDataPosition,
+				Symbol:        symbol,
+				Time:          uTime,
+				PositionSide:  side,
+				PositionAmt:   contracts * t.contractValue(symbol),
+				EntryPrice:    parseFloat(p.AvgPx),
+				UnrealizedPnL: parseFloat(p.Upl),
+			})
+		}
+
+	case "account":
+		t.invalidateBalanceCache()
+		var accounts []struct {
+			UTime   string `json:"uTime"`
+			Details []struct {
+				Ccy      string `json:"ccy"`
+				CashBal  string `json:"cashBal"`
+				AvailBal string `json:"availBal"`
+			} `json:"details"`
+		}
+		if err := json.Unmarshal(m.Data, &accounts); err != nil {
+			return nil, fmt.Errorf("failed to parse account: %w", err)
+		}
+		for _, a := range accounts {
+			uTime, _ := strconv.ParseInt(a.UTime, 10, 64)
+			for _, d := range a.Details {
+				events = append(events, types.UserDataEvent{
+					Type:             types.UserDataBalance,
+					Time:             uTime,
+					Asset:            d.Ccy,
+					WalletBalance:    parseFloat(d.CashBal),
+					AvailableBalance: parseFloat(d.AvailBal),
+				})
+			}
+		}
+	}
+	return events, nil
+}
+
+// contractValue base asset quantity of one contract, 1 when the instrument is unknown
+func (t *OKXTrader) contractValue(symbol string) float64 {
+	if inst, err := t.getInstrument(symbol); err == nil && inst.CtVal > 0 {
+		return inst.CtVal
+	}
+	return 1
+}
+
+// invalidateBalanceCache clears the balance cache to force fresh data on next call
+func (t *OKXTrader) invalidateBalanceCache() {
+	t.balanceCacheMutex.Lock()
+	t.cachedBalance = nil
+	t.balanceCacheTime = time.Time{}
+	t.balanceCacheMutex.Unlock()
+}
+
+// okxOrderStatus maps OKX order states to types.OrderStatus*
+func okxOrderStatus(state string) string {
+	switch state {
+	case "partially_filled":
+		return types.OrderStatusPartiallyFilled
+	case "filled":
+		return types.OrderStatusFilled
+	case "canceled", "mmp_canceled":
+		return types.OrderStatusCanceled
+	default: // live
+		return types.OrderStatusNew
+	}
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
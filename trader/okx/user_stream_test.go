@@ -0,0 +1,45 @@
+package okx
+
+import (
+	"testing"
+	"time"
+
+	"nofx/trader/types"
+)
+
+func TestParseUserData(t *testing.T) {
+	trader := &OKXTrader{
+		instrumentsCache:     map[string]*OKXInstrument{"BTC-USDT-SWAP": {InstID: "BTC-USDT-SWAP", CtVal: 0.01}},
+		instrumentsCacheTime: time.Now(),
+	}
+
+	events, err := trader.parseUserData([]byte(`{"arg":{"channel":"orders","instType":"SWAP"},"data":[{"instId":"BTC-USDT-SWAP","ordId":"123","clOrdId":"cl1","side":"sell","posSide":"short","state":"filled","px":"","sz":"5","avgPx":"50000","accFillSz":"5","fillSz":"5","fillPx":"50000","tradeId":"t1","fillFee":"-0.125","fillPnl":"0","uTime":"1700000000000"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want order and fill: %+v", len(events), events)
+	}
+	order, fill := events[0], events[1]
+	if order.Symbol != "BTCUSDT" || order.Status != types.OrderStatusFilled || order.Side != "SELL" || order.PositionSide != "SHORT" ||
+		order.Quantity != 0.05 || order.ExecutedQty != 0.05 {
+		t.Errorf("unexpected order event (5 contracts of 0.01 BTC): %+v", order)
+	}
+	if fill.Type != types.UserDataFill || fill.Quantity != 0.05 || fill.Fee != 0.125 {
+		t.Errorf("unexpected fill event: %+v", fill)
+	}
+
+	events, err = trader.parseUserData([]byte(`{"arg":{"channel":"positions","instType":"SWAP"},"data":[{"instId":"BTC-USDT-SWAP","posSide":"net","pos":"-10","avgPx":"51000","upl":"3"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].PositionSide != "SHORT" || events[0].PositionAmt != 0.1 {
+		t.Errorf("unexpected position events: %+v", events)
+	}
+
+	for _, msg := range []string{"pong", `{"event":"subscribe","arg":{"channel":"orders"}}`} {
+		if events, err := trader.parseUserData([]byte(msg)); err != nil || len(events) != 0 {
+			t.Errorf("%s: expected no events, got %+v, %v", msg, events, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package paper
+
+import (
+	"fmt"
+	"time"
+
+	"nofx/logger"
+	"nofx/store"
+	"nofx/trader/types"
+)
+
+// GetTrades returns simulated executions since startTime
+func (t *PaperTrader) GetTrades(startTime time.Time, limit int) ([]types.TradeRecord, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fills, err := t.store.ListFills(t.traderID, startTime, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]types.TradeRecord, 0, len(fills))
+	for _, f := range fills {
+		trades = append(trades, types.TradeRecord{
+			TradeID:      f.TradeID,
+			Symbol:       f.Symbol,
+			Side:         f.Side,
+			PositionSide: f.PositionSide,
+			OrderAction:  f.OrderAction,
+			Price:        f.Price,
+			Quantity:     f.Quantity,
+			RealizedPnL:  f.RealizedPnL,
+			Fee:          f.Fee,
+			Time:         time.UnixMilli(f.CreatedAt).UTC(),
+		})
+	}
+	return trades, nil
+}
+
+// SyncOrdersFromPaper syncs simulated executions into the trader order/fill/position tables
+// This covers fills that happen outside AutoTrader calls: stop-loss, take-profit, liquidation and resting limit orders
+func (t *PaperTrader) SyncOrdersFromPaper(traderID string, exchangeID string, exchangeType string, st *store.Store) error {
+	if st == nil {
+		return fmt.Errorf("store is nil")
+	}
+
+	// Evaluate triggers first so protective orders fire even while the trader is idle
+	t.mu.Lock()
+	if err := t.processTriggers(make(map[string]float64)); err != nil {
+		logger.Warnf("  [Paper] Failed to process pending orders: %v", err)
+	}
+	t.mu.Unlock()
+
+	startTime := time.Now().Add(-24 * time.Hour)
+	trades, err := t.GetTrades(startTime, 1000)
+	if err != nil {
+		return fmt.Errorf("failed to get trades: %w", err)
+	}
+
+	orderStore := st.Order()
+	posBuilder := store.NewPositionBuilder(st.Position())
+	syncedCount := 0
+
+	for _, trade := range trades {
+		existing, err := orderStore.GetOrderByExchangeID(exchangeID, trade.TradeID)
+		if err == nil && existing != nil {
+			continue // Already synced
+		}
+
+		tradeTimeMs := trade.Time.UTC().UnixMilli()
+		orderRecord := &store.TraderOrder{
+			TraderID:        traderID,
+			ExchangeID:      exchangeID,
+			ExchangeType:    exchangeType,
+			ExchangeOrderID: trade.TradeID,
+			Symbol:          trade.Symbol,
+			Side:            trade.Side,
+			PositionSide:    trade.PositionSide,
+			Type:            "MARKET",
+			OrderAction:     trade.OrderAction,
+			Quantity:        trade.Quantity,
+			Price:           trade.Price,
+			Status:          "FILLED",
+			FilledQuantity:  trade.Quantity,
+			AvgFillPrice:    trade.Price,
+			Commission:      trade.Fee,
+			ReduceOnly:      trade.OrderAction == "close_long" || trade.OrderAction == "close_short",
+			FilledAt:        tradeTimeMs,
+			CreatedAt:       tradeTimeMs,
+			UpdatedAt:       tradeTimeMs,
+		}
+		if err := orderStore.CreateOrder(orderRecord); err != nil {
+			logger.Infof("  ⚠️ Failed to sync paper trade %s: %v", trade.TradeID, err)
+			continue
+		}
+
+		fillRecord := &store.TraderFill{
+			TraderID:        traderID,
+			ExchangeID:      exchangeID,
+			ExchangeType:    exchangeType,
+			OrderID:         orderRecord.ID,
+			ExchangeOrderID: trade.TradeID,
+			ExchangeTradeID: trade.TradeID,
+			Symbol:          trade.Symbol,
+			Side:            trade.Side,
+			Price:           trade.Price,
+			Quantity:        trade.Quantity,
+			QuoteQuantity:   trade.Price * trade.Quantity,
+			Commission:      trade.Fee,
+			CommissionAsset: "USDT",
+			RealizedPnL:     trade.RealizedPnL,
+			CreatedAt:       tradeTimeMs,
+		}
+		if err := orderStore.CreateFill(fillRecord); err != nil {
+			logger.Infof("  ⚠️ Failed to sync fill for paper trade %s: %v", trade.TradeID, err)
+		}
+
+		if err := posBuilder.ProcessTrade(
+			traderID, exchangeID, exchangeType,
+			trade.Symbol, trade.PositionSide, trade.OrderAction,
+			trade.Quantity, trade.Price, trade.Fee, trade.RealizedPnL,
+			tradeTimeMs, trade.TradeID,
+		); err != nil {
+			logger.Infof("  ⚠️ Failed to sync position for paper trade %s: %v", trade.TradeID, err)
+		}
+		syncedCount++
+	}
+
+	if syncedCount > 0 {
+		logger.Infof("✅ Paper order sync completed: %d new trades synced", syncedCount)
+	}
+	return nil
+}
+
+// StartOrderSync starts background order sync task
+func (t *PaperTrader) StartOrderSync(traderID string, exchangeID string, exchangeType string, st *store.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := t.SyncOrdersFromPaper(traderID, exchangeID, exchangeType, st); err != nil {
+				logger.Infof("⚠️  Paper order sync failed: %v", err)
+			}
+		}
+	}()
+	logger.Infof("🔄 Paper order sync started (interval: %v)", interval)
+}
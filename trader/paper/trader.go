@@ -0,0 +1,1213 @@
+package paper
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nofx/logger"
+	"nofx/market"
+	"nofx/store"
+	"nofx/trader/fillmodel"
+	"nofx/trader/types"
+)
+
+const (
+	// Default simulated market order slippage
+	defaultSlippageRate = 0.0001
+
+	// Default initial balance when trader has none configured
+	DefaultInitialBalance = 10000.0
+
+	// Synthetic order book spread around last price
+	bookSpreadRate = 0.0001
+
+	epsilon = 1e-9
+
+	// TriggerInterval how often RunTriggers checks liquidations and resting orders
+	TriggerInterval = 5 * time.Second
+)
+
+// Compile-time check that PaperTrader satisfies the grid trading interface
+var _ types.GridTrader = (*PaperTrader)(nil)
+
+// PriceFunc returns the latest price for a symbol
+type PriceFunc func(symbol string) (float64, error)
+
+// PaperTrader implements types.Trader and types.GridTrader against a simulated account.
+// Prices come from public market data; balances, positions, orders and closed PnL are kept in the store,
+// so a paper trader survives restarts and can be driven by AutoTrader without any exchange keys.
+type PaperTrader struct {
+	traderID       string
+	initialBalance float64 // Balance of the account when it's (re)created
+	store          *store.PaperStore
+
+	fills   *fillmodel.Model // Shared with backtests: fees, slippage and SL/TP/limit matching
+	priceFn PriceFunc
+
+	leverage map[string]int // Leverage set via SetLeverage, applied to next open
+
+	orderSeq uint64
+	mu       sync.Mutex
+}
+
+// NewPaperTrader creates a paper trader for traderID, initializing its account with initialBalance on first use
+func NewPaperTrader(st *store.Store, traderID string, initialBalance float64) (*PaperTrader, error) {
+	if st == nil {
+		return nil, fmt.Errorf("paper trading requires a store")
+	}
+	if traderID == "" {
+		return nil, fmt.Errorf("paper trading requires a trader ID")
+	}
+	if initialBalance <= 0 {
+		initialBalance = DefaultInitialBalance
+	}
+
+	if _, err := st.Paper().GetOrCreateAccount(traderID, initialBalance); err != nil {
+		return nil, err
+	}
+
+	apiClient := market.NewAPIClient()
+	return &PaperTrader{
+		traderID:       traderID,
+		initialBalance: initialBalance,
+		store:          st.Paper(),
+		fills: fillmodel.New(fillmodel.Config{
+			Fees:         fillmodel.FeesFor(fillmodel.DefaultExchange),
+			SlippageRate: defaultSlippageRate,
+		}),
+		priceFn:  apiClient.GetCurrentPrice,
+		leverage: make(map[string]int),
+	}, nil
+}
+
+// SetPriceSource overrides the market price source (used by tests and replay)
+func (t *PaperTrader) SetPriceSource(fn PriceFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.priceFn = fn
+}
+
+// SetFillModel replaces the fill simulation settings
+func (t *PaperTrader) SetFillModel(cfg fillmodel.Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fills = fillmodel.New(cfg)
+}
+
+// SetFeeRates overrides maker/taker fee rates (fractions, e.g. 0.0004 = 4 bps)
+func (t *PaperTrader) SetFeeRates(maker, taker float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cfg := t.fills.Config()
+	cfg.Fees = fillmodel.FeeSchedule{Maker: maker, Taker: taker}
+	t.fills = fillmodel.New(cfg)
+}
+
+// SetFeeSchedule uses the fee schedule of an exchange type (binance, bybit, okx, ...)
+func (t *PaperTrader) SetFeeSchedule(exchange string) error {
+	fees, ok := fillmodel.ExchangeFees(exchange)
+	if !ok {
+		return fmt.Errorf("no fee schedule for exchange: %s", exchange)
+	}
+	t.SetFeeRates(fees.Maker, fees.Taker)
+	return nil
+}
+
+// SetSlippageRate overrides market order slippage (fraction of price)
+func (t *PaperTrader) SetSlippageRate(rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cfg := t.fills.Config()
+	cfg.SlippageRate = rate
+	t.fills = fillmodel.New(cfg)
+}
+
+// Reset wipes the simulated account and restores initialBalance
+func (t *PaperTrader) Reset(initialBalance float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if initialBalance <= 0 {
+		initialBalance = DefaultInitialBalance
+	}
+	if err := t.store.ResetAccount(t.traderID, initialBalance); err != nil {
+		return err
+	}
+	t.initialBalance = initialBalance
+	return nil
+}
+
+// ============================================================================
+// Account queries
+// ============================================================================
+
+// GetBalance returns simulated account balance
+func (t *PaperTrader) GetBalance() (map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	balance, marginUsed, fees, err := t.balance()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"totalWalletBalance":    balance.TotalWalletBalance,
+		"availableBalance":      balance.AvailableBalance,
+		"totalUnrealizedProfit": balance.TotalUnrealizedProfit,
+		"totalEquity":           balance.TotalEquity,
+		"totalMarginUsed":       marginUsed,
+		"totalFees":             fees,
+	}, nil
+}
+
+// GetBalanceV2 returns simulated account balance
+func (t *PaperTrader) GetBalanceV2() (*types.AccountBalance, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	balance, _, _, err := t.balance()
+	return balance, err
+}
+
+// balance values the account at current prices, also returning the margin used and total fees.
+// Caller must hold t.mu.
+func (t *PaperTrader) balance() (balance *types.AccountBalance, marginUsed, fees float64, err error) {
+	prices := make(map[string]float64)
+	if err := t.processTriggers(prices); err != nil {
+		logger.Warnf("  [Paper] Failed to process pending orders: %v", err)
+	}
+
+	account, err := t.store.GetOrCreateAccount(t.traderID, t.initialBalance)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	positions, err := t.store.ListPositions(t.traderID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	unrealized := 0.0
+	for _, pos := range positions {
+		price, err := t.cachedPrice(prices, pos.Symbol)
+		if err != nil {
+			price = pos.EntryPrice
+		}
+		marginUsed += pos.Margin
+		unrealized += unrealizedPnL(pos, price)
+	}
+
+	walletBalance := account.Cash + marginUsed
+	totalEquity := walletBalance + unrealized
+	available := account.Cash
+	if unrealized < 0 {
+		available += unrealized
+	}
+	if available < 0 {
+		available = 0
+	}
+
+	balance = &types.AccountBalance{
+		TotalWalletBalance:    walletBalance,
+		AvailableBalance:      available,
+		TotalUnrealizedProfit: unrealized,
+		TotalEquity:           totalEquity,
+	}
+	return balance, marginUsed, account.TotalFees, nil
+}
+
+// GetPositions returns simulated open positions
+func (t *PaperTrader) GetPositions() ([]map[string]interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	positions, err := t.positions()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, 0, len(positions))
+	for _, pos := range positions {
+		positionAmt := pos.Quantity
+		if pos.Side == "short" {
+			positionAmt = -pos.Quantity
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":           pos.Symbol,
+			"side":             pos.Side,
+			"positionAmt":      positionAmt,
+			"entryPrice":       pos.EntryPrice,
+			"markPrice":        pos.MarkPrice,
+			"unRealizedProfit": pos.UnrealizedPnL,
+			"liquidationPrice": pos.LiquidationPrice,
+			"leverage":         float64(pos.Leverage),
+			"createdTime":      pos.CreatedTime,
+		})
+	}
+	return result, nil
+}
+
+// GetPositionsV2 returns simulated open positions
+func (t *PaperTrader) GetPositionsV2() ([]types.Position, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.positions()
+}
+
+// positions returns the open positions marked at current prices. Caller must hold t.mu.
+func (t *PaperTrader) positions() ([]types.Position, error) {
+	prices := make(map[string]float64)
+	if err := t.processTriggers(prices); err != nil {
+		logger.Warnf("  [Paper] Failed to process pending orders: %v", err)
+	}
+
+	positions, err := t.store.ListPositions(t.traderID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Position, 0, len(positions))
+	for _, pos := range positions {
+		markPrice, err := t.cachedPrice(prices, pos.Symbol)
+		if err != nil {
+			markPrice = pos.EntryPrice
+		}
+		result = append(result, types.Position{
+			Symbol:           pos.Symbol,
+			Side:             pos.Side,
+			Quantity:         pos.Quantity,
+			EntryPrice:       pos.EntryPrice,
+			MarkPrice:        markPrice,
+			UnrealizedPnL:    unrealizedPnL(pos, markPrice),
+			LiquidationPrice: pos.LiquidationPrice,
+			Leverage:         pos.Leverage,
+			CreatedTime:      pos.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// GetMarketPrice returns latest market price
+func (t *PaperTrader) GetMarketPrice(symbol string) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.priceFn(market.Normalize(symbol))
+}
+
+// ============================================================================
+// Configuration
+// ============================================================================
+
+// SetLeverage sets leverage applied to subsequent opens
+func (t *PaperTrader) SetLeverage(symbol string, leverage int) error {
+	if leverage <= 0 {
+		return fmt.Errorf("leverage must be positive: %d", leverage)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leverage[market.Normalize(symbol)] = leverage
+	return nil
+}
+
+// SetMarginMode is a no-op: paper positions always use isolated margin accounting
+func (t *PaperTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
+	return nil
+}
+
+// FormatQuantity formats quantity to 6 decimal places (truncated)
+func (t *PaperTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	truncated := math.Floor(quantity*1e6) / 1e6
+	return strconv.FormatFloat(truncated, 'f', -1, 64), nil
+}
+
+// ============================================================================
+// Market orders
+// ============================================================================
+
+// OpenLong opens a long position at market
+func (t *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.openMarket(symbol, "long", quantity, leverage)
+}
+
+// OpenShort opens a short position at market
+func (t *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.openMarket(symbol, "short", quantity, leverage)
+}
+
+// CloseLong closes a long position at market (quantity=0 means close all)
+func (t *PaperTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.closeMarket(symbol, "long", quantity)
+}
+
+// CloseShort closes a short position at market (quantity=0 means close all)
+func (t *PaperTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.closeMarket(symbol, "short", quantity)
+}
+
+func (t *PaperTrader) openMarket(symbol, side string, quantity float64, leverage int) (map[string]interface{}, error) {
+	symbol = market.Normalize(symbol)
+	if quantity <= 0 {
+		return nil, fmt.Errorf("quantity must be positive")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if leverage <= 0 {
+		leverage = t.leverage[symbol]
+	}
+	if leverage <= 0 {
+		leverage = 1
+	}
+	t.leverage[symbol] = leverage
+
+	// Mirror exchange adapters: opening a position clears stale orders for the symbol
+	if _, err := t.store.CancelOrders(t.traderID, symbol); err != nil {
+		logger.Warnf("  [Paper] Failed to cancel old orders: %v", err)
+	}
+
+	price, err := t.priceFn(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market price: %w", err)
+	}
+	execPrice := t.fills.Slip(price, sideToOrderSide(side, true))
+
+	order := t.newOrder(symbol, sideToOrderSide(side, true), strings.ToUpper(side), "MARKET", quantity)
+	order.Leverage = leverage
+	fee, err := t.openPosition(order.OrderID, symbol, side, quantity, leverage, execPrice, false)
+	if err != nil {
+		order.Status = "REJECTED"
+		if createErr := t.store.CreateOrder(order); createErr != nil {
+			logger.Warnf("  [Paper] Failed to record rejected order: %v", createErr)
+		}
+		return nil, err
+	}
+	t.markFilled(order, quantity, execPrice, fee)
+	if err := t.store.CreateOrder(order); err != nil {
+		return nil, err
+	}
+
+	logger.Infof("  [Paper] Opened %s %s: qty=%.6f price=%.6f leverage=%dx fee=%.4f",
+		side, symbol, quantity, execPrice, leverage, fee)
+
+	return map[string]interface{}{
+		"orderId":  order.OrderID,
+		"symbol":   symbol,
+		"status":   "FILLED",
+		"avgPrice": execPrice,
+	}, nil
+}
+
+func (t *PaperTrader) closeMarket(symbol, side string, quantity float64) (map[string]interface{}, error) {
+	symbol = market.Normalize(symbol)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pos, err := t.store.GetPosition(t.traderID, symbol, side)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil {
+		return nil, fmt.Errorf("%s position not found for %s", side, symbol)
+	}
+	if quantity <= 0 || quantity > pos.Quantity {
+		quantity = pos.Quantity
+	}
+
+	price, err := t.priceFn(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get market price: %w", err)
+	}
+	execPrice := t.fills.Slip(price, sideToOrderSide(side, false))
+
+	order := t.newOrder(symbol, sideToOrderSide(side, false), strings.ToUpper(side), "MARKET", quantity)
+	order.ReduceOnly = true
+	order.Leverage = pos.Leverage
+	quantity, fee, err := t.closePosition(pos, order.OrderID, quantity, execPrice, false, "manual")
+	if err != nil {
+		return nil, err
+	}
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%s position not found for %s", side, symbol)
+	}
+	t.markFilled(order, quantity, execPrice, fee)
+	if err := t.store.CreateOrder(order); err != nil {
+		return nil, err
+	}
+
+	// Fully closed: remove orphaned stop orders, like exchanges do for closePosition orders
+	if pos.Quantity <= epsilon {
+		if _, err := t.store.CancelOrders(t.traderID, symbol, "STOP_MARKET", "TAKE_PROFIT_MARKET"); err != nil {
+			logger.Warnf("  [Paper] Failed to cancel stop orders: %v", err)
+		}
+	}
+
+	logger.Infof("  [Paper] Closed %s %s: qty=%.6f price=%.6f fee=%.4f", side, symbol, quantity, execPrice, fee)
+
+	return map[string]interface{}{
+		"orderId":  order.OrderID,
+		"symbol":   symbol,
+		"status":   "FILLED",
+		"avgPrice": execPrice,
+	}, nil
+}
+
+// ============================================================================
+// Stop-loss / take-profit
+// ============================================================================
+
+// SetStopLoss places a simulated stop-market order closing positionSide (LONG/SHORT)
+func (t *PaperTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return t.placeStopOrder(symbol, positionSide, "STOP_MARKET", quantity, stopPrice)
+}
+
+// SetTakeProfit places a simulated take-profit-market order closing positionSide (LONG/SHORT)
+func (t *PaperTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.placeStopOrder(symbol, positionSide, "TAKE_PROFIT_MARKET", quantity, takeProfitPrice)
+}
+
+func (t *PaperTrader) placeStopOrder(symbol, positionSide, orderType string, quantity, stopPrice float64) error {
+	symbol = market.Normalize(symbol)
+	positionSide = strings.ToUpper(positionSide)
+	if positionSide != "LONG" && positionSide != "SHORT" {
+		return fmt.Errorf("invalid position side: %s", positionSide)
+	}
+	if stopPrice <= 0 {
+		return fmt.Errorf("stop price must be positive")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	side := strings.ToLower(positionSide)
+	if quantity <= 0 {
+		// Protect the whole position, like closePosition=true orders on exchanges
+		pos, err := t.store.GetPosition(t.traderID, symbol, side)
+		if err != nil {
+			return err
+		}
+		if pos == nil {
+			return fmt.Errorf("%s position not found for %s", side, symbol)
+		}
+		quantity = pos.Quantity
+	}
+	order := t.newOrder(symbol, sideToOrderSide(side, false), positionSide, orderType, quantity)
+	order.StopPrice = stopPrice
+	order.ReduceOnly = true
+	if err := t.store.CreateOrder(order); err != nil {
+		return err
+	}
+
+	logger.Infof("  [Paper] %s set for %s %s: trigger=%.6f qty=%.6f", orderType, symbol, positionSide, stopPrice, quantity)
+	return nil
+}
+
+// CancelStopLossOrders cancels simulated stop-loss orders
+func (t *PaperTrader) CancelStopLossOrders(symbol string) error {
+	return t.cancelOrders(symbol, "STOP_MARKET")
+}
+
+// CancelTakeProfitOrders cancels simulated take-profit orders
+func (t *PaperTrader) CancelTakeProfitOrders(symbol string) error {
+	return t.cancelOrders(symbol, "TAKE_PROFIT_MARKET")
+}
+
+// CancelStopOrders cancels simulated stop-loss and take-profit orders
+func (t *PaperTrader) CancelStopOrders(symbol string) error {
+	return t.cancelOrders(symbol, "STOP_MARKET", "TAKE_PROFIT_MARKET")
+}
+
+// CancelAllOrders cancels all simulated pending orders for symbol
+func (t *PaperTrader) CancelAllOrders(symbol string) error {
+	return t.cancelOrders(symbol)
+}
+
+func (t *PaperTrader) cancelOrders(symbol string, orderTypes ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.store.CancelOrders(t.traderID, market.Normalize(symbol), orderTypes...)
+	return err
+}
+
+// ============================================================================
+// Order queries
+// ============================================================================
+
+// GetOrderStatus returns simulated order status
+func (t *PaperTrader) GetOrderStatus(symbol string, orderID string) (map[string]interface{}, error) {
+	order, err := t.GetOrderStatusV2(symbol, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"orderId":     order.OrderID,
+		"symbol":      order.Symbol,
+		"status":      order.Status,
+		"avgPrice":    order.AvgPrice,
+		"executedQty": order.ExecutedQty,
+		"commission":  order.Commission,
+	}, nil
+}
+
+// GetOrderStatusV2 returns simulated order status
+func (t *PaperTrader) GetOrderStatusV2(symbol string, orderID string) (*types.OrderResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.processTriggers(make(map[string]float64)); err != nil {
+		logger.Warnf("  [Paper] Failed to process pending orders: %v", err)
+	}
+
+	order, err := t.store.GetOrder(t.traderID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &types.OrderResult{
+		OrderID:     order.OrderID,
+		Symbol:      order.Symbol,
+		Status:      order.Status,
+		AvgPrice:    order.AvgPrice,
+		ExecutedQty: order.ExecutedQty,
+		Commission:  order.Commission,
+	}, nil
+}
+
+// GetOpenOrders returns simulated pending orders
+func (t *PaperTrader) GetOpenOrders(symbol string) ([]types.OpenOrder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.processTriggers(make(map[string]float64)); err != nil {
+		logger.Warnf("  [Paper] Failed to process pending orders: %v", err)
+	}
+
+	if symbol != "" {
+		symbol = market.Normalize(symbol)
+	}
+	orders, err := t.store.ListOpenOrders(t.traderID, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.OpenOrder, 0, len(orders))
+	for _, o := range orders {
+		result = append(result, types.OpenOrder{
+			OrderID:      o.OrderID,
+			Symbol:       o.Symbol,
+			Side:         o.Side,
+			PositionSide: o.PositionSide,
+			Type:         o.Type,
+			Price:        o.Price,
+			StopPrice:    o.StopPrice,
+			Quantity:     o.Quantity,
+			Status:       o.Status,
+		})
+	}
+	return result, nil
+}
+
+// GetClosedPnL returns simulated closed position records
+func (t *PaperTrader) GetClosedPnL(startTime time.Time, limit int) ([]types.ClosedPnLRecord, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	records, err := t.store.ListClosedPnL(t.traderID, startTime, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.ClosedPnLRecord, 0, len(records))
+	for _, r := range records {
+		result = append(result, types.ClosedPnLRecord{
+			Symbol:      r.Symbol,
+			Side:        r.Side,
+			EntryPrice:  r.EntryPrice,
+			ExitPrice:   r.ExitPrice,
+			Quantity:    r.Quantity,
+			RealizedPnL: r.RealizedPnL,
+			Fee:         r.Fee,
+			Leverage:    r.Leverage,
+			EntryTime:   time.UnixMilli(r.EntryTime).UTC(),
+			ExitTime:    time.UnixMilli(r.ExitTime).UTC(),
+			OrderID:     r.OrderID,
+			CloseType:   r.CloseType,
+			ExchangeID:  fmt.Sprintf("paper-%d", r.ID),
+		})
+	}
+	return result, nil
+}
+
+// ============================================================================
+// GridTrader
+// ============================================================================
+
+// PlaceLimitOrder places a simulated resting limit order.
+// Without an explicit PositionSide the order nets against the opposite position first (one-way mode).
+func (t *PaperTrader) PlaceLimitOrder(req *types.LimitOrderRequest) (*types.LimitOrderResult, error) {
+	if req == nil {
+		return nil, fmt.Errorf("limit order request is nil")
+	}
+	symbol := market.Normalize(req.Symbol)
+	side := strings.ToUpper(req.Side)
+	if side != "BUY" && side != "SELL" {
+		return nil, fmt.Errorf("invalid order side: %s", req.Side)
+	}
+	if req.Price <= 0 || req.Quantity <= 0 {
+		return nil, fmt.Errorf("price and quantity must be positive")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if req.Leverage > 0 {
+		t.leverage[symbol] = req.Leverage
+	}
+
+	// Post-only orders that would cross the book are rejected, like on a real exchange
+	if req.PostOnly {
+		if price, err := t.priceFn(symbol); err == nil {
+			probe := &fillmodel.Order{Side: side, Type: fillmodel.TypeLimit, Price: req.Price}
+			if t.fills.WouldCross(probe, price) {
+				return nil, fmt.Errorf("post-only order would immediately match: price %.6f, market %.6f", req.Price, price)
+			}
+		}
+	}
+
+	order := t.newOrder(symbol, side, strings.ToUpper(req.PositionSide), "LIMIT", req.Quantity)
+	order.ClientID = req.ClientID
+	order.Price = req.Price
+	order.Leverage = t.leverage[symbol]
+	order.ReduceOnly = req.ReduceOnly
+	if err := t.store.CreateOrder(order); err != nil {
+		return nil, err
+	}
+
+	// Marketable limit orders fill immediately
+	if err := t.processTriggers(make(map[string]float64)); err != nil {
+		logger.Warnf("  [Paper] Failed to process pending orders: %v", err)
+	}
+	status := order.Status
+	if refreshed, err := t.store.GetOrder(t.traderID, order.OrderID); err == nil {
+		status = refreshed.Status
+	}
+
+	return &types.LimitOrderResult{
+		OrderID:      order.OrderID,
+		ClientID:     req.ClientID,
+		Symbol:       symbol,
+		Side:         side,
+		PositionSide: req.PositionSide,
+		Price:        req.Price,
+		Quantity:     req.Quantity,
+		Status:       status,
+	}, nil
+}
+
+// CancelOrder cancels a single simulated order
+func (t *PaperTrader) CancelOrder(symbol, orderID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	order, err := t.store.GetOrder(t.traderID, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != "NEW" && order.Status != "PARTIALLY_FILLED" {
+		return fmt.Errorf("order %s is not open (status: %s)", orderID, order.Status)
+	}
+	order.Status = "CANCELED"
+	return t.store.UpdateOrder(order)
+}
+
+// GetOrderBook returns a synthetic one-level book around the last price
+func (t *PaperTrader) GetOrderBook(symbol string, depth int) (bids, asks [][]float64, err error) {
+	price, err := t.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, nil, err
+	}
+	bids = [][]float64{{price * (1 - bookSpreadRate), 0}}
+	asks = [][]float64{{price * (1 + bookSpreadRate), 0}}
+	return bids, asks, nil
+}
+
+// ============================================================================
+// Simulation core (caller must hold t.mu)
+// ============================================================================
+
+func (t *PaperTrader) newOrder(symbol, side, positionSide, orderType string, quantity float64) *store.PaperOrder {
+	seq := atomic.AddUint64(&t.orderSeq, 1)
+	return &store.PaperOrder{
+		TraderID:     t.traderID,
+		OrderID:      fmt.Sprintf("paper-%d-%d", time.Now().UnixNano(), seq),
+		Symbol:       symbol,
+		Side:         side,
+		PositionSide: positionSide,
+		Type:         orderType,
+		Quantity:     quantity,
+		Leverage:     1,
+		Status:       "NEW",
+	}
+}
+
+func (t *PaperTrader) markFilled(order *store.PaperOrder, qty, price, fee float64) {
+	order.Status = "FILLED"
+	order.ExecutedQty = qty
+	order.AvgPrice = price
+	order.Commission = fee
+}
+
+func (t *PaperTrader) cachedPrice(prices map[string]float64, symbol string) (float64, error) {
+	if p, ok := prices[symbol]; ok {
+		return p, nil
+	}
+	p, err := t.priceFn(symbol)
+	if err != nil {
+		return 0, err
+	}
+	prices[symbol] = p
+	return p, nil
+}
+
+// openPosition adds quantity to a position, debiting margin and fee from cash
+func (t *PaperTrader) openPosition(orderID, symbol, side string, quantity float64, leverage int, price float64, isMaker bool) (float64, error) {
+	notional := price * quantity
+	margin := notional / float64(leverage)
+	fee := t.fills.Fee(notional, isMaker)
+
+	err := t.store.Transaction(func(tx *store.PaperStore) error {
+		account, err := tx.LockAccount(t.traderID, t.initialBalance)
+		if err != nil {
+			return err
+		}
+		if margin+fee > account.Cash+epsilon {
+			return fmt.Errorf("insufficient paper balance: need %.2f, available %.2f", margin+fee, account.Cash)
+		}
+
+		pos, err := tx.GetPosition(t.traderID, symbol, side)
+		if err != nil {
+			return err
+		}
+		if pos == nil {
+			pos = &store.PaperPosition{
+				TraderID:   t.traderID,
+				Symbol:     symbol,
+				Side:       side,
+				Quantity:   quantity,
+				EntryPrice: price,
+				Leverage:   leverage,
+				Margin:     margin,
+			}
+		} else {
+			totalMargin := pos.Margin + margin
+			pos.EntryPrice = (pos.EntryPrice*pos.Quantity + price*quantity) / (pos.Quantity + quantity)
+			pos.Quantity += quantity
+			pos.Margin = totalMargin
+			pos.Leverage = int(math.Round(pos.EntryPrice * pos.Quantity / totalMargin))
+		}
+		pos.AccumulatedFee += fee
+		pos.LiquidationPrice = computeLiquidation(pos.EntryPrice, pos.Leverage, side)
+
+		account.Cash -= margin + fee
+		account.TotalFees += fee
+
+		if err := tx.SavePosition(pos); err != nil {
+			return err
+		}
+		if err := tx.SaveAccount(account); err != nil {
+			return err
+		}
+		return tx.CreateFill(t.newFill(orderID, symbol, side, "open_"+side, price, quantity, 0, fee, isMaker))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return fee, nil
+}
+
+// closePosition reduces the position of pos at price, crediting margin and realized PnL to cash.
+// The position is reloaded under the account lock and pos updated to it; returns the quantity
+// closed, 0 if the position is already gone.
+func (t *PaperTrader) closePosition(pos *store.PaperPosition, orderID string, quantity, price float64, isMaker bool, closeType string) (closed, fee float64, err error) {
+	err = t.store.Transaction(func(tx *store.PaperStore) error {
+		account, err := tx.LockAccount(t.traderID, t.initialBalance)
+		if err != nil {
+			return err
+		}
+		current, err := tx.GetPosition(t.traderID, pos.Symbol, pos.Side)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			pos.Quantity = 0
+			return nil
+		}
+		*pos = *current
+		if quantity > pos.Quantity {
+			quantity = pos.Quantity
+		}
+
+		closePortion := quantity / pos.Quantity
+		closingFee := t.fills.Fee(price*quantity, isMaker)
+		openingFeePortion := pos.AccumulatedFee * closePortion
+		marginPortion := pos.Margin * closePortion
+
+		realized := (price - pos.EntryPrice) * quantity
+		if pos.Side == "short" {
+			realized = -realized
+		}
+
+		account.Cash += marginPortion + realized - closingFee
+		account.RealizedPnL += realized - closingFee - openingFeePortion
+		account.TotalFees += closingFee
+
+		record := &store.PaperClosedPnL{
+			TraderID:    t.traderID,
+			Symbol:      pos.Symbol,
+			Side:        pos.Side,
+			EntryPrice:  pos.EntryPrice,
+			ExitPrice:   price,
+			Quantity:    quantity,
+			RealizedPnL: realized,
+			Fee:         closingFee + openingFeePortion,
+			Leverage:    pos.Leverage,
+			OrderID:     orderID,
+			CloseType:   closeType,
+			EntryTime:   pos.CreatedAt,
+			ExitTime:    time.Now().UTC().UnixMilli(),
+		}
+
+		pos.Quantity -= quantity
+		pos.Margin -= marginPortion
+		pos.AccumulatedFee -= openingFeePortion
+		if pos.Quantity <= epsilon {
+			pos.Quantity = 0
+		}
+
+		if err := tx.SavePosition(pos); err != nil {
+			return err
+		}
+		if err := tx.SaveAccount(account); err != nil {
+			return err
+		}
+		if err := tx.CreateClosedPnL(record); err != nil {
+			return err
+		}
+		if err := tx.CreateFill(t.newFill(orderID, pos.Symbol, pos.Side, "close_"+pos.Side, price, quantity, realized, closingFee, isMaker)); err != nil {
+			return err
+		}
+		closed, fee = quantity, closingFee
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return closed, fee, nil
+}
+
+// newFill builds the execution record order sync rebuilds orders/fills/positions from
+func (t *PaperTrader) newFill(orderID, symbol, side, action string, price, quantity, realizedPnL, fee float64, isMaker bool) *store.PaperFill {
+	return &store.PaperFill{
+		TraderID:     t.traderID,
+		TradeID:      fmt.Sprintf("%s-%s-%d", orderID, action, time.Now().UnixNano()), // Partial fills share the order and action
+		OrderID:      orderID,
+		Symbol:       symbol,
+		Side:         sideToOrderSide(side, strings.HasPrefix(action, "open_")),
+		PositionSide: strings.ToUpper(side),
+		OrderAction:  action,
+		Price:        price,
+		Quantity:     quantity,
+		RealizedPnL:  realizedPnL,
+		Fee:          fee,
+		IsMaker:      isMaker,
+	}
+}
+
+// RunTriggers checks liquidations and resting orders against the market every interval until
+// stop is closed, so stops and limit orders fire while nothing queries the account
+func (t *PaperTrader) RunTriggers(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			err := t.processTriggers(make(map[string]float64))
+			t.mu.Unlock()
+			if err != nil {
+				logger.Warnf("  [Paper] Failed to process triggers: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// processTriggers liquidates underwater positions and fills pending orders whose trigger was crossed
+func (t *PaperTrader) processTriggers(prices map[string]float64) error {
+	positions, err := t.store.ListPositions(t.traderID)
+	if err != nil {
+		return err
+	}
+
+	// 1. Liquidations take precedence over resting orders
+	for _, pos := range positions {
+		price, err := t.cachedPrice(prices, pos.Symbol)
+		if err != nil || pos.LiquidationPrice <= 0 {
+			continue
+		}
+		liquidated := (pos.Side == "long" && price <= pos.LiquidationPrice) ||
+			(pos.Side == "short" && price >= pos.LiquidationPrice)
+		if !liquidated {
+			continue
+		}
+		order := t.newOrder(pos.Symbol, sideToOrderSide(pos.Side, false), strings.ToUpper(pos.Side), "LIQUIDATION", pos.Quantity)
+		order.ReduceOnly = true
+		order.Leverage = pos.Leverage
+		liquidationPrice := pos.LiquidationPrice
+		qty, fee, err := t.closePosition(pos, order.OrderID, pos.Quantity, liquidationPrice, false, "liquidation")
+		if err != nil {
+			return err
+		}
+		if qty <= 0 {
+			continue
+		}
+		t.markFilled(order, qty, liquidationPrice, fee)
+		if err := t.store.CreateOrder(order); err != nil {
+			return err
+		}
+		if _, err := t.store.CancelOrders(t.traderID, pos.Symbol); err != nil {
+			return err
+		}
+		logger.Warnf("  [Paper] 💥 %s %s liquidated at %.6f (mark %.6f)", pos.Symbol, pos.Side, liquidationPrice, price)
+	}
+
+	// 2. Resting orders, matched per symbol against the latest price
+	orders, err := t.store.ListOpenOrders(t.traderID, "")
+	if err != nil {
+		return err
+	}
+	bySymbol := make(map[string][]*store.PaperOrder)
+	symbols := make([]string, 0)
+	for _, order := range orders {
+		if _, ok := bySymbol[order.Symbol]; !ok {
+			symbols = append(symbols, order.Symbol)
+		}
+		bySymbol[order.Symbol] = append(bySymbol[order.Symbol], order)
+	}
+
+	now := time.Now().UTC().UnixMilli()
+	for _, symbol := range symbols {
+		price, err := t.cachedPrice(prices, symbol)
+		if err != nil {
+			continue
+		}
+		pending := make(map[string]*store.PaperOrder)
+		simOrders := make([]*fillmodel.Order, 0, len(bySymbol[symbol]))
+		for _, order := range bySymbol[symbol] {
+			pending[order.OrderID] = order
+			simOrders = append(simOrders, toSimOrder(order))
+		}
+		for _, fill := range t.fills.Match(simOrders, fillmodel.PriceBar(now, price)) {
+			order := pending[fill.OrderID]
+			if err := t.fillOrder(order, fill); err != nil {
+				logger.Warnf("  [Paper] Failed to fill order %s: %v", order.OrderID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fillOrder applies a fill produced by the fill model to the simulated account
+func (t *PaperTrader) fillOrder(order *store.PaperOrder, fill fillmodel.Fill) error {
+	switch order.Type {
+	case "STOP_MARKET", "TAKE_PROFIT_MARKET":
+		side := strings.ToLower(order.PositionSide)
+		pos, err := t.store.GetPosition(t.traderID, order.Symbol, side)
+		if err != nil {
+			return err
+		}
+		if pos == nil {
+			// Position already gone: the order can never execute
+			order.Status = "CANCELED"
+			return t.store.UpdateOrder(order)
+		}
+		qty := order.Quantity
+		if qty <= 0 || qty > pos.Quantity {
+			qty = pos.Quantity
+		}
+		closeType := fill.Reason()
+		execPrice := fill.Price
+		qty, fee, err := t.closePosition(pos, order.OrderID, qty, execPrice, false, closeType)
+		if err != nil {
+			return err
+		}
+		if qty <= 0 {
+			order.Status = "CANCELED"
+			return t.store.UpdateOrder(order)
+		}
+		t.markFilled(order, qty, execPrice, fee)
+		if err := t.store.UpdateOrder(order); err != nil {
+			return err
+		}
+		// One-cancels-other: a fully closed position leaves no protective orders behind
+		if pos.Quantity <= epsilon {
+			if _, err := t.store.CancelOrders(t.traderID, order.Symbol, "STOP_MARKET", "TAKE_PROFIT_MARKET"); err != nil {
+				return err
+			}
+		}
+		logger.Infof("  [Paper] %s triggered for %s %s: qty=%.6f price=%.6f", order.Type, order.Symbol, side, qty, execPrice)
+		return nil
+
+	case "LIMIT":
+		return t.fillLimitOrder(order, fill)
+	}
+	return nil
+}
+
+// fillLimitOrder executes the quantity of fill at the fill price. A partial fill leaves the rest
+// of the order resting as PARTIALLY_FILLED.
+func (t *PaperTrader) fillLimitOrder(order *store.PaperOrder, fill fillmodel.Fill) error {
+	remaining := fill.Quantity
+	totalFee := 0.0
+
+	// Determine which position the order closes (if any) and which it opens
+	var closeSide, openSide string
+	switch order.PositionSide {
+	case "LONG":
+		if order.Side == "BUY" {
+			openSide = "long"
+		} else {
+			closeSide = "long"
+		}
+	case "SHORT":
+		if order.Side == "SELL" {
+			openSide = "short"
+		} else {
+			closeSide = "short"
+		}
+	default:
+		// One-way mode: net against the opposite position first, remainder opens
+		if order.Side == "BUY" {
+			closeSide, openSide = "short", "long"
+		} else {
+			closeSide, openSide = "long", "short"
+		}
+	}
+	if order.ReduceOnly {
+		openSide = ""
+	}
+
+	if closeSide != "" {
+		pos, err := t.store.GetPosition(t.traderID, order.Symbol, closeSide)
+		if err != nil {
+			return err
+		}
+		if pos != nil {
+			qty, fee, err := t.closePosition(pos, order.OrderID, math.Min(remaining, pos.Quantity), fill.Price, fill.IsMaker, "manual")
+			if err != nil {
+				return err
+			}
+			totalFee += fee
+			remaining -= qty
+		}
+	}
+
+	if remaining > epsilon && openSide != "" {
+		leverage := order.Leverage
+		if leverage <= 0 {
+			leverage = 1
+		}
+		fee, err := t.openPosition(order.OrderID, order.Symbol, openSide, remaining, leverage, fill.Price, fill.IsMaker)
+		if err != nil {
+			// Keep what the close part executed; an order that executed nothing is rejected
+			t.addExecution(order, fill.Quantity-remaining, fill.Price, totalFee)
+			order.Status = "CANCELED"
+			if order.ExecutedQty <= epsilon {
+				order.Status = "REJECTED"
+			}
+			if updateErr := t.store.UpdateOrder(order); updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+		totalFee += fee
+		remaining = 0
+	}
+
+	t.addExecution(order, fill.Quantity-remaining, fill.Price, totalFee)
+	switch {
+	case order.ExecutedQty <= epsilon:
+		// Reduce-only order with nothing to reduce
+		order.Status = "CANCELED"
+	case fill.Partial && remaining <= epsilon:
+		order.Status = "PARTIALLY_FILLED"
+	default:
+		// Complete, or a reduce-only order that ran out of position
+		order.Status = "FILLED"
+	}
+	return t.store.UpdateOrder(order)
+}
+
+// addExecution adds an execution to the filled quantity, average price and commission of order
+func (t *PaperTrader) addExecution(order *store.PaperOrder, qty, price, fee float64) {
+	if qty <= epsilon {
+		return
+	}
+	executed := order.ExecutedQty + qty
+	order.AvgPrice = (order.AvgPrice*order.ExecutedQty + price*qty) / executed
+	order.ExecutedQty = executed
+	order.Commission += fee
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+// toSimOrder converts a stored order for the fill model.
+// Protective orders of one position form an OCO group so only one of them fires per price update.
+func toSimOrder(order *store.PaperOrder) *fillmodel.Order {
+	sim := &fillmodel.Order{
+		ID:         order.OrderID,
+		Symbol:     order.Symbol,
+		Side:       order.Side,
+		Type:       order.Type,
+		Price:      order.Price,
+		StopPrice:  order.StopPrice,
+		Quantity:   order.Quantity,
+		Filled:     order.ExecutedQty,
+		ReduceOnly: order.ReduceOnly,
+	}
+	if order.Type == fillmodel.TypeStopMarket || order.Type == fillmodel.TypeTakeProfitMarket {
+		sim.OCOGroup = order.Symbol + ":" + order.PositionSide
+	}
+	return sim
+}
+
+// sideToOrderSide maps position side (long/short) and direction to BUY/SELL
+func sideToOrderSide(side string, isOpen bool) string {
+	if (side == "long") == isOpen {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+func computeLiquidation(entry float64, leverage int, side string) float64 {
+	if leverage <= 0 {
+		return 0
+	}
+	lev := float64(leverage)
+	if side == "long" {
+		return entry * (1.0 - 1.0/lev)
+	}
+	return entry * (1.0 + 1.0/lev)
+}
+
+func unrealizedPnL(pos *store.PaperPosition, price float64) float64 {
+	if pos.Side == "long" {
+		return (price - pos.EntryPrice) * pos.Quantity
+	}
+	return (pos.EntryPrice - price) * pos.Quantity
+}
@@ -0,0 +1,380 @@
+package paper
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nofx/store"
+	"nofx/trader/fillmodel"
+	"nofx/trader/types"
+)
+
+// fakePrices is a mutable price source for deterministic simulation
+type fakePrices struct {
+	mu     sync.Mutex
+	prices map[string]float64
+}
+
+func (f *fakePrices) set(symbol string, price float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prices[symbol] = price
+}
+
+func (f *fakePrices) get(symbol string) (float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.prices[symbol], nil
+}
+
+func newTestTrader(t *testing.T, balance float64) (*PaperTrader, *fakePrices) {
+	t.Helper()
+	st, err := store.New(filepath.Join(t.TempDir(), "paper.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { st.Close() })
+
+	trader, err := NewPaperTrader(st, "paper-test", balance)
+	require.NoError(t, err)
+
+	prices := &fakePrices{prices: map[string]float64{"BTCUSDT": 50000}}
+	trader.SetPriceSource(prices.get)
+	trader.SetSlippageRate(0)
+	trader.SetFeeRates(0.0002, 0.0004)
+	return trader, prices
+}
+
+func balanceOf(t *testing.T, trader *PaperTrader) map[string]interface{} {
+	t.Helper()
+	balance, err := trader.GetBalance()
+	require.NoError(t, err)
+	return balance
+}
+
+func TestPaperTrader_ImplementsGridTrader(t *testing.T) {
+	var _ types.Trader = (*PaperTrader)(nil)
+	var _ types.GridTrader = (*PaperTrader)(nil)
+}
+
+func TestPaperTrader_OpenCloseLong(t *testing.T) {
+	trader, prices := newTestTrader(t, 10000)
+
+	result, err := trader.OpenLong("BTC", 0.1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", result["status"])
+
+	// Margin 500 + taker fee 2 debited from available cash
+	balance := balanceOf(t, trader)
+	assert.InDelta(t, 9998.0, balance["totalWalletBalance"].(float64), 1e-6)
+	assert.InDelta(t, 9498.0, balance["availableBalance"].(float64), 1e-6)
+
+	positions, err := trader.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, "long", positions[0]["side"])
+	assert.InDelta(t, 0.1, positions[0]["positionAmt"].(float64), 1e-9)
+	assert.InDelta(t, 45000.0, positions[0]["liquidationPrice"].(float64), 1e-6)
+
+	prices.set("BTCUSDT", 51000)
+	balance = balanceOf(t, trader)
+	assert.InDelta(t, 100.0, balance["totalUnrealizedProfit"].(float64), 1e-6)
+
+	result, err = trader.CloseLong("BTCUSDT", 0)
+	require.NoError(t, err)
+
+	status, err := trader.GetOrderStatus("BTCUSDT", result["orderId"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", status["status"])
+	assert.InDelta(t, 51000.0, status["avgPrice"].(float64), 1e-6)
+	assert.InDelta(t, 2.04, status["commission"].(float64), 1e-6)
+
+	// 10000 - 2 (open fee) + 100 (pnl) - 2.04 (close fee)
+	balance = balanceOf(t, trader)
+	assert.InDelta(t, 10095.96, balance["totalWalletBalance"].(float64), 1e-6)
+
+	closed, err := trader.GetClosedPnL(time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, closed, 1)
+	assert.Equal(t, "manual", closed[0].CloseType)
+	assert.InDelta(t, 100.0, closed[0].RealizedPnL, 1e-6)
+	assert.InDelta(t, 4.04, closed[0].Fee, 1e-6)
+}
+
+func TestPaperTrader_TypedQueries(t *testing.T) {
+	trader, prices := newTestTrader(t, 10000)
+	var _ types.TypedQueries = trader
+
+	result, err := trader.OpenShort("BTCUSDT", 0.2, 5)
+	require.NoError(t, err)
+	prices.set("BTCUSDT", 49000)
+
+	positions, err := trader.GetPositionsV2()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, "short", positions[0].Side)
+	assert.InDelta(t, 0.2, positions[0].Quantity, 1e-9)
+	assert.InDelta(t, 50000.0, positions[0].EntryPrice, 1e-6)
+	assert.InDelta(t, 200.0, positions[0].UnrealizedPnL, 1e-6)
+	assert.Equal(t, 5, positions[0].Leverage)
+
+	// Margin 2000 + taker fee 4
+	balance, err := trader.GetBalanceV2()
+	require.NoError(t, err)
+	assert.InDelta(t, 9996.0, balance.TotalWalletBalance, 1e-6)
+	assert.InDelta(t, 7996.0, balance.AvailableBalance, 1e-6)
+	assert.InDelta(t, 10196.0, balance.TotalEquity, 1e-6)
+
+	status, err := trader.GetOrderStatusV2("BTCUSDT", result["orderId"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", status.Status)
+	assert.InDelta(t, 0.2, status.ExecutedQty, 1e-9)
+	assert.InDelta(t, 4.0, status.Commission, 1e-6)
+}
+
+func TestPaperTrader_ConcurrentInstances(t *testing.T) {
+	trader, prices := newTestTrader(t, 10000)
+	// A second instance on the same account, like a temporary trader of an API call
+	other := &PaperTrader{
+		traderID:       trader.traderID,
+		initialBalance: trader.initialBalance,
+		store:          trader.store,
+		fills:          trader.fills,
+		priceFn:        prices.get,
+		leverage:       make(map[string]int),
+	}
+
+	var wg sync.WaitGroup
+	for _, pt := range []*PaperTrader{trader, other} {
+		wg.Add(1)
+		go func(pt *PaperTrader) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				_, err := pt.OpenLong("BTCUSDT", 0.01, 5)
+				assert.NoError(t, err)
+			}
+		}(pt)
+	}
+	wg.Wait()
+
+	// No open overwrote another: 20 x (100 margin + 0.2 fee)
+	positions, err := trader.GetPositionsV2()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.InDelta(t, 0.2, positions[0].Quantity, 1e-9)
+	account, err := trader.store.GetOrCreateAccount(trader.traderID, 0)
+	require.NoError(t, err)
+	assert.InDelta(t, 10000-20*100.2, account.Cash, 1e-6)
+
+	// Closing from both instances closes the position once
+	_, err = other.CloseLong("BTCUSDT", 0)
+	require.NoError(t, err)
+	_, err = trader.CloseLong("BTCUSDT", 0)
+	assert.Error(t, err)
+}
+
+func TestPaperTrader_InsufficientBalance(t *testing.T) {
+	trader, _ := newTestTrader(t, 100)
+
+	_, err := trader.OpenShort("BTCUSDT", 1, 5)
+	assert.Error(t, err)
+
+	positions, err := trader.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+}
+
+func TestPaperTrader_StopLossAndTakeProfit(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      string
+		nextPrice float64
+		closeType string
+	}{
+		{name: "long stop loss", side: "long", nextPrice: 48900, closeType: "stop_loss"},
+		{name: "long take profit", side: "long", nextPrice: 53100, closeType: "take_profit"},
+		{name: "short stop loss", side: "short", nextPrice: 51100, closeType: "stop_loss"},
+		{name: "short take profit", side: "short", nextPrice: 46900, closeType: "take_profit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trader, prices := newTestTrader(t, 10000)
+
+			if tt.side == "long" {
+				_, err := trader.OpenLong("BTCUSDT", 0.1, 5)
+				require.NoError(t, err)
+				require.NoError(t, trader.SetStopLoss("BTCUSDT", "LONG", 0.1, 49000))
+				require.NoError(t, trader.SetTakeProfit("BTCUSDT", "LONG", 0.1, 53000))
+			} else {
+				_, err := trader.OpenShort("BTCUSDT", 0.1, 5)
+				require.NoError(t, err)
+				require.NoError(t, trader.SetStopLoss("BTCUSDT", "SHORT", 0.1, 51000))
+				require.NoError(t, trader.SetTakeProfit("BTCUSDT", "SHORT", 0.1, 47000))
+			}
+
+			orders, err := trader.GetOpenOrders("BTCUSDT")
+			require.NoError(t, err)
+			assert.Len(t, orders, 2)
+
+			prices.set("BTCUSDT", tt.nextPrice)
+			positions, err := trader.GetPositions()
+			require.NoError(t, err)
+			assert.Empty(t, positions)
+
+			// Sibling protective order is cancelled once the position is gone
+			orders, err = trader.GetOpenOrders("BTCUSDT")
+			require.NoError(t, err)
+			assert.Empty(t, orders)
+
+			closed, err := trader.GetClosedPnL(time.Now().Add(-time.Hour), 10)
+			require.NoError(t, err)
+			require.Len(t, closed, 1)
+			assert.Equal(t, tt.closeType, closed[0].CloseType)
+		})
+	}
+}
+
+func TestPaperTrader_RunTriggersFiresStopsWithoutPolling(t *testing.T) {
+	trader, prices := newTestTrader(t, 10000)
+	_, err := trader.OpenLong("BTCUSDT", 0.1, 5)
+	require.NoError(t, err)
+	require.NoError(t, trader.SetStopLoss("BTCUSDT", "LONG", 0.1, 49000))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		trader.RunTriggers(10*time.Millisecond, stop)
+	}()
+	prices.set("BTCUSDT", 48500)
+
+	// Read the store directly: account queries would process the trigger themselves
+	assert.Eventually(t, func() bool {
+		pos, err := trader.store.GetPosition("paper-test", "BTCUSDT", "long")
+		return err == nil && pos == nil
+	}, time.Second, 10*time.Millisecond)
+	close(stop)
+	<-done
+
+	closed, err := trader.store.ListClosedPnL("paper-test", time.Time{}, 0)
+	require.NoError(t, err)
+	require.Len(t, closed, 1)
+	assert.Equal(t, "stop_loss", closed[0].CloseType)
+}
+
+func TestPaperTrader_Liquidation(t *testing.T) {
+	trader, prices := newTestTrader(t, 10000)
+
+	_, err := trader.OpenLong("BTCUSDT", 0.2, 20)
+	require.NoError(t, err)
+
+	prices.set("BTCUSDT", 47000)
+	positions, err := trader.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+
+	closed, err := trader.GetClosedPnL(time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, closed, 1)
+	assert.Equal(t, "liquidation", closed[0].CloseType)
+	assert.InDelta(t, 47500.0, closed[0].ExitPrice, 1e-6)
+	assert.InDelta(t, -500.0, closed[0].RealizedPnL, 1e-6)
+}
+
+func TestPaperTrader_GridLimitOrders(t *testing.T) {
+	trader, prices := newTestTrader(t, 10000)
+
+	buy, err := trader.PlaceLimitOrder(&types.LimitOrderRequest{
+		Symbol: "BTCUSDT", Side: "BUY", Price: 49000, Quantity: 0.05, Leverage: 5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "NEW", buy.Status)
+
+	sell, err := trader.PlaceLimitOrder(&types.LimitOrderRequest{
+		Symbol: "BTCUSDT", Side: "SELL", Price: 51000, Quantity: 0.05, Leverage: 5,
+	})
+	require.NoError(t, err)
+
+	// Buy level fills at its limit price with maker fee
+	prices.set("BTCUSDT", 48800)
+	status, err := trader.GetOrderStatus("BTCUSDT", buy.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", status["status"])
+	assert.InDelta(t, 49000.0, status["avgPrice"].(float64), 1e-6)
+	assert.InDelta(t, 0.49, status["commission"].(float64), 1e-6)
+
+	// Sell level nets against the long in one-way mode
+	prices.set("BTCUSDT", 51200)
+	status, err = trader.GetOrderStatus("BTCUSDT", sell.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", status["status"])
+
+	positions, err := trader.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+
+	closed, err := trader.GetClosedPnL(time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, closed, 1)
+	assert.InDelta(t, 100.0, closed[0].RealizedPnL, 1e-6)
+
+	// Cancel a resting order
+	pending, err := trader.PlaceLimitOrder(&types.LimitOrderRequest{
+		Symbol: "BTCUSDT", Side: "BUY", Price: 50000, Quantity: 0.05, Leverage: 5,
+	})
+	require.NoError(t, err)
+	require.NoError(t, trader.CancelOrder("BTCUSDT", pending.OrderID))
+	orders, err := trader.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+}
+
+func TestPaperTrader_PartialLimitFill(t *testing.T) {
+	trader, prices := newTestTrader(t, 10000)
+
+	buy, err := trader.PlaceLimitOrder(&types.LimitOrderRequest{
+		Symbol: "BTCUSDT", Side: "BUY", Price: 49000, Quantity: 0.05, Leverage: 5,
+	})
+	require.NoError(t, err)
+	order, err := trader.store.GetOrder("paper-test", buy.OrderID)
+	require.NoError(t, err)
+
+	// A participation-capped fill executes only part of the order, at the fill price
+	trader.mu.Lock()
+	err = trader.fillOrder(order, fillmodel.Fill{
+		OrderID: order.OrderID, Type: fillmodel.TypeLimit, Price: 48990, Quantity: 0.02, IsMaker: true, Partial: true,
+	})
+	trader.mu.Unlock()
+	require.NoError(t, err)
+
+	status, err := trader.GetOrderStatus("BTCUSDT", buy.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, "PARTIALLY_FILLED", status["status"])
+	assert.InDelta(t, 0.02, status["executedQty"].(float64), 1e-9)
+	assert.InDelta(t, 48990.0, status["avgPrice"].(float64), 1e-6)
+
+	positions, err := trader.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.InDelta(t, 0.02, positions[0]["positionAmt"].(float64), 1e-9)
+
+	orders, err := trader.GetOpenOrders("BTCUSDT")
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+
+	// The remainder keeps resting and fills on the next touch
+	prices.set("BTCUSDT", 48800)
+	status, err = trader.GetOrderStatus("BTCUSDT", buy.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, "FILLED", status["status"])
+	assert.InDelta(t, 0.05, status["executedQty"].(float64), 1e-9)
+	assert.InDelta(t, (48990*0.02+49000*0.03)/0.05, status["avgPrice"].(float64), 1e-6)
+
+	positions, err = trader.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.InDelta(t, 0.05, positions[0]["positionAmt"].(float64), 1e-9)
+}
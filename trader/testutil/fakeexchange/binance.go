@@ -0,0 +1,507 @@
+package fakeexchange
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"nofx/trader/types"
+)
+
+// NewBinanceServer serves the exchange as the Binance USDⓈ-M Futures REST API; point
+// futures.Client.BaseURL at the returned server's URL
+func NewBinanceServer(e *Exchange) *httptest.Server {
+	return httptest.NewServer(e.handler(&binanceDialect{e: e}))
+}
+
+type binanceDialect struct {
+	e *Exchange
+}
+
+// params merges query and form body parameters; the body is restored for later reads
+// since go-binance also sends form bodies with DELETE
+func (d *binanceDialect) params(r *http.Request) url.Values {
+	values := r.URL.Query()
+	if r.Body != nil {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		form, _ := url.ParseQuery(string(body))
+		for k, v := range form {
+			values[k] = append(values[k], v...)
+		}
+	}
+	return values
+}
+
+func (d *binanceDialect) requestTime(r *http.Request) (time.Time, time.Duration, time.Duration, bool) {
+	p := d.params(r)
+	if p.Get("signature") == "" {
+		return time.Time{}, 0, 0, false
+	}
+	ts, _ := strconv.ParseInt(p.Get("timestamp"), 10, 64)
+	window := 5 * time.Second
+	if ms, err := strconv.ParseInt(p.Get("recvWindow"), 10, 64); err == nil && ms > 0 {
+		window = time.Duration(ms) * time.Millisecond
+	}
+	return time.UnixMilli(ts), window, time.Second, true
+}
+
+func (d *binanceDialect) rateLimited(w http.ResponseWriter) {
+	d.error(w, http.StatusTooManyRequests, -1003, "Too many requests; current limit of IP is 2400 requests per minute. Please use the websocket for live updates to avoid polling the API.")
+}
+
+func (d *binanceDialect) timestampRejected(w http.ResponseWriter) {
+	d.error(w, http.StatusBadRequest, -1021, "Timestamp for this request is outside of the recvWindow.")
+}
+
+func (d *binanceDialect) error(w http.ResponseWriter, status int, code int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"code": code, "msg": msg})
+}
+
+// engineError renders a matching engine error as the Binance error code
+func (d *binanceDialect) engineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownSymbol):
+		d.error(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+	case errors.Is(err, ErrUnknownOrder):
+		d.error(w, http.StatusBadRequest, -2013, "Order does not exist.")
+	case errors.Is(err, ErrInvalidQuantity):
+		d.error(w, http.StatusBadRequest, -1013, "Filter failure: LOT_SIZE")
+	case errors.Is(err, ErrInvalidPrice):
+		d.error(w, http.StatusBadRequest, -1102, "Mandatory parameter 'price' was not sent, was empty/null, or malformed.")
+	case errors.Is(err, ErrPositionSide):
+		d.error(w, http.StatusBadRequest, -4061, "Order's position side does not match user's setting.")
+	case errors.Is(err, ErrReduceOnly):
+		d.error(w, http.StatusBadRequest, -2022, "ReduceOnly Order is rejected.")
+	case errors.Is(err, ErrInsufficientMargin):
+		d.error(w, http.StatusBadRequest, -2019, "Margin is insufficient.")
+	default:
+		d.error(w, http.StatusBadRequest, -1000, err.Error())
+	}
+}
+
+func (d *binanceDialect) serve(w http.ResponseWriter, r *http.Request) {
+	p := d.params(r)
+	route := r.Method + " " + r.URL.Path
+	switch route {
+	case "GET /fapi/v1/time":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"serverTime": d.e.Now().UnixMilli()})
+	case "GET /fapi/v1/exchangeInfo":
+		d.exchangeInfo(w)
+	case "GET /fapi/v1/ticker/price", "GET /fapi/v2/ticker/price":
+		d.tickerPrice(w, p.Get("symbol"))
+	case "GET /fapi/v1/depth":
+		d.depth(w, p)
+	case "GET /fapi/v2/account", "GET /fapi/v3/account":
+		d.account(w)
+	case "GET /fapi/v2/balance", "GET /fapi/v3/balance":
+		b := d.e.Balance()
+		writeJSON(w, http.StatusOK, []map[string]interface{}{{
+			"asset": "USDT", "balance": ftoa(b.Wallet), "crossWalletBalance": ftoa(b.Wallet),
+			"crossUnPnl": ftoa(b.UnrealizedPnL), "availableBalance": ftoa(b.Available), "maxWithdrawAmount": ftoa(b.Available),
+		}})
+	case "GET /fapi/v2/positionRisk", "GET /fapi/v3/positionRisk":
+		d.positionRisk(w, p.Get("symbol"))
+	case "GET /fapi/v1/positionSide/dual":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"dualSidePosition": d.e.HedgeMode()})
+	case "POST /fapi/v1/positionSide/dual":
+		hedge := p.Get("dualSidePosition") == "true"
+		if d.e.HedgeMode() == hedge {
+			d.error(w, http.StatusBadRequest, -4059, "No need to change position side.")
+			return
+		}
+		if err := d.e.SetHedgeMode(hedge); err != nil {
+			d.error(w, http.StatusBadRequest, -4068, "Position side cannot be changed if there exists position.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": 200, "msg": "success"})
+	case "POST /fapi/v1/leverage":
+		leverage, _ := strconv.Atoi(p.Get("leverage"))
+		if err := d.e.SetLeverage(p.Get("symbol"), leverage); err != nil {
+			if errors.Is(err, ErrUnknownSymbol) {
+				d.engineError(w, err)
+			} else {
+				d.error(w, http.StatusBadRequest, -4028, "Leverage is not valid")
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"leverage": leverage, "maxNotionalValue": "1000000", "symbol": p.Get("symbol")})
+	case "POST /fapi/v1/marginType":
+		symbol, isolated := p.Get("symbol"), p.Get("marginType") == "ISOLATED"
+		if _, ok := d.e.Instrument(symbol); ok && d.e.Isolated(symbol) == isolated {
+			d.error(w, http.StatusBadRequest, -4046, "No need to change margin type.")
+			return
+		}
+		if err := d.e.SetMarginMode(symbol, isolated); err != nil {
+			if errors.Is(err, ErrPositionExists) {
+				d.error(w, http.StatusBadRequest, -4048, "Margin type cannot be changed if there exists position.")
+			} else {
+				d.engineError(w, err)
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": 200, "msg": "success"})
+	case "POST /fapi/v1/order":
+		d.createOrder(w, p)
+	case "GET /fapi/v1/order":
+		o, err := d.lookupOrder(p)
+		if err != nil {
+			d.engineError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrder(o))
+	case "DELETE /fapi/v1/order":
+		o, err := d.lookupOrder(p)
+		if err == nil {
+			o, err = d.e.CancelOrder(p.Get("symbol"), o.ID)
+		}
+		if err != nil {
+			d.engineError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrder(o))
+	case "GET /fapi/v1/openOrders":
+		orders := d.e.Orders(func(o Order) bool {
+			return !o.IsConditional() && o.IsOpen() && (p.Get("symbol") == "" || o.Symbol == p.Get("symbol"))
+		})
+		result := make([]map[string]interface{}, 0, len(orders))
+		for _, o := range orders {
+			result = append(result, binanceOrder(o))
+		}
+		writeJSON(w, http.StatusOK, result)
+	case "DELETE /fapi/v1/allOpenOrders":
+		d.e.CancelOrders(p.Get("symbol"), func(o Order) bool { return !o.IsConditional() })
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": 200, "msg": "The operation of cancel all open order is done."})
+	case "POST /fapi/v1/algoOrder":
+		d.createAlgoOrder(w, p)
+	case "DELETE /fapi/v1/algoOrder":
+		id, _ := strconv.ParseInt(p.Get("algoId"), 10, 64)
+		o, err := d.e.Order(id)
+		if err == nil && o.IsConditional() {
+			o, err = d.e.CancelOrder(o.Symbol, id)
+		} else if err == nil {
+			err = ErrUnknownOrder
+		}
+		if err != nil {
+			d.error(w, http.StatusBadRequest, -2011, "Unknown order sent.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"algoId": o.ID, "clientAlgoId": o.ClientID, "code": "200", "msg": "success"})
+	case "GET /fapi/v1/openAlgoOrders":
+		orders := d.e.Orders(func(o Order) bool {
+			return o.IsConditional() && o.IsOpen() && (p.Get("symbol") == "" || o.Symbol == p.Get("symbol"))
+		})
+		result := make([]map[string]interface{}, 0, len(orders))
+		for _, o := range orders {
+			result = append(result, binanceAlgoOrder(o))
+		}
+		writeJSON(w, http.StatusOK, result)
+	case "DELETE /fapi/v1/algoOpenOrders":
+		d.e.CancelOrders(p.Get("symbol"), Order.IsConditional)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": 200, "msg": "The operation of cancel all open order is done."})
+	case "GET /fapi/v1/userTrades":
+		d.userTrades(w, p)
+	case "GET /fapi/v1/income":
+		d.income(w, p)
+	default:
+		d.error(w, http.StatusNotFound, -5000, fmt.Sprintf("Path %s, Method %s is invalid", r.URL.Path, r.Method))
+	}
+}
+
+func (d *binanceDialect) exchangeInfo(w http.ResponseWriter) {
+	var symbols []map[string]interface{}
+	for _, inst := range d.e.Instruments() {
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":       inst.Symbol,
+			"pair":         inst.Symbol,
+			"contractType": "PERPETUAL",
+			"status":       "TRADING",
+			"baseAsset":    inst.Symbol[:len(inst.Symbol)-4],
+			"quoteAsset":   "USDT",
+			"marginAsset":  "USDT",
+			"filters": []map[string]interface{}{
+				{"filterType": "PRICE_FILTER", "tickSize": ftoa(inst.TickSize), "minPrice": ftoa(inst.TickSize), "maxPrice": "10000000"},
+				{"filterType": "LOT_SIZE", "stepSize": ftoa(inst.StepSize), "minQty": ftoa(inst.MinQty), "maxQty": "10000"},
+				{"filterType": "MARKET_LOT_SIZE", "stepSize": ftoa(inst.StepSize), "minQty": ftoa(inst.MinQty), "maxQty": "1000"},
+				{"filterType": "MIN_NOTIONAL", "notional": "5"},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"timezone": "UTC", "serverTime": d.e.Now().UnixMilli(), "symbols": symbols})
+}
+
+func (d *binanceDialect) tickerPrice(w http.ResponseWriter, symbol string) {
+	now := d.e.Now().UnixMilli()
+	if symbol == "" {
+		var result []map[string]interface{}
+		for _, inst := range d.e.Instruments() {
+			result = append(result, map[string]interface{}{"symbol": inst.Symbol, "price": ftoa(inst.Price), "time": now})
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	price, ok := d.e.Price(symbol)
+	if !ok {
+		d.engineError(w, ErrUnknownSymbol)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"symbol": symbol, "price": ftoa(price), "time": now})
+}
+
+func (d *binanceDialect) depth(w http.ResponseWriter, p url.Values) {
+	limit, _ := strconv.Atoi(p.Get("limit"))
+	bids, asks, err := d.e.Book(p.Get("symbol"), limit)
+	if err != nil {
+		d.engineError(w, err)
+		return
+	}
+	now := d.e.Now().UnixMilli()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"lastUpdateId": now, "E": now, "T": now, "bids": levels(bids), "asks": levels(asks)})
+}
+
+func (d *binanceDialect) account(w http.ResponseWriter) {
+	b := d.e.Balance()
+	var positions []map[string]interface{}
+	for _, pos := range d.e.Positions() {
+		positions = append(positions, map[string]interface{}{
+			"symbol": pos.Symbol, "positionSide": pos.Side, "positionAmt": ftoa(pos.Amount),
+			"entryPrice": ftoa(pos.EntryPrice), "leverage": strconv.Itoa(d.e.Leverage(pos.Symbol)),
+			"isolated": d.e.Isolated(pos.Symbol), "updateTime": pos.UpdateTime.UnixMilli(),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"canTrade":              true,
+		"totalWalletBalance":    ftoa(b.Wallet),
+		"totalUnrealizedProfit": ftoa(b.UnrealizedPnL),
+		"totalMarginBalance":    ftoa(b.Equity),
+		"totalInitialMargin":    ftoa(b.InitialMargin),
+		"availableBalance":      ftoa(b.Available),
+		"maxWithdrawAmount":     ftoa(b.Available),
+		"assets": []map[string]interface{}{{
+			"asset": "USDT", "walletBalance": ftoa(b.Wallet), "unrealizedProfit": ftoa(b.UnrealizedPnL),
+			"marginBalance": ftoa(b.Equity), "initialMargin": ftoa(b.InitialMargin), "availableBalance": ftoa(b.Available),
+		}},
+		"positions":  positions,
+		"updateTime": d.e.Now().UnixMilli(),
+	})
+}
+
+// positionRisk lists every symbol and side like the real endpoint, flat ones included
+func (d *binanceDialect) positionRisk(w http.ResponseWriter, symbol string) {
+	sides := []string{SideBoth}
+	if d.e.HedgeMode() {
+		sides = []string{SideLong, SideShort}
+	}
+	result := []map[string]interface{}{}
+	for _, inst := range d.e.Instruments() {
+		if symbol != "" && inst.Symbol != symbol {
+			continue
+		}
+		for _, side := range sides {
+			pos := d.e.Position(inst.Symbol, side)
+			leverage := d.e.Leverage(inst.Symbol)
+			marginType := "cross"
+			if d.e.Isolated(inst.Symbol) {
+				marginType = "isolated"
+			}
+			result = append(result, map[string]interface{}{
+				"symbol":           inst.Symbol,
+				"positionSide":     side,
+				"positionAmt":      ftoa(pos.Amount),
+				"entryPrice":       ftoa(pos.EntryPrice),
+				"markPrice":        ftoa(inst.Price),
+				"unRealizedProfit": ftoa(pos.Amount * (inst.Price - pos.EntryPrice)),
+				"liquidationPrice": ftoa(liquidationPrice(pos, leverage)),
+				"leverage":         strconv.Itoa(leverage),
+				"marginType":       marginType,
+				"notional":         ftoa(pos.Amount * inst.Price),
+				"updateTime":       pos.UpdateTime.UnixMilli(),
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (d *binanceDialect) createOrder(w http.ResponseWriter, p url.Values) {
+	orderType := p.Get("type")
+	if orderType != OrderTypeMarket && orderType != OrderTypeLimit {
+		// Conditional orders moved to the algo order API
+		d.error(w, http.StatusBadRequest, -4120, "Order type not supported for this endpoint. Please use the Algo Order API endpoints instead.")
+		return
+	}
+	o, err := d.e.PlaceOrder(OrderRequest{
+		ClientID:     p.Get("newClientOrderId"),
+		Symbol:       p.Get("symbol"),
+		Side:         p.Get("side"),
+		PositionSide: p.Get("positionSide"),
+		Type:         orderType,
+		Price:        atof(p.Get("price")),
+		Quantity:     atof(p.Get("quantity")),
+		ReduceOnly:   p.Get("reduceOnly") == "true",
+	})
+	if err != nil {
+		d.engineError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, binanceOrder(o))
+}
+
+func (d *binanceDialect) createAlgoOrder(w http.ResponseWriter, p url.Values) {
+	orderType := p.Get("type")
+	if orderType != OrderTypeStopMarket && orderType != OrderTypeTakeProfit && orderType != OrderTypeTrailingStop {
+		d.error(w, http.StatusBadRequest, -1116, "Invalid orderType.")
+		return
+	}
+	if orderType == OrderTypeTrailingStop {
+		if rate := atof(p.Get("callbackRate")); rate < 0.1 || rate > 10 {
+			d.error(w, http.StatusBadRequest, -2007, "Invalid callBack rate.")
+			return
+		}
+	}
+	o, err := d.e.PlaceOrder(OrderRequest{
+		ClientID:        p.Get("clientAlgoId"),
+		Symbol:          p.Get("symbol"),
+		Side:            p.Get("side"),
+		PositionSide:    p.Get("positionSide"),
+		Type:            orderType,
+		TriggerPrice:    atof(p.Get("triggerPrice")),
+		Quantity:        atof(p.Get("quantity")),
+		ClosePosition:   p.Get("closePosition") == "true",
+		ReduceOnly:      p.Get("reduceOnly") == "true",
+		CallbackRate:    atof(p.Get("callbackRate")),
+		ActivationPrice: atof(p.Get("activationPrice")),
+	})
+	if err != nil {
+		d.engineError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, binanceAlgoOrder(o))
+}
+
+func (d *binanceDialect) lookupOrder(p url.Values) (Order, error) {
+	var o Order
+	var err error
+	if id := p.Get("orderId"); id != "" {
+		orderID, _ := strconv.ParseInt(id, 10, 64)
+		o, err = d.e.Order(orderID)
+	} else {
+		o, err = d.e.OrderByClientID(p.Get("origClientOrderId"))
+	}
+	if err == nil && (o.Symbol != p.Get("symbol") || o.IsConditional()) {
+		err = ErrUnknownOrder
+	}
+	return o, err
+}
+
+func (d *binanceDialect) userTrades(w http.ResponseWriter, p url.Values) {
+	symbol := p.Get("symbol")
+	if _, ok := d.e.Instrument(symbol); !ok {
+		d.engineError(w, ErrUnknownSymbol)
+		return
+	}
+	fills := d.e.Fills(symbol, msTime(p.Get("startTime")))
+	fromID, _ := strconv.ParseInt(p.Get("fromId"), 10, 64)
+	result := []map[string]interface{}{}
+	for _, f := range limitFills(fills, p.Get("limit"), 500) {
+		if f.ID < fromID {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"id": f.ID, "orderId": f.OrderID, "symbol": f.Symbol, "side": f.Side, "positionSide": f.PositionSide,
+			"price": ftoa(f.Price), "qty": ftoa(f.Quantity), "quoteQty": ftoa(f.Price * f.Quantity),
+			"commission": ftoa(f.Fee), "commissionAsset": "USDT", "realizedPnl": ftoa(f.RealizedPnL),
+			"buyer": f.Side == "BUY", "maker": f.Maker, "time": f.Time.UnixMilli(),
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// income derives COMMISSION and REALIZED_PNL records from the fills
+func (d *binanceDialect) income(w http.ResponseWriter, p url.Values) {
+	incomeType := p.Get("incomeType")
+	result := []map[string]interface{}{}
+	for _, f := range d.e.Fills(p.Get("symbol"), msTime(p.Get("startTime"))) {
+		record := func(kind string, amount float64) {
+			if incomeType != "" && incomeType != kind {
+				return
+			}
+			result = append(result, map[string]interface{}{
+				"symbol": f.Symbol, "incomeType": kind, "income": ftoa(amount), "asset": "USDT",
+				"info": kind, "time": f.Time.UnixMilli(), "tranId": f.ID, "tradeId": strconv.FormatInt(f.ID, 10),
+			})
+		}
+		record("COMMISSION", -f.Fee)
+		if f.RealizedPnL != 0 {
+			record("REALIZED_PNL", f.RealizedPnL)
+		}
+	}
+	if limit, err := strconv.Atoi(p.Get("limit")); err == nil && limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func binanceOrder(o Order) map[string]interface{} {
+	return map[string]interface{}{
+		"orderId":       o.ID,
+		"clientOrderId": o.ClientID,
+		"symbol":        o.Symbol,
+		"side":          o.Side,
+		"positionSide":  o.PositionSide,
+		"type":          o.Type,
+		"origType":      o.Type,
+		"status":        o.Status,
+		"timeInForce":   "GTC",
+		"price":         ftoa(o.Price),
+		"avgPrice":      ftoa(o.AvgPrice),
+		"origQty":       ftoa(o.Quantity),
+		"executedQty":   ftoa(o.ExecutedQty),
+		"cumQty":        ftoa(o.ExecutedQty),
+		"cumQuote":      ftoa(o.ExecutedQty * o.AvgPrice),
+		"stopPrice":     ftoa(o.TriggerPrice),
+		"reduceOnly":    o.ReduceOnly,
+		"closePosition": o.ClosePosition,
+		"workingType":   "CONTRACT_PRICE",
+		"time":          o.CreateTime.UnixMilli(),
+		"updateTime":    o.UpdateTime.UnixMilli(),
+	}
+}
+
+func binanceAlgoOrder(o Order) map[string]interface{} {
+	status := "NEW"
+	switch {
+	case o.Status == types.OrderStatusCanceled:
+		status = "CANCELED"
+	case o.Triggered:
+		status = "FINISHED"
+	}
+	result := map[string]interface{}{
+		"algoId":        o.ID,
+		"clientAlgoId":  o.ClientID,
+		"algoType":      "CONDITIONAL",
+		"orderType":     o.Type,
+		"symbol":        o.Symbol,
+		"side":          o.Side,
+		"positionSide":  o.PositionSide,
+		"quantity":      ftoa(o.Quantity),
+		"algoStatus":    status,
+		"triggerPrice":  ftoa(o.TriggerPrice),
+		"price":         "0",
+		"workingType":   "CONTRACT_PRICE",
+		"closePosition": o.ClosePosition,
+		"reduceOnly":    o.ReduceOnly,
+		"createTime":    o.CreateTime.UnixMilli(),
+		"updateTime":    o.UpdateTime.UnixMilli(),
+	}
+	if o.Type == OrderTypeTrailingStop {
+		result["activatePrice"] = ftoa(o.ActivationPrice)
+		result["priceRate"] = ftoa(o.CallbackRate)
+	}
+	return result
+}
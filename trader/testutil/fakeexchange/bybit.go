@@ -0,0 +1,507 @@
+package fakeexchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"nofx/trader/types"
+)
+
+// NewBybitServer serves the exchange as the Bybit v5 (linear, unified account) REST API;
+// point bybit.Client.BaseURL at the returned server's URL
+func NewBybitServer(e *Exchange) *httptest.Server {
+	return httptest.NewServer(e.handler(&bybitDialect{e: e}))
+}
+
+type bybitDialect struct {
+	e *Exchange
+}
+
+func (d *bybitDialect) requestTime(r *http.Request) (time.Time, time.Duration, time.Duration, bool) {
+	header := r.Header.Get("X-BAPI-TIMESTAMP")
+	if header == "" {
+		return time.Time{}, 0, 0, false
+	}
+	ts, _ := strconv.ParseInt(header, 10, 64)
+	window := 5 * time.Second
+	if ms, err := strconv.ParseInt(r.Header.Get("X-BAPI-RECV-WINDOW"), 10, 64); err == nil && ms > 0 {
+		window = time.Duration(ms) * time.Millisecond
+	}
+	return time.UnixMilli(ts), window, time.Second, true
+}
+
+// Bybit reports most errors with HTTP 200 and a non-zero retCode
+
+func (d *bybitDialect) rateLimited(w http.ResponseWriter) {
+	d.error(w, 10006, "Too many visits!")
+}
+
+func (d *bybitDialect) timestampRejected(w http.ResponseWriter) {
+	d.error(w, 10002, "invalid request, please check your server timestamp or recv_window param")
+}
+
+func (d *bybitDialect) error(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"retCode": code, "retMsg": msg, "result": map[string]interface{}{}, "retExtInfo": map[string]interface{}{}, "time": d.e.Now().UnixMilli(),
+	})
+}
+
+func (d *bybitDialect) ok(w http.ResponseWriter, result interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"retCode": 0, "retMsg": "OK", "result": result, "retExtInfo": map[string]interface{}{}, "time": d.e.Now().UnixMilli(),
+	})
+}
+
+func (d *bybitDialect) engineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownSymbol):
+		d.error(w, 10001, "params error: symbol invalid")
+	case errors.Is(err, ErrUnknownOrder):
+		d.error(w, 110001, "order not exists or too late to cancel")
+	case errors.Is(err, ErrInvalidQuantity):
+		d.error(w, 10001, "The number of contracts is below the minimum allowed")
+	case errors.Is(err, ErrInvalidPrice):
+		d.error(w, 10001, "params error: price invalid")
+	case errors.Is(err, ErrPositionSide):
+		d.error(w, 10001, "position idx not match position mode")
+	case errors.Is(err, ErrReduceOnly):
+		d.error(w, 110017, "Reduce-only rule not satisfied")
+	case errors.Is(err, ErrInsufficientMargin):
+		d.error(w, 110007, "ab not enough for new order")
+	default:
+		d.error(w, 10001, err.Error())
+	}
+}
+
+// params merges the query with the JSON body of POST requests, values as strings
+func (d *bybitDialect) params(r *http.Request) url.Values {
+	values := r.URL.Query()
+	if r.Method != http.MethodPost || r.Body == nil {
+		return values
+	}
+	body, _ := io.ReadAll(r.Body)
+	var fields map[string]interface{}
+	json.Unmarshal(body, &fields)
+	for k, v := range fields {
+		switch v := v.(type) {
+		case string:
+			values.Set(k, v)
+		case float64:
+			values.Set(k, ftoa(v))
+		default:
+			values.Set(k, fmt.Sprint(v))
+		}
+	}
+	return values
+}
+
+func (d *bybitDialect) serve(w http.ResponseWriter, r *http.Request) {
+	p := d.params(r)
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v5/market/time":
+		now := d.e.Now()
+		d.ok(w, map[string]interface{}{"timeSecond": strconv.FormatInt(now.Unix(), 10), "timeNano": strconv.FormatInt(now.UnixNano(), 10)})
+	case "GET /v5/market/tickers":
+		d.tickers(w, p.Get("symbol"))
+	case "GET /v5/market/instruments-info":
+		d.instruments(w, p.Get("symbol"))
+	case "GET /v5/market/orderbook":
+		limit, _ := strconv.Atoi(p.Get("limit"))
+		bids, asks, err := d.e.Book(p.Get("symbol"), limit)
+		if err != nil {
+			d.engineError(w, err)
+			return
+		}
+		now := d.e.Now().UnixMilli()
+		d.ok(w, map[string]interface{}{"s": p.Get("symbol"), "b": levels(bids), "a": levels(asks), "ts": now, "u": now})
+	case "GET /v5/account/wallet-balance":
+		b := d.e.Balance()
+		d.ok(w, map[string]interface{}{"list": []map[string]interface{}{{
+			"accountType":           "UNIFIED",
+			"totalEquity":           ftoa(b.Equity),
+			"totalWalletBalance":    ftoa(b.Wallet),
+			"totalMarginBalance":    ftoa(b.Equity),
+			"totalAvailableBalance": ftoa(b.Available),
+			"totalPerpUPL":          ftoa(b.UnrealizedPnL),
+			"totalInitialMargin":    ftoa(b.InitialMargin),
+			"coin": []map[string]interface{}{{
+				"coin": "USDT", "equity": ftoa(b.Equity), "walletBalance": ftoa(b.Wallet), "unrealisedPnl": ftoa(b.UnrealizedPnL),
+			}},
+		}}})
+	case "GET /v5/position/list":
+		d.positions(w, p.Get("symbol"))
+	case "POST /v5/position/set-leverage":
+		symbol := p.Get("symbol")
+		leverage, _ := strconv.Atoi(p.Get("buyLeverage"))
+		if _, ok := d.e.Instrument(symbol); ok && d.e.Leverage(symbol) == leverage {
+			d.error(w, 110043, "leverage not modified")
+			return
+		}
+		if err := d.e.SetLeverage(symbol, leverage); err != nil {
+			d.engineError(w, err)
+			return
+		}
+		d.ok(w, map[string]interface{}{})
+	case "POST /v5/position/switch-isolated":
+		symbol, isolated := p.Get("symbol"), p.Get("tradeMode") == "1"
+		if _, ok := d.e.Instrument(symbol); ok && d.e.Isolated(symbol) == isolated {
+			d.error(w, 110026, "Cross/isolated margin mode is not modified")
+			return
+		}
+		if err := d.e.SetMarginMode(symbol, isolated); err != nil {
+			if errors.Is(err, ErrPositionExists) {
+				d.error(w, 110024, "You have an existing position, so position mode cannot be switched")
+			} else {
+				d.engineError(w, err)
+			}
+			return
+		}
+		d.ok(w, map[string]interface{}{})
+	case "POST /v5/position/switch-mode":
+		if err := d.e.SetHedgeMode(p.Get("mode") == "3"); err != nil {
+			d.error(w, 110025, "Position mode is not modified")
+			return
+		}
+		d.ok(w, map[string]interface{}{})
+	case "POST /v5/position/trading-stop":
+		d.tradingStop(w, p)
+	case "POST /v5/order/create":
+		d.createOrder(w, p)
+	case "POST /v5/order/cancel":
+		id, _ := strconv.ParseInt(p.Get("orderId"), 10, 64)
+		if id == 0 {
+			if o, err := d.e.OrderByClientID(p.Get("orderLinkId")); err == nil {
+				id = o.ID
+			}
+		}
+		o, err := d.e.CancelOrder(p.Get("symbol"), id)
+		if err != nil {
+			d.engineError(w, err)
+			return
+		}
+		d.ok(w, map[string]interface{}{"orderId": strconv.FormatInt(o.ID, 10), "orderLinkId": o.ClientID})
+	case "POST /v5/order/cancel-all":
+		var filter func(Order) bool
+		switch p.Get("orderFilter") {
+		case "Order":
+			filter = func(o Order) bool { return !o.IsConditional() }
+		case "StopOrder":
+			filter = Order.IsConditional
+		}
+		list := []map[string]interface{}{}
+		for _, o := range d.e.CancelOrders(p.Get("symbol"), filter) {
+			list = append(list, map[string]interface{}{"orderId": strconv.FormatInt(o.ID, 10), "orderLinkId": o.ClientID})
+		}
+		d.ok(w, map[string]interface{}{"list": list, "success": "1"})
+	case "GET /v5/order/realtime":
+		d.orders(w, p, true)
+	case "GET /v5/order/history":
+		d.orders(w, p, false)
+	case "GET /v5/execution/list":
+		d.executions(w, p)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *bybitDialect) tickers(w http.ResponseWriter, symbol string) {
+	var list []map[string]interface{}
+	for _, inst := range d.e.Instruments() {
+		if symbol != "" && inst.Symbol != symbol {
+			continue
+		}
+		price := ftoa(inst.Price)
+		list = append(list, map[string]interface{}{
+			"symbol": inst.Symbol, "lastPrice": price, "markPrice": price, "indexPrice": price,
+			"bid1Price": ftoa(inst.Price - inst.TickSize), "ask1Price": ftoa(inst.Price + inst.TickSize),
+		})
+	}
+	if len(list) == 0 {
+		d.error(w, 10001, "Not supported symbols")
+		return
+	}
+	d.ok(w, map[string]interface{}{"category": "linear", "list": list})
+}
+
+func (d *bybitDialect) instruments(w http.ResponseWriter, symbol string) {
+	list := []map[string]interface{}{}
+	for _, inst := range d.e.Instruments() {
+		if symbol != "" && inst.Symbol != symbol {
+			continue
+		}
+		list = append(list, map[string]interface{}{
+			"symbol": inst.Symbol, "contractType": "LinearPerpetual", "status": "Trading",
+			"baseCoin": inst.Symbol[:len(inst.Symbol)-4], "quoteCoin": "USDT", "settleCoin": "USDT",
+			"priceFilter":   map[string]interface{}{"tickSize": ftoa(inst.TickSize), "minPrice": ftoa(inst.TickSize), "maxPrice": "10000000"},
+			"lotSizeFilter": map[string]interface{}{"qtyStep": ftoa(inst.StepSize), "minOrderQty": ftoa(inst.MinQty), "maxOrderQty": "10000", "minNotionalValue": "5"},
+		})
+	}
+	d.ok(w, map[string]interface{}{"category": "linear", "list": list})
+}
+
+func (d *bybitDialect) positions(w http.ResponseWriter, symbol string) {
+	list := []map[string]interface{}{}
+	for _, pos := range d.e.Positions() {
+		if symbol != "" && pos.Symbol != symbol {
+			continue
+		}
+		price, _ := d.e.Price(pos.Symbol)
+		leverage := d.e.Leverage(pos.Symbol)
+		side, size := "Buy", pos.Amount
+		if pos.Amount < 0 {
+			side, size = "Sell", -pos.Amount
+		}
+		tradeMode := 0
+		if d.e.Isolated(pos.Symbol) {
+			tradeMode = 1
+		}
+		list = append(list, map[string]interface{}{
+			"symbol":        pos.Symbol,
+			"side":          side,
+			"size":          ftoa(size),
+			"avgPrice":      ftoa(pos.EntryPrice),
+			"markPrice":     ftoa(price),
+			"positionValue": ftoa(size * price),
+			"unrealisedPnl": ftoa(pos.Amount * (price - pos.EntryPrice)),
+			"leverage":      strconv.Itoa(leverage),
+			"liqPrice":      ftoa(liquidationPrice(pos, leverage)),
+			"tradeMode":     tradeMode,
+			"positionIdx":   bybitPositionIdx(pos.Side),
+			"createdTime":   strconv.FormatInt(pos.CreateTime.UnixMilli(), 10),
+			"updatedTime":   strconv.FormatInt(pos.UpdateTime.UnixMilli(), 10),
+		})
+	}
+	d.ok(w, map[string]interface{}{"category": "linear", "list": list})
+}
+
+// tradingStop sets the trailing stop of a position in full mode: a trailing order closing
+// the position, replaced on every call and removed by trailingStop "0"; position take-profit
+// and stop-loss are not emulated
+func (d *bybitDialect) tradingStop(w http.ResponseWriter, p url.Values) {
+	if p.Get("takeProfit") != "" || p.Get("stopLoss") != "" {
+		d.error(w, 10001, "params error: only trailingStop is supported")
+		return
+	}
+	symbol, side := p.Get("symbol"), SideBoth
+	switch p.Get("positionIdx") {
+	case "1":
+		side = SideLong
+	case "2":
+		side = SideShort
+	}
+	pos := d.e.Position(symbol, side)
+	if pos.Amount == 0 {
+		d.error(w, 10001, "can not set tp/sl/ts for zero position")
+		return
+	}
+	d.e.CancelOrders(symbol, func(o Order) bool {
+		return o.Type == OrderTypeTrailingStop && o.ClosePosition && o.PositionSide == side
+	})
+
+	distance := atof(p.Get("trailingStop"))
+	if distance > 0 {
+		closeSide := "SELL"
+		if pos.Amount < 0 {
+			closeSide = "BUY"
+		}
+		_, err := d.e.PlaceOrder(OrderRequest{
+			Symbol:           symbol,
+			Side:             closeSide,
+			PositionSide:     side,
+			Type:             OrderTypeTrailingStop,
+			ClosePosition:    true,
+			CallbackDistance: distance,
+			ActivationPrice:  atof(p.Get("activePrice")),
+		})
+		if err != nil {
+			d.engineError(w, err)
+			return
+		}
+	}
+	d.ok(w, map[string]interface{}{})
+}
+
+// createOrder places market and limit orders; a triggerPrice makes it a conditional
+// order, a stop when it triggers against the closing side and a take-profit otherwise
+func (d *bybitDialect) createOrder(w http.ResponseWriter, p url.Values) {
+	side := map[string]string{"Buy": "BUY", "Sell": "SELL"}[p.Get("side")]
+	positionSide := SideBoth
+	switch p.Get("positionIdx") {
+	case "1":
+		positionSide = SideLong
+	case "2":
+		positionSide = SideShort
+	}
+	req := OrderRequest{
+		ClientID:     p.Get("orderLinkId"),
+		Symbol:       p.Get("symbol"),
+		Side:         side,
+		PositionSide: positionSide,
+		Type:         map[string]string{"Market": OrderTypeMarket, "Limit": OrderTypeLimit}[p.Get("orderType")],
+		Price:        atof(p.Get("price")),
+		Quantity:     atof(p.Get("qty")),
+		ReduceOnly:   p.Get("reduceOnly") == "true",
+	}
+	if trigger := atof(p.Get("triggerPrice")); trigger > 0 {
+		if req.Type != OrderTypeMarket {
+			d.error(w, 10001, "params error: only conditional market orders are supported")
+			return
+		}
+		rise := p.Get("triggerDirection") == "1"
+		req.TriggerPrice, req.TriggerAbove = trigger, &rise
+		req.Type = OrderTypeTakeProfit
+		if rise == (side == "BUY") {
+			req.Type = OrderTypeStopMarket
+		}
+	}
+	o, err := d.e.PlaceOrder(req)
+	if err != nil {
+		d.engineError(w, err)
+		return
+	}
+	d.ok(w, map[string]interface{}{"orderId": strconv.FormatInt(o.ID, 10), "orderLinkId": o.ClientID})
+}
+
+// orders lists orders; realtime only working ones, narrowed by orderFilter (Order or
+// StopOrder), history all of them
+func (d *bybitDialect) orders(w http.ResponseWriter, p url.Values, realtime bool) {
+	id, _ := strconv.ParseInt(p.Get("orderId"), 10, 64)
+	filter := p.Get("orderFilter")
+	orders := d.e.Orders(func(o Order) bool {
+		switch {
+		case p.Get("symbol") != "" && o.Symbol != p.Get("symbol"):
+			return false
+		case id != 0 && o.ID != id:
+			return false
+		case realtime && !o.IsOpen():
+			return false
+		case filter == "Order" && o.IsConditional(), filter == "StopOrder" && !o.IsConditional():
+			return false
+		}
+		return true
+	})
+	list := []map[string]interface{}{}
+	for i := len(orders) - 1; i >= 0; i-- {
+		list = append(list, bybitOrder(orders[i]))
+	}
+	d.ok(w, map[string]interface{}{"category": "linear", "list": list, "nextPageCursor": ""})
+}
+
+func (d *bybitDialect) executions(w http.ResponseWriter, p url.Values) {
+	list := []map[string]interface{}{}
+	fills := limitFills(d.e.Fills(p.Get("symbol"), msTime(p.Get("startTime"))), p.Get("limit"), 50)
+	for i := len(fills) - 1; i >= 0; i-- {
+		f := fills[i]
+		o, _ := d.e.Order(f.OrderID)
+		closedSize := "0"
+		if f.RealizedPnL != 0 || o.ReduceOnly {
+			closedSize = ftoa(f.Quantity)
+		}
+		list = append(list, map[string]interface{}{
+			"symbol":      f.Symbol,
+			"orderId":     strconv.FormatInt(f.OrderID, 10),
+			"orderLinkId": o.ClientID,
+			"execId":      strconv.FormatInt(f.ID, 10),
+			"side":        bybitSide(f.Side),
+			"orderType":   bybitOrderType(o.Type),
+			"isMaker":     f.Maker,
+			"execPrice":   ftoa(f.Price),
+			"execQty":     ftoa(f.Quantity),
+			"execValue":   ftoa(f.Price * f.Quantity),
+			"execFee":     ftoa(f.Fee),
+			"feeRate":     ftoa(f.Fee / (f.Price * f.Quantity)),
+			"closedSize":  closedSize,
+			"closedPnl":   ftoa(f.RealizedPnL),
+			"execType":    "Trade",
+			"execTime":    strconv.FormatInt(f.Time.UnixMilli(), 10),
+		})
+	}
+	d.ok(w, map[string]interface{}{"category": "linear", "list": list, "nextPageCursor": ""})
+}
+
+func bybitOrder(o Order) map[string]interface{} {
+	status := map[string]string{
+		types.OrderStatusNew:             "New",
+		types.OrderStatusPartiallyFilled: "PartiallyFilled",
+		types.OrderStatusFilled:          "Filled",
+		types.OrderStatusCanceled:        "Cancelled",
+		types.OrderStatusExpired:         "Cancelled",
+	}[o.Status]
+	stopOrderType := ""
+	if o.IsConditional() {
+		// Plain conditional orders are reported as "Stop" whichever way they trigger
+		stopOrderType = "Stop"
+		if o.Type == OrderTypeTrailingStop {
+			stopOrderType = "TrailingStop"
+		}
+		switch {
+		case o.Status == types.OrderStatusNew && !o.Triggered:
+			status = "Untriggered"
+		case o.Status == types.OrderStatusCanceled || o.Status == types.OrderStatusExpired:
+			status = "Deactivated"
+		}
+	}
+	triggerDirection := 0
+	if o.IsConditional() {
+		triggerDirection = 2
+		if o.TriggerAbove {
+			triggerDirection = 1
+		}
+	}
+	return map[string]interface{}{
+		"orderId":          strconv.FormatInt(o.ID, 10),
+		"orderLinkId":      o.ClientID,
+		"symbol":           o.Symbol,
+		"side":             bybitSide(o.Side),
+		"orderType":        bybitOrderType(o.Type),
+		"stopOrderType":    stopOrderType,
+		"orderStatus":      status,
+		"price":            ftoa(o.Price),
+		"qty":              ftoa(o.Quantity),
+		"avgPrice":         ftoa(o.AvgPrice),
+		"cumExecQty":       ftoa(o.ExecutedQty),
+		"cumExecValue":     ftoa(o.ExecutedQty * o.AvgPrice),
+		"cumExecFee":       ftoa(o.Fee),
+		"leavesQty":        ftoa(o.Quantity - o.ExecutedQty),
+		"triggerPrice":     ftoa(o.TriggerPrice),
+		"triggerDirection": triggerDirection,
+		"triggerBy":        "LastPrice",
+		"reduceOnly":       o.ReduceOnly,
+		"closeOnTrigger":   o.ClosePosition,
+		"positionIdx":      bybitPositionIdx(o.PositionSide),
+		"timeInForce":      "GTC",
+		"createdTime":      strconv.FormatInt(o.CreateTime.UnixMilli(), 10),
+		"updatedTime":      strconv.FormatInt(o.UpdateTime.UnixMilli(), 10),
+	}
+}
+
+func bybitSide(side string) string {
+	if side == "BUY" {
+		return "Buy"
+	}
+	return "Sell"
+}
+
+func bybitOrderType(orderType string) string {
+	if orderType == OrderTypeLimit {
+		return "Limit"
+	}
+	return "Market"
+}
+
+func bybitPositionIdx(side string) int {
+	switch side {
+	case SideLong:
+		return 1
+	case SideShort:
+		return 2
+	}
+	return 0
+}
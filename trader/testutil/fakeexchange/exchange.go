@@ -0,0 +1,900 @@
+// Package fakeexchange is an in-memory futures exchange served over httptest in the
+// REST dialects of Binance Futures, Bybit v5 and OKX v5, so that adapters can be tested
+// end to end without credentials or network.
+//
+// All dialect servers share one Exchange: a USDT-margined account with positions, an
+// order book of working orders and the resulting fills. Prices only move when the test
+// calls SetPrice, which also matches resting limit orders, moves trailing stops and
+// triggers stop orders.
+// Faults (rate limits, 5xx errors, partial fills, clock skew) are injected on the Exchange
+// and rendered by each server in its venue's wire format.
+package fakeexchange
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/trader/types"
+)
+
+// Order types
+const (
+	OrderTypeMarket     = "MARKET"
+	OrderTypeLimit      = "LIMIT"
+	OrderTypeStopMarket = "STOP_MARKET"        // Stop-loss, triggers against the position
+	OrderTypeTakeProfit = "TAKE_PROFIT_MARKET" // Take-profit, triggers in favour of the position
+	// Trailing stop, triggers once the price retraces the callback from its best price
+	OrderTypeTrailingStop = "TRAILING_STOP_MARKET"
+)
+
+// Position sides, BOTH is the net position of one-way mode
+const (
+	SideLong  = "LONG"
+	SideShort = "SHORT"
+	SideBoth  = "BOTH"
+)
+
+// Errors returned by the matching engine, rendered by each dialect as its venue error code
+var (
+	ErrUnknownSymbol      = errors.New("unknown symbol")
+	ErrUnknownOrder       = errors.New("order does not exist")
+	ErrInvalidQuantity    = errors.New("invalid quantity")
+	ErrInvalidPrice       = errors.New("invalid price")
+	ErrPositionSide       = errors.New("position side does not match position mode")
+	ErrReduceOnly         = errors.New("reduce-only order would not reduce the position")
+	ErrInsufficientMargin = errors.New("margin is insufficient")
+	ErrPositionExists     = errors.New("not allowed while positions or orders are open")
+)
+
+// Instrument a tradable USDT perpetual
+type Instrument struct {
+	Symbol   string  // Generic symbol, e.g. BTCUSDT
+	Price    float64 // Last/mark price
+	TickSize float64 // Price increment
+	StepSize float64 // Quantity increment in base asset
+	MinQty   float64 // Minimum order quantity in base asset
+	CtVal    float64 // Base asset per contract, for venues trading in contracts (OKX)
+}
+
+// Order an order as held by the exchange; quantities are in base asset
+type Order struct {
+	ID            int64
+	ClientID      string
+	Symbol        string
+	Side          string // BUY or SELL
+	PositionSide  string // LONG, SHORT or BOTH
+	Type          string // See OrderType*
+	Price         float64
+	TriggerPrice  float64
+	TriggerAbove  bool // Trigger when the price rises to TriggerPrice (else when it falls to it)
+	Quantity      float64
+	ClosePosition bool // Close the whole position on trigger, Quantity is ignored
+	ReduceOnly    bool
+	// Trailing stops trail by CallbackRate percent of the best price, or by the fixed
+	// CallbackDistance if set, once the price reached ActivationPrice (at once if 0)
+	CallbackRate     float64
+	CallbackDistance float64
+	ActivationPrice  float64
+	BestPrice        float64 // Best price since activation, 0 while not activated
+	Status           string  // See types.OrderStatus*
+	Triggered        bool
+	ExecutedQty      float64
+	AvgPrice         float64
+	Fee              float64
+	RealizedPnL      float64
+	CreateTime       time.Time
+	UpdateTime       time.Time
+}
+
+// IsConditional whether the order waits for a trigger price
+func (o Order) IsConditional() bool {
+	return o.Type == OrderTypeStopMarket || o.Type == OrderTypeTakeProfit || o.Type == OrderTypeTrailingStop
+}
+
+// trail activates a trailing stop and moves its trigger after price; returns whether it
+// is active
+func (o *Order) trail(price float64) bool {
+	sell := o.Side == "SELL"
+	if o.BestPrice == 0 {
+		if o.ActivationPrice > 0 && ((sell && price < o.ActivationPrice) || (!sell && price > o.ActivationPrice)) {
+			return false
+		}
+		o.BestPrice = price
+	} else if (sell && price > o.BestPrice) || (!sell && price < o.BestPrice) {
+		o.BestPrice = price
+	}
+	distance := o.CallbackDistance
+	if distance <= 0 {
+		distance = o.BestPrice * o.CallbackRate / 100
+	}
+	if sell {
+		o.TriggerPrice = o.BestPrice - distance
+	} else {
+		o.TriggerPrice = o.BestPrice + distance
+	}
+	return true
+}
+
+// IsOpen whether the order is still working (including untriggered conditional orders)
+func (o Order) IsOpen() bool {
+	return o.Status == types.OrderStatusNew || o.Status == types.OrderStatusPartiallyFilled
+}
+
+// Fill an execution of (part of) an order
+type Fill struct {
+	ID           int64
+	OrderID      int64
+	Symbol       string
+	Side         string
+	PositionSide string
+	Price        float64
+	Quantity     float64
+	Fee          float64 // Positive fee paid in USDT
+	RealizedPnL  float64 // Gross PnL of the closed part, excluding the fee
+	Maker        bool
+	Time         time.Time
+}
+
+// Position an open position; Amount is signed (negative for short)
+type Position struct {
+	Symbol     string
+	Side       string // LONG, SHORT or BOTH
+	Amount     float64
+	EntryPrice float64
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+// OrderRequest a new order as decoded by a dialect
+type OrderRequest struct {
+	ClientID      string
+	Symbol        string
+	Side          string // BUY or SELL
+	PositionSide  string // LONG, SHORT, BOTH or empty for BOTH
+	Type          string
+	Price         float64
+	TriggerPrice  float64
+	TriggerAbove  *bool // nil derives the direction from type and side
+	Quantity      float64
+	ClosePosition bool
+	ReduceOnly    bool
+	// Trailing stops only, see Order
+	CallbackRate     float64
+	CallbackDistance float64
+	ActivationPrice  float64
+}
+
+// Balance account balance in USDT
+type Balance struct {
+	Wallet        float64 // Deposits plus realized PnL minus fees
+	UnrealizedPnL float64
+	Equity        float64
+	InitialMargin float64 // Margin of open positions at their leverage
+	Available     float64
+}
+
+// Request a request received by one of the servers
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+}
+
+// Exchange in-memory exchange state shared by the dialect servers
+type Exchange struct {
+	mu sync.Mutex
+
+	instruments map[string]*Instrument
+	leverage    map[string]int
+	isolated    map[string]bool
+	hedgeMode   bool
+
+	initialBalance float64
+	wallet         float64
+	positions      map[string]*Position // symbol + "/" + side
+	orders         []*Order
+	fills          []Fill
+	nextID         int64
+
+	makerFee float64
+	takerFee float64
+
+	faults      []*fault
+	partialFill float64
+	clockSkew   time.Duration
+	requests    []Request
+}
+
+// New creates an exchange with BTCUSDT at 50000 and ETHUSDT at 3000, 10000 USDT
+// of balance, one-way position mode and Binance's default fee rates
+func New() *Exchange {
+	e := &Exchange{
+		instruments:    make(map[string]*Instrument),
+		leverage:       make(map[string]int),
+		isolated:       make(map[string]bool),
+		initialBalance: 10000,
+		wallet:         10000,
+		positions:      make(map[string]*Position),
+		nextID:         1000,
+		makerFee:       0.0002,
+		takerFee:       0.0005,
+	}
+	e.AddInstrument(Instrument{Symbol: "BTCUSDT", Price: 50000, TickSize: 0.1, StepSize: 0.001, MinQty: 0.001, CtVal: 0.01})
+	e.AddInstrument(Instrument{Symbol: "ETHUSDT", Price: 3000, TickSize: 0.01, StepSize: 0.001, MinQty: 0.001, CtVal: 0.1})
+	return e
+}
+
+// AddInstrument adds or replaces an instrument
+func (e *Exchange) AddInstrument(inst Instrument) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if inst.CtVal == 0 {
+		inst.CtVal = 1
+	}
+	e.instruments[inst.Symbol] = &inst
+}
+
+// Instrument returns the instrument of a symbol
+func (e *Exchange) Instrument(symbol string) (Instrument, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	inst, ok := e.instruments[symbol]
+	if !ok {
+		return Instrument{}, false
+	}
+	return *inst, true
+}
+
+// Instruments returns all instruments sorted by symbol
+func (e *Exchange) Instruments() []Instrument {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var result []Instrument
+	for _, inst := range e.instruments {
+		result = append(result, *inst)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Symbol < result[j].Symbol })
+	return result
+}
+
+// SetFees sets the maker and taker fee rates
+func (e *Exchange) SetFees(maker, taker float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.makerFee, e.takerFee = maker, taker
+}
+
+// SetBalance sets the wallet balance, also restored by Reset
+func (e *Exchange) SetBalance(wallet float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.initialBalance, e.wallet = wallet, wallet
+}
+
+// Reset clears positions, orders, fills, faults and the request log and restores the
+// wallet balance; instruments, prices, leverage and position mode are kept
+func (e *Exchange) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.wallet = e.initialBalance
+	e.positions = make(map[string]*Position)
+	e.orders = nil
+	e.fills = nil
+	e.faults = nil
+	e.partialFill = 0
+	e.clockSkew = 0
+	e.requests = nil
+}
+
+// SuiteSetup returns a testutil.TraderTestSuite BeforeEach hook that seeds the state the
+// stateless suite assumes: the query tests find a 0.01 BTCUSDT long, the close tests a
+// 0.5 BTCUSDT position of their side and nothing else. Other tests run against the
+// accumulated state.
+func (e *Exchange) SuiteSetup() func(name string) {
+	return func(name string) {
+		switch name {
+		case "GetBalance":
+			e.Reset()
+			e.SetPosition("BTCUSDT", SideLong, 0.01, 50000)
+		case "CloseLong":
+			e.Reset()
+			e.SetPosition("BTCUSDT", SideLong, 0.5, 50000)
+		case "CloseShort":
+			e.Reset()
+			e.SetPosition("BTCUSDT", SideShort, 0.5, 50000)
+		}
+	}
+}
+
+// SuiteSeed returns a testutil.TraderTestSuite SeedAccount hook that resets the exchange to
+// the wallet balance (also restored by later resets) and the position
+func (e *Exchange) SuiteSeed() func(wallet float64, pos types.Position) {
+	return func(wallet float64, pos types.Position) {
+		e.SetBalance(wallet)
+		e.Reset()
+		e.SetPosition(pos.Symbol, strings.ToUpper(pos.Side), pos.Quantity, pos.EntryPrice)
+	}
+}
+
+// Now returns the exchange clock, including the injected skew
+func (e *Exchange) Now() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().Add(e.clockSkew)
+}
+
+// ============================================================
+// Account configuration
+// ============================================================
+
+// HedgeMode whether positions are held per side (LONG/SHORT) rather than net (BOTH)
+func (e *Exchange) HedgeMode() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.hedgeMode
+}
+
+// SetHedgeMode switches the position mode; like the venues, not while anything is open
+func (e *Exchange) SetHedgeMode(hedge bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if hedge == e.hedgeMode {
+		return nil
+	}
+	if len(e.positions) > 0 || e.hasOpenOrders("") {
+		return ErrPositionExists
+	}
+	e.hedgeMode = hedge
+	return nil
+}
+
+// Leverage returns the leverage of a symbol (20 unless set)
+func (e *Exchange) Leverage(symbol string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leverageOf(symbol)
+}
+
+func (e *Exchange) leverageOf(symbol string) int {
+	if lev, ok := e.leverage[symbol]; ok {
+		return lev
+	}
+	return 20
+}
+
+// SetLeverage sets the leverage of a symbol
+func (e *Exchange) SetLeverage(symbol string, leverage int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.instruments[symbol]; !ok {
+		return ErrUnknownSymbol
+	}
+	if leverage < 1 || leverage > 125 {
+		return fmt.Errorf("leverage %d out of range", leverage)
+	}
+	e.leverage[symbol] = leverage
+	return nil
+}
+
+// Isolated whether a symbol uses isolated margin
+func (e *Exchange) Isolated(symbol string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.isolated[symbol]
+}
+
+// SetMarginMode sets the margin mode of a symbol; not allowed with an open position
+func (e *Exchange) SetMarginMode(symbol string, isolated bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.instruments[symbol]; !ok {
+		return ErrUnknownSymbol
+	}
+	if e.isolated[symbol] == isolated {
+		return nil
+	}
+	for _, pos := range e.positions {
+		if pos.Symbol == symbol {
+			return ErrPositionExists
+		}
+	}
+	e.isolated[symbol] = isolated
+	return nil
+}
+
+// ============================================================
+// Market
+// ============================================================
+
+// Price returns the current price of a symbol
+func (e *Exchange) Price(symbol string) (float64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	inst, ok := e.instruments[symbol]
+	if !ok {
+		return 0, false
+	}
+	return inst.Price, true
+}
+
+// SetPrice moves the price of a symbol and matches working orders against it: triggered
+// stop orders and remaining market quantity execute at the new price, crossed limit
+// orders at their limit price
+func (e *Exchange) SetPrice(symbol string, price float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	inst, ok := e.instruments[symbol]
+	if !ok {
+		return
+	}
+	inst.Price = price
+
+	for _, o := range e.orders {
+		if o.Symbol != symbol || !o.IsOpen() {
+			continue
+		}
+		switch {
+		case o.IsConditional() && !o.Triggered:
+			if o.Type == OrderTypeTrailingStop && !o.trail(price) {
+				continue // Not activated yet
+			}
+			if (o.TriggerAbove && price >= o.TriggerPrice) || (!o.TriggerAbove && price <= o.TriggerPrice) {
+				o.Triggered = true
+				e.execute(o, price, false, false)
+			}
+		case o.Type == OrderTypeLimit:
+			if (o.Side == "BUY" && price <= o.Price) || (o.Side == "SELL" && price >= o.Price) {
+				e.execute(o, o.Price, true, false)
+			}
+		default:
+			// Market or triggered order waiting for its remainder (partial fill scenario)
+			e.execute(o, price, false, false)
+		}
+	}
+}
+
+// Book returns depth levels around the price: the account's resting limit orders on top
+// of a synthetic ladder of 100 minimum quantities per tick
+func (e *Exchange) Book(symbol string, depth int) (bids, asks [][2]float64, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	inst, ok := e.instruments[symbol]
+	if !ok {
+		return nil, nil, ErrUnknownSymbol
+	}
+	if depth <= 0 {
+		depth = 5
+	}
+
+	own := make(map[float64]float64)
+	for _, o := range e.orders {
+		if o.Symbol == symbol && o.Type == OrderTypeLimit && o.IsOpen() {
+			own[roundTo(o.Price, inst.TickSize)] += o.Quantity - o.ExecutedQty
+		}
+	}
+	for i := 1; i <= depth; i++ {
+		bid := roundTo(inst.Price-float64(i)*inst.TickSize, inst.TickSize)
+		ask := roundTo(inst.Price+float64(i)*inst.TickSize, inst.TickSize)
+		bids = append(bids, [2]float64{bid, 100*inst.MinQty + own[bid]})
+		asks = append(asks, [2]float64{ask, 100*inst.MinQty + own[ask]})
+	}
+	return bids, asks, nil
+}
+
+// ============================================================
+// Account state
+// ============================================================
+
+// Balance returns the account balance at current prices
+func (e *Exchange) Balance() Balance {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.balance()
+}
+
+func (e *Exchange) balance() Balance {
+	b := Balance{Wallet: e.wallet}
+	for _, pos := range e.positions {
+		price := e.instruments[pos.Symbol].Price
+		b.UnrealizedPnL += pos.Amount * (price - pos.EntryPrice)
+		b.InitialMargin += math.Abs(pos.Amount) * price / float64(e.leverageOf(pos.Symbol))
+	}
+	b.Equity = b.Wallet + b.UnrealizedPnL
+	b.Available = math.Max(0, b.Equity-b.InitialMargin)
+	return b
+}
+
+// Positions returns the open positions sorted by symbol and side
+func (e *Exchange) Positions() []Position {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var result []Position
+	for _, pos := range e.positions {
+		result = append(result, *pos)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Symbol != result[j].Symbol {
+			return result[i].Symbol < result[j].Symbol
+		}
+		return result[i].Side < result[j].Side
+	})
+	return result
+}
+
+// Position returns the position of a symbol on a side (LONG/SHORT, or BOTH for the
+// net position in one-way mode); the zero Position if there is none
+func (e *Exchange) Position(symbol, side string) Position {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if pos := e.positions[positionKey(symbol, side)]; pos != nil {
+		return *pos
+	}
+	return Position{Symbol: symbol, Side: side}
+}
+
+// SetPosition seeds a position of quantity (base asset, 0 removes it) at an entry price;
+// side is LONG or SHORT and is stored as the net BOTH position in one-way mode
+func (e *Exchange) SetPosition(symbol, side string, quantity, entryPrice float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	amount := math.Abs(quantity)
+	if side == SideShort {
+		amount = -amount
+	}
+	if !e.hedgeMode {
+		side = SideBoth
+	}
+	key := positionKey(symbol, side)
+	if amount == 0 {
+		delete(e.positions, key)
+		return
+	}
+	now := time.Now()
+	e.positions[key] = &Position{Symbol: symbol, Side: side, Amount: amount, EntryPrice: entryPrice, CreateTime: now, UpdateTime: now}
+}
+
+// Orders returns the orders matching filter (all if nil) in placement order
+func (e *Exchange) Orders(filter func(Order) bool) []Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var result []Order
+	for _, o := range e.orders {
+		if filter == nil || filter(*o) {
+			result = append(result, *o)
+		}
+	}
+	return result
+}
+
+// Order returns an order by ID
+func (e *Exchange) Order(id int64) (Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if o := e.findOrder(id); o != nil {
+		return *o, nil
+	}
+	return Order{}, ErrUnknownOrder
+}
+
+// OrderByClientID returns an order by client order ID
+func (e *Exchange) OrderByClientID(clientID string) (Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, o := range e.orders {
+		if clientID != "" && o.ClientID == clientID {
+			return *o, nil
+		}
+	}
+	return Order{}, ErrUnknownOrder
+}
+
+// Fills returns the fills of a symbol (all if empty) since a time, oldest first
+func (e *Exchange) Fills(symbol string, since time.Time) []Fill {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var result []Fill
+	for _, f := range e.fills {
+		if (symbol == "" || f.Symbol == symbol) && !f.Time.Before(since) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// ============================================================
+// Trading
+// ============================================================
+
+// PlaceOrder validates and accepts an order; market and marketable limit orders
+// execute immediately (in part under the partial fill scenario)
+func (e *Exchange) PlaceOrder(req OrderRequest) (Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	inst, ok := e.instruments[req.Symbol]
+	if !ok {
+		return Order{}, ErrUnknownSymbol
+	}
+	if req.Side != "BUY" && req.Side != "SELL" {
+		return Order{}, fmt.Errorf("invalid side %q", req.Side)
+	}
+	if req.PositionSide == "" {
+		req.PositionSide = SideBoth
+	}
+	if e.hedgeMode == (req.PositionSide == SideBoth) {
+		return Order{}, ErrPositionSide
+	}
+	if !req.ClosePosition && (req.Quantity < inst.MinQty || req.Quantity <= 0) {
+		return Order{}, ErrInvalidQuantity
+	}
+
+	now := time.Now()
+	o := &Order{
+		ClientID:      req.ClientID,
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		PositionSide:  req.PositionSide,
+		Type:          req.Type,
+		Price:         req.Price,
+		TriggerPrice:  req.TriggerPrice,
+		Quantity:      req.Quantity,
+		ClosePosition: req.ClosePosition,
+		ReduceOnly:    req.ReduceOnly || req.ClosePosition,
+		Status:        types.OrderStatusNew,
+		CreateTime:    now,
+		UpdateTime:    now,
+	}
+
+	switch req.Type {
+	case OrderTypeMarket:
+		if e.reducible(o) == 0 && e.reducing(o) {
+			return Order{}, ErrReduceOnly
+		}
+		if err := e.checkMargin(o, inst.Price); err != nil {
+			return Order{}, err
+		}
+	case OrderTypeLimit:
+		if req.Price <= 0 {
+			return Order{}, ErrInvalidPrice
+		}
+		if err := e.checkMargin(o, req.Price); err != nil {
+			return Order{}, err
+		}
+	case OrderTypeStopMarket, OrderTypeTakeProfit:
+		if req.TriggerPrice <= 0 {
+			return Order{}, ErrInvalidPrice
+		}
+		if req.TriggerAbove != nil {
+			o.TriggerAbove = *req.TriggerAbove
+		} else {
+			// Buy stops trigger on the way up, buy take-profits on the way down
+			o.TriggerAbove = (req.Side == "BUY") == (req.Type == OrderTypeStopMarket)
+		}
+	case OrderTypeTrailingStop:
+		if req.CallbackRate <= 0 && req.CallbackDistance <= 0 {
+			return Order{}, ErrInvalidPrice
+		}
+		o.CallbackRate, o.CallbackDistance, o.ActivationPrice = req.CallbackRate, req.CallbackDistance, req.ActivationPrice
+		o.TriggerAbove = req.Side == "BUY"
+		o.trail(inst.Price)
+	default:
+		return Order{}, fmt.Errorf("unsupported order type %q", req.Type)
+	}
+
+	e.nextID++
+	o.ID = e.nextID
+	e.orders = append(e.orders, o)
+
+	switch {
+	case req.Type == OrderTypeMarket:
+		e.execute(o, inst.Price, false, true)
+	case req.Type == OrderTypeLimit && ((o.Side == "BUY" && inst.Price <= o.Price) || (o.Side == "SELL" && inst.Price >= o.Price)):
+		// Marketable limit order takes the current price
+		e.execute(o, inst.Price, false, true)
+	}
+	return *o, nil
+}
+
+// CancelOrder cancels a working order
+func (e *Exchange) CancelOrder(symbol string, id int64) (Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o := e.findOrder(id)
+	if o == nil || o.Symbol != symbol || !o.IsOpen() {
+		return Order{}, ErrUnknownOrder
+	}
+	o.Status = types.OrderStatusCanceled
+	o.UpdateTime = time.Now()
+	return *o, nil
+}
+
+// CancelOrders cancels the working orders of a symbol matching filter (all if nil)
+// and returns them
+func (e *Exchange) CancelOrders(symbol string, filter func(Order) bool) []Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var canceled []Order
+	for _, o := range e.orders {
+		if o.Symbol != symbol || !o.IsOpen() || (filter != nil && !filter(*o)) {
+			continue
+		}
+		o.Status = types.OrderStatusCanceled
+		o.UpdateTime = time.Now()
+		canceled = append(canceled, *o)
+	}
+	return canceled
+}
+
+func (e *Exchange) findOrder(id int64) *Order {
+	for _, o := range e.orders {
+		if o.ID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+func (e *Exchange) hasOpenOrders(symbol string) bool {
+	for _, o := range e.orders {
+		if o.IsOpen() && (symbol == "" || o.Symbol == symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// reducing whether the order trades against its position side: always for reduce-only
+// orders, and for sells of LONG and buys of SHORT in hedge mode
+func (e *Exchange) reducing(o *Order) bool {
+	if o.ReduceOnly {
+		return true
+	}
+	return (o.PositionSide == SideLong && o.Side == "SELL") || (o.PositionSide == SideShort && o.Side == "BUY")
+}
+
+// reducible quantity of the position the order can close
+func (e *Exchange) reducible(o *Order) float64 {
+	pos := e.positions[positionKey(o.Symbol, o.PositionSide)]
+	if pos == nil {
+		return 0
+	}
+	if (pos.Amount > 0 && o.Side == "SELL") || (pos.Amount < 0 && o.Side == "BUY") {
+		return math.Abs(pos.Amount)
+	}
+	return 0
+}
+
+// checkMargin rejects opening orders whose initial margin exceeds the available balance
+func (e *Exchange) checkMargin(o *Order, price float64) error {
+	if e.reducing(o) {
+		return nil
+	}
+	margin := o.Quantity * price / float64(e.leverageOf(o.Symbol))
+	if margin > e.balance().Available {
+		return ErrInsufficientMargin
+	}
+	return nil
+}
+
+// execute fills the remaining quantity of o at price (a fraction of it when the partial
+// fill scenario is on and the order is new); reduce-only quantity is capped at the
+// position, orders with nothing left to reduce expire
+func (e *Exchange) execute(o *Order, price float64, maker, initial bool) {
+	inst := e.instruments[o.Symbol]
+	qty := o.Quantity - o.ExecutedQty
+	if o.ClosePosition {
+		qty = e.reducible(o)
+	} else if e.reducing(o) {
+		qty = math.Min(qty, e.reducible(o))
+	}
+	if qty <= 0 {
+		o.Status = types.OrderStatusExpired
+		o.UpdateTime = time.Now()
+		return
+	}
+	if initial && e.partialFill > 0 && e.partialFill < 1 {
+		qty = math.Floor(qty*e.partialFill/inst.StepSize) * inst.StepSize
+		if qty <= 0 {
+			return
+		}
+	}
+
+	now := time.Now()
+	delta := qty
+	if o.Side == "SELL" {
+		delta = -qty
+	}
+	pnl := e.applyToPosition(o.Symbol, o.PositionSide, delta, price, now)
+	feeRate := e.takerFee
+	if maker {
+		feeRate = e.makerFee
+	}
+	fee := qty * price * feeRate
+	e.wallet += pnl - fee
+
+	e.nextID++
+	e.fills = append(e.fills, Fill{
+		ID:           e.nextID,
+		OrderID:      o.ID,
+		Symbol:       o.Symbol,
+		Side:         o.Side,
+		PositionSide: o.PositionSide,
+		Price:        price,
+		Quantity:     qty,
+		Fee:          fee,
+		RealizedPnL:  pnl,
+		Maker:        maker,
+		Time:         now,
+	})
+
+	o.AvgPrice = (o.AvgPrice*o.ExecutedQty + price*qty) / (o.ExecutedQty + qty)
+	o.ExecutedQty += qty
+	o.Fee += fee
+	o.RealizedPnL += pnl
+	o.UpdateTime = now
+	if o.ClosePosition || o.ExecutedQty >= o.Quantity-inst.StepSize/2 {
+		o.Status = types.OrderStatusFilled
+	} else {
+		o.Status = types.OrderStatusPartiallyFilled
+	}
+}
+
+// applyToPosition adds a signed quantity to a position and returns the realized PnL;
+// a net position crossing zero reopens on the other side at the fill price
+func (e *Exchange) applyToPosition(symbol, side string, delta, price float64, now time.Time) float64 {
+	key := positionKey(symbol, side)
+	pos := e.positions[key]
+	if pos == nil {
+		pos = &Position{Symbol: symbol, Side: side, CreateTime: now}
+		e.positions[key] = pos
+	}
+	pos.UpdateTime = now
+
+	var pnl float64
+	if pos.Amount == 0 || (pos.Amount > 0) == (delta > 0) {
+		pos.EntryPrice = (math.Abs(pos.Amount)*pos.EntryPrice + math.Abs(delta)*price) / (math.Abs(pos.Amount) + math.Abs(delta))
+		pos.Amount += delta
+	} else {
+		closed := math.Min(math.Abs(delta), math.Abs(pos.Amount))
+		if pos.Amount > 0 {
+			pnl = closed * (price - pos.EntryPrice)
+		} else {
+			pnl = closed * (pos.EntryPrice - price)
+		}
+		pos.Amount += delta
+		if math.Abs(pos.Amount) < 1e-12 {
+			delete(e.positions, key)
+		} else if (pos.Amount > 0) == (delta > 0) {
+			pos.EntryPrice = price
+			pos.CreateTime = now
+		}
+	}
+	return pnl
+}
+
+func positionKey(symbol, side string) string {
+	return symbol + "/" + side
+}
+
+func roundTo(value, step float64) float64 {
+	if step <= 0 {
+		return value
+	}
+	return math.Round(value/step) * step
+}
+
+// formatStep formats a value with the decimals of step, as venues print prices and sizes
+func formatStep(value, step float64) string {
+	decimals := 0
+	if s := fmt.Sprintf("%g", step); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	} else if strings.Contains(s, "e-") {
+		fmt.Sscanf(s[strings.Index(s, "e-")+2:], "%d", &decimals)
+	}
+	return fmt.Sprintf("%.*f", decimals, value)
+}
@@ -0,0 +1,192 @@
+package fakeexchange
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"nofx/trader/types"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestHedgeModeRoundTrip(t *testing.T) {
+	e := New()
+	if err := e.SetHedgeMode(true); err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", PositionSide: SideLong, Type: OrderTypeMarket, Quantity: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open.Status != types.OrderStatusFilled || open.AvgPrice != 50000 || !approx(open.Fee, 2.5) {
+		t.Fatalf("unexpected open order: %+v", open)
+	}
+	if _, err := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", PositionSide: SideShort, Type: OrderTypeMarket, Quantity: 0.1}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(e.Positions()); n != 2 {
+		t.Fatalf("got %d positions, want long and short", n)
+	}
+
+	e.SetPrice("BTCUSDT", 51000)
+	closeLong, err := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", PositionSide: SideLong, Type: OrderTypeMarket, Quantity: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approx(closeLong.RealizedPnL, 100) {
+		t.Errorf("long closed at +1000 on 0.1 BTC, got PnL %v", closeLong.RealizedPnL)
+	}
+	if pos := e.Position("BTCUSDT", SideLong); pos.Amount != 0 {
+		t.Errorf("long not closed: %+v", pos)
+	}
+
+	// 10000 + 100 PnL - fees of 2.5 (open long) + 2.5 (open short) + 2.55 (close long)
+	b := e.Balance()
+	if !approx(b.Wallet, 10000+100-7.55) || !approx(b.UnrealizedPnL, -100) {
+		t.Errorf("unexpected balance: %+v", b)
+	}
+	if fills := e.Fills("BTCUSDT", time.Time{}); len(fills) != 3 {
+		t.Errorf("got %d fills, want 3", len(fills))
+	}
+
+	_, err = e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", PositionSide: SideLong, Type: OrderTypeMarket, Quantity: 0.1})
+	if !errors.Is(err, ErrReduceOnly) {
+		t.Errorf("closing a flat long: got %v, want ErrReduceOnly", err)
+	}
+	_, err = e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket, Quantity: 0.1})
+	if !errors.Is(err, ErrPositionSide) {
+		t.Errorf("BOTH order in hedge mode: got %v, want ErrPositionSide", err)
+	}
+}
+
+func TestOneWayModeFlip(t *testing.T) {
+	e := New()
+	e.SetFees(0, 0)
+	e.PlaceOrder(OrderRequest{Symbol: "ETHUSDT", Side: "BUY", Type: OrderTypeMarket, Quantity: 1})
+	e.SetPrice("ETHUSDT", 3100)
+	o, err := e.PlaceOrder(OrderRequest{Symbol: "ETHUSDT", Side: "SELL", Type: OrderTypeMarket, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approx(o.RealizedPnL, 100) {
+		t.Errorf("got PnL %v, want 100", o.RealizedPnL)
+	}
+	pos := e.Position("ETHUSDT", SideBoth)
+	if !approx(pos.Amount, -2) || pos.EntryPrice != 3100 {
+		t.Errorf("expected a 2 ETH short at 3100, got %+v", pos)
+	}
+
+	_, err = e.PlaceOrder(OrderRequest{Symbol: "ETHUSDT", Side: "BUY", Type: OrderTypeMarket, Quantity: 100})
+	if !errors.Is(err, ErrInsufficientMargin) {
+		t.Errorf("got %v, want ErrInsufficientMargin", err)
+	}
+}
+
+func TestConditionalAndLimitOrders(t *testing.T) {
+	e := New()
+	e.SetPosition("BTCUSDT", SideLong, 0.2, 50000)
+
+	stop, err := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: OrderTypeStopMarket, TriggerPrice: 49000, ClosePosition: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, _ := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: OrderTypeTakeProfit, TriggerPrice: 52000, Quantity: 0.1, ReduceOnly: true})
+	bid, _ := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeLimit, Price: 49500, Quantity: 0.1})
+
+	e.SetPrice("BTCUSDT", 49600)
+	if o, _ := e.Order(bid.ID); o.Status != types.OrderStatusNew {
+		t.Fatalf("limit filled above its price: %+v", o)
+	}
+
+	e.SetPrice("BTCUSDT", 49400)
+	if o, _ := e.Order(bid.ID); o.Status != types.OrderStatusFilled || o.AvgPrice != 49500 {
+		t.Errorf("limit should fill at its price: %+v", o)
+	}
+	if o, _ := e.Order(stop.ID); o.Triggered {
+		t.Errorf("stop triggered above its price: %+v", o)
+	}
+
+	e.SetPrice("BTCUSDT", 48900)
+	if o, _ := e.Order(stop.ID); o.Status != types.OrderStatusFilled || !approx(o.ExecutedQty, 0.3) || o.AvgPrice != 48900 {
+		t.Errorf("stop should close the whole 0.3 position at 48900: %+v", o)
+	}
+	if n := len(e.Positions()); n != 0 {
+		t.Errorf("position not closed: %+v", e.Positions())
+	}
+
+	// The take-profit has nothing left to reduce
+	e.SetPrice("BTCUSDT", 52000)
+	if o, _ := e.Order(tp.ID); o.Status != types.OrderStatusExpired {
+		t.Errorf("reduce-only take-profit without position should expire: %+v", o)
+	}
+}
+
+func TestPartialFill(t *testing.T) {
+	e := New()
+	e.SetPartialFill(0.5)
+	o, err := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket, Quantity: 0.011})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != types.OrderStatusPartiallyFilled || !approx(o.ExecutedQty, 0.005) {
+		t.Fatalf("expected half filled (rounded down to the step): %+v", o)
+	}
+
+	e.SetPrice("BTCUSDT", 50100)
+	o, _ = e.Order(o.ID)
+	if o.Status != types.OrderStatusFilled || !approx(o.ExecutedQty, 0.011) || !approx(o.AvgPrice, (0.005*50000+0.006*50100)/0.011) {
+		t.Errorf("remainder should fill at the next price: %+v", o)
+	}
+}
+
+func TestTrailingStop(t *testing.T) {
+	e := New()
+	e.SetPosition("BTCUSDT", SideLong, 0.1, 50000)
+
+	o, err := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: OrderTypeTrailingStop, Quantity: 0.1, ReduceOnly: true, CallbackRate: 2, ActivationPrice: 51000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.BestPrice != 0 || o.TriggerPrice != 0 {
+		t.Fatalf("trailing stop active below its activation price: %+v", o)
+	}
+
+	// Activates at 52000, follows the high to 53000 and triggers on the 2% retrace
+	for _, price := range []float64{50500, 52000, 53000, 52000} {
+		e.SetPrice("BTCUSDT", price)
+	}
+	if o, _ = e.Order(o.ID); !o.IsOpen() || !approx(o.BestPrice, 53000) || !approx(o.TriggerPrice, 51940) {
+		t.Fatalf("unexpected trailing stop: %+v", o)
+	}
+	e.SetPrice("BTCUSDT", 51900)
+	if o, _ = e.Order(o.ID); o.Status != types.OrderStatusFilled || o.AvgPrice != 51900 {
+		t.Errorf("trailing stop not triggered: %+v", o)
+	}
+	if pos := e.Position("BTCUSDT", SideBoth); pos.Amount != 0 {
+		t.Errorf("position not closed: %+v", pos)
+	}
+
+	// A fixed distance on a short, active at once
+	e.SetPosition("ETHUSDT", SideShort, 1, 3000)
+	o, err = e.PlaceOrder(OrderRequest{Symbol: "ETHUSDT", Side: "BUY", Type: OrderTypeTrailingStop, ClosePosition: true, CallbackDistance: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetPrice("ETHUSDT", 2900)
+	if o, _ = e.Order(o.ID); !o.IsOpen() || !approx(o.TriggerPrice, 2950) {
+		t.Fatalf("unexpected trailing stop: %+v", o)
+	}
+	e.SetPrice("ETHUSDT", 2960)
+	if pos := e.Position("ETHUSDT", SideBoth); pos.Amount != 0 {
+		t.Errorf("short not closed: %+v", pos)
+	}
+
+	if _, err := e.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: OrderTypeTrailingStop, Quantity: 0.1}); !errors.Is(err, ErrInvalidPrice) {
+		t.Errorf("trailing stop without callback: got %v, want ErrInvalidPrice", err)
+	}
+}
@@ -0,0 +1,123 @@
+package fakeexchange
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FaultKind kind of an injected request failure
+type FaultKind int
+
+const (
+	// FaultRateLimit rejects the request with the venue's rate limit error
+	FaultRateLimit FaultKind = iota + 1
+	// FaultServerError fails the request with HTTP 503 before it reaches the exchange
+	FaultServerError
+)
+
+type fault struct {
+	kind   FaultKind
+	path   string
+	remain int
+}
+
+// Inject fails the next count requests whose path starts with path (any request if
+// empty); faults are consumed in injection order
+func (e *Exchange) Inject(kind FaultKind, path string, count int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.faults = append(e.faults, &fault{kind: kind, path: path, remain: count})
+}
+
+// SetPartialFill makes new market and marketable limit orders fill only ratio of their
+// quantity (rounded down to the step); the remainder stays working and fills at the next
+// SetPrice. 0 restores full fills.
+func (e *Exchange) SetPartialFill(ratio float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.partialFill = ratio
+}
+
+// SetClockSkew moves the exchange clock relative to the local one: signed requests whose
+// timestamp falls outside the venue's receive window are rejected, server time endpoints
+// report the skewed time
+func (e *Exchange) SetClockSkew(skew time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clockSkew = skew
+}
+
+// Requests returns the log of requests received by the servers
+func (e *Exchange) Requests() []Request {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Request(nil), e.requests...)
+}
+
+// Calls counts the received requests with a method (any if empty) and path
+func (e *Exchange) Calls(method, path string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for _, r := range e.requests {
+		if (method == "" || r.Method == method) && r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// admit logs a request and returns the fault to apply to it, if any
+func (e *Exchange) admit(r *http.Request) FaultKind {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery})
+	for i, f := range e.faults {
+		if !strings.HasPrefix(r.URL.Path, f.path) {
+			continue
+		}
+		f.remain--
+		if f.remain <= 0 {
+			e.faults = append(e.faults[:i], e.faults[i+1:]...)
+		}
+		return f.kind
+	}
+	return 0
+}
+
+// inWindow whether a signed request timestamp is acceptable to the exchange clock: not
+// more than ahead in the future and not older than window
+func (e *Exchange) inWindow(ts time.Time, window, ahead time.Duration) bool {
+	now := e.Now()
+	return !ts.After(now.Add(ahead)) && !ts.Before(now.Add(-window))
+}
+
+// dialect renders a venue's wire format for the shared middleware
+type dialect interface {
+	// requestTime returns the timestamp of a signed request and the venue's acceptance
+	// window, ok is false for public requests
+	requestTime(r *http.Request) (ts time.Time, window, ahead time.Duration, ok bool)
+	rateLimited(w http.ResponseWriter)
+	timestampRejected(w http.ResponseWriter)
+	serve(w http.ResponseWriter, r *http.Request)
+}
+
+// handler applies faults and the clock check before handing the request to the dialect
+func (e *Exchange) handler(d dialect) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch e.admit(r) {
+		case FaultRateLimit:
+			d.rateLimited(w)
+			return
+		case FaultServerError:
+			http.Error(w, "503 Service Temporarily Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if ts, window, ahead, ok := d.requestTime(r); ok && !e.inWindow(ts, window, ahead) {
+			d.timestampRejected(w)
+			return
+		}
+		d.serve(w, r)
+	})
+}
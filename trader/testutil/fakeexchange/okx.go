@@ -0,0 +1,634 @@
+package fakeexchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"nofx/trader/types"
+)
+
+// NewOKXServer serves the exchange as the OKX v5 REST API for USDT swaps; sizes are in
+// contracts of Instrument.CtVal
+func NewOKXServer(e *Exchange) *httptest.Server {
+	return httptest.NewServer(e.handler(&okxDialect{e: e}))
+}
+
+type okxDialect struct {
+	e *Exchange
+}
+
+// okxError a failed request or order item: code and message
+type okxError struct {
+	code string
+	msg  string
+}
+
+func (d *okxDialect) requestTime(r *http.Request) (time.Time, time.Duration, time.Duration, bool) {
+	header := r.Header.Get("OK-ACCESS-TIMESTAMP")
+	if header == "" {
+		return time.Time{}, 0, 0, false
+	}
+	ts, err := time.Parse("2006-01-02T15:04:05.000Z", header)
+	if err != nil {
+		return time.Time{}, 30 * time.Second, 30 * time.Second, true
+	}
+	return ts, 30 * time.Second, 30 * time.Second, true
+}
+
+func (d *okxDialect) rateLimited(w http.ResponseWriter) {
+	writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{"code": "50011", "msg": "Too Many Requests", "data": []interface{}{}})
+}
+
+func (d *okxDialect) timestampRejected(w http.ResponseWriter) {
+	writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"code": "50102", "msg": "Timestamp request expired", "data": []interface{}{}})
+}
+
+func (d *okxDialect) error(w http.ResponseWriter, e okxError) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": e.code, "msg": e.msg, "data": []interface{}{}})
+}
+
+func (d *okxDialect) ok(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "0", "msg": "", "data": data})
+}
+
+// itemFailed reports a rejected order item: code "1" with the reason in sCode/sMsg
+func (d *okxDialect) itemFailed(w http.ResponseWriter, item map[string]interface{}, e okxError) {
+	item["sCode"], item["sMsg"] = e.code, e.msg
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "1", "msg": "All operations failed", "data": []interface{}{item}})
+}
+
+func okxEngineError(err error) okxError {
+	switch {
+	case errors.Is(err, ErrUnknownSymbol):
+		return okxError{"51001", "Instrument ID or Spread ID doesn't exist."}
+	case errors.Is(err, ErrUnknownOrder):
+		return okxError{"51603", "Order does not exist"}
+	case errors.Is(err, ErrInvalidQuantity):
+		return okxError{"51020", "Your order should meet or exceed the minimum order amount."}
+	case errors.Is(err, ErrInvalidPrice):
+		return okxError{"51000", "Parameter px error"}
+	case errors.Is(err, ErrPositionSide):
+		return okxError{"51000", "Parameter posSide error"}
+	case errors.Is(err, ErrReduceOnly):
+		return okxError{"51169", "Order failed because you don't have any positions in this direction for this contract to reduce or close."}
+	case errors.Is(err, ErrInsufficientMargin):
+		return okxError{"51008", "Order failed. Insufficient USDT margin in account"}
+	case errors.Is(err, ErrPositionExists):
+		return okxError{"59000", "Setting failed. Cancel any open orders, close positions, and stop trading bots first."}
+	}
+	return okxError{"51000", err.Error()}
+}
+
+// body decodes a JSON object body (or the first element of an array body) into strings
+func (d *okxDialect) body(r *http.Request) []url.Values {
+	if r.Body == nil {
+		return nil
+	}
+	raw, _ := io.ReadAll(r.Body)
+	var items []map[string]interface{}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var item map[string]interface{}
+		if json.Unmarshal(raw, &item) != nil {
+			return nil
+		}
+		items = []map[string]interface{}{item}
+	}
+	result := make([]url.Values, 0, len(items))
+	for _, item := range items {
+		values := url.Values{}
+		for k, v := range item {
+			switch v := v.(type) {
+			case string:
+				values.Set(k, v)
+			case float64:
+				values.Set(k, ftoa(v))
+			default:
+				values.Set(k, fmt.Sprint(v))
+			}
+		}
+		result = append(result, values)
+	}
+	return result
+}
+
+func (d *okxDialect) serve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var p url.Values
+	if r.Method == http.MethodPost {
+		items := d.body(r)
+		if r.URL.Path == "/api/v5/trade/cancel-algos" {
+			d.cancelAlgos(w, items)
+			return
+		}
+		if len(items) > 0 {
+			p = items[0]
+		} else {
+			p = url.Values{}
+		}
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /api/v5/public/time":
+		d.ok(w, []map[string]interface{}{{"ts": strconv.FormatInt(d.e.Now().UnixMilli(), 10)}})
+	case "GET /api/v5/public/instruments":
+		d.instruments(w, q.Get("instId"))
+	case "GET /api/v5/market/ticker":
+		inst, ok := d.instrument(q.Get("instId"))
+		if !ok {
+			d.error(w, okxEngineError(ErrUnknownSymbol))
+			return
+		}
+		d.ok(w, []map[string]interface{}{{
+			"instId": q.Get("instId"), "instType": "SWAP", "last": ftoa(inst.Price),
+			"bidPx": ftoa(inst.Price - inst.TickSize), "askPx": ftoa(inst.Price + inst.TickSize),
+			"ts": strconv.FormatInt(d.e.Now().UnixMilli(), 10),
+		}})
+	case "GET /api/v5/market/books":
+		d.books(w, q)
+	case "GET /api/v5/account/config":
+		posMode := "net_mode"
+		if d.e.HedgeMode() {
+			posMode = "long_short_mode"
+		}
+		d.ok(w, []map[string]interface{}{{"uid": "1", "acctLv": "2", "posMode": posMode, "autoLoan": false}})
+	case "POST /api/v5/account/set-position-mode":
+		if err := d.e.SetHedgeMode(p.Get("posMode") == "long_short_mode"); err != nil {
+			d.error(w, okxEngineError(err))
+			return
+		}
+		d.ok(w, []map[string]interface{}{{"posMode": p.Get("posMode")}})
+	case "POST /api/v5/account/set-isolated-mode":
+		// Sets how isolated margin is transferred, the margin mode itself is per order
+		d.ok(w, []map[string]interface{}{{"isoMode": "automatic"}})
+	case "POST /api/v5/account/set-leverage":
+		symbol, ok := okxSymbol(p.Get("instId"))
+		leverage, _ := strconv.Atoi(p.Get("lever"))
+		if !ok {
+			d.error(w, okxEngineError(ErrUnknownSymbol))
+			return
+		}
+		if err := d.e.SetLeverage(symbol, leverage); err != nil {
+			d.error(w, okxEngineError(err))
+			return
+		}
+		d.ok(w, []map[string]interface{}{{"instId": p.Get("instId"), "lever": p.Get("lever"), "mgnMode": p.Get("mgnMode"), "posSide": p.Get("posSide")}})
+	case "GET /api/v5/account/balance":
+		b := d.e.Balance()
+		d.ok(w, []map[string]interface{}{{
+			"totalEq": ftoa(b.Equity), "adjEq": ftoa(b.Equity), "isoEq": "0", "ordFroz": "0", "imr": ftoa(b.InitialMargin),
+			"uTime": strconv.FormatInt(d.e.Now().UnixMilli(), 10),
+			"details": []map[string]interface{}{{
+				"ccy": "USDT", "eq": ftoa(b.Equity), "cashBal": ftoa(b.Wallet), "availBal": ftoa(b.Available),
+				"availEq": ftoa(b.Available), "upl": ftoa(b.UnrealizedPnL), "frozenBal": ftoa(b.InitialMargin),
+			}},
+		}})
+	case "GET /api/v5/account/positions":
+		d.positions(w, q.Get("instId"))
+	case "POST /api/v5/trade/order":
+		d.createOrder(w, p)
+	case "GET /api/v5/trade/order":
+		o, err := d.lookupOrder(q.Get("instId"), q.Get("ordId"), q.Get("clOrdId"))
+		if err != nil {
+			d.error(w, okxEngineError(err))
+			return
+		}
+		d.ok(w, []map[string]interface{}{d.order(o)})
+	case "GET /api/v5/trade/orders-pending":
+		orders := d.e.Orders(func(o Order) bool {
+			return !o.IsConditional() && o.IsOpen() && (q.Get("instId") == "" || okxInstID(o.Symbol) == q.Get("instId"))
+		})
+		data := []map[string]interface{}{}
+		for _, o := range orders {
+			data = append(data, d.order(o))
+		}
+		d.ok(w, data)
+	case "POST /api/v5/trade/cancel-order":
+		item := map[string]interface{}{"ordId": p.Get("ordId"), "clOrdId": p.Get("clOrdId")}
+		o, err := d.lookupOrder(p.Get("instId"), p.Get("ordId"), p.Get("clOrdId"))
+		if err == nil {
+			o, err = d.e.CancelOrder(o.Symbol, o.ID)
+		}
+		if err != nil {
+			d.itemFailed(w, item, okxError{"51400", "Order cancellation failed as the order has been filled, canceled or does not exist."})
+			return
+		}
+		item["ordId"], item["sCode"], item["sMsg"] = strconv.FormatInt(o.ID, 10), "0", ""
+		d.ok(w, []map[string]interface{}{item})
+	case "POST /api/v5/trade/order-algo":
+		d.createAlgoOrder(w, p)
+	case "GET /api/v5/trade/orders-algo-pending":
+		orders := d.e.Orders(func(o Order) bool {
+			return o.IsConditional() && o.IsOpen() && !o.Triggered && (q.Get("instId") == "" || okxInstID(o.Symbol) == q.Get("instId")) &&
+				(q.Get("ordType") == "" || okxAlgoOrdType(o) == q.Get("ordType"))
+		})
+		data := []map[string]interface{}{}
+		for _, o := range orders {
+			data = append(data, d.algoOrder(o))
+		}
+		d.ok(w, data)
+	case "GET /api/v5/trade/fills-history", "GET /api/v5/trade/fills":
+		d.fills(w, q)
+	default:
+		d.error(w, okxError{"50030", fmt.Sprintf("No permission to use this API: %s", r.URL.Path)})
+	}
+}
+
+func (d *okxDialect) instrument(instID string) (Instrument, bool) {
+	symbol, ok := okxSymbol(instID)
+	if !ok {
+		return Instrument{}, false
+	}
+	return d.e.Instrument(symbol)
+}
+
+func (d *okxDialect) instruments(w http.ResponseWriter, instID string) {
+	data := []map[string]interface{}{}
+	for _, inst := range d.e.Instruments() {
+		if instID != "" && okxInstID(inst.Symbol) != instID {
+			continue
+		}
+		base := strings.TrimSuffix(inst.Symbol, "USDT")
+		data = append(data, map[string]interface{}{
+			"instId":    okxInstID(inst.Symbol),
+			"instType":  "SWAP",
+			"uly":       base + "-USDT",
+			"ctVal":     ftoa(inst.CtVal),
+			"ctMult":    "1",
+			"ctValCcy":  base,
+			"ctType":    "linear",
+			"settleCcy": "USDT",
+			"lotSz":     ftoa(clean(inst.StepSize / inst.CtVal)),
+			"minSz":     ftoa(clean(inst.MinQty / inst.CtVal)),
+			"maxMktSz":  "10000",
+			"tickSz":    ftoa(inst.TickSize),
+			"state":     "live",
+		})
+	}
+	if instID != "" && len(data) == 0 {
+		d.error(w, okxEngineError(ErrUnknownSymbol))
+		return
+	}
+	d.ok(w, data)
+}
+
+func (d *okxDialect) books(w http.ResponseWriter, q url.Values) {
+	inst, ok := d.instrument(q.Get("instId"))
+	if !ok {
+		d.error(w, okxEngineError(ErrUnknownSymbol))
+		return
+	}
+	depth, _ := strconv.Atoi(q.Get("sz"))
+	bids, asks, err := d.e.Book(inst.Symbol, depth)
+	if err != nil {
+		d.error(w, okxEngineError(err))
+		return
+	}
+	side := func(book [][2]float64) [][4]string {
+		result := make([][4]string, 0, len(book))
+		for _, level := range book {
+			result = append(result, [4]string{ftoa(level[0]), ftoa(clean(level[1] / inst.CtVal)), "0", "1"})
+		}
+		return result
+	}
+	d.ok(w, []map[string]interface{}{{"bids": side(bids), "asks": side(asks), "ts": strconv.FormatInt(d.e.Now().UnixMilli(), 10)}})
+}
+
+func (d *okxDialect) positions(w http.ResponseWriter, instID string) {
+	data := []map[string]interface{}{}
+	for _, pos := range d.e.Positions() {
+		if instID != "" && okxInstID(pos.Symbol) != instID {
+			continue
+		}
+		inst, _ := d.e.Instrument(pos.Symbol)
+		leverage := d.e.Leverage(pos.Symbol)
+		contracts := clean(pos.Amount / inst.CtVal)
+		if pos.Side != SideBoth {
+			// Hedge mode positions are reported unsigned, the side is in posSide
+			contracts = math.Abs(contracts)
+		}
+		mgnMode := "cross"
+		if d.e.Isolated(pos.Symbol) {
+			mgnMode = "isolated"
+		}
+		data = append(data, map[string]interface{}{
+			"instId":   okxInstID(pos.Symbol),
+			"instType": "SWAP",
+			"posSide":  okxPosSide(pos.Side),
+			"pos":      ftoa(contracts),
+			"avgPx":    ftoa(pos.EntryPrice),
+			"markPx":   ftoa(inst.Price),
+			"upl":      ftoa(pos.Amount * (inst.Price - pos.EntryPrice)),
+			"lever":    strconv.Itoa(leverage),
+			"liqPx":    ftoa(liquidationPrice(pos, leverage)),
+			"margin":   ftoa(math.Abs(pos.Amount) * inst.Price / float64(leverage)),
+			"mgnMode":  mgnMode,
+			"cTime":    strconv.FormatInt(pos.CreateTime.UnixMilli(), 10),
+			"uTime":    strconv.FormatInt(pos.UpdateTime.UnixMilli(), 10),
+		})
+	}
+	d.ok(w, data)
+}
+
+// orderRequest decodes the fields shared by regular and algo orders
+func (d *okxDialect) orderRequest(p url.Values) (OrderRequest, okxError, bool) {
+	inst, ok := d.instrument(p.Get("instId"))
+	if !ok {
+		return OrderRequest{}, okxEngineError(ErrUnknownSymbol), false
+	}
+	side := strings.ToUpper(p.Get("side"))
+	if side != "BUY" && side != "SELL" {
+		return OrderRequest{}, okxError{"51000", "Parameter side error"}, false
+	}
+	positionSide := SideBoth
+	switch p.Get("posSide") {
+	case "long":
+		positionSide = SideLong
+	case "short":
+		positionSide = SideShort
+	}
+	return OrderRequest{
+		ClientID:     p.Get("clOrdId"),
+		Symbol:       inst.Symbol,
+		Side:         side,
+		PositionSide: positionSide,
+		Quantity:     clean(atof(p.Get("sz")) * inst.CtVal),
+		ReduceOnly:   p.Get("reduceOnly") == "true",
+	}, okxError{}, true
+}
+
+func (d *okxDialect) createOrder(w http.ResponseWriter, p url.Values) {
+	item := map[string]interface{}{"ordId": "", "clOrdId": p.Get("clOrdId"), "tag": p.Get("tag")}
+	req, failure, ok := d.orderRequest(p)
+	if !ok {
+		d.itemFailed(w, item, failure)
+		return
+	}
+	switch p.Get("ordType") {
+	case "market":
+		req.Type = OrderTypeMarket
+	case "limit", "post_only", "gtc":
+		req.Type = OrderTypeLimit
+		req.Price = atof(p.Get("px"))
+	default:
+		d.itemFailed(w, item, okxError{"51000", "Parameter ordType error"})
+		return
+	}
+	o, err := d.e.PlaceOrder(req)
+	if err != nil {
+		d.itemFailed(w, item, okxEngineError(err))
+		return
+	}
+	item["ordId"], item["sCode"], item["sMsg"] = strconv.FormatInt(o.ID, 10), "0", "Order placed"
+	d.ok(w, []map[string]interface{}{item})
+}
+
+// createAlgoOrder places conditional orders with either a stop-loss or a take-profit
+// trigger and trailing stops (move_order_stop); attached and one-cancels-the-other
+// orders are not emulated
+func (d *okxDialect) createAlgoOrder(w http.ResponseWriter, p url.Values) {
+	item := map[string]interface{}{"algoId": "", "algoClOrdId": p.Get("algoClOrdId"), "tag": p.Get("tag")}
+	if p.Get("ordType") != "conditional" && p.Get("ordType") != "move_order_stop" {
+		d.itemFailed(w, item, okxError{"51000", "Parameter ordType error"})
+		return
+	}
+	req, failure, ok := d.orderRequest(p)
+	if !ok {
+		d.itemFailed(w, item, failure)
+		return
+	}
+	req.ClientID = p.Get("algoClOrdId")
+	sl, tp := atof(p.Get("slTriggerPx")), atof(p.Get("tpTriggerPx"))
+	switch {
+	case p.Get("ordType") == "move_order_stop":
+		req.Type = OrderTypeTrailingStop
+		req.CallbackRate = atof(p.Get("callbackRatio")) * 100
+		req.CallbackDistance = atof(p.Get("callbackSpread"))
+		req.ActivationPrice = atof(p.Get("activePx"))
+		if req.CallbackRate <= 0 && req.CallbackDistance <= 0 {
+			d.itemFailed(w, item, okxError{"51000", "Parameter callbackRatio error"})
+			return
+		}
+	case sl > 0 && tp > 0:
+		d.itemFailed(w, item, okxError{"51000", "Parameter slTriggerPx error: combined stop-loss and take-profit is not supported"})
+		return
+	case sl > 0:
+		req.Type, req.TriggerPrice = OrderTypeStopMarket, sl
+	case tp > 0:
+		req.Type, req.TriggerPrice = OrderTypeTakeProfit, tp
+	default:
+		d.itemFailed(w, item, okxError{"51000", "Parameter slTriggerPx error"})
+		return
+	}
+	if req.Quantity == 0 && p.Get("closeFraction") == "1" {
+		req.ClosePosition = true
+	}
+	o, err := d.e.PlaceOrder(req)
+	if err != nil {
+		d.itemFailed(w, item, okxEngineError(err))
+		return
+	}
+	item["algoId"], item["sCode"], item["sMsg"] = strconv.FormatInt(o.ID, 10), "0", ""
+	d.ok(w, []map[string]interface{}{item})
+}
+
+func (d *okxDialect) cancelAlgos(w http.ResponseWriter, items []url.Values) {
+	data := []map[string]interface{}{}
+	failed := 0
+	for _, p := range items {
+		item := map[string]interface{}{"algoId": p.Get("algoId"), "sCode": "0", "sMsg": ""}
+		id, _ := strconv.ParseInt(p.Get("algoId"), 10, 64)
+		o, err := d.e.Order(id)
+		if err == nil && o.IsConditional() && okxInstID(o.Symbol) == p.Get("instId") {
+			_, err = d.e.CancelOrder(o.Symbol, id)
+		} else if err == nil {
+			err = ErrUnknownOrder
+		}
+		if err != nil {
+			item["sCode"], item["sMsg"] = "51000", "Parameter algoId error"
+			failed++
+		}
+		data = append(data, item)
+	}
+	code, msg := "0", ""
+	if failed > 0 && failed == len(items) {
+		code, msg = "1", "All operations failed"
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": code, "msg": msg, "data": data})
+}
+
+func (d *okxDialect) lookupOrder(instID, ordID, clOrdID string) (Order, error) {
+	var o Order
+	var err error
+	if ordID != "" {
+		id, _ := strconv.ParseInt(ordID, 10, 64)
+		o, err = d.e.Order(id)
+	} else {
+		o, err = d.e.OrderByClientID(clOrdID)
+	}
+	if err == nil && (okxInstID(o.Symbol) != instID || o.IsConditional()) {
+		err = ErrUnknownOrder
+	}
+	return o, err
+}
+
+func (d *okxDialect) fills(w http.ResponseWriter, q url.Values) {
+	symbol := ""
+	if instID := q.Get("instId"); instID != "" {
+		inst, ok := d.instrument(instID)
+		if !ok {
+			d.error(w, okxEngineError(ErrUnknownSymbol))
+			return
+		}
+		symbol = inst.Symbol
+	}
+	fills := limitFills(d.e.Fills(symbol, msTime(q.Get("begin"))), q.Get("limit"), 100)
+	data := []map[string]interface{}{}
+	for i := len(fills) - 1; i >= 0; i-- {
+		f := fills[i]
+		inst, _ := d.e.Instrument(f.Symbol)
+		o, _ := d.e.Order(f.OrderID)
+		execType := "T"
+		if f.Maker {
+			execType = "M"
+		}
+		data = append(data, map[string]interface{}{
+			"instType": "SWAP",
+			"instId":   okxInstID(f.Symbol),
+			"tradeId":  strconv.FormatInt(f.ID, 10),
+			"ordId":    strconv.FormatInt(f.OrderID, 10),
+			"clOrdId":  o.ClientID,
+			"billId":   strconv.FormatInt(f.ID, 10),
+			"side":     strings.ToLower(f.Side),
+			"posSide":  okxPosSide(f.PositionSide),
+			"fillPx":   ftoa(f.Price),
+			"fillSz":   ftoa(clean(f.Quantity / inst.CtVal)),
+			"fillPnl":  ftoa(f.RealizedPnL),
+			"fee":      ftoa(-f.Fee),
+			"feeCcy":   "USDT",
+			"execType": execType,
+			"ts":       strconv.FormatInt(f.Time.UnixMilli(), 10),
+		})
+	}
+	d.ok(w, data)
+}
+
+func (d *okxDialect) order(o Order) map[string]interface{} {
+	inst, _ := d.e.Instrument(o.Symbol)
+	state := map[string]string{
+		types.OrderStatusNew:             "live",
+		types.OrderStatusPartiallyFilled: "partially_filled",
+		types.OrderStatusFilled:          "filled",
+		types.OrderStatusCanceled:        "canceled",
+		types.OrderStatusExpired:         "canceled",
+	}[o.Status]
+	ordType := "market"
+	px := ""
+	if o.Type == OrderTypeLimit {
+		ordType, px = "limit", ftoa(o.Price)
+	}
+	avgPx := ""
+	if o.ExecutedQty > 0 {
+		avgPx = ftoa(o.AvgPrice)
+	}
+	return map[string]interface{}{
+		"instType":   "SWAP",
+		"instId":     okxInstID(o.Symbol),
+		"ordId":      strconv.FormatInt(o.ID, 10),
+		"clOrdId":    o.ClientID,
+		"side":       strings.ToLower(o.Side),
+		"posSide":    okxPosSide(o.PositionSide),
+		"ordType":    ordType,
+		"px":         px,
+		"sz":         ftoa(clean(o.Quantity / inst.CtVal)),
+		"accFillSz":  ftoa(clean(o.ExecutedQty / inst.CtVal)),
+		"avgPx":      avgPx,
+		"state":      state,
+		"fee":        ftoa(-o.Fee),
+		"feeCcy":     "USDT",
+		"pnl":        ftoa(o.RealizedPnL),
+		"reduceOnly": strconv.FormatBool(o.ReduceOnly),
+		"cTime":      strconv.FormatInt(o.CreateTime.UnixMilli(), 10),
+		"uTime":      strconv.FormatInt(o.UpdateTime.UnixMilli(), 10),
+	}
+}
+
+func (d *okxDialect) algoOrder(o Order) map[string]interface{} {
+	inst, _ := d.e.Instrument(o.Symbol)
+	sl, tp := "", ""
+	switch o.Type {
+	case OrderTypeStopMarket:
+		sl = ftoa(o.TriggerPrice)
+	case OrderTypeTakeProfit:
+		tp = ftoa(o.TriggerPrice)
+	}
+	result := map[string]interface{}{
+		"instType":    "SWAP",
+		"instId":      okxInstID(o.Symbol),
+		"algoId":      strconv.FormatInt(o.ID, 10),
+		"algoClOrdId": o.ClientID,
+		"side":        strings.ToLower(o.Side),
+		"posSide":     okxPosSide(o.PositionSide),
+		"ordType":     okxAlgoOrdType(o),
+		"sz":          ftoa(clean(o.Quantity / inst.CtVal)),
+		"slTriggerPx": sl,
+		"slOrdPx":     "-1",
+		"tpTriggerPx": tp,
+		"tpOrdPx":     "-1",
+		"state":       "live",
+		"cTime":       strconv.FormatInt(o.CreateTime.UnixMilli(), 10),
+	}
+	if o.Type == OrderTypeTrailingStop {
+		result["callbackRatio"], result["callbackSpread"] = ftoa(o.CallbackRate/100), ""
+		if o.CallbackDistance > 0 {
+			result["callbackRatio"], result["callbackSpread"] = "", ftoa(o.CallbackDistance)
+		}
+		result["activePx"] = ftoa(o.ActivationPrice)
+	}
+	return result
+}
+
+// okxAlgoOrdType the ordType an algo order is listed under
+func okxAlgoOrdType(o Order) string {
+	if o.Type == OrderTypeTrailingStop {
+		return "move_order_stop"
+	}
+	return "conditional"
+}
+
+// okxInstID converts BTCUSDT to BTC-USDT-SWAP
+func okxInstID(symbol string) string {
+	return strings.TrimSuffix(symbol, "USDT") + "-USDT-SWAP"
+}
+
+// okxSymbol converts BTC-USDT-SWAP to BTCUSDT
+func okxSymbol(instID string) (string, bool) {
+	parts := strings.Split(instID, "-")
+	if len(parts) != 3 || parts[1] != "USDT" || parts[2] != "SWAP" {
+		return "", false
+	}
+	return parts[0] + parts[1], true
+}
+
+func okxPosSide(side string) string {
+	switch side {
+	case SideLong:
+		return "long"
+	case SideShort:
+		return "short"
+	}
+	return "net"
+}
+
+// clean drops floating point noise from contract conversions
+func clean(v float64) float64 {
+	return math.Round(v*1e9) / 1e9
+}
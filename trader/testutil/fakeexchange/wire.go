@@ -0,0 +1,67 @@
+package fakeexchange
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Helpers shared by the dialects to encode the wire formats
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ftoa formats a number the way venues send them: as a string, without exponent
+func ftoa(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func atof(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// msTime parses a millisecond timestamp parameter, the zero time if absent
+func msTime(s string) time.Time {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// levels encodes depth levels as [price, size] string pairs
+func levels(book [][2]float64) [][2]string {
+	result := make([][2]string, 0, len(book))
+	for _, level := range book {
+		result = append(result, [2]string{ftoa(level[0]), ftoa(level[1])})
+	}
+	return result
+}
+
+// limitFills keeps the most recent limit fills (def if the parameter is absent)
+func limitFills(fills []Fill, limit string, def int) []Fill {
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		n = def
+	}
+	if len(fills) > n {
+		fills = fills[len(fills)-n:]
+	}
+	return fills
+}
+
+// liquidationPrice rough isolated liquidation price, ignoring maintenance margin
+func liquidationPrice(pos Position, leverage int) float64 {
+	switch {
+	case pos.Amount > 0:
+		return pos.EntryPrice * (1 - 1/float64(leverage))
+	case pos.Amount < 0:
+		return pos.EntryPrice * (1 + 1/float64(leverage))
+	}
+	return 0
+}
@@ -19,6 +19,15 @@ type TraderTestSuite struct {
 	T       *testing.T
 	Trader  types.Trader
 	Patches *gomonkey.Patches
+
+	// BeforeEach is called with the test name before each test of RunAllTests, e.g. to
+	// reset a stateful fake exchange (optional)
+	BeforeEach func(name string)
+	// Skip maps test names to the reason they don't apply to the trader (optional)
+	Skip map[string]string
+	// SeedAccount resets the exchange to a wallet balance and a single position, so
+	// TypedContract can check the typed results against known values (optional)
+	SeedAccount func(wallet float64, pos types.Position)
 }
 
 // NewTraderTestSuite Create new base test suite
@@ -41,30 +50,44 @@ func (s *TraderTestSuite) Cleanup() {
 // Note: Before calling this method, please set up required mocks via SetupMocks
 func (s *TraderTestSuite) RunAllTests() {
 	// Basic query methods
-	s.T.Run("GetBalance", func(t *testing.T) { s.TestGetBalance() })
-	s.T.Run("GetPositions", func(t *testing.T) { s.TestGetPositions() })
-	s.T.Run("GetMarketPrice", func(t *testing.T) { s.TestGetMarketPrice() })
+	s.run("GetBalance", s.TestGetBalance)
+	s.run("GetPositions", s.TestGetPositions)
+	s.run("TypedContract", s.TestTypedContract)
+	s.run("GetMarketPrice", s.TestGetMarketPrice)
 
 	// Configuration methods
-	s.T.Run("SetLeverage", func(t *testing.T) { s.TestSetLeverage() })
-	s.T.Run("SetMarginMode", func(t *testing.T) { s.TestSetMarginMode() })
-	s.T.Run("FormatQuantity", func(t *testing.T) { s.TestFormatQuantity() })
+	s.run("SetLeverage", s.TestSetLeverage)
+	s.run("SetMarginMode", s.TestSetMarginMode)
+	s.run("FormatQuantity", s.TestFormatQuantity)
 
 	// Core trading methods
-	s.T.Run("OpenLong", func(t *testing.T) { s.TestOpenLong() })
-	s.T.Run("OpenShort", func(t *testing.T) { s.TestOpenShort() })
-	s.T.Run("CloseLong", func(t *testing.T) { s.TestCloseLong() })
-	s.T.Run("CloseShort", func(t *testing.T) { s.TestCloseShort() })
+	s.run("OpenLong", s.TestOpenLong)
+	s.run("OpenShort", s.TestOpenShort)
+	s.run("CloseLong", s.TestCloseLong)
+	s.run("CloseShort", s.TestCloseShort)
 
 	// Stop-loss and take-profit
-	s.T.Run("SetStopLoss", func(t *testing.T) { s.TestSetStopLoss() })
-	s.T.Run("SetTakeProfit", func(t *testing.T) { s.TestSetTakeProfit() })
+	s.run("SetStopLoss", s.TestSetStopLoss)
+	s.run("SetTakeProfit", s.TestSetTakeProfit)
 
 	// Order management
-	s.T.Run("CancelAllOrders", func(t *testing.T) { s.TestCancelAllOrders() })
-	s.T.Run("CancelStopOrders", func(t *testing.T) { s.TestCancelStopOrders() })
-	s.T.Run("CancelStopLossOrders", func(t *testing.T) { s.TestCancelStopLossOrders() })
-	s.T.Run("CancelTakeProfitOrders", func(t *testing.T) { s.TestCancelTakeProfitOrders() })
+	s.run("CancelAllOrders", s.TestCancelAllOrders)
+	s.run("CancelStopOrders", s.TestCancelStopOrders)
+	s.run("CancelStopLossOrders", s.TestCancelStopLossOrders)
+	s.run("CancelTakeProfitOrders", s.TestCancelTakeProfitOrders)
+}
+
+// run runs a test of the suite as a subtest, applying Skip and BeforeEach
+func (s *TraderTestSuite) run(name string, test func()) {
+	s.T.Run(name, func(t *testing.T) {
+		if reason, ok := s.Skip[name]; ok {
+			t.Skip(reason)
+		}
+		if s.BeforeEach != nil {
+			s.BeforeEach(name)
+		}
+		test()
+	})
 }
 
 // TestGetBalance Test getting account balance
@@ -137,6 +160,77 @@ func (s *TraderTestSuite) TestGetPositions() {
 	}
 }
 
+// TestTypedContract Test the typed (v2) results: against the seeded account when SeedAccount is
+// set, else only for well-formed values
+func (s *TraderTestSuite) TestTypedContract() {
+	trader := types.AsTraderV2(s.Trader)
+
+	if s.SeedAccount == nil {
+		s.T.Run("WellFormed", func(t *testing.T) {
+			balance, err := trader.GetBalanceV2()
+			if assert.NoError(t, err) {
+				assert.GreaterOrEqual(t, balance.TotalEquity, 0.0)
+			}
+			positions, err := trader.GetPositionsV2()
+			assert.NoError(t, err)
+			for _, pos := range positions {
+				assert.NotEmpty(t, pos.Symbol)
+				assert.Contains(t, []string{"long", "short"}, pos.Side)
+				assert.Greater(t, pos.Quantity, 0.0)
+			}
+		})
+		return
+	}
+
+	// Short below the mark price, so the side and the sign of the PnL are both checked
+	seeded := types.Position{Symbol: "BTCUSDT", Side: "short", Quantity: 0.02, EntryPrice: 52000}
+	const wallet = 25000.0
+	s.SeedAccount(wallet, seeded)
+
+	var markPrice, unrealized float64
+	s.T.Run("Positions", func(t *testing.T) {
+		positions, err := trader.GetPositionsV2()
+		if !assert.NoError(t, err) || !assert.Len(t, positions, 1) {
+			return
+		}
+		pos := positions[0]
+		assert.Equal(t, seeded.Symbol, pos.Symbol)
+		assert.Equal(t, seeded.Side, pos.Side)
+		assert.InDelta(t, seeded.Quantity, pos.Quantity, 1e-9)
+		assert.InDelta(t, seeded.EntryPrice, pos.EntryPrice, 1e-6)
+		assert.Greater(t, pos.MarkPrice, 0.0)
+		assert.InDelta(t, seeded.Quantity*(seeded.EntryPrice-pos.MarkPrice), pos.UnrealizedPnL, 1e-6)
+		markPrice, unrealized = pos.MarkPrice, pos.UnrealizedPnL
+	})
+
+	s.T.Run("Balance", func(t *testing.T) {
+		balance, err := trader.GetBalanceV2()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.InDelta(t, wallet, balance.TotalWalletBalance, 1e-6)
+		assert.InDelta(t, unrealized, balance.TotalUnrealizedProfit, 1e-6)
+		assert.InDelta(t, wallet+unrealized, balance.TotalEquity, 1e-6)
+		assert.Greater(t, balance.AvailableBalance, 0.0)
+		assert.Less(t, balance.AvailableBalance, balance.TotalEquity)
+	})
+
+	s.T.Run("OrderStatus", func(t *testing.T) {
+		order, err := trader.OpenLongV2("BTCUSDT", 0.01, 5)
+		if !assert.NoError(t, err) || !assert.NotEmpty(t, order.OrderID) {
+			return
+		}
+		status, err := trader.GetOrderStatusV2("BTCUSDT", order.OrderID)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, order.OrderID, status.OrderID)
+		assert.Equal(t, types.OrderStatusFilled, status.Status)
+		assert.InDelta(t, 0.01, status.ExecutedQty, 1e-9)
+		assert.InDelta(t, markPrice, status.AvgPrice, 1e-6)
+	})
+}
+
 // TestGetMarketPrice Test getting market price
 func (s *TraderTestSuite) TestGetMarketPrice() {
 	tests := []struct {
@@ -0,0 +1,355 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"nofx/kernel"
+	"nofx/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Take-profit ladders (scaled exits with partial closes)
+// ============================================================================
+
+const (
+	// ladderQtyTolerance relative tolerance when matching position changes to level quantities
+	ladderQtyTolerance = 1e-6
+	// ladderPriceTolerance relative tolerance when matching open orders to level prices, which
+	// the exchange may have rounded to its tick size
+	ladderPriceTolerance = 1e-4
+)
+
+// takeProfitStep one level of a take-profit ladder
+type takeProfitStep struct {
+	price    float64
+	quantity float64
+	native   bool // Exchange take-profit order: only tracked, not executed
+	done     bool
+}
+
+// takeProfitLadder take-profit levels of one position, nearest to the entry first
+type takeProfitLadder struct {
+	symbol     string
+	side       string // "long" or "short"
+	entryPrice float64
+	remaining  float64 // Position quantity left once the done levels closed
+	steps      []*takeProfitStep
+}
+
+// reached whether the mark price reached the level
+func (l *takeProfitLadder) reached(step *takeProfitStep, markPrice float64) bool {
+	if l.side == "long" {
+		return markPrice >= step.price
+	}
+	return markPrice <= step.price
+}
+
+// pendingNative whether an exchange level is still open
+func (l *takeProfitLadder) pendingNative() bool {
+	for _, step := range l.steps {
+		if step.native && !step.done {
+			return true
+		}
+	}
+	return false
+}
+
+// finished whether all levels are done
+func (l *takeProfitLadder) finished() bool {
+	for _, step := range l.steps {
+		if !step.done {
+			return false
+		}
+	}
+	return true
+}
+
+// ladderFill a take-profit level that closed part of a position
+type ladderFill struct {
+	symbol     string
+	side       string
+	quantity   float64
+	price      float64
+	entryPrice float64
+	order      map[string]interface{} // Close order of an emulated level, nil for exchange orders
+}
+
+// takeProfitLadderEngine tracks the take-profit ladders of open positions. Levels are exchange
+// orders when the trader can place partial take-profits, else they are emulated by closing part
+// of the position once the mark price reaches them.
+type takeProfitLadderEngine struct {
+	mu      sync.Mutex
+	trader  Trader
+	ladders map[string]*takeProfitLadder // symbol_side -> ladder
+}
+
+func newTakeProfitLadderEngine(t Trader) *takeProfitLadderEngine {
+	return &takeProfitLadderEngine{
+		trader:  t,
+		ladders: make(map[string]*takeProfitLadder),
+	}
+}
+
+// add registers the ladder of a position, replacing an earlier one
+func (e *takeProfitLadderEngine) add(l *takeProfitLadder) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.ladders[l.symbol+"_"+l.side] = l
+}
+
+func (e *takeProfitLadderEngine) empty() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.ladders) == 0
+}
+
+// openTriggerPrices returns the trigger prices of the open orders of the symbols with pending
+// exchange levels; symbols whose orders couldn't be queried are left out
+func (e *takeProfitLadderEngine) openTriggerPrices(open map[string]Position) map[string][]float64 {
+	symbols := make(map[string]bool)
+	e.mu.Lock()
+	for key, l := range e.ladders {
+		if _, ok := open[key]; ok && l.pendingNative() {
+			symbols[l.symbol] = true
+		}
+	}
+	e.mu.Unlock()
+
+	prices := make(map[string][]float64, len(symbols))
+	for symbol := range symbols {
+		orders, err := e.trader.GetOpenOrders(symbol)
+		if err != nil {
+			logger.Infof("⚠️ Take-profit ladder: failed to get open orders of %s: %v", symbol, err)
+			continue
+		}
+		triggers := make([]float64, 0, len(orders))
+		for _, o := range orders {
+			if o.StopPrice > 0 {
+				triggers = append(triggers, o.StopPrice)
+			}
+		}
+		prices[symbol] = triggers
+	}
+	return prices
+}
+
+// orderAt whether one of the trigger prices is the level price
+func orderAt(triggers []float64, price float64) bool {
+	for _, p := range triggers {
+		if math.Abs(p-price) <= price*ladderPriceTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// update drops the ladders of closed positions, detects filled exchange levels and closes emulated
+// levels the mark price reached. It returns the fills. An exchange level is filled once its order
+// is no longer open and the position shrank by its quantity.
+func (e *takeProfitLadderEngine) update(positions []Position) []ladderFill {
+	open := make(map[string]Position, len(positions))
+	for _, pos := range positions {
+		open[pos.Symbol+"_"+pos.Side] = pos
+	}
+	triggers := e.openTriggerPrices(open)
+
+	type hit struct {
+		ladder *takeProfitLadder
+		step   *takeProfitStep
+		pos    Position
+	}
+	var fills []ladderFill
+	var hits []hit
+
+	e.mu.Lock()
+	for key, l := range e.ladders {
+		pos, ok := open[key]
+		if !ok {
+			delete(e.ladders, key)
+			continue
+		}
+		for _, step := range l.steps {
+			if step.done {
+				continue
+			}
+			if step.native {
+				symbolTriggers, known := triggers[l.symbol]
+				if !known || orderAt(symbolTriggers, step.price) {
+					continue
+				}
+				// Gone but the position didn't shrink (yet): cancelled, or the position
+				// update lags the order update
+				if pos.Quantity > l.remaining-step.quantity*(1-ladderQtyTolerance) {
+					continue
+				}
+				step.done = true
+				l.remaining -= step.quantity
+				fills = append(fills, ladderFill{symbol: l.symbol, side: l.side, quantity: step.quantity, price: step.price, entryPrice: l.entryPrice})
+				continue
+			}
+			if pos.MarkPrice > 0 && l.reached(step, pos.MarkPrice) {
+				hits = append(hits, hit{ladder: l, step: step, pos: pos})
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	for _, h := range hits {
+		l, step := h.ladder, h.step
+		logger.Infof("🎯 Take-profit level hit: %s %s mark %.4f reached %.4f, closing %.6f",
+			l.symbol, l.side, h.pos.MarkPrice, step.price, step.quantity)
+		var order map[string]interface{}
+		var err error
+		if l.side == "long" {
+			order, err = e.trader.CloseLong(l.symbol, step.quantity)
+		} else {
+			order, err = e.trader.CloseShort(l.symbol, step.quantity)
+		}
+		if err != nil {
+			logger.Warnf("⚠️ Take-profit level close failed (%s %s): %v", l.symbol, l.side, err)
+			continue
+		}
+		e.mu.Lock()
+		step.done = true
+		l.remaining -= step.quantity
+		e.mu.Unlock()
+		fills = append(fills, ladderFill{symbol: l.symbol, side: l.side, quantity: step.quantity, price: h.pos.MarkPrice, entryPrice: l.entryPrice, order: order})
+	}
+
+	e.mu.Lock()
+	for key, l := range e.ladders {
+		if l.finished() {
+			delete(e.ladders, key)
+		}
+	}
+	e.mu.Unlock()
+	return fills
+}
+
+// placeTakeProfitLadder places the take-profit ladder of a position just opened by order and
+// returns the quantity it leaves for the final take-profit and the trailing stop. R-multiple
+// levels are priced from the fill price, markPrice if it's unknown.
+func (at *AutoTrader) placeTakeProfitLadder(decision *kernel.Decision, side string, quantity float64, order map[string]interface{}, markPrice float64) float64 {
+	if len(decision.TakeProfitLevels) == 0 {
+		return quantity
+	}
+	entryPrice := at.openFillPrice(order, decision.Symbol, side, markPrice)
+	levels := decision.TakeProfitLadder(side, entryPrice)
+
+	ladder := &takeProfitLadder{symbol: decision.Symbol, side: side, entryPrice: entryPrice, remaining: quantity}
+	partial, native := at.trader.(PartialTakeProfitTrader)
+	rest := quantity
+	for i, qty := range at.ladderQuantities(decision.Symbol, quantity, levels) {
+		level := levels[i]
+		if qty <= 0 {
+			logger.Infof("  ⚠ Take-profit level at %.4f is below the minimum quantity, skipped", level.Price)
+			continue
+		}
+		step := &takeProfitStep{price: level.Price, quantity: qty}
+		if native {
+			if err := partial.SetPartialTakeProfit(decision.Symbol, strings.ToUpper(side), qty, level.Price); err != nil {
+				logger.Infof("  ⚠ Partial take-profit at %.4f failed, emulating it: %v", level.Price, err)
+			} else {
+				step.native = true
+			}
+		}
+		ladder.steps = append(ladder.steps, step)
+		rest -= qty
+		logger.Infof("  ✓ Take-profit level: %s %s %.6f @ %.4f (%.0f%%, native: %v)",
+			decision.Symbol, side, qty, level.Price, level.Pct, step.native)
+	}
+	if len(ladder.steps) > 0 {
+		at.takeProfitLadders.add(ladder)
+	}
+
+	if rest < quantity*1e-9 {
+		return 0
+	}
+	return rest
+}
+
+// ladderQuantities splits quantity over the levels, each rounded with FormatQuantity. The last
+// level takes what rounding left of the levels' total share, so the ladder closes exactly that.
+func (at *AutoTrader) ladderQuantities(symbol string, quantity float64, levels []kernel.TakeProfitLevel) []float64 {
+	total := 0.0
+	for _, level := range levels {
+		total += level.Pct
+	}
+	ladderQty := quantity * math.Min(total, 100) / 100
+
+	quantities := make([]float64, len(levels))
+	assigned := 0.0
+	for i, level := range levels {
+		qty := quantity * level.Pct / 100
+		if i == len(levels)-1 {
+			qty = ladderQty - assigned
+		}
+		qty = math.Min(at.roundQuantity(symbol, qty), quantity-assigned)
+		if qty < 0 {
+			qty = 0
+		}
+		quantities[i] = qty
+		assigned += qty
+	}
+	return quantities
+}
+
+// roundQuantity rounds a quantity to the exchange's precision, unchanged if it can't be formatted
+func (at *AutoTrader) roundQuantity(symbol string, quantity float64) float64 {
+	formatted, err := at.trader.FormatQuantity(symbol, quantity)
+	if err != nil {
+		return quantity
+	}
+	rounded, err := strconv.ParseFloat(formatted, 64)
+	if err != nil {
+		return quantity
+	}
+	return rounded
+}
+
+// openFillPrice returns the average fill price of an open order: from the order result, else the
+// position's entry price (averaged with the earlier entries when adding to a position), else
+// fallback
+func (at *AutoTrader) openFillPrice(order map[string]interface{}, symbol, side string, fallback float64) float64 {
+	switch p := order["avgPrice"].(type) {
+	case float64:
+		if p > 0 {
+			return p
+		}
+	case string:
+		if v, err := strconv.ParseFloat(p, 64); err == nil && v > 0 {
+			return v
+		}
+	}
+	positions, err := AsTraderV2(at.trader).GetPositionsV2()
+	if err == nil {
+		for _, pos := range positions {
+			if pos.Symbol == symbol && pos.Side == side && pos.EntryPrice > 0 {
+				return pos.EntryPrice
+			}
+		}
+	}
+	return fallback
+}
+
+// recordLadderFills records the partial closes of take-profit levels. Emulated levels go through
+// the usual order recording; filled exchange orders are only recorded for exchanges without
+// OrderSync, which otherwise picks them up from the trade history.
+func (at *AutoTrader) recordLadderFills(fills []ladderFill) {
+	for _, f := range fills {
+		action := "close_" + f.side
+		if f.order != nil {
+			at.recordAndConfirmOrder(f.order, f.symbol, action, f.quantity, f.price, 0, f.entryPrice)
+			continue
+		}
+		if at.hasOrderSync() {
+			continue
+		}
+		orderID := fmt.Sprintf("tp_%s_%s_%d", f.symbol, f.side, time.Now().UnixMilli())
+		at.recordPositionChange(orderID, f.symbol, strings.ToUpper(f.side), action, f.quantity, f.price, 0, f.entryPrice, 0)
+	}
+}
@@ -0,0 +1,178 @@
+package trader
+
+import (
+	"fmt"
+	"nofx/kernel"
+	"nofx/trader/types"
+	"testing"
+)
+
+// closeRecorder records the partial closes of the take-profit ladder engine
+type closeRecorder struct {
+	types.Trader
+	closed []float64
+}
+
+func (r *closeRecorder) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return fmt.Sprintf("%.1f", quantity), nil
+}
+
+func (r *closeRecorder) GetPositions() ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (r *closeRecorder) GetOpenOrders(symbol string) ([]types.OpenOrder, error) {
+	return nil, nil
+}
+
+func (r *closeRecorder) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	r.closed = append(r.closed, quantity)
+	return map[string]interface{}{"orderId": int64(len(r.closed))}, nil
+}
+
+func (r *closeRecorder) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return r.CloseLong(symbol, quantity)
+}
+
+// partialTaker a trader with exchange partial take-profits
+type partialTaker struct {
+	*closeRecorder
+	levels []float64
+	open   []types.OpenOrder
+}
+
+func (p *partialTaker) SetPartialTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	p.levels = append(p.levels, takeProfitPrice)
+	p.open = append(p.open, types.OpenOrder{Symbol: symbol, Type: "TAKE_PROFIT_MARKET", StopPrice: takeProfitPrice, Quantity: quantity})
+	return nil
+}
+
+func (p *partialTaker) GetOpenOrders(symbol string) ([]types.OpenOrder, error) {
+	return p.open, nil
+}
+
+// fill removes the open orders of the filled levels
+func (p *partialTaker) fill(prices ...float64) {
+	var open []types.OpenOrder
+	for _, o := range p.open {
+		filled := false
+		for _, price := range prices {
+			filled = filled || o.StopPrice == price
+		}
+		if !filled {
+			open = append(open, o)
+		}
+	}
+	p.open = open
+}
+
+// ladderDecision long from 100 risking 10: 30% at 1R (110), 40% at 2R (120)
+func ladderDecision() *kernel.Decision {
+	return &kernel.Decision{Symbol: "SOLUSDT", StopLoss: 90, TakeProfit: 150, TakeProfitLevels: []kernel.TakeProfitLevel{
+		{RMultiple: 1, Pct: 30},
+		{RMultiple: 2, Pct: 40},
+	}}
+}
+
+func TestTakeProfitLadderEmulated(t *testing.T) {
+	rec := &closeRecorder{}
+	at := &AutoTrader{trader: rec, takeProfitLadders: newTakeProfitLadderEngine(rec)}
+
+	if remaining := at.placeTakeProfitLadder(ladderDecision(), "long", 1, nil, 100); !near(remaining, 0.3) {
+		t.Fatalf("got remaining %v, want 0.3", remaining)
+	}
+
+	long := func(qty, mark float64) []Position {
+		return []Position{{Symbol: "SOLUSDT", Side: "long", Quantity: qty, EntryPrice: 100, MarkPrice: mark}}
+	}
+	if fills := at.takeProfitLadders.update(long(1, 105)); len(fills) != 0 {
+		t.Fatalf("no level reached, got %+v", fills)
+	}
+	fills := at.takeProfitLadders.update(long(1, 111))
+	if len(fills) != 1 || fills[0].order == nil || !near(fills[0].quantity, 0.3) || fills[0].price != 111 {
+		t.Fatalf("unexpected fills at 1R: %+v", fills)
+	}
+	// The second level is closed once
+	at.takeProfitLadders.update(long(0.7, 125))
+	at.takeProfitLadders.update(long(0.3, 125))
+	if len(rec.closed) != 2 || !near(rec.closed[1], 0.4) {
+		t.Errorf("unexpected closes: %v", rec.closed)
+	}
+	if !at.takeProfitLadders.empty() {
+		t.Error("finished ladder kept")
+	}
+}
+
+func TestTakeProfitLadderNative(t *testing.T) {
+	native := &partialTaker{closeRecorder: &closeRecorder{}}
+	at := &AutoTrader{trader: native, takeProfitLadders: newTakeProfitLadderEngine(native)}
+
+	d := ladderDecision()
+	d.TakeProfitLevels = append(d.TakeProfitLevels, kernel.TakeProfitLevel{Price: 130, Pct: 30})
+	if remaining := at.placeTakeProfitLadder(d, "long", 1, nil, 100); remaining != 0 {
+		t.Fatalf("a ladder closing 100%% should leave nothing, got %v", remaining)
+	}
+	if len(native.levels) != 3 || native.levels[0] != 110 || native.levels[2] != 130 {
+		t.Fatalf("unexpected exchange levels: %v", native.levels)
+	}
+
+	// Exchange fills are detected by their orders and the position quantity, nothing is closed
+	// by the engine
+	long := func(qty float64) []Position {
+		return []Position{{Symbol: "SOLUSDT", Side: "long", Quantity: qty, MarkPrice: 200}}
+	}
+	if fills := at.takeProfitLadders.update(long(1)); len(fills) != 0 {
+		t.Fatalf("nothing filled yet, got %+v", fills)
+	}
+	// A position reduced otherwise doesn't fill levels whose orders are open
+	if fills := at.takeProfitLadders.update(long(0.3)); len(fills) != 0 {
+		t.Fatalf("levels with open orders filled: %+v", fills)
+	}
+	// Orders gone before the position update: not filled yet
+	native.fill(110, 120)
+	if fills := at.takeProfitLadders.update(long(1)); len(fills) != 0 {
+		t.Fatalf("levels filled without a position change: %+v", fills)
+	}
+	fills := at.takeProfitLadders.update(long(0.3))
+	if len(fills) != 2 || fills[0].order != nil || fills[0].price != 110 || !near(fills[1].quantity, 0.4) {
+		t.Errorf("unexpected fills: %+v", fills)
+	}
+	if len(native.closed) != 0 {
+		t.Errorf("native levels closed by the engine: %v", native.closed)
+	}
+
+	// Closed positions are dropped
+	at.takeProfitLadders.update(nil)
+	if !at.takeProfitLadders.empty() {
+		t.Error("ladder of a closed position kept")
+	}
+}
+
+func TestTakeProfitLadderRounding(t *testing.T) {
+	rec := &closeRecorder{}
+	at := &AutoTrader{trader: rec, takeProfitLadders: newTakeProfitLadderEngine(rec)}
+
+	// Levels rounded to the exchange precision (0.1), the rounding remainder to the last one
+	d := &kernel.Decision{Symbol: "SOLUSDT", StopLoss: 90, TakeProfit: 150, TakeProfitLevels: []kernel.TakeProfitLevel{
+		{Price: 110, Pct: 33.35}, {Price: 120, Pct: 33.35}, {Price: 130, Pct: 33.3},
+	}}
+	if remaining := at.placeTakeProfitLadder(d, "long", 1, nil, 100); remaining != 0 {
+		t.Fatalf("got remaining %v, want 0", remaining)
+	}
+	steps := at.takeProfitLadders.ladders["SOLUSDT_long"].steps
+	if len(steps) != 3 || !near(steps[0].quantity, 0.3) || !near(steps[1].quantity, 0.3) || !near(steps[2].quantity, 0.4) {
+		t.Errorf("unexpected level quantities: %+v %+v %+v", steps[0], steps[1], steps[2])
+	}
+}
+
+func TestTakeProfitLadderFillPrice(t *testing.T) {
+	rec := &closeRecorder{}
+	at := &AutoTrader{trader: rec, takeProfitLadders: newTakeProfitLadderEngine(rec)}
+
+	// R-multiples are priced from the fill, not from the price the decision saw
+	at.placeTakeProfitLadder(ladderDecision(), "long", 1, map[string]interface{}{"avgPrice": 102.0}, 100)
+	ladder := at.takeProfitLadders.ladders["SOLUSDT_long"]
+	if ladder.entryPrice != 102 || !near(ladder.steps[0].price, 114) || !near(ladder.steps[1].price, 126) {
+		t.Errorf("unexpected ladder: entry %v, levels %v / %v", ladder.entryPrice, ladder.steps[0].price, ladder.steps[1].price)
+	}
+}
@@ -0,0 +1,405 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"nofx/kernel"
+	"nofx/logger"
+	"nofx/store"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Trailing stops and break-even stop management
+// ============================================================================
+
+const (
+	// trailingStopInterval how often emulated trailing stops and take-profit levels follow the mark price
+	trailingStopInterval = 5 * time.Second
+	// trailingStopMinMove minimum improvement (fraction of price) before an emulated stop is
+	// replaced, so a creeping price doesn't replace the stop order on every poll
+	trailingStopMinMove = 0.001
+)
+
+// trailingStop trailing stop of one position
+type trailingStop struct {
+	symbol      string
+	side        string // "long" or "short"
+	callbackPct float64
+	native      bool    // Exchange trailing order: only tracked, not moved
+	bestPrice   float64 // Best mark price since the trailing stop was set (emulated)
+	stopPrice   float64 // Stop-loss on the exchange, 0 if none (emulated)
+}
+
+// level stop price trailing the best price by the callback
+func (ts *trailingStop) level() float64 {
+	if ts.side == "long" {
+		return ts.bestPrice * (1 - ts.callbackPct/100)
+	}
+	return ts.bestPrice * (1 + ts.callbackPct/100)
+}
+
+// tighter whether stop protects the position better than the current stop
+func (ts *trailingStop) tighter(stop float64) bool {
+	if ts.stopPrice <= 0 {
+		return true
+	}
+	if ts.side == "long" {
+		return stop > ts.stopPrice
+	}
+	return stop < ts.stopPrice
+}
+
+// trailingStopEngine tracks the trailing stops of open positions. For exchanges without native
+// trailing orders it emulates them by ratcheting SetStopLoss after the mark price.
+type trailingStopEngine struct {
+	mu       sync.Mutex
+	trader   Trader
+	stops    map[string]*trailingStop // symbol_side -> trailing stop
+	store    *store.TrailingStopStore // Keeps the stops across restarts (nil: memory only)
+	traderID string
+}
+
+// newTrailingStopEngine creates the trailing stop engine of a trader; st may be nil
+func newTrailingStopEngine(t Trader, st *store.Store, traderID string) *trailingStopEngine {
+	e := &trailingStopEngine{
+		trader:   t,
+		stops:    make(map[string]*trailingStop),
+		traderID: traderID,
+	}
+	if st != nil {
+		e.store = st.TrailingStop()
+	}
+	return e
+}
+
+// load restores the trailing stops saved by an earlier run. Those of positions closed meanwhile
+// are dropped by the next update.
+func (e *trailingStopEngine) load() {
+	if e.store == nil {
+		return
+	}
+	saved, err := e.store.List(e.traderID)
+	if err != nil {
+		logger.Warnf("⚠️ Failed to load trailing stops: %v", err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range saved {
+		e.stops[s.Symbol+"_"+s.Side] = &trailingStop{
+			symbol:      s.Symbol,
+			side:        s.Side,
+			callbackPct: s.CallbackPct,
+			native:      s.Native,
+			bestPrice:   s.BestPrice,
+			stopPrice:   s.StopPrice,
+		}
+	}
+	if len(saved) > 0 {
+		logger.Infof("📐 Restored %d trailing stops", len(saved))
+	}
+}
+
+// save persists a copy of a trailing stop taken under e.mu
+func (e *trailingStopEngine) save(ts trailingStop) {
+	if e.store == nil {
+		return
+	}
+	err := e.store.Save(&store.TrailingStop{
+		TraderID:    e.traderID,
+		Symbol:      ts.symbol,
+		Side:        ts.side,
+		CallbackPct: ts.callbackPct,
+		Native:      ts.native,
+		BestPrice:   ts.bestPrice,
+		StopPrice:   ts.stopPrice,
+	})
+	if err != nil {
+		logger.Warnf("⚠️ Trailing stop of %s %s not saved: %v", ts.symbol, ts.side, err)
+	}
+}
+
+func (e *trailingStopEngine) remove(symbol, side string) {
+	if e.store == nil {
+		return
+	}
+	if err := e.store.Delete(e.traderID, symbol, side); err != nil {
+		logger.Warnf("⚠️ Trailing stop of %s %s not deleted: %v", symbol, side, err)
+	}
+}
+
+// add registers the trailing stop of a position, replacing an earlier one
+func (e *trailingStopEngine) add(ts *trailingStop) {
+	e.mu.Lock()
+	e.stops[ts.symbol+"_"+ts.side] = ts
+	saved := *ts
+	e.mu.Unlock()
+	e.save(saved)
+}
+
+// has whether the position has a trailing stop
+func (e *trailingStopEngine) has(symbol, side string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.stops[symbol+"_"+side]
+	return ok
+}
+
+func (e *trailingStopEngine) empty() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.stops) == 0
+}
+
+// setStop records a stop-loss placed outside the engine (break-even), so that the emulated
+// trailing stop only moves it further in favour of the position
+func (e *trailingStopEngine) setStop(symbol, side string, stopPrice float64) {
+	e.mu.Lock()
+	ts, ok := e.stops[symbol+"_"+side]
+	if !ok || !ts.tighter(stopPrice) {
+		e.mu.Unlock()
+		return
+	}
+	ts.stopPrice = stopPrice
+	saved := *ts
+	e.mu.Unlock()
+	e.save(saved)
+}
+
+// update drops the trailing stops of closed positions and moves emulated stops after the mark
+// price of the open ones. A price that retraced past the trailing level between two updates
+// closes the position.
+func (e *trailingStopEngine) update(positions []Position) {
+	open := make(map[string]Position, len(positions))
+	for _, pos := range positions {
+		open[pos.Symbol+"_"+pos.Side] = pos
+	}
+
+	type move struct {
+		ts    *trailingStop
+		pos   Position
+		stop  float64
+		close bool
+	}
+	var moves []move
+	var closed []trailingStop
+
+	e.mu.Lock()
+	for key, ts := range e.stops {
+		pos, ok := open[key]
+		if !ok {
+			delete(e.stops, key)
+			closed = append(closed, *ts)
+			continue
+		}
+		if ts.native || pos.MarkPrice <= 0 {
+			continue
+		}
+		if ts.bestPrice <= 0 || (ts.side == "long" && pos.MarkPrice > ts.bestPrice) || (ts.side == "short" && pos.MarkPrice < ts.bestPrice) {
+			ts.bestPrice = pos.MarkPrice
+		}
+		stop := ts.level()
+		hit := (ts.side == "long" && pos.MarkPrice <= stop) || (ts.side == "short" && pos.MarkPrice >= stop)
+		if hit || (ts.tighter(stop) && (ts.stopPrice <= 0 || math.Abs(stop-ts.stopPrice) >= pos.MarkPrice*trailingStopMinMove)) {
+			moves = append(moves, move{ts: ts, pos: pos, stop: stop, close: hit})
+		}
+	}
+	e.mu.Unlock()
+
+	for _, ts := range closed {
+		e.remove(ts.symbol, ts.side)
+	}
+	for _, m := range moves {
+		if m.close {
+			logger.Infof("📉 Trailing stop hit: %s %s mark %.4f past %.4f (best %.4f, callback %.2f%%)",
+				m.ts.symbol, m.ts.side, m.pos.MarkPrice, m.stop, m.ts.bestPrice, m.ts.callbackPct)
+			var err error
+			if m.ts.side == "long" {
+				_, err = e.trader.CloseLong(m.ts.symbol, 0)
+			} else {
+				_, err = e.trader.CloseShort(m.ts.symbol, 0)
+			}
+			if err != nil {
+				logger.Warnf("⚠️ Trailing stop close failed (%s %s): %v", m.ts.symbol, m.ts.side, err)
+			}
+			continue
+		}
+
+		e.mu.Lock()
+		previous := m.ts.stopPrice
+		e.mu.Unlock()
+		if err := e.trader.CancelStopLossOrders(m.ts.symbol); err != nil {
+			logger.Warnf("⚠️ Trailing stop: failed to cancel stop-loss of %s: %v", m.ts.symbol, err)
+		}
+		if err := e.trader.SetStopLoss(m.ts.symbol, strings.ToUpper(m.ts.side), m.pos.Quantity, m.stop); err != nil {
+			logger.Warnf("⚠️ Trailing stop: failed to move stop-loss of %s %s to %.4f: %v", m.ts.symbol, m.ts.side, m.stop, err)
+			e.restoreStop(m.ts, m.pos.Quantity, previous)
+			continue
+		}
+		logger.Infof("📈 Trailing stop moved: %s %s stop-loss %.4f → %.4f (best %.4f)", m.ts.symbol, m.ts.side, previous, m.stop, m.ts.bestPrice)
+		e.mu.Lock()
+		m.ts.stopPrice = m.stop
+		saved := *m.ts
+		e.mu.Unlock()
+		e.save(saved)
+	}
+}
+
+// restoreStop puts back the stop-loss cancelled for a move that failed. If that fails too the
+// stop is forgotten, so the next update places one again; until then the engine itself closes
+// the position at the trailing level.
+func (e *trailingStopEngine) restoreStop(ts *trailingStop, quantity, stopPrice float64) {
+	if stopPrice <= 0 {
+		return
+	}
+	err := e.trader.SetStopLoss(ts.symbol, strings.ToUpper(ts.side), quantity, stopPrice)
+	if err == nil {
+		logger.Infof("  ↩️ Trailing stop: restored stop-loss of %s %s at %.4f", ts.symbol, ts.side, stopPrice)
+		return
+	}
+	logger.Warnf("⚠️ Trailing stop: failed to restore stop-loss of %s %s at %.4f: %v", ts.symbol, ts.side, stopPrice, err)
+	e.mu.Lock()
+	ts.stopPrice = 0
+	saved := *ts
+	e.mu.Unlock()
+	e.save(saved)
+}
+
+// startExitMonitor starts moving emulated trailing stops and following take-profit ladders
+func (at *AutoTrader) startExitMonitor() {
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+
+		ticker := time.NewTicker(trailingStopInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if at.trailingStops.empty() && at.takeProfitLadders.empty() {
+					continue
+				}
+				positions, err := AsTraderV2(at.trader).GetPositionsV2()
+				if err != nil {
+					logger.Infof("❌ Exit monitoring: failed to get positions: %v", err)
+					continue
+				}
+				at.recordLadderFills(at.takeProfitLadders.update(positions))
+				at.trailingStops.update(positions)
+			case <-at.stopMonitorCh:
+				return
+			}
+		}
+	}()
+}
+
+// setTrailingStop places a trailing stop on a position: as an exchange order when the trader
+// supports it, else emulated by the trailing stop engine. stopPrice is the position's current
+// stop-loss (0 if unknown); an emulated trailing stop never loosens it.
+func (at *AutoTrader) setTrailingStop(symbol, side string, quantity, callbackPct, stopPrice float64) {
+	ts := &trailingStop{symbol: symbol, side: side, callbackPct: callbackPct, stopPrice: stopPrice}
+	if native, ok := at.trader.(TrailingStopper); ok {
+		if err := native.CancelTrailingStopOrders(symbol); err != nil {
+			logger.Infof("  ⚠ Failed to cancel previous trailing stop: %v", err)
+		}
+		if err := native.SetTrailingStop(symbol, strings.ToUpper(side), quantity, callbackPct, 0); err != nil {
+			logger.Infof("  ⚠ Native trailing stop failed, emulating it: %v", err)
+		} else {
+			ts.native = true
+		}
+	}
+	at.trailingStops.add(ts)
+	logger.Infof("  ✓ Trailing stop set: %s %s callback %.2f%% (native: %v)", symbol, side, callbackPct, ts.native)
+}
+
+// executeSetTrailingStopWithRecord puts a trailing stop on the open positions of the symbol
+func (at *AutoTrader) executeSetTrailingStopWithRecord(decision *kernel.Decision, actionRecord *store.DecisionAction) error {
+	logger.Infof("  📐 Set trailing stop: %s %.2f%%", decision.Symbol, decision.TrailingStopPct)
+
+	positions, err := at.symbolPositions(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	for _, pos := range positions {
+		at.setTrailingStop(pos.Symbol, pos.Side, pos.Quantity, decision.TrailingStopPct, at.currentStopLoss(pos))
+		actionRecord.Quantity = pos.Quantity
+		actionRecord.Price = pos.MarkPrice
+	}
+	return nil
+}
+
+// executeMoveToBreakEvenWithRecord moves the stop-loss of the open positions of the symbol to
+// their entry price. Positions not in profit are rejected: the stop would trigger at once.
+func (at *AutoTrader) executeMoveToBreakEvenWithRecord(decision *kernel.Decision, actionRecord *store.DecisionAction) error {
+	logger.Infof("  🛡️ Move stop to break-even: %s", decision.Symbol)
+
+	positions, err := at.symbolPositions(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	for _, pos := range positions {
+		if (pos.Side == "long" && pos.MarkPrice <= pos.EntryPrice) || (pos.Side == "short" && pos.MarkPrice >= pos.EntryPrice) {
+			return fmt.Errorf("%s %s is not in profit (entry %.4f, mark %.4f), can't move stop to break-even",
+				pos.Symbol, pos.Side, pos.EntryPrice, pos.MarkPrice)
+		}
+	}
+
+	for _, pos := range positions {
+		if err := at.trader.CancelStopLossOrders(pos.Symbol); err != nil {
+			logger.Infof("  ⚠ Failed to cancel stop-loss: %v", err)
+		}
+		if err := at.trader.SetStopLoss(pos.Symbol, strings.ToUpper(pos.Side), pos.Quantity, pos.EntryPrice); err != nil {
+			return fmt.Errorf("failed to move stop-loss to break-even: %w", err)
+		}
+		at.trailingStops.setStop(pos.Symbol, pos.Side, pos.EntryPrice)
+		logger.Infof("  ✓ %s %s stop-loss moved to entry %.4f", pos.Symbol, pos.Side, pos.EntryPrice)
+
+		actionRecord.Quantity = pos.Quantity
+		actionRecord.Price = pos.MarkPrice
+		actionRecord.StopLoss = pos.EntryPrice
+	}
+	return nil
+}
+
+// symbolPositions returns the open positions of a symbol, an error if there are none
+func (at *AutoTrader) symbolPositions(symbol string) ([]Position, error) {
+	positions, err := AsTraderV2(at.trader).GetPositionsV2()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get positions: %w", err)
+	}
+	var result []Position
+	for _, pos := range positions {
+		if pos.Symbol == symbol {
+			result = append(result, pos)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no open position for %s", symbol)
+	}
+	return result, nil
+}
+
+// currentStopLoss returns the tightest open stop order protecting the position, 0 if none
+func (at *AutoTrader) currentStopLoss(pos Position) float64 {
+	orders, err := at.trader.GetOpenOrders(pos.Symbol)
+	if err != nil {
+		return 0
+	}
+	var stop float64
+	for _, o := range orders {
+		if o.StopPrice <= 0 || (o.PositionSide != "" && o.PositionSide != "BOTH" && !strings.EqualFold(o.PositionSide, pos.Side)) {
+			continue
+		}
+		if pos.Side == "long" && strings.EqualFold(o.Side, "SELL") && o.StopPrice < pos.MarkPrice && o.StopPrice > stop {
+			stop = o.StopPrice
+		}
+		if pos.Side == "short" && strings.EqualFold(o.Side, "BUY") && o.StopPrice > pos.MarkPrice && (stop == 0 || o.StopPrice < stop) {
+			stop = o.StopPrice
+		}
+	}
+	return stop
+}
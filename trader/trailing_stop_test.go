@@ -0,0 +1,220 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"nofx/kernel"
+	"nofx/store"
+	"nofx/trader/types"
+	"path/filepath"
+	"testing"
+)
+
+// stopRecorder records the stop-loss calls of the trailing stop engine
+type stopRecorder struct {
+	types.Trader
+	positions []map[string]interface{}
+	stops     []float64
+	closed    []string
+	trailing  []float64 // Callbacks of native trailing stops
+	failStops int       // SetStopLoss calls to reject
+}
+
+func (r *stopRecorder) GetPositions() ([]map[string]interface{}, error) {
+	return r.positions, nil
+}
+
+func (r *stopRecorder) GetOpenOrders(symbol string) ([]types.OpenOrder, error) {
+	return nil, nil
+}
+
+func (r *stopRecorder) CancelStopLossOrders(symbol string) error {
+	return nil
+}
+
+func (r *stopRecorder) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	if r.failStops > 0 {
+		r.failStops--
+		return fmt.Errorf("stop price %v rejected", stopPrice)
+	}
+	r.stops = append(r.stops, stopPrice)
+	return nil
+}
+
+func (r *stopRecorder) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	r.closed = append(r.closed, symbol+"_long")
+	return map[string]interface{}{}, nil
+}
+
+// nativeTrailer a trader with exchange trailing stops
+type nativeTrailer struct {
+	*stopRecorder
+}
+
+func (n nativeTrailer) SetTrailingStop(symbol string, positionSide string, quantity, callbackPct, activationPrice float64) error {
+	n.trailing = append(n.trailing, callbackPct)
+	return nil
+}
+
+func (n nativeTrailer) CancelTrailingStopOrders(symbol string) error {
+	return nil
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestTrailingStopEngineRatchet(t *testing.T) {
+	rec := &stopRecorder{}
+	e := newTrailingStopEngine(rec, nil, "")
+	e.add(&trailingStop{symbol: "BTCUSDT", side: "long", callbackPct: 2, stopPrice: 95000})
+
+	long := func(mark float64) []Position {
+		return []Position{{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, EntryPrice: 100000, MarkPrice: mark}}
+	}
+
+	// 100000 * 0.98 = 98000 is tighter than the initial 95000 stop
+	e.update(long(100000))
+	// Falling prices and moves below the minimum step don't move the stop
+	e.update(long(99000))
+	e.update(long(100050))
+	// 102000 * 0.98 = 99960
+	e.update(long(102000))
+	if len(rec.stops) != 2 || !near(rec.stops[0], 98000) || !near(rec.stops[1], 99960) {
+		t.Fatalf("unexpected stop moves: %v", rec.stops)
+	}
+
+	// Break-even below the trailing level doesn't loosen it
+	e.setStop("BTCUSDT", "long", 100000)
+	e.setStop("BTCUSDT", "long", 99000)
+	if ts := e.stops["BTCUSDT_long"]; ts.stopPrice != 100000 {
+		t.Errorf("got stop %v, want 100000", ts.stopPrice)
+	}
+
+	// Retraced past the trailing level between two updates
+	e.update(long(99500))
+	if len(rec.closed) != 1 {
+		t.Errorf("expected the position to be closed: %v", rec.closed)
+	}
+
+	// Closed positions are dropped
+	e.update(nil)
+	if !e.empty() {
+		t.Error("trailing stop of a closed position kept")
+	}
+}
+
+func TestTrailingStopEngineShort(t *testing.T) {
+	rec := &stopRecorder{}
+	e := newTrailingStopEngine(rec, nil, "")
+	e.add(&trailingStop{symbol: "ETHUSDT", side: "short", callbackPct: 1})
+
+	e.update([]Position{{Symbol: "ETHUSDT", Side: "short", Quantity: 1, MarkPrice: 3000}})
+	e.update([]Position{{Symbol: "ETHUSDT", Side: "short", Quantity: 1, MarkPrice: 2900}})
+	if len(rec.stops) != 2 || !near(rec.stops[0], 3030) || !near(rec.stops[1], 2929) {
+		t.Errorf("unexpected stop moves: %v", rec.stops)
+	}
+}
+
+func TestTrailingStopEngineRestoresStop(t *testing.T) {
+	rec := &stopRecorder{}
+	e := newTrailingStopEngine(rec, nil, "")
+	e.add(&trailingStop{symbol: "BTCUSDT", side: "long", callbackPct: 2, stopPrice: 95000})
+	long := []Position{{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, MarkPrice: 100000}}
+
+	// The old stop is cancelled, the new one rejected: the old one is placed again
+	rec.failStops = 1
+	e.update(long)
+	if len(rec.stops) != 1 || rec.stops[0] != 95000 || e.stops["BTCUSDT_long"].stopPrice != 95000 {
+		t.Fatalf("old stop not restored: %v", rec.stops)
+	}
+
+	// Restoring fails too: the stop is placed again on the next update
+	rec.failStops = 2
+	e.update(long)
+	if e.stops["BTCUSDT_long"].stopPrice != 0 {
+		t.Fatalf("expected the lost stop to be forgotten, got %v", e.stops["BTCUSDT_long"].stopPrice)
+	}
+	e.update(long)
+	if n := len(rec.stops); n != 2 || !near(rec.stops[1], 98000) {
+		t.Errorf("stop not placed again: %v", rec.stops)
+	}
+}
+
+func TestTrailingStopEnginePersists(t *testing.T) {
+	st, err := store.New(filepath.Join(t.TempDir(), "trailing.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	rec := &stopRecorder{}
+	e := newTrailingStopEngine(rec, st, "trader-1")
+	e.add(&trailingStop{symbol: "BTCUSDT", side: "long", callbackPct: 2})
+	e.add(&trailingStop{symbol: "ETHUSDT", side: "short", callbackPct: 1, native: true})
+	e.update([]Position{
+		{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, MarkPrice: 100000},
+		{Symbol: "ETHUSDT", Side: "short", Quantity: 1, MarkPrice: 3000},
+	})
+
+	// A restarted trader keeps ratcheting from the saved state
+	restarted := newTrailingStopEngine(rec, st, "trader-1")
+	restarted.load()
+	btc, eth := restarted.stops["BTCUSDT_long"], restarted.stops["ETHUSDT_short"]
+	if btc == nil || btc.callbackPct != 2 || btc.bestPrice != 100000 || !near(btc.stopPrice, 98000) {
+		t.Fatalf("unexpected restored stop: %+v", btc)
+	}
+	if eth == nil || !eth.native || eth.callbackPct != 1 {
+		t.Fatalf("unexpected restored native stop: %+v", eth)
+	}
+
+	// Positions closed while stopped are dropped from the store too
+	restarted.update([]Position{{Symbol: "BTCUSDT", Side: "long", Quantity: 0.1, MarkPrice: 100000}})
+	saved, err := st.TrailingStop().List("trader-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].Symbol != "BTCUSDT" {
+		t.Errorf("unexpected saved stops: %+v", saved)
+	}
+}
+
+func TestSetTrailingStopAndBreakEven(t *testing.T) {
+	rec := &stopRecorder{positions: []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1, "entryPrice": 100000.0, "markPrice": 101000.0},
+		{"symbol": "ETHUSDT", "side": "short", "positionAmt": 1.0, "entryPrice": 3000.0, "markPrice": 3100.0},
+	}}
+
+	// Emulated: the engine moves the stop
+	at := &AutoTrader{trader: rec, trailingStops: newTrailingStopEngine(rec, nil, "")}
+	record := &store.DecisionAction{}
+	if err := at.executeDecisionWithRecord(&kernel.Decision{Symbol: "BTCUSDT", Action: "set_trailing_stop", TrailingStopPct: 1}, record); err != nil {
+		t.Fatal(err)
+	}
+	if ts := at.trailingStops.stops["BTCUSDT_long"]; ts == nil || ts.native || record.Quantity != 0.1 {
+		t.Fatalf("expected an emulated trailing stop, got %+v", ts)
+	}
+
+	if err := at.executeDecisionWithRecord(&kernel.Decision{Symbol: "BTCUSDT", Action: "move_to_breakeven"}, record); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.stops) != 1 || rec.stops[0] != 100000 || at.trailingStops.stops["BTCUSDT_long"].stopPrice != 100000 {
+		t.Errorf("stop not moved to entry: %v", rec.stops)
+	}
+	if err := at.executeDecisionWithRecord(&kernel.Decision{Symbol: "ETHUSDT", Action: "move_to_breakeven"}, record); err == nil {
+		t.Error("expected an error for a losing position")
+	}
+	if err := at.executeDecisionWithRecord(&kernel.Decision{Symbol: "SOLUSDT", Action: "set_trailing_stop", TrailingStopPct: 1}, record); err == nil {
+		t.Error("expected an error without position")
+	}
+
+	// Native: placed on the exchange, the engine only tracks it
+	native := nativeTrailer{&stopRecorder{positions: rec.positions}}
+	at = &AutoTrader{trader: native, trailingStops: newTrailingStopEngine(native, nil, "")}
+	if err := at.executeDecisionWithRecord(&kernel.Decision{Symbol: "ETHUSDT", Action: "set_trailing_stop", TrailingStopPct: 1.5}, record); err != nil {
+		t.Fatal(err)
+	}
+	if ts := at.trailingStops.stops["ETHUSDT_short"]; ts == nil || !ts.native || len(native.trailing) != 1 || native.trailing[0] != 1.5 {
+		t.Errorf("expected a native trailing stop, got %+v (%v)", ts, native.trailing)
+	}
+}
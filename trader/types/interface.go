@@ -8,19 +8,19 @@ import (
 
 // ClosedPnLRecord represents a single closed position record from exchange
 type ClosedPnLRecord struct {
-	Symbol       string    // Trading pair (e.g., "BTCUSDT")
-	Side         string    // "long" or "short"
-	EntryPrice   float64   // Entry price
-	ExitPrice    float64   // Exit/close price
-	Quantity     float64   // Position size
-	RealizedPnL  float64   // Realized profit/loss
-	Fee          float64   // Trading fee/commission
-	Leverage     int       // Leverage used
-	EntryTime    time.Time // Position open time
-	ExitTime     time.Time // Position close time
-	OrderID      string    // Close order ID
-	CloseType    string    // "manual", "stop_loss", "take_profit", "liquidation", "unknown"
-	ExchangeID   string    // Exchange-specific position ID
+	Symbol      string    // Trading pair (e.g., "BTCUSDT")
+	Side        string    // "long" or "short"
+	EntryPrice  float64   // Entry price
+	ExitPrice   float64   // Exit/close price
+	Quantity    float64   // Position size
+	RealizedPnL float64   // Realized profit/loss
+	Fee         float64   // Trading fee/commission
+	Leverage    int       // Leverage used
+	EntryTime   time.Time // Position open time
+	ExitTime    time.Time // Position close time
+	OrderID     string    // Close order ID
+	CloseType   string    // "manual", "stop_loss", "take_profit", "liquidation", "unknown"
+	ExchangeID  string    // Exchange-specific position ID
 }
 
 // TradeRecord represents a single trade/fill from exchange
@@ -125,9 +125,9 @@ type LimitOrderRequest struct {
 	Price        float64 `json:"price"`         // Limit price
 	Quantity     float64 `json:"quantity"`
 	Leverage     int     `json:"leverage"`
-	PostOnly     bool    `json:"post_only"`     // Maker only order
-	ReduceOnly   bool    `json:"reduce_only"`   // Reduce position only
-	ClientID     string  `json:"client_id"`     // Client order ID for tracking
+	PostOnly     bool    `json:"post_only"`   // Maker only order
+	ReduceOnly   bool    `json:"reduce_only"` // Reduce position only
+	ClientID     string  `json:"client_id"`   // Client order ID for tracking
 }
 
 // LimitOrderResult represents the result of placing a limit order
@@ -159,6 +159,25 @@ type GridTrader interface {
 	GetOrderBook(symbol string, depth int) (bids, asks [][]float64, err error)
 }
 
+// TrailingStopper optional interface of Traders that can place native trailing stop orders.
+// For other Traders the AutoTrader emulates trailing stops by ratcheting SetStopLoss.
+type TrailingStopper interface {
+	// SetTrailingStop places a reduce-only trailing stop that closes quantity of the position once
+	// the price retraces callbackPct percent from its best price since activation.
+	// activationPrice 0 starts trailing immediately.
+	SetTrailingStop(symbol string, positionSide string, quantity, callbackPct, activationPrice float64) error
+
+	// CancelTrailingStopOrders Cancel trailing stop orders for this symbol
+	CancelTrailingStopOrders(symbol string) error
+}
+
+// PartialTakeProfitTrader optional interface of Traders whose take-profit orders can close part of a position.
+// Used for take-profit ladders; SetTakeProfit of other Traders may close the whole position.
+type PartialTakeProfitTrader interface {
+	// SetPartialTakeProfit places a reduce-only take-profit closing quantity of the position at takeProfitPrice
+	SetPartialTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error
+}
+
 // GridTraderAdapter wraps a basic Trader to provide GridTrader interface
 // Uses stop orders as a fallback when limit orders aren't directly available
 type GridTraderAdapter struct {
@@ -0,0 +1,79 @@
+package types
+
+import "context"
+
+// ============================================================================
+// Private WebSocket user-data streams
+// ============================================================================
+
+// User data event types
+const (
+	UserDataOrder      = "order"      // Order status change
+	UserDataFill       = "fill"       // Execution of (part of) an order
+	UserDataPosition   = "position"   // Position size or entry price change
+	UserDataBalance    = "balance"    // Wallet balance change
+	UserDataDisconnect = "disconnect" // Stream disconnected: events are missed until the reconnect
+	UserDataReconnect  = "reconnect"  // Stream reconnected: events may have been missed, resync via REST
+)
+
+// Order statuses of user data events (adapters normalize exchange statuses to these)
+const (
+	OrderStatusNew             = "NEW"
+	OrderStatusPartiallyFilled = "PARTIALLY_FILLED"
+	OrderStatusFilled          = "FILLED"
+	OrderStatusCanceled        = "CANCELED"
+	OrderStatusExpired         = "EXPIRED"
+	OrderStatusRejected        = "REJECTED"
+)
+
+// UserDataEvent event of a private user-data stream. Which fields are set depends on Type.
+type UserDataEvent struct {
+	Type   string `json:"type"`
+	Symbol string `json:"symbol,omitempty"` // Generic format, e.g. BTCUSDT
+	Time   int64  `json:"time"`             // Event time, Unix milliseconds
+
+	// Order and fill events
+	OrderID       string  `json:"order_id,omitempty"`
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	TradeID       string  `json:"trade_id,omitempty"`      // Fill events only
+	Side          string  `json:"side,omitempty"`          // BUY or SELL
+	PositionSide  string  `json:"position_side,omitempty"` // LONG, SHORT or BOTH (one-way mode)
+	Status        string  `json:"status,omitempty"`        // Order events only, see OrderStatus*
+	Price         float64 `json:"price,omitempty"`         // Order price (0 for market orders), or fill price
+	Quantity      float64 `json:"quantity,omitempty"`      // Order quantity, or fill quantity
+	AvgPrice      float64 `json:"avg_price,omitempty"`     // Order events only
+	ExecutedQty   float64 `json:"executed_qty,omitempty"`  // Order events only, cumulative
+	Fee           float64 `json:"fee,omitempty"`           // Fill events only, positive when paid
+	RealizedPnL   float64 `json:"realized_pnl,omitempty"`  // Fill events only
+	ClosedQty     float64 `json:"closed_qty,omitempty"`    // Fill events only, part of Quantity that closed a position (Bybit)
+
+	// Position events
+	PositionAmt   float64 `json:"position_amt,omitempty"` // Always positive; 0 when the position is closed
+	EntryPrice    float64 `json:"entry_price,omitempty"`
+	UnrealizedPnL float64 `json:"unrealized_pnl,omitempty"`
+
+	// Balance events
+	Asset            string  `json:"asset,omitempty"`
+	WalletBalance    float64 `json:"wallet_balance,omitempty"`
+	AvailableBalance float64 `json:"available_balance,omitempty"` // 0 when the exchange doesn't report it
+}
+
+// IsFinal reports whether an order event has a terminal status
+func (e *UserDataEvent) IsFinal() bool {
+	switch e.Status {
+	case OrderStatusFilled, OrderStatusCanceled, OrderStatusExpired, OrderStatusRejected:
+		return true
+	}
+	return false
+}
+
+// UserDataStreamer optional interface of Traders that can stream order updates, fills, position
+// and balance changes over the exchange's private WebSocket
+type UserDataStreamer interface {
+	// StreamUserData connects and delivers events until ctx is done, then closes the channel.
+	// An error is returned when the first connection fails. Later disconnects send a
+	// UserDataDisconnect event and are retried with backoff; after each reconnect a
+	// UserDataReconnect event is sent and onReconnect (may be nil) is called to backfill the gap
+	// via REST.
+	StreamUserData(ctx context.Context, onReconnect func()) (<-chan UserDataEvent, error)
+}
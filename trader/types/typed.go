@@ -0,0 +1,371 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Typed (v2) trader results
+// ============================================================================
+//
+// The map results of Trader differ slightly between adapters (int vs float leverage, string vs
+// int64 order IDs, negative short quantities, missing keys). The structs below are the typed
+// contract; the Decode functions convert map results with checked conversions instead of the
+// unchecked type assertions that panic on such differences.
+
+// Position an open position
+type Position struct {
+	Symbol           string  `json:"symbol"`
+	Side             string  `json:"side"`     // "long" or "short"
+	Quantity         float64 `json:"quantity"` // Always positive
+	EntryPrice       float64 `json:"entry_price"`
+	MarkPrice        float64 `json:"mark_price"`
+	UnrealizedPnL    float64 `json:"unrealized_pnl"`
+	LiquidationPrice float64 `json:"liquidation_price"`
+	Leverage         int     `json:"leverage"`     // 0 when the exchange doesn't report it
+	CreatedTime      int64   `json:"created_time"` // Unix milliseconds, 0 when unknown
+}
+
+// AccountBalance account balance in the margin currency
+type AccountBalance struct {
+	TotalWalletBalance    float64 `json:"total_wallet_balance"` // Excluding unrealized PnL
+	AvailableBalance      float64 `json:"available_balance"`
+	TotalUnrealizedProfit float64 `json:"total_unrealized_profit"`
+	TotalEquity           float64 `json:"total_equity"` // Reported equity, else wallet balance + unrealized PnL
+}
+
+// OrderResult result of placing an order or querying its status
+type OrderResult struct {
+	OrderID     string  `json:"order_id"` // Empty or "0" when the exchange doesn't return one
+	Symbol      string  `json:"symbol"`
+	Status      string  `json:"status"`                 // FILLED, NEW, PARTIALLY_FILLED, CANCELED...
+	AvgPrice    float64 `json:"avg_price,omitempty"`    // 0 when unknown
+	ExecutedQty float64 `json:"executed_qty,omitempty"` // 0 when unknown
+	Commission  float64 `json:"commission,omitempty"`
+}
+
+// NumericOrderID returns the order ID as an integer, 0 if it isn't numeric
+func (o *OrderResult) NumericOrderID() int64 {
+	id, err := strconv.ParseInt(o.OrderID, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// TypedQueries typed account and order queries, implemented by adapters that build the structs
+// from the exchange response
+type TypedQueries interface {
+	// GetBalanceV2 Get account balance
+	GetBalanceV2() (*AccountBalance, error)
+
+	// GetPositionsV2 Get all open positions
+	GetPositionsV2() ([]Position, error)
+
+	// GetOrderStatusV2 Get order status
+	GetOrderStatusV2(symbol string, orderID string) (*OrderResult, error)
+}
+
+// TraderV2 typed counterparts of the map-returning Trader methods. Use AsTraderV2, which falls back
+// to decoding the map results for what an adapter doesn't implement natively.
+type TraderV2 interface {
+	TypedQueries
+
+	// OpenLongV2 Open long position
+	OpenLongV2(symbol string, quantity float64, leverage int) (*OrderResult, error)
+
+	// OpenShortV2 Open short position
+	OpenShortV2(symbol string, quantity float64, leverage int) (*OrderResult, error)
+
+	// CloseLongV2 Close long position (quantity=0 means close all)
+	CloseLongV2(symbol string, quantity float64) (*OrderResult, error)
+
+	// CloseShortV2 Close short position (quantity=0 means close all)
+	CloseShortV2(symbol string, quantity float64) (*OrderResult, error)
+}
+
+// AsTraderV2 returns the typed interface of a Trader: the Trader itself when it implements
+// TraderV2, else a shim using its TypedQueries if any and decoding its map results otherwise
+func AsTraderV2(t Trader) TraderV2 {
+	if v2, ok := t.(TraderV2); ok {
+		return v2
+	}
+	queries, ok := t.(TypedQueries)
+	if !ok {
+		queries = mapQueries{t}
+	}
+	return mapShim{TypedQueries: queries, t: t}
+}
+
+// mapQueries implements TypedQueries by decoding the results of a map-based Trader
+type mapQueries struct {
+	t Trader
+}
+
+func (q mapQueries) GetBalanceV2() (*AccountBalance, error) {
+	return DecodeBalance(q.t.GetBalance())
+}
+
+func (q mapQueries) GetPositionsV2() ([]Position, error) {
+	return DecodePositions(q.t.GetPositions())
+}
+
+func (q mapQueries) GetOrderStatusV2(symbol string, orderID string) (*OrderResult, error) {
+	return DecodeOrderResult(q.t.GetOrderStatus(symbol, orderID))
+}
+
+// mapShim implements the order methods of TraderV2 by decoding the results of a map-based Trader
+type mapShim struct {
+	TypedQueries
+	t Trader
+}
+
+func (s mapShim) OpenLongV2(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return DecodeOrderResult(s.t.OpenLong(symbol, quantity, leverage))
+}
+
+func (s mapShim) OpenShortV2(symbol string, quantity float64, leverage int) (*OrderResult, error) {
+	return DecodeOrderResult(s.t.OpenShort(symbol, quantity, leverage))
+}
+
+func (s mapShim) CloseLongV2(symbol string, quantity float64) (*OrderResult, error) {
+	return DecodeOrderResult(s.t.CloseLong(symbol, quantity))
+}
+
+func (s mapShim) CloseShortV2(symbol string, quantity float64) (*OrderResult, error) {
+	return DecodeOrderResult(s.t.CloseShort(symbol, quantity))
+}
+
+// DecodeBalance converts a GetBalance result. The error of the call is passed through, so it
+// can wrap the call directly: DecodeBalance(t.GetBalance())
+func DecodeBalance(m map[string]interface{}, err error) (*AccountBalance, error) {
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("empty balance")
+	}
+	var b AccountBalance
+	var ok bool
+	if b.TotalWalletBalance, ok, err = numberField(m, "totalWalletBalance"); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("balance has no totalWalletBalance")
+	}
+	if b.AvailableBalance, ok, err = numberField(m, "availableBalance"); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("balance has no availableBalance")
+	}
+	if b.TotalUnrealizedProfit, _, err = numberField(m, "totalUnrealizedProfit"); err != nil {
+		return nil, err
+	}
+	if b.TotalEquity, ok, err = numberField(m, "totalEquity", "total_equity"); err != nil {
+		return nil, err
+	}
+	if !ok || b.TotalEquity <= 0 {
+		b.TotalEquity = b.TotalWalletBalance + b.TotalUnrealizedProfit
+	}
+	return &b, nil
+}
+
+// DecodePositions converts a GetPositions result, dropping positions with zero quantity.
+// A malformed position is an error: the well-formed positions are still returned, for display,
+// but the list is incomplete and risk checks must not act on it.
+func DecodePositions(list []map[string]interface{}, err error) ([]Position, error) {
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]Position, 0, len(list))
+	var errs []error
+	for i, m := range list {
+		pos, err := DecodePosition(m)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("position #%d: %w", i+1, err))
+			continue
+		}
+		if pos.Quantity > 0 {
+			positions = append(positions, pos)
+		}
+	}
+	if len(errs) > 0 {
+		return positions, fmt.Errorf("malformed positions: %w", errors.Join(errs...))
+	}
+	return positions, nil
+}
+
+// DecodePosition converts one position of a GetPositions result
+func DecodePosition(m map[string]interface{}) (Position, error) {
+	var p Position
+	var ok bool
+	if p.Symbol, ok = m["symbol"].(string); !ok || p.Symbol == "" {
+		return p, fmt.Errorf("missing symbol")
+	}
+	amount, ok, err := numberField(m, "positionAmt")
+	if err != nil {
+		return p, fmt.Errorf("%s: %w", p.Symbol, err)
+	} else if !ok {
+		return p, fmt.Errorf("%s: missing positionAmt", p.Symbol)
+	}
+	p.Quantity = math.Abs(amount)
+
+	side, _ := m["side"].(string)
+	switch p.Side = strings.ToLower(side); p.Side {
+	case "long", "short":
+	case "":
+		// One-way mode: the sign of the amount gives the side
+		p.Side = "long"
+		if amount < 0 {
+			p.Side = "short"
+		}
+	default:
+		return p, fmt.Errorf("%s: invalid side %q", p.Symbol, side)
+	}
+
+	for _, f := range []struct {
+		dest *float64
+		key  string
+	}{
+		{&p.EntryPrice, "entryPrice"},
+		{&p.MarkPrice, "markPrice"},
+		{&p.UnrealizedPnL, "unRealizedProfit"},
+		{&p.LiquidationPrice, "liquidationPrice"},
+	} {
+		if *f.dest, _, err = numberField(m, f.key); err != nil {
+			return p, fmt.Errorf("%s: %w", p.Symbol, err)
+		}
+	}
+	leverage, _, err := numberField(m, "leverage")
+	if err != nil {
+		return p, fmt.Errorf("%s: %w", p.Symbol, err)
+	}
+	p.Leverage = int(leverage)
+	created, _, err := numberField(m, "createdTime")
+	if err != nil {
+		return p, fmt.Errorf("%s: %w", p.Symbol, err)
+	}
+	p.CreatedTime = int64(created)
+	return p, nil
+}
+
+// DecodeOrderResult converts the result of an order call or GetOrderStatus
+func DecodeOrderResult(m map[string]interface{}, err error) (*OrderResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("empty order result")
+	}
+	var o OrderResult
+	o.Symbol, _ = m["symbol"].(string)
+	o.Status, _ = m["status"].(string)
+	o.Status = strings.ToUpper(o.Status)
+	switch id := m["orderId"].(type) {
+	case nil:
+	case string:
+		o.OrderID = id
+	case int64:
+		o.OrderID = strconv.FormatInt(id, 10)
+	case int:
+		o.OrderID = strconv.Itoa(id)
+	default:
+		n, ok := toNumber(id)
+		if !ok {
+			return nil, fmt.Errorf("orderId has unsupported type %T", id)
+		}
+		o.OrderID = strconv.FormatInt(int64(n), 10)
+	}
+	for _, f := range []struct {
+		dest *float64
+		key  string
+	}{
+		{&o.AvgPrice, "avgPrice"},
+		{&o.ExecutedQty, "executedQty"},
+		{&o.Commission, "commission"},
+	} {
+		if *f.dest, _, err = numberField(m, f.key); err != nil {
+			return nil, err
+		}
+	}
+	return &o, nil
+}
+
+// NumberParser parses the numeric string fields of an exchange response, keeping the first error
+// so a batch of fields can be checked once
+type NumberParser struct {
+	Err error
+}
+
+// Float parses a decimal field, "" is 0
+func (p *NumberParser) Float(field, s string) float64 {
+	if s == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && p.Err == nil {
+		p.Err = fmt.Errorf("%s is not a number: %q", field, s)
+	}
+	return f
+}
+
+// Int parses an integer field such as a millisecond timestamp, "" is 0
+func (p *NumberParser) Int(field, s string) int64 {
+	if s == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil && p.Err == nil {
+		p.Err = fmt.Errorf("%s is not an integer: %q", field, s)
+	}
+	return n
+}
+
+// numberField reads the first present key as a number. ok is false when no key is present;
+// values that aren't numbers are an error.
+func numberField(m map[string]interface{}, keys ...string) (value float64, ok bool, err error) {
+	for _, key := range keys {
+		v, present := m[key]
+		if !present || v == nil {
+			continue
+		}
+		n, isNumber := toNumber(v)
+		if !isNumber {
+			return 0, false, fmt.Errorf("%s is not a number: %v (%T)", key, v, v)
+		}
+		return n, true, nil
+	}
+	return 0, false, nil
+}
+
+// toNumber converts numeric values of any Go type, numeric strings and json.Number
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		if n == "" {
+			return 0, true
+		}
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
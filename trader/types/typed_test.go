@@ -0,0 +1,174 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecodeBalance(t *testing.T) {
+	b, err := DecodeBalance(map[string]interface{}{
+		"totalWalletBalance":    "1000.5",
+		"availableBalance":      800,
+		"totalUnrealizedProfit": json.Number("-20.5"),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.TotalWalletBalance != 1000.5 || b.AvailableBalance != 800 || b.TotalEquity != 980 {
+		t.Errorf("unexpected balance: %+v", b)
+	}
+
+	b, err = DecodeBalance(map[string]interface{}{"totalWalletBalance": 100.0, "availableBalance": 50.0, "total_equity": 120.0}, nil)
+	if err != nil || b.TotalEquity != 120 {
+		t.Errorf("total_equity not used: %+v, %v", b, err)
+	}
+
+	for _, m := range []map[string]interface{}{
+		nil,
+		{"availableBalance": 1.0},
+		{"totalWalletBalance": 1.0, "availableBalance": true},
+		{"totalWalletBalance": "abc", "availableBalance": 1.0},
+	} {
+		if _, err := DecodeBalance(m, nil); err == nil {
+			t.Errorf("expected error for %v", m)
+		}
+	}
+
+	callErr := errors.New("timeout")
+	if _, err := DecodeBalance(nil, callErr); err != callErr {
+		t.Errorf("call error not passed through: %v", err)
+	}
+}
+
+func TestDecodePositions(t *testing.T) {
+	positions, err := DecodePositions([]map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "LONG", "positionAmt": "0.5", "entryPrice": 50000.0, "markPrice": "51000", "unRealizedProfit": 500.0, "leverage": 10, "createdTime": int64(1700000000000)},
+		{"symbol": "ETHUSDT", "positionAmt": -2.0, "entryPrice": 3000.0, "leverage": "5"},
+		{"symbol": "SOLUSDT", "side": "long", "positionAmt": 0.0},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("got %d positions, want 2 (zero quantity dropped): %+v", len(positions), positions)
+	}
+	btc, eth := positions[0], positions[1]
+	if btc.Side != "long" || btc.Quantity != 0.5 || btc.MarkPrice != 51000 || btc.Leverage != 10 || btc.CreatedTime != 1700000000000 {
+		t.Errorf("unexpected BTCUSDT position: %+v", btc)
+	}
+	if eth.Side != "short" || eth.Quantity != 2 || eth.Leverage != 5 || eth.LiquidationPrice != 0 {
+		t.Errorf("unexpected ETHUSDT position: %+v", eth)
+	}
+
+	malformed := []map[string]interface{}{
+		{"side": "long", "positionAmt": 1.0},
+		{"symbol": "BTCUSDT", "side": "long"},
+		{"symbol": "BTCUSDT", "side": "both", "positionAmt": 1.0},
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 1.0, "entryPrice": []float64{1}},
+	}
+	for _, m := range malformed {
+		if _, err := DecodePosition(m); err == nil {
+			t.Errorf("expected error for %v", m)
+		}
+	}
+
+	// Malformed positions are an error, the valid ones still come through
+	positions, err = DecodePositions(append(malformed, map[string]interface{}{"symbol": "ETHUSDT", "side": "short", "positionAmt": 1.0}), nil)
+	if err == nil {
+		t.Error("expected an error for the malformed positions")
+	}
+	if len(positions) != 1 || positions[0].Symbol != "ETHUSDT" {
+		t.Errorf("expected only ETHUSDT, got %+v", positions)
+	}
+}
+
+func TestDecodeOrderResult(t *testing.T) {
+	cases := []struct {
+		orderID interface{}
+		want    string
+		numeric int64
+	}{
+		{int64(9007199254740993), "9007199254740993", 9007199254740993}, // Beyond float64 precision
+		{42, "42", 42},
+		{float64(123), "123", 123},
+		{"0xabc-def", "0xabc-def", 0},
+		{nil, "", 0},
+	}
+	for _, tc := range cases {
+		o, err := DecodeOrderResult(map[string]interface{}{"orderId": tc.orderID, "symbol": "BTCUSDT", "status": "filled", "avgPrice": "50000.5"}, nil)
+		if err != nil {
+			t.Fatalf("orderId %v: %v", tc.orderID, err)
+		}
+		if o.OrderID != tc.want || o.NumericOrderID() != tc.numeric {
+			t.Errorf("orderId %v: got %q (%d)", tc.orderID, o.OrderID, o.NumericOrderID())
+		}
+		if o.Status != "FILLED" || o.AvgPrice != 50000.5 {
+			t.Errorf("unexpected order result: %+v", o)
+		}
+	}
+	if _, err := DecodeOrderResult(map[string]interface{}{"orderId": true}, nil); err == nil {
+		t.Error("expected error for bool orderId")
+	}
+}
+
+// mapTrader map-only Trader; unimplemented methods panic through the nil embedded interface
+type mapTrader struct {
+	Trader
+}
+
+func (mapTrader) GetBalance() (map[string]interface{}, error) {
+	return map[string]interface{}{"totalWalletBalance": 100.0, "availableBalance": 100.0}, nil
+}
+
+func (mapTrader) GetPositions() ([]map[string]interface{}, error) {
+	return []map[string]interface{}{{"symbol": "BTCUSDT", "side": "short", "positionAmt": -1.0}}, nil
+}
+
+func (mapTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return map[string]interface{}{"orderId": int64(7), "symbol": symbol}, nil
+}
+
+func TestAsTraderV2Shim(t *testing.T) {
+	trader := AsTraderV2(mapTrader{})
+	if b, err := trader.GetBalanceV2(); err != nil || b.TotalEquity != 100 {
+		t.Errorf("balance: %+v, %v", b, err)
+	}
+	if p, err := trader.GetPositionsV2(); err != nil || len(p) != 1 || p[0].Side != "short" || p[0].Quantity != 1 {
+		t.Errorf("positions: %+v, %v", p, err)
+	}
+	if o, err := trader.OpenLongV2("ETHUSDT", 1, 5); err != nil || o.OrderID != "7" || o.Symbol != "ETHUSDT" {
+		t.Errorf("open long: %+v, %v", o, err)
+	}
+}
+
+// queryTrader map-based Trader with native typed queries
+type queryTrader struct {
+	mapTrader
+}
+
+func (queryTrader) GetBalanceV2() (*AccountBalance, error) {
+	return &AccountBalance{TotalEquity: 42}, nil
+}
+
+func (queryTrader) GetPositionsV2() ([]Position, error) {
+	return []Position{{Symbol: "SOLUSDT", Side: "long", Quantity: 3}}, nil
+}
+
+func (queryTrader) GetOrderStatusV2(symbol string, orderID string) (*OrderResult, error) {
+	return &OrderResult{OrderID: orderID, Symbol: symbol, Status: OrderStatusFilled}, nil
+}
+
+func TestAsTraderV2NativeQueries(t *testing.T) {
+	trader := AsTraderV2(queryTrader{})
+	if b, err := trader.GetBalanceV2(); err != nil || b.TotalEquity != 42 {
+		t.Errorf("balance not from the native query: %+v, %v", b, err)
+	}
+	if p, err := trader.GetPositionsV2(); err != nil || len(p) != 1 || p[0].Symbol != "SOLUSDT" {
+		t.Errorf("positions not from the native query: %+v, %v", p, err)
+	}
+	// Order methods the adapter doesn't implement natively are still decoded
+	if o, err := trader.OpenLongV2("ETHUSDT", 1, 5); err != nil || o.OrderID != "7" {
+		t.Errorf("open long: %+v, %v", o, err)
+	}
+}
@@ -0,0 +1,415 @@
+package trader
+
+import (
+	"context"
+	"nofx/logger"
+	"nofx/market"
+	"nofx/store"
+	"nofx/trader/binance"
+	"nofx/trader/bybit"
+	"nofx/trader/okx"
+	"nofx/trader/types"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Exchange user data streams (real-time order updates, fills, position changes)
+// ============================================================================
+
+// userDataHub keeps the latest state of the orders seen on the user data stream, so order
+// confirmation and grid sync don't have to poll the exchange
+type userDataHub struct {
+	mu         sync.Mutex
+	orders     map[string]*trackedOrder   // Order ID -> latest state
+	waiters    map[string][]chan struct{} // Order ID -> waiters of a final status
+	connected  bool                       // False from a disconnect until the stream reconnected
+	synced     bool                       // Grid state reconciled via REST since the last (re)connect
+	gaps       int                        // Periods without stream events, the time before the stream started included
+	backfilled int                        // Gaps covered by the last completed REST order backfill
+}
+
+// fillRecorder traders that store a fill of their user data stream like their REST order sync
+// stores a trade
+type fillRecorder interface {
+	RecordFill(traderID, exchangeID, exchangeType string, st *store.Store, fill types.UserDataEvent) error
+}
+
+// trackedOrder latest order event and the fees of its fills
+type trackedOrder struct {
+	event types.UserDataEvent
+	fees  float64
+	seen  time.Time
+}
+
+// maxTrackedOrders orders older than trackedOrderTTL are pruned beyond this many
+const (
+	maxTrackedOrders = 1000
+	trackedOrderTTL  = 10 * time.Minute
+)
+
+func newUserDataHub() *userDataHub {
+	return &userDataHub{
+		orders:    make(map[string]*trackedOrder),
+		waiters:   make(map[string][]chan struct{}),
+		connected: true,
+		gaps:      1,
+	}
+}
+
+// handle records an order or fill event and wakes the waiters of orders reaching a final status
+func (h *userDataHub) handle(e types.UserDataEvent) {
+	if e.OrderID == "" || (e.Type != types.UserDataOrder && e.Type != types.UserDataFill) {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	o := h.orders[e.OrderID]
+	if o == nil {
+		if len(h.orders) >= maxTrackedOrders {
+			h.prune()
+		}
+		o = &trackedOrder{}
+		h.orders[e.OrderID] = o
+	}
+	o.seen = time.Now()
+	if e.Type == types.UserDataFill {
+		o.fees += e.Fee
+		return
+	}
+	o.event = e
+	if e.IsFinal() {
+		for _, ch := range h.waiters[e.OrderID] {
+			close(ch)
+		}
+		delete(h.waiters, e.OrderID)
+	}
+}
+
+func (h *userDataHub) prune() {
+	for id, o := range h.orders {
+		if time.Since(o.seen) > trackedOrderTTL {
+			delete(h.orders, id)
+		}
+	}
+}
+
+// finalStatus returns the order result once the order reached a final status
+func (h *userDataHub) finalStatus(orderID string) (*OrderResult, bool) {
+	o := h.orders[orderID]
+	if o == nil || !o.event.IsFinal() {
+		return nil, false
+	}
+	return &OrderResult{
+		OrderID:     orderID,
+		Symbol:      o.event.Symbol,
+		Status:      o.event.Status,
+		AvgPrice:    o.event.AvgPrice,
+		ExecutedQty: o.event.ExecutedQty,
+		Commission:  o.fees,
+	}, true
+}
+
+// waitFinal waits up to timeout for the order to reach a final status. Events received before the
+// call count, so orders filled before the order call returned are found immediately.
+func (h *userDataHub) waitFinal(orderID string, timeout time.Duration) (*OrderResult, bool) {
+	h.mu.Lock()
+	if result, ok := h.finalStatus(orderID); ok {
+		h.mu.Unlock()
+		return result, true
+	}
+	ch := make(chan struct{})
+	h.waiters[orderID] = append(h.waiters[orderID], ch)
+	h.mu.Unlock()
+
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// The fill fees may arrive after the final order event (Bybit execution topic)
+	return h.finalStatus(orderID)
+}
+
+func (h *userDataHub) setSynced(synced bool) {
+	h.mu.Lock()
+	h.synced = synced
+	h.mu.Unlock()
+}
+
+// isSynced reports whether the grid state is reconciled and kept current by a connected stream
+func (h *userDataHub) isSynced() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connected && h.synced
+}
+
+func (h *userDataHub) isConnected() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connected
+}
+
+// disconnected marks the start of a gap: the grid needs REST reconciliation, fills a REST
+// backfill, and order confirmations waiting on the stream fall back to polling
+func (h *userDataHub) disconnected() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connected = false
+	h.synced = false
+	h.gaps++
+	for id, chs := range h.waiters {
+		for _, ch := range chs {
+			close(ch)
+		}
+		delete(h.waiters, id)
+	}
+}
+
+// reconnected marks the end of a gap; the grid still reconciles via REST as events were missed
+func (h *userDataHub) reconnected() {
+	h.mu.Lock()
+	h.connected = true
+	h.synced = false
+	h.mu.Unlock()
+}
+
+// gapCount returns the gaps so far, passed to setBackfilled once a backfill started now completed
+func (h *userDataHub) gapCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.gaps
+}
+
+func (h *userDataHub) setBackfilled(gaps int) {
+	h.mu.Lock()
+	if gaps > h.backfilled {
+		h.backfilled = gaps
+	}
+	h.mu.Unlock()
+}
+
+// isContinuous reports whether the stream has been connected since the last REST order backfill,
+// so fills can be recorded from events without leaving missed trades behind (Binance resumes its
+// trade sync from the last recorded trade ID)
+func (h *userDataHub) isContinuous() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.connected && h.backfilled == h.gaps
+}
+
+// startUserDataStream streams user data events when the exchange supports it. Polling remains the
+// fallback for exchanges without streams and while the stream is down.
+func (at *AutoTrader) startUserDataStream() {
+	streamer, ok := at.trader.(types.UserDataStreamer)
+	if !ok {
+		return
+	}
+
+	// The hub of a previous run must not be waited on
+	at.setUserData(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopCh := at.stopMonitorCh
+	hub := newUserDataHub()
+	events, err := streamer.StreamUserData(ctx, func() {
+		// Backfill fills missed while disconnected
+		at.syncOrdersNow(hub)
+	})
+	if err != nil {
+		cancel()
+		logger.Warnf("⚠️  [%s] User data stream unavailable, falling back to polling: %v", at.name, err)
+		return
+	}
+	at.setUserData(hub)
+	// Backfill fills from before the stream started
+	at.syncOrdersNow(hub)
+
+	at.monitorWg.Add(1)
+	go func() {
+		defer at.monitorWg.Done()
+		defer cancel()
+		defer at.clearUserData(hub)
+		for {
+			select {
+			case <-stopCh:
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				at.handleUserDataEvent(hub, e)
+			}
+		}
+	}()
+	logger.Infof("📡 [%s] Real-time order updates enabled via user data stream", at.name)
+}
+
+// getUserData returns the hub of the running user data stream, nil when not streaming
+func (at *AutoTrader) getUserData() *userDataHub {
+	at.userDataMutex.RLock()
+	defer at.userDataMutex.RUnlock()
+	return at.userData
+}
+
+func (at *AutoTrader) setUserData(hub *userDataHub) {
+	at.userDataMutex.Lock()
+	at.userData = hub
+	at.userDataMutex.Unlock()
+}
+
+// clearUserData unsets the hub when its stream stopped, unless a newer stream replaced it
+func (at *AutoTrader) clearUserData(hub *userDataHub) {
+	at.userDataMutex.Lock()
+	if at.userData == hub {
+		at.userData = nil
+	}
+	at.userDataMutex.Unlock()
+}
+
+// handleUserDataEvent applies one stream event
+func (at *AutoTrader) handleUserDataEvent(hub *userDataHub, e types.UserDataEvent) {
+	switch e.Type {
+	case types.UserDataDisconnect:
+		// Events are missed from now on: the grid reconciles via REST on its next sync
+		hub.disconnected()
+	case types.UserDataReconnect:
+		hub.reconnected()
+	case types.UserDataOrder:
+		hub.handle(e)
+		if e.IsFinal() && at.gridState != nil {
+			at.applyGridOrderEvent(e)
+		}
+	case types.UserDataFill:
+		hub.handle(e)
+		at.recordFill(hub, e)
+	}
+}
+
+// recordFill stores a fill from its event. Until a REST backfill covered the last gap the fill is
+// left to the backfill, which would otherwise resume after it and skip the trades missed.
+func (at *AutoTrader) recordFill(hub *userDataHub, e types.UserDataEvent) {
+	recorder, ok := at.trader.(fillRecorder)
+	if !ok || at.store == nil || !hub.isContinuous() {
+		at.syncOrdersNow(hub)
+		return
+	}
+	if err := recorder.RecordFill(at.id, at.exchangeID, at.exchange, at.store, e); err != nil {
+		logger.Infof("⚠️  [%s] Failed to record fill %s, backfilling: %v", at.name, e.TradeID, err)
+		at.syncOrdersNow(hub)
+	}
+}
+
+// orderBackfillDelay lets the exchange's trade history catch up with the stream before a backfill
+const orderBackfillDelay = 2 * time.Second
+
+// syncOrdersNow backfills fills missed by the user data stream with the REST order sync, in the
+// background. Calls while a sync is running are coalesced into one follow-up sync.
+func (at *AutoTrader) syncOrdersNow(hub *userDataHub) {
+	if at.store == nil {
+		return
+	}
+	at.orderSyncMutex.Lock()
+	if at.orderSyncRunning {
+		at.orderSyncPending = true
+		at.orderSyncMutex.Unlock()
+		return
+	}
+	at.orderSyncRunning = true
+	at.orderSyncMutex.Unlock()
+
+	go func() {
+		for {
+			time.Sleep(orderBackfillDelay)
+			gaps := hub.gapCount()
+			var err error
+			switch t := at.trader.(type) {
+			case *binance.FuturesTrader:
+				err = t.SyncOrdersFromBinance(at.id, at.exchangeID, at.exchange, at.store)
+			case *bybit.BybitTrader:
+				err = t.SyncOrdersFromBybit(at.id, at.exchangeID, at.exchange, at.store)
+			case *okx.OKXTrader:
+				err = t.SyncOrdersFromOKX(at.id, at.exchangeID, at.exchange, at.store)
+			}
+			if err != nil {
+				logger.Infof("⚠️  [%s] Order backfill failed: %v", at.name, err)
+			} else {
+				hub.setBackfilled(gaps)
+			}
+
+			at.orderSyncMutex.Lock()
+			if !at.orderSyncPending {
+				at.orderSyncRunning = false
+				at.orderSyncMutex.Unlock()
+				return
+			}
+			at.orderSyncPending = false
+			at.orderSyncMutex.Unlock()
+		}
+	}()
+}
+
+// awaitOrderResult waits for the order to reach a final status: from the user data stream when
+// available, else by polling the order status. Returns nil if the order isn't final in time.
+func (at *AutoTrader) awaitOrderResult(symbol, orderID string) *OrderResult {
+	if hub := at.getUserData(); hub != nil && hub.isConnected() {
+		if result, ok := hub.waitFinal(orderID, 3*time.Second); ok {
+			return result
+		}
+		logger.Infof("  ⚠️ No final status for order %s on the user data stream, polling", orderID)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		result, err := AsTraderV2(at.trader).GetOrderStatusV2(symbol, orderID)
+		if err == nil {
+			switch result.Status {
+			case types.OrderStatusFilled, types.OrderStatusCanceled, types.OrderStatusExpired, types.OrderStatusRejected:
+				return result
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil
+}
+
+// applyGridOrderEvent updates the grid level of an order that reached a final status
+func (at *AutoTrader) applyGridOrderEvent(e types.UserDataEvent) {
+	at.gridState.mu.Lock()
+	defer at.gridState.mu.Unlock()
+
+	if gridConfig := at.gridState.Config; gridConfig != nil && market.Normalize(gridConfig.Symbol) != market.Normalize(e.Symbol) {
+		return
+	}
+	i, ok := at.gridState.OrderBook[e.OrderID]
+	if !ok || i < 0 || i >= len(at.gridState.Levels) {
+		return
+	}
+	level := &at.gridState.Levels[i]
+	if level.State != "pending" || level.OrderID != e.OrderID {
+		return
+	}
+	delete(at.gridState.OrderBook, e.OrderID)
+
+	if e.Status == types.OrderStatusFilled {
+		level.State = "filled"
+		level.PositionEntry = level.Price
+		if e.AvgPrice > 0 {
+			level.PositionEntry = e.AvgPrice
+		}
+		level.PositionSize = level.OrderQuantity
+		if e.ExecutedQty > 0 {
+			level.PositionSize = e.ExecutedQty
+		}
+		at.gridState.TotalTrades++
+		logger.Infof("[Grid] Level %d order filled at $%.2f", i, level.PositionEntry)
+		return
+	}
+	level.State = "empty"
+	level.OrderID = ""
+	level.OrderQuantity = 0
+	logger.Infof("[Grid] Level %d order %s", i, e.Status)
+}
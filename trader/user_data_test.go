@@ -0,0 +1,106 @@
+package trader
+
+import (
+	"nofx/kernel"
+	"nofx/store"
+	"nofx/trader/types"
+	"testing"
+	"time"
+)
+
+func TestUserDataHubWaitFinal(t *testing.T) {
+	hub := newUserDataHub()
+
+	// Filled before the wait starts (market order filled before the order call returned)
+	hub.handle(types.UserDataEvent{Type: types.UserDataFill, OrderID: "1", Fee: 0.1})
+	hub.handle(types.UserDataEvent{Type: types.UserDataFill, OrderID: "1", Fee: 0.2})
+	hub.handle(types.UserDataEvent{Type: types.UserDataOrder, OrderID: "1", Symbol: "BTCUSDT", Status: types.OrderStatusFilled, AvgPrice: 100, ExecutedQty: 2})
+	result, ok := hub.waitFinal("1", time.Second)
+	if !ok || result.Status != "FILLED" || result.AvgPrice != 100 || result.ExecutedQty != 2 || result.Commission < 0.29 || result.Commission > 0.31 {
+		t.Errorf("unexpected result: %+v, %v", result, ok)
+	}
+
+	// Canceled while waiting; partial states don't end the wait
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		hub.handle(types.UserDataEvent{Type: types.UserDataOrder, OrderID: "2", Status: types.OrderStatusPartiallyFilled})
+		hub.handle(types.UserDataEvent{Type: types.UserDataOrder, OrderID: "2", Status: types.OrderStatusCanceled})
+	}()
+	if result, ok := hub.waitFinal("2", 5*time.Second); !ok || result.Status != "CANCELED" {
+		t.Errorf("unexpected result: %+v, %v", result, ok)
+	}
+
+	if _, ok := hub.waitFinal("3", 10*time.Millisecond); ok {
+		t.Error("expected timeout for unknown order")
+	}
+}
+
+func TestApplyGridOrderEvent(t *testing.T) {
+	at := &AutoTrader{gridState: &GridState{
+		Config: &store.GridStrategyConfig{Symbol: "BTCUSDT"},
+		Levels: []kernel.GridLevelInfo{
+			{Price: 100, State: "pending", OrderID: "a", OrderQuantity: 1},
+			{Price: 110, State: "pending", OrderID: "b", OrderQuantity: 1},
+		},
+		OrderBook: map[string]int{"a": 0, "b": 1},
+	}}
+
+	at.applyGridOrderEvent(types.UserDataEvent{Type: types.UserDataOrder, Symbol: "ETHUSDT", OrderID: "a", Status: types.OrderStatusFilled})
+	if at.gridState.Levels[0].State != "pending" {
+		t.Fatal("event of another symbol applied")
+	}
+
+	at.applyGridOrderEvent(types.UserDataEvent{Type: types.UserDataOrder, Symbol: "BTCUSDT", OrderID: "a", Status: types.OrderStatusFilled, AvgPrice: 99.5, ExecutedQty: 0.9})
+	at.applyGridOrderEvent(types.UserDataEvent{Type: types.UserDataOrder, Symbol: "BTCUSDT", OrderID: "b", Status: types.OrderStatusCanceled})
+
+	filled, canceled := at.gridState.Levels[0], at.gridState.Levels[1]
+	if filled.State != "filled" || filled.PositionEntry != 99.5 || filled.PositionSize != 0.9 || at.gridState.TotalTrades != 1 {
+		t.Errorf("unexpected filled level: %+v", filled)
+	}
+	if canceled.State != "empty" || canceled.OrderID != "" || len(at.gridState.OrderBook) != 0 {
+		t.Errorf("unexpected canceled level: %+v, order book %v", canceled, at.gridState.OrderBook)
+	}
+}
+
+func TestUserDataHubGaps(t *testing.T) {
+	hub := newUserDataHub()
+	if hub.isContinuous() {
+		t.Fatal("fills before the first backfill must be left to the backfill")
+	}
+	hub.setBackfilled(hub.gapCount())
+	hub.setSynced(true)
+	if !hub.isContinuous() || !hub.isSynced() {
+		t.Fatal("expected a continuous, synced stream after the backfill")
+	}
+
+	// A backfill started before the drop doesn't cover it
+	started := hub.gapCount()
+	waiting := make(chan bool)
+	go func() {
+		_, ok := hub.waitFinal("1", 5*time.Second)
+		waiting <- ok
+	}()
+	time.Sleep(20 * time.Millisecond)
+	hub.disconnected()
+	select {
+	case ok := <-waiting:
+		if ok {
+			t.Error("unexpected final status")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("order confirmation still waiting on a disconnected stream")
+	}
+	if hub.isSynced() || hub.isConnected() {
+		t.Fatal("expected an unsynced, disconnected stream after the drop")
+	}
+	hub.setBackfilled(started)
+	hub.reconnected()
+	if hub.isContinuous() || hub.isSynced() {
+		t.Fatal("expected the gap to need a backfill and a grid reconciliation")
+	}
+
+	hub.setBackfilled(hub.gapCount())
+	if !hub.isContinuous() {
+		t.Error("expected a continuous stream once the backfill covered the gap")
+	}
+}
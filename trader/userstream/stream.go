@@ -0,0 +1,261 @@
+// Package userstream runs exchange private WebSocket (user-data) connections with keepalive,
+// reconnect and resubscribe. Exchange adapters provide the URL, the authentication/subscription
+// handshake and the message parser.
+package userstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"nofx/logger"
+	"nofx/trader/types"
+)
+
+// Defaults of the optional Config durations
+const (
+	DefaultPingInterval = 20 * time.Second
+	DefaultReadTimeout  = 60 * time.Second
+	DefaultMinBackoff   = 1 * time.Second
+	DefaultMaxBackoff   = 60 * time.Second
+)
+
+// ErrReconnect returned (wrapped) by Parse to drop the connection and reconnect, e.g. when the
+// exchange reports that the listen key expired
+var ErrReconnect = errors.New("reconnect requested")
+
+// Config private stream of one exchange account
+type Config struct {
+	// Name exchange name for logs
+	Name string
+
+	// URL returns the URL to dial. It is called on every (re)connect, so it can create or refresh
+	// a listen key.
+	URL func(ctx context.Context) (string, error)
+
+	// OnConnect authenticates and subscribes on a new connection before messages are read.
+	// It runs again after every reconnect, which resubscribes. May be nil.
+	OnConnect func(conn *websocket.Conn) error
+
+	// Parse converts a message into events; acks and pongs return no events. Errors are logged and
+	// the message skipped, except ErrReconnect.
+	Parse func(msg []byte) ([]types.UserDataEvent, error)
+
+	// PingMessage application-level ping sent every PingInterval; nil sends WebSocket ping frames
+	PingMessage  []byte
+	PingInterval time.Duration
+
+	// KeepAlive is called every KeepAliveInterval while connected (e.g. to extend a listen key).
+	// May be nil.
+	KeepAlive         func(ctx context.Context) error
+	KeepAliveInterval time.Duration
+
+	// ReadTimeout the connection is considered dead after this long without a message
+	ReadTimeout time.Duration
+
+	// MinBackoff, MaxBackoff reconnect delay bounds; the delay doubles after each failed attempt
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Start connects and returns the event channel. The first connection is made synchronously so
+// that bad credentials are reported; afterwards the stream sends a types.UserDataDisconnect event
+// when the connection drops, reconnects until ctx is done, sends a types.UserDataReconnect event
+// after each reconnect and calls onReconnect (may be nil) asynchronously so callers can backfill
+// the gap. The channel is closed when ctx is done.
+func Start(ctx context.Context, cfg Config, onReconnect func()) (<-chan types.UserDataEvent, error) {
+	if cfg.URL == nil || cfg.Parse == nil {
+		return nil, fmt.Errorf("user data stream needs URL and Parse")
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = DefaultPingInterval
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = DefaultReadTimeout
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+
+	conn, err := cfg.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("📡 [%s] User data stream connected", cfg.Name)
+
+	events := make(chan types.UserDataEvent, 256)
+	go cfg.run(ctx, conn, events, onReconnect)
+	return events, nil
+}
+
+// connect dials and performs the OnConnect handshake
+func (cfg *Config) connect(ctx context.Context) (*websocket.Conn, error) {
+	url, err := cfg.URL(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream URL: %w", err)
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if cfg.OnConnect != nil {
+		conn.SetReadDeadline(time.Now().Add(cfg.ReadTimeout))
+		if err := cfg.OnConnect(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Request sends a JSON request during OnConnect and reads messages until reply recognizes the
+// response (done) or reports an error. Other messages are skipped.
+func Request(conn *websocket.Conn, req interface{}, reply func(msg []byte) (done bool, err error)) error {
+	if err := conn.WriteJSON(req); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		done, err := reply(msg)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// run serves connections until ctx is done, reconnecting with exponential backoff
+func (cfg *Config) run(ctx context.Context, conn *websocket.Conn, events chan<- types.UserDataEvent, onReconnect func()) {
+	defer close(events)
+	for {
+		err := cfg.serve(ctx, conn, events)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warnf("⚠️  [%s] User data stream disconnected: %v", cfg.Name, err)
+		select {
+		case events <- types.UserDataEvent{Type: types.UserDataDisconnect, Time: time.Now().UnixMilli()}:
+		case <-ctx.Done():
+			return
+		}
+
+		backoff := cfg.MinBackoff
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if conn, err = cfg.connect(ctx); err == nil {
+				break
+			}
+			logger.Warnf("⚠️  [%s] User data stream reconnect failed (retry in %v): %v", cfg.Name, backoff, err)
+			if backoff *= 2; backoff > cfg.MaxBackoff {
+				backoff = cfg.MaxBackoff
+			}
+		}
+		logger.Infof("📡 [%s] User data stream reconnected", cfg.Name)
+
+		select {
+		case events <- types.UserDataEvent{Type: types.UserDataReconnect, Time: time.Now().UnixMilli()}:
+		case <-ctx.Done():
+			conn.Close()
+			return
+		}
+		if onReconnect != nil {
+			go onReconnect()
+		}
+	}
+}
+
+// serve reads one connection until it fails or ctx is done; the connection is closed on return
+func (cfg *Config) serve(ctx context.Context, conn *websocket.Conn, events chan<- types.UserDataEvent) error {
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// Unblock ReadMessage when ctx is done or the pingers give up
+		<-connCtx.Done()
+		conn.Close()
+	}()
+	go cfg.ping(connCtx, cancel, conn)
+	if cfg.KeepAlive != nil && cfg.KeepAliveInterval > 0 {
+		go cfg.keepAlive(connCtx, cancel)
+	}
+
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cfg.ReadTimeout))
+	})
+	for {
+		conn.SetReadDeadline(time.Now().Add(cfg.ReadTimeout))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		parsed, err := cfg.Parse(msg)
+		if errors.Is(err, ErrReconnect) {
+			return err
+		}
+		if err != nil {
+			logger.Warnf("⚠️  [%s] Unparsable user data message: %v", cfg.Name, err)
+			continue
+		}
+		for _, event := range parsed {
+			select {
+			case events <- event:
+			case <-connCtx.Done():
+				return connCtx.Err()
+			}
+		}
+	}
+}
+
+// ping is the only writer of the connection once it is served
+func (cfg *Config) ping(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn) {
+	ticker := time.NewTicker(cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var err error
+		if cfg.PingMessage != nil {
+			err = conn.WriteMessage(websocket.TextMessage, cfg.PingMessage)
+		} else {
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+		}
+		if err != nil {
+			cancel()
+			return
+		}
+	}
+}
+
+func (cfg *Config) keepAlive(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(cfg.KeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := cfg.KeepAlive(ctx); err != nil {
+			// Reconnecting gets a fresh URL (listen key)
+			logger.Warnf("⚠️  [%s] User data stream keepalive failed: %v", cfg.Name, err)
+			cancel()
+			return
+		}
+	}
+}
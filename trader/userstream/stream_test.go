@@ -0,0 +1,145 @@
+package userstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"nofx/trader/types"
+)
+
+// fakeServer WebSocket server that expects an auth message, acknowledges it, then pushes
+// the messages of the connection (by connection number)
+func fakeServer(t *testing.T, messages func(n int) []string) (*httptest.Server, *int32) {
+	var connections int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := int(atomic.AddInt32(&connections, 1))
+
+		var auth map[string]string
+		if err := conn.ReadJSON(&auth); err != nil || auth["op"] != "auth" {
+			return
+		}
+		conn.WriteJSON(map[string]string{"op": "ack", "key": auth["key"]})
+		for _, msg := range messages(n) {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		}
+		// Keep the connection open until the client leaves
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &connections
+}
+
+func testConfig(srv *httptest.Server, key string) Config {
+	return Config{
+		Name: "Fake",
+		URL: func(ctx context.Context) (string, error) {
+			return "ws" + strings.TrimPrefix(srv.URL, "http"), nil
+		},
+		OnConnect: func(conn *websocket.Conn) error {
+			return Request(conn, map[string]string{"op": "auth", "key": key}, func(msg []byte) (bool, error) {
+				var ack map[string]string
+				if err := json.Unmarshal(msg, &ack); err != nil || ack["op"] != "ack" {
+					return false, nil
+				}
+				if ack["key"] != "secret" {
+					return false, fmt.Errorf("bad key")
+				}
+				return true, nil
+			})
+		},
+		Parse: func(msg []byte) ([]types.UserDataEvent, error) {
+			switch s := string(msg); {
+			case s == "expired":
+				return nil, fmt.Errorf("key expired: %w", ErrReconnect)
+			case strings.HasPrefix(s, "order:"):
+				return []types.UserDataEvent{{Type: types.UserDataOrder, OrderID: strings.TrimPrefix(s, "order:")}}, nil
+			}
+			return nil, fmt.Errorf("unknown message %q", msg)
+		},
+		MinBackoff: 10 * time.Millisecond,
+	}
+}
+
+func next(t *testing.T, events <-chan types.UserDataEvent) types.UserDataEvent {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("event channel closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	return types.UserDataEvent{}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	srv, connections := fakeServer(t, func(n int) []string {
+		if n == 1 {
+			return []string{"order:1", "garbage", "expired"}
+		}
+		return []string{fmt.Sprintf("order:%d", n)}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	backfilled := make(chan struct{}, 1)
+	events, err := Start(ctx, testConfig(srv, "secret"), func() { backfilled <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := next(t, events); e.Type != types.UserDataOrder || e.OrderID != "1" {
+		t.Fatalf("unexpected first event: %+v", e)
+	}
+	// The unparsable message is skipped, "expired" forces a reconnect with a new handshake
+	if e := next(t, events); e.Type != types.UserDataDisconnect {
+		t.Fatalf("expected disconnect event, got %+v", e)
+	}
+	if e := next(t, events); e.Type != types.UserDataReconnect {
+		t.Fatalf("expected reconnect event, got %+v", e)
+	}
+	if e := next(t, events); e.OrderID != "2" {
+		t.Fatalf("unexpected event after reconnect: %+v", e)
+	}
+	select {
+	case <-backfilled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onReconnect not called")
+	}
+	if n := atomic.LoadInt32(connections); n != 2 {
+		t.Errorf("got %d connections, want 2", n)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestStreamHandshakeFailure(t *testing.T) {
+	srv, _ := fakeServer(t, func(int) []string { return nil })
+	if _, err := Start(context.Background(), testConfig(srv, "wrong"), nil); err == nil || !strings.Contains(err.Error(), "bad key") {
+		t.Errorf("expected handshake error, got %v", err)
+	}
+}
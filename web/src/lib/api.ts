@@ -644,6 +644,21 @@ export const api = {
     return res.blob()
   },
 
+  async importBacktest(file: File, runId?: string): Promise<BacktestRunMetadata> {
+    const form = new FormData()
+    form.append('file', file)
+    if (runId) form.append('run_id', runId)
+    // Let the browser set the multipart boundary
+    const headers = getAuthHeaders()
+    delete headers['Content-Type']
+    const res = await fetch(`${API_BASE}/backtest/import`, {
+      method: 'POST',
+      headers,
+      body: form,
+    })
+    return handleJSONResponse<BacktestRunMetadata>(res)
+  },
+
   // Strategy APIs
   async getStrategies(): Promise<Strategy[]> {
     const result = await httpClient.get<{ strategies: Strategy[] }>(`${API_BASE}/strategies`)
@@ -338,6 +338,7 @@ export interface BacktestTradeEvent {
   cycle: number;
   position_after: number;
   liquidation: boolean;
+  close_reason?: string; // signal | stop_loss | take_profit | liquidation
   note?: string;
 }
 
@@ -353,17 +354,46 @@ export interface BacktestMetrics {
   best_symbol: string;
   worst_symbol: string;
   liquidated: boolean;
-  symbol_stats?: Record<
-    string,
-    {
-      total_trades: number;
-      winning_trades: number;
-      losing_trades: number;
-      total_pnl: number;
-      avg_pnl: number;
-      win_rate: number;
-    }
-  >;
+  symbol_stats?: Record<string, BacktestPnLStats>;
+  side_stats?: Record<string, BacktestPnLStats>; // long | short
+  annualized_return_pct?: number;
+  sortino_ratio?: number;
+  calmar_ratio?: number;
+  ulcer_index?: number;
+  longest_drawdown_hours?: number;
+  time_in_market_pct?: number;
+  avg_exposure_pct?: number;
+  avg_leverage?: number;
+  total_fees?: number;
+  total_slippage?: number;
+  fee_drag_pct?: number;
+  slippage_drag_pct?: number;
+  monthly_returns?: {
+    month: string; // YYYY-MM
+    start_equity: number;
+    end_equity: number;
+    return_pct: number;
+  }[];
+  benchmark?: {
+    symbol: string;
+    start_price: number;
+    end_price: number;
+    return_pct: number;
+    max_drawdown_pct: number;
+    excess_return_pct: number;
+  };
+}
+
+export interface BacktestPnLStats {
+  total_trades: number;
+  winning_trades: number;
+  losing_trades: number;
+  total_pnl: number;
+  avg_pnl: number;
+  win_rate: number;
+  long_pnl?: number;
+  short_pnl?: number;
+  fees?: number;
 }
 
 export interface BacktestStartConfig {
@@ -378,8 +408,11 @@ export interface BacktestStartConfig {
   end_ts: number;
   initial_balance: number;
   fee_bps: number;
+  fee_exchange?: string;
   slippage_bps: number;
   fill_policy: string;
+  max_participation?: number;
+  intrabar_tie_break?: 'stop_first' | 'target_first' | 'nearest_open';
   prompt_variant?: string;
   prompt_template?: string;
   custom_prompt?: string;
@@ -390,6 +423,7 @@ export interface BacktestStartConfig {
   checkpoint_interval_seconds?: number;
   replay_decision_dir?: string;
   shared_ai_cache_path?: string;
+  grid_config?: GridStrategyConfig; // Optional: simulate a grid strategy instead of AI decisions
   ai?: {
     provider?: string;
     model?: string;
@@ -567,6 +601,10 @@ export interface IndicatorConfig {
   enable_price_ranking?: boolean;
   price_ranking_duration?: string;  // "1h", "4h", "24h" or "1h,4h,24h"
   price_ranking_limit?: number;
+
+  // Tool calling: AI fetches candidate data on demand instead of preloading it
+  enable_tool_calling?: boolean;
+  tool_max_iterations?: number;
 }
 
 export interface KlineConfig {